@@ -153,9 +153,24 @@ func (s *appState) modelForm(index int) tview.Primitive {
 	addIntInput(form, "RPM", model.RPM, func(value int) {
 		model.RPM = value
 	})
+	addIntInput(form, "TPM", model.TPM, func(value int) {
+		model.TPM = value
+	})
+	addIntInput(form, "Max Concurrent", model.MaxConcurrent, func(value int) {
+		model.MaxConcurrent = value
+	})
+	addInput(form, "Load Balance", model.LoadBalance, func(value string) {
+		model.LoadBalance = value
+	})
+	addIntInput(form, "Weight", model.Weight, func(value int) {
+		model.Weight = value
+	})
 	addIntInput(form, "Request Timeout", model.RequestTimeout, func(value int) {
 		model.RequestTimeout = value
 	})
+	addIntInput(form, "Context Window", model.ContextWindow, func(value int) {
+		model.ContextWindow = value
+	})
 
 	form.AddButton("Delete", func() {
 		s.deleteModel(index)
@@ -16,6 +16,7 @@ import (
 	"github.com/sipeed/picoclaw/pkg/agent"
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/mcp"
 	"github.com/sipeed/picoclaw/pkg/providers"
 	"github.com/sipeed/picoclaw/pkg/tools"
 	"github.com/sipeed/picoclaw/pkg/update"
@@ -77,6 +78,9 @@ func agentCmd(message, sessionKey, model string, debug bool) error {
 	if err != nil {
 		return fmt.Errorf("error creating provider: %w", err)
 	}
+	if sp, ok := provider.(providers.StatefulProvider); ok {
+		defer sp.Close()
+	}
 
 	// Use the resolved model ID from provider creation
 	if modelID != "" {
@@ -85,7 +89,9 @@ func agentCmd(message, sessionKey, model string, debug bool) error {
 
 	msgBus := bus.NewMessageBus()
 	defer msgBus.Close()
+	mcp.ClientVersion = internal.GetVersion()
 	agentLoop := agent.NewAgentLoop(cfg, msgBus, provider)
+	defer agentLoop.Close()
 
 	// Spinner guard used by both the interactive loop and the permission
 	// prompt callbacks. In non-interactive mode the spinner is still created
@@ -431,6 +431,9 @@ func authStatusCmd() error {
 		if cred.ProjectID != "" {
 			fmt.Printf("    Project: %s\n", cred.ProjectID)
 		}
+		if len(cred.APIKeys) > 0 {
+			fmt.Printf("    API keys: %d\n", len(cred.APIKeys))
+		}
 		if !cred.ExpiresAt.IsZero() {
 			fmt.Printf("    Expires: %s\n", cred.ExpiresAt.Format("2006-01-02 15:04"))
 		}
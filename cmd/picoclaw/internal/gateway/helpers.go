@@ -32,6 +32,7 @@ import (
 	"github.com/sipeed/picoclaw/pkg/health"
 	"github.com/sipeed/picoclaw/pkg/heartbeat"
 	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/mcp"
 	"github.com/sipeed/picoclaw/pkg/media"
 	"github.com/sipeed/picoclaw/pkg/providers"
 	"github.com/sipeed/picoclaw/pkg/state"
@@ -61,8 +62,20 @@ func gatewayCmd(debug bool) error {
 	}
 
 	msgBus := bus.NewMessageBus()
+	mcp.ClientVersion = internal.GetVersion()
 	agentLoop := agent.NewAgentLoop(cfg, msgBus, provider)
 
+	// Refresh model capabilities in the background; agents pick them up on the next start.
+	go func() {
+		capabilities := agentLoop.Capabilities()
+		if !capabilities.Stale(providers.CapabilityCacheTTL) {
+			return
+		}
+		if err := capabilities.Refresh(context.Background(), cfg); err != nil {
+			logger.WarnCF("gateway", "Model capability discovery incomplete", map[string]any{"error": err.Error()})
+		}
+	}()
+
 	// Print agent startup info
 	fmt.Println("\n📦 Agent Status:")
 	startupInfo := agentLoop.GetStartupInfo()
@@ -227,6 +240,7 @@ func gatewayCmd(debug bool) error {
 	cronService.Stop()
 	mediaStore.Stop()
 	agentLoop.Stop()
+	agentLoop.Close()
 	fmt.Println("✓ Gateway stopped")
 
 	return nil
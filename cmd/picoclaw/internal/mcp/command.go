@@ -0,0 +1,20 @@
+package mcp
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func NewMCPCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "mcp",
+		Short: "Model Context Protocol server",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return cmd.Help()
+		},
+	}
+
+	cmd.AddCommand(newServeCommand())
+
+	return cmd
+}
@@ -0,0 +1,49 @@
+package mcp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMCPCommand(t *testing.T) {
+	cmd := NewMCPCommand()
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "mcp", cmd.Use)
+	assert.Equal(t, "Model Context Protocol server", cmd.Short)
+
+	assert.Nil(t, cmd.Run)
+	assert.NotNil(t, cmd.RunE)
+
+	assert.True(t, cmd.HasSubCommands())
+
+	serve, _, err := cmd.Find([]string{"serve"})
+	require.NoError(t, err)
+	assert.Equal(t, "serve", serve.Name())
+	assert.NotNil(t, serve.RunE)
+
+	for _, flag := range []string{"http", "agent", "token", "exclude", "debug"} {
+		assert.NotNil(t, serve.Flags().Lookup(flag), "missing flag %q", flag)
+	}
+	assert.Equal(t, "[message,spawn,subagent]", serve.Flags().Lookup("exclude").DefValue)
+}
+
+func TestIsLoopback(t *testing.T) {
+	assert.True(t, isLoopback("127.0.0.1:18795"))
+	assert.True(t, isLoopback("localhost:18795"))
+	assert.True(t, isLoopback("[::1]:18795"))
+	assert.False(t, isLoopback("0.0.0.0:18795"))
+	assert.False(t, isLoopback(":18795"))
+	assert.False(t, isLoopback("192.168.1.10:18795"))
+}
+
+func TestValidateServeOptions(t *testing.T) {
+	assert.NoError(t, validateServeOptions(serveOptions{}))
+	assert.NoError(t, validateServeOptions(serveOptions{httpAddr: "127.0.0.1:18795"}))
+	assert.NoError(t, validateServeOptions(serveOptions{httpAddr: "0.0.0.0:18795", token: "secret"}))
+	assert.Error(t, validateServeOptions(serveOptions{httpAddr: "0.0.0.0:18795"}))
+	assert.Error(t, validateServeOptions(serveOptions{httpAddr: ":18795"}))
+}
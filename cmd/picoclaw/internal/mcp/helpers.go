@@ -0,0 +1,143 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
+	"github.com/sipeed/picoclaw/pkg/agent"
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/mcp"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+func serveCmd(opts serveOptions) error {
+	if err := validateServeOptions(opts); err != nil {
+		return err
+	}
+
+	// In stdio mode stdout carries the protocol; anything else printed
+	// there (startup notices, tool warnings) goes to stderr instead.
+	out := os.Stdout
+	if opts.httpAddr == "" {
+		os.Stdout = os.Stderr
+		defer func() { os.Stdout = out }()
+	}
+
+	if opts.debug {
+		logger.SetLevel(logger.DEBUG)
+	}
+
+	cfg, err := internal.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("error loading config: %w", err)
+	}
+
+	provider, modelID, err := providers.CreateProvider(cfg)
+	if err != nil {
+		return fmt.Errorf("error creating provider: %w", err)
+	}
+	if sp, ok := provider.(providers.StatefulProvider); ok {
+		defer sp.Close()
+	}
+	if modelID != "" {
+		cfg.Agents.Defaults.ModelName = modelID
+	}
+
+	msgBus := bus.NewMessageBus()
+	defer msgBus.Close()
+	mcp.ClientVersion = internal.GetVersion()
+	agentLoop := agent.NewAgentLoop(cfg, msgBus, provider)
+	defer agentLoop.Close()
+
+	inst, ok := agentLoop.Agent(opts.agentID)
+	if !ok {
+		return fmt.Errorf("agent %q not found", opts.agentID)
+	}
+
+	// There is no user to ask, so access outside the workspace is refused
+	// instead of prompted for.
+	for _, name := range inst.Tools.List() {
+		if tool, ok := inst.Tools.Get(name); ok {
+			if pt, ok := tool.(tools.PermissibleTool); ok {
+				pt.SetPermission(inst.PermStore, denyOutsideWorkspace)
+			}
+		}
+	}
+
+	server := mcp.NewServer(mcp.ServerOptions{
+		Name:      "picoclaw",
+		Version:   internal.GetVersion(),
+		Tools:     inst.Tools,
+		Exclude:   opts.exclude,
+		Workspace: inst.Workspace,
+		Token:     opts.token,
+	})
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if opts.httpAddr == "" {
+		logger.InfoCF("mcp", "Serving MCP over stdio", map[string]any{"agent": inst.ID})
+		return server.ServeStdio(ctx, os.Stdin, out)
+	}
+	return serveHTTP(ctx, server, opts)
+}
+
+// validateServeOptions refuses to expose the tools to the network without a
+// token, since the server would otherwise run them for anyone.
+func validateServeOptions(opts serveOptions) error {
+	if opts.httpAddr != "" && opts.token == "" && !isLoopback(opts.httpAddr) {
+		return fmt.Errorf("refusing to serve MCP on %s without a token: "+
+			"set --token (or PICOCLAW_MCP_TOKEN) or listen on a loopback address", opts.httpAddr)
+	}
+	return nil
+}
+
+func serveHTTP(ctx context.Context, server *mcp.Server, opts serveOptions) error {
+	mux := http.NewServeMux()
+	mux.Handle("/mcp", server)
+	srv := &http.Server{Addr: opts.httpAddr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- srv.ListenAndServe() }()
+	fmt.Printf("✓ MCP server listening on http://%s/mcp\n", opts.httpAddr)
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return srv.Shutdown(shutdownCtx)
+}
+
+func denyOutsideWorkspace(_ context.Context, path string) (bool, error) {
+	logger.WarnCF("mcp", "Denied access outside the workspace", map[string]any{"path": path})
+	return false, nil
+}
+
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,54 @@
+package mcp
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// defaultExcludedTools need an active chat to report back to, which an MCP
+// client does not provide.
+var defaultExcludedTools = []string{"message", "spawn", "subagent"}
+
+type serveOptions struct {
+	httpAddr string
+	agentID  string
+	token    string
+	exclude  []string
+	debug    bool
+}
+
+func newServeCommand() *cobra.Command {
+	opts := serveOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Expose an agent's tools and memory as an MCP server",
+		Long: `Serve an agent's tools and workspace memory to MCP clients such as IDE assistants.
+
+By default the server speaks MCP over stdin/stdout, so a client can launch
+"picoclaw mcp serve" directly. With --http it listens for the streamable HTTP
+transport at http://<addr>/mcp instead. Listening on an address other than
+loopback requires --token.
+
+Tools keep the guards configured for the agent (restrict_to_workspace, exec
+deny patterns). Access outside the workspace that would normally prompt the
+user is denied.`,
+		Example: `  picoclaw mcp serve
+  picoclaw mcp serve --agent coder
+  picoclaw mcp serve --http 0.0.0.0:18795 --token secret`,
+		Args: cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return serveCmd(opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.httpAddr, "http", "", "Listen address for the streamable HTTP transport (stdio if empty)")
+	cmd.Flags().StringVarP(&opts.agentID, "agent", "a", "", "Agent whose tools and memory to serve (default: main agent)")
+	cmd.Flags().StringVar(&opts.token, "token", os.Getenv("PICOCLAW_MCP_TOKEN"),
+		"Bearer token required by the HTTP transport (env PICOCLAW_MCP_TOKEN)")
+	cmd.Flags().StringSliceVar(&opts.exclude, "exclude", defaultExcludedTools, "Tools not to expose")
+	cmd.Flags().BoolVarP(&opts.debug, "debug", "d", false, "Enable debug logging")
+
+	return cmd
+}
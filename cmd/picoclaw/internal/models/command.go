@@ -0,0 +1,43 @@
+package models
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func NewModelsCommand() *cobra.Command {
+	var (
+		ollamaHost string
+		cfg        *config.Config
+	)
+
+	cmd := &cobra.Command{
+		Use:   "models",
+		Short: "Manage models on the local Ollama daemon",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return cmd.Help()
+		},
+		PersistentPreRunE: func(_ *cobra.Command, _ []string) error {
+			var err error
+			cfg, err = internal.LoadConfig()
+			if err != nil {
+				return fmt.Errorf("error loading config: %w", err)
+			}
+			ollamaHost = resolveOllamaHost(cfg)
+			return nil
+		},
+	}
+
+	cmd.AddCommand(
+		newListCommand(func() string { return ollamaHost }, func() *config.Config { return cfg }),
+		newPullCommand(func() string { return ollamaHost }),
+		newRemoveCommand(func() string { return ollamaHost }),
+	)
+
+	return cmd
+}
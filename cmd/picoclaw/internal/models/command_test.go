@@ -0,0 +1,113 @@
+package models
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestNewModelsCommand(t *testing.T) {
+	cmd := NewModelsCommand()
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "models", cmd.Use)
+	assert.Equal(t, "Manage models on the local Ollama daemon", cmd.Short)
+
+	assert.False(t, cmd.HasFlags())
+	assert.NotNil(t, cmd.RunE)
+	assert.NotNil(t, cmd.PersistentPreRunE)
+
+	allowedCommands := []string{
+		"list",
+		"pull",
+		"rm",
+	}
+
+	subcommands := cmd.Commands()
+	assert.Len(t, subcommands, len(allowedCommands))
+
+	for _, subcmd := range subcommands {
+		found := slices.Contains(allowedCommands, subcmd.Name())
+		assert.True(t, found, "unexpected subcommand %q", subcmd.Name())
+		assert.NotNil(t, subcmd.RunE)
+	}
+}
+
+func TestResolveOllamaHost(t *testing.T) {
+	t.Setenv("OLLAMA_HOST", "")
+
+	cfg := &config.Config{}
+	assert.Equal(t, "http://localhost:11434", resolveOllamaHost(cfg))
+
+	cfg.ModelList = []config.ModelConfig{
+		{ModelName: "gpt", Model: "openai/gpt-4o", APIBase: "https://api.openai.com/v1"},
+		{ModelName: "llama", Model: "ollama/llama3", APIBase: "http://pi.local:11434/v1"},
+	}
+	assert.Equal(t, "http://pi.local:11434", resolveOllamaHost(cfg))
+
+	t.Setenv("OLLAMA_HOST", "10.0.0.2:11434")
+	assert.Equal(t, "http://10.0.0.2:11434", resolveOllamaHost(cfg))
+}
+
+func TestModelsCommands_AgainstDaemon(t *testing.T) {
+	var deleted, pulled string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/tags":
+			json.NewEncoder(w).Encode(map[string]any{
+				"models": []map[string]any{{"name": "llama3:latest", "size": 4_700_000_000}},
+			})
+		case "/api/pull":
+			var body map[string]any
+			json.NewDecoder(r.Body).Decode(&body)
+			pulled, _ = body["model"].(string)
+			w.Write([]byte(`{"status":"pulling manifest"}` + "\n" + `{"status":"success"}` + "\n"))
+		case "/api/delete":
+			var body map[string]any
+			json.NewDecoder(r.Body).Decode(&body)
+			deleted, _ = body["model"].(string)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	require.NoError(t, modelsListCmd(t.Context(), server.URL))
+	require.NoError(t, modelsPullCmd(t.Context(), server.URL, "qwen2.5:7b"))
+	require.NoError(t, modelsRemoveCmd(t.Context(), server.URL, "llama3:latest"))
+
+	assert.Equal(t, "qwen2.5:7b", pulled)
+	assert.Equal(t, "llama3:latest", deleted)
+}
+
+func TestFormatSize(t *testing.T) {
+	assert.Equal(t, "512 B", formatSize(512))
+	assert.Equal(t, "1.5 KB", formatSize(1536))
+	assert.Equal(t, "4.4 GB", formatSize(4_700_000_000))
+}
+
+func TestModelsCapabilitiesCmd_Offline(t *testing.T) {
+	cfg := &config.Config{ModelList: []config.ModelConfig{
+		{ModelName: "gpt", Model: "anthropic/claude-sonnet-4.6", APIKey: "k"},
+		{ModelName: "local", Model: "claude-cli/unknown-model"},
+	}}
+
+	// Neither protocol has a /models endpoint, so this must work without network access.
+	require.NoError(t, modelsCapabilitiesCmd(t.Context(), cfg, filepath.Join(t.TempDir(), "cache.json"), true))
+}
+
+func TestFormatTokens(t *testing.T) {
+	assert.Equal(t, "?", formatTokens(0))
+	assert.Equal(t, "512", formatTokens(512))
+	assert.Equal(t, "128K", formatTokens(128000))
+	assert.Equal(t, "1.049M", formatTokens(1048576))
+}
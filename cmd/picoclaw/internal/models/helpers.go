@@ -0,0 +1,80 @@
+package models
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/providers/ollama"
+)
+
+// resolveOllamaHost picks the daemon address: OLLAMA_HOST, then the first
+// ollama/ entry in model_list with an api_base, then the default local daemon.
+func resolveOllamaHost(cfg *config.Config) string {
+	if host := strings.TrimSpace(os.Getenv("OLLAMA_HOST")); host != "" {
+		if !strings.Contains(host, "://") {
+			host = "http://" + host
+		}
+		return ollama.NormalizeAPIBase(host)
+	}
+
+	for _, mc := range cfg.ModelList {
+		protocol, _ := providers.ExtractProtocol(mc.Model)
+		if protocol == "ollama" && mc.APIBase != "" {
+			return ollama.NormalizeAPIBase(mc.APIBase)
+		}
+	}
+
+	return ollama.DefaultAPIBase
+}
+
+// formatSize renders a byte count with binary units (e.g. "4.7 GB").
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// formatTokens renders a token count compactly (e.g. "128K", "1M"); 0 is unknown.
+func formatTokens(n int) string {
+	switch {
+	case n <= 0:
+		return "?"
+	case n >= 1_000_000:
+		return fmt.Sprintf("%.4gM", float64(n)/1_000_000)
+	case n >= 1000:
+		return fmt.Sprintf("%.4gK", float64(n)/1000)
+	default:
+		return fmt.Sprintf("%d", n)
+	}
+}
+
+func formatModalities(modalities []string) string {
+	if len(modalities) == 0 {
+		return "?"
+	}
+	return strings.Join(modalities, ",")
+}
+
+func formatPricing(p *providers.ModelPricing) string {
+	if p == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.4g/%.4g", p.InputPerMTok, p.OutputPerMTok)
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
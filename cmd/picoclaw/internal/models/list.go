@@ -0,0 +1,110 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/providers/ollama"
+)
+
+func newListCommand(ollamaHost func() string, loadConfig func() *config.Config) *cobra.Command {
+	var (
+		capabilities bool
+		refresh      bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List models installed on the Ollama daemon",
+		Long: "List models installed on the Ollama daemon.\n\n" +
+			"With --capabilities, list the model_list entries with their context window,\n" +
+			"max output, input modalities, tool/reasoning support and pricing.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if capabilities {
+				return modelsCapabilitiesCmd(
+					cmd.Context(), loadConfig(), providers.DefaultCapabilityCachePath(), refresh)
+			}
+			return modelsListCmd(cmd.Context(), ollamaHost())
+		},
+	}
+
+	cmd.Flags().BoolVar(&capabilities, "capabilities", false, "Show capabilities of the configured model_list entries")
+	cmd.Flags().BoolVar(&refresh, "refresh", false, "Re-query provider /models endpoints even if the cache is fresh")
+
+	return cmd
+}
+
+func modelsListCmd(ctx context.Context, host string) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	models, err := ollama.NewProvider(host, "").List(ctx)
+	if err != nil {
+		return fmt.Errorf("listing models from %s: %w", host, err)
+	}
+
+	if len(models) == 0 {
+		fmt.Println("No models installed")
+		return nil
+	}
+
+	fmt.Printf("Models on %s:\n", host)
+	fmt.Printf("  %-40s %10s  %-8s  %s\n", "Name", "Size", "Quant", "Modified")
+	for _, m := range models {
+		fmt.Printf("  %-40s %10s  %-8s  %s\n",
+			m.Name, formatSize(m.Size), m.Details.QuantizationLevel, m.ModifiedAt.Format("2006-01-02 15:04"))
+	}
+
+	fmt.Printf("\n%d model(s) found\n", len(models))
+	return nil
+}
+
+func modelsCapabilitiesCmd(ctx context.Context, cfg *config.Config, cachePath string, refresh bool) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if len(cfg.ModelList) == 0 {
+		fmt.Println("No models configured in model_list")
+		return nil
+	}
+
+	registry := providers.NewCapabilityRegistry(cachePath)
+	if err := registry.LoadCache(); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+	registry.ApplyConfig(cfg)
+
+	if refresh || registry.Stale(providers.CapabilityCacheTTL) {
+		// Discovery failures are not fatal: cached and bundled data still apply.
+		if err := registry.Refresh(ctx, cfg); err != nil {
+			fmt.Printf("Warning: model discovery incomplete:\n  %s\n\n",
+				strings.ReplaceAll(err.Error(), "\n", "\n  "))
+		}
+	}
+
+	fmt.Printf("  %-24s %-36s %9s %9s  %-16s %-5s %-9s %-14s %s\n",
+		"Name", "Model", "Context", "MaxOut", "Input", "Tools", "Reasoning", "Price $/Mtok", "Source")
+	for _, mc := range cfg.ModelList {
+		protocol, modelID := providers.ExtractProtocol(mc.Model)
+		caps, ok := registry.Lookup(protocol, modelID)
+		if !ok {
+			fmt.Printf("  %-24s %-36s %9s %9s  %-16s %-5s %-9s %-14s %s\n",
+				mc.ModelName, mc.Model, "?", "?", "?", "?", "?", "?", "unknown")
+			continue
+		}
+		fmt.Printf("  %-24s %-36s %9s %9s  %-16s %-5s %-9s %-14s %s\n",
+			mc.ModelName, mc.Model,
+			formatTokens(caps.ContextWindow), formatTokens(caps.MaxOutputTokens),
+			formatModalities(caps.InputModalities), yesNo(caps.Tools), yesNo(caps.Reasoning),
+			formatPricing(caps.Pricing), caps.Source)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/pkg/providers/ollama"
+)
+
+func newPullCommand(ollamaHost func() string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "pull <name>",
+		Short:   "Download a model to the Ollama daemon",
+		Args:    cobra.ExactArgs(1),
+		Example: "picoclaw models pull qwen2.5:7b",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return modelsPullCmd(cmd.Context(), ollamaHost(), args[0])
+		},
+	}
+
+	return cmd
+}
+
+func modelsPullCmd(ctx context.Context, host, name string) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	lastStatus := ""
+	err := ollama.NewProvider(host, "").Pull(ctx, name, func(p ollama.PullProgress) {
+		if p.Total > 0 {
+			fmt.Printf("\r%s: %s / %s (%.0f%%)   ",
+				p.Status, formatSize(p.Completed), formatSize(p.Total),
+				float64(p.Completed)/float64(p.Total)*100)
+			lastStatus = p.Status
+			return
+		}
+		if p.Status != lastStatus {
+			if lastStatus != "" {
+				fmt.Println()
+			}
+			fmt.Print(p.Status)
+			lastStatus = p.Status
+		}
+	})
+	fmt.Println()
+	if err != nil {
+		return fmt.Errorf("pulling %s: %w", name, err)
+	}
+
+	fmt.Printf("✓ Pulled %s\n", name)
+	return nil
+}
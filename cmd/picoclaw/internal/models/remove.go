@@ -0,0 +1,37 @@
+package models
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/pkg/providers/ollama"
+)
+
+func newRemoveCommand(ollamaHost func() string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "rm <name>",
+		Short:   "Remove a model from the Ollama daemon",
+		Args:    cobra.ExactArgs(1),
+		Example: "picoclaw models rm qwen2.5:7b",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return modelsRemoveCmd(cmd.Context(), ollamaHost(), args[0])
+		},
+	}
+
+	return cmd
+}
+
+func modelsRemoveCmd(ctx context.Context, host, name string) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if err := ollama.NewProvider(host, "").Delete(ctx, name); err != nil {
+		return fmt.Errorf("removing %s: %w", name, err)
+	}
+
+	fmt.Printf("✓ Removed %s\n", name)
+	return nil
+}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
 	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/egress"
 	"github.com/sipeed/picoclaw/pkg/skills"
 	"github.com/sipeed/picoclaw/pkg/utils"
 )
@@ -67,6 +68,7 @@ func skillsInstallFromRegistry(cfg *config.Config, registryName, slug string) er
 	registryMgr := skills.NewRegistryManagerFromConfig(skills.RegistryConfig{
 		MaxConcurrentSearches: cfg.Tools.Skills.MaxConcurrentSearches,
 		ClawHub:               skills.ClawHubConfig(cfg.Tools.Skills.Registries.ClawHub),
+		Egress:                egress.New(cfg.Tools.Egress),
 	})
 
 	registry := registryMgr.GetRegistry(registryName)
@@ -229,6 +231,7 @@ func skillsSearchCmd(query string) {
 	registryMgr := skills.NewRegistryManagerFromConfig(skills.RegistryConfig{
 		MaxConcurrentSearches: cfg.Tools.Skills.MaxConcurrentSearches,
 		ClawHub:               skills.ClawHubConfig(cfg.Tools.Skills.Registries.ClawHub),
+		Egress:                egress.New(cfg.Tools.Egress),
 	})
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -5,14 +5,22 @@ import (
 )
 
 func NewStatusCommand() *cobra.Command {
+	var showProviders bool
+
 	cmd := &cobra.Command{
 		Use:     "status",
 		Aliases: []string{"s"},
 		Short:   "Show picoclaw status",
 		Run: func(cmd *cobra.Command, args []string) {
+			if showProviders {
+				providersStatusCmd()
+				return
+			}
 			statusCmd()
 		},
 	}
 
+	cmd.Flags().BoolVar(&showProviders, "providers", false, "Show provider availability, remaining cooldowns and API key usage")
+
 	return cmd
 }
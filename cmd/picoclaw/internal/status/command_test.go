@@ -19,6 +19,10 @@ func TestNewStatusCommand(t *testing.T) {
 
 	assert.Equal(t, "Show picoclaw status", cmd.Short)
 
+	f := cmd.Flags().Lookup("providers")
+	require.NotNil(t, f)
+	assert.Equal(t, "false", f.DefValue)
+
 	assert.False(t, cmd.HasSubCommands())
 
 	assert.NotNil(t, cmd.Run)
@@ -3,9 +3,12 @@ package status
 import (
 	"fmt"
 	"os"
+	"sort"
+	"time"
 
 	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
 	"github.com/sipeed/picoclaw/pkg/auth"
+	"github.com/sipeed/picoclaw/pkg/providers"
 )
 
 func statusCmd() {
@@ -98,3 +101,62 @@ func statusCmd() {
 		}
 	}
 }
+
+// providersStatusCmd prints the availability of every configured provider
+// from the cooldown state persisted by the gateway or agent.
+func providersStatusCmd() {
+	cfg, err := internal.LoadConfig()
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		return
+	}
+
+	statePath := providers.CooldownStatePath(cfg.WorkspacePath())
+	cooldown, err := providers.LoadCooldownState(statePath)
+	if err != nil {
+		fmt.Printf("Error reading %s: %v\n", statePath, err)
+		return
+	}
+
+	seen := make(map[string]bool)
+	for _, m := range cfg.ModelList {
+		if ref := providers.ParseModelRef(m.Model, "openai"); ref != nil {
+			seen[ref.Provider] = true
+		}
+	}
+	for _, name := range cooldown.Providers() {
+		seen[name] = true
+	}
+	if len(seen) == 0 {
+		fmt.Println("No providers configured")
+		return
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Println("Providers:")
+	for _, name := range names {
+		fmt.Println(" ", cooldown.Status(name))
+	}
+
+	usagePath := providers.KeyUsagePath(cfg.WorkspacePath())
+	reports, err := providers.LoadKeyUsage(usagePath)
+	if err != nil {
+		fmt.Printf("Error reading %s: %v\n", usagePath, err)
+		return
+	}
+	if len(reports) > 0 {
+		fmt.Println("\nAPI keys:")
+		for _, r := range reports {
+			fmt.Printf("  %s (since %s, updated %s):\n", r.Pool,
+				r.Started.Format(time.DateTime), r.Updated.Format(time.DateTime))
+			for _, u := range r.Keys {
+				fmt.Println("   ", u)
+			}
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package workspace
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+func NewWorkspaceCommand() *cobra.Command {
+	var journal *tools.Journal
+	var workspace string
+
+	cmd := &cobra.Command{
+		Use:   "workspace",
+		Short: "Inspect and restore file changes made by the agent",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return cmd.Help()
+		},
+		PersistentPreRunE: func(_ *cobra.Command, _ []string) error {
+			cfg, err := internal.LoadConfig()
+			if err != nil {
+				return fmt.Errorf("error loading config: %w", err)
+			}
+			jc := cfg.Tools.Journal
+			workspace = cfg.WorkspacePath()
+			restrict := cfg.Agents.Defaults.RestrictToWorkspace
+			journal = tools.NewJournal(workspace, restrict, jc.MaxEntries, jc.MaxBytes, jc.MaxFileBytes)
+			return nil
+		},
+	}
+
+	cmd.AddCommand(
+		newHistoryCommand(func() (*tools.Journal, string) { return journal, workspace }),
+		newRestoreCommand(func() (*tools.Journal, string) { return journal, workspace }),
+	)
+
+	return cmd
+}
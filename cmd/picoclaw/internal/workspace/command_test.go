@@ -0,0 +1,76 @@
+package workspace
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+func TestNewWorkspaceCommand(t *testing.T) {
+	cmd := NewWorkspaceCommand()
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "workspace", cmd.Use)
+	assert.Equal(t, "Inspect and restore file changes made by the agent", cmd.Short)
+
+	assert.False(t, cmd.HasFlags())
+
+	assert.Nil(t, cmd.Run)
+	assert.NotNil(t, cmd.RunE)
+	assert.NotNil(t, cmd.PersistentPreRunE)
+
+	allowedCommands := []string{
+		"history",
+		"restore",
+	}
+
+	subcommands := cmd.Commands()
+	assert.Len(t, subcommands, len(allowedCommands))
+
+	for _, subcmd := range subcommands {
+		found := slices.Contains(allowedCommands, subcmd.Name())
+		assert.True(t, found, "unexpected subcommand %q", subcmd.Name())
+
+		assert.False(t, subcmd.HasSubCommands())
+		assert.Nil(t, subcmd.Run)
+		assert.NotNil(t, subcmd.RunE)
+	}
+
+	history, _, err := cmd.Find([]string{"history"})
+	require.NoError(t, err)
+	assert.NotNil(t, history.Flags().Lookup("session"))
+	assert.NotNil(t, history.Flags().Lookup("limit"))
+
+	restore, _, err := cmd.Find([]string{"restore"})
+	require.NoError(t, err)
+	assert.Error(t, restore.Args(restore, []string{}))
+	assert.NoError(t, restore.Args(restore, []string{"1"}))
+}
+
+func TestWorkspaceRestoreCmd(t *testing.T) {
+	workspace := t.TempDir()
+	path := filepath.Join(workspace, "config.json")
+	require.NoError(t, os.WriteFile(path, []byte("good"), 0o644))
+
+	j := tools.NewJournal(workspace, true, 0, 0, 0)
+	ctx := j.BeginTurn(context.Background(), "s")
+	require.NoError(t, j.Record(ctx, "write_file", path, []byte("good"), true))
+	require.NoError(t, os.WriteFile(path, []byte("bad"), 0o644))
+
+	require.NoError(t, workspaceHistoryCmd(j, workspace, "", 20))
+	require.NoError(t, workspaceRestoreCmd(j, workspace, 1))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "good", string(data))
+
+	assert.Error(t, workspaceRestoreCmd(j, workspace, 1))
+}
@@ -0,0 +1,27 @@
+package workspace
+
+import (
+	"path/filepath"
+
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// displayPath shows paths inside the workspace relative to it.
+func displayPath(workspace, path string) string {
+	if rel, err := filepath.Rel(workspace, path); err == nil && filepath.IsLocal(rel) {
+		return rel
+	}
+	return path
+}
+
+// describeChange tells what restoring an entry does to its file.
+func describeChange(e tools.JournalEntry) string {
+	switch {
+	case e.Omitted:
+		return "not restorable (too large)"
+	case e.Existed:
+		return "restore previous content"
+	default:
+		return "remove (created)"
+	}
+}
@@ -0,0 +1,60 @@
+package workspace
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+func newHistoryCommand(journal func() (*tools.Journal, string)) *cobra.Command {
+	var (
+		session string
+		limit   int
+	)
+
+	cmd := &cobra.Command{
+		Use:     "history",
+		Short:   "List file changes made by the agent",
+		Args:    cobra.NoArgs,
+		Example: "picoclaw workspace history --session agent:main:main --limit 50",
+		RunE: func(_ *cobra.Command, _ []string) error {
+			j, workspace := journal()
+			return workspaceHistoryCmd(j, workspace, session, limit)
+		},
+	}
+
+	cmd.Flags().StringVarP(&session, "session", "s", "", "Only show changes of this session")
+	cmd.Flags().IntVarP(&limit, "limit", "n", 20, "Number of changes to show")
+
+	return cmd
+}
+
+func workspaceHistoryCmd(j *tools.Journal, workspace, session string, limit int) error {
+	entries, err := j.Entries()
+	if err != nil {
+		return err
+	}
+
+	var shown []tools.JournalEntry
+	for i := len(entries) - 1; i >= 0 && (limit <= 0 || len(shown) < limit); i-- {
+		if session == "" || entries[i].Session == session {
+			shown = append(shown, entries[i])
+		}
+	}
+	if len(shown) == 0 {
+		fmt.Println("No file changes recorded")
+		return nil
+	}
+
+	fmt.Println("File changes (most recent first):")
+	fmt.Printf("  %5s  %-16s  %-24s %4s  %-12s  %s\n", "ID", "Time", "Session", "Turn", "Tool", "Path")
+	for _, e := range shown {
+		fmt.Printf("  %5d  %-16s  %-24s %4d  %-12s  %s\n",
+			e.ID, e.Time.Format("2006-01-02 15:04"), e.Session, e.Turn, e.Tool, displayPath(workspace, e.Path))
+	}
+
+	fmt.Printf("\nRestore a file to its state before a change with: picoclaw workspace restore <id>\n")
+	return nil
+}
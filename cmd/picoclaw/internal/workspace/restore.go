@@ -0,0 +1,39 @@
+package workspace
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+func newRestoreCommand(journal func() (*tools.Journal, string)) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "restore <id>",
+		Short:   "Restore a file to its state before a change",
+		Long:    "Restore a file to its state before a change. Later changes to the same file are undone as well.",
+		Args:    cobra.ExactArgs(1),
+		Example: "picoclaw workspace restore 42",
+		RunE: func(_ *cobra.Command, args []string) error {
+			id, err := strconv.ParseInt(args[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid change id %q", args[0])
+			}
+			j, workspace := journal()
+			return workspaceRestoreCmd(j, workspace, id)
+		},
+	}
+
+	return cmd
+}
+
+func workspaceRestoreCmd(j *tools.Journal, workspace string, id int64) error {
+	e, err := j.Restore(id)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s: %s\n", displayPath(workspace, e.Path), describeChange(e))
+	return nil
+}
@@ -18,13 +18,17 @@ import (
 	"github.com/sipeed/picoclaw/cmd/picoclaw/internal/cron"
 	"github.com/sipeed/picoclaw/cmd/picoclaw/internal/doctor"
 	"github.com/sipeed/picoclaw/cmd/picoclaw/internal/gateway"
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal/mcp"
 	"github.com/sipeed/picoclaw/cmd/picoclaw/internal/migrate"
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal/models"
 	"github.com/sipeed/picoclaw/cmd/picoclaw/internal/onboard"
 	"github.com/sipeed/picoclaw/cmd/picoclaw/internal/sessions"
 	"github.com/sipeed/picoclaw/cmd/picoclaw/internal/skills"
 	"github.com/sipeed/picoclaw/cmd/picoclaw/internal/status"
 	"github.com/sipeed/picoclaw/cmd/picoclaw/internal/update"
 	"github.com/sipeed/picoclaw/cmd/picoclaw/internal/version"
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal/workspace"
+	"github.com/sipeed/picoclaw/pkg/sandbox"
 )
 
 func NewPicoclawCommand() *cobra.Command {
@@ -41,20 +45,26 @@ func NewPicoclawCommand() *cobra.Command {
 		agent.NewAgentCommand(),
 		auth.NewAuthCommand(),
 		gateway.NewGatewayCommand(),
+		mcp.NewMCPCommand(),
 		status.NewStatusCommand(),
 		cron.NewCronCommand(),
 		doctor.NewDoctorCommand(),
 		migrate.NewMigrateCommand(),
+		models.NewModelsCommand(),
 		sessions.NewSessionsCommand(),
 		skills.NewSkillsCommand(),
 		update.NewUpdateCommand(),
 		version.NewVersionCommand(),
+		workspace.NewWorkspaceCommand(),
 	)
 
 	return cmd
 }
 
 func main() {
+	// Must run first: when started as the sandbox helper this never returns.
+	sandbox.Init()
+
 	cmd := NewPicoclawCommand()
 	if err := cmd.Execute(); err != nil {
 		os.Exit(1)
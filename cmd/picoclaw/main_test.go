@@ -38,13 +38,16 @@ func TestNewPicoclawCommand(t *testing.T) {
 		"cron",
 		"doctor",
 		"gateway",
+		"mcp",
 		"migrate",
+		"models",
 		"onboard",
 		"sessions",
 		"skills",
 		"status",
 		"update",
 		"version",
+		"workspace",
 	}
 
 	subcommands := cmd.Commands()
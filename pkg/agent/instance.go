@@ -9,6 +9,7 @@ import (
 	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/providers"
 	"github.com/sipeed/picoclaw/pkg/routing"
+	"github.com/sipeed/picoclaw/pkg/sandbox"
 	"github.com/sipeed/picoclaw/pkg/session"
 	"github.com/sipeed/picoclaw/pkg/tools"
 )
@@ -29,10 +30,24 @@ type AgentInstance struct {
 	Sessions       *session.SessionManager
 	ContextBuilder *ContextBuilder
 	Tools          *tools.ToolRegistry
+	Processes      *tools.ProcessManager
+	Journal        *tools.Journal
 	PermStore      *tools.PermissionStore
 	Subagents      *config.SubagentsConfig
 	SkillsFilter   []string
 	Candidates     []providers.FallbackCandidate
+
+	// ImageCandidates serve turns with image input when the primary model lacks vision.
+	ImageCandidates []providers.FallbackCandidate
+	Capabilities    providers.ModelCapabilities
+
+	// reasoning holds the effective reasoning settings per candidate
+	// (providers.ModelKey); reasoningDefault applies to any other model.
+	reasoning        map[string]*config.ReasoningConfig
+	reasoningDefault *config.ReasoningConfig
+	// reasoningModels are the candidates reasoning options are sent to: those
+	// with reasoning on their model_list entry or known to support it.
+	reasoningModels map[string]bool
 }
 
 // NewAgentInstance creates an agent instance from config.
@@ -53,15 +68,42 @@ func NewAgentInstance(
 	toolsRegistry.Register(tools.NewReadFileTool(workspace, restrict))
 	toolsRegistry.Register(tools.NewWriteFileTool(workspace, restrict))
 	toolsRegistry.Register(tools.NewListDirTool(workspace, restrict))
+	toolsRegistry.Register(tools.NewGlobTool(workspace, restrict))
+	toolsRegistry.Register(tools.NewGrepTool(workspace, restrict))
 	execTool, err := tools.NewExecToolWithConfig(workspace, restrict, cfg)
 	if err != nil {
 		log.Fatalf("Critical error: unable to initialize exec tool: %v", err)
 	}
+	sandboxCfg := defaults.Sandbox
+	if agentCfg != nil {
+		sandboxCfg = sandboxCfg.Merge(agentCfg.Sandbox)
+	}
+	execTool.SetSandbox(sandbox.FromConfig(sandboxCfg, workspace))
 	toolsRegistry.Register(execTool)
+	processes := tools.NewProcessManager(cfg.Tools.Exec.MaxProcesses, cfg.Tools.Exec.ProcessOutputBytes)
+	toolsRegistry.Register(tools.NewProcessTool(execTool, processes))
+	gpioCfg := defaults.GPIO
+	if agentCfg != nil {
+		gpioCfg = gpioCfg.Merge(agentCfg.GPIO)
+	}
+	toolsRegistry.Register(tools.NewGPIOTool(gpioCfg))
 
 	toolsRegistry.Register(tools.NewEditFileTool(workspace, restrict))
+	toolsRegistry.Register(tools.NewApplyPatchTool(workspace, restrict))
 	toolsRegistry.Register(tools.NewAppendFileTool(workspace, restrict))
 
+	var journal *tools.Journal
+	if jc := cfg.Tools.Journal; jc.Enabled {
+		journal = tools.NewJournal(workspace, restrict, jc.MaxEntries, jc.MaxBytes, jc.MaxFileBytes)
+		for _, name := range toolsRegistry.List() {
+			if tool, ok := toolsRegistry.Get(name); ok {
+				if jt, ok := tool.(tools.JournaledTool); ok {
+					jt.SetJournal(journal)
+				}
+			}
+		}
+	}
+
 	sessionsDir := filepath.Join(workspace, "sessions")
 	sessionsManager := session.NewSessionManager(sessionsDir)
 
@@ -151,6 +193,21 @@ func NewAgentInstance(
 
 	candidates := providers.ResolveCandidatesWithLookup(modelCfg, defaults.Provider, resolveFromModelList)
 
+	var imageCandidates []providers.FallbackCandidate
+	if strings.TrimSpace(defaults.ImageModel) != "" {
+		imageCandidates = providers.ResolveCandidatesWithLookup(providers.ModelConfig{
+			Primary:   defaults.ImageModel,
+			Fallbacks: defaults.ImageModelFallbacks,
+		}, defaults.Provider, resolveFromModelList)
+	}
+
+	var agentReasoning *config.ReasoningConfig
+	if agentCfg != nil {
+		agentReasoning = agentCfg.Reasoning
+	}
+	reasoning, reasoningModels := resolveReasoning(cfg, defaults.Reasoning, agentReasoning,
+		append(append([]providers.FallbackCandidate{}, candidates...), imageCandidates...))
+
 	return &AgentInstance{
 		ID:             agentID,
 		Name:           agentName,
@@ -165,10 +222,135 @@ func NewAgentInstance(
 		Sessions:       sessionsManager,
 		ContextBuilder: contextBuilder,
 		Tools:          toolsRegistry,
+		Processes:      processes,
+		Journal:        journal,
 		PermStore:      permStore,
 		Subagents:      subagents,
 		SkillsFilter:   skillsFilter,
 		Candidates:     candidates,
+
+		ImageCandidates: imageCandidates,
+
+		reasoning:        reasoning,
+		reasoningDefault: defaults.Reasoning.Merge(agentReasoning),
+		reasoningModels:  reasoningModels,
+	}
+}
+
+// resolveReasoning layers the reasoning settings for each candidate:
+// agents.defaults, then the candidate's model_list entry, then the agent.
+// It also returns the candidates whose model_list entry sets reasoning.
+func resolveReasoning(
+	cfg *config.Config,
+	defaults, agent *config.ReasoningConfig,
+	candidates []providers.FallbackCandidate,
+) (map[string]*config.ReasoningConfig, map[string]bool) {
+	modelReasoning := make(map[string]*config.ReasoningConfig)
+	if cfg != nil {
+		for i := range cfg.ModelList {
+			if cfg.ModelList[i].Reasoning == nil {
+				continue
+			}
+			protocol, modelID := providers.ExtractProtocol(cfg.ModelList[i].Model)
+			key := providers.ModelKey(protocol, modelID)
+			if _, ok := modelReasoning[key]; !ok {
+				modelReasoning[key] = cfg.ModelList[i].Reasoning
+			}
+		}
+	}
+
+	resolved := make(map[string]*config.ReasoningConfig, len(candidates))
+	explicit := make(map[string]bool)
+	for _, c := range candidates {
+		key := providers.ModelKey(c.Provider, c.Model)
+		resolved[key] = defaults.Merge(modelReasoning[key]).Merge(agent)
+		if modelReasoning[key] != nil {
+			explicit[key] = true
+		}
+	}
+	return resolved, explicit
+}
+
+// ReasoningFor returns the reasoning settings that apply to provider/model,
+// or nil if none are configured.
+func (a *AgentInstance) ReasoningFor(provider, model string) *config.ReasoningConfig {
+	if r, ok := a.reasoning[providers.ModelKey(provider, model)]; ok {
+		return r
+	}
+	return a.reasoningDefault
+}
+
+// primaryReasoning returns the reasoning settings of the primary model.
+func (a *AgentInstance) primaryReasoning() *config.ReasoningConfig {
+	if len(a.Candidates) > 0 {
+		return a.ReasoningFor(a.Candidates[0].Provider, a.Candidates[0].Model)
+	}
+	return a.reasoningDefault
+}
+
+// withReasoningOptions adds the "reasoning_effort" and "thinking_budget"
+// request options for provider/model to options and returns it. Models that
+// neither set reasoning on their model_list entry nor are known to support it
+// get no reasoning options, since many endpoints reject them.
+func (a *AgentInstance) withReasoningOptions(provider, model string, options map[string]any) map[string]any {
+	if !a.reasoningModels[providers.ModelKey(provider, model)] {
+		return options
+	}
+	r := a.ReasoningFor(provider, model)
+	if r == nil {
+		return options
+	}
+	if r.Effort != "" {
+		options["reasoning_effort"] = r.Effort
+	}
+	if r.BudgetTokens > 0 {
+		options["thinking_budget"] = r.BudgetTokens
+	}
+	return options
+}
+
+// historyMessage returns msg as it should be stored in the session:
+// reasoning text and thinking blocks are dropped unless the primary model's
+// reasoning.include_in_history is set. They stay on the in-memory messages
+// of the running turn, where providers need them.
+func (a *AgentInstance) historyMessage(msg providers.Message) providers.Message {
+	if r := a.primaryReasoning(); r != nil && r.IncludeInHistory != nil && *r.IncludeInHistory {
+		return msg
+	}
+	msg.ReasoningContent = ""
+	msg.ThinkingBlocks = nil
+	return msg
+}
+
+// ApplyCapabilities sizes the context window and output budget from what the
+// registry knows about the primary model. Unknown values keep the config-based
+// defaults. Candidates the registry marks as reasoning models are also
+// enabled for reasoning options.
+func (a *AgentInstance) ApplyCapabilities(registry *providers.CapabilityRegistry) {
+	if a.reasoningModels == nil {
+		a.reasoningModels = make(map[string]bool)
+	}
+	for _, c := range append(append([]providers.FallbackCandidate{}, a.Candidates...), a.ImageCandidates...) {
+		if caps, ok := registry.Lookup(c.Provider, c.Model); ok && caps.Reasoning {
+			a.reasoningModels[providers.ModelKey(c.Provider, c.Model)] = true
+		}
+	}
+
+	provider, model := "", a.Model
+	if len(a.Candidates) > 0 {
+		provider, model = a.Candidates[0].Provider, a.Candidates[0].Model
+	}
+
+	caps, ok := registry.Lookup(provider, model)
+	if !ok {
+		return
+	}
+	a.Capabilities = caps
+	if caps.ContextWindow > 0 {
+		a.ContextWindow = caps.ContextWindow
+	}
+	if caps.MaxOutputTokens > 0 && a.MaxTokens > caps.MaxOutputTokens {
+		a.MaxTokens = caps.MaxOutputTokens
 	}
 }
 
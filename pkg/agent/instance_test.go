@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
 )
 
 func TestNewAgentInstance_UsesDefaultsTemperatureAndMaxTokens(t *testing.T) {
@@ -167,3 +168,123 @@ func TestNewAgentInstance_ResolveCandidatesFromModelListAliasWithoutProtocol(t *
 		t.Fatalf("candidate model = %q, want %q", agent.Candidates[0].Model, "glm-5")
 	}
 }
+
+func TestAgentInstance_ApplyCapabilities(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         tmpDir,
+				ModelName:         "local",
+				MaxTokens:         200000,
+				MaxToolIterations: 5,
+				ImageModel:        "vision",
+			},
+		},
+		ModelList: []config.ModelConfig{
+			{ModelName: "local", Model: "ollama/llama3.1:8b", NumCtx: 16384},
+			{ModelName: "vision", Model: "openai/gpt-4o"},
+		},
+	}
+
+	agent := NewAgentInstance(nil, &cfg.Agents.Defaults, cfg, &mockProvider{})
+	if len(agent.ImageCandidates) != 1 || agent.ImageCandidates[0].Model != "gpt-4o" {
+		t.Fatalf("ImageCandidates = %+v, want gpt-4o", agent.ImageCandidates)
+	}
+
+	registry := providers.NewCapabilityRegistry("")
+	registry.ApplyConfig(cfg)
+	agent.ApplyCapabilities(registry)
+
+	if agent.ContextWindow != 16384 {
+		t.Errorf("ContextWindow = %d, want 16384 from num_ctx", agent.ContextWindow)
+	}
+	if !agent.Capabilities.Tools || agent.Capabilities.SupportsImages() {
+		t.Errorf("Capabilities = %+v, want tools without vision", agent.Capabilities)
+	}
+
+	// max_tokens is clamped to the model's output limit when known.
+	registry.Set("ollama", "llama3.1:8b", providers.ModelCapabilities{MaxOutputTokens: 4096})
+	agent.ApplyCapabilities(registry)
+	if agent.MaxTokens != 4096 {
+		t.Errorf("MaxTokens = %d, want 4096", agent.MaxTokens)
+	}
+}
+
+func TestNewAgentInstance_ResolvesReasoning(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "agent-instance-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	includeHistory := true
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:      tmpDir,
+				Model:          "claude",
+				ModelFallbacks: []string{"gpt", "chat"},
+				Reasoning:      &config.ReasoningConfig{Effort: "low"},
+			},
+		},
+		ModelList: []config.ModelConfig{
+			{
+				ModelName: "claude",
+				Model:     "anthropic/claude-sonnet-4.6",
+				Reasoning: &config.ReasoningConfig{BudgetTokens: 16000, IncludeInHistory: &includeHistory},
+			},
+			{ModelName: "gpt", Model: "openai/gpt-5"},
+			{ModelName: "chat", Model: "deepseek/deepseek-chat"},
+		},
+	}
+
+	agentCfg := &config.AgentConfig{ID: "coder", Reasoning: &config.ReasoningConfig{Effort: "high"}}
+	agent := NewAgentInstance(agentCfg, &cfg.Agents.Defaults, cfg, &mockProvider{})
+
+	claude := agent.ReasoningFor("anthropic", "claude-sonnet-4.6")
+	if claude == nil || claude.Effort != "high" || claude.BudgetTokens != 16000 || claude.IncludeInHistory == nil {
+		t.Errorf("claude reasoning = %+v, want agent effort over model budget", claude)
+	}
+	gpt := agent.ReasoningFor("openai", "gpt-5")
+	if gpt == nil || gpt.Effort != "high" || gpt.BudgetTokens != 0 {
+		t.Errorf("gpt reasoning = %+v, want effort high only", gpt)
+	}
+
+	opts := agent.withReasoningOptions("anthropic", "claude-sonnet-4.6", map[string]any{"max_tokens": 1})
+	if opts["reasoning_effort"] != "high" || opts["thinking_budget"] != 16000 || opts["max_tokens"] != 1 {
+		t.Errorf("options = %v", opts)
+	}
+
+	// Fallbacks without reasoning on their model_list entry get reasoning
+	// options only once the registry knows they support it.
+	if opts := agent.withReasoningOptions("openai", "gpt-5", map[string]any{}); len(opts) != 0 {
+		t.Errorf("options = %v, want none before capabilities are known", opts)
+	}
+	agent.ApplyCapabilities(providers.NewCapabilityRegistry(""))
+	if opts := agent.withReasoningOptions("openai", "gpt-5", map[string]any{}); opts["reasoning_effort"] != "high" {
+		t.Errorf("options = %v, want effort high for a reasoning model", opts)
+	}
+	if opts := agent.withReasoningOptions("deepseek", "deepseek-chat", map[string]any{}); len(opts) != 0 {
+		t.Errorf("options = %v, want none for a non-reasoning fallback", opts)
+	}
+
+	// include_in_history comes from the primary model's entry.
+	msg := providers.Message{
+		Role:             "assistant",
+		ReasoningContent: "thoughts",
+		ThinkingBlocks:   []providers.ThinkingBlock{{Type: "thinking", Signature: "sig"}},
+	}
+	if got := agent.historyMessage(msg); got.ReasoningContent != "thoughts" || len(got.ThinkingBlocks) != 1 {
+		t.Errorf("historyMessage() = %+v, want reasoning kept", got)
+	}
+
+	plain := NewAgentInstance(nil, &cfg.Agents.Defaults, &config.Config{}, &mockProvider{})
+	if got := plain.historyMessage(msg); got.ReasoningContent != "" || got.ThinkingBlocks != nil {
+		t.Errorf("historyMessage() = %+v, want reasoning dropped by default", got)
+	}
+	if opts := plain.withReasoningOptions("", "claude", map[string]any{}); len(opts) != 0 {
+		t.Errorf("options = %v, want none for an unknown model", opts)
+	}
+}
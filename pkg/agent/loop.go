@@ -12,6 +12,9 @@ import (
 	"errors"
 	"fmt"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -22,7 +25,9 @@ import (
 	"github.com/sipeed/picoclaw/pkg/channels"
 	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/egress"
 	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/mcp"
 	"github.com/sipeed/picoclaw/pkg/media"
 	"github.com/sipeed/picoclaw/pkg/providers"
 	"github.com/sipeed/picoclaw/pkg/routing"
@@ -40,6 +45,10 @@ type AgentLoop struct {
 	running         atomic.Bool
 	summarizing     sync.Map
 	fallback        *providers.FallbackChain
+	cooldown        *providers.CooldownTracker
+	keyPools        []*providers.KeyPoolProvider
+	capabilities    *providers.CapabilityRegistry
+	mcp             *mcp.Manager
 	channelManager  *channels.Manager
 	mediaStore      media.MediaStore
 	permFuncFactory tools.PermissionFuncFactory
@@ -57,6 +66,45 @@ type processOptions struct {
 	NoHistory       bool   // If true, don't load session history (for heartbeat)
 }
 
+// mcpStartupWait bounds how long NewAgentLoop waits for MCP servers before
+// leaving the rest to connect in the background.
+const mcpStartupWait = 5 * time.Second
+
+// mcpMounts tracks the MCP tools registered in each agent per server, so
+// that tools a server no longer offers after reconnecting are removed.
+type mcpMounts struct {
+	mu      sync.Mutex
+	mounted map[string]map[string][]string // agent ID -> server -> tool names
+}
+
+// mount registers the current tools of server in every agent it is
+// available to and unregisters those it offered before but no longer does.
+func (mm *mcpMounts) mount(registry *AgentRegistry, m *mcp.Manager, server string) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	for _, agentID := range registry.ListAgentIDs() {
+		agent, ok := registry.GetAgent(agentID)
+		if !ok {
+			continue
+		}
+		if mm.mounted[agentID] == nil {
+			mm.mounted[agentID] = make(map[string][]string)
+		}
+		current := m.ServerToolsFor(server, agentID)
+		names := make([]string, 0, len(current))
+		for _, tool := range current {
+			agent.Tools.Register(tool)
+			names = append(names, tool.Name())
+		}
+		for _, name := range mm.mounted[agentID][server] {
+			if !slices.Contains(names, name) {
+				agent.Tools.Unregister(name)
+			}
+		}
+		mm.mounted[agentID][server] = names
+	}
+}
+
 const defaultResponse = "I've completed processing but have no response to give. Increase `max_tool_iterations` in config.json."
 
 func NewAgentLoop(cfg *config.Config, msgBus *bus.MessageBus, provider providers.LLMProvider) *AgentLoop {
@@ -65,9 +113,48 @@ func NewAgentLoop(cfg *config.Config, msgBus *bus.MessageBus, provider providers
 	// Register shared tools to all agents
 	registerSharedTools(cfg, msgBus, registry, provider)
 
+	// Mount MCP server tools, resources and prompts into the agents they are configured for
+	// as each server comes up; slow servers keep connecting in the background and servers
+	// that reconnect have their tools replaced
+	mcpManager := mcp.NewManager(cfg.MCP)
+	mounts := &mcpMounts{mounted: make(map[string]map[string][]string)}
+	mcpManager.Start(context.Background(), func(server string) {
+		mounts.mount(registry, mcpManager, server)
+	})
+	startCtx, cancelStart := context.WithTimeout(context.Background(), mcpStartupWait)
+	mcpManager.Wait(startCtx)
+	cancelStart()
+
 	// Set up shared fallback chain
-	cooldown := providers.NewCooldownTracker()
+	// Cooldowns are persisted so a crash-restart loop does not hammer a provider that is cooling down
+	cooldown := providers.NewPersistentCooldownTracker(providers.CooldownStatePath(cfg.WorkspacePath()))
 	fallbackChain := providers.NewFallbackChain(cooldown)
+	providers.ShareCooldownTracker(provider, cooldown)
+	keyPools := providers.KeyPoolsOf(provider)
+	if len(keyPools) > 0 {
+		// Benched keys stay benched across restarts, like the providers above
+		keyCooldown := providers.NewPersistentCooldownTracker(providers.KeyCooldownPath(cfg.WorkspacePath()))
+		for _, pool := range keyPools {
+			pool.SetCooldownTracker(keyCooldown)
+			pool.SetUsagePath(providers.KeyUsagePath(cfg.WorkspacePath()))
+		}
+	}
+	if limiters := providers.RateLimitersOf(provider); limiters != nil {
+		maxWait := time.Duration(cfg.Agents.Defaults.RateLimitMaxWait) * time.Second
+		fallbackChain.SetRateLimiters(limiters, maxWait)
+	}
+
+	// Size agents from known model capabilities (bundled table, cache, config overrides)
+	capabilities := providers.NewCapabilityRegistry(providers.DefaultCapabilityCachePath())
+	if err := capabilities.LoadCache(); err != nil {
+		logger.WarnCF("agent", "Failed to load model capability cache", map[string]any{"error": err.Error()})
+	}
+	capabilities.ApplyConfig(cfg)
+	for _, agentID := range registry.ListAgentIDs() {
+		if agent, ok := registry.GetAgent(agentID); ok {
+			agent.ApplyCapabilities(capabilities)
+		}
+	}
 
 	// Create state manager using default agent's workspace for channel recording
 	defaultAgent := registry.GetDefaultAgent()
@@ -77,15 +164,34 @@ func NewAgentLoop(cfg *config.Config, msgBus *bus.MessageBus, provider providers
 	}
 
 	return &AgentLoop{
-		bus:         msgBus,
-		cfg:         cfg,
-		registry:    registry,
-		state:       stateManager,
-		summarizing: sync.Map{},
-		fallback:    fallbackChain,
+		bus:          msgBus,
+		cfg:          cfg,
+		registry:     registry,
+		state:        stateManager,
+		summarizing:  sync.Map{},
+		fallback:     fallbackChain,
+		cooldown:     cooldown,
+		keyPools:     keyPools,
+		capabilities: capabilities,
+		mcp:          mcpManager,
 	}
 }
 
+// Capabilities returns the model capability registry shared by all agents.
+func (al *AgentLoop) Capabilities() *providers.CapabilityRegistry {
+	return al.capabilities
+}
+
+// Agent returns the agent with the given ID, or the default agent when
+// agentID is empty.
+func (al *AgentLoop) Agent(agentID string) (*AgentInstance, bool) {
+	if agentID == "" {
+		agent := al.registry.GetDefaultAgent()
+		return agent, agent != nil
+	}
+	return al.registry.GetAgent(agentID)
+}
+
 // registerSharedTools registers tools that are shared across all agents (web, message, spawn).
 func registerSharedTools(
 	cfg *config.Config,
@@ -120,16 +226,27 @@ func registerSharedTools(
 		} else if searchTool != nil {
 			agent.Tools.Register(searchTool)
 		}
-		fetchTool, err := tools.NewWebFetchToolWithProxy(50000, cfg.Tools.Web.Proxy)
+		egressPolicy := egress.New(cfg.Tools.Egress)
+		fetchTool, err := tools.NewWebFetchToolWithPolicy(50000, cfg.Tools.Web.Proxy, egressPolicy)
 		if err != nil {
 			logger.ErrorCF("agent", "Failed to create web fetch tool", map[string]any{"error": err.Error()})
 		} else {
 			agent.Tools.Register(fetchTool)
 		}
+		if cfg.Tools.HTTPRequest.Enabled {
+			httpTool, err := tools.NewHTTPRequestTool(cfg.Tools.HTTPRequest, cfg.Tools.Web.Proxy, egressPolicy)
+			if err != nil {
+				logger.ErrorCF("agent", "Failed to create http request tool", map[string]any{"error": err.Error()})
+			} else {
+				agent.Tools.Register(httpTool)
+			}
+		}
 
-		// Hardware tools (I2C, SPI) - Linux only, returns error on other platforms
+		// Hardware tools (I2C, SPI, serial) - Linux only, returns error on other platforms.
+		// The gpio tool is registered per agent in NewAgentInstance with its line allowlist.
 		agent.Tools.Register(tools.NewI2CTool())
 		agent.Tools.Register(tools.NewSPITool())
+		agent.Tools.Register(tools.NewSerialTool())
 
 		// Message tool
 		messageTool := tools.NewMessageTool()
@@ -144,10 +261,27 @@ func registerSharedTools(
 		})
 		agent.Tools.Register(messageTool)
 
+		// Send file tool; the media store is injected by SetMediaStore
+		if cfg.Tools.SendFile.Enabled {
+			sendFileTool := tools.NewSendFileTool(
+				agent.Workspace,
+				cfg.Agents.Defaults.RestrictToWorkspace,
+				cfg.Tools.SendFile.MaxBytes,
+				cfg.Tools.SendFile.ChannelMaxBytes,
+			)
+			sendFileTool.SetSendCallback(func(ctx context.Context, msg bus.OutboundMediaMessage) error {
+				pubCtx, pubCancel := context.WithTimeout(ctx, 5*time.Second)
+				defer pubCancel()
+				return msgBus.PublishOutboundMedia(pubCtx, msg)
+			})
+			agent.Tools.Register(sendFileTool)
+		}
+
 		// Skill discovery and installation tools
 		registryMgr := skills.NewRegistryManagerFromConfig(skills.RegistryConfig{
 			MaxConcurrentSearches: cfg.Tools.Skills.MaxConcurrentSearches,
 			ClawHub:               skills.ClawHubConfig(cfg.Tools.Skills.Registries.ClawHub),
+			Egress:                egressPolicy,
 		})
 		searchCache := skills.NewSearchCache(
 			cfg.Tools.Skills.SearchCache.MaxSize,
@@ -246,6 +380,41 @@ func (al *AgentLoop) Stop() {
 	al.running.Store(false)
 }
 
+// Close shuts down resources owned by the loop: MCP server connections,
+// background processes, serial ports and GPIO lines held by the agents.
+func (al *AgentLoop) Close() {
+	if al.mcp != nil {
+		al.mcp.Close()
+	}
+	for _, agentID := range al.registry.ListAgentIDs() {
+		agent, ok := al.registry.GetAgent(agentID)
+		if !ok {
+			continue
+		}
+		if agent.Processes != nil {
+			agent.Processes.Close()
+		}
+		if serial, ok := serialTool(agent); ok {
+			serial.Close()
+		}
+		if tool, ok := agent.Tools.Get("gpio"); ok {
+			if gpio, ok := tool.(*tools.GPIOTool); ok {
+				gpio.Close()
+			}
+		}
+	}
+}
+
+// serialTool returns the agent's serial tool, which owns the ports it opened.
+func serialTool(agent *AgentInstance) (*tools.SerialTool, bool) {
+	tool, ok := agent.Tools.Get("serial")
+	if !ok {
+		return nil, false
+	}
+	st, ok := tool.(*tools.SerialTool)
+	return st, ok
+}
+
 func (al *AgentLoop) RegisterTool(tool tools.Tool) {
 	for _, agentID := range al.registry.ListAgentIDs() {
 		if agent, ok := al.registry.GetAgent(agentID); ok {
@@ -256,11 +425,43 @@ func (al *AgentLoop) RegisterTool(tool tools.Tool) {
 
 func (al *AgentLoop) SetChannelManager(cm *channels.Manager) {
 	al.channelManager = cm
+	al.configureSendFile()
 }
 
 // SetMediaStore injects a MediaStore for media lifecycle management.
 func (al *AgentLoop) SetMediaStore(s media.MediaStore) {
 	al.mediaStore = s
+	al.configureSendFile()
+}
+
+// configureSendFile gives each agent's send_file tool the media store and
+// limits it to channels that can deliver media.
+func (al *AgentLoop) configureSendFile() {
+	for _, agentID := range al.registry.ListAgentIDs() {
+		agent, ok := al.registry.GetAgent(agentID)
+		if !ok {
+			continue
+		}
+		tool, ok := agent.Tools.Get("send_file")
+		if !ok {
+			continue
+		}
+		sft, ok := tool.(*tools.SendFileTool)
+		if !ok {
+			continue
+		}
+		sft.SetMediaStore(al.mediaStore)
+		if cm := al.channelManager; cm != nil {
+			sft.SetMediaSupport(func(channel string) bool {
+				ch, ok := cm.GetChannel(channel)
+				if !ok {
+					return false
+				}
+				_, ok = ch.(channels.MediaSender)
+				return ok
+			})
+		}
+	}
 }
 
 // SetPermissionFuncFactory sets the factory that creates PermissionFunc instances
@@ -269,36 +470,6 @@ func (al *AgentLoop) SetPermissionFuncFactory(factory tools.PermissionFuncFactor
 	al.permFuncFactory = factory
 }
 
-// inferMediaType determines the media type ("image", "audio", "video", "file")
-// from a filename and MIME content type.
-func inferMediaType(filename, contentType string) string {
-	ct := strings.ToLower(contentType)
-	fn := strings.ToLower(filename)
-
-	if strings.HasPrefix(ct, "image/") {
-		return "image"
-	}
-	if strings.HasPrefix(ct, "audio/") || ct == "application/ogg" {
-		return "audio"
-	}
-	if strings.HasPrefix(ct, "video/") {
-		return "video"
-	}
-
-	// Fallback: infer from extension
-	ext := filepath.Ext(fn)
-	switch ext {
-	case ".jpg", ".jpeg", ".png", ".gif", ".webp", ".bmp", ".svg":
-		return "image"
-	case ".mp3", ".wav", ".ogg", ".m4a", ".flac", ".aac", ".wma", ".opus":
-		return "audio"
-	case ".mp4", ".avi", ".mov", ".webm", ".mkv":
-		return "video"
-	}
-
-	return "file"
-}
-
 // RecordLastChannel records the last active channel for this workspace.
 // This uses the atomic state save mechanism to prevent data loss on crash.
 func (al *AgentLoop) RecordLastChannel(channel string) error {
@@ -505,6 +676,11 @@ func (al *AgentLoop) runAgentLoop(ctx context.Context, agent *AgentInstance, opt
 	// 1. Update tool contexts
 	al.updateToolContexts(agent, opts.Channel, opts.ChatID)
 
+	// File changes made during this turn are rolled back together
+	if agent.Journal != nil {
+		ctx = agent.Journal.BeginTurn(ctx, opts.SessionKey)
+	}
+
 	// 2. Build messages (skip history for heartbeat)
 	var history []providers.Message
 	var summary string
@@ -525,10 +701,11 @@ func (al *AgentLoop) runAgentLoop(ctx context.Context, agent *AgentInstance, opt
 	agent.Sessions.AddMessage(opts.SessionKey, "user", opts.UserMessage)
 
 	// 4. Run LLM iteration loop
-	finalContent, iteration, err := al.runLLMIteration(ctx, agent, messages, opts)
+	final, iteration, err := al.runLLMIteration(ctx, agent, messages, opts)
 	if err != nil {
 		return "", err
 	}
+	finalContent := final.Content
 
 	// If last tool had ForUser content and we already sent it, we might not need to send final response
 	// This is controlled by the tool's Silent flag and ForUser content
@@ -539,7 +716,8 @@ func (al *AgentLoop) runAgentLoop(ctx context.Context, agent *AgentInstance, opt
 	}
 
 	// 6. Save final assistant message to session
-	agent.Sessions.AddMessage(opts.SessionKey, "assistant", finalContent)
+	final.Content = finalContent
+	agent.Sessions.AddFullMessage(opts.SessionKey, agent.historyMessage(final))
 	agent.Sessions.Save(opts.SessionKey)
 
 	// 7. Optional: summarization
@@ -628,9 +806,9 @@ func (al *AgentLoop) runLLMIteration(
 	agent *AgentInstance,
 	messages []providers.Message,
 	opts processOptions,
-) (string, int, error) {
+) (providers.Message, int, error) {
 	iteration := 0
-	var finalContent string
+	final := providers.Message{Role: "assistant"}
 
 	for iteration < agent.MaxIterations {
 		iteration++
@@ -671,14 +849,35 @@ func (al *AgentLoop) runLLMIteration(
 		var err error
 
 		callLLM := func() (*providers.LLMResponse, error) {
+			// Route image turns to the image model when the primary model is known to have no vision.
+			if hasMedia(messages) && agent.Capabilities.LacksImages() &&
+				len(agent.ImageCandidates) > 0 && al.fallback != nil {
+				fbResult, fbErr := al.fallback.ExecuteImage(ctx, agent.ImageCandidates,
+					func(ctx context.Context, provider, model string) (*providers.LLMResponse, error) {
+						return agent.Provider.Chat(ctx, messages, providerToolDefs, model,
+							agent.withReasoningOptions(provider, model, map[string]any{
+								"max_tokens":             agent.MaxTokens,
+								"temperature":            agent.Temperature,
+								providers.ProviderOption: provider,
+							}))
+					},
+				)
+				if fbErr != nil {
+					return nil, fbErr
+				}
+				return fbResult.Response, nil
+			}
 			if len(agent.Candidates) > 1 && al.fallback != nil {
 				fbResult, fbErr := al.fallback.Execute(ctx, agent.Candidates,
+					providers.EstimateRequestTokens(messages, providerToolDefs),
 					func(ctx context.Context, provider, model string) (*providers.LLMResponse, error) {
-						return agent.Provider.Chat(ctx, messages, providerToolDefs, model, map[string]any{
-							"max_tokens":       agent.MaxTokens,
-							"temperature":      agent.Temperature,
-							"prompt_cache_key": agent.ID,
-						})
+						return agent.Provider.Chat(ctx, messages, providerToolDefs, model,
+							agent.withReasoningOptions(provider, model, map[string]any{
+								"max_tokens":             agent.MaxTokens,
+								"temperature":            agent.Temperature,
+								"prompt_cache_key":       agent.ID,
+								providers.ProviderOption: provider,
+							}))
 					},
 				)
 				if fbErr != nil {
@@ -691,11 +890,17 @@ func (al *AgentLoop) runLLMIteration(
 				}
 				return fbResult.Response, nil
 			}
-			return agent.Provider.Chat(ctx, messages, providerToolDefs, agent.Model, map[string]any{
-				"max_tokens":       agent.MaxTokens,
-				"temperature":      agent.Temperature,
-				"prompt_cache_key": agent.ID,
-			})
+			primaryProvider, primaryModel := "", agent.Model
+			if len(agent.Candidates) > 0 {
+				primaryProvider, primaryModel = agent.Candidates[0].Provider, agent.Candidates[0].Model
+			}
+			return agent.Provider.Chat(ctx, messages, providerToolDefs, agent.Model,
+				agent.withReasoningOptions(primaryProvider, primaryModel, map[string]any{
+					"max_tokens":             agent.MaxTokens,
+					"temperature":            agent.Temperature,
+					"prompt_cache_key":       agent.ID,
+					providers.ProviderOption: primaryProvider,
+				}))
 		}
 
 		// Retry loop for context/token errors
@@ -770,10 +975,14 @@ func (al *AgentLoop) runLLMIteration(
 					"iteration": iteration,
 					"error":     err.Error(),
 				})
-			return "", iteration, fmt.Errorf("LLM call failed after retries: %w", err)
+			return final, iteration, fmt.Errorf("LLM call failed after retries: %w", err)
 		}
 
-		go al.handleReasoning(ctx, response.Reasoning, opts.Channel, al.targetReasoningChannelID(opts.Channel))
+		reasoning := response.Reasoning
+		if reasoning == "" {
+			reasoning = response.ReasoningContent
+		}
+		go al.handleReasoning(ctx, reasoning, opts.Channel, al.targetReasoningChannelID(opts.Channel))
 
 		logger.DebugCF("agent", "LLM response",
 			map[string]any{
@@ -787,12 +996,14 @@ func (al *AgentLoop) runLLMIteration(
 			})
 		// Check if no tool calls - we're done
 		if len(response.ToolCalls) == 0 {
-			finalContent = response.Content
+			final.Content = response.Content
+			final.ReasoningContent = response.ReasoningContent
+			final.ThinkingBlocks = response.ThinkingBlocks
 			logger.InfoCF("agent", "LLM response without tool calls (direct answer)",
 				map[string]any{
 					"agent_id":      agent.ID,
 					"iteration":     iteration,
-					"content_chars": len(finalContent),
+					"content_chars": len(final.Content),
 				})
 			break
 		}
@@ -820,6 +1031,7 @@ func (al *AgentLoop) runLLMIteration(
 			Role:             "assistant",
 			Content:          response.Content,
 			ReasoningContent: response.ReasoningContent,
+			ThinkingBlocks:   response.ThinkingBlocks,
 		}
 		for _, tc := range normalizedToolCalls {
 			argumentsJSON, _ := json.Marshal(tc.Arguments)
@@ -846,9 +1058,10 @@ func (al *AgentLoop) runLLMIteration(
 		messages = append(messages, assistantMsg)
 
 		// Save assistant message with tool calls to session
-		agent.Sessions.AddFullMessage(opts.SessionKey, assistantMsg)
+		agent.Sessions.AddFullMessage(opts.SessionKey, agent.historyMessage(assistantMsg))
 
 		// Execute tool calls
+		var toolMedia []string
 		for _, tc := range normalizedToolCalls {
 			argsJSON, _ := json.Marshal(tc.Arguments)
 			argsPreview := utils.Truncate(string(argsJSON), 200)
@@ -908,7 +1121,7 @@ func (al *AgentLoop) runLLMIteration(
 						if _, meta, err := al.mediaStore.ResolveWithMeta(ref); err == nil {
 							part.Filename = meta.Filename
 							part.ContentType = meta.ContentType
-							part.Type = inferMediaType(meta.Filename, meta.ContentType)
+							part.Type = media.InferType(meta.Filename, meta.ContentType)
 						}
 					}
 					parts = append(parts, part)
@@ -935,10 +1148,17 @@ func (al *AgentLoop) runLLMIteration(
 
 			// Save tool result message to session
 			agent.Sessions.AddFullMessage(opts.SessionKey, toolResultMsg)
+			toolMedia = append(toolMedia, toolResult.ForLLMMedia...)
+		}
+
+		// Files attached for the model (e.g. images from read_file) are only kept
+		// for this turn; the session stores the tool results' text.
+		if len(toolMedia) > 0 {
+			messages = append(messages, tools.MediaMessage(toolMedia))
 		}
 	}
 
-	return finalContent, iteration, nil
+	return final, iteration, nil
 }
 
 // updateToolContexts updates the context for tools that need channel/chatID info.
@@ -973,6 +1193,17 @@ func (al *AgentLoop) updateToolContexts(agent *AgentInstance, channel, chatID st
 	}
 }
 
+// hasMedia reports whether the latest user message carries attached images.
+// Older turns are ignored so one picture doesn't pin the session to the image model.
+func hasMedia(messages []providers.Message) bool {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			return len(messages[i].Media) > 0
+		}
+	}
+	return false
+}
+
 // maybeSummarize triggers summarization if the session history exceeds thresholds.
 func (al *AgentLoop) maybeSummarize(agent *AgentInstance, sessionKey, channel, chatID string) {
 	newHistory := agent.Sessions.GetHistory(sessionKey)
@@ -1264,6 +1495,57 @@ func (al *AgentLoop) estimateTokens(messages []providers.Message) int {
 	return totalChars * 2 / 5
 }
 
+// providerStatusReport lists every provider referenced by an agent, plus any
+// with recorded failures, together with its availability and cooldown.
+func (al *AgentLoop) providerStatusReport() string {
+	if al.cooldown == nil {
+		return "Provider cooldown tracking not initialized"
+	}
+
+	seen := make(map[string]bool)
+	var names []string
+	add := func(name string) {
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	for _, id := range al.registry.ListAgentIDs() {
+		if agent, ok := al.registry.GetAgent(id); ok {
+			for _, c := range agent.Candidates {
+				add(c.Provider)
+			}
+			for _, c := range agent.ImageCandidates {
+				add(c.Provider)
+			}
+		}
+	}
+	for _, name := range al.cooldown.Providers() {
+		add(name)
+	}
+	if len(names) == 0 {
+		return "No providers configured"
+	}
+	sort.Strings(names)
+
+	lines := make([]string, 0, len(names))
+	for _, name := range names {
+		lines = append(lines, "  "+al.cooldown.Status(name).String())
+	}
+	report := fmt.Sprintf("Providers:\n%s", strings.Join(lines, "\n"))
+
+	if len(al.keyPools) > 0 {
+		report += "\n\nAPI keys:"
+		for _, pool := range al.keyPools {
+			report += "\n  " + pool.Name() + ":"
+			for _, u := range pool.Usage() {
+				report += "\n    " + u.String()
+			}
+		}
+	}
+	return report
+}
+
 func (al *AgentLoop) handleCommand(ctx context.Context, msg bus.InboundMessage) (string, bool) {
 	content := strings.TrimSpace(msg.Content)
 	if !strings.HasPrefix(content, "/") {
@@ -1283,7 +1565,9 @@ func (al *AgentLoop) handleCommand(ctx context.Context, msg bus.InboundMessage)
 		return `Available commands:
   /help                     Show this help message
   /new                      Start a new conversation
+  /rollback [n]             Undo file changes of the last n turns
   /status                   Show current session info
+  /providers                Show provider availability and cooldowns
   /doctor                   Diagnose and repair current session
   /show model               Show current model
   /show channel             Show current channel
@@ -1380,8 +1664,54 @@ func (al *AgentLoop) handleCommand(ctx context.Context, msg bus.InboundMessage)
 		agent.Sessions.SetSummary(sessionKey, "")
 		agent.Sessions.Save(sessionKey)
 
+		// Background processes and serial ports belong to the conversation that started them
+		if serial, ok := serialTool(agent); ok {
+			serial.CloseSession(tools.ProcessSessionKey(msg.Channel, msg.ChatID))
+		}
+		if agent.Processes != nil {
+			if n := agent.Processes.KillSession(tools.ProcessSessionKey(msg.Channel, msg.ChatID)); n > 0 {
+				return fmt.Sprintf("Started a new conversation. Previous session saved, "+
+					"%d background process(es) stopped.", n), true
+			}
+		}
+
 		return "Started a new conversation. Previous session saved.", true
 
+	case "/rollback":
+		turns := 1
+		if len(args) > 0 {
+			n, err := strconv.Atoi(args[0])
+			if err != nil || n < 1 {
+				return "Usage: /rollback [n]", true
+			}
+			turns = n
+		}
+
+		route := al.registry.ResolveRoute(routing.RouteInput{
+			Channel:   msg.Channel,
+			AccountID: msg.Metadata["account_id"],
+			Peer:      extractPeer(msg),
+			GuildID:   msg.Metadata["guild_id"],
+			TeamID:    msg.Metadata["team_id"],
+		})
+
+		agent, ok := al.registry.GetAgent(route.AgentID)
+		if !ok {
+			agent = al.registry.GetDefaultAgent()
+		}
+		if agent == nil {
+			return "No agent configured", true
+		}
+		if agent.Journal == nil {
+			return "The file change journal is disabled", true
+		}
+
+		restored, err := agent.Journal.Rollback(route.SessionKey, turns)
+		if err != nil {
+			return fmt.Sprintf("Rollback failed: %v", err), true
+		}
+		return formatRollback(restored, agent.Workspace), true
+
 	case "/status":
 		// Resolve the route to find the correct agent and session key
 		route := al.registry.ResolveRoute(routing.RouteInput{
@@ -1410,6 +1740,9 @@ func (al *AgentLoop) handleCommand(ctx context.Context, msg bus.InboundMessage)
   Messages: %d in current session
   Max iterations: %d`, agent.Model, agent.ID, msg.Channel, len(history), agent.MaxIterations), true
 
+	case "/providers":
+		return al.providerStatusReport(), true
+
 	case "/doctor":
 		// Diagnose and repair the current session in-place
 		route := al.registry.ResolveRoute(routing.RouteInput{
@@ -1505,6 +1838,26 @@ func (al *AgentLoop) handleCommand(ctx context.Context, msg bus.InboundMessage)
 	return "", false
 }
 
+// formatRollback describes the files restored by /rollback.
+func formatRollback(restored []tools.JournalEntry, workspace string) string {
+	lines := []string{"Rolled back file changes:"}
+	for _, e := range restored {
+		path := e.Path
+		if rel, err := filepath.Rel(workspace, path); err == nil && filepath.IsLocal(rel) {
+			path = rel
+		}
+		switch {
+		case e.Omitted:
+			lines = append(lines, fmt.Sprintf("  %s: not restored (too large to snapshot)", path))
+		case e.Existed:
+			lines = append(lines, fmt.Sprintf("  %s: restored", path))
+		default:
+			lines = append(lines, fmt.Sprintf("  %s: removed", path))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
 // extractPeer extracts the routing peer from the inbound message's structured Peer field.
 func extractPeer(msg bus.InboundMessage) *routing.RoutePeer {
 	if msg.Peer.Kind == "" {
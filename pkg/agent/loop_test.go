@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
 	"slices"
 	"strings"
 	"testing"
@@ -14,6 +15,7 @@ import (
 	"github.com/sipeed/picoclaw/pkg/channels"
 	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/routing"
 	"github.com/sipeed/picoclaw/pkg/tools"
 )
 
@@ -951,6 +953,74 @@ func TestHandleCommand_ListModels(t *testing.T) {
 	}
 }
 
+func TestHandleCommand_Providers(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// A cooldown recorded before the restart must still be reported.
+	providers.NewPersistentCooldownTracker(providers.CooldownStatePath(tmpDir)).
+		MarkFailure("anthropic", providers.FailoverBilling)
+
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         tmpDir,
+				Model:             "openai/gpt-4o",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &mockProvider{})
+	response, handled := al.handleCommand(context.Background(), bus.InboundMessage{
+		Channel: "test",
+		ChatID:  "chat1",
+		Content: "/providers",
+	})
+	if !handled {
+		t.Fatal("Expected /providers to be handled")
+	}
+	if !strings.Contains(response, "anthropic: cooling down") || !strings.Contains(response, "(disabled: billing)") {
+		t.Errorf("Expected restored billing cooldown, got: %s", response)
+	}
+	if !strings.Contains(response, "openai: available") {
+		t.Errorf("Expected configured provider to be listed, got: %s", response)
+	}
+}
+
+func TestHandleCommand_ProvidersShowsKeyUsage(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         tmpDir,
+				Model:             "openai/gpt-4o",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	pool := providers.NewKeyPoolProvider("gpt", "", []*providers.PooledKey{
+		{Label: "key1(sk-…0001)", Provider: &mockProvider{}},
+	})
+
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), pool)
+	if _, err := pool.Chat(context.Background(), nil, nil, "gpt-4o", nil); err != nil {
+		t.Fatalf("Chat() error: %v", err)
+	}
+	response, _ := al.handleCommand(context.Background(), bus.InboundMessage{
+		Channel: "test",
+		ChatID:  "chat1",
+		Content: "/providers",
+	})
+	if !strings.Contains(response, "API keys:\n  gpt:\n    key1(sk-…0001): 1 request(s)") {
+		t.Errorf("Expected per-key usage, got: %s", response)
+	}
+	if reports, err := providers.LoadKeyUsage(providers.KeyUsagePath(tmpDir)); err != nil || len(reports) != 1 {
+		t.Errorf("LoadKeyUsage() = %+v, %v; want the pool's report", reports, err)
+	}
+}
+
 func TestHandleCommand_NotACommand(t *testing.T) {
 	tmpDir, err := os.MkdirTemp("", "agent-test-*")
 	if err != nil {
@@ -1202,3 +1272,262 @@ func TestHandleCommand_Status(t *testing.T) {
 		}
 	}
 }
+
+type modelRecordingProvider struct {
+	models []string
+}
+
+func (p *modelRecordingProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	p.models = append(p.models, model)
+	return &providers.LLMResponse{Content: "answered by " + model}, nil
+}
+
+func (p *modelRecordingProvider) GetDefaultModel() string {
+	return "recording-model"
+}
+
+func TestAgentLoop_RoutesImagesToImageModel(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				ModelName:         "text-only",
+				MaxTokens:         4096,
+				MaxToolIterations: 3,
+				ImageModel:        "vision",
+			},
+		},
+		ModelList: []config.ModelConfig{
+			{ModelName: "text-only", Model: "deepseek/deepseek-chat", APIKey: "k"},
+			{ModelName: "vision", Model: "openai/gpt-4o", APIKey: "k"},
+		},
+	}
+
+	provider := &modelRecordingProvider{}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+	agent := al.registry.GetDefaultAgent()
+	opts := processOptions{SessionKey: "vision-test", Channel: "cli", ChatID: "direct"}
+
+	textOnly := []providers.Message{{Role: "system", Content: "sys"}, {Role: "user", Content: "hi"}}
+	if _, _, err := al.runLLMIteration(context.Background(), agent, textOnly, opts); err != nil {
+		t.Fatalf("runLLMIteration() error: %v", err)
+	}
+
+	withImage := []providers.Message{
+		{Role: "system", Content: "sys"},
+		{Role: "user", Content: "what is this?", Media: []string{"data:image/png;base64,AAAA"}},
+	}
+	if _, _, err := al.runLLMIteration(context.Background(), agent, withImage, opts); err != nil {
+		t.Fatalf("runLLMIteration() error: %v", err)
+	}
+
+	if !slices.Equal(provider.models, []string{"text-only", "gpt-4o"}) {
+		t.Errorf("models = %v, want [text-only gpt-4o]", provider.models)
+	}
+}
+
+func TestAgentLoop_KeepsImagesOnUnknownModel(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				ModelName:         "local",
+				MaxTokens:         4096,
+				MaxToolIterations: 3,
+				ImageModel:        "vision",
+			},
+		},
+		ModelList: []config.ModelConfig{
+			{ModelName: "local", Model: "vllm/my-vision-finetune", APIBase: "http://localhost:8000/v1"},
+			{ModelName: "vision", Model: "openai/gpt-4o", APIKey: "k"},
+		},
+	}
+
+	provider := &modelRecordingProvider{}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+	agent := al.registry.GetDefaultAgent()
+	opts := processOptions{SessionKey: "vision-unknown", Channel: "cli", ChatID: "direct"}
+
+	withImage := []providers.Message{
+		{Role: "system", Content: "sys"},
+		{Role: "user", Content: "what is this?", Media: []string{"data:image/png;base64,AAAA"}},
+	}
+	if _, _, err := al.runLLMIteration(context.Background(), agent, withImage, opts); err != nil {
+		t.Fatalf("runLLMIteration() error: %v", err)
+	}
+	if !slices.Equal(provider.models, []string{"local"}) {
+		t.Errorf("models = %v, want the unregistered primary model to keep its image turn", provider.models)
+	}
+}
+
+func TestHandleCommand_NewStopsBackgroundProcesses(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &mockProvider{})
+	defer al.Close()
+
+	agent := al.registry.GetDefaultAgent()
+	result := agent.Tools.ExecuteWithContext(context.Background(), "process", map[string]any{
+		"action":  "start",
+		"command": "sleep 60",
+	}, "test", "chat1", nil)
+	if result.IsError || !strings.Contains(result.ForLLM, "running") {
+		t.Fatalf("process start = %+v", result)
+	}
+
+	response, handled := al.handleCommand(context.Background(), bus.InboundMessage{
+		Channel: "test",
+		ChatID:  "chat1",
+		Content: "/new",
+	})
+	if !handled || !strings.Contains(response, "1 background process(es) stopped") {
+		t.Errorf("/new response = %q", response)
+	}
+
+	result = agent.Tools.ExecuteWithContext(context.Background(), "process", map[string]any{"action": "list"},
+		"test", "chat1", nil)
+	if !strings.Contains(result.ForLLM, "No background processes") {
+		t.Errorf("process list after /new = %q", result.ForLLM)
+	}
+}
+
+func TestHandleCommand_Rollback(t *testing.T) {
+	workspace := t.TempDir()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:           workspace,
+				Model:               "test-model",
+				MaxTokens:           4096,
+				MaxToolIterations:   10,
+				RestrictToWorkspace: true,
+			},
+		},
+		Tools: config.ToolsConfig{Journal: config.JournalConfig{Enabled: true}},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &mockProvider{})
+	defer al.Close()
+
+	msg := bus.InboundMessage{Channel: "test", ChatID: "chat1", Content: "/rollback"}
+	route := al.registry.ResolveRoute(routing.RouteInput{Channel: msg.Channel, Peer: extractPeer(msg)})
+	agent := al.registry.GetDefaultAgent()
+	path := filepath.Join(workspace, "config.json")
+	ctx := context.Background()
+
+	turn := agent.Journal.BeginTurn(ctx, route.SessionKey)
+	agent.Tools.Execute(turn, "write_file", map[string]any{"path": "config.json", "content": `{"a": 1}`})
+	turn = agent.Journal.BeginTurn(ctx, route.SessionKey)
+	agent.Tools.Execute(turn, "edit_file", map[string]any{"path": "config.json", "old_text": "1", "new_text": "2"})
+	agent.Tools.Execute(turn, "append_file", map[string]any{"path": "config.json", "content": "garbage"})
+
+	response, handled := al.handleCommand(ctx, msg)
+	if !handled || !strings.Contains(response, "config.json: restored") {
+		t.Errorf("/rollback response = %q", response)
+	}
+	if got, _ := os.ReadFile(path); string(got) != `{"a": 1}` {
+		t.Errorf("config.json after /rollback = %q", got)
+	}
+
+	msg.Content = "/rollback 5"
+	response, _ = al.handleCommand(ctx, msg)
+	if !strings.Contains(response, "config.json: removed") {
+		t.Errorf("/rollback 5 response = %q", response)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("config.json should have been removed")
+	}
+
+	response, _ = al.handleCommand(ctx, msg)
+	if !strings.Contains(response, "no changes to roll back") {
+		t.Errorf("empty /rollback response = %q", response)
+	}
+	msg.Content = "/rollback x"
+	if response, _ = al.handleCommand(ctx, msg); response != "Usage: /rollback [n]" {
+		t.Errorf("invalid /rollback response = %q", response)
+	}
+}
+
+// readImageProvider asks for an image with read_file, then answers.
+type readImageProvider struct {
+	calls [][]providers.Message
+}
+
+func (p *readImageProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	p.calls = append(p.calls, messages)
+	if len(p.calls) == 1 {
+		return &providers.LLMResponse{ToolCalls: []providers.ToolCall{{
+			ID:        "c1",
+			Name:      "read_file",
+			Arguments: map[string]any{"path": "pic.png"},
+		}}}, nil
+	}
+	return &providers.LLMResponse{Content: "a picture"}, nil
+}
+
+func (p *readImageProvider) GetDefaultModel() string {
+	return "vision-model"
+}
+
+func TestAgentLoop_AttachesToolMedia(t *testing.T) {
+	workspace := t.TempDir()
+	png := "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"
+	if err := os.WriteFile(filepath.Join(workspace, "pic.png"), []byte(png), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:           workspace,
+				Model:               "vision-model",
+				MaxTokens:           4096,
+				MaxToolIterations:   5,
+				RestrictToWorkspace: true,
+			},
+		},
+	}
+	provider := &readImageProvider{}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+	defer al.Close()
+
+	response, err := al.ProcessDirect(context.Background(), "what is in pic.png?", "media-test")
+	if err != nil || response != "a picture" {
+		t.Fatalf("ProcessDirect() = %q, %v", response, err)
+	}
+	if len(provider.calls) != 2 {
+		t.Fatalf("provider called %d times", len(provider.calls))
+	}
+	second := provider.calls[1]
+	last := second[len(second)-1]
+	if last.Role != "user" || len(last.Media) != 1 || !strings.HasPrefix(last.Media[0], "data:image/png;base64,") {
+		t.Errorf("last message = %+v, want the attached image", last)
+	}
+
+	for _, m := range al.registry.GetDefaultAgent().Sessions.GetHistory("media-test") {
+		if len(m.Media) > 0 {
+			t.Errorf("session history should not keep tool media: %+v", m)
+		}
+	}
+}
@@ -22,6 +22,7 @@ type AuthCredential struct {
 	SubscriptionType string    `json:"subscription_type,omitempty"`
 	OrgID            string    `json:"org_id,omitempty"`
 	APIKey           string    `json:"api_key,omitempty"`
+	APIKeys          []string  `json:"api_keys,omitempty"` // key pool used by model_list entries with auth_method "api_keys"
 }
 
 type AuthStore struct {
@@ -3,7 +3,11 @@ package config
 import (
 	"encoding/json"
 	"fmt"
+	"net"
+	"net/netip"
 	"os"
+	"regexp"
+	"strings"
 	"sync/atomic"
 
 	"github.com/caarlos0/env/v11"
@@ -58,6 +62,9 @@ type Config struct {
 	Tools     ToolsConfig     `json:"tools"`
 	Heartbeat HeartbeatConfig `json:"heartbeat"`
 	Devices   DevicesConfig   `json:"devices"`
+
+	ResponseCache ResponseCacheConfig `json:"response_cache"`
+	MCP           MCPConfig           `json:"mcp"`
 }
 
 // MarshalJSON implements custom JSON marshaling for Config
@@ -129,16 +136,187 @@ func (m AgentModelConfig) MarshalJSON() ([]byte, error) {
 	return json.Marshal(raw{Primary: m.Primary, Fallbacks: m.Fallbacks})
 }
 
+// ReasoningConfig controls thinking/reasoning for models that support it.
+// Each provider maps it to its native parameter: reasoning_effort (OpenAI),
+// thinking budget_tokens (Anthropic), thinkingBudget (Gemini), think (Ollama).
+type ReasoningConfig struct {
+	Effort           string `json:"effort,omitempty"`             // none, minimal, low, medium, high
+	BudgetTokens     int    `json:"budget_tokens,omitempty"`      // Explicit token budget; overrides the effort mapping
+	IncludeInHistory *bool  `json:"include_in_history,omitempty"` // Keep reasoning text in session history (default false)
+}
+
+// Merge returns r with the fields set in other layered on top.
+// Either side may be nil.
+func (r *ReasoningConfig) Merge(other *ReasoningConfig) *ReasoningConfig {
+	if other == nil {
+		return r
+	}
+	if r == nil {
+		merged := *other
+		return &merged
+	}
+	merged := *r
+	if other.Effort != "" {
+		merged.Effort = other.Effort
+	}
+	if other.BudgetTokens != 0 {
+		merged.BudgetTokens = other.BudgetTokens
+	}
+	if other.IncludeInHistory != nil {
+		merged.IncludeInHistory = other.IncludeInHistory
+	}
+	return &merged
+}
+
+// Validate checks the effort level and budget.
+func (r *ReasoningConfig) Validate() error {
+	if r == nil {
+		return nil
+	}
+	switch r.Effort {
+	case "", "none", "minimal", "low", "medium", "high":
+	default:
+		return fmt.Errorf("reasoning.effort must be one of none, minimal, low, medium, high; got %q", r.Effort)
+	}
+	if r.BudgetTokens < 0 {
+		return fmt.Errorf("reasoning.budget_tokens must not be negative")
+	}
+	return nil
+}
+
+// SandboxConfig confines the commands run by the exec and process tools
+// (Linux only): writes are limited to the workspace and WritablePaths, and
+// optional rlimits and network isolation are applied. Features the kernel
+// lacks are skipped with a warning.
+type SandboxConfig struct {
+	Enabled       *bool    `json:"enabled,omitempty"`
+	NoNetwork     *bool    `json:"no_network,omitempty"`     // Block all network access
+	WritablePaths []string `json:"writable_paths,omitempty"` // Extra writable directories besides the workspace
+	CPUSeconds    int      `json:"cpu_seconds,omitempty"`    // RLIMIT_CPU per command; 0 = unlimited
+	MemoryMB      int      `json:"memory_mb,omitempty"`      // RLIMIT_AS per process; 0 = unlimited
+	MaxProcesses  int      `json:"max_processes,omitempty"`  // RLIMIT_NPROC; 0 = unlimited
+}
+
+// Merge returns s with the fields set in other layered on top.
+// Either side may be nil.
+func (s *SandboxConfig) Merge(other *SandboxConfig) *SandboxConfig {
+	if other == nil {
+		return s
+	}
+	if s == nil {
+		merged := *other
+		return &merged
+	}
+	merged := *s
+	if other.Enabled != nil {
+		merged.Enabled = other.Enabled
+	}
+	if other.NoNetwork != nil {
+		merged.NoNetwork = other.NoNetwork
+	}
+	if other.WritablePaths != nil {
+		merged.WritablePaths = other.WritablePaths
+	}
+	if other.CPUSeconds != 0 {
+		merged.CPUSeconds = other.CPUSeconds
+	}
+	if other.MemoryMB != 0 {
+		merged.MemoryMB = other.MemoryMB
+	}
+	if other.MaxProcesses != 0 {
+		merged.MaxProcesses = other.MaxProcesses
+	}
+	return &merged
+}
+
+// Validate checks that the limits are not negative.
+func (s *SandboxConfig) Validate() error {
+	if s == nil {
+		return nil
+	}
+	if s.CPUSeconds < 0 {
+		return fmt.Errorf("sandbox.cpu_seconds must not be negative")
+	}
+	if s.MemoryMB < 0 {
+		return fmt.Errorf("sandbox.memory_mb must not be negative")
+	}
+	if s.MaxProcesses < 0 {
+		return fmt.Errorf("sandbox.max_processes must not be negative")
+	}
+	for _, p := range s.WritablePaths {
+		if p == "" {
+			return fmt.Errorf("sandbox.writable_paths must not contain empty entries")
+		}
+	}
+	return nil
+}
+
 type AgentConfig struct {
 	ID        string            `json:"id"`
 	Default   bool              `json:"default,omitempty"`
 	Name      string            `json:"name,omitempty"`
 	Workspace string            `json:"workspace,omitempty"`
 	Model     *AgentModelConfig `json:"model,omitempty"`
+	Reasoning *ReasoningConfig  `json:"reasoning,omitempty"`
+	Sandbox   *SandboxConfig    `json:"sandbox,omitempty"`
+	GPIO      *GPIOConfig       `json:"gpio,omitempty"`
 	Skills    []string          `json:"skills,omitempty"`
 	Subagents *SubagentsConfig  `json:"subagents,omitempty"`
 }
 
+// GPIOConfig lists the GPIO lines an agent may use through the gpio tool
+// (Linux only), keyed by chip name ("gpiochip0") with line offsets as
+// values. Chips and lines can always be listed; other lines are refused.
+type GPIOConfig struct {
+	Lines       map[string][]int `json:"lines,omitempty"`        // Lines that may be read and watched for edges
+	OutputLines map[string][]int `json:"output_lines,omitempty"` // Lines that may also be driven as outputs
+}
+
+// Merge returns g with the fields set in other layered on top.
+// Either side may be nil.
+func (g *GPIOConfig) Merge(other *GPIOConfig) *GPIOConfig {
+	if other == nil {
+		return g
+	}
+	if g == nil {
+		merged := *other
+		return &merged
+	}
+	merged := *g
+	if other.Lines != nil {
+		merged.Lines = other.Lines
+	}
+	if other.OutputLines != nil {
+		merged.OutputLines = other.OutputLines
+	}
+	return &merged
+}
+
+// Validate checks the chip names and line offsets.
+func (g *GPIOConfig) Validate() error {
+	if g == nil {
+		return nil
+	}
+	for _, list := range []struct {
+		name  string
+		lines map[string][]int
+	}{{"lines", g.Lines}, {"output_lines", g.OutputLines}} {
+		for chip, offsets := range list.lines {
+			if !gpioChipRe.MatchString(chip) {
+				return fmt.Errorf("gpio.%s: invalid chip %q: use a name such as \"gpiochip0\"", list.name, chip)
+			}
+			for _, offset := range offsets {
+				if offset < 0 {
+					return fmt.Errorf("gpio.%s.%s: line offsets must not be negative", list.name, chip)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+var gpioChipRe = regexp.MustCompile(`^gpiochip\d+$`)
+
 type SubagentsConfig struct {
 	AllowAgents []string          `json:"allow_agents,omitempty"`
 	Model       *AgentModelConfig `json:"model,omitempty"`
@@ -179,6 +357,11 @@ type AgentDefaults struct {
 	MaxTokens           int      `json:"max_tokens"                      env:"PICOCLAW_AGENTS_DEFAULTS_MAX_TOKENS"`
 	Temperature         *float64 `json:"temperature,omitempty"           env:"PICOCLAW_AGENTS_DEFAULTS_TEMPERATURE"`
 	MaxToolIterations   int      `json:"max_tool_iterations"             env:"PICOCLAW_AGENTS_DEFAULTS_MAX_TOOL_ITERATIONS"`
+	RateLimitMaxWait    int      `json:"rate_limit_max_wait,omitempty"   env:"PICOCLAW_AGENTS_DEFAULTS_RATE_LIMIT_MAX_WAIT"`
+
+	Reasoning *ReasoningConfig `json:"reasoning,omitempty"`
+	Sandbox   *SandboxConfig   `json:"sandbox,omitempty"`
+	GPIO      *GPIOConfig      `json:"gpio,omitempty"`
 }
 
 // GetModelName returns the effective model name for the agent defaults.
@@ -377,6 +560,66 @@ type HeartbeatConfig struct {
 	Interval int  `json:"interval" env:"PICOCLAW_HEARTBEAT_INTERVAL"` // minutes, min 5
 }
 
+// ResponseCacheConfig configures the opt-in cache of LLM responses for
+// repeated identical requests (heartbeat, cron, summarization retries).
+// TTL is in seconds; Path defaults to ~/.picoclaw/response_cache.json.
+// Requests with a temperature above MaxTemperature bypass the cache.
+type ResponseCacheConfig struct {
+	Enabled           bool     `json:"enabled"                   env:"PICOCLAW_RESPONSE_CACHE_ENABLED"`
+	TTL               int      `json:"ttl"                       env:"PICOCLAW_RESPONSE_CACHE_TTL"`
+	MaxEntries        int      `json:"max_entries"               env:"PICOCLAW_RESPONSE_CACHE_MAX_ENTRIES"`
+	MaxBytes          int      `json:"max_bytes"                 env:"PICOCLAW_RESPONSE_CACHE_MAX_BYTES"`
+	Persist           bool     `json:"persist"                   env:"PICOCLAW_RESPONSE_CACHE_PERSIST"`
+	Path              string   `json:"path,omitempty"            env:"PICOCLAW_RESPONSE_CACHE_PATH"`
+	MaxTemperature    *float64 `json:"max_temperature,omitempty" env:"PICOCLAW_RESPONSE_CACHE_MAX_TEMPERATURE"`
+	IgnoreCurrentTime bool     `json:"ignore_current_time"       env:"PICOCLAW_RESPONSE_CACHE_IGNORE_CURRENT_TIME"`
+}
+
+// MCPConfig lists the Model Context Protocol servers whose tools, resources
+// and prompts are mounted into agents.
+type MCPConfig struct {
+	Enabled bool              `json:"enabled" env:"PICOCLAW_MCP_ENABLED"`
+	Servers []MCPServerConfig `json:"servers,omitempty"`
+}
+
+// MCPServerConfig describes one MCP server. Type "stdio" launches Command and
+// speaks JSON-RPC over its stdin/stdout; type "http" uses the streamable HTTP
+// transport at URL. Agents restricts the server to the listed agent IDs
+// (empty = every agent).
+type MCPServerConfig struct {
+	Name     string            `json:"name"`
+	Type     string            `json:"type,omitempty"` // "stdio" (default) or "http"
+	Command  string            `json:"command,omitempty"`
+	Args     []string          `json:"args,omitempty"`
+	Env      map[string]string `json:"env,omitempty"`
+	Dir      string            `json:"dir,omitempty"`
+	URL      string            `json:"url,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Agents   []string          `json:"agents,omitempty"`
+	Timeout  int               `json:"timeout,omitempty"` // seconds per request, default 60
+	Disabled bool              `json:"disabled,omitempty"`
+}
+
+// Validate checks that the server has a usable name and transport.
+func (c *MCPServerConfig) Validate() error {
+	if strings.TrimSpace(c.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	switch c.Type {
+	case "", "stdio":
+		if strings.TrimSpace(c.Command) == "" {
+			return fmt.Errorf("command is required for stdio server %q", c.Name)
+		}
+	case "http":
+		if strings.TrimSpace(c.URL) == "" {
+			return fmt.Errorf("url is required for http server %q", c.Name)
+		}
+	default:
+		return fmt.Errorf("type must be \"stdio\" or \"http\", got %q", c.Type)
+	}
+	return nil
+}
+
 type DevicesConfig struct {
 	Enabled    bool `json:"enabled"     env:"PICOCLAW_DEVICES_ENABLED"`
 	MonitorUSB bool `json:"monitor_usb" env:"PICOCLAW_DEVICES_MONITOR_USB"`
@@ -465,6 +708,10 @@ type ModelConfig struct {
 	APIKey  string `json:"api_key"`            // API authentication key
 	Proxy   string `json:"proxy,omitempty"`    // HTTP proxy URL
 
+	// Key pools: several keys for the same endpoint, rotated per request
+	APIKeys     []string `json:"api_keys,omitempty"`     // Additional keys; api_key (if set) joins the pool
+	KeyRotation string   `json:"key_rotation,omitempty"` // round_robin (default), least_used, fill_first
+
 	// Special providers (CLI-based, OAuth, etc.)
 	AuthMethod  string `json:"auth_method,omitempty"`  // Authentication method: oauth, token
 	ConnectMode string `json:"connect_mode,omitempty"` // Connection mode: stdio, grpc
@@ -472,8 +719,29 @@ type ModelConfig struct {
 
 	// Optional optimizations
 	RPM            int    `json:"rpm,omitempty"`              // Requests per minute limit
+	TPM            int    `json:"tpm,omitempty"`              // Tokens per minute limit
+	MaxConcurrent  int    `json:"max_concurrent,omitempty"`   // Maximum in-flight requests
+	LoadBalance    string `json:"load_balance,omitempty"`     // Strategy across entries sharing model_name
+	Weight         int    `json:"weight,omitempty"`           // Relative weight for the "weighted" strategy
 	MaxTokensField string `json:"max_tokens_field,omitempty"` // Field name for max tokens (e.g., "max_completion_tokens")
 	RequestTimeout int    `json:"request_timeout,omitempty"`
+
+	// Capability overrides
+	ContextWindow int    `json:"context_window,omitempty"` // Overrides the discovered/bundled context window
+	ToolMode      string `json:"tool_mode,omitempty"`      // "native" (default) or "prompted" for models without function calling
+
+	// Reasoning defaults for this model; agent-level settings take precedence
+	Reasoning *ReasoningConfig `json:"reasoning,omitempty"`
+
+	// Native Ollama protocol
+	KeepAlive string         `json:"keep_alive,omitempty"` // How long the model stays loaded (e.g., "5m", "-1")
+	NumCtx    int            `json:"num_ctx,omitempty"`    // Context window; 0 discovers it from /api/show
+	Options   map[string]any `json:"options,omitempty"`    // Model options passed through (e.g., top_k, repeat_penalty)
+
+	// Native Gemini protocol
+	ThinkingBudget *int              `json:"thinking_budget,omitempty"` // Thinking token budget (0 disables, -1 dynamic)
+	SafetySettings map[string]string `json:"safety_settings,omitempty"` // Harm category -> block threshold
+	CachedContent  string            `json:"cached_content,omitempty"`  // cachedContents/... resource used as prompt prefix
 }
 
 // Validate checks if the ModelConfig has all required fields.
@@ -484,7 +752,17 @@ func (c *ModelConfig) Validate() error {
 	if c.Model == "" {
 		return fmt.Errorf("model is required")
 	}
-	return nil
+	switch c.ToolMode {
+	case "", "native", "prompted":
+	default:
+		return fmt.Errorf("tool_mode must be \"native\" or \"prompted\", got %q", c.ToolMode)
+	}
+	switch c.KeyRotation {
+	case "", "round_robin", "least_used", "fill_first":
+	default:
+		return fmt.Errorf("key_rotation must be \"round_robin\", \"least_used\" or \"fill_first\", got %q", c.KeyRotation)
+	}
+	return c.Reasoning.Validate()
 }
 
 type GatewayConfig struct {
@@ -533,8 +811,145 @@ type CronToolsConfig struct {
 type ExecConfig struct {
 	EnableDenyPatterns bool     `json:"enable_deny_patterns" env:"PICOCLAW_TOOLS_EXEC_ENABLE_DENY_PATTERNS"`
 	CustomDenyPatterns []string `json:"custom_deny_patterns" env:"PICOCLAW_TOOLS_EXEC_CUSTOM_DENY_PATTERNS"`
+	// MaxProcesses bounds the background processes the process tool may run per session.
+	MaxProcesses int `json:"max_processes" env:"PICOCLAW_TOOLS_EXEC_MAX_PROCESSES"`
+	// ProcessOutputBytes is the output kept per background process; older output is dropped.
+	ProcessOutputBytes int `json:"process_output_bytes" env:"PICOCLAW_TOOLS_EXEC_PROCESS_OUTPUT_BYTES"`
+	// MaxOutputBytes is how much of each exec output stream (its head and tail) is returned to the model.
+	MaxOutputBytes int `json:"max_output_bytes" env:"PICOCLAW_TOOLS_EXEC_MAX_OUTPUT_BYTES"`
+	// MaxSpillBytes caps the file holding the full output of a truncated stream; negative disables spilling.
+	MaxSpillBytes int `json:"max_spill_bytes" env:"PICOCLAW_TOOLS_EXEC_MAX_SPILL_BYTES"`
+}
+
+// EgressConfig limits the hosts that web_fetch and skill downloads may
+// reach. Host entries are names ("example.com"), wildcard names
+// ("*.example.com", "*"), IP addresses or CIDR ranges.
+type EgressConfig struct {
+	// BlockPrivateNetworks refuses loopback, private, link-local (including
+	// cloud metadata) and other non-public addresses.
+	BlockPrivateNetworks bool `json:"block_private_networks" env:"PICOCLAW_TOOLS_EGRESS_BLOCK_PRIVATE_NETWORKS"`
+	// AllowHosts are always reachable, even on private addresses.
+	AllowHosts []string `json:"allow_hosts" env:"PICOCLAW_TOOLS_EGRESS_ALLOW_HOSTS"`
+	// DenyHosts are never reachable unless also allowed; "*" denies every host not in AllowHosts.
+	DenyHosts []string `json:"deny_hosts" env:"PICOCLAW_TOOLS_EGRESS_DENY_HOSTS"`
+	// MaxResponseBytes caps how much of a response body is read.
+	MaxResponseBytes int64 `json:"max_response_bytes" env:"PICOCLAW_TOOLS_EGRESS_MAX_RESPONSE_BYTES"`
+}
+
+// Validate checks the host entries and limits.
+func (e *EgressConfig) Validate() error {
+	if e.MaxResponseBytes < 0 {
+		return fmt.Errorf("egress.max_response_bytes must not be negative")
+	}
+	for _, list := range []struct {
+		name  string
+		hosts []string
+	}{{"allow_hosts", e.AllowHosts}, {"deny_hosts", e.DenyHosts}} {
+		for _, h := range list.hosts {
+			if err := validateHostPattern(h); err != nil {
+				return fmt.Errorf("egress.%s: %w", list.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func validateHostPattern(h string) error {
+	switch {
+	case h == "":
+		return fmt.Errorf("empty entry")
+	case strings.Contains(h, "/"):
+		if _, err := netip.ParsePrefix(h); err != nil {
+			return fmt.Errorf("invalid CIDR %q", h)
+		}
+	case strings.ContainsAny(h, ":@ ") && net.ParseIP(h) == nil:
+		return fmt.Errorf("invalid host %q: use a host name or IP address without scheme or port", h)
+	case strings.Contains(strings.TrimPrefix(h, "*."), "*") && h != "*":
+		return fmt.Errorf("invalid host %q: \"*\" is only allowed as \"*\" or a \"*.\" prefix", h)
+	}
+	return nil
+}
+
+// JournalConfig bounds the journal of files changed by the agent, used by /rollback.
+type JournalConfig struct {
+	Enabled    bool `json:"enabled"     env:"PICOCLAW_TOOLS_JOURNAL_ENABLED"`
+	MaxEntries int  `json:"max_entries" env:"PICOCLAW_TOOLS_JOURNAL_MAX_ENTRIES"`
+	// MaxBytes caps the total size of the stored snapshots.
+	MaxBytes int64 `json:"max_bytes" env:"PICOCLAW_TOOLS_JOURNAL_MAX_BYTES"`
+	// MaxFileBytes is the largest file that is snapshotted; larger files are listed but cannot be restored.
+	MaxFileBytes int64 `json:"max_file_bytes" env:"PICOCLAW_TOOLS_JOURNAL_MAX_FILE_BYTES"`
+}
+
+// SendFileConfig limits the workspace files the send_file tool delivers to chats.
+type SendFileConfig struct {
+	Enabled bool `json:"enabled" env:"PICOCLAW_TOOLS_SEND_FILE_ENABLED"`
+	// MaxBytes is the largest file sent on channels without a limit of their own.
+	MaxBytes int64 `json:"max_bytes" env:"PICOCLAW_TOOLS_SEND_FILE_MAX_BYTES"`
+	// ChannelMaxBytes sets the largest file per channel, following the platforms' upload limits.
+	ChannelMaxBytes map[string]int64 `json:"channel_max_bytes"`
+}
+
+// Validate checks the size limits.
+func (s *SendFileConfig) Validate() error {
+	if s.MaxBytes < 0 {
+		return fmt.Errorf("send_file.max_bytes must not be negative")
+	}
+	for channel, n := range s.ChannelMaxBytes {
+		if n < 0 {
+			return fmt.Errorf("send_file.channel_max_bytes.%s must not be negative", channel)
+		}
+	}
+	return nil
 }
 
+// HTTPRequestConfig configures the http_request tool and its credentials vault.
+type HTTPRequestConfig struct {
+	Enabled        bool `json:"enabled"         env:"PICOCLAW_TOOLS_HTTP_REQUEST_ENABLED"`
+	TimeoutSeconds int  `json:"timeout_seconds" env:"PICOCLAW_TOOLS_HTTP_REQUEST_TIMEOUT_SECONDS"`
+	// MaxResponseChars caps the response text returned to the model.
+	MaxResponseChars int `json:"max_response_chars" env:"PICOCLAW_TOOLS_HTTP_REQUEST_MAX_RESPONSE_CHARS"`
+	// Credentials are named secrets that requests reference as {{secret:name}}.
+	// Their values are filled in when the request is sent and are never shown
+	// to the model.
+	Credentials map[string]HTTPCredential `json:"credentials"`
+}
+
+// HTTPCredential is one named secret of the http_request tool.
+type HTTPCredential struct {
+	Value string `json:"value"`
+	// Hosts the secret may be sent to, in the egress allow_hosts syntax.
+	Hosts []string `json:"hosts"`
+}
+
+// Validate checks the limits and credentials.
+func (h *HTTPRequestConfig) Validate() error {
+	if h.TimeoutSeconds < 0 {
+		return fmt.Errorf("http_request.timeout_seconds must not be negative")
+	}
+	if h.MaxResponseChars < 0 {
+		return fmt.Errorf("http_request.max_response_chars must not be negative")
+	}
+	for name, cred := range h.Credentials {
+		if !credentialNameRe.MatchString(name) {
+			return fmt.Errorf("http_request.credentials: invalid name %q: use letters, digits, '-', '_' and '.'", name)
+		}
+		if cred.Value == "" {
+			return fmt.Errorf("http_request.credentials.%s: value is empty", name)
+		}
+		if len(cred.Hosts) == 0 {
+			return fmt.Errorf("http_request.credentials.%s: hosts must list where the secret may be sent", name)
+		}
+		for _, host := range cred.Hosts {
+			if err := validateHostPattern(host); err != nil {
+				return fmt.Errorf("http_request.credentials.%s.hosts: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+var credentialNameRe = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
 type MediaCleanupConfig struct {
 	Enabled  bool `json:"enabled"          env:"PICOCLAW_MEDIA_CLEANUP_ENABLED"`
 	MaxAge   int  `json:"max_age_minutes"  env:"PICOCLAW_MEDIA_CLEANUP_MAX_AGE"`
@@ -545,6 +960,10 @@ type ToolsConfig struct {
 	Web          WebToolsConfig     `json:"web"`
 	Cron         CronToolsConfig    `json:"cron"`
 	Exec         ExecConfig         `json:"exec"`
+	Egress       EgressConfig       `json:"egress"`
+	SendFile     SendFileConfig     `json:"send_file"`
+	HTTPRequest  HTTPRequestConfig  `json:"http_request"`
+	Journal      JournalConfig      `json:"journal"`
 	Skills       SkillsToolsConfig  `json:"skills"`
 	MediaCleanup MediaCleanupConfig `json:"media_cleanup"`
 }
@@ -621,6 +1040,24 @@ func LoadConfig(path string) (*Config, error) {
 	if err := cfg.ValidateModelList(); err != nil {
 		return nil, err
 	}
+	if err := cfg.validateReasoning(); err != nil {
+		return nil, err
+	}
+	if err := cfg.validateSandbox(); err != nil {
+		return nil, err
+	}
+	if err := cfg.validateMCP(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Tools.Egress.Validate(); err != nil {
+		return nil, fmt.Errorf("tools.%w", err)
+	}
+	if err := cfg.Tools.SendFile.Validate(); err != nil {
+		return nil, fmt.Errorf("tools.%w", err)
+	}
+	if err := cfg.Tools.HTTPRequest.Validate(); err != nil {
+		return nil, fmt.Errorf("tools.%w", err)
+	}
 
 	return cfg, nil
 }
@@ -729,6 +1166,12 @@ func (c *Config) GetModelConfig(modelName string) (*ModelConfig, error) {
 	return &matches[idx], nil
 }
 
+// GetModelConfigs returns every model_list entry with the given model_name.
+// Multiple entries describe a pool of endpoints for the same model alias.
+func (c *Config) GetModelConfigs(modelName string) []ModelConfig {
+	return c.findMatches(modelName)
+}
+
 // findMatches finds all ModelConfig entries with the given model_name.
 func (c *Config) findMatches(modelName string) []ModelConfig {
 	var matches []ModelConfig
@@ -763,6 +1206,57 @@ func (c *Config) HasProvidersConfig() bool {
 		v.Mistral.APIKey != "" || v.Mistral.APIBase != ""
 }
 
+// validateReasoning checks the reasoning settings of the agent defaults and
+// each agent (model_list entries are covered by ValidateModelList).
+func (c *Config) validateReasoning() error {
+	if err := c.Agents.Defaults.Reasoning.Validate(); err != nil {
+		return fmt.Errorf("agents.defaults: %w", err)
+	}
+	for i := range c.Agents.List {
+		if err := c.Agents.List[i].Reasoning.Validate(); err != nil {
+			return fmt.Errorf("agents.list[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// validateSandbox checks the sandbox and GPIO settings of the agent
+// defaults and each agent.
+func (c *Config) validateSandbox() error {
+	if err := c.Agents.Defaults.Sandbox.Validate(); err != nil {
+		return fmt.Errorf("agents.defaults: %w", err)
+	}
+	if err := c.Agents.Defaults.GPIO.Validate(); err != nil {
+		return fmt.Errorf("agents.defaults: %w", err)
+	}
+	for i := range c.Agents.List {
+		if err := c.Agents.List[i].Sandbox.Validate(); err != nil {
+			return fmt.Errorf("agents.list[%d]: %w", i, err)
+		}
+		if err := c.Agents.List[i].GPIO.Validate(); err != nil {
+			return fmt.Errorf("agents.list[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// validateMCP checks each MCP server entry and that server names are unique,
+// since they namespace the mounted tools.
+func (c *Config) validateMCP() error {
+	seen := make(map[string]bool)
+	for i := range c.MCP.Servers {
+		srv := &c.MCP.Servers[i]
+		if err := srv.Validate(); err != nil {
+			return fmt.Errorf("mcp.servers[%d]: %w", i, err)
+		}
+		if seen[srv.Name] {
+			return fmt.Errorf("mcp.servers[%d]: duplicate server name %q", i, srv.Name)
+		}
+		seen[srv.Name] = true
+	}
+	return nil
+}
+
 // ValidateModelList validates all ModelConfig entries in the model_list.
 // It checks that each model config is valid.
 // Note: Multiple entries with the same model_name are allowed for load balancing.
@@ -301,6 +301,14 @@ func TestDefaultConfig_WebTools(t *testing.T) {
 	}
 }
 
+func TestDefaultConfig_HTTPRequestDisabled(t *testing.T) {
+	cfg := DefaultConfig()
+
+	if cfg.Tools.HTTPRequest.Enabled {
+		t.Error("http_request can change data on remote hosts and should be disabled by default")
+	}
+}
+
 func TestSaveConfig_FilePermissions(t *testing.T) {
 	if runtime.GOOS == "windows" {
 		t.Skip("file permission bits are not enforced on Windows")
@@ -442,3 +450,189 @@ func TestDefaultConfig_DMScope(t *testing.T) {
 		t.Errorf("Session.DMScope = %q, want 'per-channel-peer'", cfg.Session.DMScope)
 	}
 }
+
+func TestReasoningConfig_Merge(t *testing.T) {
+	yes := true
+	base := &ReasoningConfig{Effort: "low", BudgetTokens: 1000}
+	got := base.Merge(&ReasoningConfig{Effort: "high", IncludeInHistory: &yes})
+	if got.Effort != "high" || got.BudgetTokens != 1000 || got.IncludeInHistory == nil || !*got.IncludeInHistory {
+		t.Errorf("Merge() = %+v", got)
+	}
+	if base.Effort != "low" {
+		t.Error("Merge() modified the receiver")
+	}
+
+	var none *ReasoningConfig
+	if none.Merge(nil) != nil {
+		t.Error("nil.Merge(nil) should be nil")
+	}
+	if got := none.Merge(base); got == base || got.Effort != "low" {
+		t.Errorf("nil.Merge(base) = %+v, want a copy of base", got)
+	}
+}
+
+func TestReasoningConfig_Validate(t *testing.T) {
+	valid := []*ReasoningConfig{nil, {}, {Effort: "none"}, {Effort: "high", BudgetTokens: 2048}}
+	for _, r := range valid {
+		if err := r.Validate(); err != nil {
+			t.Errorf("Validate(%+v) error = %v", r, err)
+		}
+	}
+	invalid := []*ReasoningConfig{{Effort: "max"}, {BudgetTokens: -1}}
+	for _, r := range invalid {
+		if err := r.Validate(); err == nil {
+			t.Errorf("Validate(%+v) should fail", r)
+		}
+	}
+}
+
+func TestSandboxConfig_Merge(t *testing.T) {
+	yes, no := true, false
+	base := &SandboxConfig{Enabled: &yes, WritablePaths: []string{"/cache"}, CPUSeconds: 10}
+	got := base.Merge(&SandboxConfig{Enabled: &no, MemoryMB: 256})
+	if got.Enabled == nil || *got.Enabled || got.CPUSeconds != 10 || got.MemoryMB != 256 || len(got.WritablePaths) != 1 {
+		t.Errorf("Merge() = %+v", got)
+	}
+	if !*base.Enabled || base.MemoryMB != 0 {
+		t.Error("Merge() modified the receiver")
+	}
+
+	var none *SandboxConfig
+	if got := none.Merge(base); got == base || got.CPUSeconds != 10 {
+		t.Errorf("nil.Merge(base) = %+v, want a copy of base", got)
+	}
+}
+
+func TestSandboxConfig_Validate(t *testing.T) {
+	valid := []*SandboxConfig{nil, {}, {CPUSeconds: 30, MemoryMB: 512, MaxProcesses: 64, WritablePaths: []string{"/tmp"}}}
+	for _, s := range valid {
+		if err := s.Validate(); err != nil {
+			t.Errorf("Validate(%+v) error = %v", s, err)
+		}
+	}
+	invalid := []*SandboxConfig{{CPUSeconds: -1}, {MemoryMB: -1}, {MaxProcesses: -1}, {WritablePaths: []string{""}}}
+	for _, s := range invalid {
+		if err := s.Validate(); err == nil {
+			t.Errorf("Validate(%+v) should fail", s)
+		}
+	}
+}
+
+func TestGPIOConfig_MergeValidate(t *testing.T) {
+	base := &GPIOConfig{Lines: map[string][]int{"gpiochip0": {4, 17}}}
+	got := base.Merge(&GPIOConfig{OutputLines: map[string][]int{"gpiochip0": {27}}})
+	if len(got.Lines["gpiochip0"]) != 2 || len(got.OutputLines["gpiochip0"]) != 1 || base.OutputLines != nil {
+		t.Errorf("Merge() = %+v, base = %+v", got, base)
+	}
+	if got := got.Merge(&GPIOConfig{Lines: map[string][]int{}}); len(got.Lines) != 0 || len(got.OutputLines) != 1 {
+		t.Errorf("Merge() with empty lines = %+v", got)
+	}
+
+	valid := []*GPIOConfig{nil, {}, base, {OutputLines: map[string][]int{"gpiochip12": {0}}}}
+	for _, g := range valid {
+		if err := g.Validate(); err != nil {
+			t.Errorf("Validate(%+v) error = %v", g, err)
+		}
+	}
+	invalid := []*GPIOConfig{
+		{Lines: map[string][]int{"0": {1}}},
+		{Lines: map[string][]int{"/dev/gpiochip0": {1}}},
+		{OutputLines: map[string][]int{"gpiochip0": {-1}}},
+	}
+	for _, g := range invalid {
+		if err := g.Validate(); err == nil {
+			t.Errorf("Validate(%+v) should fail", g)
+		}
+	}
+}
+
+func TestEgressConfig_Validate(t *testing.T) {
+	valid := []EgressConfig{
+		{},
+		{AllowHosts: []string{"example.com", "*.example.com", "10.0.0.0/8", "::1"}, DenyHosts: []string{"*"}},
+	}
+	for _, e := range valid {
+		if err := e.Validate(); err != nil {
+			t.Errorf("Validate(%+v) error = %v", e, err)
+		}
+	}
+	invalid := []EgressConfig{
+		{MaxResponseBytes: -1},
+		{AllowHosts: []string{""}},
+		{DenyHosts: []string{"https://example.com"}},
+		{DenyHosts: []string{"example.com:443"}},
+		{AllowHosts: []string{"10.0.0.0/33"}},
+		{AllowHosts: []string{"a.*.example.com"}},
+	}
+	for _, e := range invalid {
+		if err := e.Validate(); err == nil {
+			t.Errorf("Validate(%+v) should fail", e)
+		}
+	}
+}
+
+func TestHTTPRequestConfig_Validate(t *testing.T) {
+	cred := HTTPCredential{Value: "token", Hosts: []string{"ha.example.com"}}
+	valid := []HTTPRequestConfig{
+		{},
+		{Credentials: map[string]HTTPCredential{"home-assistant_1": cred}},
+	}
+	for _, h := range valid {
+		if err := h.Validate(); err != nil {
+			t.Errorf("Validate(%+v) error = %v", h, err)
+		}
+	}
+	invalid := []HTTPRequestConfig{
+		{TimeoutSeconds: -1},
+		{MaxResponseChars: -1},
+		{Credentials: map[string]HTTPCredential{"bad name": cred}},
+		{Credentials: map[string]HTTPCredential{"ha": {Hosts: []string{"ha.example.com"}}}},
+		{Credentials: map[string]HTTPCredential{"ha": {Value: "token"}}},
+		{Credentials: map[string]HTTPCredential{"ha": {Value: "token", Hosts: []string{"https://ha.example.com"}}}},
+	}
+	for _, h := range invalid {
+		if err := h.Validate(); err == nil {
+			t.Errorf("Validate(%+v) should fail", h)
+		}
+	}
+}
+
+func TestMCPServerConfig_Validate(t *testing.T) {
+	valid := []MCPServerConfig{
+		{Name: "fs", Command: "mcp-fs"},
+		{Name: "fs", Type: "stdio", Command: "mcp-fs"},
+		{Name: "remote", Type: "http", URL: "http://localhost:8080/mcp"},
+	}
+	for _, c := range valid {
+		if err := c.Validate(); err != nil {
+			t.Errorf("Validate(%+v) error = %v", c, err)
+		}
+	}
+	invalid := []MCPServerConfig{
+		{Command: "mcp-fs"},
+		{Name: "fs"},
+		{Name: "remote", Type: "http"},
+		{Name: "ws", Type: "websocket", URL: "ws://localhost"},
+	}
+	for _, c := range invalid {
+		if err := c.Validate(); err == nil {
+			t.Errorf("Validate(%+v) should fail", c)
+		}
+	}
+}
+
+func TestLoadConfig_MCPDuplicateServerNames(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.json")
+	configJSON := `{"mcp":{"enabled":true,"servers":[
+  {"name":"fs","command":"mcp-fs"},
+  {"name":"fs","type":"http","url":"http://localhost/mcp"}
+]}}`
+	if err := os.WriteFile(configPath, []byte(configJSON), 0o600); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	if _, err := LoadConfig(configPath); err == nil || !strings.Contains(err.Error(), "duplicate") {
+		t.Fatalf("LoadConfig() error = %v, want duplicate server name error", err)
+	}
+}
@@ -17,6 +17,7 @@ func DefaultConfig() *Config {
 				MaxTokens:           32768,
 				Temperature:         nil, // nil means use provider default
 				MaxToolIterations:   50,
+				RateLimitMaxWait:    30,
 			},
 		},
 		Bindings: []AgentBinding{},
@@ -268,7 +269,7 @@ func DefaultConfig() *Config {
 			{
 				ModelName: "llama3",
 				Model:     "ollama/llama3",
-				APIBase:   "http://localhost:11434/v1",
+				APIBase:   "http://localhost:11434",
 				APIKey:    "ollama",
 			},
 
@@ -320,6 +321,37 @@ func DefaultConfig() *Config {
 			},
 			Exec: ExecConfig{
 				EnableDenyPatterns: true,
+				MaxProcesses:       8,
+				ProcessOutputBytes: 65536,
+				MaxOutputBytes:     8192,
+				MaxSpillBytes:      16777216,
+			},
+			Egress: EgressConfig{
+				BlockPrivateNetworks: true,
+				MaxResponseBytes:     10485760,
+			},
+			SendFile: SendFileConfig{
+				Enabled:  true,
+				MaxBytes: 20971520,
+				ChannelMaxBytes: map[string]int64{
+					"telegram":  52428800,
+					"discord":   10485760,
+					"slack":     1073741824,
+					"line":      10485760,
+					"onebot":    104857600,
+					"wecom_app": 20971520,
+				},
+			},
+			HTTPRequest: HTTPRequestConfig{
+				Enabled:          false,
+				TimeoutSeconds:   30,
+				MaxResponseChars: 50000,
+			},
+			Journal: JournalConfig{
+				Enabled:      true,
+				MaxEntries:   200,
+				MaxBytes:     67108864,
+				MaxFileBytes: 4194304,
 			},
 			Skills: SkillsToolsConfig{
 				Registries: SkillsRegistriesConfig{
@@ -339,6 +371,14 @@ func DefaultConfig() *Config {
 			Enabled:  true,
 			Interval: 30,
 		},
+		ResponseCache: ResponseCacheConfig{
+			Enabled:           false,
+			TTL:               3600,
+			MaxEntries:        256,
+			MaxBytes:          4 << 20,
+			Persist:           true,
+			IgnoreCurrentTime: false,
+		},
 		Devices: DevicesConfig{
 			Enabled:    false,
 			MonitorUSB: true,
@@ -277,6 +277,43 @@ func TestModelConfig_Validate(t *testing.T) {
 			config:  ModelConfig{},
 			wantErr: true,
 		},
+		{
+			name: "prompted tool mode",
+			config: ModelConfig{
+				ModelName: "test",
+				Model:     "vllm/phi-3-mini",
+				ToolMode:  "prompted",
+			},
+			wantErr: false,
+		},
+		{
+			name: "unknown tool mode",
+			config: ModelConfig{
+				ModelName: "test",
+				Model:     "openai/gpt-4o",
+				ToolMode:  "xml",
+			},
+			wantErr: true,
+		},
+		{
+			name: "key pool with rotation",
+			config: ModelConfig{
+				ModelName:   "test",
+				Model:       "openai/gpt-4o",
+				APIKeys:     []string{"k1", "k2"},
+				KeyRotation: "least_used",
+			},
+			wantErr: false,
+		},
+		{
+			name: "unknown key rotation",
+			config: ModelConfig{
+				ModelName:   "test",
+				Model:       "openai/gpt-4o",
+				KeyRotation: "random",
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,373 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+// Package egress enforces the network egress policy shared by the tools
+// that make HTTP requests on the agent's behalf (web_fetch, http_request,
+// skill downloads). Host names are checked against allow and deny lists before a
+// request is sent, and the addresses a name resolves to are checked again
+// when the connection is dialed, so DNS rebinding and redirects cannot
+// reach private, link-local or cloud metadata addresses. Response bodies
+// are read up to a size limit.
+package egress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// DefaultMaxResponseBytes is the response size limit used when none is
+// configured.
+const DefaultMaxResponseBytes = 10 << 20
+
+// ErrBlocked is wrapped by the errors returned for requests the policy
+// refuses.
+var ErrBlocked = errors.New("blocked by egress policy")
+
+// blockedPrefixes are the non-public ranges refused when private networks
+// are blocked, in addition to those reported by netip.Addr methods.
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("::/96"),        // IPv4-compatible, embeds an IPv4 address
+	netip.MustParsePrefix("64:ff9b::/96"), // NAT64 can reach any IPv4 address
+	netip.MustParsePrefix("2001::/32"),    // Teredo embeds an IPv4 address
+	netip.MustParsePrefix("2002::/16"),    // 6to4 embeds an IPv4 address
+	netip.MustParsePrefix("fec0::/10"),    // deprecated site-local
+}
+
+// internalNames resolve to private addresses by convention; they are
+// refused by name so proxied requests cannot reach them either.
+var internalNames = []string{"localhost", "metadata.google.internal"}
+
+// Policy decides which hosts may be reached. A nil *Policy allows every
+// host and only applies the default response size limit.
+type Policy struct {
+	blockPrivate bool
+	allow        []rule
+	deny         []rule
+	maxBytes     int64
+
+	// lookup resolves host names; tests replace it.
+	lookup func(ctx context.Context, host string) ([]netip.Addr, error)
+}
+
+// rule is one allow_hosts or deny_hosts entry.
+type rule struct {
+	name   string       // exact host name, or the parent domain when wildcard
+	wild   bool         // "*.name" matches subdomains of name
+	all    bool         // "*" matches every host
+	prefix netip.Prefix // IP address or CIDR entry
+}
+
+// New builds a policy from cfg. Invalid host entries are skipped; LoadConfig
+// rejects them before this is reached.
+func New(cfg config.EgressConfig) *Policy {
+	p := &Policy{
+		blockPrivate: cfg.BlockPrivateNetworks,
+		allow:        parseRules(cfg.AllowHosts),
+		deny:         parseRules(cfg.DenyHosts),
+		maxBytes:     cfg.MaxResponseBytes,
+		lookup: func(ctx context.Context, host string) ([]netip.Addr, error) {
+			return net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+		},
+	}
+	if p.maxBytes <= 0 {
+		p.maxBytes = DefaultMaxResponseBytes
+	}
+	return p
+}
+
+func parseRules(entries []string) []rule {
+	rules := make([]rule, 0, len(entries))
+	for _, e := range entries {
+		e = normalizeHost(e)
+		switch {
+		case e == "":
+		case e == "*":
+			rules = append(rules, rule{all: true})
+		case strings.HasPrefix(e, "*."):
+			rules = append(rules, rule{name: e[2:], wild: true})
+		default:
+			if prefix, err := netip.ParsePrefix(e); err == nil {
+				rules = append(rules, rule{prefix: prefix.Masked()})
+			} else if addr, err := netip.ParseAddr(e); err == nil {
+				addr = addr.Unmap()
+				rules = append(rules, rule{prefix: netip.PrefixFrom(addr, addr.BitLen())})
+			} else {
+				rules = append(rules, rule{name: e})
+			}
+		}
+	}
+	return rules
+}
+
+func normalizeHost(host string) string {
+	host = strings.TrimSpace(strings.ToLower(host))
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	return strings.TrimSuffix(host, ".")
+}
+
+func (r rule) matchName(host string) bool {
+	switch {
+	case r.all:
+		return true
+	case r.name == "":
+		return false
+	case r.wild:
+		return strings.HasSuffix(host, "."+r.name)
+	default:
+		return host == r.name
+	}
+}
+
+func (r rule) matchAddr(addr netip.Addr) bool {
+	return r.all || r.prefix.IsValid() && r.prefix.Contains(addr)
+}
+
+func matchName(rules []rule, host string) bool {
+	for _, r := range rules {
+		if r.matchName(host) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAddr(rules []rule, addr netip.Addr) bool {
+	for _, r := range rules {
+		if r.matchAddr(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchHost reports whether host matches one of patterns, which use the
+// allow_hosts syntax.
+func MatchHost(patterns []string, host string) bool {
+	rules := parseRules(patterns)
+	host = normalizeHost(host)
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return matchAddr(rules, addr.Unmap())
+	}
+	return matchName(rules, host)
+}
+
+// CheckHost reports whether host may be reached by name. Addresses a name
+// resolves to are checked separately when the connection is dialed.
+func (p *Policy) CheckHost(host string) error {
+	_, err := p.checkHost(host)
+	return err
+}
+
+// checkHost reports whether host is allowed outright (it is in the allow
+// list) and an error when it is refused.
+func (p *Policy) checkHost(host string) (bool, error) {
+	if p == nil {
+		return true, nil
+	}
+	host = normalizeHost(host)
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return p.checkAddr(host, addr.Unmap())
+	}
+	if matchName(p.allow, host) {
+		return true, nil
+	}
+	if matchName(p.deny, host) {
+		return false, blocked(host, "host is denied")
+	}
+	if p.blockPrivate {
+		for _, name := range internalNames {
+			if host == name || strings.HasSuffix(host, "."+name) {
+				return false, blocked(host, "internal host name")
+			}
+		}
+	}
+	return false, nil
+}
+
+// checkAddr checks one address that host names or resolved to.
+func (p *Policy) checkAddr(host string, addr netip.Addr) (bool, error) {
+	if matchAddr(p.allow, addr) {
+		return true, nil
+	}
+	if matchAddr(p.deny, addr) {
+		return false, blocked(host, fmt.Sprintf("address %s is denied", addr))
+	}
+	if p.blockPrivate && isPrivate(addr) {
+		return false, blocked(host, fmt.Sprintf("address %s is not public", addr))
+	}
+	return false, nil
+}
+
+// isPrivate reports whether addr is loopback, private, link-local (which
+// includes the 169.254.169.254 metadata service), multicast or otherwise
+// not a public unicast address.
+func isPrivate(addr netip.Addr) bool {
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() || addr.IsUnspecified() {
+		return true
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func blocked(host, reason string) error {
+	return fmt.Errorf("%w: %s (%s)", ErrBlocked, host, reason)
+}
+
+// Transport returns a round tripper that enforces the policy on top of a
+// copy of base. Requests are checked by host name before they are sent, and
+// connections are only dialed to addresses the policy allows; the resolved
+// address is dialed directly so a second lookup cannot return a different
+// one. Connections to the proxy chosen by base.Proxy are not checked; the
+// proxy resolves the target itself, so only the name check applies then.
+func (p *Policy) Transport(base *http.Transport) http.RoundTripper {
+	if p == nil {
+		return base
+	}
+	t := base.Clone()
+	pt := &policyTransport{policy: p, base: t, proxies: make(map[string]bool)}
+
+	if proxy := t.Proxy; proxy != nil {
+		t.Proxy = func(req *http.Request) (*url.URL, error) {
+			u, err := proxy(req)
+			if u != nil {
+				pt.addProxy(u)
+			}
+			return u, err
+		}
+	}
+	dial := t.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+	}
+	t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return pt.dial(ctx, dial, network, addr)
+	}
+	return pt
+}
+
+type policyTransport struct {
+	policy *Policy
+	base   *http.Transport
+
+	mu      sync.Mutex
+	proxies map[string]bool // host:port of proxies in use
+}
+
+func (t *policyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.policy.CheckHost(req.URL.Hostname()); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
+
+func (t *policyTransport) addProxy(u *url.URL) {
+	port := u.Port()
+	if port == "" {
+		switch u.Scheme {
+		case "https":
+			port = "443"
+		case "socks5", "socks5h":
+			port = "1080"
+		default:
+			port = "80"
+		}
+	}
+	t.mu.Lock()
+	t.proxies[net.JoinHostPort(normalizeHost(u.Hostname()), port)] = true
+	t.mu.Unlock()
+}
+
+func (t *policyTransport) isProxy(host, port string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.proxies[net.JoinHostPort(normalizeHost(host), port)]
+}
+
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// dial connects to the first address of addr the policy allows.
+func (t *policyTransport) dial(ctx context.Context, dial dialFunc, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if t.isProxy(host, port) {
+		return dial(ctx, network, addr)
+	}
+
+	p := t.policy
+	allowed, err := p.checkHost(host)
+	if err != nil {
+		return nil, err
+	}
+	var addrs []netip.Addr
+	if ip, err := netip.ParseAddr(normalizeHost(host)); err == nil {
+		addrs = []netip.Addr{ip}
+	} else if addrs, err = p.lookup(ctx, host); err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for _, ip := range addrs {
+		ip = ip.Unmap()
+		if !allowed {
+			if _, err := p.checkAddr(host, ip); err != nil {
+				lastErr = err
+				continue
+			}
+		}
+		conn, err := dial(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no addresses for %s", host)
+	}
+	return nil, lastErr
+}
+
+// MaxResponseBytes returns the response size limit.
+func (p *Policy) MaxResponseBytes() int64 {
+	if p == nil {
+		return DefaultMaxResponseBytes
+	}
+	return p.maxBytes
+}
+
+// ReadBody reads r up to the response size limit. truncated reports whether
+// r held more than that.
+func (p *Policy) ReadBody(r io.Reader) (body []byte, truncated bool, err error) {
+	limit := p.MaxResponseBytes()
+	body, err = io.ReadAll(io.LimitReader(r, limit+1))
+	if int64(len(body)) > limit {
+		return body[:limit], true, err
+	}
+	return body, false, err
+}
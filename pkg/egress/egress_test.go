@@ -0,0 +1,191 @@
+package egress
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func newClient(p *Policy) *http.Client {
+	return &http.Client{Transport: p.Transport(http.DefaultTransport.(*http.Transport))}
+}
+
+func get(t *testing.T, c *http.Client, rawURL string) error {
+	t.Helper()
+	resp, err := c.Get(rawURL)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func TestPolicy_CheckHost(t *testing.T) {
+	p := New(config.EgressConfig{
+		BlockPrivateNetworks: true,
+		AllowHosts:           []string{"intranet.example.com", "10.1.0.0/16"},
+		DenyHosts:            []string{"*.evil.test", "203.0.113.7"},
+	})
+	tests := []struct {
+		host    string
+		blocked bool
+	}{
+		{"example.com", false},
+		{"93.184.216.34", false},
+		{"127.0.0.1", true},
+		{"169.254.169.254", true},
+		{"[::1]", true},
+		{"::ffff:10.0.0.1", true},
+		{"100.64.1.1", true},
+		{"fec0::1", true},
+		{"2002:a00:1::1", true},
+		{"64:ff9b::a00:1", true},
+		{"::a00:1", true},
+		{"::7f00:1", true},
+		{"2001:0:4136:e378:8000:63bf:f5ff:fffe", true},
+		{"2001:4860:4860::8888", false},
+		{"2606:4700::1111", false},
+		{"0.0.0.0", true},
+		{"localhost", true},
+		{"Metadata.Google.Internal.", true},
+		{"a.evil.test", true},
+		{"evil.test", false},
+		{"203.0.113.7", true},
+		{"10.1.2.3", false},
+		{"10.2.0.1", true},
+		{"intranet.example.com", false},
+	}
+	for _, tt := range tests {
+		err := p.CheckHost(tt.host)
+		if got := err != nil; got != tt.blocked {
+			t.Errorf("CheckHost(%q) = %v, want blocked=%v", tt.host, err, tt.blocked)
+		}
+		if err != nil && !errors.Is(err, ErrBlocked) {
+			t.Errorf("CheckHost(%q) error %v does not wrap ErrBlocked", tt.host, err)
+		}
+	}
+
+	var nilPolicy *Policy
+	if err := nilPolicy.CheckHost("127.0.0.1"); err != nil {
+		t.Errorf("nil policy should allow every host, got %v", err)
+	}
+}
+
+func TestPolicy_DenyAll(t *testing.T) {
+	p := New(config.EgressConfig{AllowHosts: []string{"*.example.com"}, DenyHosts: []string{"*"}})
+	if err := p.CheckHost("api.example.com"); err != nil {
+		t.Errorf("allowed host blocked: %v", err)
+	}
+	for _, host := range []string{"example.org", "93.184.216.34"} {
+		if err := p.CheckHost(host); err == nil {
+			t.Errorf("CheckHost(%q) should be denied", host)
+		}
+	}
+}
+
+func TestTransport_BlocksPrivateAndRedirects(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "http://169.254.169.254/latest/meta-data/", http.StatusFound)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	if err := get(t, newClient(New(config.EgressConfig{BlockPrivateNetworks: true})), srv.URL); !errors.Is(
+		err, ErrBlocked) {
+		t.Fatalf("request to loopback server: %v, want ErrBlocked", err)
+	}
+
+	allowed := newClient(New(config.EgressConfig{BlockPrivateNetworks: true, AllowHosts: []string{"127.0.0.1"}}))
+	if err := get(t, allowed, srv.URL); err != nil {
+		t.Fatalf("allowed host: %v", err)
+	}
+	if err := get(t, allowed, srv.URL+"/redirect"); !errors.Is(err, ErrBlocked) {
+		t.Fatalf("redirect to metadata service: %v, want ErrBlocked", err)
+	}
+}
+
+func TestTransport_ChecksResolvedAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+
+	// A public-looking name that resolves to loopback, as with DNS rebinding.
+	p := New(config.EgressConfig{BlockPrivateNetworks: true})
+	p.lookup = func(ctx context.Context, host string) ([]netip.Addr, error) {
+		return []netip.Addr{netip.MustParseAddr("127.0.0.1")}, nil
+	}
+	rebindURL := "http://rebind.example.com:" + u.Port() + "/"
+	if err := get(t, newClient(p), rebindURL); !errors.Is(err, ErrBlocked) {
+		t.Fatalf("rebinding name: %v, want ErrBlocked", err)
+	}
+
+	p.allow = parseRules([]string{"rebind.example.com"})
+	if err := get(t, newClient(p), rebindURL); err != nil {
+		t.Fatalf("allowed name: %v", err)
+	}
+}
+
+func TestTransport_ProxyIsNotChecked(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("proxied " + r.URL.Host))
+	}))
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	p := New(config.EgressConfig{BlockPrivateNetworks: true})
+	c := &http.Client{Transport: p.Transport(&http.Transport{Proxy: http.ProxyURL(proxyURL)})}
+	if err := get(t, c, "http://example.com/"); err != nil {
+		t.Fatalf("request through proxy: %v", err)
+	}
+	if err := get(t, c, "http://169.254.169.254/"); !errors.Is(err, ErrBlocked) {
+		t.Fatalf("proxied request to metadata service: %v, want ErrBlocked", err)
+	}
+}
+
+func TestPolicy_ReadBody(t *testing.T) {
+	p := New(config.EgressConfig{MaxResponseBytes: 4})
+	body, truncated, err := p.ReadBody(strings.NewReader("abcdef"))
+	if err != nil || string(body) != "abcd" || !truncated {
+		t.Errorf("ReadBody = %q, %v, %v", body, truncated, err)
+	}
+	body, truncated, err = p.ReadBody(strings.NewReader("abcd"))
+	if err != nil || string(body) != "abcd" || truncated {
+		t.Errorf("ReadBody at the limit = %q, %v, %v", body, truncated, err)
+	}
+
+	var nilPolicy *Policy
+	body, truncated, err = nilPolicy.ReadBody(io.LimitReader(strings.NewReader("x"), 1))
+	if err != nil || string(body) != "x" || truncated {
+		t.Errorf("nil policy ReadBody = %q, %v, %v", body, truncated, err)
+	}
+}
+
+func TestMatchHost(t *testing.T) {
+	patterns := []string{"ha.example.com", "*.grafana.test", "10.0.0.0/8"}
+	for host, want := range map[string]bool{
+		"HA.example.com.":   true,
+		"example.com":       false,
+		"eu.grafana.test":   true,
+		"grafana.test":      false,
+		"10.1.2.3":          true,
+		"[::ffff:10.0.0.1]": true,
+		"11.0.0.1":          false,
+	} {
+		if got := MatchHost(patterns, host); got != want {
+			t.Errorf("MatchHost(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
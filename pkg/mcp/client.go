@@ -0,0 +1,209 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// ClientVersion is reported to servers in clientInfo; set by the CLI at startup.
+var ClientVersion = "dev"
+
+// defaultRequestTimeout applies when a server config sets no timeout.
+const defaultRequestTimeout = 60 * time.Second
+
+// Client is a connection to one initialized MCP server.
+type Client struct {
+	name    string
+	t       transport
+	timeout time.Duration
+	nextID  atomic.Int64
+	info    InitializeResult
+}
+
+// Connect starts or dials the server described by cfg and performs the
+// initialize handshake.
+func Connect(ctx context.Context, cfg config.MCPServerConfig) (*Client, error) {
+	t, err := newTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := defaultRequestTimeout
+	if cfg.Timeout > 0 {
+		timeout = time.Duration(cfg.Timeout) * time.Second
+	}
+	c := &Client{name: cfg.Name, t: t, timeout: timeout}
+	if err := c.initialize(ctx); err != nil {
+		t.close()
+		return nil, fmt.Errorf("initializing %s: %w", cfg.Name, err)
+	}
+	return c, nil
+}
+
+func (c *Client) initialize(ctx context.Context) error {
+	params := map[string]any{
+		"protocolVersion": ProtocolVersion,
+		"capabilities":    map[string]any{},
+		"clientInfo":      Implementation{Name: "picoclaw", Version: ClientVersion},
+	}
+	if err := c.call(ctx, "initialize", params, &c.info); err != nil {
+		return err
+	}
+	c.t.setProtocolVersion(c.info.ProtocolVersion)
+
+	notif := &message{JSONRPC: "2.0", Method: "notifications/initialized"}
+	return c.t.notify(ctx, notif)
+}
+
+// Name returns the configured server name.
+func (c *Client) Name() string {
+	return c.name
+}
+
+// ServerInfo returns the result of the initialize handshake.
+func (c *Client) ServerInfo() InitializeResult {
+	return c.info
+}
+
+// ListTools returns every tool offered by the server, following pagination.
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	var all []Tool
+	err := c.paginate(ctx, "tools/list", func(raw json.RawMessage) (string, error) {
+		var page struct {
+			Tools      []Tool `json:"tools"`
+			NextCursor string `json:"nextCursor"`
+		}
+		err := json.Unmarshal(raw, &page)
+		all = append(all, page.Tools...)
+		return page.NextCursor, err
+	})
+	return all, err
+}
+
+// CallTool invokes a tool with the given arguments.
+func (c *Client) CallTool(ctx context.Context, name string, args map[string]any) (*CallToolResult, error) {
+	if args == nil {
+		args = map[string]any{}
+	}
+	var result CallToolResult
+	if err := c.call(ctx, "tools/call", map[string]any{"name": name, "arguments": args}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ListResources returns every resource offered by the server.
+func (c *Client) ListResources(ctx context.Context) ([]Resource, error) {
+	var all []Resource
+	err := c.paginate(ctx, "resources/list", func(raw json.RawMessage) (string, error) {
+		var page struct {
+			Resources  []Resource `json:"resources"`
+			NextCursor string     `json:"nextCursor"`
+		}
+		err := json.Unmarshal(raw, &page)
+		all = append(all, page.Resources...)
+		return page.NextCursor, err
+	})
+	return all, err
+}
+
+// ReadResource returns the contents of the resource at uri.
+func (c *Client) ReadResource(ctx context.Context, uri string) (*ReadResourceResult, error) {
+	var result ReadResourceResult
+	if err := c.call(ctx, "resources/read", map[string]any{"uri": uri}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ListPrompts returns every prompt template offered by the server.
+func (c *Client) ListPrompts(ctx context.Context) ([]Prompt, error) {
+	var all []Prompt
+	err := c.paginate(ctx, "prompts/list", func(raw json.RawMessage) (string, error) {
+		var page struct {
+			Prompts    []Prompt `json:"prompts"`
+			NextCursor string   `json:"nextCursor"`
+		}
+		err := json.Unmarshal(raw, &page)
+		all = append(all, page.Prompts...)
+		return page.NextCursor, err
+	})
+	return all, err
+}
+
+// GetPrompt renders a prompt template with the given arguments.
+func (c *Client) GetPrompt(ctx context.Context, name string, args map[string]string) (*GetPromptResult, error) {
+	var result GetPromptResult
+	if err := c.call(ctx, "prompts/get", map[string]any{"name": name, "arguments": args}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Close shuts the connection down (and stops a stdio server process).
+func (c *Client) Close() error {
+	return c.t.close()
+}
+
+// paginate calls method repeatedly while the server returns a next cursor.
+func (c *Client) paginate(ctx context.Context, method string, page func(json.RawMessage) (string, error)) error {
+	cursor := ""
+	for range 100 {
+		params := map[string]any{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		var raw json.RawMessage
+		if err := c.call(ctx, method, params, &raw); err != nil {
+			return err
+		}
+		next, err := page(raw)
+		if err != nil {
+			return fmt.Errorf("decoding %s: %w", method, err)
+		}
+		if next == "" || next == cursor {
+			return nil
+		}
+		cursor = next
+	}
+	return fmt.Errorf("%s: too many pages", method)
+}
+
+func (c *Client) call(ctx context.Context, method string, params, result any) error {
+	rawParams, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	req := &message{
+		JSONRPC: "2.0",
+		ID:      json.RawMessage(strconv.FormatInt(c.nextID.Add(1), 10)),
+		Method:  method,
+		Params:  rawParams,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	resp, err := c.t.call(ctx, req)
+	if err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if result == nil || len(resp.Result) == 0 {
+		return nil
+	}
+	return json.Unmarshal(resp.Result, result)
+}
@@ -0,0 +1,104 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func testClientRoundTrip(t *testing.T, cfg config.MCPServerConfig) {
+	t.Helper()
+	ctx := context.Background()
+
+	c, err := Connect(ctx, cfg)
+	if err != nil {
+		t.Fatalf("Connect() error: %v", err)
+	}
+	defer c.Close()
+
+	if info := c.ServerInfo(); info.ServerInfo.Name != "fake" || info.Capabilities.Tools == nil {
+		t.Fatalf("ServerInfo() = %+v", info)
+	}
+
+	toolList, err := c.ListTools(ctx)
+	if err != nil {
+		t.Fatalf("ListTools() error: %v", err)
+	}
+	if len(toolList) != 3 || toolList[0].Name != "echo" || toolList[2].Name != "crash" {
+		t.Fatalf("ListTools() = %+v, want echo, fail and crash across two pages", toolList)
+	}
+
+	result, err := c.CallTool(ctx, "echo", map[string]any{"text": "hi"})
+	if err != nil {
+		t.Fatalf("CallTool(echo) error: %v", err)
+	}
+	if result.IsError || len(result.Content) != 1 || result.Content[0].Text != "hi" {
+		t.Errorf("CallTool(echo) = %+v", result)
+	}
+
+	_, err = c.CallTool(ctx, "missing", nil)
+	var rpcErr *RPCError
+	if !errors.As(err, &rpcErr) || rpcErr.Code != -32602 {
+		t.Errorf("CallTool(missing) error = %v, want RPC error -32602", err)
+	}
+
+	resources, err := c.ListResources(ctx)
+	if err != nil || len(resources) != 1 {
+		t.Fatalf("ListResources() = %+v, %v", resources, err)
+	}
+	contents, err := c.ReadResource(ctx, resources[0].URI)
+	if err != nil || contents.Contents[0].Text != "hello there" {
+		t.Errorf("ReadResource() = %+v, %v", contents, err)
+	}
+
+	prompts, err := c.ListPrompts(ctx)
+	if err != nil || len(prompts) != 1 || prompts[0].Name != "greet" {
+		t.Fatalf("ListPrompts() = %+v, %v", prompts, err)
+	}
+	prompt, err := c.GetPrompt(ctx, "greet", map[string]string{"who": "Ada"})
+	if err != nil || prompt.Messages[0].Content.Text != "Say hello to Ada" {
+		t.Errorf("GetPrompt() = %+v, %v", prompt, err)
+	}
+}
+
+func TestClient_Stdio(t *testing.T) {
+	testClientRoundTrip(t, stdioServerConfig(t, "fake"))
+}
+
+func TestClient_HTTP(t *testing.T) {
+	srv := newFakeHTTPServer(t, false)
+	testClientRoundTrip(t, config.MCPServerConfig{Name: "fake", Type: "http", URL: srv.URL})
+	if srv.deleted != 1 {
+		t.Errorf("session DELETE count = %d, want 1", srv.deleted)
+	}
+}
+
+func TestClient_HTTPEventStream(t *testing.T) {
+	srv := newFakeHTTPServer(t, true)
+	testClientRoundTrip(t, config.MCPServerConfig{Name: "fake", Type: "http", URL: srv.URL})
+}
+
+func TestClient_StdioServerExit(t *testing.T) {
+	ctx := context.Background()
+	c, err := Connect(ctx, stdioServerConfig(t, "fake"))
+	if err != nil {
+		t.Fatalf("Connect() error: %v", err)
+	}
+	defer c.Close()
+
+	if _, err := c.CallTool(ctx, "crash", nil); !errors.Is(err, errTransportClosed) {
+		t.Fatalf("CallTool(crash) error = %v, want errTransportClosed", err)
+	}
+	if _, err := c.ListTools(ctx); !errors.Is(err, errTransportClosed) {
+		t.Errorf("ListTools() after exit error = %v, want errTransportClosed", err)
+	}
+}
+
+func TestConnect_MissingCommand(t *testing.T) {
+	_, err := Connect(context.Background(), config.MCPServerConfig{Name: "x", Command: "/nonexistent/mcp-server"})
+	if err == nil {
+		t.Fatal("Connect() should fail for a missing command")
+	}
+}
@@ -0,0 +1,252 @@
+package mcp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// fakeServerEnv makes the test binary act as a stdio MCP server instead of
+// running the tests, so the stdio transport is exercised against a real
+// child process.
+const fakeServerEnv = "PICOCLAW_MCP_FAKE_SERVER"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(fakeServerEnv) == "1" {
+		serveStdio(os.Stdin, os.Stdout)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// stdioServerConfig returns a config that launches the fake server.
+func stdioServerConfig(t *testing.T, name string) config.MCPServerConfig {
+	t.Helper()
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatalf("os.Executable() error: %v", err)
+	}
+	return config.MCPServerConfig{
+		Name:    name,
+		Command: exe,
+		Env:     map[string]string{fakeServerEnv: "1"},
+		Timeout: 10,
+	}
+}
+
+func serveStdio(in io.Reader, out io.Writer) {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		var req message
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			continue
+		}
+		if req.Method == "tools/call" && toolCallName(&req) == "crash" {
+			os.Exit(3)
+		}
+		if resp := handleFake(&req); resp != nil {
+			data, _ := json.Marshal(resp)
+			fmt.Fprintf(out, "%s\n", data)
+		}
+	}
+}
+
+// fakeHTTPServer serves the fake MCP server over the streamable HTTP
+// transport. Sessions can be expired and the server taken down to test
+// reconnecting.
+type fakeHTTPServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	sessions map[string]bool
+	next     int
+	useSSE   bool
+	deleted  int
+	down     bool
+}
+
+func newFakeHTTPServer(t *testing.T, useSSE bool) *fakeHTTPServer {
+	t.Helper()
+	f := &fakeHTTPServer{sessions: make(map[string]bool), useSSE: useSSE}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeHTTPServer) expireSessions() {
+	f.mu.Lock()
+	f.sessions = make(map[string]bool)
+	f.mu.Unlock()
+}
+
+func (f *fakeHTTPServer) setDown(down bool) {
+	f.mu.Lock()
+	f.down = down
+	f.mu.Unlock()
+}
+
+func (f *fakeHTTPServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.down {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+		return
+	}
+
+	session := r.Header.Get("Mcp-Session-Id")
+	if r.Method == http.MethodDelete {
+		delete(f.sessions, session)
+		f.deleted++
+		return
+	}
+
+	var req message
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Method == "initialize" {
+		f.next++
+		session = fmt.Sprintf("session-%d", f.next)
+		f.sessions[session] = true
+		w.Header().Set("Mcp-Session-Id", session)
+	} else if !f.sessions[session] {
+		http.Error(w, "unknown session", http.StatusNotFound)
+		return
+	}
+
+	resp := handleFake(&req)
+	if resp == nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	data, _ := json.Marshal(resp)
+	if f.useSSE {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, "event: message\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/progress\"}\n\n")
+		fmt.Fprintf(w, "event: message\ndata: %s\n\n", data)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+func toolCallName(req *message) string {
+	var p struct {
+		Name string `json:"name"`
+	}
+	json.Unmarshal(req.Params, &p)
+	return p.Name
+}
+
+// handleFake answers one request of the fake server; notifications get no
+// response.
+func handleFake(req *message) *message {
+	if len(req.ID) == 0 {
+		return nil
+	}
+
+	var result any
+	switch req.Method {
+	case "initialize":
+		result = InitializeResult{
+			ProtocolVersion: ProtocolVersion,
+			Capabilities: ServerCapabilities{
+				Tools:     &Capability{},
+				Resources: &Capability{},
+				Prompts:   &Capability{},
+			},
+			ServerInfo: Implementation{Name: "fake", Version: "1.0"},
+		}
+	case "ping":
+		result = map[string]any{}
+	case "tools/list":
+		var p struct {
+			Cursor string `json:"cursor"`
+		}
+		json.Unmarshal(req.Params, &p)
+		// Two pages, to exercise pagination.
+		if p.Cursor == "" {
+			result = map[string]any{
+				"tools": []Tool{{
+					Name:        "echo",
+					Description: "Echo the text back",
+					InputSchema: map[string]any{
+						"type":       "object",
+						"properties": map[string]any{"text": map[string]any{"type": "string"}},
+						"required":   []string{"text"},
+					},
+				}},
+				"nextCursor": "page2",
+			}
+		} else {
+			result = map[string]any{
+				"tools": []Tool{
+					{Name: "fail", Description: "Always fails", InputSchema: map[string]any{"type": "object"}},
+					{Name: "crash", Title: "Exit the server", InputSchema: map[string]any{"type": "object"}},
+				},
+			}
+		}
+	case "tools/call":
+		var p struct {
+			Name      string         `json:"name"`
+			Arguments map[string]any `json:"arguments"`
+		}
+		json.Unmarshal(req.Params, &p)
+		switch p.Name {
+		case "echo":
+			result = CallToolResult{Content: []Content{{Type: "text", Text: fmt.Sprint(p.Arguments["text"])}}}
+		case "fail":
+			result = CallToolResult{Content: []Content{{Type: "text", Text: "something broke"}}, IsError: true}
+		default:
+			return fakeError(req, -32602, "unknown tool: "+p.Name)
+		}
+	case "resources/list":
+		result = map[string]any{"resources": []Resource{
+			{URI: "memo://greeting", Name: "greeting", Description: "A friendly greeting"},
+		}}
+	case "resources/read":
+		var p struct {
+			URI string `json:"uri"`
+		}
+		json.Unmarshal(req.Params, &p)
+		if p.URI != "memo://greeting" {
+			return fakeError(req, -32002, "resource not found")
+		}
+		result = ReadResourceResult{Contents: []ResourceContents{{URI: p.URI, MimeType: "text/plain", Text: "hello there"}}}
+	case "prompts/list":
+		result = map[string]any{"prompts": []Prompt{{
+			Name:        "greet",
+			Description: "Greet someone",
+			Arguments:   []PromptArgument{{Name: "who", Required: true}},
+		}}}
+	case "prompts/get":
+		var p struct {
+			Name      string            `json:"name"`
+			Arguments map[string]string `json:"arguments"`
+		}
+		json.Unmarshal(req.Params, &p)
+		result = GetPromptResult{Messages: []PromptMessage{{
+			Role:    "user",
+			Content: Content{Type: "text", Text: "Say hello to " + p.Arguments["who"]},
+		}}}
+	default:
+		return fakeError(req, codeMethodNotFound, "method not found: "+req.Method)
+	}
+
+	data, _ := json.Marshal(result)
+	return &message{JSONRPC: "2.0", ID: req.ID, Result: data}
+}
+
+func fakeError(req *message, code int, msg string) *message {
+	return &message{JSONRPC: "2.0", ID: req.ID, Error: &RPCError{Code: code, Message: msg}}
+}
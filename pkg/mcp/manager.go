@@ -0,0 +1,295 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+package mcp
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// Servers that fail to connect at startup are retried with exponential
+// backoff between retryInitial and retryMax.
+const (
+	retryInitial = 5 * time.Second
+	retryMax     = 5 * time.Minute
+)
+
+// Manager owns the connections to all configured MCP servers.
+type Manager struct {
+	servers []*server
+	started sync.WaitGroup
+	cancel  context.CancelFunc
+
+	retryInitial, retryMax time.Duration // tests shorten these
+}
+
+// server is one configured MCP server and what it offered at connect time.
+type server struct {
+	cfg     config.MCPServerConfig
+	onReady func(server string) // set by Start, called after every successful connect
+
+	mu        sync.Mutex
+	client    *Client
+	tools     []Tool
+	resources []Resource
+	prompts   []Prompt
+}
+
+// NewManager creates a manager for the enabled servers of cfg. Nothing is
+// started until Start is called.
+func NewManager(cfg config.MCPConfig) *Manager {
+	m := &Manager{retryInitial: retryInitial, retryMax: retryMax}
+	if !cfg.Enabled {
+		return m
+	}
+	for _, sc := range cfg.Servers {
+		if !sc.Disabled {
+			m.servers = append(m.servers, &server{cfg: sc})
+		}
+	}
+	return m
+}
+
+// Start connects to every server concurrently in the background and lists
+// its tools, resources and prompts. onReady, if not nil, is called with the
+// name of a server each time it has (re)connected; ServerToolsFor then
+// returns its current tools. Servers that fail to start are logged and
+// retried with backoff until Close. Use Wait to block until every server
+// has been tried once.
+func (m *Manager) Start(ctx context.Context, onReady func(server string)) {
+	ctx, m.cancel = context.WithCancel(ctx)
+	for _, s := range m.servers {
+		s.onReady = onReady
+		m.started.Add(1)
+		go func() {
+			err := s.connect(ctx)
+			m.started.Done()
+			if err != nil {
+				m.retry(ctx, s)
+			}
+		}()
+	}
+}
+
+// retry reconnects s with exponential backoff until it succeeds, ctx is
+// done or a tool call has connected it in the meantime.
+func (m *Manager) retry(ctx context.Context, s *server) {
+	backoff := m.retryInitial
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		s.mu.Lock()
+		connected := s.client != nil
+		s.mu.Unlock()
+		if connected || s.connect(ctx) == nil {
+			return
+		}
+		backoff = min(backoff*2, m.retryMax)
+	}
+}
+
+// connect (re)connects s, logs the outcome and calls its onReady hook.
+func (s *server) connect(ctx context.Context) error {
+	s.mu.Lock()
+	err := s.connectLocked(ctx)
+	counts := map[string]any{
+		"server":    s.cfg.Name,
+		"tools":     len(s.tools),
+		"resources": len(s.resources),
+		"prompts":   len(s.prompts),
+	}
+	s.mu.Unlock()
+	if err != nil {
+		logger.ErrorCF("mcp", "Failed to connect MCP server", map[string]any{
+			"server": s.cfg.Name,
+			"error":  err.Error(),
+		})
+		return err
+	}
+	logger.InfoCF("mcp", "MCP server connected", counts)
+	s.ready()
+	return nil
+}
+
+// ready tells the owner of the manager that the listings of s changed.
+// It must be called without s.mu held, as the hook reads them.
+func (s *server) ready() {
+	if s.onReady != nil {
+		s.onReady(s.cfg.Name)
+	}
+}
+
+// Wait blocks until every server started by Start has connected or failed,
+// or until ctx is done. It reports whether all servers were tried.
+func (m *Manager) Wait(ctx context.Context) bool {
+	done := make(chan struct{})
+	go func() {
+		m.started.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// ToolsFor returns the agent tools of every connected server that is
+// available to agentID: one per remote tool, plus read_resource and
+// get_prompt tools for servers that offer resources or prompts.
+func (m *Manager) ToolsFor(agentID string) []tools.Tool {
+	var out []tools.Tool
+	for _, s := range m.servers {
+		out = append(out, s.toolsFor(agentID)...)
+	}
+	return out
+}
+
+// ServerToolsFor returns the agent tools of the named server that are
+// available to agentID, as ToolsFor does for all servers.
+func (m *Manager) ServerToolsFor(name, agentID string) []tools.Tool {
+	for _, s := range m.servers {
+		if s.cfg.Name == name {
+			return s.toolsFor(agentID)
+		}
+	}
+	return nil
+}
+
+func (s *server) toolsFor(agentID string) []tools.Tool {
+	if len(s.cfg.Agents) > 0 && !slices.Contains(s.cfg.Agents, agentID) {
+		return nil
+	}
+
+	s.mu.Lock()
+	remote, resources, prompts := s.tools, s.resources, s.prompts
+	s.mu.Unlock()
+
+	var out []tools.Tool
+	for _, t := range remote {
+		out = append(out, newRemoteTool(s, t))
+	}
+	if len(resources) > 0 {
+		out = append(out, newResourceTool(s, resources))
+	}
+	if len(prompts) > 0 {
+		out = append(out, newPromptTool(s, prompts))
+	}
+	return out
+}
+
+// Close stops any connection still in progress and shuts every server
+// connection down.
+func (m *Manager) Close() {
+	if m.cancel != nil {
+		m.cancel()
+	}
+	for _, s := range m.servers {
+		s.mu.Lock()
+		if s.client != nil {
+			s.client.Close()
+			s.client = nil
+		}
+		s.mu.Unlock()
+	}
+}
+
+// connectLocked (re)connects the server and refreshes its listings.
+// Must be called with s.mu held.
+func (s *server) connectLocked(ctx context.Context) error {
+	if s.client != nil {
+		s.client.Close()
+		s.client = nil
+	}
+
+	c, err := Connect(ctx, s.cfg)
+	if err != nil {
+		return err
+	}
+
+	caps := c.ServerInfo().Capabilities
+	var listed struct {
+		tools     []Tool
+		resources []Resource
+		prompts   []Prompt
+	}
+	if caps.Tools != nil {
+		if listed.tools, err = c.ListTools(ctx); err != nil {
+			c.Close()
+			return err
+		}
+	}
+	if caps.Resources != nil {
+		if listed.resources, err = c.ListResources(ctx); err != nil {
+			logger.WarnCF("mcp", "Failed to list MCP resources", map[string]any{"server": s.cfg.Name, "error": err.Error()})
+		}
+	}
+	if caps.Prompts != nil {
+		if listed.prompts, err = c.ListPrompts(ctx); err != nil {
+			logger.WarnCF("mcp", "Failed to list MCP prompts", map[string]any{"server": s.cfg.Name, "error": err.Error()})
+		}
+	}
+
+	s.client = c
+	s.tools, s.resources, s.prompts = listed.tools, listed.resources, listed.prompts
+	return nil
+}
+
+// do runs fn with a connected client. If the server process died or the
+// HTTP session expired, it reconnects once and retries. Reconnecting
+// refreshes the listings, so the onReady hook is called again.
+func (s *server) do(ctx context.Context, fn func(*Client) error) error {
+	s.mu.Lock()
+	reconnected := false
+	if s.client == nil {
+		if err := s.connectLocked(ctx); err != nil {
+			s.mu.Unlock()
+			return err
+		}
+		reconnected = true
+	}
+	c := s.client
+	s.mu.Unlock()
+	if reconnected {
+		s.ready()
+	}
+
+	err := fn(c)
+	if !errors.Is(err, errTransportClosed) {
+		return err
+	}
+
+	logger.WarnCF("mcp", "MCP server connection lost, reconnecting", map[string]any{
+		"server": s.cfg.Name,
+		"error":  err.Error(),
+	})
+	s.mu.Lock()
+	reconnected = false
+	if s.client == c || s.client == nil {
+		if err := s.connectLocked(ctx); err != nil {
+			s.mu.Unlock()
+			return err
+		}
+		reconnected = true
+	}
+	c = s.client
+	s.mu.Unlock()
+	if reconnected {
+		s.ready()
+	}
+	return fn(c)
+}
@@ -0,0 +1,260 @@
+package mcp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+func toolsByName(list []tools.Tool) map[string]tools.Tool {
+	m := make(map[string]tools.Tool, len(list))
+	for _, t := range list {
+		m[t.Name()] = t
+	}
+	return m
+}
+
+func TestToolName(t *testing.T) {
+	tests := []struct {
+		server, tool, want string
+	}{
+		{"fs", "read_file", "mcp_fs_read_file"},
+		{"my server", "get.item", "mcp_my_server_get_item"},
+		{"git-hub", "list-prs", "mcp_git-hub_list-prs"},
+	}
+	for _, tt := range tests {
+		if got := ToolName(tt.server, tt.tool); got != tt.want {
+			t.Errorf("ToolName(%q, %q) = %q, want %q", tt.server, tt.tool, got, tt.want)
+		}
+	}
+	if got := ToolName("server", strings.Repeat("x", 100)); len(got) != maxToolNameLength {
+		t.Errorf("ToolName() length = %d, want %d", len(got), maxToolNameLength)
+	}
+}
+
+func TestManager_Disabled(t *testing.T) {
+	m := NewManager(config.MCPConfig{Servers: []config.MCPServerConfig{stdioServerConfig(t, "fake")}})
+	m.Start(context.Background(), nil)
+	m.Wait(context.Background())
+	defer m.Close()
+	if got := m.ToolsFor("main"); len(got) != 0 {
+		t.Errorf("ToolsFor() = %d tools, want none while MCP is disabled", len(got))
+	}
+}
+
+func TestManager_ToolsFor(t *testing.T) {
+	ctx := context.Background()
+	restricted := stdioServerConfig(t, "private")
+	restricted.Agents = []string{"coder"}
+	off := stdioServerConfig(t, "off")
+	off.Disabled = true
+
+	m := NewManager(config.MCPConfig{
+		Enabled: true,
+		Servers: []config.MCPServerConfig{stdioServerConfig(t, "fake"), restricted, off},
+	})
+	m.Start(ctx, nil)
+	m.Wait(ctx)
+	defer m.Close()
+
+	mainTools := toolsByName(m.ToolsFor("main"))
+	for _, name := range []string{
+		"mcp_fake_echo", "mcp_fake_fail", "mcp_fake_crash",
+		"mcp_fake_read_resource", "mcp_fake_get_prompt",
+	} {
+		if _, ok := mainTools[name]; !ok {
+			t.Errorf("ToolsFor(main) missing %s", name)
+		}
+	}
+	if len(mainTools) != 5 {
+		t.Errorf("ToolsFor(main) = %d tools, want 5 (private and disabled servers excluded)", len(mainTools))
+	}
+	if got := len(m.ToolsFor("coder")); got != 10 {
+		t.Errorf("ToolsFor(coder) = %d tools, want 10", got)
+	}
+
+	echo := mainTools["mcp_fake_echo"]
+	if !strings.HasPrefix(echo.Description(), "[MCP fake]") {
+		t.Errorf("Description() = %q", echo.Description())
+	}
+	if echo.Parameters()["type"] != "object" {
+		t.Errorf("Parameters() = %v", echo.Parameters())
+	}
+	if _, ok := mainTools["mcp_fake_fail"].Parameters()["properties"]; !ok {
+		t.Error("Parameters() should always contain properties")
+	}
+
+	if r := echo.Execute(ctx, map[string]any{"text": "ping"}); r.IsError || r.ForLLM != "ping" {
+		t.Errorf("echo result = %+v", r)
+	}
+	if r := mainTools["mcp_fake_fail"].Execute(ctx, nil); !r.IsError || r.ForLLM != "something broke" {
+		t.Errorf("fail result = %+v", r)
+	}
+
+	resource := mainTools["mcp_fake_read_resource"]
+	if !strings.Contains(resource.Description(), "memo://greeting") {
+		t.Errorf("read_resource description = %q", resource.Description())
+	}
+	if r := resource.Execute(ctx, map[string]any{"uri": "memo://greeting"}); r.IsError || r.ForLLM != "hello there" {
+		t.Errorf("read_resource result = %+v", r)
+	}
+	if r := resource.Execute(ctx, map[string]any{"uri": "memo://missing"}); !r.IsError {
+		t.Errorf("read_resource(missing) should fail, got %+v", r)
+	}
+
+	prompt := mainTools["mcp_fake_get_prompt"]
+	if !strings.Contains(prompt.Description(), "greet(who*)") {
+		t.Errorf("get_prompt description = %q", prompt.Description())
+	}
+	r := prompt.Execute(ctx, map[string]any{"name": "greet", "arguments": map[string]any{"who": "Ada"}})
+	if r.IsError || r.ForLLM != "[user]\nSay hello to Ada" {
+		t.Errorf("get_prompt result = %+v", r)
+	}
+}
+
+func TestManager_StartDoesNotWaitForSlowServers(t *testing.T) {
+	hang := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-hang:
+		case <-r.Context().Done():
+		}
+	}))
+	defer slow.Close()
+	defer close(hang)
+
+	m := NewManager(config.MCPConfig{
+		Enabled: true,
+		Servers: []config.MCPServerConfig{
+			stdioServerConfig(t, "fake"),
+			{Name: "slow", Type: "http", URL: slow.URL},
+		},
+	})
+	var mu sync.Mutex
+	var ready []string
+	m.Start(context.Background(), func(server string) {
+		mu.Lock()
+		defer mu.Unlock()
+		ready = append(ready, server)
+		if got := len(m.ServerToolsFor(server, "main")); got == 0 {
+			t.Errorf("ServerToolsFor(%s) = no tools once ready", server)
+		}
+	})
+	defer m.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if m.Wait(ctx) {
+		t.Fatal("Wait() = true, want the slow server still connecting")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(ready) != 1 || ready[0] != "fake" {
+		t.Errorf("ready = %v, want [fake]", ready)
+	}
+}
+
+func TestManager_ReconnectsAfterServerExit(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(config.MCPConfig{Enabled: true, Servers: []config.MCPServerConfig{stdioServerConfig(t, "fake")}})
+	m.Start(ctx, nil)
+	m.Wait(ctx)
+	defer m.Close()
+
+	byName := toolsByName(m.ToolsFor("main"))
+	// The crash kills the server; the retry after reconnecting crashes it
+	// again, so the call itself still fails.
+	if r := byName["mcp_fake_crash"].Execute(ctx, nil); !r.IsError {
+		t.Fatalf("crash result = %+v, want error", r)
+	}
+	if r := byName["mcp_fake_echo"].Execute(ctx, map[string]any{"text": "back"}); r.IsError || r.ForLLM != "back" {
+		t.Errorf("echo after crash = %+v, want reconnect", r)
+	}
+}
+
+func TestManager_ReconnectsAfterSessionExpiry(t *testing.T) {
+	ctx := context.Background()
+	srv := newFakeHTTPServer(t, false)
+	m := NewManager(config.MCPConfig{
+		Enabled: true,
+		Servers: []config.MCPServerConfig{{Name: "remote", Type: "http", URL: srv.URL}},
+	})
+	m.Start(ctx, nil)
+	m.Wait(ctx)
+	defer m.Close()
+
+	echo := toolsByName(m.ToolsFor("main"))["mcp_remote_echo"]
+	if echo == nil {
+		t.Fatal("mcp_remote_echo not mounted")
+	}
+	srv.expireSessions()
+	if r := echo.Execute(ctx, map[string]any{"text": "again"}); r.IsError || r.ForLLM != "again" {
+		t.Errorf("echo after session expiry = %+v, want reconnect", r)
+	}
+}
+
+func TestManager_RetriesFailedServers(t *testing.T) {
+	srv := newFakeHTTPServer(t, false)
+	srv.setDown(true)
+	m := NewManager(config.MCPConfig{
+		Enabled: true,
+		Servers: []config.MCPServerConfig{{Name: "remote", Type: "http", URL: srv.URL}},
+	})
+	m.retryInitial, m.retryMax = 10*time.Millisecond, 50*time.Millisecond
+	ready := make(chan string, 4)
+	m.Start(context.Background(), func(server string) { ready <- server })
+	defer m.Close()
+
+	if !m.Wait(context.Background()) {
+		t.Fatal("Wait() = false after the first attempt")
+	}
+	if got := m.ServerToolsFor("remote", "main"); len(got) != 0 {
+		t.Fatalf("ServerToolsFor() = %d tools while the server is down", len(got))
+	}
+
+	srv.setDown(false)
+	select {
+	case <-ready:
+	case <-time.After(5 * time.Second):
+		t.Fatal("onReady not called after the server came up")
+	}
+	if got := m.ServerToolsFor("remote", "main"); len(got) == 0 {
+		t.Error("ServerToolsFor() = no tools after retrying")
+	}
+}
+
+func TestManager_ReconnectCallsOnReady(t *testing.T) {
+	ctx := context.Background()
+	srv := newFakeHTTPServer(t, false)
+	m := NewManager(config.MCPConfig{
+		Enabled: true,
+		Servers: []config.MCPServerConfig{{Name: "remote", Type: "http", URL: srv.URL}},
+	})
+	var mu sync.Mutex
+	calls := 0
+	m.Start(ctx, func(string) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+	})
+	m.Wait(ctx)
+	defer m.Close()
+
+	srv.expireSessions()
+	echo := toolsByName(m.ToolsFor("main"))["mcp_remote_echo"]
+	if r := echo.Execute(ctx, map[string]any{"text": "again"}); r.IsError {
+		t.Fatalf("echo after session expiry = %+v", r)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if calls != 2 {
+		t.Errorf("onReady called %d times, want 2 (connect and reconnect)", calls)
+	}
+}
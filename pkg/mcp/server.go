@@ -0,0 +1,468 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// memoryScheme is the URI scheme of workspace memory resources, e.g.
+// memory://MEMORY.md or memory://202610/20261018.md.
+const memoryScheme = "memory://"
+
+const (
+	// sessionIdleTimeout is how long an HTTP session lives without requests.
+	sessionIdleTimeout = 30 * time.Minute
+	// maxSessions caps the live HTTP sessions; initializing another one
+	// ends the least recently used.
+	maxSessions = 64
+)
+
+// supportedVersions are the protocol revisions the server accepts from clients.
+var supportedVersions = []string{ProtocolVersion, "2025-03-26", "2024-11-05"}
+
+// ServerOptions configures a Server.
+type ServerOptions struct {
+	// Name and Version are reported in serverInfo.
+	Name    string
+	Version string
+	// Tools is the registry whose tools are exposed.
+	Tools *tools.ToolRegistry
+	// Exclude lists tool names that are not exposed.
+	Exclude []string
+	// Workspace, when set, exposes the Markdown files under
+	// <workspace>/memory as read-only resources.
+	Workspace string
+	// Token, when set, is required as a bearer token on HTTP requests.
+	Token string
+}
+
+// Server exposes a tool registry and a workspace's memory to MCP clients
+// over stdio or the streamable HTTP transport. Tools run through the
+// registry, so they keep the guards the agent configured on them.
+type Server struct {
+	opts ServerOptions
+
+	mu       sync.Mutex
+	sessions map[string]time.Time // HTTP session ID -> last request
+}
+
+// NewServer creates a server for opts.
+func NewServer(opts ServerOptions) *Server {
+	if opts.Name == "" {
+		opts.Name = "picoclaw"
+	}
+	return &Server{opts: opts, sessions: make(map[string]time.Time)}
+}
+
+// ServeStdio reads newline-delimited requests from in and writes responses to
+// out until in is closed or ctx is cancelled. Requests are handled
+// concurrently so a long tool call does not block pings.
+func (s *Server) ServeStdio(ctx context.Context, in io.Reader, out io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		writeMu sync.Mutex
+		wg      sync.WaitGroup
+	)
+	write := func(msg *message) {
+		data, err := json.Marshal(msg)
+		if err != nil {
+			return
+		}
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		out.Write(append(data, '\n'))
+	}
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var req message
+		if err := json.Unmarshal(line, &req); err != nil {
+			write(&message{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &RPCError{
+				Code:    codeParseError,
+				Message: "parse error: " + err.Error(),
+			}})
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if resp := s.handle(ctx, &req, "stdio"); resp != nil {
+				write(resp)
+			}
+		}()
+	}
+	wg.Wait()
+	return scanner.Err()
+}
+
+// ServeHTTP implements the streamable HTTP transport. Every response is a
+// single JSON message; the server never opens an SSE stream.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !allowedOrigin(r) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+
+	session := r.Header.Get("Mcp-Session-Id")
+	switch r.Method {
+	case http.MethodPost:
+	case http.MethodDelete:
+		s.mu.Lock()
+		delete(s.sessions, session)
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		w.Header().Set("Allow", "POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req message
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxMessageSize)).Decode(&req); err != nil {
+		writeJSON(w, &message{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &RPCError{
+			Code:    codeParseError,
+			Message: "parse error: " + err.Error(),
+		}})
+		return
+	}
+
+	if req.Method == "initialize" {
+		session = s.newSession()
+		w.Header().Set("Mcp-Session-Id", session)
+	} else if !s.touchSession(session) {
+		http.Error(w, "unknown session", http.StatusNotFound)
+		return
+	}
+
+	resp := s.handle(r.Context(), &req, session)
+	if resp == nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	writeJSON(w, resp)
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	if s.opts.Token == "" {
+		return true
+	}
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(got), []byte(s.opts.Token)) == 1
+}
+
+// allowedOrigin rejects browser requests from other sites, which could
+// otherwise reach a server bound to localhost (DNS rebinding).
+func allowedOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	host := u.Hostname()
+	return host == "localhost" || host == "127.0.0.1" || host == "::1" || u.Host == r.Host
+}
+
+func writeJSON(w http.ResponseWriter, msg *message) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(msg)
+}
+
+func newSessionID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// newSession starts an HTTP session, first dropping idle sessions and, at
+// maxSessions, the least recently used one.
+func (s *Server) newSession() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	oldest := ""
+	for id, last := range s.sessions {
+		if now.Sub(last) > sessionIdleTimeout {
+			delete(s.sessions, id)
+			continue
+		}
+		if oldest == "" || last.Before(s.sessions[oldest]) {
+			oldest = id
+		}
+	}
+	if len(s.sessions) >= maxSessions {
+		delete(s.sessions, oldest)
+	}
+
+	id := newSessionID()
+	s.sessions[id] = now
+	return id
+}
+
+// touchSession records a request on session and reports whether it is live.
+func (s *Server) touchSession(session string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	last, ok := s.sessions[session]
+	if !ok {
+		return false
+	}
+	now := time.Now()
+	if now.Sub(last) > sessionIdleTimeout {
+		delete(s.sessions, session)
+		return false
+	}
+	s.sessions[session] = now
+	return true
+}
+
+// handle answers one request; notifications get no response.
+func (s *Server) handle(ctx context.Context, req *message, session string) *message {
+	if len(req.ID) == 0 {
+		return nil
+	}
+
+	var (
+		result any
+		err    *RPCError
+	)
+	switch req.Method {
+	case "initialize":
+		result = s.initialize(req.Params)
+	case "ping":
+		result = map[string]any{}
+	case "tools/list":
+		result = map[string]any{"tools": s.listTools()}
+	case "tools/call":
+		result, err = s.callTool(ctx, req.Params, session)
+	case "resources/list":
+		var resources []Resource
+		resources, err = s.listResources()
+		result = map[string]any{"resources": resources}
+	case "resources/templates/list":
+		result = map[string]any{"resourceTemplates": []any{}}
+	case "resources/read":
+		result, err = s.readResource(req.Params)
+	case "prompts/list":
+		result = map[string]any{"prompts": []Prompt{}}
+	default:
+		err = &RPCError{Code: codeMethodNotFound, Message: "method not found: " + req.Method}
+	}
+
+	if err != nil {
+		return &message{JSONRPC: "2.0", ID: req.ID, Error: err}
+	}
+	data, marshalErr := json.Marshal(result)
+	if marshalErr != nil {
+		return &message{JSONRPC: "2.0", ID: req.ID, Error: &RPCError{Code: codeInternalError, Message: marshalErr.Error()}}
+	}
+	return &message{JSONRPC: "2.0", ID: req.ID, Result: data}
+}
+
+func (s *Server) initialize(params json.RawMessage) InitializeResult {
+	var p struct {
+		ProtocolVersion string         `json:"protocolVersion"`
+		ClientInfo      Implementation `json:"clientInfo"`
+	}
+	json.Unmarshal(params, &p)
+
+	version := ProtocolVersion
+	if slices.Contains(supportedVersions, p.ProtocolVersion) {
+		version = p.ProtocolVersion
+	}
+	logger.InfoCF("mcp", "MCP client connected", map[string]any{
+		"client":   p.ClientInfo.Name,
+		"version":  p.ClientInfo.Version,
+		"protocol": version,
+	})
+
+	caps := ServerCapabilities{Tools: &Capability{}}
+	if s.opts.Workspace != "" {
+		caps.Resources = &Capability{}
+	}
+	return InitializeResult{
+		ProtocolVersion: version,
+		Capabilities:    caps,
+		ServerInfo:      Implementation{Name: s.opts.Name, Version: s.opts.Version},
+	}
+}
+
+func (s *Server) exposed(name string) bool {
+	return !slices.Contains(s.opts.Exclude, name)
+}
+
+func (s *Server) listTools() []Tool {
+	out := []Tool{}
+	if s.opts.Tools == nil {
+		return out
+	}
+	for _, name := range s.opts.Tools.List() {
+		t, ok := s.opts.Tools.Get(name)
+		if !ok || !s.exposed(name) {
+			continue
+		}
+		out = append(out, Tool{Name: name, Description: t.Description(), InputSchema: t.Parameters()})
+	}
+	return out
+}
+
+func (s *Server) callTool(ctx context.Context, params json.RawMessage, session string) (*CallToolResult, *RPCError) {
+	var p struct {
+		Name      string         `json:"name"`
+		Arguments map[string]any `json:"arguments"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, &RPCError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	if s.opts.Tools == nil || !s.exposed(p.Name) {
+		return nil, &RPCError{Code: codeInvalidParams, Message: "unknown tool: " + p.Name}
+	}
+	if _, ok := s.opts.Tools.Get(p.Name); !ok {
+		return nil, &RPCError{Code: codeInvalidParams, Message: "unknown tool: " + p.Name}
+	}
+	if p.Arguments == nil {
+		p.Arguments = map[string]any{}
+	}
+
+	res := s.opts.Tools.ExecuteWithContext(ctx, p.Name, p.Arguments, "mcp", session, nil)
+	text := res.ForLLM
+	if text == "" && res.Err != nil {
+		text = res.Err.Error()
+	}
+	return &CallToolResult{Content: []Content{{Type: "text", Text: text}}, IsError: res.IsError}, nil
+}
+
+func (s *Server) memoryDir() string {
+	return filepath.Join(s.opts.Workspace, "memory")
+}
+
+// resolveMemoryPath resolves the symlinks in path and reports whether the
+// result is still below the memory directory, so that a link placed there
+// cannot expose other files.
+func (s *Server) resolveMemoryPath(path string) (string, bool) {
+	root, err := filepath.EvalSymlinks(s.memoryDir())
+	if err != nil {
+		return "", false
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return resolved, true
+}
+
+// listResources returns the Markdown files of the workspace memory.
+func (s *Server) listResources() ([]Resource, *RPCError) {
+	out := []Resource{}
+	if s.opts.Workspace == "" {
+		return out, nil
+	}
+	root := s.memoryDir()
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root && os.IsNotExist(err) {
+				return fs.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(d.Name(), ".md") {
+			return nil
+		}
+		if _, ok := s.resolveMemoryPath(path); !ok {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		desc := "Daily note"
+		if rel == "MEMORY.md" {
+			desc = "Long-term memory"
+		}
+		out = append(out, Resource{URI: memoryScheme + rel, Name: rel, Description: desc, MimeType: "text/markdown"})
+		return nil
+	})
+	if err != nil {
+		return nil, &RPCError{Code: codeInternalError, Message: fmt.Sprintf("listing memory: %v", err)}
+	}
+	return out, nil
+}
+
+func (s *Server) readResource(params json.RawMessage) (*ReadResourceResult, *RPCError) {
+	var p struct {
+		URI string `json:"uri"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, &RPCError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	notFound := &RPCError{Code: codeResourceAbsent, Message: "resource not found: " + p.URI}
+
+	rel, ok := strings.CutPrefix(p.URI, memoryScheme)
+	if s.opts.Workspace == "" || !ok || !strings.HasSuffix(rel, ".md") {
+		return nil, notFound
+	}
+	// Only files below the memory directory are readable.
+	clean := filepath.Clean(filepath.FromSlash(rel))
+	if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return nil, notFound
+	}
+	path, ok := s.resolveMemoryPath(filepath.Join(s.memoryDir(), clean))
+	if !ok {
+		return nil, notFound
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, notFound
+	}
+	return &ReadResourceResult{Contents: []ResourceContents{{
+		URI:      p.URI,
+		MimeType: "text/markdown",
+		Text:     string(data),
+	}}}, nil
+}
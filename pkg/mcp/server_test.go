@@ -0,0 +1,252 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// newTestServer serves a workspace-restricted read_file tool, a message tool
+// that should stay hidden, and two memory files.
+func newTestServer(t *testing.T, token string) (*Server, string) {
+	t.Helper()
+	workspace := t.TempDir()
+	memDir := filepath.Join(workspace, "memory", "202610")
+	if err := os.MkdirAll(memDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(workspace, "memory", "MEMORY.md"), []byte("likes tea"), 0o644)
+	os.WriteFile(filepath.Join(memDir, "20261018.md"), []byte("fixed the board"), 0o644)
+	os.WriteFile(filepath.Join(workspace, "notes.txt"), []byte("inside"), 0o644)
+
+	registry := tools.NewToolRegistry()
+	registry.Register(tools.NewReadFileTool(workspace, true))
+	registry.Register(tools.NewMessageTool())
+
+	return NewServer(ServerOptions{
+		Version:   "test",
+		Tools:     registry,
+		Exclude:   []string{"message"},
+		Workspace: workspace,
+		Token:     token,
+	}), workspace
+}
+
+func TestServer_HTTPWithClient(t *testing.T) {
+	srv, workspace := newTestServer(t, "secret")
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	ctx := context.Background()
+
+	c, err := Connect(ctx, config.MCPServerConfig{
+		Name:    "pico",
+		Type:    "http",
+		URL:     ts.URL,
+		Headers: map[string]string{"Authorization": "Bearer secret"},
+	})
+	if err != nil {
+		t.Fatalf("Connect() error: %v", err)
+	}
+	defer c.Close()
+
+	info := c.ServerInfo()
+	if info.ServerInfo.Name != "picoclaw" || info.Capabilities.Tools == nil || info.Capabilities.Resources == nil {
+		t.Fatalf("ServerInfo() = %+v", info)
+	}
+
+	list, err := c.ListTools(ctx)
+	if err != nil {
+		t.Fatalf("ListTools() error: %v", err)
+	}
+	if len(list) != 1 || list[0].Name != "read_file" || list[0].InputSchema["type"] != "object" {
+		t.Fatalf("ListTools() = %+v, want only read_file", list)
+	}
+
+	result, err := c.CallTool(ctx, "read_file", map[string]any{"path": "notes.txt"})
+	if err != nil || result.IsError || !strings.Contains(result.Content[0].Text, "inside") {
+		t.Errorf("CallTool(read_file) = %+v, %v", result, err)
+	}
+
+	// The workspace restriction of the tool still applies.
+	outside := filepath.Join(filepath.Dir(workspace), "elsewhere.txt")
+	result, err = c.CallTool(ctx, "read_file", map[string]any{"path": outside})
+	if err != nil || !result.IsError {
+		t.Errorf("CallTool(read_file outside workspace) = %+v, %v, want tool error", result, err)
+	}
+
+	if _, err := c.CallTool(ctx, "message", map[string]any{"content": "hi"}); err == nil {
+		t.Error("CallTool(message) should fail for an excluded tool")
+	}
+
+	resources, err := c.ListResources(ctx)
+	if err != nil {
+		t.Fatalf("ListResources() error: %v", err)
+	}
+	uris := make([]string, 0, len(resources))
+	for _, r := range resources {
+		uris = append(uris, r.URI)
+	}
+	if strings.Join(uris, ",") != "memory://202610/20261018.md,memory://MEMORY.md" {
+		t.Errorf("ListResources() URIs = %v", uris)
+	}
+
+	contents, err := c.ReadResource(ctx, "memory://MEMORY.md")
+	if err != nil || contents.Contents[0].Text != "likes tea" {
+		t.Errorf("ReadResource() = %+v, %v", contents, err)
+	}
+	for _, uri := range []string{"memory://../notes.txt", "memory://missing.md", "file:///etc/passwd"} {
+		if _, err := c.ReadResource(ctx, uri); err == nil {
+			t.Errorf("ReadResource(%q) should fail", uri)
+		}
+	}
+}
+
+func TestServer_MemorySymlinks(t *testing.T) {
+	srv, workspace := newTestServer(t, "")
+	secret := filepath.Join(t.TempDir(), "secret.md")
+	os.WriteFile(secret, []byte("password"), 0o644)
+	memDir := filepath.Join(workspace, "memory")
+	if err := os.Symlink(secret, filepath.Join(memDir, "leak.md")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	os.Symlink(filepath.Dir(secret), filepath.Join(memDir, "outside"))
+	os.Symlink("MEMORY.md", filepath.Join(memDir, "alias.md"))
+
+	for _, uri := range []string{"memory://leak.md", "memory://outside/secret.md"} {
+		params, _ := json.Marshal(map[string]string{"uri": uri})
+		if res, rpcErr := srv.readResource(params); rpcErr == nil {
+			t.Errorf("readResource(%s) = %+v, want not found", uri, res)
+		}
+	}
+	params, _ := json.Marshal(map[string]string{"uri": "memory://alias.md"})
+	if res, rpcErr := srv.readResource(params); rpcErr != nil || res.Contents[0].Text != "likes tea" {
+		t.Errorf("readResource(alias.md) = %+v, %v", res, rpcErr)
+	}
+
+	resources, rpcErr := srv.listResources()
+	if rpcErr != nil {
+		t.Fatal(rpcErr)
+	}
+	for _, r := range resources {
+		if r.URI == "memory://leak.md" {
+			t.Error("listResources() lists a link pointing outside the memory directory")
+		}
+	}
+}
+
+func TestServer_HTTPRejectsBadRequests(t *testing.T) {
+	srv, _ := newTestServer(t, "secret")
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	post := func(header map[string]string) int {
+		body := strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"ping"}`)
+		req, _ := http.NewRequest(http.MethodPost, ts.URL, body)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if got := post(nil); got != http.StatusUnauthorized {
+		t.Errorf("without token: status %d, want 401", got)
+	}
+	auth := "Bearer secret"
+	if got := post(map[string]string{"Authorization": auth, "Origin": "https://evil.example"}); got != http.StatusForbidden {
+		t.Errorf("foreign origin: status %d, want 403", got)
+	}
+	if got := post(map[string]string{"Authorization": auth, "Mcp-Session-Id": "bogus"}); got != http.StatusNotFound {
+		t.Errorf("unknown session: status %d, want 404", got)
+	}
+}
+
+func TestServer_HTTPSessionsExpire(t *testing.T) {
+	srv, _ := newTestServer(t, "")
+
+	idle := srv.newSession()
+	srv.sessions[idle] = time.Now().Add(-sessionIdleTimeout - time.Minute)
+	if srv.touchSession(idle) {
+		t.Error("idle session should have expired")
+	}
+
+	first := srv.newSession()
+	srv.sessions[first] = time.Now().Add(-time.Minute)
+	for range maxSessions {
+		srv.newSession()
+	}
+	if len(srv.sessions) != maxSessions {
+		t.Errorf("live sessions = %d, want %d", len(srv.sessions), maxSessions)
+	}
+	if srv.touchSession(first) {
+		t.Error("least recently used session should have been dropped")
+	}
+}
+
+func TestServer_Stdio(t *testing.T) {
+	srv, _ := newTestServer(t, "")
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- srv.ServeStdio(context.Background(), inR, outW)
+		outW.Close()
+	}()
+
+	responses := bufio.NewScanner(outR)
+	exchange := func(line string) message {
+		t.Helper()
+		if _, err := io.WriteString(inW, line+"\n"); err != nil {
+			t.Fatal(err)
+		}
+		if !responses.Scan() {
+			t.Fatalf("no response to %s", line)
+		}
+		var msg message
+		if err := json.Unmarshal(responses.Bytes(), &msg); err != nil {
+			t.Fatalf("invalid response %q: %v", responses.Text(), err)
+		}
+		return msg
+	}
+
+	init := exchange(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26"}}`)
+	var result InitializeResult
+	json.Unmarshal(init.Result, &result)
+	if result.ProtocolVersion != "2025-03-26" {
+		t.Errorf("negotiated version = %q, want the client's 2025-03-26", result.ProtocolVersion)
+	}
+
+	// Notifications get no response, so the next line answers the ping.
+	io.WriteString(inW, `{"jsonrpc":"2.0","method":"notifications/initialized"}`+"\n")
+	if ping := exchange(`{"jsonrpc":"2.0","id":2,"method":"ping"}`); string(ping.ID) != "2" || ping.Error != nil {
+		t.Errorf("ping response = %+v", ping)
+	}
+
+	if resp := exchange(`{"jsonrpc":"2.0","id":3,"method":"sampling/createMessage"}`); resp.Error == nil ||
+		resp.Error.Code != codeMethodNotFound {
+		t.Errorf("unknown method response = %+v", resp)
+	}
+	if resp := exchange(`not json`); resp.Error == nil || resp.Error.Code != codeParseError {
+		t.Errorf("parse error response = %+v", resp)
+	}
+
+	inW.Close()
+	if err := <-done; err != nil {
+		t.Errorf("ServeStdio() error: %v", err)
+	}
+}
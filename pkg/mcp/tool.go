@@ -0,0 +1,288 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// maxToolNameLength is the longest function name accepted by most providers.
+const maxToolNameLength = 64
+
+// maxListedItems bounds how many resources or prompts are described inline.
+const maxListedItems = 50
+
+// ToolName returns the namespaced agent tool name for a server's tool:
+// mcp_<server>_<tool>, restricted to [a-zA-Z0-9_-] and 64 characters.
+func ToolName(serverName, toolName string) string {
+	name := "mcp_" + sanitizeName(serverName) + "_" + sanitizeName(toolName)
+	if len(name) > maxToolNameLength {
+		name = name[:maxToolNameLength]
+	}
+	return name
+}
+
+func sanitizeName(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+// remoteTool exposes one MCP server tool as an agent tool.
+type remoteTool struct {
+	srv  *server
+	tool Tool
+	name string
+}
+
+func newRemoteTool(srv *server, tool Tool) *remoteTool {
+	return &remoteTool{srv: srv, tool: tool, name: ToolName(srv.cfg.Name, tool.Name)}
+}
+
+func (t *remoteTool) Name() string {
+	return t.name
+}
+
+func (t *remoteTool) Description() string {
+	desc := t.tool.Description
+	if desc == "" {
+		desc = t.tool.Title
+	}
+	return fmt.Sprintf("[MCP %s] %s", t.srv.cfg.Name, desc)
+}
+
+func (t *remoteTool) Parameters() map[string]any {
+	schema := make(map[string]any, len(t.tool.InputSchema)+2)
+	for k, v := range t.tool.InputSchema {
+		schema[k] = v
+	}
+	schema["type"] = "object"
+	if _, ok := schema["properties"]; !ok {
+		schema["properties"] = map[string]any{}
+	}
+	return schema
+}
+
+func (t *remoteTool) Execute(ctx context.Context, args map[string]any) *tools.ToolResult {
+	var result *CallToolResult
+	err := t.srv.do(ctx, func(c *Client) error {
+		var err error
+		result, err = c.CallTool(ctx, t.tool.Name, args)
+		return err
+	})
+	if err != nil {
+		return tools.ErrorResult(fmt.Sprintf("MCP tool %s failed: %v", t.name, err)).WithError(err)
+	}
+
+	text := formatContent(result.Content)
+	if text == "" && result.StructuredContent != nil {
+		data, _ := json.Marshal(result.StructuredContent)
+		text = string(data)
+	}
+	if result.IsError {
+		return tools.ErrorResult(text)
+	}
+	return tools.NewToolResult(text)
+}
+
+// resourceTool reads the resources of one server.
+type resourceTool struct {
+	srv       *server
+	resources []Resource
+}
+
+func newResourceTool(srv *server, resources []Resource) *resourceTool {
+	return &resourceTool{srv: srv, resources: resources}
+}
+
+func (t *resourceTool) Name() string {
+	return ToolName(t.srv.cfg.Name, "read_resource")
+}
+
+func (t *resourceTool) Description() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[MCP %s] Read a resource by URI. Available resources:", t.srv.cfg.Name)
+	for i, r := range t.resources {
+		if i == maxListedItems {
+			fmt.Fprintf(&b, "\n- ... and %d more", len(t.resources)-i)
+			break
+		}
+		fmt.Fprintf(&b, "\n- %s (%s)", r.URI, r.Name)
+		if r.Description != "" {
+			b.WriteString(": " + r.Description)
+		}
+	}
+	return b.String()
+}
+
+func (t *resourceTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"uri": map[string]any{
+				"type":        "string",
+				"description": "URI of the resource to read",
+			},
+		},
+		"required": []string{"uri"},
+	}
+}
+
+func (t *resourceTool) Execute(ctx context.Context, args map[string]any) *tools.ToolResult {
+	uri, _ := args["uri"].(string)
+	if uri == "" {
+		return tools.ErrorResult("uri is required")
+	}
+
+	var result *ReadResourceResult
+	err := t.srv.do(ctx, func(c *Client) error {
+		var err error
+		result, err = c.ReadResource(ctx, uri)
+		return err
+	})
+	if err != nil {
+		return tools.ErrorResult(fmt.Sprintf("reading %s failed: %v", uri, err)).WithError(err)
+	}
+
+	parts := make([]string, 0, len(result.Contents))
+	for _, rc := range result.Contents {
+		parts = append(parts, formatResource(rc))
+	}
+	return tools.NewToolResult(strings.Join(parts, "\n\n"))
+}
+
+// promptTool renders the prompt templates of one server.
+type promptTool struct {
+	srv     *server
+	prompts []Prompt
+}
+
+func newPromptTool(srv *server, prompts []Prompt) *promptTool {
+	return &promptTool{srv: srv, prompts: prompts}
+}
+
+func (t *promptTool) Name() string {
+	return ToolName(t.srv.cfg.Name, "get_prompt")
+}
+
+func (t *promptTool) Description() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[MCP %s] Render a prompt template. Available prompts:", t.srv.cfg.Name)
+	for i, p := range t.prompts {
+		if i == maxListedItems {
+			fmt.Fprintf(&b, "\n- ... and %d more", len(t.prompts)-i)
+			break
+		}
+		fmt.Fprintf(&b, "\n- %s", p.Name)
+		if len(p.Arguments) > 0 {
+			args := make([]string, 0, len(p.Arguments))
+			for _, a := range p.Arguments {
+				if a.Required {
+					args = append(args, a.Name+"*")
+				} else {
+					args = append(args, a.Name)
+				}
+			}
+			fmt.Fprintf(&b, "(%s)", strings.Join(args, ", "))
+		}
+		if p.Description != "" {
+			b.WriteString(": " + p.Description)
+		}
+	}
+	return b.String()
+}
+
+func (t *promptTool) Parameters() map[string]any {
+	names := make([]string, 0, len(t.prompts))
+	for _, p := range t.prompts {
+		names = append(names, p.Name)
+	}
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"name": map[string]any{
+				"type":        "string",
+				"description": "Prompt name",
+				"enum":        names,
+			},
+			"arguments": map[string]any{
+				"type":                 "object",
+				"description":          "Prompt arguments (* marks required ones)",
+				"additionalProperties": map[string]any{"type": "string"},
+			},
+		},
+		"required": []string{"name"},
+	}
+}
+
+func (t *promptTool) Execute(ctx context.Context, args map[string]any) *tools.ToolResult {
+	name, _ := args["name"].(string)
+	if name == "" {
+		return tools.ErrorResult("name is required")
+	}
+	promptArgs := map[string]string{}
+	if raw, ok := args["arguments"].(map[string]any); ok {
+		for k, v := range raw {
+			promptArgs[k] = fmt.Sprint(v)
+		}
+	}
+
+	var result *GetPromptResult
+	err := t.srv.do(ctx, func(c *Client) error {
+		var err error
+		result, err = c.GetPrompt(ctx, name, promptArgs)
+		return err
+	})
+	if err != nil {
+		return tools.ErrorResult(fmt.Sprintf("prompt %s failed: %v", name, err)).WithError(err)
+	}
+
+	parts := make([]string, 0, len(result.Messages))
+	for _, m := range result.Messages {
+		parts = append(parts, fmt.Sprintf("[%s]\n%s", m.Role, formatContent([]Content{m.Content})))
+	}
+	return tools.NewToolResult(strings.Join(parts, "\n\n"))
+}
+
+// formatContent renders tool or prompt content as text for the LLM. Binary
+// content is summarized rather than inlined.
+func formatContent(contents []Content) string {
+	parts := make([]string, 0, len(contents))
+	for _, c := range contents {
+		switch c.Type {
+		case "text":
+			parts = append(parts, c.Text)
+		case "image", "audio":
+			parts = append(parts, fmt.Sprintf("[%s content (%s), %d bytes base64 omitted]", c.Type, c.MimeType, len(c.Data)))
+		case "resource":
+			if c.Resource != nil {
+				parts = append(parts, formatResource(*c.Resource))
+			}
+		case "resource_link":
+			parts = append(parts, fmt.Sprintf("[resource %s %s]", c.URI, c.Name))
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+func formatResource(rc ResourceContents) string {
+	if rc.Text != "" || rc.Blob == "" {
+		return rc.Text
+	}
+	return fmt.Sprintf("[binary resource %s (%s), %d bytes base64 omitted]", rc.URI, rc.MimeType, len(rc.Blob))
+}
@@ -0,0 +1,403 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// maxMessageSize bounds a single JSON-RPC message read from a server.
+const maxMessageSize = 16 << 20
+
+// transport carries JSON-RPC messages to one server.
+type transport interface {
+	// call sends a request and waits for the response with the same ID.
+	call(ctx context.Context, req *message) (*message, error)
+	// notify sends a notification, which has no response.
+	notify(ctx context.Context, msg *message) error
+	// setProtocolVersion records the negotiated protocol revision.
+	setProtocolVersion(version string)
+	close() error
+}
+
+func newTransport(cfg config.MCPServerConfig) (transport, error) {
+	if cfg.Type == "http" {
+		return newHTTPTransport(cfg), nil
+	}
+	return startStdioTransport(cfg)
+}
+
+// stdioTransport runs the server as a child process and exchanges
+// newline-delimited JSON messages over its stdin and stdout.
+type stdioTransport struct {
+	name  string
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	pending map[string]chan *message
+	exited  chan struct{}
+	stderr  *tailBuffer
+}
+
+func startStdioTransport(cfg config.MCPServerConfig) (*stdioTransport, error) {
+	cmd := exec.Command(cfg.Command, cfg.Args...)
+	cmd.Dir = cfg.Dir
+	cmd.Env = os.Environ()
+	for k, v := range cfg.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr := &tailBuffer{max: 4096}
+	cmd.Stderr = stderr
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("starting %s: %w", cfg.Command, err)
+	}
+
+	t := &stdioTransport{
+		name:    cfg.Name,
+		cmd:     cmd,
+		stdin:   stdin,
+		pending: make(map[string]chan *message),
+		exited:  make(chan struct{}),
+		stderr:  stderr,
+	}
+	go t.readLoop(stdout)
+	return t, nil
+}
+
+func (t *stdioTransport) readLoop(stdout io.Reader) {
+	defer func() {
+		t.cmd.Wait()
+		t.mu.Lock()
+		close(t.exited)
+		t.pending = nil
+		t.mu.Unlock()
+	}()
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var msg message
+		if err := json.Unmarshal(line, &msg); err != nil {
+			logger.DebugCF("mcp", "Ignoring non-JSON output", map[string]any{"server": t.name, "line": string(line)})
+			continue
+		}
+
+		switch {
+		case msg.isResponse():
+			t.mu.Lock()
+			ch := t.pending[string(msg.ID)]
+			delete(t.pending, string(msg.ID))
+			t.mu.Unlock()
+			if ch != nil {
+				ch <- &msg
+			}
+		case len(msg.ID) > 0:
+			// Server-to-client request: answer pings, refuse the rest.
+			t.write(replyToServerRequest(&msg))
+		}
+	}
+}
+
+func (t *stdioTransport) call(ctx context.Context, req *message) (*message, error) {
+	ch := make(chan *message, 1)
+	t.mu.Lock()
+	if t.pending == nil {
+		t.mu.Unlock()
+		return nil, t.exitError()
+	}
+	t.pending[string(req.ID)] = ch
+	t.mu.Unlock()
+
+	if err := t.write(req); err != nil {
+		t.mu.Lock()
+		delete(t.pending, string(req.ID))
+		t.mu.Unlock()
+		return nil, err
+	}
+
+	select {
+	case resp := <-ch:
+		return resp, nil
+	case <-t.exited:
+		return nil, t.exitError()
+	case <-ctx.Done():
+		t.mu.Lock()
+		if t.pending != nil {
+			delete(t.pending, string(req.ID))
+		}
+		t.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+func (t *stdioTransport) notify(_ context.Context, msg *message) error {
+	return t.write(msg)
+}
+
+func (t *stdioTransport) setProtocolVersion(string) {}
+
+func (t *stdioTransport) write(msg *message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	if _, err := t.stdin.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("%w: %v", errTransportClosed, err)
+	}
+	return nil
+}
+
+func (t *stdioTransport) exitError() error {
+	if tail := strings.TrimSpace(t.stderr.String()); tail != "" {
+		return fmt.Errorf("%w: server exited: %s", errTransportClosed, tail)
+	}
+	return fmt.Errorf("%w: server exited", errTransportClosed)
+}
+
+// close closes stdin, which asks the server to exit, and kills it if it is
+// still running after a grace period.
+func (t *stdioTransport) close() error {
+	t.stdin.Close()
+	select {
+	case <-t.exited:
+	case <-time.After(2 * time.Second):
+		if t.cmd.Process != nil {
+			t.cmd.Process.Kill()
+		}
+		<-t.exited
+	}
+	return nil
+}
+
+// httpTransport implements the streamable HTTP transport: every message is
+// POSTed to the server URL, which answers with JSON or an SSE stream.
+type httpTransport struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+
+	mu              sync.Mutex
+	sessionID       string
+	protocolVersion string
+}
+
+func newHTTPTransport(cfg config.MCPServerConfig) *httpTransport {
+	return &httpTransport{
+		url:     cfg.URL,
+		headers: cfg.Headers,
+		client:  &http.Client{},
+	}
+}
+
+func (t *httpTransport) call(ctx context.Context, req *message) (*message, error) {
+	resp, err := t.post(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		return readSSEResponse(resp.Body, req.ID)
+	}
+
+	var msg message
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxMessageSize)).Decode(&msg); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+	return &msg, nil
+}
+
+func (t *httpTransport) notify(ctx context.Context, msg *message) error {
+	resp, err := t.post(ctx, msg)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return nil
+}
+
+func (t *httpTransport) post(ctx context.Context, msg *message) (*http.Response, error) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	t.setHeaders(req)
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if id := resp.Header.Get("Mcp-Session-Id"); id != "" {
+		t.mu.Lock()
+		t.sessionID = id
+		t.mu.Unlock()
+	}
+
+	if resp.StatusCode == http.StatusNotFound && t.session() != "" {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: session expired", errTransportClosed)
+	}
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf("mcp http status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return resp, nil
+}
+
+func (t *httpTransport) setHeaders(req *http.Request) {
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.sessionID != "" {
+		req.Header.Set("Mcp-Session-Id", t.sessionID)
+	}
+	if t.protocolVersion != "" {
+		req.Header.Set("MCP-Protocol-Version", t.protocolVersion)
+	}
+}
+
+func (t *httpTransport) session() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.sessionID
+}
+
+func (t *httpTransport) setProtocolVersion(version string) {
+	t.mu.Lock()
+	t.protocolVersion = version
+	t.mu.Unlock()
+}
+
+// close ends the session so the server can release its state.
+func (t *httpTransport) close() error {
+	if t.session() == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, t.url, nil)
+	if err != nil {
+		return err
+	}
+	t.setHeaders(req)
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// readSSEResponse reads server-sent events until the response to id arrives.
+func readSSEResponse(body io.Reader, id json.RawMessage) (*message, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+
+	var data strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+		if after, ok := strings.CutPrefix(line, "data:"); ok {
+			data.WriteString(strings.TrimPrefix(after, " "))
+			continue
+		}
+		if line != "" || data.Len() == 0 {
+			continue
+		}
+
+		// A blank line ends the event.
+		var msg message
+		err := json.Unmarshal([]byte(data.String()), &msg)
+		data.Reset()
+		if err == nil && msg.isResponse() && bytes.Equal(msg.ID, id) {
+			return &msg, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading event stream: %w", err)
+	}
+	return nil, fmt.Errorf("event stream ended without a response")
+}
+
+// replyToServerRequest answers a request sent by the server. Only ping is
+// supported; sampling, roots and elicitation are not offered by this client.
+func replyToServerRequest(req *message) *message {
+	if req.Method == "ping" {
+		return &message{JSONRPC: "2.0", ID: req.ID, Result: json.RawMessage(`{}`)}
+	}
+	return &message{
+		JSONRPC: "2.0",
+		ID:      req.ID,
+		Error:   &RPCError{Code: codeMethodNotFound, Message: "method not supported: " + req.Method},
+	}
+}
+
+// tailBuffer keeps the last max bytes written to it, used to report why a
+// server process exited.
+type tailBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+	max int
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.max {
+		b.buf = b.buf[len(b.buf)-b.max:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
@@ -21,9 +21,15 @@ type (
 	Message                = protocoltypes.Message
 	ToolDefinition         = protocoltypes.ToolDefinition
 	ToolFunctionDefinition = protocoltypes.ToolFunctionDefinition
+	ThinkingBlock          = protocoltypes.ThinkingBlock
 )
 
-const defaultBaseURL = "https://api.anthropic.com"
+const (
+	defaultBaseURL = "https://api.anthropic.com"
+
+	// minThinkingBudget is the smallest budget_tokens the API accepts.
+	minThinkingBudget = 1024
+)
 
 type Provider struct {
 	client      *anthropic.Client
@@ -125,7 +131,18 @@ func buildParams(
 	var system []anthropic.TextBlockParam
 	var anthropicMessages []anthropic.MessageParam
 
-	for _, msg := range messages {
+	budget := thinkingBudget(options)
+	turnStart := currentTurnStart(messages)
+	if budget > 0 && !canReplayThinking(messages[turnStart:]) {
+		// The API rejects a tool-use turn with thinking enabled unless the
+		// assistant messages carry their signed thinking blocks (they may have
+		// come from another provider or an older session). Answer this turn
+		// without thinking instead of failing.
+		log.Printf("anthropic: disabling thinking for this request: tool-use turn lacks thinking blocks")
+		budget = 0
+	}
+
+	for i, msg := range messages {
 		switch msg.Role {
 		case "system":
 			// Prefer structured SystemParts for per-block cache_control.
@@ -147,6 +164,11 @@ func buildParams(
 				anthropicMessages = append(anthropicMessages,
 					anthropic.NewUserMessage(anthropic.NewToolResultBlock(msg.ToolCallID, msg.Content, false)),
 				)
+			} else if blocks := mediaBlocks(msg.Media); len(blocks) > 0 {
+				if msg.Content != "" {
+					blocks = append(blocks, anthropic.NewTextBlock(msg.Content))
+				}
+				anthropicMessages = append(anthropicMessages, anthropic.NewUserMessage(blocks...))
 			} else {
 				anthropicMessages = append(anthropicMessages,
 					anthropic.NewUserMessage(anthropic.NewTextBlock(msg.Content)),
@@ -155,6 +177,11 @@ func buildParams(
 		case "assistant":
 			if len(msg.ToolCalls) > 0 {
 				var blocks []anthropic.ContentBlockParamUnion
+				// Thinking blocks are only required (and only verified) for the
+				// turn in progress; earlier ones would just cost input tokens.
+				if budget > 0 && i >= turnStart {
+					blocks = append(blocks, thinkingBlockParams(msg.ThinkingBlocks)...)
+				}
 				if msg.Content != "" {
 					blocks = append(blocks, anthropic.NewTextBlock(msg.Content))
 				}
@@ -202,7 +229,17 @@ func buildParams(
 		params.System = system
 	}
 
-	if temp, ok := options["temperature"].(float64); ok {
+	if budget > 0 {
+		budget = max(budget, minThinkingBudget)
+		// max_tokens includes the thinking budget and must exceed it.
+		if params.MaxTokens <= int64(budget) {
+			params.MaxTokens += int64(budget)
+		}
+		params.Thinking = anthropic.ThinkingConfigParamOfEnabled(int64(budget))
+	}
+
+	// Extended thinking only works with the default temperature.
+	if temp, ok := options["temperature"].(float64); ok && budget == 0 {
 		params.Temperature = anthropic.Float(temp)
 	}
 
@@ -213,6 +250,79 @@ func buildParams(
 	return params, nil
 }
 
+// mediaBlocks turns image data URLs and http(s) URLs into image blocks and
+// PDF data URLs into document blocks. Anything else is dropped.
+func mediaBlocks(media []string) []anthropic.ContentBlockParamUnion {
+	var blocks []anthropic.ContentBlockParamUnion
+	for _, m := range media {
+		if rest, ok := strings.CutPrefix(m, "data:"); ok {
+			mediaType, data, ok := strings.Cut(rest, ";base64,")
+			switch {
+			case !ok || data == "":
+			case mediaType == "application/pdf":
+				blocks = append(blocks, anthropic.NewDocumentBlock(anthropic.Base64PDFSourceParam{Data: data}))
+			case strings.HasPrefix(mediaType, "image/"):
+				blocks = append(blocks, anthropic.NewImageBlockBase64(mediaType, data))
+			}
+			continue
+		}
+		if strings.HasPrefix(m, "https://") || strings.HasPrefix(m, "http://") {
+			blocks = append(blocks, anthropic.NewImageBlock(anthropic.URLImageSourceParam{URL: m}))
+		}
+	}
+	return blocks
+}
+
+// thinkingBudget resolves the requested thinking budget from the
+// "thinking_budget" option or, failing that, the "reasoning_effort" level.
+// 0 means thinking stays disabled.
+func thinkingBudget(options map[string]any) int {
+	if budget, ok := options["thinking_budget"].(int); ok {
+		return max(budget, 0)
+	}
+	if effort, ok := options["reasoning_effort"].(string); ok {
+		budget, _ := protocoltypes.ThinkingBudgetForEffort(effort)
+		return budget
+	}
+	return 0
+}
+
+// currentTurnStart returns the index just past the last user message, i.e.
+// where the assistant/tool exchange of the current turn begins. Messages
+// carrying tool attachments belong to that exchange.
+func currentTurnStart(messages []Message) int {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" && messages[i].ToolCallID == "" && !messages[i].ToolMedia {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// canReplayThinking reports whether every tool-calling assistant message of
+// the current turn has thinking blocks to send back.
+func canReplayThinking(turn []Message) bool {
+	for _, msg := range turn {
+		if msg.Role == "assistant" && len(msg.ToolCalls) > 0 && len(msg.ThinkingBlocks) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func thinkingBlockParams(blocks []ThinkingBlock) []anthropic.ContentBlockParamUnion {
+	params := make([]anthropic.ContentBlockParamUnion, 0, len(blocks))
+	for _, b := range blocks {
+		switch b.Type {
+		case "thinking":
+			params = append(params, anthropic.NewThinkingBlock(b.Signature, b.Thinking))
+		case "redacted_thinking":
+			params = append(params, anthropic.NewRedactedThinkingBlock(b.Data))
+		}
+	}
+	return params
+}
+
 func translateTools(tools []ToolDefinition) []anthropic.ToolUnionParam {
 	result := make([]anthropic.ToolUnionParam, 0, len(tools))
 	for _, t := range tools {
@@ -240,14 +350,28 @@ func translateTools(tools []ToolDefinition) []anthropic.ToolUnionParam {
 }
 
 func parseResponse(resp *anthropic.Message) *LLMResponse {
-	var content strings.Builder
+	var content, reasoning strings.Builder
 	var toolCalls []ToolCall
+	var thinkingBlocks []ThinkingBlock
 
 	for _, block := range resp.Content {
 		switch block.Type {
 		case "text":
 			tb := block.AsText()
 			content.WriteString(tb.Text)
+		case "thinking":
+			th := block.AsThinking()
+			reasoning.WriteString(th.Thinking)
+			thinkingBlocks = append(thinkingBlocks, ThinkingBlock{
+				Type:      "thinking",
+				Thinking:  th.Thinking,
+				Signature: th.Signature,
+			})
+		case "redacted_thinking":
+			thinkingBlocks = append(thinkingBlocks, ThinkingBlock{
+				Type: "redacted_thinking",
+				Data: block.AsRedactedThinking().Data,
+			})
 		case "tool_use":
 			tu := block.AsToolUse()
 			var args map[string]any
@@ -274,9 +398,11 @@ func parseResponse(resp *anthropic.Message) *LLMResponse {
 	}
 
 	return &LLMResponse{
-		Content:      content.String(),
-		ToolCalls:    toolCalls,
-		FinishReason: finishReason,
+		Content:          content.String(),
+		ReasoningContent: reasoning.String(),
+		ThinkingBlocks:   thinkingBlocks,
+		ToolCalls:        toolCalls,
+		FinishReason:     finishReason,
 		Usage: &UsageInfo{
 			PromptTokens:     int(resp.Usage.InputTokens),
 			CompletionTokens: int(resp.Usage.OutputTokens),
@@ -77,6 +77,43 @@ func TestBuildParams_ToolCallMessage(t *testing.T) {
 	}
 }
 
+func TestBuildParams_Media(t *testing.T) {
+	// read_file attaches images and PDFs as data URLs on a user message
+	// that follows the tool results.
+	messages := []Message{
+		{Role: "user", Content: "Look at these"},
+		{Role: "assistant", ToolCalls: []ToolCall{{ID: "call_1", Name: "read_file", Arguments: map[string]any{}}}},
+		{Role: "tool", Content: "image.png is image/png (4 bytes); it is attached for you to view", ToolCallID: "call_1"},
+		{
+			Role:    "user",
+			Content: "[2 file(s) attached by the tool results above]",
+			Media: []string{
+				"data:image/png;base64,iVBORw==",
+				"data:application/pdf;base64,JVBERi0=",
+				"data:text/plain;base64,aGk=",
+			},
+		},
+	}
+	params, err := buildParams(messages, nil, "claude-sonnet-4.6", map[string]any{})
+	if err != nil {
+		t.Fatalf("buildParams() error: %v", err)
+	}
+	blocks := params.Messages[3].Content
+	if len(blocks) != 3 {
+		t.Fatalf("len(Content) = %d, want image, document and text", len(blocks))
+	}
+	if img := blocks[0].OfImage; img == nil || img.Source.OfBase64 == nil ||
+		img.Source.OfBase64.MediaType != "image/png" || img.Source.OfBase64.Data != "iVBORw==" {
+		t.Errorf("Content[0] = %+v, want a base64 PNG image block", blocks[0])
+	}
+	if doc := blocks[1].OfDocument; doc == nil || doc.Source.OfBase64 == nil || doc.Source.OfBase64.Data != "JVBERi0=" {
+		t.Errorf("Content[1] = %+v, want a base64 PDF document block", blocks[1])
+	}
+	if text := blocks[2].OfText; text == nil || text.Text != messages[3].Content {
+		t.Errorf("Content[2] = %+v, want the message text", blocks[2])
+	}
+}
+
 func TestBuildParams_WithTools(t *testing.T) {
 	tools := []ToolDefinition{
 		{
@@ -103,6 +140,182 @@ func TestBuildParams_WithTools(t *testing.T) {
 	}
 }
 
+func thinkingTurn(blocks []ThinkingBlock) []Message {
+	return []Message{
+		{Role: "user", Content: "Earlier question"},
+		{
+			Role:           "assistant",
+			ToolCalls:      []ToolCall{{ID: "call_0", Name: "get_weather", Arguments: map[string]any{"city": "LA"}}},
+			ThinkingBlocks: []ThinkingBlock{{Type: "thinking", Thinking: "old", Signature: "sig-old"}},
+		},
+		{Role: "tool", Content: "70F", ToolCallID: "call_0"},
+		{Role: "assistant", Content: "It is 70F."},
+		{Role: "user", Content: "And SF?"},
+		{
+			Role:           "assistant",
+			ToolCalls:      []ToolCall{{ID: "call_1", Name: "get_weather", Arguments: map[string]any{"city": "SF"}}},
+			ThinkingBlocks: blocks,
+		},
+		{Role: "tool", Content: "60F", ToolCallID: "call_1"},
+	}
+}
+
+func TestBuildParams_Thinking(t *testing.T) {
+	messages := thinkingTurn([]ThinkingBlock{
+		{Type: "thinking", Thinking: "Need the weather tool.", Signature: "sig-1"},
+		{Type: "redacted_thinking", Data: "opaque"},
+	})
+	params, err := buildParams(messages, nil, "claude-sonnet-4.6", map[string]any{
+		"max_tokens":      4096,
+		"temperature":     0.7,
+		"thinking_budget": 8000,
+	})
+	if err != nil {
+		t.Fatalf("buildParams() error: %v", err)
+	}
+
+	if params.Thinking.OfEnabled == nil || params.Thinking.OfEnabled.BudgetTokens != 8000 {
+		t.Fatalf("Thinking = %+v, want enabled with budget 8000", params.Thinking)
+	}
+	if params.MaxTokens != 12096 {
+		t.Errorf("MaxTokens = %d, want budget + 4096", params.MaxTokens)
+	}
+	if params.Temperature.Valid() {
+		t.Error("temperature must not be sent with thinking enabled")
+	}
+
+	// Earlier turn: thinking blocks are dropped.
+	if first := params.Messages[1].Content[0]; first.OfToolUse == nil {
+		t.Errorf("earlier assistant message should start with tool_use, got %+v", first)
+	}
+	// Current turn: thinking blocks are replayed before the tool call.
+	current := params.Messages[5].Content
+	if len(current) != 3 {
+		t.Fatalf("len(current turn blocks) = %d, want 3", len(current))
+	}
+	if current[0].OfThinking == nil || current[0].OfThinking.Signature != "sig-1" {
+		t.Errorf("block 0 = %+v, want signed thinking", current[0])
+	}
+	if current[1].OfRedactedThinking == nil || current[1].OfRedactedThinking.Data != "opaque" {
+		t.Errorf("block 1 = %+v, want redacted thinking", current[1])
+	}
+	if current[2].OfToolUse == nil {
+		t.Errorf("block 2 = %+v, want tool_use", current[2])
+	}
+}
+
+func TestBuildParams_ThinkingFromEffort(t *testing.T) {
+	params, err := buildParams([]Message{{Role: "user", Content: "Hi"}}, nil, "claude-sonnet-4.6", map[string]any{
+		"reasoning_effort": "low",
+	})
+	if err != nil {
+		t.Fatalf("buildParams() error: %v", err)
+	}
+	if params.Thinking.OfEnabled == nil || params.Thinking.OfEnabled.BudgetTokens != 2048 {
+		t.Fatalf("Thinking = %+v, want enabled with budget 2048", params.Thinking)
+	}
+
+	params, err = buildParams([]Message{{Role: "user", Content: "Hi"}}, nil, "claude-sonnet-4.6", map[string]any{
+		"reasoning_effort": "none",
+	})
+	if err != nil {
+		t.Fatalf("buildParams() error: %v", err)
+	}
+	if params.Thinking.OfEnabled != nil {
+		t.Error("effort none should leave thinking disabled")
+	}
+}
+
+func TestBuildParams_ThinkingDisabledWithoutSignatures(t *testing.T) {
+	// The tool call of the current turn came from another provider.
+	params, err := buildParams(thinkingTurn(nil), nil, "claude-sonnet-4.6", map[string]any{
+		"thinking_budget": 4096,
+	})
+	if err != nil {
+		t.Fatalf("buildParams() error: %v", err)
+	}
+	if params.Thinking.OfEnabled != nil {
+		t.Error("thinking should be disabled when the current turn cannot be replayed")
+	}
+	if first := params.Messages[5].Content[0]; first.OfToolUse == nil {
+		t.Errorf("assistant message should start with tool_use, got %+v", first)
+	}
+}
+
+func TestBuildParams_ThinkingWithToolMedia(t *testing.T) {
+	// Media attached by a tool result follows it as a user message that
+	// still belongs to the current turn.
+	messages := []Message{
+		{Role: "user", Content: "What is in the picture?"},
+		{
+			Role:           "assistant",
+			ToolCalls:      []ToolCall{{ID: "call_1", Name: "read_file", Arguments: map[string]any{"path": "cat.png"}}},
+			ThinkingBlocks: []ThinkingBlock{{Type: "thinking", Thinking: "Read the file.", Signature: "sig-1"}},
+		},
+		{Role: "tool", Content: "image attached", ToolCallID: "call_1"},
+		{
+			Role:      "user",
+			Content:   "[1 file(s) attached by the tool results above]",
+			Media:     []string{"data:image/png;base64,iVBORw0KGgo="},
+			ToolMedia: true,
+		},
+	}
+	params, err := buildParams(messages, nil, "claude-sonnet-4.6", map[string]any{
+		"thinking_budget": 2048,
+	})
+	if err != nil {
+		t.Fatalf("buildParams() error: %v", err)
+	}
+	if params.Thinking.OfEnabled == nil {
+		t.Fatal("thinking should stay enabled")
+	}
+	first := params.Messages[1].Content[0]
+	if first.OfThinking == nil || first.OfThinking.Signature != "sig-1" {
+		t.Errorf("assistant message should start with the signed thinking block, got %+v", first)
+	}
+}
+
+func TestProvider_ChatParsesThinking(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp := map[string]any{
+			"id":          "msg_test",
+			"type":        "message",
+			"role":        "assistant",
+			"model":       "claude-sonnet-4.6",
+			"stop_reason": "tool_use",
+			"content": []map[string]any{
+				{"type": "thinking", "thinking": "Check the weather.", "signature": "sig-abc"},
+				{"type": "redacted_thinking", "data": "opaque"},
+				{"type": "tool_use", "id": "call_1", "name": "get_weather", "input": map[string]any{"city": "SF"}},
+			},
+			"usage": map[string]any{"input_tokens": 1, "output_tokens": 1},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	provider := NewProviderWithClient(createAnthropicTestClient(server.URL, "test-token"))
+	resp, err := provider.Chat(t.Context(), []Message{{Role: "user", Content: "Weather?"}}, nil,
+		"claude-sonnet-4.6", map[string]any{"thinking_budget": 2048})
+	if err != nil {
+		t.Fatalf("Chat() error: %v", err)
+	}
+	if resp.ReasoningContent != "Check the weather." {
+		t.Errorf("ReasoningContent = %q", resp.ReasoningContent)
+	}
+	want := []ThinkingBlock{
+		{Type: "thinking", Thinking: "Check the weather.", Signature: "sig-abc"},
+		{Type: "redacted_thinking", Data: "opaque"},
+	}
+	if len(resp.ThinkingBlocks) != len(want) || resp.ThinkingBlocks[0] != want[0] || resp.ThinkingBlocks[1] != want[1] {
+		t.Errorf("ThinkingBlocks = %+v, want %+v", resp.ThinkingBlocks, want)
+	}
+	if len(resp.ToolCalls) != 1 {
+		t.Errorf("len(ToolCalls) = %d, want 1", len(resp.ToolCalls))
+	}
+}
+
 func TestParseResponse_TextOnly(t *testing.T) {
 	resp := &anthropic.Message{
 		Content: []anthropic.ContentBlockUnion{},
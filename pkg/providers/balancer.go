@@ -0,0 +1,335 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// Load balancing strategies for model_list entries sharing a model_name.
+const (
+	BalanceRoundRobin   = "round_robin"
+	BalanceWeighted     = "weighted"
+	BalanceLeastLatency = "least_latency"
+	BalanceLeastErrors  = "least_errors"
+)
+
+// latencyEWMAAlpha is the smoothing factor for per-endpoint latency tracking.
+const latencyEWMAAlpha = 0.3
+
+// BalancedEndpoint is one member of a load-balanced pool.
+type BalancedEndpoint struct {
+	Name     string // identifier used for cooldown tracking and logs
+	Protocol string // provider name the fallback chain tracks cooldowns under
+	Provider LLMProvider
+	Model    string // model ID sent to this endpoint
+	Weight   int
+	Limiter  *RateLimiter // optional per-endpoint rate limiter
+
+	mu            sync.Mutex
+	latency       time.Duration // EWMA of successful request latency
+	currentWeight int           // smooth weighted round-robin state, guarded by BalancedProvider.weightMu
+}
+
+// BalancedProvider spreads requests for one model alias over several endpoints.
+// Endpoint health is tracked with a CooldownTracker: endpoints in cooldown, or
+// whose provider the fallback chain has put in cooldown, are tried last, and
+// retriable failures move on to the next endpoint.
+type BalancedProvider struct {
+	alias     string
+	strategy  string
+	endpoints []*BalancedEndpoint
+	cooldown  *CooldownTracker
+	counter   atomic.Uint64
+	weightMu  sync.Mutex
+}
+
+// NewBalancedProvider creates a balancer over endpoints using strategy.
+// An unknown or empty strategy falls back to round-robin.
+func NewBalancedProvider(alias, strategy string, endpoints []*BalancedEndpoint) *BalancedProvider {
+	switch strategy {
+	case BalanceRoundRobin, BalanceWeighted, BalanceLeastLatency, BalanceLeastErrors:
+	default:
+		strategy = BalanceRoundRobin
+	}
+	for _, ep := range endpoints {
+		if ep.Weight <= 0 {
+			ep.Weight = 1
+		}
+	}
+	return &BalancedProvider{
+		alias:     alias,
+		strategy:  strategy,
+		endpoints: endpoints,
+		cooldown:  NewCooldownTracker(),
+	}
+}
+
+// NewBalancedProviderFromConfigs builds one provider per model_list entry and
+// balances across them. The strategy is taken from the first entry that sets
+// load_balance. Returns the balancer and the model ID of the first entry.
+func NewBalancedProviderFromConfigs(cfgs []config.ModelConfig) (*BalancedProvider, string, error) {
+	if len(cfgs) == 0 {
+		return nil, "", fmt.Errorf("no model configs to balance")
+	}
+
+	strategy := ""
+	endpoints := make([]*BalancedEndpoint, 0, len(cfgs))
+	for i := range cfgs {
+		mc := &cfgs[i]
+		if strategy == "" {
+			strategy = strings.TrimSpace(mc.LoadBalance)
+		}
+
+		provider, modelID, err := CreateProviderFromConfig(mc)
+		if err != nil {
+			return nil, "", fmt.Errorf("endpoint %d of %q: %w", i, mc.ModelName, err)
+		}
+
+		protocol, _ := ExtractProtocol(mc.Model)
+		ep := &BalancedEndpoint{
+			Name:     fmt.Sprintf("%s#%d", mc.ModelName, i),
+			Protocol: NormalizeProvider(protocol),
+			Provider: provider,
+			Model:    modelID,
+			Weight:   mc.Weight,
+		}
+		if rlCfg := RateLimitConfigFromModel(mc); !rlCfg.IsZero() {
+			ep.Limiter = NewRateLimiter(rlCfg)
+		}
+		endpoints = append(endpoints, ep)
+	}
+
+	return NewBalancedProvider(cfgs[0].ModelName, strategy, endpoints), endpoints[0].Model, nil
+}
+
+func (p *BalancedProvider) Chat(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (*LLMResponse, error) {
+	var lastErr error
+	for _, ep := range p.order() {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		resp, err := p.chatEndpoint(ctx, ep, messages, tools, p.endpointModel(ep, model), options)
+		if err == nil {
+			return resp, nil
+		}
+		if errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+
+		failErr := ClassifyError(err, ep.Name, ep.Model)
+		if failErr == nil || !failErr.IsRetriable() {
+			return nil, err
+		}
+		p.cooldown.MarkFailure(ep.Name, failErr.Reason)
+		lastErr = err
+	}
+
+	if lastErr == nil {
+		return nil, fmt.Errorf("balancer %q: no endpoints configured", p.alias)
+	}
+	return nil, lastErr
+}
+
+func (p *BalancedProvider) chatEndpoint(
+	ctx context.Context,
+	ep *BalancedEndpoint,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (*LLMResponse, error) {
+	release := func(int) {}
+	if ep.Limiter != nil {
+		var err error
+		release, err = ep.Limiter.Acquire(ctx, EstimateRequestTokens(messages, tools))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	start := time.Now()
+	resp, err := ep.Provider.Chat(ctx, messages, tools, model, options)
+	elapsed := time.Since(start)
+
+	used := 0
+	if resp != nil && resp.Usage != nil {
+		used = resp.Usage.TotalTokens
+	}
+	release(used)
+
+	if err == nil {
+		ep.observeLatency(elapsed)
+		p.cooldown.MarkSuccess(ep.Name)
+	}
+	return resp, err
+}
+
+// endpointModel maps the requested model to the endpoint's own model ID when
+// the request targets this pool; other models are passed through unchanged.
+func (p *BalancedProvider) endpointModel(ep *BalancedEndpoint, requested string) string {
+	requested = strings.TrimSpace(requested)
+	if requested == "" || requested == p.alias {
+		return ep.Model
+	}
+	for _, other := range p.endpoints {
+		if strings.EqualFold(other.Model, requested) {
+			return ep.Model
+		}
+	}
+	return requested
+}
+
+// order returns the endpoints in the order they should be tried: the
+// strategy's preference among healthy endpoints, followed by endpoints in
+// cooldown (soonest available first).
+func (p *BalancedProvider) order() []*BalancedEndpoint {
+	n := len(p.endpoints)
+	if n == 0 {
+		return nil
+	}
+
+	start := int((p.counter.Add(1) - 1) % uint64(n))
+	ordered := make([]*BalancedEndpoint, 0, n)
+	for i := range n {
+		ordered = append(ordered, p.endpoints[(start+i)%n])
+	}
+
+	switch p.strategy {
+	case BalanceWeighted:
+		first := p.pickWeighted()
+		sort.SliceStable(ordered, func(i, j int) bool {
+			if ordered[i] == first {
+				return ordered[j] != first
+			}
+			if ordered[j] == first {
+				return false
+			}
+			return ordered[i].Weight > ordered[j].Weight
+		})
+	case BalanceLeastLatency:
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return ordered[i].Latency() < ordered[j].Latency()
+		})
+	case BalanceLeastErrors:
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return p.cooldown.ErrorCount(ordered[i].Name) < p.cooldown.ErrorCount(ordered[j].Name)
+		})
+	}
+
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return p.cooldownRemaining(ordered[i]) < p.cooldownRemaining(ordered[j])
+	})
+
+	return ordered
+}
+
+// cooldownRemaining returns how long ep is still cooling down, either on its
+// own or because the fallback chain put its provider in cooldown.
+func (p *BalancedProvider) cooldownRemaining(ep *BalancedEndpoint) time.Duration {
+	remaining := p.cooldown.CooldownRemaining(ep.Name)
+	if ep.Protocol != "" {
+		remaining = max(remaining, p.cooldown.CooldownRemaining(ep.Protocol))
+	}
+	return remaining
+}
+
+// pickWeighted implements smooth weighted round-robin (as used by nginx).
+func (p *BalancedProvider) pickWeighted() *BalancedEndpoint {
+	p.weightMu.Lock()
+	defer p.weightMu.Unlock()
+
+	total := 0
+	var best *BalancedEndpoint
+	for _, ep := range p.endpoints {
+		ep.currentWeight += ep.Weight
+		total += ep.Weight
+		if best == nil || ep.currentWeight > best.currentWeight {
+			best = ep
+		}
+	}
+	best.currentWeight -= total
+	return best
+}
+
+func (p *BalancedProvider) GetDefaultModel() string {
+	if len(p.endpoints) == 0 {
+		return ""
+	}
+	return p.endpoints[0].Model
+}
+
+// Close closes every stateful endpoint provider.
+func (p *BalancedProvider) Close() {
+	for _, ep := range p.endpoints {
+		if sp, ok := ep.Provider.(StatefulProvider); ok {
+			sp.Close()
+		}
+	}
+}
+
+// SetCooldownTracker makes the balancer share ct, the tracker of the fallback
+// chain. It must be called before the first request.
+func (p *BalancedProvider) SetCooldownTracker(ct *CooldownTracker) {
+	if ct != nil {
+		p.cooldown = ct
+	}
+}
+
+// ShareCooldownTracker hands ct to the BalancedProvider found by unwrapping
+// p's decorator chain, if any, so balancer and fallback chain see the same
+// endpoint health.
+func ShareCooldownTracker(p LLMProvider, ct *CooldownTracker) {
+	for p != nil {
+		if bp, ok := p.(*BalancedProvider); ok {
+			bp.SetCooldownTracker(ct)
+			return
+		}
+		u, ok := p.(interface{ Unwrap() LLMProvider })
+		if !ok {
+			return
+		}
+		p = u.Unwrap()
+	}
+}
+
+// Endpoints returns the pool members.
+func (p *BalancedProvider) Endpoints() []*BalancedEndpoint {
+	return p.endpoints
+}
+
+// Latency returns the smoothed latency of successful requests (0 if none yet).
+func (ep *BalancedEndpoint) Latency() time.Duration {
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+	return ep.latency
+}
+
+func (ep *BalancedEndpoint) observeLatency(d time.Duration) {
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+	if ep.latency == 0 {
+		ep.latency = d
+		return
+	}
+	ep.latency = time.Duration(latencyEWMAAlpha*float64(d) + (1-latencyEWMAAlpha)*float64(ep.latency))
+}
@@ -0,0 +1,205 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+type scriptedProvider struct {
+	name   string
+	err    error
+	delay  time.Duration
+	calls  int
+	models []string
+}
+
+func (p *scriptedProvider) Chat(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (*LLMResponse, error) {
+	p.calls++
+	p.models = append(p.models, model)
+	if p.delay > 0 {
+		time.Sleep(p.delay)
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &LLMResponse{Content: p.name, FinishReason: "stop"}, nil
+}
+
+func (p *scriptedProvider) GetDefaultModel() string { return p.name }
+
+func newTestEndpoints(providers ...*scriptedProvider) []*BalancedEndpoint {
+	endpoints := make([]*BalancedEndpoint, 0, len(providers))
+	for _, p := range providers {
+		endpoints = append(endpoints, &BalancedEndpoint{Name: p.name, Provider: p, Model: "model-" + p.name})
+	}
+	return endpoints
+}
+
+func TestBalancedProvider_RoundRobin(t *testing.T) {
+	a := &scriptedProvider{name: "a"}
+	b := &scriptedProvider{name: "b"}
+	bp := NewBalancedProvider("pool", BalanceRoundRobin, newTestEndpoints(a, b))
+
+	for range 4 {
+		if _, err := bp.Chat(context.Background(), nil, nil, "pool", nil); err != nil {
+			t.Fatalf("Chat() error: %v", err)
+		}
+	}
+	if a.calls != 2 || b.calls != 2 {
+		t.Errorf("calls a=%d b=%d, want 2/2", a.calls, b.calls)
+	}
+	if a.models[0] != "model-a" || b.models[0] != "model-b" {
+		t.Errorf("models = %v/%v, want endpoint model IDs", a.models, b.models)
+	}
+}
+
+func TestBalancedProvider_Weighted(t *testing.T) {
+	a := &scriptedProvider{name: "a"}
+	b := &scriptedProvider{name: "b"}
+	endpoints := newTestEndpoints(a, b)
+	endpoints[0].Weight = 3
+	endpoints[1].Weight = 1
+	bp := NewBalancedProvider("pool", BalanceWeighted, endpoints)
+
+	for range 8 {
+		if _, err := bp.Chat(context.Background(), nil, nil, "pool", nil); err != nil {
+			t.Fatalf("Chat() error: %v", err)
+		}
+	}
+	if a.calls != 6 || b.calls != 2 {
+		t.Errorf("calls a=%d b=%d, want 6/2", a.calls, b.calls)
+	}
+}
+
+func TestBalancedProvider_LeastLatency(t *testing.T) {
+	slow := &scriptedProvider{name: "slow", delay: 20 * time.Millisecond}
+	fast := &scriptedProvider{name: "fast"}
+	bp := NewBalancedProvider("pool", BalanceLeastLatency, newTestEndpoints(slow, fast))
+
+	// Warm up both endpoints so latencies are known.
+	for range 2 {
+		if _, err := bp.Chat(context.Background(), nil, nil, "pool", nil); err != nil {
+			t.Fatalf("Chat() error: %v", err)
+		}
+	}
+	for range 3 {
+		resp, err := bp.Chat(context.Background(), nil, nil, "pool", nil)
+		if err != nil {
+			t.Fatalf("Chat() error: %v", err)
+		}
+		if resp.Content != "fast" {
+			t.Errorf("response from %q, want fast", resp.Content)
+		}
+	}
+}
+
+func TestBalancedProvider_FailoverAndCooldown(t *testing.T) {
+	bad := &scriptedProvider{name: "bad", err: errors.New("status: 429 rate limit exceeded")}
+	good := &scriptedProvider{name: "good"}
+	bp := NewBalancedProvider("pool", BalanceRoundRobin, newTestEndpoints(bad, good))
+
+	for range 3 {
+		resp, err := bp.Chat(context.Background(), nil, nil, "pool", nil)
+		if err != nil {
+			t.Fatalf("Chat() error: %v", err)
+		}
+		if resp.Content != "good" {
+			t.Errorf("response from %q, want good", resp.Content)
+		}
+	}
+	// After the first failure, "bad" is in cooldown and ordered last.
+	if bad.calls != 1 {
+		t.Errorf("bad calls = %d, want 1", bad.calls)
+	}
+}
+
+func TestBalancedProvider_SharedCooldownTracker(t *testing.T) {
+	a := &scriptedProvider{name: "a", err: errors.New("status: 429 rate limit exceeded")}
+	b := &scriptedProvider{name: "b"}
+	endpoints := newTestEndpoints(a, b)
+	endpoints[0].Protocol, endpoints[1].Protocol = "openai", "groq"
+	bp := NewBalancedProvider("pool", BalanceRoundRobin, endpoints)
+
+	shared := NewCooldownTracker()
+	ShareCooldownTracker(NewRateLimitedProvider(bp, NewRateLimiterRegistry()), shared)
+
+	// Failures seen by the balancer are visible to the fallback chain's tracker.
+	if _, err := bp.Chat(context.Background(), nil, nil, "pool", nil); err != nil {
+		t.Fatalf("Chat() error: %v", err)
+	}
+	if shared.IsAvailable("a") {
+		t.Error("endpoint failure should be recorded in the shared tracker")
+	}
+
+	// A provider cooled down by the fallback chain is tried last by the balancer.
+	a.err = nil
+	shared.MarkSuccess("a")
+	shared.MarkFailure("openai", FailoverRateLimit)
+	for range 2 {
+		if resp, err := bp.Chat(context.Background(), nil, nil, "pool", nil); err != nil || resp.Content != "b" {
+			t.Errorf("Chat() = %v, %v; want the endpoint whose provider is not cooling down", resp, err)
+		}
+	}
+}
+
+func TestBalancedProvider_NonRetriableStops(t *testing.T) {
+	bad := &scriptedProvider{name: "bad", err: errors.New("invalid request format")}
+	good := &scriptedProvider{name: "good"}
+	bp := NewBalancedProvider("pool", BalanceRoundRobin, newTestEndpoints(bad, good))
+
+	if _, err := bp.Chat(context.Background(), nil, nil, "pool", nil); err == nil {
+		t.Fatal("expected error for non-retriable failure")
+	}
+	if good.calls != 0 {
+		t.Errorf("good calls = %d, want 0", good.calls)
+	}
+}
+
+func TestBalancedProvider_PassesThroughOtherModels(t *testing.T) {
+	a := &scriptedProvider{name: "a"}
+	bp := NewBalancedProvider("pool", "", newTestEndpoints(a))
+
+	if _, err := bp.Chat(context.Background(), nil, nil, "other-model", nil); err != nil {
+		t.Fatalf("Chat() error: %v", err)
+	}
+	if a.models[0] != "other-model" {
+		t.Errorf("model = %q, want other-model", a.models[0])
+	}
+}
+
+func TestCreateProvider_MultipleEntriesBalanced(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Agents.Defaults.ModelName = "pool"
+	cfg.ModelList = []config.ModelConfig{
+		{ModelName: "pool", Model: "openai/gpt-4o", APIKey: "k1", LoadBalance: BalanceLeastErrors},
+		{ModelName: "pool", Model: "vllm/qwen", APIBase: "http://localhost:8000/v1", RPM: 60},
+	}
+
+	provider, modelID, err := CreateProvider(cfg)
+	if err != nil {
+		t.Fatalf("CreateProvider() error: %v", err)
+	}
+	bp, ok := provider.(*BalancedProvider)
+	if !ok {
+		t.Fatalf("CreateProvider() returned %T, want *BalancedProvider", provider)
+	}
+	if modelID != "gpt-4o" {
+		t.Errorf("modelID = %q, want gpt-4o", modelID)
+	}
+	if bp.strategy != BalanceLeastErrors {
+		t.Errorf("strategy = %q, want %q", bp.strategy, BalanceLeastErrors)
+	}
+	if len(bp.Endpoints()) != 2 || bp.Endpoints()[1].Limiter == nil {
+		t.Error("second endpoint should have its own rate limiter")
+	}
+}
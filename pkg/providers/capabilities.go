@@ -0,0 +1,253 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// ModelCapabilities describes what a model supports.
+// Zero values mean "unknown" rather than "unsupported" for the numeric fields.
+type ModelCapabilities struct {
+	ContextWindow    int           `json:"context_window,omitempty"`
+	MaxOutputTokens  int           `json:"max_output_tokens,omitempty"`
+	InputModalities  []string      `json:"input_modalities,omitempty"`  // "text", "image", "audio", "video"
+	OutputModalities []string      `json:"output_modalities,omitempty"` // usually just "text"
+	Tools            bool          `json:"tools"`
+	Reasoning        bool          `json:"reasoning"`
+	Pricing          *ModelPricing `json:"pricing,omitempty"`
+	Source           string        `json:"source,omitempty"` // "api", "builtin" or "config"
+}
+
+// ModelPricing holds list prices in USD per million tokens.
+type ModelPricing struct {
+	InputPerMTok  float64 `json:"input_per_mtok"`
+	OutputPerMTok float64 `json:"output_per_mtok"`
+}
+
+// SupportsImages reports whether the model accepts image input.
+func (c ModelCapabilities) SupportsImages() bool {
+	return slices.Contains(c.InputModalities, "image")
+}
+
+// LacksImages reports whether the model is known not to accept image input.
+// A model whose input modalities are unknown is not assumed to lack vision.
+func (c ModelCapabilities) LacksImages() bool {
+	return len(c.InputModalities) > 0 && !c.SupportsImages()
+}
+
+// merge overlays the known fields of other onto c.
+func (c ModelCapabilities) merge(other ModelCapabilities) ModelCapabilities {
+	if other.ContextWindow > 0 {
+		c.ContextWindow = other.ContextWindow
+	}
+	if other.MaxOutputTokens > 0 {
+		c.MaxOutputTokens = other.MaxOutputTokens
+	}
+	if len(other.InputModalities) > 0 {
+		c.InputModalities = other.InputModalities
+	}
+	if len(other.OutputModalities) > 0 {
+		c.OutputModalities = other.OutputModalities
+	}
+	c.Tools = c.Tools || other.Tools
+	c.Reasoning = c.Reasoning || other.Reasoning
+	if other.Pricing != nil {
+		c.Pricing = other.Pricing
+	}
+	if other.Source != "" {
+		c.Source = other.Source
+	}
+	return c
+}
+
+// CapabilityRegistry answers capability queries for protocol/model pairs.
+// Models discovered from provider APIs take precedence over the bundled table;
+// discoveries are persisted to a cache file so lookups work offline.
+type CapabilityRegistry struct {
+	mu         sync.RWMutex
+	discovered map[string]ModelCapabilities // ModelKey(protocol, model) -> caps
+	overrides  map[string]ModelCapabilities // from model_list; never cached
+	fetchedAt  time.Time
+	cachePath  string
+}
+
+// CapabilityCacheTTL is how long discovered capabilities are trusted before
+// they are refreshed from the provider APIs.
+const CapabilityCacheTTL = 24 * time.Hour
+
+type capabilityCache struct {
+	FetchedAt time.Time                    `json:"fetched_at"`
+	Models    map[string]ModelCapabilities `json:"models"`
+}
+
+// DefaultCapabilityCachePath returns ~/.picoclaw/models_cache.json.
+func DefaultCapabilityCachePath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".picoclaw", "models_cache.json")
+}
+
+// NewCapabilityRegistry creates a registry backed by cachePath.
+// An empty cachePath disables persistence.
+func NewCapabilityRegistry(cachePath string) *CapabilityRegistry {
+	return &CapabilityRegistry{
+		discovered: make(map[string]ModelCapabilities),
+		overrides:  make(map[string]ModelCapabilities),
+		cachePath:  cachePath,
+	}
+}
+
+// Lookup returns the capabilities of a model. Discovered entries are layered
+// over the bundled table and model_list overrides over both; ok is false if
+// none of them knows the model. An empty provider matches by model ID only.
+func (r *CapabilityRegistry) Lookup(provider, model string) (ModelCapabilities, bool) {
+	caps, found := lookupBuiltinCapabilities(model)
+
+	if r == nil {
+		return caps, found
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, layer := range []map[string]ModelCapabilities{r.discovered, r.overrides} {
+		if d, ok := lookupLayer(layer, provider, model); ok {
+			caps = caps.merge(d)
+			found = true
+		}
+	}
+	return caps, found
+}
+
+func lookupLayer(layer map[string]ModelCapabilities, provider, model string) (ModelCapabilities, bool) {
+	if provider != "" {
+		d, ok := layer[capabilityKey(provider, model)]
+		return d, ok
+	}
+	id := normalizeCapabilityModel(model)
+	for key, d := range layer {
+		if _, m, _ := strings.Cut(key, "/"); m == id {
+			return d, true
+		}
+	}
+	return ModelCapabilities{}, false
+}
+
+// ApplyConfig registers the context_window (or Ollama num_ctx) set on
+// model_list entries as overrides of discovered and bundled values.
+func (r *CapabilityRegistry) ApplyConfig(cfg *config.Config) {
+	if cfg == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range cfg.ModelList {
+		mc := &cfg.ModelList[i]
+		window := mc.ContextWindow
+		if window == 0 {
+			window = mc.NumCtx
+		}
+		if window <= 0 {
+			continue
+		}
+		protocol, modelID := ExtractProtocol(mc.Model)
+		r.overrides[capabilityKey(protocol, modelID)] = ModelCapabilities{ContextWindow: window, Source: "config"}
+	}
+}
+
+// Set records discovered capabilities for protocol/model.
+func (r *CapabilityRegistry) Set(protocol, model string, caps ModelCapabilities) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.discovered[capabilityKey(protocol, model)] = caps
+}
+
+// Len returns the number of discovered models.
+func (r *CapabilityRegistry) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.discovered)
+}
+
+// Stale reports whether the discovered data is older than maxAge (or absent).
+func (r *CapabilityRegistry) Stale(maxAge time.Duration) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.fetchedAt.IsZero() || time.Since(r.fetchedAt) > maxAge
+}
+
+// LoadCache reads previously discovered capabilities from the cache file.
+// A missing file is not an error.
+func (r *CapabilityRegistry) LoadCache() error {
+	if r.cachePath == "" {
+		return nil
+	}
+	data, err := os.ReadFile(r.cachePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("reading model cache: %w", err)
+	}
+
+	var cache capabilityCache
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return fmt.Errorf("parsing model cache: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for k, v := range cache.Models {
+		r.discovered[k] = v
+	}
+	r.fetchedAt = cache.FetchedAt
+	return nil
+}
+
+// SaveCache writes the discovered capabilities to the cache file.
+func (r *CapabilityRegistry) SaveCache() error {
+	if r.cachePath == "" {
+		return nil
+	}
+
+	r.mu.RLock()
+	data, err := json.MarshalIndent(capabilityCache{FetchedAt: r.fetchedAt, Models: r.discovered}, "", "  ")
+	r.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("encoding model cache: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(r.cachePath), 0o755); err != nil {
+		return fmt.Errorf("creating cache dir: %w", err)
+	}
+	tmp := r.cachePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("writing model cache: %w", err)
+	}
+	return os.Rename(tmp, r.cachePath)
+}
+
+func capabilityKey(protocol, model string) string {
+	return NormalizeProvider(protocol) + "/" + normalizeCapabilityModel(model)
+}
+
+// normalizeCapabilityModel strips Gemini's "models/" resource prefix and
+// lowercases the ID so API and config spellings compare equal.
+func normalizeCapabilityModel(model string) string {
+	model = strings.ToLower(strings.TrimSpace(model))
+	return strings.TrimPrefix(model, "models/")
+}
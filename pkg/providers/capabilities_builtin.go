@@ -0,0 +1,98 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+package providers
+
+import "strings"
+
+var (
+	textOnly   = []string{"text"}
+	textImage  = []string{"text", "image"}
+	multimodal = []string{"text", "image", "audio", "video"}
+)
+
+func price(input, output float64) *ModelPricing {
+	return &ModelPricing{InputPerMTok: input, OutputPerMTok: output}
+}
+
+// builtinCapabilities is the fallback table used when a provider has no
+// /models endpoint (or it is unreachable). Keys are model ID prefixes and are
+// matched on "-", ":" or "@" boundaries; the longest match wins.
+var builtinCapabilities = map[string]ModelCapabilities{
+	// OpenAI
+	"gpt-4o":       {ContextWindow: 128000, MaxOutputTokens: 16384, InputModalities: textImage, Tools: true, Pricing: price(2.5, 10)},
+	"gpt-4o-mini":  {ContextWindow: 128000, MaxOutputTokens: 16384, InputModalities: textImage, Tools: true, Pricing: price(0.15, 0.6)},
+	"gpt-4.1":      {ContextWindow: 1047576, MaxOutputTokens: 32768, InputModalities: textImage, Tools: true, Pricing: price(2, 8)},
+	"gpt-4.1-mini": {ContextWindow: 1047576, MaxOutputTokens: 32768, InputModalities: textImage, Tools: true, Pricing: price(0.4, 1.6)},
+	"gpt-4.1-nano": {ContextWindow: 1047576, MaxOutputTokens: 32768, InputModalities: textImage, Tools: true, Pricing: price(0.1, 0.4)},
+	"gpt-5":        {ContextWindow: 400000, MaxOutputTokens: 128000, InputModalities: textImage, Tools: true, Reasoning: true, Pricing: price(1.25, 10)},
+	"gpt-5-mini":   {ContextWindow: 400000, MaxOutputTokens: 128000, InputModalities: textImage, Tools: true, Reasoning: true, Pricing: price(0.25, 2)},
+	"gpt-5-nano":   {ContextWindow: 400000, MaxOutputTokens: 128000, InputModalities: textImage, Tools: true, Reasoning: true, Pricing: price(0.05, 0.4)},
+	"o3":           {ContextWindow: 200000, MaxOutputTokens: 100000, InputModalities: textImage, Tools: true, Reasoning: true, Pricing: price(2, 8)},
+	"o3-mini":      {ContextWindow: 200000, MaxOutputTokens: 100000, InputModalities: textOnly, Tools: true, Reasoning: true, Pricing: price(1.1, 4.4)},
+	"o4-mini":      {ContextWindow: 200000, MaxOutputTokens: 100000, InputModalities: textImage, Tools: true, Reasoning: true, Pricing: price(1.1, 4.4)},
+
+	// Anthropic
+	"claude-opus-4":     {ContextWindow: 200000, MaxOutputTokens: 32000, InputModalities: textImage, Tools: true, Reasoning: true, Pricing: price(15, 75)},
+	"claude-sonnet-4":   {ContextWindow: 200000, MaxOutputTokens: 64000, InputModalities: textImage, Tools: true, Reasoning: true, Pricing: price(3, 15)},
+	"claude-3-7-sonnet": {ContextWindow: 200000, MaxOutputTokens: 64000, InputModalities: textImage, Tools: true, Reasoning: true, Pricing: price(3, 15)},
+	"claude-3-5-sonnet": {ContextWindow: 200000, MaxOutputTokens: 8192, InputModalities: textImage, Tools: true, Pricing: price(3, 15)},
+	"claude-haiku-4":    {ContextWindow: 200000, MaxOutputTokens: 64000, InputModalities: textImage, Tools: true, Reasoning: true, Pricing: price(1, 5)},
+	"claude-3-5-haiku":  {ContextWindow: 200000, MaxOutputTokens: 8192, InputModalities: textImage, Tools: true, Pricing: price(0.8, 4)},
+
+	// Google
+	"gemini-2.5-pro":        {ContextWindow: 1048576, MaxOutputTokens: 65536, InputModalities: multimodal, Tools: true, Reasoning: true, Pricing: price(1.25, 10)},
+	"gemini-2.5-flash":      {ContextWindow: 1048576, MaxOutputTokens: 65536, InputModalities: multimodal, Tools: true, Reasoning: true, Pricing: price(0.3, 2.5)},
+	"gemini-2.5-flash-lite": {ContextWindow: 1048576, MaxOutputTokens: 65536, InputModalities: multimodal, Tools: true, Reasoning: true, Pricing: price(0.1, 0.4)},
+	"gemini-2.0-flash":      {ContextWindow: 1048576, MaxOutputTokens: 8192, InputModalities: multimodal, Tools: true, Pricing: price(0.1, 0.4)},
+	"gemini-3":              {ContextWindow: 1048576, MaxOutputTokens: 65536, InputModalities: multimodal, Tools: true, Reasoning: true},
+
+	// Open-weight families (served by many providers; pricing varies)
+	"deepseek-chat":     {ContextWindow: 128000, MaxOutputTokens: 8192, InputModalities: textOnly, Tools: true},
+	"deepseek-reasoner": {ContextWindow: 128000, MaxOutputTokens: 65536, InputModalities: textOnly, Reasoning: true},
+	"llama-3.1":         {ContextWindow: 131072, InputModalities: textOnly, Tools: true},
+	"llama-3.3":         {ContextWindow: 131072, InputModalities: textOnly, Tools: true},
+	"llama3":            {ContextWindow: 8192, InputModalities: textOnly},
+	"llama3.1":          {ContextWindow: 131072, InputModalities: textOnly, Tools: true},
+	"llama3.2":          {ContextWindow: 131072, InputModalities: textOnly, Tools: true},
+	"qwen-max":          {ContextWindow: 32768, MaxOutputTokens: 8192, InputModalities: textOnly, Tools: true},
+	"qwen-plus":         {ContextWindow: 131072, MaxOutputTokens: 8192, InputModalities: textOnly, Tools: true},
+	"qwen3":             {ContextWindow: 40960, InputModalities: textOnly, Tools: true, Reasoning: true},
+	"glm-4":             {ContextWindow: 128000, InputModalities: textOnly, Tools: true},
+	"mistral-large":     {ContextWindow: 131072, InputModalities: textOnly, Tools: true},
+	"moonshot-v1-8k":    {ContextWindow: 8192, InputModalities: textOnly, Tools: true},
+	"moonshot-v1-32k":   {ContextWindow: 32768, InputModalities: textOnly, Tools: true},
+	"moonshot-v1-128k":  {ContextWindow: 131072, InputModalities: textOnly, Tools: true},
+}
+
+// lookupBuiltinCapabilities matches the last path segment of model against
+// the bundled table, so "openrouter/anthropic/claude-sonnet-4.6" finds
+// "claude-sonnet-4". Dots and dashes in version numbers compare equal.
+func lookupBuiltinCapabilities(model string) (ModelCapabilities, bool) {
+	id := normalizeCapabilityModel(model)
+	if idx := strings.LastIndex(id, "/"); idx >= 0 {
+		id = id[idx+1:]
+	}
+	id = strings.ReplaceAll(id, ".", "-")
+
+	bestLen := 0
+	var best ModelCapabilities
+	for prefix, caps := range builtinCapabilities {
+		p := strings.ReplaceAll(prefix, ".", "-")
+		if len(p) <= bestLen || !strings.HasPrefix(id, p) {
+			continue
+		}
+		if len(id) > len(p) && !strings.ContainsRune("-:@", rune(id[len(p)])) {
+			continue
+		}
+		bestLen = len(p)
+		best = caps
+	}
+	if bestLen == 0 {
+		return ModelCapabilities{}, false
+	}
+	best.Source = "builtin"
+	return best, true
+}
@@ -0,0 +1,265 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers/gemini"
+	"github.com/sipeed/picoclaw/pkg/providers/ollama"
+)
+
+// Refresh queries the /models endpoint of every distinct endpoint in the
+// model_list and records what it reports. Protocols without a usable
+// endpoint (anthropic, CLI providers, ...) rely on the bundled table.
+// Failures of individual endpoints are collected and returned together;
+// whatever was discovered is kept and saved to the cache.
+func (r *CapabilityRegistry) Refresh(ctx context.Context, cfg *config.Config) error {
+	type endpoint struct{ protocol, apiBase, apiKey string }
+	seen := make(map[endpoint]bool)
+	ollamaModels := make(map[string][]string) // api base -> configured model IDs
+
+	var errs []error
+	discovered := 0
+	for i := range cfg.ModelList {
+		mc := &cfg.ModelList[i]
+		protocol, modelID := ExtractProtocol(mc.Model)
+		apiBase := mc.APIBase
+		if apiBase == "" {
+			apiBase = getDefaultAPIBase(protocol)
+		}
+
+		if protocol == "ollama" {
+			base := ollama.NormalizeAPIBase(apiBase)
+			ollamaModels[base] = append(ollamaModels[base], modelID)
+			continue
+		}
+
+		ep := endpoint{protocol, apiBase, mc.APIKey}
+		if seen[ep] || !hasModelsEndpoint(protocol) || apiBase == "" {
+			continue
+		}
+		seen[ep] = true
+
+		var (
+			models map[string]ModelCapabilities
+			err    error
+		)
+		if protocol == "gemini" {
+			models, err = discoverGeminiModels(ctx, gemini.NormalizeAPIBase(apiBase), mc.APIKey)
+		} else {
+			models, err = discoverOpenAIModels(ctx, apiBase, mc.APIKey)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s (%s): %w", protocol, apiBase, err))
+			continue
+		}
+		for id, caps := range models {
+			r.Set(protocol, id, caps)
+		}
+		discovered += len(models)
+	}
+
+	for base, models := range ollamaModels {
+		client := ollama.NewProvider(base, "")
+		for _, model := range models {
+			info, err := client.Show(ctx, model)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("ollama (%s): %w", base, err))
+				continue
+			}
+			r.Set("ollama", model, ollamaCapabilities(info))
+			discovered++
+		}
+	}
+
+	if discovered > 0 {
+		r.mu.Lock()
+		r.fetchedAt = time.Now()
+		r.mu.Unlock()
+		if err := r.SaveCache(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	logger.DebugCF("provider.capabilities", "Model capabilities refreshed",
+		map[string]any{"models": discovered, "errors": len(errs)})
+
+	return errors.Join(errs...)
+}
+
+// hasModelsEndpoint reports whether a protocol exposes an OpenAI-style or
+// Gemini /models listing that includes useful metadata.
+func hasModelsEndpoint(protocol string) bool {
+	switch protocol {
+	case "openai", "openrouter", "groq", "gemini", "vllm", "deepseek", "mistral",
+		"cerebras", "nvidia", "moonshot", "qwen", "zhipu", "volcengine", "shengsuanyun":
+		return true
+	default:
+		return false
+	}
+}
+
+// openAIModel covers the common /models fields plus the extensions used by
+// OpenRouter (context_length, architecture, pricing, supported_parameters),
+// Groq (context_window) and vLLM (max_model_len).
+type openAIModel struct {
+	ID            string `json:"id"`
+	ContextLength int    `json:"context_length"`
+	ContextWindow int    `json:"context_window"`
+	MaxModelLen   int    `json:"max_model_len"`
+	Architecture  *struct {
+		InputModalities  []string `json:"input_modalities"`
+		OutputModalities []string `json:"output_modalities"`
+	} `json:"architecture"`
+	TopProvider *struct {
+		MaxCompletionTokens int `json:"max_completion_tokens"`
+	} `json:"top_provider"`
+	Pricing *struct {
+		Prompt     string `json:"prompt"`
+		Completion string `json:"completion"`
+	} `json:"pricing"`
+	SupportedParameters []string `json:"supported_parameters"`
+}
+
+func discoverOpenAIModels(ctx context.Context, apiBase, apiKey string) (map[string]ModelCapabilities, error) {
+	headers := map[string]string{}
+	if apiKey != "" {
+		headers["Authorization"] = "Bearer " + apiKey
+	}
+
+	var resp struct {
+		Data []openAIModel `json:"data"`
+	}
+	if err := getModelsJSON(ctx, strings.TrimRight(apiBase, "/")+"/models", headers, &resp); err != nil {
+		return nil, err
+	}
+
+	models := make(map[string]ModelCapabilities, len(resp.Data))
+	for _, m := range resp.Data {
+		if m.ID == "" {
+			continue
+		}
+		caps := ModelCapabilities{
+			ContextWindow: max(m.ContextLength, m.ContextWindow, m.MaxModelLen),
+			Source:        "api",
+		}
+		if m.Architecture != nil {
+			caps.InputModalities = m.Architecture.InputModalities
+			caps.OutputModalities = m.Architecture.OutputModalities
+		}
+		if m.TopProvider != nil {
+			caps.MaxOutputTokens = m.TopProvider.MaxCompletionTokens
+		}
+		if m.Pricing != nil {
+			in, errIn := strconv.ParseFloat(m.Pricing.Prompt, 64)
+			out, errOut := strconv.ParseFloat(m.Pricing.Completion, 64)
+			if errIn == nil && errOut == nil {
+				// OpenRouter quotes USD per token.
+				caps.Pricing = price(in*1e6, out*1e6)
+			}
+		}
+		caps.Tools = slices.Contains(m.SupportedParameters, "tools")
+		caps.Reasoning = slices.Contains(m.SupportedParameters, "reasoning")
+		// Plain OpenAI listings carry only IDs; leave those to the bundled table.
+		if caps.ContextWindow == 0 && caps.Pricing == nil && len(caps.InputModalities) == 0 &&
+			len(m.SupportedParameters) == 0 {
+			continue
+		}
+		models[m.ID] = caps
+	}
+	return models, nil
+}
+
+func discoverGeminiModels(ctx context.Context, apiBase, apiKey string) (map[string]ModelCapabilities, error) {
+	var resp struct {
+		Models []struct {
+			Name                       string   `json:"name"`
+			InputTokenLimit            int      `json:"inputTokenLimit"`
+			OutputTokenLimit           int      `json:"outputTokenLimit"`
+			SupportedGenerationMethods []string `json:"supportedGenerationMethods"`
+			Thinking                   bool     `json:"thinking"`
+		} `json:"models"`
+	}
+	headers := map[string]string{}
+	if apiKey != "" {
+		headers["x-goog-api-key"] = apiKey
+	}
+	if err := getModelsJSON(ctx, apiBase+"/models?pageSize=1000", headers, &resp); err != nil {
+		return nil, err
+	}
+
+	models := make(map[string]ModelCapabilities, len(resp.Models))
+	for _, m := range resp.Models {
+		if !slices.Contains(m.SupportedGenerationMethods, "generateContent") {
+			continue
+		}
+		models[strings.TrimPrefix(m.Name, "models/")] = ModelCapabilities{
+			ContextWindow:   m.InputTokenLimit,
+			MaxOutputTokens: m.OutputTokenLimit,
+			Tools:           true,
+			Reasoning:       m.Thinking,
+			Source:          "api",
+		}
+	}
+	return models, nil
+}
+
+func ollamaCapabilities(info *ollama.ModelInfo) ModelCapabilities {
+	caps := ModelCapabilities{
+		ContextWindow:   info.ContextLength,
+		InputModalities: []string{"text"},
+		Tools:           slices.Contains(info.Capabilities, "tools"),
+		Reasoning:       slices.Contains(info.Capabilities, "thinking"),
+		Source:          "api",
+	}
+	if slices.Contains(info.Capabilities, "vision") {
+		caps.InputModalities = append(caps.InputModalities, "image")
+	}
+	return caps
+}
+
+func getModelsJSON(ctx context.Context, url string, headers map[string]string, out any) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("API request failed:\n  Status: %d\n  Body:   %s", resp.StatusCode, string(body))
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	return nil
+}
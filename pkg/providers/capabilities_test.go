@@ -0,0 +1,198 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestLookupBuiltinCapabilities(t *testing.T) {
+	tests := []struct {
+		model      string
+		wantWindow int
+		wantImages bool
+		wantFound  bool
+	}{
+		{"gpt-4o", 128000, true, true},
+		{"gpt-4o-mini-2024-07-18", 128000, true, true},
+		{"gpt-4.1-mini", 1047576, true, true},
+		{"anthropic/claude-sonnet-4.6", 200000, true, true},
+		{"claude-sonnet-4-20250514", 200000, true, true},
+		{"models/gemini-2.5-flash", 1048576, true, true},
+		{"qwen3:8b", 40960, false, true},
+		{"llama3.1:70b", 131072, false, true},
+		{"gpt-40", 0, false, false}, // prefix must end on a boundary
+		{"totally-unknown", 0, false, false},
+	}
+
+	for _, tt := range tests {
+		caps, ok := lookupBuiltinCapabilities(tt.model)
+		if ok != tt.wantFound {
+			t.Errorf("%s: found = %v, want %v", tt.model, ok, tt.wantFound)
+			continue
+		}
+		if caps.ContextWindow != tt.wantWindow {
+			t.Errorf("%s: ContextWindow = %d, want %d", tt.model, caps.ContextWindow, tt.wantWindow)
+		}
+		if caps.SupportsImages() != tt.wantImages {
+			t.Errorf("%s: SupportsImages = %v, want %v", tt.model, caps.SupportsImages(), tt.wantImages)
+		}
+	}
+
+	// Longest prefix wins: gpt-4o-mini pricing, not gpt-4o.
+	caps, _ := lookupBuiltinCapabilities("gpt-4o-mini")
+	if caps.Pricing == nil || caps.Pricing.InputPerMTok != 0.15 {
+		t.Errorf("gpt-4o-mini pricing = %+v", caps.Pricing)
+	}
+}
+
+func TestCapabilityRegistry_Layers(t *testing.T) {
+	r := NewCapabilityRegistry("")
+	r.Set("openrouter", "anthropic/claude-sonnet-4.6", ModelCapabilities{ContextWindow: 1000000, Source: "api"})
+	r.ApplyConfig(&config.Config{ModelList: []config.ModelConfig{
+		{ModelName: "local", Model: "ollama/qwen3:8b", NumCtx: 16384},
+		{ModelName: "custom", Model: "vllm/my-model", ContextWindow: 32000},
+	}})
+
+	caps, ok := r.Lookup("openrouter", "anthropic/claude-sonnet-4.6")
+	if !ok || caps.ContextWindow != 1000000 || !caps.Tools || caps.Source != "api" {
+		t.Errorf("discovered over builtin = %+v, %v", caps, ok)
+	}
+
+	caps, _ = r.Lookup("ollama", "qwen3:8b")
+	if caps.ContextWindow != 16384 || !caps.Reasoning || caps.Source != "config" {
+		t.Errorf("config override = %+v", caps)
+	}
+
+	caps, ok = r.Lookup("", "my-model")
+	if !ok || caps.ContextWindow != 32000 {
+		t.Errorf("lookup by model ID = %+v, %v", caps, ok)
+	}
+
+	var nilRegistry *CapabilityRegistry
+	if _, ok := nilRegistry.Lookup("openai", "gpt-4o"); !ok {
+		t.Error("nil registry should still consult the bundled table")
+	}
+}
+
+func TestCapabilityRegistry_CacheRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "models_cache.json")
+
+	r := NewCapabilityRegistry(path)
+	r.Set("vllm", "my-model", ModelCapabilities{ContextWindow: 8192, Source: "api"})
+	if err := r.SaveCache(); err != nil {
+		t.Fatalf("SaveCache() error: %v", err)
+	}
+
+	loaded := NewCapabilityRegistry(path)
+	if err := loaded.LoadCache(); err != nil {
+		t.Fatalf("LoadCache() error: %v", err)
+	}
+	if caps, ok := loaded.Lookup("vllm", "my-model"); !ok || caps.ContextWindow != 8192 {
+		t.Errorf("cached caps = %+v, %v", caps, ok)
+	}
+
+	missing := NewCapabilityRegistry(filepath.Join(t.TempDir(), "absent.json"))
+	if err := missing.LoadCache(); err != nil {
+		t.Errorf("LoadCache() on missing file: %v", err)
+	}
+	if !missing.Stale(CapabilityCacheTTL) {
+		t.Error("registry without data should be stale")
+	}
+}
+
+func TestCapabilityRegistry_Refresh(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/or/models":
+			json.NewEncoder(w).Encode(map[string]any{"data": []map[string]any{{
+				"id":             "vendor/vision-model",
+				"context_length": 65536,
+				"architecture": map[string]any{
+					"input_modalities":  []string{"text", "image"},
+					"output_modalities": []string{"text"},
+				},
+				"top_provider":         map[string]any{"max_completion_tokens": 4096},
+				"pricing":              map[string]any{"prompt": "0.000001", "completion": "0.000002"},
+				"supported_parameters": []string{"tools", "temperature"},
+			}, {
+				"id": "bare-id-only",
+			}}})
+		case "/gm/models":
+			if r.Header.Get("x-goog-api-key") != "gkey" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]any{"models": []map[string]any{{
+				"name":                       "models/gemini-next",
+				"inputTokenLimit":            2000000,
+				"outputTokenLimit":           8192,
+				"supportedGenerationMethods": []string{"generateContent"},
+				"thinking":                   true,
+			}, {
+				"name":                       "models/text-embedding-004",
+				"supportedGenerationMethods": []string{"embedContent"},
+			}}})
+		case "/api/show":
+			json.NewEncoder(w).Encode(map[string]any{
+				"capabilities": []string{"completion", "vision"},
+				"model_info":   map[string]any{"gemma3.context_length": 131072},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{ModelList: []config.ModelConfig{
+		{ModelName: "or", Model: "openrouter/vendor/vision-model", APIBase: server.URL + "/or", APIKey: "k"},
+		{ModelName: "gm", Model: "gemini/gemini-next", APIBase: server.URL + "/gm", APIKey: "gkey"},
+		{ModelName: "local", Model: "ollama/gemma3", APIBase: server.URL},
+		{ModelName: "broken", Model: "vllm/x", APIBase: server.URL + "/missing"},
+		{ModelName: "claude", Model: "anthropic/claude-sonnet-4.6", APIKey: "k"},
+	}}
+
+	path := filepath.Join(t.TempDir(), "models_cache.json")
+	r := NewCapabilityRegistry(path)
+	if err := r.Refresh(context.Background(), cfg); err == nil {
+		t.Error("Refresh() should report the failing vllm endpoint")
+	}
+
+	caps, _ := r.Lookup("openrouter", "vendor/vision-model")
+	if caps.ContextWindow != 65536 || caps.MaxOutputTokens != 4096 || !caps.SupportsImages() || !caps.Tools {
+		t.Errorf("openrouter caps = %+v", caps)
+	}
+	if caps.Pricing == nil || caps.Pricing.InputPerMTok != 1 || caps.Pricing.OutputPerMTok != 2 {
+		t.Errorf("openrouter pricing = %+v", caps.Pricing)
+	}
+	if _, ok := r.Lookup("openrouter", "bare-id-only"); ok {
+		t.Error("IDs without metadata should not be recorded")
+	}
+
+	caps, _ = r.Lookup("gemini", "gemini-next")
+	if caps.ContextWindow != 2000000 || !caps.Reasoning {
+		t.Errorf("gemini caps = %+v", caps)
+	}
+	if _, ok := r.Lookup("gemini", "text-embedding-004"); ok {
+		t.Error("non-generative Gemini models should be skipped")
+	}
+
+	caps, _ = r.Lookup("ollama", "gemma3")
+	if caps.ContextWindow != 131072 || !caps.SupportsImages() {
+		t.Errorf("ollama caps = %+v", caps)
+	}
+
+	// Discoveries are persisted for offline use.
+	offline := NewCapabilityRegistry(path)
+	if err := offline.LoadCache(); err != nil {
+		t.Fatalf("LoadCache() error: %v", err)
+	}
+	if offline.Len() != r.Len() || offline.Stale(CapabilityCacheTTL) {
+		t.Errorf("cache has %d models (stale=%v), want %d fresh", offline.Len(), offline.Stale(CapabilityCacheTTL), r.Len())
+	}
+}
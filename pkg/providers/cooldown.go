@@ -1,9 +1,17 @@
 package providers
 
 import (
+	"encoding/json"
+	"fmt"
 	"math"
+	"os"
+	"path/filepath"
+	"sort"
 	"sync"
 	"time"
+
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/logger"
 )
 
 const (
@@ -11,21 +19,55 @@ const (
 )
 
 // CooldownTracker manages per-provider cooldown state for the fallback chain.
-// Thread-safe via sync.RWMutex. In-memory only (resets on restart).
+// Thread-safe via sync.RWMutex. State is in-memory unless the tracker was
+// created with NewPersistentCooldownTracker.
 type CooldownTracker struct {
 	mu            sync.RWMutex
 	entries       map[string]*cooldownEntry
 	failureWindow time.Duration
 	nowFunc       func() time.Time // for testing
+	statePath     string           // empty = in-memory only
 }
 
 type cooldownEntry struct {
+	ErrorCount     int                    `json:"error_count"`
+	FailureCounts  map[FailoverReason]int `json:"failure_counts,omitempty"`
+	CooldownEnd    time.Time              `json:"cooldown_end,omitzero"`     // standard cooldown expiry
+	DisabledUntil  time.Time              `json:"disabled_until,omitzero"`   // billing-specific disable expiry
+	DisabledReason FailoverReason         `json:"disabled_reason,omitempty"` // reason for disable (billing)
+	LastFailure    time.Time              `json:"last_failure,omitzero"`
+	LastReason     FailoverReason         `json:"last_reason,omitempty"`
+}
+
+// ProviderStatus is a point-in-time view of one provider's cooldown state.
+type ProviderStatus struct {
+	Provider       string
+	Available      bool
+	Remaining      time.Duration
 	ErrorCount     int
-	FailureCounts  map[FailoverReason]int
-	CooldownEnd    time.Time      // standard cooldown expiry
-	DisabledUntil  time.Time      // billing-specific disable expiry
-	DisabledReason FailoverReason // reason for disable (billing)
+	DisabledReason FailoverReason
 	LastFailure    time.Time
+	LastReason     FailoverReason
+}
+
+// String renders the status as a single human-readable line.
+func (s ProviderStatus) String() string {
+	line := s.Provider + ": available"
+	if !s.Available {
+		line = fmt.Sprintf("%s: cooling down, %s remaining", s.Provider, s.Remaining.Round(time.Second))
+		if s.DisabledReason != "" {
+			line += fmt.Sprintf(" (disabled: %s)", s.DisabledReason)
+		}
+	}
+	if s.ErrorCount > 0 {
+		line += fmt.Sprintf(", %d error(s), last %s at %s", s.ErrorCount, s.LastReason, s.LastFailure.Format(time.DateTime))
+	}
+	return line
+}
+
+// CooldownStatePath returns the file used to persist cooldowns for a workspace.
+func CooldownStatePath(workspace string) string {
+	return filepath.Join(workspace, "state", "provider_cooldowns.json")
 }
 
 // NewCooldownTracker creates a tracker with default 24h failure window.
@@ -37,6 +79,30 @@ func NewCooldownTracker() *CooldownTracker {
 	}
 }
 
+// NewPersistentCooldownTracker creates a tracker that restores its state from
+// path and writes every change back atomically, so cooldowns survive restarts.
+func NewPersistentCooldownTracker(path string) *CooldownTracker {
+	ct := NewCooldownTracker()
+	ct.statePath = path
+	if err := ct.load(); err != nil {
+		logger.WarnCF("provider.cooldown", "Failed to restore provider cooldowns", map[string]any{
+			"path":  path,
+			"error": err.Error(),
+		})
+	}
+	return ct
+}
+
+// LoadCooldownState reads a persisted cooldown file without taking ownership
+// of it. It is meant for read-only views such as `picoclaw status`.
+func LoadCooldownState(path string) (*CooldownTracker, error) {
+	ct := NewCooldownTracker()
+	ct.statePath = path
+	err := ct.load()
+	ct.statePath = ""
+	return ct, err
+}
+
 // MarkFailure records a failure for a provider and sets appropriate cooldown.
 // Resets error counts if last failure was more than failureWindow ago.
 func (ct *CooldownTracker) MarkFailure(provider string, reason FailoverReason) {
@@ -55,6 +121,7 @@ func (ct *CooldownTracker) MarkFailure(provider string, reason FailoverReason) {
 	entry.ErrorCount++
 	entry.FailureCounts[reason]++
 	entry.LastFailure = now
+	entry.LastReason = reason
 
 	if reason == FailoverBilling {
 		billingCount := entry.FailureCounts[FailoverBilling]
@@ -63,6 +130,8 @@ func (ct *CooldownTracker) MarkFailure(provider string, reason FailoverReason) {
 	} else {
 		entry.CooldownEnd = now.Add(calculateStandardCooldown(entry.ErrorCount))
 	}
+
+	ct.saveLocked()
 }
 
 // MarkSuccess resets all counters and cooldowns for a provider.
@@ -74,12 +143,17 @@ func (ct *CooldownTracker) MarkSuccess(provider string) {
 	if entry == nil {
 		return
 	}
+	changed := entry.ErrorCount > 0 || !entry.CooldownEnd.IsZero() || !entry.DisabledUntil.IsZero()
 
 	entry.ErrorCount = 0
 	entry.FailureCounts = make(map[FailoverReason]int)
 	entry.CooldownEnd = time.Time{}
 	entry.DisabledUntil = time.Time{}
 	entry.DisabledReason = ""
+
+	if changed {
+		ct.saveLocked()
+	}
 }
 
 // IsAvailable returns true if the provider is not in cooldown or disabled.
@@ -162,6 +236,93 @@ func (ct *CooldownTracker) FailureCount(provider string, reason FailoverReason)
 	return entry.FailureCounts[reason]
 }
 
+// Status returns the current cooldown state of a provider.
+func (ct *CooldownTracker) Status(provider string) ProviderStatus {
+	remaining := ct.CooldownRemaining(provider)
+
+	ct.mu.RLock()
+	defer ct.mu.RUnlock()
+
+	status := ProviderStatus{Provider: provider, Available: remaining == 0, Remaining: remaining}
+	if entry := ct.entries[provider]; entry != nil {
+		status.ErrorCount = entry.ErrorCount
+		status.LastFailure = entry.LastFailure
+		status.LastReason = entry.LastReason
+		if remaining > 0 {
+			status.DisabledReason = entry.DisabledReason
+		}
+	}
+	return status
+}
+
+// Providers returns the sorted names of all providers with recorded state.
+func (ct *CooldownTracker) Providers() []string {
+	ct.mu.RLock()
+	defer ct.mu.RUnlock()
+
+	names := make([]string, 0, len(ct.entries))
+	for name := range ct.entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// load restores entries from statePath, dropping those whose cooldowns have
+// expired and whose last failure is outside the failure window.
+// A missing file is not an error.
+func (ct *CooldownTracker) load() error {
+	data, err := os.ReadFile(ct.statePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("reading cooldown state: %w", err)
+	}
+
+	var entries map[string]*cooldownEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("parsing cooldown state: %w", err)
+	}
+
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	now := ct.nowFunc()
+	for provider, entry := range entries {
+		if entry == nil {
+			continue
+		}
+		active := now.Before(entry.CooldownEnd) || now.Before(entry.DisabledUntil)
+		if !active && now.Sub(entry.LastFailure) > ct.failureWindow {
+			continue
+		}
+		if entry.FailureCounts == nil {
+			entry.FailureCounts = make(map[FailoverReason]int)
+		}
+		ct.entries[provider] = entry
+	}
+	return nil
+}
+
+// saveLocked persists all entries. Must be called with the write lock held,
+// which also keeps concurrent saves ordered.
+func (ct *CooldownTracker) saveLocked() {
+	if ct.statePath == "" {
+		return
+	}
+	data, err := json.MarshalIndent(ct.entries, "", "  ")
+	if err == nil {
+		err = fileutil.WriteFileAtomic(ct.statePath, data, 0o600)
+	}
+	if err != nil {
+		logger.WarnCF("provider.cooldown", "Failed to persist provider cooldowns", map[string]any{
+			"path":  ct.statePath,
+			"error": err.Error(),
+		})
+	}
+}
+
 func (ct *CooldownTracker) getOrCreate(provider string) *cooldownEntry {
 	entry := ct.entries[provider]
 	if entry == nil {
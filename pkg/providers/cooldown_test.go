@@ -1,6 +1,7 @@
 package providers
 
 import (
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
@@ -267,3 +268,62 @@ func TestCooldown_MultipleProviders(t *testing.T) {
 		t.Error("groq should be available")
 	}
 }
+
+func TestCooldown_PersistsAcrossRestarts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "provider_cooldowns.json")
+
+	ct := NewPersistentCooldownTracker(path)
+	ct.MarkFailure("anthropic", FailoverBilling)
+	ct.MarkFailure("openai", FailoverRateLimit)
+
+	restored := NewPersistentCooldownTracker(path)
+	if restored.IsAvailable("anthropic") {
+		t.Error("billing cooldown should survive a restart")
+	}
+	if got := restored.CooldownRemaining("anthropic"); got < 4*time.Hour {
+		t.Errorf("restored billing cooldown = %v, want ~5h", got)
+	}
+	if restored.FailureCount("anthropic", FailoverBilling) != 1 {
+		t.Error("failure counts should survive a restart")
+	}
+
+	status := restored.Status("openai")
+	if status.Available || status.ErrorCount != 1 || status.LastReason != FailoverRateLimit {
+		t.Errorf("Status() = %+v", status)
+	}
+
+	restored.MarkSuccess("anthropic")
+	if !NewPersistentCooldownTracker(path).IsAvailable("anthropic") {
+		t.Error("success should be persisted")
+	}
+}
+
+func TestCooldown_LoadDropsStaleEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "provider_cooldowns.json")
+	now := time.Now()
+
+	ct := NewPersistentCooldownTracker(path)
+	ct.nowFunc = func() time.Time { return now.Add(-48 * time.Hour) }
+	ct.MarkFailure("groq", FailoverTimeout)
+	ct.nowFunc = func() time.Time { return now }
+	ct.MarkFailure("openai", FailoverTimeout)
+
+	got := NewPersistentCooldownTracker(path).Providers()
+	if len(got) != 1 || got[0] != "openai" {
+		t.Errorf("Providers() = %v, want [openai]", got)
+	}
+}
+
+func TestProviderStatus_String(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	ct, _ := newTestTracker(now)
+	ct.MarkFailure("anthropic", FailoverBilling)
+
+	want := "anthropic: cooling down, 5h0m0s remaining (disabled: billing), 1 error(s), last billing at 2026-01-02 03:04:05"
+	if got := ct.Status("anthropic").String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if got := ct.Status("openai").String(); got != "openai: available" {
+		t.Errorf("String() = %q", got)
+	}
+}
@@ -8,8 +8,10 @@ package providers
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers/openai_compat"
 )
 
 // createClaudeAuthProvider creates a Claude provider using OAuth credentials from auth store.
@@ -69,9 +71,22 @@ func ExtractProtocol(model string) (protocol, modelID string) {
 	return protocol, modelID
 }
 
+// newHTTPProviderFromConfig creates an OpenAI-compatible provider honouring
+// the per-model max_tokens_field, request_timeout and tool_mode settings.
+func newHTTPProviderFromConfig(cfg *config.ModelConfig, apiBase string) *HTTPProvider {
+	return NewHTTPProviderWithOptions(
+		cfg.APIKey,
+		apiBase,
+		cfg.Proxy,
+		openai_compat.WithMaxTokensField(cfg.MaxTokensField),
+		openai_compat.WithRequestTimeout(time.Duration(cfg.RequestTimeout)*time.Second),
+		openai_compat.WithToolMode(cfg.ToolMode),
+	)
+}
+
 // CreateProviderFromConfig creates a provider based on the ModelConfig.
 // It uses the protocol prefix in the Model field to determine which provider to create.
-// Supported protocols: openai, anthropic, antigravity, claude-cli, codex-cli, github-copilot
+// Supported protocols: openai, anthropic, gemini, ollama, antigravity, claude-cli, codex-cli, github-copilot
 // Returns the provider, the model ID (without protocol prefix), and any error.
 func CreateProviderFromConfig(cfg *config.ModelConfig) (LLMProvider, string, error) {
 	if cfg == nil {
@@ -82,6 +97,26 @@ func CreateProviderFromConfig(cfg *config.ModelConfig) (LLMProvider, string, err
 		return nil, "", fmt.Errorf("model is required")
 	}
 
+	// Several keys for one endpoint are rotated by a key pool
+	if len(cfg.APIKeys) > 0 || cfg.AuthMethod == AuthMethodAPIKeys {
+		keys, err := poolKeys(cfg)
+		if err != nil {
+			return nil, "", err
+		}
+		if len(keys) > 1 {
+			return newKeyPoolProviderFromConfig(cfg, keys)
+		}
+		single := *cfg
+		single.APIKeys = nil
+		if single.AuthMethod == AuthMethodAPIKeys {
+			single.AuthMethod = ""
+		}
+		if len(keys) == 1 {
+			single.APIKey = keys[0]
+		}
+		cfg = &single
+	}
+
 	protocol, modelID := ExtractProtocol(cfg.Model)
 
 	switch protocol {
@@ -102,16 +137,10 @@ func CreateProviderFromConfig(cfg *config.ModelConfig) (LLMProvider, string, err
 		if apiBase == "" {
 			apiBase = getDefaultAPIBase(protocol)
 		}
-		return NewHTTPProviderWithMaxTokensFieldAndRequestTimeout(
-			cfg.APIKey,
-			apiBase,
-			cfg.Proxy,
-			cfg.MaxTokensField,
-			cfg.RequestTimeout,
-		), modelID, nil
+		return newHTTPProviderFromConfig(cfg, apiBase), modelID, nil
 
-	case "openrouter", "groq", "zhipu", "gemini", "nvidia",
-		"ollama", "moonshot", "shengsuanyun", "deepseek", "cerebras",
+	case "openrouter", "groq", "zhipu", "nvidia",
+		"moonshot", "shengsuanyun", "deepseek", "cerebras",
 		"volcengine", "vllm", "qwen", "mistral":
 		// All other OpenAI-compatible HTTP providers
 		if cfg.APIKey == "" && cfg.APIBase == "" {
@@ -121,13 +150,18 @@ func CreateProviderFromConfig(cfg *config.ModelConfig) (LLMProvider, string, err
 		if apiBase == "" {
 			apiBase = getDefaultAPIBase(protocol)
 		}
-		return NewHTTPProviderWithMaxTokensFieldAndRequestTimeout(
-			cfg.APIKey,
-			apiBase,
-			cfg.Proxy,
-			cfg.MaxTokensField,
-			cfg.RequestTimeout,
-		), modelID, nil
+		return newHTTPProviderFromConfig(cfg, apiBase), modelID, nil
+
+	case "ollama":
+		// Native Ollama API; no API key needed for a local daemon
+		return NewOllamaProvider(cfg), modelID, nil
+
+	case "gemini":
+		// Native generateContent API
+		if cfg.APIKey == "" && cfg.APIBase == "" {
+			return nil, "", fmt.Errorf("api_key or api_base is required for HTTP-based protocol %q", protocol)
+		}
+		return NewGeminiProvider(cfg), modelID, nil
 
 	case "anthropic":
 		if cfg.AuthMethod == "oauth" || cfg.AuthMethod == "token" {
@@ -146,13 +180,7 @@ func CreateProviderFromConfig(cfg *config.ModelConfig) (LLMProvider, string, err
 		if cfg.APIKey == "" {
 			return nil, "", fmt.Errorf("api_key is required for anthropic protocol (model: %s)", cfg.Model)
 		}
-		return NewHTTPProviderWithMaxTokensFieldAndRequestTimeout(
-			cfg.APIKey,
-			apiBase,
-			cfg.Proxy,
-			cfg.MaxTokensField,
-			cfg.RequestTimeout,
-		), modelID, nil
+		return newHTTPProviderFromConfig(cfg, apiBase), modelID, nil
 
 	case "antigravity":
 		return NewAntigravityProvider(), modelID, nil
@@ -207,7 +235,7 @@ func getDefaultAPIBase(protocol string) string {
 	case "nvidia":
 		return "https://integrate.api.nvidia.com/v1"
 	case "ollama":
-		return "http://localhost:11434/v1"
+		return "http://localhost:11434"
 	case "moonshot":
 		return "https://api.moonshot.cn/v1"
 	case "shengsuanyun":
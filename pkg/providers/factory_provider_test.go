@@ -111,7 +111,6 @@ func TestCreateProviderFromConfig_DefaultAPIBase(t *testing.T) {
 		{"qwen", "qwen"},
 		{"vllm", "vllm"},
 		{"deepseek", "deepseek"},
-		{"ollama", "ollama"},
 	}
 
 	for _, tt := range tests {
@@ -172,6 +171,51 @@ func TestCreateProviderFromConfig_Antigravity(t *testing.T) {
 	}
 }
 
+func TestCreateProviderFromConfig_Gemini(t *testing.T) {
+	cfg := &config.ModelConfig{
+		ModelName: "test-gemini",
+		Model:     "gemini/gemini-2.5-flash",
+		APIKey:    "test-key",
+	}
+
+	provider, modelID, err := CreateProviderFromConfig(cfg)
+	if err != nil {
+		t.Fatalf("CreateProviderFromConfig() error = %v", err)
+	}
+	if _, ok := provider.(*GeminiProvider); !ok {
+		t.Fatalf("CreateProviderFromConfig() returned %T, want *GeminiProvider", provider)
+	}
+	if modelID != "gemini-2.5-flash" {
+		t.Errorf("modelID = %q, want %q", modelID, "gemini-2.5-flash")
+	}
+
+	cfg.APIKey = ""
+	if _, _, err := CreateProviderFromConfig(cfg); err == nil {
+		t.Error("CreateProviderFromConfig() without api_key or api_base should fail")
+	}
+	cfg.APIBase = "http://gateway.local/v1beta"
+	if _, _, err := CreateProviderFromConfig(cfg); err != nil {
+		t.Errorf("CreateProviderFromConfig() with api_base only: %v", err)
+	}
+}
+
+func TestCreateProviderFromConfig_PromptedToolMode(t *testing.T) {
+	cfg := &config.ModelConfig{
+		ModelName: "test-phi",
+		Model:     "vllm/phi-3-mini",
+		APIBase:   "http://localhost:8000/v1",
+		ToolMode:  "prompted",
+	}
+
+	provider, _, err := CreateProviderFromConfig(cfg)
+	if err != nil {
+		t.Fatalf("CreateProviderFromConfig() error = %v", err)
+	}
+	if _, ok := provider.(*HTTPProvider); !ok {
+		t.Fatalf("CreateProviderFromConfig() returned %T, want *HTTPProvider", provider)
+	}
+}
+
 func TestCreateProviderFromConfig_ClaudeCLI(t *testing.T) {
 	cfg := &config.ModelConfig{
 		ModelName: "test-claude-cli",
@@ -10,6 +10,8 @@ import (
 // FallbackChain orchestrates model fallback across multiple candidates.
 type FallbackChain struct {
 	cooldown *CooldownTracker
+	limiters *RateLimiterRegistry
+	maxWait  time.Duration
 }
 
 // FallbackCandidate represents one model/provider to try.
@@ -41,6 +43,14 @@ func NewFallbackChain(cooldown *CooldownTracker) *FallbackChain {
 	return &FallbackChain{cooldown: cooldown}
 }
 
+// SetRateLimiters makes Execute skip candidates whose rate limiter would block
+// longer than maxWait, as long as another candidate remains to be tried.
+// A maxWait <= 0 disables skipping; requests then simply queue in the limiter.
+func (fc *FallbackChain) SetRateLimiters(limiters *RateLimiterRegistry, maxWait time.Duration) {
+	fc.limiters = limiters
+	fc.maxWait = maxWait
+}
+
 // ResolveCandidates parses model config into a deduplicated candidate list.
 func ResolveCandidates(cfg ModelConfig, defaultProvider string) []FallbackCandidate {
 	return ResolveCandidatesWithLookup(cfg, defaultProvider, nil)
@@ -90,9 +100,13 @@ func ResolveCandidatesWithLookup(
 
 // Execute runs the fallback chain for text/chat requests.
 // It tries each candidate in order, respecting cooldowns and error classification.
+// estimatedTokens is the request's prompt size (see EstimateRequestTokens),
+// used to predict TPM waits; 0 considers only RPM.
 //
 // Behavior:
 //   - Candidates in cooldown are skipped (logged as skipped attempt).
+//   - Candidates whose rate limiter would block longer than maxWait are skipped,
+//     unless they are the last candidate.
 //   - context.Canceled aborts immediately (user abort, no fallback).
 //   - Non-retriable errors (format) abort immediately.
 //   - Retriable errors trigger fallback to next candidate.
@@ -101,6 +115,7 @@ func ResolveCandidatesWithLookup(
 func (fc *FallbackChain) Execute(
 	ctx context.Context,
 	candidates []FallbackCandidate,
+	estimatedTokens int,
 	run func(ctx context.Context, provider, model string) (*LLMResponse, error),
 ) (*FallbackResult, error) {
 	if len(candidates) == 0 {
@@ -134,6 +149,22 @@ func (fc *FallbackChain) Execute(
 			continue
 		}
 
+		// Check rate limiter backlog; the last candidate always queues.
+		if wait := fc.rateLimitWait(candidate, estimatedTokens); wait > fc.maxWait && i < len(candidates)-1 {
+			result.Attempts = append(result.Attempts, FallbackAttempt{
+				Provider: candidate.Provider,
+				Model:    candidate.Model,
+				Skipped:  true,
+				Reason:   FailoverRateLimit,
+				Error: fmt.Errorf(
+					"provider %s rate limited (%s wait)",
+					candidate.Provider,
+					wait.Round(time.Second),
+				),
+			})
+			continue
+		}
+
 		// Execute the run function.
 		start := time.Now()
 		resp, err := run(ctx, candidate.Provider, candidate.Model)
@@ -206,6 +237,20 @@ func (fc *FallbackChain) Execute(
 	return nil, &FallbackExhaustedError{Attempts: result.Attempts}
 }
 
+// rateLimitWait returns the expected limiter delay for a request of
+// estimatedTokens to a candidate, or 0 when rate-limit skipping is disabled
+// or the candidate is unlimited.
+func (fc *FallbackChain) rateLimitWait(candidate FallbackCandidate, estimatedTokens int) time.Duration {
+	if fc.limiters == nil || fc.maxWait <= 0 {
+		return 0
+	}
+	rl := fc.limiters.Lookup(candidate.Provider, candidate.Model)
+	if rl == nil {
+		return 0
+	}
+	return rl.EstimateWait(estimatedTokens)
+}
+
 // ExecuteImage runs the fallback chain for image/vision requests.
 // Simpler than Execute: no cooldown checks (image endpoints have different rate limits).
 // Image dimension/size errors abort immediately (non-retriable).
@@ -292,7 +337,7 @@ func (e *FallbackExhaustedError) Error() string {
 	sb.WriteString(fmt.Sprintf("fallback: all %d candidates failed:", len(e.Attempts)))
 	for i, a := range e.Attempts {
 		if a.Skipped {
-			sb.WriteString(fmt.Sprintf("\n  [%d] %s/%s: skipped (cooldown)", i+1, a.Provider, a.Model))
+			sb.WriteString(fmt.Sprintf("\n  [%d] %s/%s: skipped (%v)", i+1, a.Provider, a.Model, a.Error))
 		} else {
 			sb.WriteString(fmt.Sprintf("\n  [%d] %s/%s: %v (reason=%s, %s)",
 				i+1, a.Provider, a.Model, a.Error, a.Reason, a.Duration.Round(time.Millisecond)))
@@ -22,7 +22,7 @@ func TestFallback_SingleCandidate_Success(t *testing.T) {
 	fc := NewFallbackChain(ct)
 
 	candidates := []FallbackCandidate{makeCandidate("openai", "gpt-4")}
-	result, err := fc.Execute(context.Background(), candidates, successRun("hello"))
+	result, err := fc.Execute(context.Background(), candidates, 0, successRun("hello"))
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -52,7 +52,7 @@ func TestFallback_SecondCandidateSuccess(t *testing.T) {
 		return &LLMResponse{Content: "from claude", FinishReason: "stop"}, nil
 	}
 
-	result, err := fc.Execute(context.Background(), candidates, run)
+	result, err := fc.Execute(context.Background(), candidates, 0, run)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -81,7 +81,7 @@ func TestFallback_AllFail(t *testing.T) {
 		return nil, errors.New("rate limit exceeded")
 	}
 
-	_, err := fc.Execute(context.Background(), candidates, run)
+	_, err := fc.Execute(context.Background(), candidates, 0, run)
 	if err == nil {
 		t.Fatal("expected error when all candidates fail")
 	}
@@ -115,7 +115,7 @@ func TestFallback_ContextCanceled(t *testing.T) {
 		return nil, nil
 	}
 
-	_, err := fc.Execute(ctx, candidates, run)
+	_, err := fc.Execute(ctx, candidates, 0, run)
 	if err != context.Canceled {
 		t.Errorf("expected context.Canceled, got %v", err)
 	}
@@ -136,7 +136,7 @@ func TestFallback_NonRetriableError(t *testing.T) {
 		return nil, errors.New("string should match pattern")
 	}
 
-	_, err := fc.Execute(context.Background(), candidates, run)
+	_, err := fc.Execute(context.Background(), candidates, 0, run)
 	if err == nil {
 		t.Fatal("expected error for non-retriable")
 	}
@@ -172,7 +172,7 @@ func TestFallback_CooldownSkip(t *testing.T) {
 		return &LLMResponse{Content: "claude response", FinishReason: "stop"}, nil
 	}
 
-	result, err := fc.Execute(context.Background(), candidates, run)
+	result, err := fc.Execute(context.Background(), candidates, 0, run)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -204,7 +204,7 @@ func TestFallback_AllInCooldown(t *testing.T) {
 		makeCandidate("anthropic", "claude"),
 	}
 
-	_, err := fc.Execute(context.Background(), candidates,
+	_, err := fc.Execute(context.Background(), candidates, 0,
 		func(ctx context.Context, provider, model string) (*LLMResponse, error) {
 			t.Error("should not call any provider (all in cooldown)")
 			return nil, nil
@@ -223,7 +223,7 @@ func TestFallback_NoCandidates(t *testing.T) {
 	ct := NewCooldownTracker()
 	fc := NewFallbackChain(ct)
 
-	_, err := fc.Execute(context.Background(), nil, successRun("ok"))
+	_, err := fc.Execute(context.Background(), nil, 0, successRun("ok"))
 	if err == nil {
 		t.Error("expected error for empty candidates")
 	}
@@ -235,7 +235,7 @@ func TestFallback_EmptyFallbacks(t *testing.T) {
 	fc := NewFallbackChain(ct)
 
 	candidates := []FallbackCandidate{makeCandidate("openai", "gpt-4")}
-	result, err := fc.Execute(context.Background(), candidates, successRun("ok"))
+	result, err := fc.Execute(context.Background(), candidates, 0, successRun("ok"))
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -259,7 +259,7 @@ func TestFallback_UnclassifiedError(t *testing.T) {
 		return nil, errors.New("completely unknown internal error")
 	}
 
-	_, err := fc.Execute(context.Background(), candidates, run)
+	_, err := fc.Execute(context.Background(), candidates, 0, run)
 	if err == nil {
 		t.Fatal("expected error for unclassified error")
 	}
@@ -283,7 +283,7 @@ func TestFallback_SuccessResetsCooldown(t *testing.T) {
 		return &LLMResponse{Content: "ok", FinishReason: "stop"}, nil
 	}
 
-	_, err := fc.Execute(context.Background(), candidates, run)
+	_, err := fc.Execute(context.Background(), candidates, 0, run)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
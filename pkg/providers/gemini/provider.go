@@ -0,0 +1,184 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+// Package gemini implements the native Google Gemini generateContent API.
+package gemini
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers/protocoltypes"
+)
+
+type (
+	ToolCall               = protocoltypes.ToolCall
+	FunctionCall           = protocoltypes.FunctionCall
+	ExtraContent           = protocoltypes.ExtraContent
+	GoogleExtra            = protocoltypes.GoogleExtra
+	LLMResponse            = protocoltypes.LLMResponse
+	UsageInfo              = protocoltypes.UsageInfo
+	Message                = protocoltypes.Message
+	ToolDefinition         = protocoltypes.ToolDefinition
+	ToolFunctionDefinition = protocoltypes.ToolFunctionDefinition
+)
+
+const (
+	// DefaultAPIBase is the public Generative Language API endpoint.
+	DefaultAPIBase = "https://generativelanguage.googleapis.com/v1beta"
+
+	defaultRequestTimeout = 120 * time.Second
+)
+
+type Provider struct {
+	apiKey         string
+	apiBase        string
+	thinkingBudget *int
+	safetySettings []SafetySetting
+	cachedContent  string
+	httpClient     *http.Client
+}
+
+type Option func(*Provider)
+
+// WithThinkingBudget sets generationConfig.thinkingConfig.thinkingBudget.
+// 0 disables thinking, -1 lets the model decide.
+func WithThinkingBudget(budget *int) Option {
+	return func(p *Provider) {
+		p.thinkingBudget = budget
+	}
+}
+
+// WithSafetySettings sets the block threshold per harm category,
+// e.g. {"HARM_CATEGORY_HARASSMENT": "BLOCK_ONLY_HIGH"}.
+func WithSafetySettings(settings map[string]string) Option {
+	return func(p *Provider) {
+		p.safetySettings = p.safetySettings[:0]
+		for category, threshold := range settings {
+			p.safetySettings = append(p.safetySettings, SafetySetting{Category: category, Threshold: threshold})
+		}
+		sort.Slice(p.safetySettings, func(i, j int) bool {
+			return p.safetySettings[i].Category < p.safetySettings[j].Category
+		})
+	}
+}
+
+// WithCachedContent sets the cachedContents/... resource used as a prompt prefix.
+func WithCachedContent(name string) Option {
+	return func(p *Provider) {
+		p.cachedContent = name
+	}
+}
+
+func WithRequestTimeout(timeout time.Duration) Option {
+	return func(p *Provider) {
+		if timeout > 0 {
+			p.httpClient.Timeout = timeout
+		}
+	}
+}
+
+func NewProvider(apiKey, apiBase, proxy string, opts ...Option) *Provider {
+	client := &http.Client{
+		Timeout: defaultRequestTimeout,
+	}
+
+	if proxy != "" {
+		parsed, err := url.Parse(proxy)
+		if err == nil {
+			client.Transport = &http.Transport{
+				Proxy: http.ProxyURL(parsed),
+			}
+		} else {
+			log.Printf("gemini: invalid proxy URL %q: %v", proxy, err)
+		}
+	}
+
+	p := &Provider{
+		apiKey:     apiKey,
+		apiBase:    NormalizeAPIBase(apiBase),
+		httpClient: client,
+	}
+
+	for _, opt := range opts {
+		if opt != nil {
+			opt(p)
+		}
+	}
+
+	return p
+}
+
+// NormalizeAPIBase strips the OpenAI-compat "/openai" suffix so configs written
+// for the compatibility endpoint keep working with the native API.
+func NormalizeAPIBase(apiBase string) string {
+	apiBase = strings.TrimRight(strings.TrimSpace(apiBase), "/")
+	if apiBase == "" {
+		return DefaultAPIBase
+	}
+	return strings.TrimSuffix(apiBase, "/openai")
+}
+
+func (p *Provider) Chat(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (*LLMResponse, error) {
+	model = strings.TrimPrefix(model, "gemini/")
+	reqBody := p.buildRequest(messages, tools, options)
+
+	data, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/models/%s:generateContent", p.apiBase, url.PathEscape(model))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// Without a key, api_base is a gateway that adds its own auth.
+	if p.apiKey != "" {
+		req.Header.Set("x-goog-api-key", p.apiKey)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API request failed:\n  Status: %d\n  Body:   %s", resp.StatusCode, string(respBody))
+	}
+
+	var apiResp generateContentResponse
+	if err := json.Unmarshal(respBody, &apiResp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	return apiResp.toLLMResponse()
+}
+
+func (p *Provider) GetDefaultModel() string {
+	return ""
+}
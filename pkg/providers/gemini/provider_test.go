@@ -0,0 +1,286 @@
+package gemini
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func init() {
+	newCallID = func(name string, index int) string {
+		return fmt.Sprintf("call_%s_%d", name, index)
+	}
+}
+
+// assertGolden compares got (marshaled as indented JSON) with testdata/<name>.golden.json.
+func assertGolden(t *testing.T, name string, got any) {
+	t.Helper()
+
+	data, err := json.MarshalIndent(got, "", "  ")
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	data = append(data, '\n')
+
+	golden := filepath.Join("testdata", name+".golden.json")
+	if *update {
+		if err := os.WriteFile(golden, data, 0o644); err != nil {
+			t.Fatalf("write golden: %v", err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("read golden (run with -update to create): %v", err)
+	}
+	if !bytes.Equal(data, want) {
+		t.Errorf("%s mismatch\n--- got ---\n%s\n--- want ---\n%s", golden, data, want)
+	}
+}
+
+var weatherTool = ToolDefinition{
+	Type: "function",
+	Function: ToolFunctionDefinition{
+		Name:        "get_weather",
+		Description: "Get the weather for a city",
+		Parameters: map[string]any{
+			"$schema":              "http://json-schema.org/draft-07/schema#",
+			"additionalProperties": false,
+			"properties": map[string]any{
+				"city": map[string]any{"type": "string", "minLength": 1},
+				"days": map[string]any{"type": "integer", "minimum": 1, "maximum": 7},
+			},
+			"required": []any{"city"},
+		},
+	},
+}
+
+func intPtr(v int) *int { return &v }
+
+func TestBuildRequest_Golden(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []Option
+		messages []Message
+		tools    []ToolDefinition
+		options  map[string]any
+	}{
+		{
+			name: "request_basic",
+			messages: []Message{
+				{Role: "system", Content: "You are terse."},
+				{Role: "user", Content: "Hello"},
+			},
+			options: map[string]any{"max_tokens": 1024, "temperature": 0.0},
+		},
+		{
+			name: "request_tools_roundtrip",
+			messages: []Message{
+				{Role: "system", Content: "Use tools."},
+				{Role: "user", Content: "Weather in Paris and Rome?"},
+				{
+					Role: "assistant",
+					ToolCalls: []ToolCall{
+						{
+							ID:   "call_1",
+							Type: "function",
+							Function: &FunctionCall{
+								Name:             "get_weather",
+								Arguments:        `{"city":"Paris"}`,
+								ThoughtSignature: "sig-paris",
+							},
+						},
+						{
+							ID:           "call_2",
+							Name:         "get_weather",
+							Arguments:    map[string]any{"city": "Rome"},
+							ExtraContent: &ExtraContent{Google: &GoogleExtra{ThoughtSignature: "sig-rome"}},
+						},
+					},
+				},
+				{Role: "tool", ToolCallID: "call_1", Content: "18C sunny"},
+				{Role: "tool", ToolCallID: "call_2", Content: "24C clear"},
+				{Role: "tool", ToolCallID: "call_get_time_123", Content: "12:00"},
+			},
+			tools: []ToolDefinition{weatherTool},
+		},
+		{
+			name: "request_images",
+			messages: []Message{
+				{
+					Role:    "user",
+					Content: "What is in these pictures?",
+					Media: []string{
+						"data:image/png;base64,iVBORw0KGgo=",
+						"gs://bucket/photo.jpg",
+						"not-a-url",
+					},
+				},
+			},
+		},
+		{
+			name: "request_thinking_safety",
+			opts: []Option{
+				WithThinkingBudget(intPtr(1024)),
+				WithSafetySettings(map[string]string{
+					"HARM_CATEGORY_HATE_SPEECH": "BLOCK_NONE",
+					"HARM_CATEGORY_HARASSMENT":  "BLOCK_ONLY_HIGH",
+				}),
+			},
+			messages: []Message{{Role: "user", Content: "Think about it."}},
+			options:  map[string]any{"thinking_budget": 2048},
+		},
+		{
+			name: "request_cached_content",
+			opts: []Option{WithCachedContent("cachedContents/abc123"), WithThinkingBudget(intPtr(0))},
+			messages: []Message{
+				{Role: "system", Content: "Cached system prompt."},
+				{Role: "user", Content: "Question about the cached document."},
+			},
+			tools: []ToolDefinition{weatherTool},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewProvider("key", "", "", tt.opts...)
+			assertGolden(t, tt.name, p.buildRequest(tt.messages, tt.tools, tt.options))
+		})
+	}
+}
+
+func TestBuildRequest_ReasoningEffort(t *testing.T) {
+	p := NewProvider("key", "", "", WithThinkingBudget(intPtr(-1)))
+	messages := []Message{{Role: "user", Content: "hi"}}
+
+	req := p.buildRequest(messages, nil, map[string]any{"reasoning_effort": "low"})
+	if tc := req.GenerationConfig.ThinkingConfig; tc == nil || tc.ThinkingBudget != 2048 || !tc.IncludeThoughts {
+		t.Errorf("thinkingConfig = %+v, want budget 2048 with thoughts", tc)
+	}
+
+	req = p.buildRequest(messages, nil, map[string]any{"reasoning_effort": "none"})
+	if tc := req.GenerationConfig.ThinkingConfig; tc == nil || tc.ThinkingBudget != 0 || tc.IncludeThoughts {
+		t.Errorf("thinkingConfig = %+v, want thinking disabled", tc)
+	}
+
+	// An explicit budget wins over the effort level.
+	req = p.buildRequest(messages, nil, map[string]any{"reasoning_effort": "low", "thinking_budget": 512})
+	if tc := req.GenerationConfig.ThinkingConfig; tc == nil || tc.ThinkingBudget != 512 {
+		t.Errorf("thinkingConfig = %+v, want budget 512", tc)
+	}
+}
+
+func TestParseResponse_Golden(t *testing.T) {
+	for _, name := range []string{"response_text", "response_thinking", "response_function_calls", "response_max_tokens"} {
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", name+".json"))
+			if err != nil {
+				t.Fatalf("read input: %v", err)
+			}
+			var apiResp generateContentResponse
+			if err := json.Unmarshal(data, &apiResp); err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+			got, err := apiResp.toLLMResponse()
+			if err != nil {
+				t.Fatalf("toLLMResponse() error: %v", err)
+			}
+			assertGolden(t, name, got)
+		})
+	}
+}
+
+func TestParseResponse_PromptBlocked(t *testing.T) {
+	apiResp := generateContentResponse{PromptFeedback: &promptFeedback{BlockReason: "SAFETY"}}
+	_, err := apiResp.toLLMResponse()
+	if err == nil || !strings.Contains(err.Error(), "SAFETY") {
+		t.Fatalf("error = %v, want prompt blocked error", err)
+	}
+}
+
+func TestProviderChat_Endpoint(t *testing.T) {
+	var gotPath, gotKey string
+	var gotBody map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotKey = r.Header.Get("x-goog-api-key")
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &gotBody)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"candidates":[{"content":{"role":"model","parts":[{"text":"hi"}]},"finishReason":"STOP"}]}`)
+	}))
+	defer server.Close()
+
+	p := NewProvider("secret", server.URL+"/v1beta/openai/", "")
+	resp, err := p.Chat(context.Background(), []Message{{Role: "user", Content: "hello"}}, nil, "gemini/gemini-2.5-flash", nil)
+	if err != nil {
+		t.Fatalf("Chat() error: %v", err)
+	}
+	if gotPath != "/v1beta/models/gemini-2.5-flash:generateContent" {
+		t.Errorf("path = %q", gotPath)
+	}
+	if gotKey != "secret" {
+		t.Errorf("x-goog-api-key = %q, want secret", gotKey)
+	}
+
+	// A gateway adds its own auth; no key is sent.
+	gotKey = "unset"
+	p = NewProvider("", server.URL+"/v1beta", "")
+	if _, err := p.Chat(context.Background(), []Message{{Role: "user", Content: "hello"}}, nil, "gemini-2.5-flash", nil); err != nil {
+		t.Fatalf("Chat() without key: %v", err)
+	}
+	if gotKey != "" {
+		t.Errorf("x-goog-api-key = %q, want none", gotKey)
+	}
+	if _, ok := gotBody["contents"]; !ok {
+		t.Errorf("request body missing contents: %v", gotBody)
+	}
+	if resp.Content != "hi" || resp.FinishReason != "stop" {
+		t.Errorf("resp = %+v", resp)
+	}
+}
+
+func TestProviderChat_HTTPError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(w, `{"error":{"code":429,"status":"RESOURCE_EXHAUSTED"}}`)
+	}))
+	defer server.Close()
+
+	p := NewProvider("secret", server.URL, "")
+	_, err := p.Chat(context.Background(), []Message{{Role: "user", Content: "hello"}}, nil, "gemini-2.5-flash", nil)
+	if err == nil || !strings.Contains(err.Error(), "429") {
+		t.Fatalf("error = %v, want status 429", err)
+	}
+}
+
+func TestSanitizeSchema(t *testing.T) {
+	got := SanitizeSchema(map[string]any{
+		"additionalProperties": false,
+		"properties": map[string]any{
+			"tags": map[string]any{"type": "array", "minItems": 1, "items": map[string]any{"type": "string"}},
+		},
+	})
+	if _, ok := got["additionalProperties"]; ok {
+		t.Error("additionalProperties should be removed")
+	}
+	if got["type"] != "object" {
+		t.Errorf("type = %v, want object", got["type"])
+	}
+	tags := got["properties"].(map[string]any)["tags"].(map[string]any)
+	if _, ok := tags["minItems"]; ok {
+		t.Error("nested minItems should be removed")
+	}
+}
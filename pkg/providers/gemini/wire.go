@@ -0,0 +1,443 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+package gemini
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers/protocoltypes"
+)
+
+type generateContentRequest struct {
+	Contents          []content         `json:"contents"`
+	SystemInstruction *content          `json:"systemInstruction,omitempty"`
+	Tools             []tool            `json:"tools,omitempty"`
+	GenerationConfig  *generationConfig `json:"generationConfig,omitempty"`
+	SafetySettings    []SafetySetting   `json:"safetySettings,omitempty"`
+	CachedContent     string            `json:"cachedContent,omitempty"`
+}
+
+type content struct {
+	Role  string `json:"role,omitempty"`
+	Parts []part `json:"parts"`
+}
+
+type part struct {
+	Text             string            `json:"text,omitempty"`
+	Thought          bool              `json:"thought,omitempty"`
+	ThoughtSignature string            `json:"thoughtSignature,omitempty"`
+	InlineData       *blob             `json:"inlineData,omitempty"`
+	FileData         *fileData         `json:"fileData,omitempty"`
+	FunctionCall     *functionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *functionResponse `json:"functionResponse,omitempty"`
+}
+
+type blob struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+type fileData struct {
+	MimeType string `json:"mimeType,omitempty"`
+	FileURI  string `json:"fileUri"`
+}
+
+type functionCall struct {
+	ID   string         `json:"id,omitempty"`
+	Name string         `json:"name"`
+	Args map[string]any `json:"args"`
+}
+
+type functionResponse struct {
+	ID       string         `json:"id,omitempty"`
+	Name     string         `json:"name"`
+	Response map[string]any `json:"response"`
+}
+
+type tool struct {
+	FunctionDeclarations []functionDeclaration `json:"functionDeclarations"`
+}
+
+type functionDeclaration struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Parameters  map[string]any `json:"parameters,omitempty"`
+}
+
+type generationConfig struct {
+	MaxOutputTokens int             `json:"maxOutputTokens,omitempty"`
+	Temperature     *float64        `json:"temperature,omitempty"`
+	ThinkingConfig  *thinkingConfig `json:"thinkingConfig,omitempty"`
+}
+
+type thinkingConfig struct {
+	ThinkingBudget  int  `json:"thinkingBudget"`
+	IncludeThoughts bool `json:"includeThoughts,omitempty"`
+}
+
+// SafetySetting is one entry of the request's safetySettings.
+type SafetySetting struct {
+	Category  string `json:"category"`
+	Threshold string `json:"threshold"`
+}
+
+type generateContentResponse struct {
+	Candidates     []candidate     `json:"candidates"`
+	PromptFeedback *promptFeedback `json:"promptFeedback,omitempty"`
+	UsageMetadata  *usageMetadata  `json:"usageMetadata,omitempty"`
+}
+
+type candidate struct {
+	Content      content `json:"content"`
+	FinishReason string  `json:"finishReason"`
+}
+
+type promptFeedback struct {
+	BlockReason string `json:"blockReason"`
+}
+
+type usageMetadata struct {
+	PromptTokenCount        int `json:"promptTokenCount"`
+	CandidatesTokenCount    int `json:"candidatesTokenCount"`
+	ThoughtsTokenCount      int `json:"thoughtsTokenCount"`
+	CachedContentTokenCount int `json:"cachedContentTokenCount"`
+	TotalTokenCount         int `json:"totalTokenCount"`
+}
+
+// newCallID generates IDs for function calls the API returned without one.
+// Tests replace it to keep golden files stable.
+var newCallID = func(name string, index int) string {
+	return fmt.Sprintf("call_%s_%d%d", name, time.Now().UnixNano(), index)
+}
+
+// buildRequest translates protocol messages into a generateContent request.
+// System messages become systemInstruction, assistant turns use the "model"
+// role and tool results are sent as functionResponse parts. Consecutive
+// contents with the same role are merged, as the API requires parallel
+// function responses to share a single turn.
+func (p *Provider) buildRequest(
+	messages []Message,
+	tools []ToolDefinition,
+	options map[string]any,
+) generateContentRequest {
+	var req generateContentRequest
+	toolCallNames := make(map[string]string)
+
+	appendContent := func(role string, parts []part) {
+		if len(parts) == 0 {
+			return
+		}
+		if n := len(req.Contents); n > 0 && req.Contents[n-1].Role == role {
+			req.Contents[n-1].Parts = append(req.Contents[n-1].Parts, parts...)
+			return
+		}
+		req.Contents = append(req.Contents, content{Role: role, Parts: parts})
+	}
+
+	var systemParts []part
+	for _, msg := range messages {
+		switch msg.Role {
+		case "system":
+			if msg.Content != "" {
+				systemParts = append(systemParts, part{Text: msg.Content})
+			}
+		case "user":
+			if msg.ToolCallID != "" {
+				appendContent("user", []part{toolResultPart(msg, toolCallNames)})
+				continue
+			}
+			var parts []part
+			if msg.Content != "" {
+				parts = append(parts, part{Text: msg.Content})
+			}
+			parts = append(parts, mediaParts(msg.Media)...)
+			appendContent("user", parts)
+		case "assistant":
+			var parts []part
+			if msg.Content != "" {
+				parts = append(parts, part{Text: msg.Content})
+			}
+			for _, tc := range msg.ToolCalls {
+				name, args, signature := storedToolCall(tc)
+				if name == "" {
+					continue
+				}
+				if tc.ID != "" {
+					toolCallNames[tc.ID] = name
+				}
+				parts = append(parts, part{
+					ThoughtSignature: signature,
+					FunctionCall:     &functionCall{Name: name, Args: args},
+				})
+			}
+			appendContent("model", parts)
+		case "tool":
+			appendContent("user", []part{toolResultPart(msg, toolCallNames)})
+		}
+	}
+
+	cachedContent := p.cachedContent
+	if name, ok := options["cached_content"].(string); ok && name != "" {
+		cachedContent = name
+	}
+	req.CachedContent = cachedContent
+
+	// The system instruction and tools of a cached prompt live in the cache;
+	// the API rejects requests that set them again.
+	if cachedContent == "" {
+		if len(systemParts) > 0 {
+			req.SystemInstruction = &content{Parts: systemParts}
+		}
+		req.Tools = functionTools(tools)
+	}
+
+	cfg := &generationConfig{}
+	if maxTokens, ok := asInt(options["max_tokens"]); ok && maxTokens > 0 {
+		cfg.MaxOutputTokens = maxTokens
+	}
+	if temperature, ok := asFloat(options["temperature"]); ok {
+		cfg.Temperature = &temperature
+	}
+	budget := p.thinkingBudget
+	if v, ok := asInt(options["thinking_budget"]); ok {
+		budget = &v
+	} else if effort, ok := options["reasoning_effort"].(string); ok {
+		if v, ok := protocoltypes.ThinkingBudgetForEffort(effort); ok {
+			budget = &v
+		}
+	}
+	if budget != nil {
+		cfg.ThinkingConfig = &thinkingConfig{
+			ThinkingBudget:  *budget,
+			IncludeThoughts: *budget != 0,
+		}
+	}
+	if cfg.MaxOutputTokens > 0 || cfg.Temperature != nil || cfg.ThinkingConfig != nil {
+		req.GenerationConfig = cfg
+	}
+
+	req.SafetySettings = p.safetySettings
+
+	return req
+}
+
+func functionTools(tools []ToolDefinition) []tool {
+	var decls []functionDeclaration
+	for _, t := range tools {
+		if t.Type != "function" {
+			continue
+		}
+		decls = append(decls, functionDeclaration{
+			Name:        t.Function.Name,
+			Description: t.Function.Description,
+			Parameters:  SanitizeSchema(t.Function.Parameters),
+		})
+	}
+	if len(decls) == 0 {
+		return nil
+	}
+	return []tool{{FunctionDeclarations: decls}}
+}
+
+func toolResultPart(msg Message, toolCallNames map[string]string) part {
+	name := toolCallNames[msg.ToolCallID]
+	if name == "" {
+		name = inferToolName(msg.ToolCallID)
+	}
+	return part{
+		FunctionResponse: &functionResponse{
+			Name:     name,
+			Response: map[string]any{"result": msg.Content},
+		},
+	}
+}
+
+// inferToolName recovers the tool name from IDs of the form "call_<name>_<suffix>".
+func inferToolName(toolCallID string) string {
+	rest, ok := strings.CutPrefix(toolCallID, "call_")
+	if !ok {
+		return toolCallID
+	}
+	if idx := strings.LastIndex(rest, "_"); idx > 0 {
+		return rest[:idx]
+	}
+	return toolCallID
+}
+
+// storedToolCall extracts name, arguments and thought signature from a tool
+// call that may have been produced by any provider.
+func storedToolCall(tc ToolCall) (string, map[string]any, string) {
+	name := tc.Name
+	args := tc.Arguments
+	signature := tc.ThoughtSignature
+
+	if tc.Function != nil {
+		if name == "" {
+			name = tc.Function.Name
+		}
+		if signature == "" {
+			signature = tc.Function.ThoughtSignature
+		}
+		if len(args) == 0 && tc.Function.Arguments != "" {
+			var parsed map[string]any
+			if err := json.Unmarshal([]byte(tc.Function.Arguments), &parsed); err == nil {
+				args = parsed
+			}
+		}
+	}
+	if signature == "" && tc.ExtraContent != nil && tc.ExtraContent.Google != nil {
+		signature = tc.ExtraContent.Google.ThoughtSignature
+	}
+	if args == nil {
+		args = map[string]any{}
+	}
+
+	return name, args, signature
+}
+
+// mediaParts turns data URLs into inlineData parts and remote URIs
+// (https://, gs://) into fileData parts. Anything else is dropped.
+func mediaParts(media []string) []part {
+	var parts []part
+	for _, m := range media {
+		if rest, ok := strings.CutPrefix(m, "data:"); ok {
+			mimeType, data, ok := strings.Cut(rest, ";base64,")
+			if !ok || data == "" {
+				continue
+			}
+			parts = append(parts, part{InlineData: &blob{MimeType: mimeType, Data: data}})
+			continue
+		}
+		if strings.Contains(m, "://") {
+			parts = append(parts, part{FileData: &fileData{
+				MimeType: mime.TypeByExtension(path.Ext(m)),
+				FileURI:  m,
+			}})
+		}
+	}
+	return parts
+}
+
+func (r *generateContentResponse) toLLMResponse() (*LLMResponse, error) {
+	if len(r.Candidates) == 0 {
+		if r.PromptFeedback != nil && r.PromptFeedback.BlockReason != "" {
+			return nil, fmt.Errorf("prompt blocked by Gemini: %s", r.PromptFeedback.BlockReason)
+		}
+		return nil, fmt.Errorf("no candidates in Gemini response")
+	}
+
+	cand := r.Candidates[0]
+	var text, reasoning strings.Builder
+	var toolCalls []ToolCall
+
+	for _, pt := range cand.Content.Parts {
+		switch {
+		case pt.FunctionCall != nil:
+			args := pt.FunctionCall.Args
+			if args == nil {
+				args = map[string]any{}
+			}
+			argsJSON, _ := json.Marshal(args)
+			id := pt.FunctionCall.ID
+			if id == "" {
+				id = newCallID(pt.FunctionCall.Name, len(toolCalls))
+			}
+			tc := ToolCall{
+				ID:               id,
+				Type:             "function",
+				Name:             pt.FunctionCall.Name,
+				Arguments:        args,
+				ThoughtSignature: pt.ThoughtSignature,
+				Function: &FunctionCall{
+					Name:             pt.FunctionCall.Name,
+					Arguments:        string(argsJSON),
+					ThoughtSignature: pt.ThoughtSignature,
+				},
+			}
+			if pt.ThoughtSignature != "" {
+				tc.ExtraContent = &ExtraContent{Google: &GoogleExtra{ThoughtSignature: pt.ThoughtSignature}}
+			}
+			toolCalls = append(toolCalls, tc)
+		case pt.Thought:
+			reasoning.WriteString(pt.Text)
+		default:
+			text.WriteString(pt.Text)
+		}
+	}
+
+	finishReason := mapFinishReason(cand.FinishReason)
+	if len(toolCalls) > 0 {
+		finishReason = "tool_calls"
+	}
+
+	resp := &LLMResponse{
+		Content:          text.String(),
+		ReasoningContent: reasoning.String(),
+		ToolCalls:        toolCalls,
+		FinishReason:     finishReason,
+	}
+	if u := r.UsageMetadata; u != nil {
+		completion := u.CandidatesTokenCount + u.ThoughtsTokenCount
+		total := u.TotalTokenCount
+		if total == 0 {
+			total = u.PromptTokenCount + completion
+		}
+		resp.Usage = &UsageInfo{
+			PromptTokens:     u.PromptTokenCount,
+			CompletionTokens: completion,
+			TotalTokens:      total,
+		}
+	}
+	return resp, nil
+}
+
+func mapFinishReason(reason string) string {
+	switch reason {
+	case "", "STOP":
+		return "stop"
+	case "MAX_TOKENS":
+		return "length"
+	case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII", "IMAGE_SAFETY":
+		return "content_filter"
+	default:
+		return strings.ToLower(reason)
+	}
+}
+
+func asInt(v any) (int, bool) {
+	switch val := v.(type) {
+	case int:
+		return val, true
+	case int64:
+		return int(val), true
+	case float64:
+		return int(val), true
+	case float32:
+		return int(val), true
+	default:
+		return 0, false
+	}
+}
+
+func asFloat(v any) (float64, bool) {
+	switch val := v.(type) {
+	case float64:
+		return val, true
+	case float32:
+		return float64(val), true
+	case int:
+		return float64(val), true
+	case int64:
+		return float64(val), true
+	default:
+		return 0, false
+	}
+}
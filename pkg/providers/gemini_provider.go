@@ -0,0 +1,48 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+package providers
+
+import (
+	"context"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers/gemini"
+)
+
+// GeminiProvider talks to Google Gemini through the native generateContent API.
+type GeminiProvider struct {
+	delegate *gemini.Provider
+}
+
+// NewGeminiProvider creates a Gemini provider from a model_list entry.
+func NewGeminiProvider(cfg *config.ModelConfig) *GeminiProvider {
+	return &GeminiProvider{
+		delegate: gemini.NewProvider(
+			cfg.APIKey,
+			cfg.APIBase,
+			cfg.Proxy,
+			gemini.WithThinkingBudget(cfg.ThinkingBudget),
+			gemini.WithSafetySettings(cfg.SafetySettings),
+			gemini.WithCachedContent(cfg.CachedContent),
+			gemini.WithRequestTimeout(time.Duration(cfg.RequestTimeout)*time.Second),
+		),
+	}
+}
+
+func (p *GeminiProvider) Chat(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (*LLMResponse, error) {
+	return p.delegate.Chat(ctx, messages, tools, model, options)
+}
+
+func (p *GeminiProvider) GetDefaultModel() string {
+	return ""
+}
@@ -0,0 +1,504 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// Rotation strategies for the keys of one model_list entry.
+const (
+	KeyRotationRoundRobin = "round_robin"
+	KeyRotationLeastUsed  = "least_used"
+	KeyRotationFillFirst  = "fill_first"
+)
+
+// AuthMethodAPIKeys makes a model_list entry take its key pool from the
+// api_keys of the auth.json credential named after the entry's protocol.
+const AuthMethodAPIKeys = "api_keys"
+
+// PooledKey is one API key of a KeyPoolProvider together with its usage.
+type PooledKey struct {
+	Label    string // masked key, safe for logs
+	Provider LLMProvider
+
+	requests         atomic.Int64
+	promptTokens     atomic.Int64
+	completionTokens atomic.Int64
+	totalTokens      atomic.Int64
+	failures         atomic.Int64
+}
+
+// KeyUsage is a snapshot of one pooled key's usage and availability.
+type KeyUsage struct {
+	Label            string        `json:"key"`
+	Requests         int64         `json:"requests"`
+	PromptTokens     int64         `json:"prompt_tokens"`
+	CompletionTokens int64         `json:"completion_tokens"`
+	TotalTokens      int64         `json:"total_tokens"`
+	Failures         int64         `json:"failures"`
+	Available        bool          `json:"-"`
+	Remaining        time.Duration `json:"-"`
+	CooldownUntil    time.Time     `json:"cooldown_until,omitzero"`
+}
+
+// String renders the usage as a single human-readable line.
+func (u KeyUsage) String() string {
+	line := fmt.Sprintf("%s: %d request(s), %d tokens (%d prompt, %d completion)",
+		u.Label, u.Requests, u.TotalTokens, u.PromptTokens, u.CompletionTokens)
+	if u.Failures > 0 {
+		line += fmt.Sprintf(", %d failure(s)", u.Failures)
+	}
+	if !u.Available {
+		line += fmt.Sprintf(", cooling down, %s remaining", u.Remaining.Round(time.Second))
+	}
+	return line
+}
+
+// KeyPoolReport is the usage of every key of one pool since Started, as
+// written to KeyUsagePath for `picoclaw status --providers`.
+type KeyPoolReport struct {
+	Pool    string     `json:"pool"`
+	Started time.Time  `json:"started"`
+	Updated time.Time  `json:"updated"`
+	Keys    []KeyUsage `json:"keys"`
+}
+
+// keyUsageSaveInterval limits how often a pool rewrites its usage file, to
+// spare the flash storage of small boards.
+const keyUsageSaveInterval = 30 * time.Second
+
+// keyUsageFileMu orders the read-modify-write of the usage file shared by
+// all pools of a process.
+var keyUsageFileMu sync.Mutex
+
+// KeyUsagePath returns the file key pools report their usage to for a workspace.
+func KeyUsagePath(workspace string) string {
+	return filepath.Join(workspace, "state", "provider_keys.json")
+}
+
+// KeyCooldownPath returns the file key pools persist their key cooldowns to
+// for a workspace, kept apart from the provider cooldowns of the fallback chain.
+func KeyCooldownPath(workspace string) string {
+	return filepath.Join(workspace, "state", "provider_key_cooldowns.json")
+}
+
+// KeyPoolProvider sends requests for one endpoint through a pool of API keys.
+// Rate-limit, billing and auth failures bench only the offending key (using
+// the same cooldown schedule as the fallback chain) and the request is retried
+// with the next key; any other error is returned as is.
+type KeyPoolProvider struct {
+	name     string
+	strategy string
+	keys     []*PooledKey
+	cooldown *CooldownTracker
+	counter  atomic.Uint64
+	started  time.Time
+
+	usagePath string // empty = usage is not written out
+	lastSave  atomic.Int64
+}
+
+// NewKeyPoolProvider creates a pool over keys using strategy.
+// An unknown or empty strategy falls back to round-robin.
+func NewKeyPoolProvider(name, strategy string, keys []*PooledKey) *KeyPoolProvider {
+	switch strategy {
+	case KeyRotationRoundRobin, KeyRotationLeastUsed, KeyRotationFillFirst:
+	default:
+		strategy = KeyRotationRoundRobin
+	}
+	return &KeyPoolProvider{
+		name:     name,
+		strategy: strategy,
+		keys:     keys,
+		cooldown: NewCooldownTracker(),
+		started:  time.Now(),
+	}
+}
+
+// poolKeys returns the deduplicated keys of a model_list entry: api_key first,
+// then api_keys, then (for auth_method "api_keys") the keys stored in auth.json.
+func poolKeys(cfg *config.ModelConfig) ([]string, error) {
+	candidates := append([]string{cfg.APIKey}, cfg.APIKeys...)
+	if cfg.AuthMethod == AuthMethodAPIKeys {
+		protocol, _ := ExtractProtocol(cfg.Model)
+		cred, err := getCredential(protocol)
+		if err != nil {
+			return nil, fmt.Errorf("loading auth credentials: %w", err)
+		}
+		if cred == nil || (cred.APIKey == "" && len(cred.APIKeys) == 0) {
+			return nil, fmt.Errorf("no api_keys for %s in auth.json", protocol)
+		}
+		candidates = append(candidates, cred.APIKey)
+		candidates = append(candidates, cred.APIKeys...)
+	}
+
+	seen := make(map[string]bool)
+	keys := make([]string, 0, len(candidates))
+	for _, k := range candidates {
+		k = strings.TrimSpace(k)
+		if k != "" && !seen[k] {
+			seen[k] = true
+			keys = append(keys, k)
+		}
+	}
+	return keys, nil
+}
+
+// newKeyPoolProviderFromConfig creates one provider per key by re-running
+// CreateProviderFromConfig with a single api_key each.
+func newKeyPoolProviderFromConfig(cfg *config.ModelConfig, keys []string) (*KeyPoolProvider, string, error) {
+	pooled := make([]*PooledKey, 0, len(keys))
+	modelID := ""
+	for i, key := range keys {
+		single := *cfg
+		single.APIKey = key
+		single.APIKeys = nil
+		if single.AuthMethod == AuthMethodAPIKeys {
+			single.AuthMethod = ""
+		}
+
+		provider, id, err := CreateProviderFromConfig(&single)
+		if err != nil {
+			return nil, "", fmt.Errorf("key %d of %q: %w", i, cfg.ModelName, err)
+		}
+		modelID = id
+		pooled = append(pooled, &PooledKey{Label: fmt.Sprintf("key%d(%s)", i+1, maskKey(key)), Provider: provider})
+	}
+	return NewKeyPoolProvider(cfg.ModelName, cfg.KeyRotation, pooled), modelID, nil
+}
+
+func (p *KeyPoolProvider) Chat(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (*LLMResponse, error) {
+	var lastErr error
+	for _, key := range p.order() {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		key.requests.Add(1)
+		resp, err := key.Provider.Chat(ctx, messages, tools, model, options)
+		if err == nil {
+			if resp != nil && resp.Usage != nil {
+				key.promptTokens.Add(int64(resp.Usage.PromptTokens))
+				key.completionTokens.Add(int64(resp.Usage.CompletionTokens))
+				total := resp.Usage.TotalTokens
+				if total == 0 {
+					total = resp.Usage.PromptTokens + resp.Usage.CompletionTokens
+				}
+				key.totalTokens.Add(int64(total))
+			}
+			p.cooldown.MarkSuccess(p.cooldownKey(key))
+			p.saveUsage(false)
+			return resp, nil
+		}
+		if errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+
+		reason := keyFailureReason(err)
+		if reason == "" {
+			p.saveUsage(false)
+			return nil, err
+		}
+		key.failures.Add(1)
+		p.cooldown.MarkFailure(p.cooldownKey(key), reason)
+		logger.WarnCF("provider.keypool", "API key benched", map[string]any{
+			"pool":     p.name,
+			"key":      key.Label,
+			"reason":   string(reason),
+			"cooldown": p.cooldown.CooldownRemaining(p.cooldownKey(key)).String(),
+		})
+		lastErr = err
+	}
+	p.saveUsage(false)
+
+	if lastErr == nil {
+		return nil, fmt.Errorf("key pool %q: no keys configured", p.name)
+	}
+	return nil, lastErr
+}
+
+// contextLengthPatterns match errors about the size of a request, which
+// some providers report in terms that look like quota errors.
+var contextLengthPatterns = []errorPattern{
+	substr("context length"),
+	substr("context_length"),
+	substr("context window"),
+	substr("maximum context"),
+	substr("prompt is too long"),
+	substr("too many tokens"),
+	substr("reduce the length"),
+}
+
+// keyFailureReason returns why err counts against the key that was used: an
+// auth, billing or rate-limit (quota) failure. Errors of the endpoint or the
+// request, such as 400s, oversized prompts, overload and timeouts, return "".
+// A status code in the error decides on its own; message patterns are only
+// consulted when there is none.
+func keyFailureReason(err error) FailoverReason {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return ""
+	}
+	msg := strings.ToLower(err.Error())
+	if status := extractHTTPStatus(msg); status > 0 {
+		switch status {
+		case 401, 403:
+			return FailoverAuth
+		case 402:
+			return FailoverBilling
+		case 429:
+			if matchesAny(msg, contextLengthPatterns) {
+				return ""
+			}
+			return FailoverRateLimit
+		}
+		return ""
+	}
+	if matchesAny(msg, contextLengthPatterns) || matchesAny(msg, timeoutPatterns) {
+		return ""
+	}
+	switch {
+	case matchesAny(msg, rateLimitPatterns):
+		return FailoverRateLimit
+	case matchesAny(msg, billingPatterns):
+		return FailoverBilling
+	case matchesAny(msg, authPatterns):
+		return FailoverAuth
+	}
+	return ""
+}
+
+// order returns the keys in the order they should be tried: the strategy's
+// preference among available keys, followed by benched keys (soonest
+// available first).
+func (p *KeyPoolProvider) order() []*PooledKey {
+	n := len(p.keys)
+	if n == 0 {
+		return nil
+	}
+
+	ordered := make([]*PooledKey, 0, n)
+	switch p.strategy {
+	case KeyRotationFillFirst:
+		ordered = append(ordered, p.keys...)
+	default:
+		start := int((p.counter.Add(1) - 1) % uint64(n))
+		for i := range n {
+			ordered = append(ordered, p.keys[(start+i)%n])
+		}
+	}
+
+	if p.strategy == KeyRotationLeastUsed {
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return ordered[i].totalTokens.Load() < ordered[j].totalTokens.Load()
+		})
+	}
+
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return p.cooldown.CooldownRemaining(p.cooldownKey(ordered[i])) < p.cooldown.CooldownRemaining(p.cooldownKey(ordered[j]))
+	})
+	return ordered
+}
+
+func (p *KeyPoolProvider) GetDefaultModel() string {
+	if len(p.keys) == 0 {
+		return ""
+	}
+	return p.keys[0].Provider.GetDefaultModel()
+}
+
+// Name returns the model_name the pool serves.
+func (p *KeyPoolProvider) Name() string {
+	return p.name
+}
+
+// Usage returns per-key usage counters and availability.
+func (p *KeyPoolProvider) Usage() []KeyUsage {
+	now := time.Now()
+	usage := make([]KeyUsage, 0, len(p.keys))
+	for _, key := range p.keys {
+		remaining := p.cooldown.CooldownRemaining(p.cooldownKey(key))
+		var until time.Time
+		if remaining > 0 {
+			until = now.Add(remaining)
+		}
+		usage = append(usage, KeyUsage{
+			Label:            key.Label,
+			Requests:         key.requests.Load(),
+			PromptTokens:     key.promptTokens.Load(),
+			CompletionTokens: key.completionTokens.Load(),
+			TotalTokens:      key.totalTokens.Load(),
+			Failures:         key.failures.Load(),
+			Available:        remaining == 0,
+			Remaining:        remaining,
+			CooldownUntil:    until,
+		})
+	}
+	return usage
+}
+
+// Report returns the pool's usage report.
+func (p *KeyPoolProvider) Report() KeyPoolReport {
+	return KeyPoolReport{Pool: p.name, Started: p.started, Updated: time.Now(), Keys: p.Usage()}
+}
+
+// SetCooldownTracker makes the pool keep its key cooldowns in ct, e.g. a
+// persistent tracker shared by all pools (see KeyCooldownPath), so benched
+// keys stay benched across restarts. It must be called before the first
+// request.
+func (p *KeyPoolProvider) SetCooldownTracker(ct *CooldownTracker) {
+	if ct != nil {
+		p.cooldown = ct
+	}
+}
+
+// cooldownKey names key in the cooldown tracker, which may be shared with
+// other pools.
+func (p *KeyPoolProvider) cooldownKey(key *PooledKey) string {
+	return p.name + "/" + key.Label
+}
+
+// SetUsagePath makes the pool write its report to path (see KeyUsagePath)
+// at most every keyUsageSaveInterval and when it is closed. Call it before
+// the pool serves requests.
+func (p *KeyPoolProvider) SetUsagePath(path string) {
+	p.usagePath = path
+}
+
+// saveUsage writes the pool's report into the shared usage file, replacing
+// the pool's previous entry. Unless force is set, it does nothing if the
+// last write was less than keyUsageSaveInterval ago.
+func (p *KeyPoolProvider) saveUsage(force bool) {
+	if p.usagePath == "" {
+		return
+	}
+	now := time.Now().UnixNano()
+	last := p.lastSave.Load()
+	if !force && now-last < int64(keyUsageSaveInterval) {
+		return
+	}
+	if !p.lastSave.CompareAndSwap(last, now) {
+		return
+	}
+
+	keyUsageFileMu.Lock()
+	defer keyUsageFileMu.Unlock()
+	reports, _ := LoadKeyUsage(p.usagePath)
+	reports = slices.DeleteFunc(reports, func(r KeyPoolReport) bool { return r.Pool == p.name })
+	reports = append(reports, p.Report())
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Pool < reports[j].Pool })
+
+	data, err := json.MarshalIndent(reports, "", "  ")
+	if err == nil {
+		err = fileutil.WriteFileAtomic(p.usagePath, data, 0o600)
+	}
+	if err != nil {
+		logger.WarnCF("provider.keypool", "Failed to write API key usage", map[string]any{
+			"path":  p.usagePath,
+			"error": err.Error(),
+		})
+	}
+}
+
+// LoadKeyUsage reads the pool reports written to path, with each key's
+// availability computed for now. A missing file yields no reports.
+func LoadKeyUsage(path string) ([]KeyPoolReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading key usage: %w", err)
+	}
+	var reports []KeyPoolReport
+	if err := json.Unmarshal(data, &reports); err != nil {
+		return nil, fmt.Errorf("parsing key usage: %w", err)
+	}
+	now := time.Now()
+	for i := range reports {
+		for j := range reports[i].Keys {
+			u := &reports[i].Keys[j]
+			u.Remaining = max(u.CooldownUntil.Sub(now), 0)
+			u.Available = u.Remaining == 0
+		}
+	}
+	return reports, nil
+}
+
+// KeyPoolsOf returns the key pools found by unwrapping p's decorator chain
+// and the endpoints of a balanced pool.
+func KeyPoolsOf(p LLMProvider) []*KeyPoolProvider {
+	for p != nil {
+		switch v := p.(type) {
+		case *KeyPoolProvider:
+			return []*KeyPoolProvider{v}
+		case *BalancedProvider:
+			var pools []*KeyPoolProvider
+			for _, ep := range v.Endpoints() {
+				pools = append(pools, KeyPoolsOf(ep.Provider)...)
+			}
+			return pools
+		}
+		u, ok := p.(interface{ Unwrap() LLMProvider })
+		if !ok {
+			return nil
+		}
+		p = u.Unwrap()
+	}
+	return nil
+}
+
+// Close logs and writes out per-key usage and closes every stateful key
+// provider.
+func (p *KeyPoolProvider) Close() {
+	p.saveUsage(true)
+	for _, u := range p.Usage() {
+		logger.InfoCF("provider.keypool", "API key usage", map[string]any{
+			"pool":              p.name,
+			"key":               u.Label,
+			"requests":          u.Requests,
+			"prompt_tokens":     u.PromptTokens,
+			"completion_tokens": u.CompletionTokens,
+			"total_tokens":      u.TotalTokens,
+			"failures":          u.Failures,
+		})
+	}
+	for _, key := range p.keys {
+		if sp, ok := key.Provider.(StatefulProvider); ok {
+			sp.Close()
+		}
+	}
+}
+
+// maskKey keeps the first three and last four characters of a key so pool
+// members can be told apart in logs without exposing them.
+func maskKey(key string) string {
+	if len(key) <= 12 {
+		return "…"
+	}
+	return key[:3] + "…" + key[len(key)-4:]
+}
@@ -0,0 +1,264 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/auth"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func newTestKeys(providers ...LLMProvider) []*PooledKey {
+	keys := make([]*PooledKey, 0, len(providers))
+	for i, p := range providers {
+		keys = append(keys, &PooledKey{Label: string(rune('a' + i)), Provider: p})
+	}
+	return keys
+}
+
+func TestKeyPoolProvider_RoundRobin(t *testing.T) {
+	a := &scriptedProvider{name: "a"}
+	b := &scriptedProvider{name: "b"}
+	pool := NewKeyPoolProvider("pool", "", newTestKeys(a, b))
+
+	for range 4 {
+		if _, err := pool.Chat(context.Background(), nil, nil, "m", nil); err != nil {
+			t.Fatalf("Chat() error: %v", err)
+		}
+	}
+	if a.calls != 2 || b.calls != 2 {
+		t.Errorf("calls a=%d b=%d, want 2/2", a.calls, b.calls)
+	}
+}
+
+func TestKeyPoolProvider_BenchesOnlyOffendingKey(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"rate limit", errors.New("status: 429 rate limit exceeded")},
+		{"billing", errors.New("status: 402 insufficient credits")},
+		{"revoked", errors.New("status: 401 invalid api key")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bad := &scriptedProvider{name: "bad", err: tt.err}
+			good := &scriptedProvider{name: "good"}
+			pool := NewKeyPoolProvider("pool", KeyRotationRoundRobin, newTestKeys(bad, good))
+
+			for range 3 {
+				resp, err := pool.Chat(context.Background(), nil, nil, "m", nil)
+				if err != nil {
+					t.Fatalf("Chat() error: %v", err)
+				}
+				if resp.Content != "good" {
+					t.Errorf("response from %q, want good", resp.Content)
+				}
+			}
+			if bad.calls != 1 {
+				t.Errorf("bad calls = %d, want 1 (benched after first failure)", bad.calls)
+			}
+
+			usage := pool.Usage()
+			if usage[0].Available || usage[0].Failures != 1 || !usage[1].Available || usage[1].Requests != 3 {
+				t.Errorf("Usage() = %+v", usage)
+			}
+		})
+	}
+}
+
+func TestKeyPoolProvider_EndpointErrorsAreNotRetried(t *testing.T) {
+	bad := &scriptedProvider{name: "bad", err: errors.New("context deadline exceeded (Client.Timeout exceeded)")}
+	good := &scriptedProvider{name: "good"}
+	pool := NewKeyPoolProvider("pool", KeyRotationFillFirst, newTestKeys(bad, good))
+
+	if _, err := pool.Chat(context.Background(), nil, nil, "m", nil); err == nil {
+		t.Fatal("expected the timeout to be returned")
+	}
+	if good.calls != 0 {
+		t.Errorf("good calls = %d, want 0", good.calls)
+	}
+	if !pool.Usage()[0].Available {
+		t.Error("a timeout must not bench the key")
+	}
+}
+
+func TestKeyFailureReason(t *testing.T) {
+	tests := []struct {
+		err  string
+		want FailoverReason
+	}{
+		{"status: 429 rate limit exceeded", FailoverRateLimit},
+		{"status: 402 insufficient credits", FailoverBilling},
+		{"status: 401 invalid api key", FailoverAuth},
+		{"you exceeded your current quota", FailoverRateLimit},
+		{"status: 400 bad request: token limit of this model reached", ""},
+		{"status: 400 this model's maximum context length is 8192 tokens", ""},
+		{"status: 404 model not found", ""},
+		{"status: 529 overloaded_error", ""},
+		{"anthropic is overloaded", ""},
+		{"request timed out", ""},
+		{"context_length_exceeded: too many tokens", ""},
+	}
+	for _, tt := range tests {
+		if got := keyFailureReason(errors.New(tt.err)); got != tt.want {
+			t.Errorf("keyFailureReason(%q) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestKeyPoolProvider_RequestErrorsDoNotCountAgainstKey(t *testing.T) {
+	bad := &scriptedProvider{name: "bad", err: errors.New("status: 400 maximum context length exceeded")}
+	pool := NewKeyPoolProvider("pool", KeyRotationFillFirst, newTestKeys(bad, &scriptedProvider{name: "good"}))
+	for range 3 {
+		if _, err := pool.Chat(context.Background(), nil, nil, "m", nil); err == nil {
+			t.Fatal("expected the request error to be returned")
+		}
+	}
+	if u := pool.Usage()[0]; !u.Available || u.Failures != 0 || u.Requests != 3 {
+		t.Errorf("Usage() = %+v, want an available key without failures", u)
+	}
+}
+
+func TestKeyPoolProvider_UsageFile(t *testing.T) {
+	path := KeyUsagePath(t.TempDir())
+	bad := &scriptedProvider{name: "bad", err: errors.New("status: 429 rate limit exceeded")}
+	pool := NewKeyPoolProvider("gpt", KeyRotationFillFirst, newTestKeys(bad, &countingProvider{usage: 10}))
+	other := NewKeyPoolProvider("claude", "", newTestKeys(&countingProvider{usage: 5}))
+	pool.SetUsagePath(path)
+	other.SetUsagePath(path)
+
+	if _, err := pool.Chat(context.Background(), nil, nil, "m", nil); err != nil {
+		t.Fatalf("Chat() error: %v", err)
+	}
+	if _, err := other.Chat(context.Background(), nil, nil, "m", nil); err != nil {
+		t.Fatalf("Chat() error: %v", err)
+	}
+
+	reports, err := LoadKeyUsage(path)
+	if err != nil {
+		t.Fatalf("LoadKeyUsage() error: %v", err)
+	}
+	if len(reports) != 2 || reports[0].Pool != "claude" || reports[1].Pool != "gpt" {
+		t.Fatalf("reports = %+v, want claude and gpt", reports)
+	}
+	keys := reports[1].Keys
+	if keys[0].Available || keys[0].Failures != 1 || keys[1].TotalTokens != 10 || !keys[1].Available {
+		t.Errorf("gpt keys = %+v", keys)
+	}
+	if s := keys[0].String(); !strings.Contains(s, "cooling down") {
+		t.Errorf("String() = %q", s)
+	}
+}
+
+func TestKeyPoolProvider_CooldownsSurviveRestart(t *testing.T) {
+	path := KeyCooldownPath(t.TempDir())
+	revoked := errors.New("status: 401 invalid api key")
+	pool := NewKeyPoolProvider("gpt", KeyRotationFillFirst, newTestKeys(
+		&scriptedProvider{name: "bad", err: revoked}, &scriptedProvider{name: "good"}))
+	pool.SetCooldownTracker(NewPersistentCooldownTracker(path))
+	if _, err := pool.Chat(context.Background(), nil, nil, "m", nil); err != nil {
+		t.Fatalf("Chat() error: %v", err)
+	}
+
+	// After a restart the revoked key is still benched.
+	bad := &scriptedProvider{name: "bad", err: revoked}
+	restarted := NewKeyPoolProvider("gpt", KeyRotationFillFirst, newTestKeys(bad, &scriptedProvider{name: "good"}))
+	restarted.SetCooldownTracker(NewPersistentCooldownTracker(path))
+	resp, err := restarted.Chat(context.Background(), nil, nil, "m", nil)
+	if err != nil || resp.Content != "good" || bad.calls != 0 {
+		t.Errorf("Chat() after restart = %+v, %v; benched key called %d times", resp, err, bad.calls)
+	}
+
+	// Pools sharing the tracker keep their keys apart.
+	other := NewKeyPoolProvider("claude", "", newTestKeys(&scriptedProvider{name: "ok"}))
+	other.SetCooldownTracker(NewPersistentCooldownTracker(path))
+	if usage := other.Usage(); !usage[0].Available {
+		t.Errorf("key a of another pool is benched: %+v", usage)
+	}
+}
+
+func TestKeyPoolProvider_FillFirstAndLeastUsed(t *testing.T) {
+	a := &scriptedProvider{name: "a"}
+	b := &scriptedProvider{name: "b"}
+	fill := NewKeyPoolProvider("pool", KeyRotationFillFirst, newTestKeys(a, b))
+	for range 3 {
+		fill.Chat(context.Background(), nil, nil, "m", nil)
+	}
+	if a.calls != 3 || b.calls != 0 {
+		t.Errorf("fill_first calls a=%d b=%d, want 3/0", a.calls, b.calls)
+	}
+
+	heavy := &countingProvider{usage: 1000}
+	light := &countingProvider{usage: 10}
+	least := NewKeyPoolProvider("pool", KeyRotationLeastUsed, newTestKeys(heavy, light))
+	for range 4 {
+		least.Chat(context.Background(), nil, nil, "m", nil)
+	}
+	// After one call each, every request goes to the key with less usage.
+	if heavy.calls.Load() != 1 || light.calls.Load() != 3 {
+		t.Errorf("least_used calls heavy=%d light=%d", heavy.calls.Load(), light.calls.Load())
+	}
+}
+
+func TestCreateProviderFromConfig_KeyPool(t *testing.T) {
+	originalGetCredential := getCredential
+	t.Cleanup(func() { getCredential = originalGetCredential })
+	getCredential = func(provider string) (*auth.AuthCredential, error) {
+		if provider != "openai" {
+			t.Fatalf("provider = %q, want openai", provider)
+		}
+		return &auth.AuthCredential{APIKeys: []string{"sk-stored-key-0001", "sk-config-key-0002"}}, nil
+	}
+
+	provider, modelID, err := CreateProviderFromConfig(&config.ModelConfig{
+		ModelName:   "gpt",
+		Model:       "openai/gpt-4o",
+		APIKey:      "sk-config-key-0001",
+		APIKeys:     []string{"sk-config-key-0002"},
+		AuthMethod:  AuthMethodAPIKeys,
+		KeyRotation: KeyRotationLeastUsed,
+	})
+	if err != nil {
+		t.Fatalf("CreateProviderFromConfig() error = %v", err)
+	}
+	pool, ok := provider.(*KeyPoolProvider)
+	if !ok {
+		t.Fatalf("provider type = %T, want *KeyPoolProvider", provider)
+	}
+	if modelID != "gpt-4o" || pool.strategy != KeyRotationLeastUsed {
+		t.Errorf("modelID = %q, strategy = %q", modelID, pool.strategy)
+	}
+
+	var labels []string
+	for _, u := range pool.Usage() {
+		labels = append(labels, u.Label)
+	}
+	want := []string{"key1(sk-…0001)", "key2(sk-…0002)", "key3(sk-…0001)"}
+	if len(labels) != len(want) {
+		t.Fatalf("labels = %v, want %v (duplicates removed)", labels, want)
+	}
+	for i := range want {
+		if labels[i] != want[i] {
+			t.Errorf("labels = %v, want %v", labels, want)
+			break
+		}
+	}
+}
+
+func TestCreateProviderFromConfig_SingleKeyFromList(t *testing.T) {
+	provider, _, err := CreateProviderFromConfig(&config.ModelConfig{
+		ModelName: "gpt",
+		Model:     "openai/gpt-4o",
+		APIKeys:   []string{"sk-only-key-00001"},
+	})
+	if err != nil {
+		t.Fatalf("CreateProviderFromConfig() error = %v", err)
+	}
+	if _, ok := provider.(*HTTPProvider); !ok {
+		t.Fatalf("provider type = %T, want *HTTPProvider", provider)
+	}
+}
@@ -41,21 +41,40 @@ func CreateProvider(cfg *config.Config) (LLMProvider, string, error) {
 	}
 
 	// Get model config from model_list
-	modelCfg, err := cfg.GetModelConfig(model)
-	if err != nil {
-		return nil, "", fmt.Errorf("model %q not found in model_list: %w", model, err)
+	modelCfgs := cfg.GetModelConfigs(model)
+	if len(modelCfgs) == 0 {
+		return nil, "", fmt.Errorf("model %q not found in model_list", model)
 	}
 
 	// Inject global workspace if not set in model config
-	if modelCfg.Workspace == "" {
-		modelCfg.Workspace = cfg.WorkspacePath()
+	for i := range modelCfgs {
+		if modelCfgs[i].Workspace == "" {
+			modelCfgs[i].Workspace = cfg.WorkspacePath()
+		}
 	}
 
-	// Use factory to create provider
-	provider, modelID, err := CreateProviderFromConfig(modelCfg)
+	// Several entries with the same model_name form a load-balanced pool
+	var provider LLMProvider
+	var modelID string
+	var err error
+	if len(modelCfgs) > 1 {
+		provider, modelID, err = NewBalancedProviderFromConfigs(modelCfgs)
+	} else {
+		provider, modelID, err = CreateProviderFromConfig(&modelCfgs[0])
+	}
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to create provider for model %q: %w", model, err)
 	}
 
+	// Enforce rpm/tpm/max_concurrent from model_list across every caller of this provider
+	if limiters := NewRateLimiterRegistryFromConfig(cfg, model); limiters.Len() > 0 {
+		provider = NewRateLimitedProvider(provider, limiters)
+	}
+
+	// Serve repeated identical requests from the response cache (opt-in)
+	if cfg.ResponseCache.Enabled {
+		provider = NewCachingProvider(provider, NewResponseCacheFromConfig(cfg.ResponseCache))
+	}
+
 	return provider, modelID, nil
 }
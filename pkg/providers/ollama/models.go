@@ -0,0 +1,146 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+package ollama
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ModelSummary is one entry of the local model list (/api/tags).
+type ModelSummary struct {
+	Name       string       `json:"name"`
+	ModifiedAt time.Time    `json:"modified_at"`
+	Size       int64        `json:"size"`
+	Digest     string       `json:"digest"`
+	Details    ModelDetails `json:"details"`
+}
+
+// ModelDetails describes a model's format and quantization.
+type ModelDetails struct {
+	Format            string `json:"format"`
+	Family            string `json:"family"`
+	ParameterSize     string `json:"parameter_size"`
+	QuantizationLevel string `json:"quantization_level"`
+}
+
+// ModelInfo is the subset of /api/show that PicoClaw uses.
+type ModelInfo struct {
+	Details       ModelDetails
+	Capabilities  []string // e.g. "completion", "tools", "vision", "thinking"
+	ContextLength int      // 0 if the daemon did not report it
+}
+
+// PullProgress is one status update streamed by /api/pull.
+type PullProgress struct {
+	Status    string `json:"status"`
+	Digest    string `json:"digest,omitempty"`
+	Total     int64  `json:"total,omitempty"`
+	Completed int64  `json:"completed,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// List returns the models available on the daemon.
+func (p *Provider) List(ctx context.Context) ([]ModelSummary, error) {
+	var resp struct {
+		Models []ModelSummary `json:"models"`
+	}
+	if err := p.doJSON(ctx, http.MethodGet, "/api/tags", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Models, nil
+}
+
+// Show returns details, capabilities and the context length of a model.
+func (p *Provider) Show(ctx context.Context, model string) (*ModelInfo, error) {
+	var resp struct {
+		Details      ModelDetails   `json:"details"`
+		ModelInfo    map[string]any `json:"model_info"`
+		Capabilities []string       `json:"capabilities"`
+	}
+	if err := p.doJSON(ctx, http.MethodPost, "/api/show", map[string]any{"model": model}, &resp); err != nil {
+		return nil, err
+	}
+
+	info := &ModelInfo{
+		Details:      resp.Details,
+		Capabilities: resp.Capabilities,
+	}
+	// Context length is reported under "<architecture>.context_length".
+	for k, v := range resp.ModelInfo {
+		if strings.HasSuffix(k, ".context_length") {
+			if n, ok := asInt(v); ok {
+				info.ContextLength = n
+				break
+			}
+		}
+	}
+	return info, nil
+}
+
+// Delete removes a model from the daemon.
+func (p *Provider) Delete(ctx context.Context, model string) error {
+	return p.doJSON(ctx, http.MethodDelete, "/api/delete", map[string]any{"model": model}, nil)
+}
+
+// Pull downloads a model, calling progress for every streamed status update.
+// The request is not subject to the provider's request timeout; use ctx to bound it.
+func (p *Provider) Pull(ctx context.Context, model string, progress func(PullProgress)) error {
+	data, err := json.Marshal(map[string]any{"model": model, "stream": true})
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.apiBase+"/api/pull", bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := *p.httpClient
+	client.Timeout = 0
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("API request failed:\n  Status: %d\n  Body:   %s", resp.StatusCode, string(body))
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var update PullProgress
+		if err := json.Unmarshal(line, &update); err != nil {
+			return fmt.Errorf("failed to decode pull progress: %w", err)
+		}
+		if update.Error != "" {
+			return fmt.Errorf("pull %s: %s", model, update.Error)
+		}
+		if progress != nil {
+			progress(update)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read pull progress: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,453 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+// Package ollama implements the native Ollama REST API (/api/chat, /api/show,
+// /api/tags, /api/pull, /api/delete).
+package ollama
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers/protocoltypes"
+)
+
+type (
+	ToolCall               = protocoltypes.ToolCall
+	FunctionCall           = protocoltypes.FunctionCall
+	LLMResponse            = protocoltypes.LLMResponse
+	UsageInfo              = protocoltypes.UsageInfo
+	Message                = protocoltypes.Message
+	ToolDefinition         = protocoltypes.ToolDefinition
+	ToolFunctionDefinition = protocoltypes.ToolFunctionDefinition
+)
+
+const (
+	// DefaultAPIBase is the address of a local Ollama daemon.
+	DefaultAPIBase = "http://localhost:11434"
+
+	defaultRequestTimeout = 120 * time.Second
+
+	// maxAutoNumCtx caps the context window taken from /api/show when num_ctx is
+	// not configured, so large-context models don't exhaust RAM on small boards.
+	maxAutoNumCtx = 32768
+)
+
+type Provider struct {
+	apiBase    string
+	keepAlive  string
+	numCtx     int
+	options    map[string]any
+	httpClient *http.Client
+
+	mu       sync.Mutex
+	ctxCache map[string]int // model -> context length discovered via /api/show
+}
+
+type Option func(*Provider)
+
+// WithKeepAlive sets how long the daemon keeps the model loaded (e.g. "5m", "-1").
+func WithKeepAlive(keepAlive string) Option {
+	return func(p *Provider) {
+		p.keepAlive = keepAlive
+	}
+}
+
+// WithNumCtx overrides the context window sent as options.num_ctx.
+func WithNumCtx(numCtx int) Option {
+	return func(p *Provider) {
+		p.numCtx = numCtx
+	}
+}
+
+// WithOptions sets model options (top_k, repeat_penalty, ...) sent with every request.
+func WithOptions(options map[string]any) Option {
+	return func(p *Provider) {
+		p.options = options
+	}
+}
+
+func WithRequestTimeout(timeout time.Duration) Option {
+	return func(p *Provider) {
+		if timeout > 0 {
+			p.httpClient.Timeout = timeout
+		}
+	}
+}
+
+func NewProvider(apiBase, proxy string, opts ...Option) *Provider {
+	client := &http.Client{
+		Timeout: defaultRequestTimeout,
+	}
+
+	if proxy != "" {
+		parsed, err := url.Parse(proxy)
+		if err == nil {
+			client.Transport = &http.Transport{
+				Proxy: http.ProxyURL(parsed),
+			}
+		} else {
+			log.Printf("ollama: invalid proxy URL %q: %v", proxy, err)
+		}
+	}
+
+	p := &Provider{
+		apiBase:    NormalizeAPIBase(apiBase),
+		httpClient: client,
+		ctxCache:   make(map[string]int),
+	}
+
+	for _, opt := range opts {
+		if opt != nil {
+			opt(p)
+		}
+	}
+
+	return p
+}
+
+// NormalizeAPIBase strips the OpenAI-compat "/v1" suffix so configs written
+// for the compatibility shim keep working with the native API.
+func NormalizeAPIBase(apiBase string) string {
+	apiBase = strings.TrimRight(strings.TrimSpace(apiBase), "/")
+	if apiBase == "" {
+		return DefaultAPIBase
+	}
+	return strings.TrimSuffix(apiBase, "/v1")
+}
+
+func (p *Provider) Chat(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (*LLMResponse, error) {
+	model = strings.TrimPrefix(model, "ollama/")
+
+	modelOptions := make(map[string]any, len(p.options)+3)
+	for k, v := range p.options {
+		modelOptions[k] = v
+	}
+	if maxTokens, ok := asInt(options["max_tokens"]); ok {
+		modelOptions["num_predict"] = maxTokens
+	}
+	if temperature, ok := asFloat(options["temperature"]); ok {
+		modelOptions["temperature"] = temperature
+	}
+	if _, ok := modelOptions["num_ctx"]; !ok {
+		if numCtx := p.resolveNumCtx(ctx, model); numCtx > 0 {
+			modelOptions["num_ctx"] = numCtx
+		}
+	}
+
+	reqBody := chatRequest{
+		Model:     model,
+		Messages:  toWireMessages(messages),
+		Tools:     tools,
+		Stream:    false,
+		KeepAlive: p.keepAlive,
+		Think:     thinkOption(model, options),
+	}
+	if len(modelOptions) > 0 {
+		reqBody.Options = modelOptions
+	}
+
+	var apiResp chatResponse
+	if err := p.doJSON(ctx, http.MethodPost, "/api/chat", reqBody, &apiResp); err != nil {
+		return nil, err
+	}
+
+	return apiResp.toLLMResponse(), nil
+}
+
+// thinkOption maps the reasoning options onto Ollama's "think" field.
+// Ollama has no token budget; any positive budget just enables thinking.
+// Only gpt-oss understands effort levels, other models take a boolean.
+func thinkOption(model string, options map[string]any) any {
+	if effort, ok := options["reasoning_effort"].(string); ok && effort != "" {
+		switch {
+		case effort == protocoltypes.ReasoningEffortNone:
+			return false
+		case strings.Contains(model, "gpt-oss") && effort != protocoltypes.ReasoningEffortMinimal:
+			return effort
+		default:
+			return true
+		}
+	}
+	if budget, ok := asInt(options["thinking_budget"]); ok {
+		return budget > 0
+	}
+	return nil
+}
+
+func (p *Provider) GetDefaultModel() string {
+	return ""
+}
+
+// ContextWindow returns the model's context length as reported by /api/show.
+// Results are cached per model; 0 means unknown.
+func (p *Provider) ContextWindow(ctx context.Context, model string) int {
+	model = strings.TrimPrefix(model, "ollama/")
+
+	p.mu.Lock()
+	if n, ok := p.ctxCache[model]; ok {
+		p.mu.Unlock()
+		return n
+	}
+	p.mu.Unlock()
+
+	info, err := p.Show(ctx, model)
+	n := 0
+	if err == nil {
+		n = info.ContextLength
+	} else {
+		log.Printf("ollama: failed to query context window for %q: %v", model, err)
+	}
+
+	p.mu.Lock()
+	p.ctxCache[model] = n
+	p.mu.Unlock()
+	return n
+}
+
+func (p *Provider) resolveNumCtx(ctx context.Context, model string) int {
+	if p.numCtx > 0 {
+		return p.numCtx
+	}
+	if n := p.ContextWindow(ctx, model); n > 0 {
+		return min(n, maxAutoNumCtx)
+	}
+	return 0
+}
+
+func (p *Provider) doJSON(ctx context.Context, method, path string, reqBody, out any) error {
+	var body io.Reader
+	if reqBody != nil {
+		data, err := json.Marshal(reqBody)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, p.apiBase+path, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if reqBody != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("API request failed:\n  Status: %d\n  Body:   %s", resp.StatusCode, string(respBody))
+	}
+
+	if out == nil || len(respBody) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	return nil
+}
+
+type chatRequest struct {
+	Model     string           `json:"model"`
+	Messages  []wireMessage    `json:"messages"`
+	Tools     []ToolDefinition `json:"tools,omitempty"`
+	Stream    bool             `json:"stream"`
+	Options   map[string]any   `json:"options,omitempty"`
+	KeepAlive string           `json:"keep_alive,omitempty"`
+	Think     any              `json:"think,omitempty"` // bool, or "low"/"medium"/"high" for gpt-oss
+}
+
+type wireMessage struct {
+	Role      string         `json:"role"`
+	Content   string         `json:"content"`
+	Thinking  string         `json:"thinking,omitempty"`
+	Images    []string       `json:"images,omitempty"`
+	ToolCalls []wireToolCall `json:"tool_calls,omitempty"`
+	ToolName  string         `json:"tool_name,omitempty"`
+}
+
+type wireToolCall struct {
+	ID       string           `json:"id,omitempty"`
+	Function wireFunctionCall `json:"function"`
+}
+
+type wireFunctionCall struct {
+	Name      string         `json:"name"`
+	Arguments map[string]any `json:"arguments"`
+}
+
+type chatResponse struct {
+	Model           string      `json:"model"`
+	Message         wireMessage `json:"message"`
+	Done            bool        `json:"done"`
+	DoneReason      string      `json:"done_reason"`
+	PromptEvalCount int         `json:"prompt_eval_count"`
+	EvalCount       int         `json:"eval_count"`
+}
+
+// toWireMessages converts protocol messages to Ollama's chat format:
+// images are sent as raw base64, tool call arguments as JSON objects and
+// tool results carry the name of the tool they answer.
+func toWireMessages(messages []Message) []wireMessage {
+	toolNames := make(map[string]string)
+	out := make([]wireMessage, 0, len(messages))
+
+	for _, m := range messages {
+		wm := wireMessage{
+			Role:    m.Role,
+			Content: m.Content,
+		}
+
+		for _, media := range m.Media {
+			if img := stripDataURL(media); img != "" {
+				wm.Images = append(wm.Images, img)
+			}
+		}
+
+		for _, tc := range m.ToolCalls {
+			name := tc.Name
+			args := tc.Arguments
+			if tc.Function != nil {
+				if name == "" {
+					name = tc.Function.Name
+				}
+				if args == nil && tc.Function.Arguments != "" {
+					if err := json.Unmarshal([]byte(tc.Function.Arguments), &args); err != nil {
+						args = map[string]any{"raw": tc.Function.Arguments}
+					}
+				}
+			}
+			if args == nil {
+				args = map[string]any{}
+			}
+			toolNames[tc.ID] = name
+			wm.ToolCalls = append(wm.ToolCalls, wireToolCall{
+				ID:       tc.ID,
+				Function: wireFunctionCall{Name: name, Arguments: args},
+			})
+		}
+
+		if m.Role == "tool" {
+			wm.ToolName = toolNames[m.ToolCallID]
+		}
+
+		out = append(out, wm)
+	}
+
+	return out
+}
+
+func (r *chatResponse) toLLMResponse() *LLMResponse {
+	toolCalls := make([]ToolCall, 0, len(r.Message.ToolCalls))
+	for i, tc := range r.Message.ToolCalls {
+		id := tc.ID
+		if id == "" {
+			id = fmt.Sprintf("call_%d_%d", time.Now().UnixNano(), i)
+		}
+		args := tc.Function.Arguments
+		if args == nil {
+			args = map[string]any{}
+		}
+		argsJSON, _ := json.Marshal(args)
+		toolCalls = append(toolCalls, ToolCall{
+			ID:        id,
+			Type:      "function",
+			Name:      tc.Function.Name,
+			Arguments: args,
+			Function: &FunctionCall{
+				Name:      tc.Function.Name,
+				Arguments: string(argsJSON),
+			},
+		})
+	}
+
+	finishReason := r.DoneReason
+	if finishReason == "" {
+		finishReason = "stop"
+	}
+	if len(toolCalls) > 0 {
+		finishReason = "tool_calls"
+	}
+
+	return &LLMResponse{
+		Content:          r.Message.Content,
+		ReasoningContent: r.Message.Thinking,
+		ToolCalls:        toolCalls,
+		FinishReason:     finishReason,
+		Usage: &UsageInfo{
+			PromptTokens:     r.PromptEvalCount,
+			CompletionTokens: r.EvalCount,
+			TotalTokens:      r.PromptEvalCount + r.EvalCount,
+		},
+	}
+}
+
+// stripDataURL turns "data:image/png;base64,AAAA" into "AAAA".
+// Plain base64 strings are returned unchanged; non-data URLs are dropped
+// because Ollama only accepts inline image data.
+func stripDataURL(media string) string {
+	if !strings.Contains(media, "://") && !strings.HasPrefix(media, "data:") {
+		return media
+	}
+	if _, data, ok := strings.Cut(media, ";base64,"); ok && strings.HasPrefix(media, "data:") {
+		return data
+	}
+	return ""
+}
+
+func asInt(v any) (int, bool) {
+	switch val := v.(type) {
+	case int:
+		return val, true
+	case int64:
+		return int(val), true
+	case float64:
+		return int(val), true
+	case float32:
+		return int(val), true
+	default:
+		return 0, false
+	}
+}
+
+func asFloat(v any) (float64, bool) {
+	switch val := v.(type) {
+	case float64:
+		return val, true
+	case float32:
+		return float64(val), true
+	case int:
+		return float64(val), true
+	case int64:
+		return float64(val), true
+	default:
+		return 0, false
+	}
+}
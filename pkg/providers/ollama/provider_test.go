@@ -0,0 +1,297 @@
+package ollama
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestDaemon returns an httptest stand-in for the Ollama daemon. chat is
+// invoked for /api/chat with the decoded request body.
+func newTestDaemon(t *testing.T, contextLength int, chat func(req map[string]any) map[string]any) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/show":
+			json.NewEncoder(w).Encode(map[string]any{
+				"details":      map[string]any{"family": "llama", "quantization_level": "Q4_K_M"},
+				"model_info":   map[string]any{"general.architecture": "llama", "llama.context_length": contextLength},
+				"capabilities": []string{"completion", "tools", "vision"},
+			})
+		case "/api/chat":
+			var req map[string]any
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			json.NewEncoder(w).Encode(chat(req))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestNormalizeAPIBase(t *testing.T) {
+	tests := map[string]string{
+		"":                           DefaultAPIBase,
+		"http://localhost:11434/v1":  "http://localhost:11434",
+		"http://localhost:11434/v1/": "http://localhost:11434",
+		"http://box:11434":           "http://box:11434",
+	}
+	for in, want := range tests {
+		if got := NormalizeAPIBase(in); got != want {
+			t.Errorf("NormalizeAPIBase(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestProviderChat_RequestShape(t *testing.T) {
+	var got map[string]any
+	server := newTestDaemon(t, 8192, func(req map[string]any) map[string]any {
+		got = req
+		return map[string]any{
+			"message":           map[string]any{"role": "assistant", "content": "hi there"},
+			"done":              true,
+			"done_reason":       "stop",
+			"prompt_eval_count": 10,
+			"eval_count":        5,
+		}
+	})
+	defer server.Close()
+
+	p := NewProvider(server.URL+"/v1", "", WithKeepAlive("10m"), WithOptions(map[string]any{"top_k": 20}))
+	resp, err := p.Chat(t.Context(), []Message{
+		{Role: "system", Content: "be brief"},
+		{Role: "user", Content: "what is this?", Media: []string{"data:image/png;base64,AAAA"}},
+	}, nil, "ollama/llama3", map[string]any{"max_tokens": 256, "temperature": 0.2})
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+
+	if got["model"] != "llama3" || got["stream"] != false || got["keep_alive"] != "10m" {
+		t.Errorf("unexpected request header fields: %v", got)
+	}
+	opts := got["options"].(map[string]any)
+	if opts["num_predict"] != float64(256) || opts["temperature"] != 0.2 || opts["top_k"] != float64(20) {
+		t.Errorf("options = %v", opts)
+	}
+	if opts["num_ctx"] != float64(8192) {
+		t.Errorf("num_ctx = %v, want 8192 from /api/show", opts["num_ctx"])
+	}
+	msgs := got["messages"].([]any)
+	images := msgs[1].(map[string]any)["images"].([]any)
+	if len(images) != 1 || images[0] != "AAAA" {
+		t.Errorf("images = %v, want [AAAA]", images)
+	}
+
+	if _, ok := got["think"]; ok {
+		t.Errorf("think should be omitted without reasoning options, got %v", got["think"])
+	}
+
+	if resp.Content != "hi there" || resp.FinishReason != "stop" {
+		t.Errorf("response = %+v", resp)
+	}
+	if resp.Usage == nil || resp.Usage.TotalTokens != 15 {
+		t.Errorf("usage = %+v, want total 15", resp.Usage)
+	}
+}
+
+func TestThinkOption(t *testing.T) {
+	tests := []struct {
+		model   string
+		options map[string]any
+		want    any
+	}{
+		{"qwen3", nil, nil},
+		{"qwen3", map[string]any{"reasoning_effort": "high"}, true},
+		{"qwen3", map[string]any{"reasoning_effort": "none"}, false},
+		{"gpt-oss:20b", map[string]any{"reasoning_effort": "low"}, "low"},
+		{"gpt-oss:20b", map[string]any{"reasoning_effort": "minimal"}, true},
+		{"deepseek-r1", map[string]any{"thinking_budget": 1024}, true},
+		{"deepseek-r1", map[string]any{"thinking_budget": 0}, false},
+	}
+	for _, tt := range tests {
+		if got := thinkOption(tt.model, tt.options); got != tt.want {
+			t.Errorf("thinkOption(%q, %v) = %v, want %v", tt.model, tt.options, got, tt.want)
+		}
+	}
+}
+
+func TestProviderChat_ToolRoundTrip(t *testing.T) {
+	var got map[string]any
+	server := newTestDaemon(t, 4096, func(req map[string]any) map[string]any {
+		got = req
+		return map[string]any{
+			"message": map[string]any{
+				"role":     "assistant",
+				"thinking": "need weather",
+				"tool_calls": []map[string]any{
+					{"function": map[string]any{"name": "get_weather", "arguments": map[string]any{"city": "Tokyo"}}},
+				},
+			},
+			"done":        true,
+			"done_reason": "stop",
+		}
+	})
+	defer server.Close()
+
+	history := []Message{
+		{Role: "user", Content: "weather?"},
+		{Role: "assistant", ToolCalls: []ToolCall{{
+			ID:       "call_1",
+			Type:     "function",
+			Function: &FunctionCall{Name: "get_time", Arguments: `{"tz":"UTC"}`},
+		}}},
+		{Role: "tool", Content: "12:00", ToolCallID: "call_1"},
+	}
+	tools := []ToolDefinition{{Type: "function", Function: protocolFunc("get_weather")}}
+
+	p := NewProvider(server.URL, "", WithNumCtx(2048))
+	resp, err := p.Chat(t.Context(), history, tools, "llama3", nil)
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+
+	msgs := got["messages"].([]any)
+	call := msgs[1].(map[string]any)["tool_calls"].([]any)[0].(map[string]any)["function"].(map[string]any)
+	if call["name"] != "get_time" || call["arguments"].(map[string]any)["tz"] != "UTC" {
+		t.Errorf("assistant tool call = %v, want object arguments", call)
+	}
+	if msgs[2].(map[string]any)["tool_name"] != "get_time" {
+		t.Errorf("tool message = %v, want tool_name get_time", msgs[2])
+	}
+	if got["options"].(map[string]any)["num_ctx"] != float64(2048) {
+		t.Errorf("num_ctx should come from WithNumCtx, got %v", got["options"])
+	}
+	if len(got["tools"].([]any)) != 1 {
+		t.Errorf("tools not forwarded: %v", got["tools"])
+	}
+
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].Name != "get_weather" {
+		t.Fatalf("tool calls = %+v", resp.ToolCalls)
+	}
+	tc := resp.ToolCalls[0]
+	if tc.ID == "" || tc.Arguments["city"] != "Tokyo" || !strings.Contains(tc.Function.Arguments, "Tokyo") {
+		t.Errorf("tool call = %+v", tc)
+	}
+	if resp.FinishReason != "tool_calls" || resp.ReasoningContent != "need weather" {
+		t.Errorf("finish=%q reasoning=%q", resp.FinishReason, resp.ReasoningContent)
+	}
+}
+
+func TestProviderChat_ErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/show" {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, `{"error":"model 'nope' not found"}`, http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	p := NewProvider(server.URL, "")
+	_, err := p.Chat(t.Context(), []Message{{Role: "user", Content: "hi"}}, nil, "nope", nil)
+	if err == nil || !strings.Contains(err.Error(), "Status: 404") {
+		t.Fatalf("Chat() error = %v, want status 404", err)
+	}
+}
+
+func TestProvider_ShowAndContextWindowCache(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		json.NewEncoder(w).Encode(map[string]any{
+			"model_info":   map[string]any{"qwen2.context_length": 131072},
+			"capabilities": []string{"completion", "tools"},
+		})
+	}))
+	defer server.Close()
+
+	p := NewProvider(server.URL, "")
+	info, err := p.Show(t.Context(), "qwen2.5")
+	if err != nil {
+		t.Fatalf("Show() error = %v", err)
+	}
+	if info.ContextLength != 131072 || len(info.Capabilities) != 2 {
+		t.Errorf("info = %+v", info)
+	}
+
+	calls = 0
+	for range 3 {
+		if n := p.ContextWindow(t.Context(), "qwen2.5"); n != 131072 {
+			t.Errorf("ContextWindow() = %d", n)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("/api/show called %d times, want 1 (cached)", calls)
+	}
+	if n := p.resolveNumCtx(t.Context(), "qwen2.5"); n != maxAutoNumCtx {
+		t.Errorf("resolveNumCtx() = %d, want cap %d", n, maxAutoNumCtx)
+	}
+}
+
+func TestProvider_ListPullDelete(t *testing.T) {
+	var deleted string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/tags":
+			w.Write([]byte(`{"models":[{"name":"llama3:latest","size":42,"details":{"quantization_level":"Q4_0"}}]}`))
+		case r.URL.Path == "/api/pull":
+			w.Write([]byte(`{"status":"pulling manifest"}
+{"status":"downloading","digest":"sha256:1","total":100,"completed":50}
+{"status":"success"}
+`))
+		case r.URL.Path == "/api/delete" && r.Method == http.MethodDelete:
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			deleted = body["model"]
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	p := NewProvider(server.URL, "")
+
+	models, err := p.List(t.Context())
+	if err != nil || len(models) != 1 || models[0].Name != "llama3:latest" || models[0].Details.QuantizationLevel != "Q4_0" {
+		t.Fatalf("List() = %+v, %v", models, err)
+	}
+
+	var statuses []string
+	if err := p.Pull(t.Context(), "llama3", func(pp PullProgress) { statuses = append(statuses, pp.Status) }); err != nil {
+		t.Fatalf("Pull() error = %v", err)
+	}
+	if len(statuses) != 3 || statuses[2] != "success" {
+		t.Errorf("statuses = %v", statuses)
+	}
+
+	if err := p.Delete(t.Context(), "llama3:latest"); err != nil || deleted != "llama3:latest" {
+		t.Errorf("Delete() err=%v deleted=%q", err, deleted)
+	}
+}
+
+func TestProvider_PullStreamError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"pulling manifest"}
+{"error":"pull model manifest: file does not exist"}
+`))
+	}))
+	defer server.Close()
+
+	err := NewProvider(server.URL, "").Pull(t.Context(), "nope", nil)
+	if err == nil || !strings.Contains(err.Error(), "file does not exist") {
+		t.Fatalf("Pull() error = %v", err)
+	}
+}
+
+func protocolFunc(name string) ToolFunctionDefinition {
+	return ToolFunctionDefinition{
+		Name:        name,
+		Description: "test tool",
+		Parameters:  map[string]any{"type": "object"},
+	}
+}
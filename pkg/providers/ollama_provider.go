@@ -0,0 +1,52 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+package providers
+
+import (
+	"context"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers/ollama"
+)
+
+// OllamaProvider talks to a local Ollama daemon through its native /api/chat endpoint.
+type OllamaProvider struct {
+	delegate *ollama.Provider
+}
+
+// NewOllamaProvider creates an Ollama provider from a model_list entry.
+func NewOllamaProvider(cfg *config.ModelConfig) *OllamaProvider {
+	return &OllamaProvider{
+		delegate: ollama.NewProvider(
+			cfg.APIBase,
+			cfg.Proxy,
+			ollama.WithKeepAlive(cfg.KeepAlive),
+			ollama.WithNumCtx(cfg.NumCtx),
+			ollama.WithOptions(cfg.Options),
+			ollama.WithRequestTimeout(time.Duration(cfg.RequestTimeout)*time.Second),
+		),
+	}
+}
+
+func (p *OllamaProvider) Chat(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (*LLMResponse, error) {
+	return p.delegate.Chat(ctx, messages, tools, model, options)
+}
+
+func (p *OllamaProvider) GetDefaultModel() string {
+	return ""
+}
+
+// ContextWindow returns the model's context length reported by the daemon (0 if unknown).
+func (p *OllamaProvider) ContextWindow(ctx context.Context, model string) int {
+	return p.delegate.ContextWindow(ctx, model)
+}
@@ -0,0 +1,425 @@
+package openai_compat
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// ToolModeNative sends tool definitions in the request's "tools" field.
+	ToolModeNative = "native"
+	// ToolModePrompted renders tool definitions into the system prompt and
+	// parses calls back out of the response text, for models whose server
+	// rejects the "tools" field.
+	ToolModePrompted = "prompted"
+
+	toolCallOpen    = "<tool_call>"
+	toolCallClose   = "</tool_call>"
+	toolResultClose = "</tool_result>"
+)
+
+// buildToolPrompt describes the available tools and the exact output format
+// the model must use to call them.
+func buildToolPrompt(tools []ToolDefinition) string {
+	var sb strings.Builder
+
+	sb.WriteString("## Tool Calling\n\n")
+	sb.WriteString("You can call the tools listed below. To call a tool, reply with one block per call ")
+	sb.WriteString("in exactly this format:\n\n")
+	sb.WriteString(toolCallOpen + "\n")
+	sb.WriteString(`{"name": "<tool name>", "arguments": {<arguments as a JSON object>}}`)
+	sb.WriteString("\n" + toolCallClose + "\n\n")
+	sb.WriteString("Rules:\n")
+	sb.WriteString("- The block contains a single JSON object and nothing else.\n")
+	sb.WriteString("- \"arguments\" is a JSON object that matches the tool's parameter schema.\n")
+	sb.WriteString("- Use double quotes for every key and string. No comments, no trailing commas.\n")
+	sb.WriteString("- Emit several blocks to call several tools at once.\n")
+	sb.WriteString("- After the blocks, stop. Results arrive in the next message as <tool_result> blocks.\n")
+	sb.WriteString("- If no tool is needed, answer normally without any " + toolCallOpen + " block.\n\n")
+	sb.WriteString("### Tools\n\n")
+
+	for _, tool := range tools {
+		if tool.Type != "function" {
+			continue
+		}
+		fmt.Fprintf(&sb, "#### %s\n", tool.Function.Name)
+		if tool.Function.Description != "" {
+			fmt.Fprintf(&sb, "%s\n", tool.Function.Description)
+		}
+		if len(tool.Function.Parameters) > 0 {
+			params, _ := json.Marshal(tool.Function.Parameters)
+			fmt.Fprintf(&sb, "Parameters: %s\n", params)
+		}
+		sb.WriteString("\n")
+	}
+
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// renderPromptedMessages rewrites a conversation for prompted tool mode:
+// the tool prompt is appended to the system message, earlier assistant tool
+// calls become <tool_call> text and tool results become user messages with
+// <tool_result> blocks (consecutive results are merged into one message).
+func renderPromptedMessages(messages []Message, tools []ToolDefinition) []Message {
+	toolPrompt := buildToolPrompt(tools)
+	toolNames := make(map[string]string)
+	out := make([]Message, 0, len(messages)+1)
+	injected := false
+
+	for _, m := range messages {
+		switch {
+		case m.Role == "system" && !injected:
+			m.Content = strings.TrimSpace(m.Content + "\n\n" + toolPrompt)
+			m.SystemParts = nil
+			injected = true
+			out = append(out, m)
+
+		case m.Role == "assistant" && len(m.ToolCalls) > 0:
+			var sb strings.Builder
+			sb.WriteString(m.Content)
+			for _, tc := range m.ToolCalls {
+				name, args := toolCallNameAndArgs(tc)
+				toolNames[tc.ID] = name
+				argsJSON, _ := json.Marshal(args)
+				if sb.Len() > 0 {
+					sb.WriteString("\n")
+				}
+				fmt.Fprintf(&sb, "%s\n{\"name\": %q, \"arguments\": %s}\n%s", toolCallOpen, name, argsJSON, toolCallClose)
+			}
+			out = append(out, Message{Role: "assistant", Content: sb.String()})
+
+		case m.Role == "tool":
+			block := fmt.Sprintf("<tool_result name=%q>\n%s\n%s", toolNames[m.ToolCallID], m.Content, toolResultClose)
+			if n := len(out); n > 0 && out[n-1].Role == "user" &&
+				strings.HasSuffix(out[n-1].Content, toolResultClose) {
+				out[n-1].Content += "\n" + block
+				continue
+			}
+			out = append(out, Message{Role: "user", Content: block})
+
+		default:
+			out = append(out, m)
+		}
+	}
+
+	if !injected {
+		out = append([]Message{{Role: "system", Content: toolPrompt}}, out...)
+	}
+	return out
+}
+
+func toolCallNameAndArgs(tc ToolCall) (string, map[string]any) {
+	name := tc.Name
+	args := tc.Arguments
+	if tc.Function != nil {
+		if name == "" {
+			name = tc.Function.Name
+		}
+		if args == nil && tc.Function.Arguments != "" {
+			json.Unmarshal([]byte(tc.Function.Arguments), &args)
+		}
+	}
+	if args == nil {
+		args = map[string]any{}
+	}
+	return name, args
+}
+
+// parsePromptedToolCalls extracts <tool_call> blocks from content and returns
+// the remaining text and the parsed calls. Unclosed blocks, code fences and
+// slightly broken JSON are tolerated. Only calls naming one of tools are
+// accepted; a block with none, such as an echoed example or a hallucinated
+// tool, is left in the text. If the model ignored the tags but replied with
+// a bare {"name": ..., "arguments": ...} object naming a known tool, that
+// object is accepted as well.
+func parsePromptedToolCalls(content string, tools []ToolDefinition) (string, []ToolCall) {
+	known := make(map[string]bool, len(tools))
+	for _, t := range tools {
+		known[t.Function.Name] = true
+	}
+
+	var calls []ToolCall
+	var text strings.Builder
+	blocks := 0
+	rest := content
+	for {
+		start := strings.Index(rest, toolCallOpen)
+		if start < 0 {
+			text.WriteString(rest)
+			break
+		}
+		text.WriteString(rest[:start])
+		block := rest[start:]
+		body := block[len(toolCallOpen):]
+		rest = ""
+		if end := strings.Index(body, toolCallClose); end >= 0 {
+			rest = body[end+len(toolCallClose):]
+			block = block[:len(toolCallOpen)+end+len(toolCallClose)]
+			body = body[:end]
+		}
+		blocks++
+
+		accepted := 0
+		for _, call := range decodeToolCalls(body) {
+			if known[call.Name] {
+				calls = append(calls, call)
+				accepted++
+			}
+		}
+		if accepted == 0 {
+			text.WriteString(block)
+		}
+	}
+
+	if blocks == 0 {
+		if start := strings.Index(content, `{`); start >= 0 && strings.Contains(content, `"name"`) {
+			end := jsonObjectEnd(content, start)
+			candidates := decodeToolCalls(content[start:end])
+			if len(candidates) > 0 && known[candidates[0].Name] {
+				return strings.TrimSpace(content[:start] + content[end:]), assignCallIDs(candidates)
+			}
+		}
+		return content, nil
+	}
+	if len(calls) == 0 {
+		return content, nil
+	}
+
+	return strings.TrimSpace(text.String()), assignCallIDs(calls)
+}
+
+// decodeToolCalls parses one call object, or an array of them.
+func decodeToolCalls(body string) []ToolCall {
+	body = stripCodeFence(strings.TrimSpace(body))
+	if body == "" {
+		return nil
+	}
+
+	var raw any
+	if err := json.Unmarshal([]byte(body), &raw); err != nil {
+		if err := json.Unmarshal([]byte(repairJSON(body)), &raw); err != nil {
+			return nil
+		}
+	}
+
+	var objects []map[string]any
+	switch v := raw.(type) {
+	case map[string]any:
+		objects = append(objects, v)
+	case []any:
+		for _, item := range v {
+			if obj, ok := item.(map[string]any); ok {
+				objects = append(objects, obj)
+			}
+		}
+	}
+
+	var calls []ToolCall
+	for _, obj := range objects {
+		if fn, ok := obj["function"].(map[string]any); ok {
+			obj = fn
+		}
+		name, _ := obj["name"].(string)
+		if name == "" {
+			continue
+		}
+
+		var args map[string]any
+		for _, key := range []string{"arguments", "parameters", "args"} {
+			switch v := obj[key].(type) {
+			case map[string]any:
+				args = v
+			case string:
+				// Arguments encoded as a JSON string, OpenAI style.
+				if err := json.Unmarshal([]byte(v), &args); err != nil {
+					json.Unmarshal([]byte(repairJSON(v)), &args)
+				}
+			}
+			if args != nil {
+				break
+			}
+		}
+		if args == nil {
+			args = map[string]any{}
+		}
+
+		argsJSON, _ := json.Marshal(args)
+		calls = append(calls, ToolCall{
+			Type:      "function",
+			Name:      name,
+			Arguments: args,
+			Function: &FunctionCall{
+				Name:      name,
+				Arguments: string(argsJSON),
+			},
+		})
+	}
+	return calls
+}
+
+// jsonObjectEnd returns the index just past the object starting at start,
+// ignoring braces inside strings, or len(s) if it is never closed.
+func jsonObjectEnd(s string, start int) int {
+	depth := 0
+	inString := false
+	for i := start; i < len(s); i++ {
+		switch c := s[i]; {
+		case inString && c == '\\':
+			i++
+		case c == '"':
+			inString = !inString
+		case inString:
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return len(s)
+}
+
+func assignCallIDs(calls []ToolCall) []ToolCall {
+	now := time.Now().UnixNano()
+	for i := range calls {
+		calls[i].ID = fmt.Sprintf("call_%d_%d", now, i)
+	}
+	return calls
+}
+
+func stripCodeFence(s string) string {
+	if !strings.HasPrefix(s, "```") {
+		return s
+	}
+	s = strings.TrimPrefix(s, "```")
+	if nl := strings.IndexByte(s, '\n'); nl >= 0 {
+		s = s[nl+1:] // drop the language tag line
+	}
+	s = strings.TrimSpace(s)
+	return strings.TrimSpace(strings.TrimSuffix(s, "```"))
+}
+
+// repairJSON fixes the mistakes small models commonly make when writing JSON:
+// single-quoted strings, unquoted keys, Python literals, trailing commas,
+// raw newlines inside strings and missing closing quotes/brackets.
+func repairJSON(s string) string {
+	s = stripCodeFence(strings.TrimSpace(s))
+	if i := strings.IndexAny(s, "{["); i > 0 {
+		s = s[i:]
+	}
+
+	out := make([]byte, 0, len(s)+8)
+	var stack []byte
+	inString := false
+	var quote byte
+	expectKey := false
+
+	trimTrailingComma := func() {
+		end := len(out)
+		for end > 0 && strings.IndexByte(" \t\r\n", out[end-1]) >= 0 {
+			end--
+		}
+		if end > 0 && out[end-1] == ',' {
+			out = out[:end-1]
+		}
+	}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if inString {
+			switch {
+			case c == '\\' && i+1 < len(s):
+				if s[i+1] == '\'' {
+					out = append(out, '\'')
+				} else {
+					out = append(out, c, s[i+1])
+				}
+				i++
+			case c == quote:
+				out = append(out, '"')
+				inString = false
+			case c == '"':
+				out = append(out, '\\', '"')
+			case c == '\n':
+				out = append(out, '\\', 'n')
+			case c == '\r':
+				out = append(out, '\\', 'r')
+			case c == '\t':
+				out = append(out, '\\', 't')
+			default:
+				out = append(out, c)
+			}
+			continue
+		}
+
+		switch {
+		case c == '"' || c == '\'':
+			inString = true
+			quote = c
+			expectKey = false
+			out = append(out, '"')
+		case c == '{' || c == '[':
+			stack = append(stack, c)
+			expectKey = c == '{'
+			out = append(out, c)
+		case c == '}' || c == ']':
+			trimTrailingComma()
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+			expectKey = false
+			out = append(out, c)
+		case c == ',':
+			expectKey = len(stack) > 0 && stack[len(stack)-1] == '{'
+			out = append(out, c)
+		case isIdentStart(c):
+			j := i
+			for j < len(s) && isIdentChar(s[j]) {
+				j++
+			}
+			word := s[i:j]
+			switch {
+			case expectKey:
+				out = append(out, strconv.Quote(word)...)
+				expectKey = false
+			case word == "True":
+				out = append(out, "true"...)
+			case word == "False":
+				out = append(out, "false"...)
+			case word == "None":
+				out = append(out, "null"...)
+			default:
+				out = append(out, word...)
+			}
+			i = j - 1
+		default:
+			out = append(out, c)
+		}
+	}
+
+	if inString {
+		out = append(out, '"')
+	}
+	trimTrailingComma()
+	for i := len(stack) - 1; i >= 0; i-- {
+		if stack[i] == '{' {
+			out = append(out, '}')
+		} else {
+			out = append(out, ']')
+		}
+	}
+	return string(out)
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9') || c == '-'
+}
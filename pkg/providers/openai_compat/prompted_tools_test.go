@@ -0,0 +1,240 @@
+package openai_compat
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var promptedTools = []ToolDefinition{
+	{
+		Type: "function",
+		Function: ToolFunctionDefinition{
+			Name:        "read_file",
+			Description: "Read a file",
+			Parameters: map[string]any{
+				"type":       "object",
+				"properties": map[string]any{"path": map[string]any{"type": "string"}},
+				"required":   []any{"path"},
+			},
+		},
+	},
+	{
+		Type:     "function",
+		Function: ToolFunctionDefinition{Name: "list_dir"},
+	},
+}
+
+func TestRenderPromptedMessages(t *testing.T) {
+	messages := []Message{
+		{Role: "system", Content: "You are helpful."},
+		{Role: "user", Content: "Show a.txt and b.txt"},
+		{
+			Role: "assistant",
+			ToolCalls: []ToolCall{
+				{ID: "call_1", Name: "read_file", Arguments: map[string]any{"path": "a.txt"}},
+				{ID: "call_2", Type: "function", Function: &FunctionCall{Name: "read_file", Arguments: `{"path":"b.txt"}`}},
+			},
+		},
+		{Role: "tool", ToolCallID: "call_1", Content: "alpha"},
+		{Role: "tool", ToolCallID: "call_2", Content: "beta"},
+	}
+
+	out := renderPromptedMessages(messages, promptedTools)
+	if len(out) != 4 {
+		t.Fatalf("len(out) = %d, want 4: %+v", len(out), out)
+	}
+
+	sys := out[0]
+	for _, want := range []string{"You are helpful.", "## Tool Calling", "#### read_file", `"required":["path"]`, "#### list_dir"} {
+		if !strings.Contains(sys.Content, want) {
+			t.Errorf("system prompt missing %q", want)
+		}
+	}
+
+	if len(out[2].ToolCalls) != 0 {
+		t.Error("assistant tool calls should be rendered as text")
+	}
+	if strings.Count(out[2].Content, toolCallOpen) != 2 ||
+		!strings.Contains(out[2].Content, `{"name": "read_file", "arguments": {"path":"b.txt"}}`) {
+		t.Errorf("assistant content = %q", out[2].Content)
+	}
+
+	results := out[3]
+	if results.Role != "user" {
+		t.Errorf("tool results role = %q, want user", results.Role)
+	}
+	want := "<tool_result name=\"read_file\">\nalpha\n</tool_result>\n<tool_result name=\"read_file\">\nbeta\n</tool_result>"
+	if results.Content != want {
+		t.Errorf("tool results = %q, want %q", results.Content, want)
+	}
+
+	// Input must not be mutated.
+	if messages[0].Content != "You are helpful." {
+		t.Error("input system message was modified")
+	}
+}
+
+func TestRenderPromptedMessages_AddsSystemMessage(t *testing.T) {
+	out := renderPromptedMessages([]Message{{Role: "user", Content: "hi"}}, promptedTools)
+	if len(out) != 2 || out[0].Role != "system" || !strings.Contains(out[0].Content, "## Tool Calling") {
+		t.Fatalf("out = %+v", out)
+	}
+}
+
+func TestParsePromptedToolCalls(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		wantText  string
+		wantCalls []string // "name:argsJSON"
+	}{
+		{
+			name:      "plain answer",
+			content:   "The answer is 42.",
+			wantText:  "The answer is 42.",
+			wantCalls: nil,
+		},
+		{
+			name:      "single call with preamble",
+			content:   "Let me look.\n<tool_call>\n{\"name\": \"read_file\", \"arguments\": {\"path\": \"a.txt\"}}\n</tool_call>",
+			wantText:  "Let me look.",
+			wantCalls: []string{`read_file:{"path":"a.txt"}`},
+		},
+		{
+			name: "multiple calls",
+			content: "<tool_call>{\"name\": \"read_file\", \"arguments\": {\"path\": \"a\"}}</tool_call>\n" +
+				"<tool_call>{\"name\": \"list_dir\", \"arguments\": {}}</tool_call>",
+			wantCalls: []string{`read_file:{"path":"a"}`, `list_dir:{}`},
+		},
+		{
+			name:      "unclosed block",
+			content:   "<tool_call>\n{\"name\": \"read_file\", \"arguments\": {\"path\": \"a.txt\"",
+			wantCalls: []string{`read_file:{"path":"a.txt"}`},
+		},
+		{
+			name:      "code fence inside block",
+			content:   "<tool_call>\n```json\n{\"name\": \"list_dir\", \"arguments\": {\"path\": \".\"}}\n```\n</tool_call>",
+			wantCalls: []string{`list_dir:{"path":"."}`},
+		},
+		{
+			name:      "broken json",
+			content:   "<tool_call>{name: 'read_file', arguments: {path: 'it\\'s.txt', follow: True,},}</tool_call>",
+			wantCalls: []string{`read_file:{"follow":true,"path":"it's.txt"}`},
+		},
+		{
+			name:      "string arguments and function wrapper",
+			content:   "<tool_call>{\"function\": {\"name\": \"read_file\", \"arguments\": \"{\\\"path\\\": \\\"x\\\"}\"}}</tool_call>",
+			wantCalls: []string{`read_file:{"path":"x"}`},
+		},
+		{
+			name:      "array of calls",
+			content:   "<tool_call>[{\"name\": \"list_dir\", \"parameters\": {\"path\": \"/\"}}, {\"name\": \"read_file\", \"args\": {\"path\": \"b\"}}]</tool_call>",
+			wantCalls: []string{`list_dir:{"path":"/"}`, `read_file:{"path":"b"}`},
+		},
+		{
+			name:      "bare object naming a known tool",
+			content:   "Sure. {\"name\": \"read_file\", \"arguments\": {\"path\": \"{a}.txt\"}} Done.",
+			wantText:  "Sure.  Done.",
+			wantCalls: []string{`read_file:{"path":"{a}.txt"}`},
+		},
+		{
+			name:      "unknown tool left as text",
+			content:   "Call it like <tool_call>{\"name\": \"web_search\", \"arguments\": {}}</tool_call> next time.",
+			wantText:  "Call it like <tool_call>{\"name\": \"web_search\", \"arguments\": {}}</tool_call> next time.",
+			wantCalls: nil,
+		},
+		{
+			name: "unknown tool dropped next to a known one",
+			content: "<tool_call>{\"name\": \"delete_all\", \"arguments\": {}}</tool_call>\n" +
+				"<tool_call>[{\"name\": \"list_dir\", \"arguments\": {}}, {\"name\": \"nope\"}]</tool_call>",
+			wantText:  "<tool_call>{\"name\": \"delete_all\", \"arguments\": {}}</tool_call>",
+			wantCalls: []string{`list_dir:{}`},
+		},
+		{
+			name:      "bare object naming an unknown tool",
+			content:   `Example: {"name": "Alice", "age": 3}`,
+			wantText:  `Example: {"name": "Alice", "age": 3}`,
+			wantCalls: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, calls := parsePromptedToolCalls(tt.content, promptedTools)
+			if text != tt.wantText {
+				t.Errorf("text = %q, want %q", text, tt.wantText)
+			}
+			var got []string
+			for _, c := range calls {
+				if c.ID == "" || c.Type != "function" || c.Function == nil || c.Function.Name != c.Name {
+					t.Errorf("malformed call: %+v", c)
+				}
+				got = append(got, c.Name+":"+c.Function.Arguments)
+			}
+			if strings.Join(got, "|") != strings.Join(tt.wantCalls, "|") {
+				t.Errorf("calls = %v, want %v", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestProviderChat_PromptedToolMode(t *testing.T) {
+	var requestBody map[string]any
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp := map[string]any{
+			"choices": []map[string]any{
+				{
+					"message": map[string]any{
+						"content": "<tool_call>\n{\"name\": \"read_file\", \"arguments\": {\"path\": \"a.txt\"}}\n</tool_call>",
+					},
+					"finish_reason": "stop",
+				},
+			},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	p := NewProvider("key", server.URL, "", WithToolMode(ToolModePrompted))
+	out, err := p.Chat(
+		t.Context(),
+		[]Message{{Role: "system", Content: "sys"}, {Role: "user", Content: "read a.txt"}},
+		promptedTools,
+		"phi3",
+		nil,
+	)
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+
+	if _, ok := requestBody["tools"]; ok {
+		t.Error("prompted mode must not send the tools field")
+	}
+	if _, ok := requestBody["tool_choice"]; ok {
+		t.Error("prompted mode must not send tool_choice")
+	}
+	msgs, _ := requestBody["messages"].([]any)
+	if len(msgs) != 2 {
+		t.Fatalf("messages = %v", requestBody["messages"])
+	}
+	sys, _ := msgs[0].(map[string]any)["content"].(string)
+	if !strings.Contains(sys, "#### read_file") {
+		t.Errorf("system prompt does not describe tools: %q", sys)
+	}
+
+	if out.Content != "" || out.FinishReason != "tool_calls" {
+		t.Errorf("Content = %q, FinishReason = %q", out.Content, out.FinishReason)
+	}
+	if len(out.ToolCalls) != 1 || out.ToolCalls[0].Name != "read_file" || out.ToolCalls[0].Arguments["path"] != "a.txt" {
+		t.Fatalf("ToolCalls = %+v", out.ToolCalls)
+	}
+}
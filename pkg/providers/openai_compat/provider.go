@@ -32,6 +32,7 @@ type Provider struct {
 	apiKey         string
 	apiBase        string
 	maxTokensField string // Field name for max tokens (e.g., "max_completion_tokens" for o1/glm models)
+	toolMode       string // ToolModeNative (default) or ToolModePrompted
 	httpClient     *http.Client
 }
 
@@ -45,6 +46,13 @@ func WithMaxTokensField(maxTokensField string) Option {
 	}
 }
 
+// WithToolMode selects how tools are offered to the model (see ToolModePrompted).
+func WithToolMode(toolMode string) Option {
+	return func(p *Provider) {
+		p.toolMode = toolMode
+	}
+}
+
 func WithRequestTimeout(timeout time.Duration) Option {
 	return func(p *Provider) {
 		if timeout > 0 {
@@ -114,12 +122,22 @@ func (p *Provider) Chat(
 
 	model = normalizeModel(model, p.apiBase)
 
+	prompted := p.toolMode == ToolModePrompted && len(tools) > 0
+	if prompted {
+		messages = renderPromptedMessages(messages, tools)
+	}
+
+	wireMessages := stripSystemParts(messages)
+	if strings.Contains(p.apiBase, "deepseek.com") {
+		replayReasoningContent(wireMessages, messages)
+	}
+
 	requestBody := map[string]any{
 		"model":    model,
-		"messages": stripSystemParts(messages),
+		"messages": wireMessages,
 	}
 
-	if len(tools) > 0 {
+	if len(tools) > 0 && !prompted {
 		requestBody["tools"] = tools
 		requestBody["tool_choice"] = "auto"
 	}
@@ -150,6 +168,8 @@ func (p *Provider) Chat(
 		}
 	}
 
+	applyReasoningOptions(requestBody, options, p.apiBase)
+
 	// Prompt caching: pass a stable cache key so OpenAI can bucket requests
 	// with the same key and reuse prefix KV cache across calls.
 	// The key is typically the agent ID — stable per agent, shared across requests.
@@ -192,7 +212,17 @@ func (p *Provider) Chat(
 		return nil, fmt.Errorf("API request failed:\n  Status: %d\n  Body:   %s", resp.StatusCode, string(body))
 	}
 
-	return parseResponse(body)
+	llmResp, err := parseResponse(body)
+	if err != nil || !prompted {
+		return llmResp, err
+	}
+
+	if content, calls := parsePromptedToolCalls(llmResp.Content, tools); len(calls) > 0 {
+		llmResp.Content = content
+		llmResp.ToolCalls = calls
+		llmResp.FinishReason = "tool_calls"
+	}
+	return llmResp, nil
 }
 
 func parseResponse(body []byte) (*LLMResponse, error) {
@@ -289,15 +319,19 @@ func parseResponse(body []byte) (*LLMResponse, error) {
 // It mirrors protocoltypes.Message but omits SystemParts, which is an
 // internal field that would be unknown to third-party endpoints.
 type openaiMessage struct {
-	Role       string     `json:"role"`
-	Content    string     `json:"content"`
-	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
-	ToolCallID string     `json:"tool_call_id,omitempty"`
+	Role string `json:"role"`
+	// Content is a string, or a list of content parts when the message
+	// carries media.
+	Content          any        `json:"content"`
+	ReasoningContent string     `json:"reasoning_content,omitempty"`
+	ToolCalls        []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID       string     `json:"tool_call_id,omitempty"`
 }
 
 // stripSystemParts converts []Message to []openaiMessage, dropping the
 // SystemParts field so it doesn't leak into the JSON payload sent to
 // OpenAI-compatible APIs (some strict endpoints reject unknown fields).
+// Media becomes image_url and file content parts.
 func stripSystemParts(messages []Message) []openaiMessage {
 	out := make([]openaiMessage, len(messages))
 	for i, m := range messages {
@@ -307,10 +341,114 @@ func stripSystemParts(messages []Message) []openaiMessage {
 			ToolCalls:  m.ToolCalls,
 			ToolCallID: m.ToolCallID,
 		}
+		if parts := mediaParts(m.Media); len(parts) > 0 {
+			if m.Content != "" {
+				parts = append([]map[string]any{{"type": "text", "text": m.Content}}, parts...)
+			}
+			out[i].Content = parts
+		}
 	}
 	return out
 }
 
+// mediaParts turns images (data or http(s) URLs) into image_url parts and
+// PDF data URLs into file parts. Anything else is dropped.
+func mediaParts(media []string) []map[string]any {
+	var parts []map[string]any
+	for _, m := range media {
+		switch {
+		case strings.HasPrefix(m, "data:image/"), strings.HasPrefix(m, "https://"), strings.HasPrefix(m, "http://"):
+			parts = append(parts, map[string]any{"type": "image_url", "image_url": map[string]any{"url": m}})
+		case strings.HasPrefix(m, "data:application/pdf;base64,"):
+			parts = append(parts, map[string]any{
+				"type": "file",
+				"file": map[string]any{"filename": "document.pdf", "file_data": m},
+			})
+		}
+	}
+	return parts
+}
+
+// replayReasoningContent sends reasoning_content back on the assistant
+// messages of the turn in progress. DeepSeek's thinking mode requires it
+// between tool calls of one turn and ignores it for earlier turns, so older
+// reasoning is left out to save tokens.
+func replayReasoningContent(out []openaiMessage, messages []Message) {
+	for i := len(messages) - 1; i >= 0; i-- {
+		m := messages[i]
+		if m.Role == "user" {
+			return
+		}
+		if m.Role == "assistant" && len(m.ToolCalls) > 0 {
+			out[i].ReasoningContent = m.ReasoningContent
+		}
+	}
+}
+
+// applyReasoningOptions translates the "reasoning_effort" and
+// "thinking_budget" options into the parameter the endpoint understands.
+func applyReasoningOptions(requestBody map[string]any, options map[string]any, apiBase string) {
+	effort, _ := options["reasoning_effort"].(string)
+	budget, hasBudget := asInt(options["thinking_budget"])
+	if effort == "" && !hasBudget {
+		return
+	}
+	if !hasBudget {
+		budget, hasBudget = protocoltypes.ThinkingBudgetForEffort(effort)
+	}
+	disabled := effort == protocoltypes.ReasoningEffortNone || (effort == "" && budget <= 0)
+
+	base := strings.ToLower(apiBase)
+	switch {
+	case strings.Contains(base, "openrouter.ai"):
+		switch {
+		case disabled:
+			requestBody["reasoning"] = map[string]any{"enabled": false}
+		case effort != "":
+			requestBody["reasoning"] = map[string]any{"effort": effort}
+		default:
+			requestBody["reasoning"] = map[string]any{"max_tokens": budget}
+		}
+
+	case strings.Contains(base, "api.anthropic.com"):
+		if !disabled && hasBudget {
+			requestBody["thinking"] = map[string]any{"type": "enabled", "budget_tokens": max(budget, 1024)}
+		}
+
+	case strings.Contains(base, "dashscope"):
+		requestBody["enable_thinking"] = !disabled
+		if !disabled && hasBudget {
+			requestBody["thinking_budget"] = budget
+		}
+
+	default:
+		// Not every endpoint accepts "none", and leaving the parameter out
+		// already means no extra reasoning.
+		if disabled {
+			return
+		}
+		if effort == "" {
+			effort = effortForBudget(budget)
+		}
+		requestBody["reasoning_effort"] = effort
+	}
+}
+
+// effortForBudget approximates a token budget with the nearest effort level
+// for endpoints that only accept reasoning_effort.
+func effortForBudget(budget int) string {
+	switch {
+	case budget <= 0:
+		return protocoltypes.ReasoningEffortNone
+	case budget <= 2048:
+		return protocoltypes.ReasoningEffortLow
+	case budget <= 8192:
+		return protocoltypes.ReasoningEffortMedium
+	default:
+		return protocoltypes.ReasoningEffortHigh
+	}
+}
+
 func normalizeModel(model, apiBase string) string {
 	before, after, ok := strings.Cut(model, "/")
 	if !ok {
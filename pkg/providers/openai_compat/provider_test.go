@@ -361,3 +361,185 @@ func TestProvider_FunctionalOptionRequestTimeoutNonPositive(t *testing.T) {
 		t.Fatalf("http timeout = %v, want %v", p.httpClient.Timeout, defaultRequestTimeout)
 	}
 }
+
+func TestProviderChat_SendsMedia(t *testing.T) {
+	var requestBody struct {
+		Messages []map[string]any `json:"messages"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&requestBody)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []map[string]any{{"message": map[string]any{"content": "ok"}, "finish_reason": "stop"}},
+		})
+	}))
+	defer server.Close()
+
+	// read_file attaches images and PDFs as data URLs on a user message
+	// that follows the tool results.
+	messages := []Message{
+		{Role: "user", Content: "Look at these"},
+		{
+			Role:    "user",
+			Content: "[2 file(s) attached by the tool results above]",
+			Media: []string{
+				"data:image/png;base64,iVBORw==",
+				"data:application/pdf;base64,JVBERi0=",
+				"data:text/plain;base64,aGk=",
+			},
+		},
+	}
+	p := NewProvider("key", server.URL, "")
+	if _, err := p.Chat(t.Context(), messages, nil, "gpt-4o", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := requestBody.Messages[0]["content"]; got != "Look at these" {
+		t.Errorf("plain message content = %v, want a string", got)
+	}
+	got, _ := json.Marshal(requestBody.Messages[1]["content"])
+	want, _ := json.Marshal([]map[string]any{
+		{"type": "text", "text": "[2 file(s) attached by the tool results above]"},
+		{"type": "image_url", "image_url": map[string]any{"url": "data:image/png;base64,iVBORw=="}},
+		{"type": "file", "file": map[string]any{
+			"filename":  "document.pdf",
+			"file_data": "data:application/pdf;base64,JVBERi0=",
+		}},
+	})
+	if string(got) != string(want) {
+		t.Errorf("media message content = %s, want %s", got, want)
+	}
+}
+
+func TestApplyReasoningOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		apiBase string
+		options map[string]any
+		want    map[string]any
+	}{
+		{
+			name:    "no reasoning options",
+			apiBase: "https://api.openai.com/v1",
+			options: map[string]any{"max_tokens": 100},
+			want:    map[string]any{},
+		},
+		{
+			name:    "openai effort",
+			apiBase: "https://api.openai.com/v1",
+			options: map[string]any{"reasoning_effort": "high"},
+			want:    map[string]any{"reasoning_effort": "high"},
+		},
+		{
+			name:    "openai budget only",
+			apiBase: "https://api.openai.com/v1",
+			options: map[string]any{"thinking_budget": 4000},
+			want:    map[string]any{"reasoning_effort": "medium"},
+		},
+		{
+			name:    "openai disabled",
+			apiBase: "https://api.openai.com/v1",
+			options: map[string]any{"reasoning_effort": "none"},
+			want:    map[string]any{},
+		},
+		{
+			name:    "openrouter effort",
+			apiBase: "https://openrouter.ai/api/v1",
+			options: map[string]any{"reasoning_effort": "low"},
+			want:    map[string]any{"reasoning": map[string]any{"effort": "low"}},
+		},
+		{
+			name:    "openrouter budget",
+			apiBase: "https://openrouter.ai/api/v1",
+			options: map[string]any{"thinking_budget": 3000},
+			want:    map[string]any{"reasoning": map[string]any{"max_tokens": 3000}},
+		},
+		{
+			name:    "openrouter disabled",
+			apiBase: "https://openrouter.ai/api/v1",
+			options: map[string]any{"reasoning_effort": "none"},
+			want:    map[string]any{"reasoning": map[string]any{"enabled": false}},
+		},
+		{
+			name:    "anthropic compat",
+			apiBase: "https://api.anthropic.com/v1",
+			options: map[string]any{"reasoning_effort": "medium"},
+			want:    map[string]any{"thinking": map[string]any{"type": "enabled", "budget_tokens": 8192}},
+		},
+		{
+			name:    "dashscope budget",
+			apiBase: "https://dashscope.aliyuncs.com/compatible-mode/v1",
+			options: map[string]any{"thinking_budget": 500},
+			want:    map[string]any{"enable_thinking": true, "thinking_budget": 500},
+		},
+		{
+			name:    "dashscope disabled",
+			apiBase: "https://dashscope.aliyuncs.com/compatible-mode/v1",
+			options: map[string]any{"reasoning_effort": "none"},
+			want:    map[string]any{"enable_thinking": false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := map[string]any{}
+			applyReasoningOptions(body, tt.options, tt.apiBase)
+			got, _ := json.Marshal(body)
+			want, _ := json.Marshal(tt.want)
+			if string(got) != string(want) {
+				t.Errorf("request body = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestProviderChat_DeepSeekReplaysCurrentTurnReasoning(t *testing.T) {
+	var requestBody struct {
+		Messages []map[string]any `json:"messages"`
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&requestBody)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []map[string]any{{"message": map[string]any{"content": "ok"}, "finish_reason": "stop"}},
+		})
+	}))
+	defer server.Close()
+
+	call := func(id string) []ToolCall {
+		return []ToolCall{{ID: id, Type: "function", Function: &FunctionCall{Name: "ls", Arguments: "{}"}}}
+	}
+	messages := []Message{
+		{Role: "user", Content: "first"},
+		{Role: "assistant", ReasoningContent: "old thoughts", ToolCalls: call("c1")},
+		{Role: "tool", ToolCallID: "c1", Content: "a"},
+		{Role: "assistant", Content: "done", ReasoningContent: "old answer thoughts"},
+		{Role: "user", Content: "second"},
+		{Role: "assistant", ReasoningContent: "new thoughts", ToolCalls: call("c2")},
+		{Role: "tool", ToolCallID: "c2", Content: "b"},
+	}
+
+	// The test server stands in for api.deepseek.com.
+	p := NewProvider("key", server.URL, "")
+	p.apiBase = server.URL + "/deepseek.com"
+	server.Config.Handler = http.StripPrefix("/deepseek.com", server.Config.Handler)
+
+	if _, err := p.Chat(t.Context(), messages, nil, "deepseek-reasoner", nil); err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if len(requestBody.Messages) != len(messages) {
+		t.Fatalf("len(messages) = %d, want %d", len(requestBody.Messages), len(messages))
+	}
+
+	for i, m := range requestBody.Messages {
+		got, _ := m["reasoning_content"].(string)
+		want := ""
+		if i == 5 {
+			want = "new thoughts"
+		}
+		if got != want {
+			t.Errorf("messages[%d].reasoning_content = %q, want %q", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package protocoltypes
+
+import "strings"
+
+// Reasoning effort levels accepted in the "reasoning_effort" request option.
+const (
+	ReasoningEffortNone    = "none"
+	ReasoningEffortMinimal = "minimal"
+	ReasoningEffortLow     = "low"
+	ReasoningEffortMedium  = "medium"
+	ReasoningEffortHigh    = "high"
+)
+
+// ThinkingBudgetForEffort converts an effort level into a token budget for
+// APIs that only accept budgets. ok is false for an unknown level; "none"
+// maps to a budget of 0 (thinking disabled).
+func ThinkingBudgetForEffort(effort string) (budget int, ok bool) {
+	switch strings.ToLower(strings.TrimSpace(effort)) {
+	case ReasoningEffortNone:
+		return 0, true
+	case ReasoningEffortMinimal:
+		return 1024, true
+	case ReasoningEffortLow:
+		return 2048, true
+	case ReasoningEffortMedium:
+		return 8192, true
+	case ReasoningEffortHigh:
+		return 24576, true
+	default:
+		return 0, false
+	}
+}
@@ -32,6 +32,7 @@ type LLMResponse struct {
 	Usage            *UsageInfo        `json:"usage,omitempty"`
 	Reasoning        string            `json:"reasoning"`
 	ReasoningDetails []ReasoningDetail `json:"reasoning_details"`
+	ThinkingBlocks   []ThinkingBlock   `json:"thinking_blocks,omitempty"`
 }
 
 type ReasoningDetail struct {
@@ -41,6 +42,16 @@ type ReasoningDetail struct {
 	Text   string `json:"text"`
 }
 
+// ThinkingBlock is a signed reasoning segment (Anthropic extended thinking).
+// It has to be sent back verbatim, signature included, on the assistant
+// message that precedes the matching tool results.
+type ThinkingBlock struct {
+	Type      string `json:"type"` // "thinking" or "redacted_thinking"
+	Thinking  string `json:"thinking,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	Data      string `json:"data,omitempty"` // encrypted payload of redacted_thinking
+}
+
 type UsageInfo struct {
 	PromptTokens     int `json:"prompt_tokens"`
 	CompletionTokens int `json:"completion_tokens"`
@@ -63,12 +74,15 @@ type ContentBlock struct {
 }
 
 type Message struct {
-	Role             string         `json:"role"`
-	Content          string         `json:"content"`
-	ReasoningContent string         `json:"reasoning_content,omitempty"`
-	SystemParts      []ContentBlock `json:"system_parts,omitempty"` // structured system blocks for cache-aware adapters
-	ToolCalls        []ToolCall     `json:"tool_calls,omitempty"`
-	ToolCallID       string         `json:"tool_call_id,omitempty"`
+	Role             string          `json:"role"`
+	Content          string          `json:"content"`
+	ReasoningContent string          `json:"reasoning_content,omitempty"`
+	ThinkingBlocks   []ThinkingBlock `json:"thinking_blocks,omitempty"`
+	SystemParts      []ContentBlock  `json:"system_parts,omitempty"` // structured system blocks for cache-aware adapters
+	Media            []string        `json:"media,omitempty"`        // attached images as data URLs (data:image/png;base64,...)
+	ToolCalls        []ToolCall      `json:"tool_calls,omitempty"`
+	ToolCallID       string          `json:"tool_call_id,omitempty"`
+	ToolMedia        bool            `json:"tool_media,omitempty"` // user message carrying media attached by tool results; continues the turn
 }
 
 type ToolDefinition struct {
@@ -0,0 +1,348 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+package providers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// ProviderOption is the Chat option key naming the provider (protocol) of the
+// requested model, so that model_list entries sharing a model ID under
+// different providers get their own limiter. Providers ignore it.
+const ProviderOption = "provider"
+
+// RateLimitConfig holds the per-model_list-entry limits.
+// Zero values mean "no limit" for the corresponding dimension.
+type RateLimitConfig struct {
+	RPM           int // requests per minute
+	TPM           int // tokens per minute (prompt + completion)
+	MaxConcurrent int // maximum in-flight requests
+}
+
+// IsZero reports whether no limit is configured.
+func (c RateLimitConfig) IsZero() bool {
+	return c.RPM <= 0 && c.TPM <= 0 && c.MaxConcurrent <= 0
+}
+
+// RateLimiter enforces RPM, TPM and concurrency limits for a single model endpoint.
+// It is safe for concurrent use and is meant to be shared by every caller of that endpoint.
+type RateLimiter struct {
+	requests *rate.Limiter
+	tokens   *rate.Limiter
+	slots    chan struct{}
+}
+
+// NewRateLimiter creates a limiter for the given config.
+// Requests are spaced evenly (burst of 1) so that a full minute window never exceeds RPM.
+func NewRateLimiter(cfg RateLimitConfig) *RateLimiter {
+	rl := &RateLimiter{}
+	if cfg.RPM > 0 {
+		rl.requests = rate.NewLimiter(rate.Limit(float64(cfg.RPM)/60), 1)
+	}
+	if cfg.TPM > 0 {
+		rl.tokens = rate.NewLimiter(rate.Limit(float64(cfg.TPM)/60), cfg.TPM)
+	}
+	if cfg.MaxConcurrent > 0 {
+		rl.slots = make(chan struct{}, cfg.MaxConcurrent)
+	}
+	return rl
+}
+
+// Acquire blocks until a request with the estimated token count may be sent,
+// or until ctx is done. On success it returns a release function that must be
+// called with the actual token usage (0 if unknown) once the request finishes.
+func (rl *RateLimiter) Acquire(ctx context.Context, estimatedTokens int) (func(usedTokens int), error) {
+	if rl.slots != nil {
+		select {
+		case rl.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	releaseSlot := func() {
+		if rl.slots != nil {
+			<-rl.slots
+		}
+	}
+
+	if rl.requests != nil {
+		if err := rl.requests.Wait(ctx); err != nil {
+			releaseSlot()
+			return nil, fmt.Errorf("rate limit wait: %w", err)
+		}
+	}
+
+	reserved := 0
+	if rl.tokens != nil && estimatedTokens > 0 {
+		reserved = min(estimatedTokens, rl.tokens.Burst())
+		if err := rl.tokens.WaitN(ctx, reserved); err != nil {
+			releaseSlot()
+			return nil, fmt.Errorf("token rate limit wait: %w", err)
+		}
+	}
+
+	var once sync.Once
+	return func(usedTokens int) {
+		once.Do(func() {
+			// Charge any tokens beyond the estimate so subsequent requests are delayed accordingly.
+			if rl.tokens != nil && usedTokens > reserved {
+				rl.tokens.ReserveN(time.Now(), min(usedTokens-reserved, rl.tokens.Burst()))
+			}
+			releaseSlot()
+		})
+	}, nil
+}
+
+// EstimateWait returns how long a request with the given token estimate would
+// currently have to wait for RPM/TPM capacity. Concurrency slots are not
+// considered because their release time is unknown.
+func (rl *RateLimiter) EstimateWait(estimatedTokens int) time.Duration {
+	now := time.Now()
+	var wait time.Duration
+
+	if rl.requests != nil {
+		r := rl.requests.ReserveN(now, 1)
+		wait = max(wait, r.DelayFrom(now))
+		r.CancelAt(now)
+	}
+	if rl.tokens != nil && estimatedTokens > 0 {
+		r := rl.tokens.ReserveN(now, min(estimatedTokens, rl.tokens.Burst()))
+		wait = max(wait, r.DelayFrom(now))
+		r.CancelAt(now)
+	}
+
+	return wait
+}
+
+// RateLimiterRegistry maps model_list entries to their shared RateLimiter.
+// Limiters are keyed by ModelKey(protocol, modelID) and also indexed by
+// model_name alias and by bare model ID, so they can be found from fallback
+// candidates and from Chat's model argument. A model ID used by entries with
+// different limiters is ambiguous and is only found together with its provider.
+type RateLimiterRegistry struct {
+	mu      sync.RWMutex
+	byKey   map[string]*RateLimiter
+	byAlias map[string]*RateLimiter
+	byModel map[string]*RateLimiter // nil value: ambiguous model ID
+}
+
+// NewRateLimiterRegistry creates an empty registry.
+func NewRateLimiterRegistry() *RateLimiterRegistry {
+	return &RateLimiterRegistry{
+		byKey:   make(map[string]*RateLimiter),
+		byAlias: make(map[string]*RateLimiter),
+		byModel: make(map[string]*RateLimiter),
+	}
+}
+
+// NewRateLimiterRegistryFromConfig creates a registry with a limiter for every
+// model_list entry that declares rpm, tpm or max_concurrent. Entries of the
+// balanced model_name are skipped: their BalancedProvider limits each endpoint
+// itself. Other model_names with several entries get a limiter per entry but
+// no alias, since the alias does not tell which entry serves a request.
+func NewRateLimiterRegistryFromConfig(cfg *config.Config, balanced string) *RateLimiterRegistry {
+	registry := NewRateLimiterRegistry()
+	if cfg == nil {
+		return registry
+	}
+	for i := range cfg.ModelList {
+		mc := &cfg.ModelList[i]
+		entries := len(cfg.GetModelConfigs(mc.ModelName))
+		if entries > 1 && mc.ModelName == balanced {
+			continue
+		}
+		protocol, modelID := ExtractProtocol(mc.Model)
+		if rl := registry.Register(protocol, modelID, RateLimitConfigFromModel(mc)); rl != nil && entries == 1 {
+			registry.Alias(mc.ModelName, rl)
+		}
+	}
+	return registry
+}
+
+// RateLimitConfigFromModel extracts the rate limit settings from a model_list entry.
+func RateLimitConfigFromModel(mc *config.ModelConfig) RateLimitConfig {
+	return RateLimitConfig{
+		RPM:           mc.RPM,
+		TPM:           mc.TPM,
+		MaxConcurrent: mc.MaxConcurrent,
+	}
+}
+
+// Register creates a limiter for protocol/modelID unless one already exists.
+// Returns nil if cfg has no limits.
+func (r *RateLimiterRegistry) Register(protocol, modelID string, cfg RateLimitConfig) *RateLimiter {
+	if cfg.IsZero() {
+		return nil
+	}
+
+	key := ModelKey(protocol, modelID)
+	modelKey := strings.ToLower(strings.TrimSpace(modelID))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if rl, ok := r.byKey[key]; ok {
+		return rl
+	}
+	rl := NewRateLimiter(cfg)
+	r.byKey[key] = rl
+	if _, ok := r.byModel[modelKey]; ok {
+		r.byModel[modelKey] = nil
+	} else {
+		r.byModel[modelKey] = rl
+	}
+	return rl
+}
+
+// Alias makes rl the limiter of a model_list model_name.
+func (r *RateLimiterRegistry) Alias(alias string, rl *RateLimiter) {
+	alias = strings.TrimSpace(alias)
+	if alias == "" || rl == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.byAlias[alias] = rl
+}
+
+// Lookup returns the limiter for a provider/model pair, or nil if unlimited.
+// model may also be a model_name alias. An empty provider looks the model up
+// by alias or by an unambiguous model ID.
+func (r *RateLimiterRegistry) Lookup(provider, model string) *RateLimiter {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if provider != "" {
+		if rl, ok := r.byKey[ModelKey(provider, model)]; ok {
+			return rl
+		}
+	}
+	if rl, ok := r.byAlias[strings.TrimSpace(model)]; ok {
+		return rl
+	}
+	return r.byModel[strings.ToLower(strings.TrimSpace(model))]
+}
+
+// Len returns the number of registered limiters.
+func (r *RateLimiterRegistry) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.byKey)
+}
+
+// RateLimitedProvider is an LLMProvider decorator that waits for the
+// rate limiter of the requested model before delegating the call.
+type RateLimitedProvider struct {
+	delegate LLMProvider
+	limiters *RateLimiterRegistry
+}
+
+// NewRateLimitedProvider wraps delegate so that every Chat call honors limiters.
+func NewRateLimitedProvider(delegate LLMProvider, limiters *RateLimiterRegistry) *RateLimitedProvider {
+	return &RateLimitedProvider{
+		delegate: delegate,
+		limiters: limiters,
+	}
+}
+
+func (p *RateLimitedProvider) Chat(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (*LLMResponse, error) {
+	provider, _ := options[ProviderOption].(string)
+	rl := p.limiters.Lookup(provider, model)
+	if rl == nil {
+		return p.delegate.Chat(ctx, messages, tools, model, options)
+	}
+
+	release, err := rl.Acquire(ctx, EstimateRequestTokens(messages, tools))
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.delegate.Chat(ctx, messages, tools, model, options)
+
+	used := 0
+	if resp != nil && resp.Usage != nil {
+		used = resp.Usage.TotalTokens
+	}
+	release(used)
+
+	return resp, err
+}
+
+func (p *RateLimitedProvider) GetDefaultModel() string {
+	return p.delegate.GetDefaultModel()
+}
+
+// Close closes the wrapped provider if it is stateful.
+func (p *RateLimitedProvider) Close() {
+	if sp, ok := p.delegate.(StatefulProvider); ok {
+		sp.Close()
+	}
+}
+
+// Limiters returns the registry shared with the fallback chain.
+func (p *RateLimitedProvider) Limiters() *RateLimiterRegistry {
+	return p.limiters
+}
+
+// Unwrap returns the decorated provider.
+func (p *RateLimitedProvider) Unwrap() LLMProvider {
+	return p.delegate
+}
+
+// RateLimitersOf returns the limiter registry of the first RateLimitedProvider
+// found by unwrapping p's decorator chain, or nil if there is none.
+func RateLimitersOf(p LLMProvider) *RateLimiterRegistry {
+	for p != nil {
+		if rl, ok := p.(*RateLimitedProvider); ok {
+			return rl.Limiters()
+		}
+		u, ok := p.(interface{ Unwrap() LLMProvider })
+		if !ok {
+			return nil
+		}
+		p = u.Unwrap()
+	}
+	return nil
+}
+
+// EstimateRequestTokens gives a rough prompt token count (~4 chars per token)
+// used to reserve TPM capacity before the real usage is known.
+func EstimateRequestTokens(messages []Message, tools []ToolDefinition) int {
+	chars := 0
+	for _, m := range messages {
+		chars += len(m.Content)
+		for _, tc := range m.ToolCalls {
+			if tc.Function != nil {
+				chars += len(tc.Function.Name) + len(tc.Function.Arguments)
+			}
+		}
+	}
+	for _, t := range tools {
+		chars += len(t.Function.Name) + len(t.Function.Description)
+		// Parameters are a JSON schema; approximate their size via fmt.
+		chars += len(fmt.Sprint(t.Function.Parameters))
+	}
+	return chars / 4
+}
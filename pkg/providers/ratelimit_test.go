@@ -0,0 +1,348 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+type countingProvider struct {
+	calls    atomic.Int32
+	inFlight atomic.Int32
+	peak     atomic.Int32
+	delay    time.Duration
+	usage    int
+}
+
+func (p *countingProvider) Chat(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (*LLMResponse, error) {
+	p.calls.Add(1)
+	n := p.inFlight.Add(1)
+	defer p.inFlight.Add(-1)
+	for {
+		peak := p.peak.Load()
+		if n <= peak || p.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	if p.delay > 0 {
+		time.Sleep(p.delay)
+	}
+	resp := &LLMResponse{Content: "ok", FinishReason: "stop"}
+	if p.usage > 0 {
+		resp.Usage = &UsageInfo{TotalTokens: p.usage}
+	}
+	return resp, nil
+}
+
+func (p *countingProvider) GetDefaultModel() string { return "test-model" }
+
+func TestRateLimitConfig_IsZero(t *testing.T) {
+	if !(RateLimitConfig{}).IsZero() {
+		t.Error("empty config should be zero")
+	}
+	if (RateLimitConfig{RPM: 10}).IsZero() {
+		t.Error("RPM config should not be zero")
+	}
+}
+
+func TestRateLimiter_RPMSpacing(t *testing.T) {
+	rl := NewRateLimiter(RateLimitConfig{RPM: 600}) // one request per 100ms
+
+	start := time.Now()
+	for range 3 {
+		release, err := rl.Acquire(context.Background(), 0)
+		if err != nil {
+			t.Fatalf("Acquire() error: %v", err)
+		}
+		release(0)
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("3 requests at 600 RPM took %v, want >= 150ms", elapsed)
+	}
+}
+
+func TestRateLimiter_ContextCanceled(t *testing.T) {
+	rl := NewRateLimiter(RateLimitConfig{RPM: 1})
+
+	release, err := rl.Acquire(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("first Acquire() error: %v", err)
+	}
+	release(0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := rl.Acquire(ctx, 0); err == nil {
+		t.Fatal("expected error when wait exceeds context deadline")
+	}
+}
+
+func TestRateLimiter_ConcurrencySlots(t *testing.T) {
+	rl := NewRateLimiter(RateLimitConfig{MaxConcurrent: 1})
+
+	release, err := rl.Acquire(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("Acquire() error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := rl.Acquire(ctx, 0); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("second Acquire() error = %v, want deadline exceeded", err)
+	}
+
+	release(0)
+	release(0) // double release must not free a second slot
+
+	release2, err := rl.Acquire(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("Acquire() after release error: %v", err)
+	}
+	release2(0)
+}
+
+func TestRateLimiter_TPMChargesActualUsage(t *testing.T) {
+	rl := NewRateLimiter(RateLimitConfig{TPM: 6000}) // 100 tokens/s
+
+	release, err := rl.Acquire(context.Background(), 100)
+	if err != nil {
+		t.Fatalf("Acquire() error: %v", err)
+	}
+	// Actual usage drained the whole bucket.
+	release(6000)
+
+	if wait := rl.EstimateWait(100); wait < 500*time.Millisecond {
+		t.Errorf("EstimateWait() = %v, want roughly 1s after bucket drained", wait)
+	}
+}
+
+func TestRateLimiterRegistry_FromConfig(t *testing.T) {
+	cfg := &config.Config{
+		ModelList: []config.ModelConfig{
+			{ModelName: "fast", Model: "groq/llama-3.3-70b", RPM: 30},
+			{ModelName: "free", Model: "openai/gpt-4o"},
+		},
+	}
+
+	registry := NewRateLimiterRegistryFromConfig(cfg, "")
+	if registry.Len() != 1 {
+		t.Fatalf("Len() = %d, want 1", registry.Len())
+	}
+	if registry.Lookup("groq", "llama-3.3-70b") == nil {
+		t.Error("Lookup by provider/model should find groq limiter")
+	}
+	if registry.Lookup("", "LLaMA-3.3-70b") == nil {
+		t.Error("Lookup by model ID should be case-insensitive")
+	}
+	if registry.Lookup("openai", "gpt-4o") != nil {
+		t.Error("entry without limits should not have a limiter")
+	}
+}
+
+func TestRateLimiterRegistry_SharedModelID(t *testing.T) {
+	cfg := &config.Config{
+		ModelList: []config.ModelConfig{
+			{ModelName: "direct", Model: "openai/gpt-4o", RPM: 10},
+			{ModelName: "routed", Model: "openrouter/gpt-4o", RPM: 600},
+		},
+	}
+	registry := NewRateLimiterRegistryFromConfig(cfg, "")
+
+	direct, routed := registry.Lookup("openai", "gpt-4o"), registry.Lookup("openrouter", "gpt-4o")
+	if direct == nil || routed == nil || direct == routed {
+		t.Fatalf("entries sharing a model ID should get their own limiters, got %p and %p", direct, routed)
+	}
+	if registry.Lookup("", "direct") != direct || registry.Lookup("openai", "routed") != routed {
+		t.Error("Lookup by model_name alias should find the entry's limiter")
+	}
+	if registry.Lookup("", "gpt-4o") != nil {
+		t.Error("Lookup of an ambiguous model ID without provider should not pick either limiter")
+	}
+
+	// Chat finds the limiter through the provider option.
+	release, err := direct.Acquire(context.Background(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release(0)
+	p := NewRateLimitedProvider(&countingProvider{}, registry)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := p.Chat(ctx, nil, nil, "gpt-4o", map[string]any{ProviderOption: "openrouter"}); err != nil {
+		t.Errorf("openrouter request waited on the openai limiter: %v", err)
+	}
+	if _, err := p.Chat(ctx, nil, nil, "gpt-4o", map[string]any{ProviderOption: "openai"}); err == nil {
+		t.Error("second openai request within a minute at 10 RPM should wait past the deadline")
+	}
+}
+
+func TestRateLimiterRegistry_PooledAliases(t *testing.T) {
+	cfg := &config.Config{
+		ModelList: []config.ModelConfig{
+			{ModelName: "pool", Model: "openai/gpt-4o", RPM: 10},
+			{ModelName: "pool", Model: "azure/gpt-4o", RPM: 10},
+			{ModelName: "backup", Model: "groq/llama-3.3-70b", RPM: 30},
+			{ModelName: "backup", Model: "cerebras/llama-3.3-70b", RPM: 60},
+		},
+	}
+	registry := NewRateLimiterRegistryFromConfig(cfg, "pool")
+
+	if registry.Lookup("openai", "gpt-4o") != nil || registry.Lookup("azure", "gpt-4o") != nil {
+		t.Error("entries of the balanced model_name are limited by the balancer, not the registry")
+	}
+	groq, cerebras := registry.Lookup("groq", "llama-3.3-70b"), registry.Lookup("cerebras", "llama-3.3-70b")
+	if groq == nil || cerebras == nil || groq == cerebras {
+		t.Errorf("entries of an unbalanced pool should each be limited, got %p and %p", groq, cerebras)
+	}
+	if registry.Lookup("", "backup") != nil {
+		t.Error("a model_name with several entries should not alias one of their limiters")
+	}
+}
+
+func TestRateLimitedProvider_EnforcesConcurrency(t *testing.T) {
+	registry := NewRateLimiterRegistry()
+	registry.Register("openai", "gpt-4o", RateLimitConfig{MaxConcurrent: 1})
+
+	inner := &countingProvider{delay: 20 * time.Millisecond}
+	p := NewRateLimitedProvider(inner, registry)
+
+	done := make(chan struct{})
+	for range 3 {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			if _, err := p.Chat(context.Background(), nil, nil, "gpt-4o", nil); err != nil {
+				t.Errorf("Chat() error: %v", err)
+			}
+		}()
+	}
+	for range 3 {
+		<-done
+	}
+
+	if inner.calls.Load() != 3 {
+		t.Errorf("calls = %d, want 3", inner.calls.Load())
+	}
+	if inner.peak.Load() != 1 {
+		t.Errorf("peak concurrency = %d, want 1", inner.peak.Load())
+	}
+}
+
+func TestRateLimitedProvider_UnlimitedModelPassesThrough(t *testing.T) {
+	registry := NewRateLimiterRegistry()
+	registry.Register("groq", "llama", RateLimitConfig{RPM: 1})
+
+	inner := &countingProvider{}
+	p := NewRateLimitedProvider(inner, registry)
+
+	for range 3 {
+		if _, err := p.Chat(context.Background(), nil, nil, "gpt-4o", nil); err != nil {
+			t.Fatalf("Chat() error: %v", err)
+		}
+	}
+	if inner.calls.Load() != 3 {
+		t.Errorf("calls = %d, want 3", inner.calls.Load())
+	}
+}
+
+func TestFallback_SkipsRateLimitedCandidate(t *testing.T) {
+	registry := NewRateLimiterRegistry()
+	rl := registry.Register("groq", "llama", RateLimitConfig{RPM: 1})
+	release, err := rl.Acquire(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("Acquire() error: %v", err)
+	}
+	release(0)
+
+	fc := NewFallbackChain(NewCooldownTracker())
+	fc.SetRateLimiters(registry, 5*time.Second)
+
+	candidates := []FallbackCandidate{
+		makeCandidate("groq", "llama"),
+		makeCandidate("openai", "gpt-4o"),
+	}
+	run := func(ctx context.Context, provider, model string) (*LLMResponse, error) {
+		if provider == "groq" {
+			t.Error("should not call groq (rate limited)")
+		}
+		return &LLMResponse{Content: "ok", FinishReason: "stop"}, nil
+	}
+
+	result, err := fc.Execute(context.Background(), candidates, 0, run)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Provider != "openai" {
+		t.Errorf("provider = %q, want openai", result.Provider)
+	}
+	if len(result.Attempts) != 1 || !result.Attempts[0].Skipped {
+		t.Errorf("attempts = %+v, want one skipped attempt", result.Attempts)
+	}
+}
+
+func TestFallback_SkipsTPMLimitedCandidate(t *testing.T) {
+	registry := NewRateLimiterRegistry()
+	rl := registry.Register("groq", "llama", RateLimitConfig{TPM: 1000})
+	release, err := rl.Acquire(context.Background(), 1000)
+	if err != nil {
+		t.Fatalf("Acquire() error: %v", err)
+	}
+	release(1000)
+
+	fc := NewFallbackChain(NewCooldownTracker())
+	fc.SetRateLimiters(registry, 5*time.Second)
+
+	candidates := []FallbackCandidate{
+		makeCandidate("groq", "llama"),
+		makeCandidate("openai", "gpt-4o"),
+	}
+	run := func(ctx context.Context, provider, model string) (*LLMResponse, error) {
+		if provider == "groq" {
+			t.Error("should not call groq (out of tokens)")
+		}
+		return &LLMResponse{Content: "ok", FinishReason: "stop"}, nil
+	}
+
+	result, err := fc.Execute(context.Background(), candidates, 500, run)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Provider != "openai" || len(result.Attempts) != 1 || !result.Attempts[0].Skipped {
+		t.Errorf("provider = %q, attempts = %+v; want openai after one skipped attempt", result.Provider, result.Attempts)
+	}
+}
+
+func TestFallback_LastRateLimitedCandidateIsTried(t *testing.T) {
+	registry := NewRateLimiterRegistry()
+	rl := registry.Register("groq", "llama", RateLimitConfig{RPM: 1})
+	release, err := rl.Acquire(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("Acquire() error: %v", err)
+	}
+	release(0)
+
+	fc := NewFallbackChain(NewCooldownTracker())
+	fc.SetRateLimiters(registry, time.Second)
+
+	called := false
+	run := func(ctx context.Context, provider, model string) (*LLMResponse, error) {
+		called = true
+		return &LLMResponse{Content: "ok", FinishReason: "stop"}, nil
+	}
+
+	if _, err := fc.Execute(context.Background(), []FallbackCandidate{makeCandidate("groq", "llama")}, 0, run); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !called {
+		t.Error("last candidate should be tried even when rate limited")
+	}
+}
@@ -0,0 +1,456 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+package providers
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// NoCacheOption is the Chat option key that makes a single call bypass the
+// response cache. It is stripped before the request reaches the delegate.
+const NoCacheOption = "no_cache"
+
+// responseCacheSaveInterval throttles writes of the cache file after stores.
+const responseCacheSaveInterval = time.Minute
+
+// currentTimeSection matches the per-minute clock line of the system prompt.
+var currentTimeSection = regexp.MustCompile(`(?m)^## Current Time\n[^\n]*`)
+
+// ResponseCacheStats is a snapshot of the cache counters.
+type ResponseCacheStats struct {
+	Hits        int64 `json:"hits"`
+	Misses      int64 `json:"misses"`
+	Bypassed    int64 `json:"bypassed"`
+	Stores      int64 `json:"stores"`
+	Evictions   int64 `json:"evictions"`
+	SavedTokens int64 `json:"saved_tokens"` // total tokens of the responses served from cache
+	Entries     int   `json:"entries"`
+	Bytes       int   `json:"bytes"`
+}
+
+// HitRate returns hits / (hits + misses), or 0 before the first lookup.
+func (s ResponseCacheStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+type responseCacheEntry struct {
+	Key       string          `json:"key"`
+	Response  json.RawMessage `json:"response"`
+	ExpiresAt time.Time       `json:"expires_at"`
+}
+
+type responseCacheFile struct {
+	Entries []responseCacheEntry `json:"entries"`
+}
+
+// ResponseCache is a bounded LRU of serialized LLM responses with a TTL and
+// optional on-disk persistence. It is safe for concurrent use.
+type ResponseCache struct {
+	ttl               time.Duration
+	maxEntries        int
+	maxBytes          int
+	path              string
+	maxTemperature    *float64
+	ignoreCurrentTime bool
+
+	mu       sync.Mutex
+	order    *list.List // front = most recently used; values are *responseCacheEntry
+	entries  map[string]*list.Element
+	bytes    int
+	stats    ResponseCacheStats
+	dirty    bool
+	lastSave time.Time
+	now      func() time.Time
+}
+
+// DefaultResponseCachePath returns ~/.picoclaw/response_cache.json.
+func DefaultResponseCachePath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".picoclaw", "response_cache.json")
+}
+
+// NewResponseCacheFromConfig creates a cache from the response_cache config
+// section and loads previously persisted entries.
+func NewResponseCacheFromConfig(cfg config.ResponseCacheConfig) *ResponseCache {
+	path := ""
+	if cfg.Persist {
+		path = cfg.Path
+		if path == "" {
+			path = DefaultResponseCachePath()
+		}
+	}
+
+	c := &ResponseCache{
+		ttl:               time.Duration(cfg.TTL) * time.Second,
+		maxEntries:        cfg.MaxEntries,
+		maxBytes:          cfg.MaxBytes,
+		path:              path,
+		maxTemperature:    cfg.MaxTemperature,
+		ignoreCurrentTime: cfg.IgnoreCurrentTime,
+		order:             list.New(),
+		entries:           make(map[string]*list.Element),
+		now:               time.Now,
+	}
+	if err := c.Load(); err != nil {
+		logger.WarnCF("provider.cache", "Failed to load response cache", map[string]any{"error": err.Error()})
+	}
+	return c
+}
+
+// Key returns the cache key of a request: a SHA-256 over the model, the
+// normalized messages, the tool definitions and the options.
+func (c *ResponseCache) Key(messages []Message, tools []ToolDefinition, model string, options map[string]any) string {
+	type keyToolCall struct {
+		ID        string `json:"id"`
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	}
+	type keyMessage struct {
+		Role       string        `json:"role"`
+		Content    string        `json:"content"`
+		Media      []string      `json:"media,omitempty"`
+		ToolCalls  []keyToolCall `json:"tool_calls,omitempty"`
+		ToolCallID string        `json:"tool_call_id,omitempty"`
+	}
+
+	msgs := make([]keyMessage, 0, len(messages))
+	for _, m := range messages {
+		content := m.Content
+		if c.ignoreCurrentTime && m.Role == "system" {
+			content = currentTimeSection.ReplaceAllString(content, "## Current Time")
+		}
+		km := keyMessage{Role: m.Role, Content: content, Media: m.Media, ToolCallID: m.ToolCallID}
+		for _, tc := range m.ToolCalls {
+			tc = NormalizeToolCall(tc)
+			km.ToolCalls = append(km.ToolCalls, keyToolCall{
+				ID:        tc.ID,
+				Name:      tc.Function.Name,
+				Arguments: tc.Function.Arguments,
+			})
+		}
+		msgs = append(msgs, km)
+	}
+
+	opts := make(map[string]any, len(options))
+	for k, v := range options {
+		if k != NoCacheOption {
+			opts[k] = v
+		}
+	}
+
+	// json.Marshal sorts map keys, so the encoding is deterministic.
+	data, _ := json.Marshal(struct {
+		Model    string           `json:"model"`
+		Messages []keyMessage     `json:"messages"`
+		Tools    []ToolDefinition `json:"tools,omitempty"`
+		Options  map[string]any   `json:"options,omitempty"`
+	}{model, msgs, tools, opts})
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Bypass reports whether a request must not be served from or stored in the
+// cache: either the caller asked for it or the sampling is too random.
+func (c *ResponseCache) Bypass(options map[string]any) bool {
+	if noCache, _ := options[NoCacheOption].(bool); noCache {
+		return true
+	}
+	if c.maxTemperature == nil {
+		return false
+	}
+	switch t := options["temperature"].(type) {
+	case float64:
+		return t > *c.maxTemperature
+	case float32:
+		return float64(t) > *c.maxTemperature
+	case int:
+		return float64(t) > *c.maxTemperature
+	}
+	return false
+}
+
+// Get returns the cached response for key, or nil on a miss.
+func (c *ResponseCache) Get(key string) *LLMResponse {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		c.stats.Misses++
+		return nil
+	}
+	entry := el.Value.(*responseCacheEntry)
+	if c.expired(entry) {
+		c.removeElement(el)
+		c.stats.Misses++
+		return nil
+	}
+
+	var resp LLMResponse
+	// Tool calls may have been stored by older versions; they are never replayed.
+	if err := json.Unmarshal(entry.Response, &resp); err != nil || len(resp.ToolCalls) > 0 {
+		c.removeElement(el)
+		c.stats.Misses++
+		return nil
+	}
+
+	c.order.MoveToFront(el)
+	c.stats.Hits++
+	if resp.Usage != nil {
+		c.stats.SavedTokens += int64(resp.Usage.TotalTokens)
+	}
+	return &resp
+}
+
+// Put stores resp under key, evicting least recently used entries to stay
+// within the size bounds. Truncated and empty responses are not cached, and
+// neither are tool calls: replaying them would run the tools again without
+// a fresh decision by the model.
+func (c *ResponseCache) Put(key string, resp *LLMResponse) {
+	if resp == nil || resp.FinishReason == "length" || resp.Content == "" || len(resp.ToolCalls) > 0 {
+		return
+	}
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return
+	}
+	if c.maxBytes > 0 && len(data) > c.maxBytes {
+		return
+	}
+
+	c.mu.Lock()
+	if el, ok := c.entries[key]; ok {
+		c.removeElement(el)
+	}
+	entry := &responseCacheEntry{Key: key, Response: data, ExpiresAt: c.now().Add(c.ttl)}
+	c.entries[key] = c.order.PushFront(entry)
+	c.bytes += len(data)
+	c.stats.Stores++
+	c.evictLocked()
+	c.dirty = true
+	save := c.path != "" && c.now().Sub(c.lastSave) >= responseCacheSaveInterval
+	c.mu.Unlock()
+
+	if save {
+		if err := c.Save(); err != nil {
+			logger.WarnCF("provider.cache", "Failed to save response cache", map[string]any{"error": err.Error()})
+		}
+	}
+}
+
+// Stats returns a snapshot of the cache counters.
+func (c *ResponseCache) Stats() ResponseCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.stats
+	s.Entries = len(c.entries)
+	s.Bytes = c.bytes
+	return s
+}
+
+// Load reads persisted entries, skipping expired ones.
+// A missing file is not an error.
+func (c *ResponseCache) Load() error {
+	if c.path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("reading response cache: %w", err)
+	}
+
+	var file responseCacheFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("parsing response cache: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// The file is ordered most recently used first.
+	for i := len(file.Entries) - 1; i >= 0; i-- {
+		entry := file.Entries[i]
+		if c.expired(&entry) {
+			continue
+		}
+		if el, ok := c.entries[entry.Key]; ok {
+			c.removeElement(el)
+		}
+		c.entries[entry.Key] = c.order.PushFront(&entry)
+		c.bytes += len(entry.Response)
+	}
+	c.evictLocked()
+	c.lastSave = c.now()
+	return nil
+}
+
+// Save writes the unexpired entries to the cache file if anything changed
+// since the last save.
+func (c *ResponseCache) Save() error {
+	if c.path == "" {
+		return nil
+	}
+
+	c.mu.Lock()
+	if !c.dirty {
+		c.mu.Unlock()
+		return nil
+	}
+	file := responseCacheFile{Entries: make([]responseCacheEntry, 0, len(c.entries))}
+	for el := c.order.Front(); el != nil; el = el.Next() {
+		entry := el.Value.(*responseCacheEntry)
+		if !c.expired(entry) {
+			file.Entries = append(file.Entries, *entry)
+		}
+	}
+	c.dirty = false
+	c.lastSave = c.now()
+	c.mu.Unlock()
+
+	data, err := json.Marshal(file)
+	if err != nil {
+		return fmt.Errorf("encoding response cache: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return fmt.Errorf("creating cache dir: %w", err)
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("writing response cache: %w", err)
+	}
+	return os.Rename(tmp, c.path)
+}
+
+func (c *ResponseCache) recordBypass() {
+	c.mu.Lock()
+	c.stats.Bypassed++
+	c.mu.Unlock()
+}
+
+func (c *ResponseCache) expired(entry *responseCacheEntry) bool {
+	return c.ttl > 0 && !c.now().Before(entry.ExpiresAt)
+}
+
+func (c *ResponseCache) evictLocked() {
+	for c.order.Len() > 0 &&
+		((c.maxEntries > 0 && c.order.Len() > c.maxEntries) || (c.maxBytes > 0 && c.bytes > c.maxBytes)) {
+		c.removeElement(c.order.Back())
+		c.stats.Evictions++
+	}
+}
+
+func (c *ResponseCache) removeElement(el *list.Element) {
+	entry := c.order.Remove(el).(*responseCacheEntry)
+	delete(c.entries, entry.Key)
+	c.bytes -= len(entry.Response)
+	c.dirty = true
+}
+
+// CachingProvider is an LLMProvider decorator that serves repeated identical
+// requests from a ResponseCache instead of calling the delegate.
+type CachingProvider struct {
+	delegate LLMProvider
+	cache    *ResponseCache
+}
+
+// NewCachingProvider wraps delegate so that Chat consults cache first.
+func NewCachingProvider(delegate LLMProvider, cache *ResponseCache) *CachingProvider {
+	return &CachingProvider{
+		delegate: delegate,
+		cache:    cache,
+	}
+}
+
+func (p *CachingProvider) Chat(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (*LLMResponse, error) {
+	if p.cache.Bypass(options) {
+		p.cache.recordBypass()
+		return p.delegate.Chat(ctx, messages, tools, model, withoutNoCache(options))
+	}
+
+	key := p.cache.Key(messages, tools, model, options)
+	if resp := p.cache.Get(key); resp != nil {
+		logger.DebugCF("provider.cache", "Serving cached response", map[string]any{"model": model})
+		return resp, nil
+	}
+
+	resp, err := p.delegate.Chat(ctx, messages, tools, model, withoutNoCache(options))
+	if err == nil {
+		p.cache.Put(key, resp)
+	}
+	return resp, err
+}
+
+func (p *CachingProvider) GetDefaultModel() string {
+	return p.delegate.GetDefaultModel()
+}
+
+// Close persists the cache, logs its counters and closes the wrapped
+// provider if it is stateful.
+func (p *CachingProvider) Close() {
+	if err := p.cache.Save(); err != nil {
+		logger.WarnCF("provider.cache", "Failed to save response cache", map[string]any{"error": err.Error()})
+	}
+	stats := p.cache.Stats()
+	logger.InfoCF("provider.cache", "Response cache stats", map[string]any{
+		"hits":         stats.Hits,
+		"misses":       stats.Misses,
+		"bypassed":     stats.Bypassed,
+		"saved_tokens": stats.SavedTokens,
+		"entries":      stats.Entries,
+	})
+	if sp, ok := p.delegate.(StatefulProvider); ok {
+		sp.Close()
+	}
+}
+
+// Cache returns the underlying response cache.
+func (p *CachingProvider) Cache() *ResponseCache {
+	return p.cache
+}
+
+// Unwrap returns the decorated provider.
+func (p *CachingProvider) Unwrap() LLMProvider {
+	return p.delegate
+}
+
+func withoutNoCache(options map[string]any) map[string]any {
+	if _, ok := options[NoCacheOption]; !ok {
+		return options
+	}
+	out := make(map[string]any, len(options))
+	for k, v := range options {
+		if k != NoCacheOption {
+			out[k] = v
+		}
+	}
+	return out
+}
@@ -0,0 +1,176 @@
+package providers
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func newTestResponseCache(t *testing.T, cfg config.ResponseCacheConfig) *ResponseCache {
+	t.Helper()
+	if cfg.Persist && cfg.Path == "" {
+		cfg.Path = filepath.Join(t.TempDir(), "response_cache.json")
+	}
+	return NewResponseCacheFromConfig(cfg)
+}
+
+func TestCachingProvider_ServesRepeatedRequests(t *testing.T) {
+	delegate := &countingProvider{usage: 42}
+	cache := newTestResponseCache(t, config.ResponseCacheConfig{TTL: 60, MaxEntries: 10})
+	p := NewCachingProvider(delegate, cache)
+
+	msgs := []Message{{Role: "user", Content: "ping"}}
+	for range 3 {
+		resp, err := p.Chat(t.Context(), msgs, nil, "m", map[string]any{"max_tokens": 100})
+		if err != nil || resp.Content != "ok" {
+			t.Fatalf("Chat() = %+v, %v", resp, err)
+		}
+	}
+	if got := delegate.calls.Load(); got != 1 {
+		t.Errorf("delegate calls = %d, want 1", got)
+	}
+
+	// A different option is a different request.
+	p.Chat(t.Context(), msgs, nil, "m", map[string]any{"max_tokens": 200})
+	if got := delegate.calls.Load(); got != 2 {
+		t.Errorf("delegate calls = %d, want 2", got)
+	}
+
+	stats := cache.Stats()
+	if stats.Hits != 2 || stats.Misses != 2 || stats.Stores != 2 || stats.SavedTokens != 84 {
+		t.Errorf("stats = %+v", stats)
+	}
+	if stats.HitRate() != 0.5 {
+		t.Errorf("HitRate() = %v, want 0.5", stats.HitRate())
+	}
+}
+
+func TestCachingProvider_Bypass(t *testing.T) {
+	maxTemp := 0.2
+	delegate := &countingProvider{}
+	cache := newTestResponseCache(t, config.ResponseCacheConfig{TTL: 60, MaxTemperature: &maxTemp})
+	p := NewCachingProvider(delegate, cache)
+
+	msgs := []Message{{Role: "user", Content: "roll a die"}}
+	for range 2 {
+		p.Chat(t.Context(), msgs, nil, "m", map[string]any{"temperature": 0.9})
+		p.Chat(t.Context(), msgs, nil, "m", map[string]any{NoCacheOption: true})
+	}
+	if got := delegate.calls.Load(); got != 4 {
+		t.Errorf("delegate calls = %d, want 4", got)
+	}
+	if stats := cache.Stats(); stats.Bypassed != 4 || stats.Entries != 0 {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	p.Chat(t.Context(), msgs, nil, "m", map[string]any{"temperature": 0.1})
+	p.Chat(t.Context(), msgs, nil, "m", map[string]any{"temperature": 0.1})
+	if got := delegate.calls.Load(); got != 5 {
+		t.Errorf("delegate calls = %d, want 5", got)
+	}
+}
+
+func TestResponseCache_KeyIgnoresCurrentTime(t *testing.T) {
+	at := func(clock string) []Message {
+		return []Message{
+			{Role: "system", Content: "You are helpful.\n\n## Current Time\n" + clock + "\n\n## Runtime\nlinux"},
+			{Role: "user", Content: "hi"},
+		}
+	}
+
+	c := newTestResponseCache(t, config.ResponseCacheConfig{IgnoreCurrentTime: true})
+	if c.Key(at("2026-01-01 10:00 (Thursday)"), nil, "m", nil) != c.Key(at("2026-01-01 10:01 (Thursday)"), nil, "m", nil) {
+		t.Error("keys differ although only the current time changed")
+	}
+	if c.Key(at("x"), nil, "m", nil) == c.Key(at("x"), nil, "other", nil) {
+		t.Error("keys must differ across models")
+	}
+
+	strict := newTestResponseCache(t, config.ResponseCacheConfig{})
+	if strict.Key(at("10:00"), nil, "m", nil) == strict.Key(at("10:01"), nil, "m", nil) {
+		t.Error("keys must include the current time unless ignore_current_time is set")
+	}
+}
+
+func TestResponseCache_TTLAndEviction(t *testing.T) {
+	now := time.Now()
+	c := newTestResponseCache(t, config.ResponseCacheConfig{TTL: 60, MaxEntries: 2})
+	c.now = func() time.Time { return now }
+
+	resp := &LLMResponse{Content: "ok", FinishReason: "stop"}
+	c.Put("a", resp)
+	c.Put("b", resp)
+	c.Get("a") // a becomes most recently used
+	c.Put("c", resp)
+
+	if c.Get("b") != nil {
+		t.Error("least recently used entry b should have been evicted")
+	}
+	if c.Get("a") == nil || c.Get("c") == nil {
+		t.Error("entries a and c should be cached")
+	}
+
+	now = now.Add(61 * time.Second)
+	if c.Get("a") != nil {
+		t.Error("entry should have expired")
+	}
+
+	c.Put("truncated", &LLMResponse{Content: "partial", FinishReason: "length"})
+	c.Put("empty", &LLMResponse{FinishReason: "stop"})
+	if c.Get("truncated") != nil || c.Get("empty") != nil {
+		t.Error("truncated and empty responses must not be cached")
+	}
+}
+
+func TestResponseCache_Persistence(t *testing.T) {
+	cfg := config.ResponseCacheConfig{
+		TTL:     60,
+		Persist: true,
+		Path:    filepath.Join(t.TempDir(), "cache", "response_cache.json"),
+	}
+
+	c := NewResponseCacheFromConfig(cfg)
+	c.Put("k", &LLMResponse{Content: "hello", FinishReason: "stop", Usage: &UsageInfo{TotalTokens: 12}})
+	if err := c.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	reloaded := NewResponseCacheFromConfig(cfg)
+	resp := reloaded.Get("k")
+	if resp == nil {
+		t.Fatal("entry was not persisted")
+	}
+	if resp.Content != "hello" || resp.Usage == nil || resp.Usage.TotalTokens != 12 {
+		t.Errorf("reloaded response = %+v", resp)
+	}
+}
+
+func TestResponseCache_SkipsToolCalls(t *testing.T) {
+	c := newTestResponseCache(t, config.ResponseCacheConfig{})
+	c.Put("k", &LLMResponse{
+		Content:      "Let me check.",
+		FinishReason: "tool_calls",
+		ToolCalls:    []ToolCall{{ID: "call_1", Name: "exec", Arguments: map[string]any{"command": "rm -rf build"}}},
+	})
+	if resp := c.Get("k"); resp != nil {
+		t.Errorf("Get() = %+v, want tool calls never replayed", resp)
+	}
+	if stats := c.Stats(); stats.Stores != 0 {
+		t.Errorf("Stores = %d, want 0", stats.Stores)
+	}
+}
+
+func TestRateLimitersOf_UnwrapsDecorators(t *testing.T) {
+	limiters := NewRateLimiterRegistry()
+	rl := NewRateLimitedProvider(&countingProvider{}, limiters)
+	cached := NewCachingProvider(rl, newTestResponseCache(t, config.ResponseCacheConfig{}))
+
+	if RateLimitersOf(cached) != limiters {
+		t.Error("RateLimitersOf did not find the limiter registry behind the cache")
+	}
+	if RateLimitersOf(&countingProvider{}) != nil {
+		t.Error("RateLimitersOf should return nil for an undecorated provider")
+	}
+}
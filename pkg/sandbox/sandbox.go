@@ -0,0 +1,80 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+// Package sandbox confines shell commands run by the exec and process tools.
+// On Linux the command is started through a helper (the picoclaw binary
+// itself, see Init) that enters new user, mount and network namespaces,
+// applies rlimits and a Landlock ruleset that only allows writes to the
+// workspace, and then execs the command. Features the kernel lacks are
+// skipped with a warning; on other platforms commands run unconfined.
+package sandbox
+
+import (
+	"path/filepath"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// envSpec carries the helper spec from the parent to the helper process.
+const envSpec = "PICOCLAW_SANDBOX_SPEC"
+
+// probeToken is printed by a helper started in probe mode.
+const probeToken = "picoclaw-sandbox-ok"
+
+// Policy describes how a command is confined.
+type Policy struct {
+	// Writable lists the directories the command may modify; everything
+	// else is read-only. The first entry is the workspace.
+	Writable []string `json:"writable"`
+	// NoNetwork cuts the command off from the network.
+	NoNetwork bool `json:"no_network,omitempty"`
+	// CPUSeconds, MemoryBytes and MaxProcesses set RLIMIT_CPU, RLIMIT_AS
+	// and RLIMIT_NPROC; zero leaves the limit unchanged.
+	CPUSeconds   uint64 `json:"cpu_seconds,omitempty"`
+	MemoryBytes  uint64 `json:"memory_bytes,omitempty"`
+	MaxProcesses uint64 `json:"max_processes,omitempty"`
+}
+
+// FromConfig returns the policy for an agent's sandbox settings, or nil
+// when the sandbox is disabled.
+func FromConfig(cfg *config.SandboxConfig, workspace string) *Policy {
+	if cfg == nil || cfg.Enabled == nil || !*cfg.Enabled {
+		return nil
+	}
+	p := &Policy{
+		NoNetwork:    cfg.NoNetwork != nil && *cfg.NoNetwork,
+		CPUSeconds:   uint64(max(cfg.CPUSeconds, 0)),
+		MemoryBytes:  uint64(max(cfg.MemoryMB, 0)) << 20,
+		MaxProcesses: uint64(max(cfg.MaxProcesses, 0)),
+	}
+	for _, dir := range append([]string{workspace}, cfg.WritablePaths...) {
+		if dir == "" {
+			continue
+		}
+		if abs, err := filepath.Abs(dir); err == nil {
+			p.Writable = append(p.Writable, abs)
+		}
+	}
+	return p
+}
+
+// Support reports which confinement features the running kernel offers.
+type Support struct {
+	// Namespaces is true when unprivileged user, mount and network
+	// namespaces can be created.
+	Namespaces bool
+	// Landlock is the Landlock ABI version, 0 when unavailable.
+	Landlock int
+}
+
+// spec is what the parent hands to the helper process.
+type spec struct {
+	Probe      bool     `json:"probe,omitempty"`
+	Policy     Policy   `json:"policy"`
+	Path       string   `json:"path,omitempty"`
+	Args       []string `json:"args,omitempty"`
+	Namespaces bool     `json:"namespaces,omitempty"`
+	Landlock   int      `json:"landlock,omitempty"`
+}
@@ -0,0 +1,328 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// selfExe re-executes the running binary, even if it was replaced on disk.
+const selfExe = "/proc/self/exe"
+
+// helperExitCode is returned when the helper cannot set up the sandbox.
+const helperExitCode = 126
+
+// devFiles stay writable so commands can use redirections like >/dev/null.
+var devFiles = []string{"/dev/null", "/dev/zero", "/dev/full", "/dev/random", "/dev/urandom", "/dev/tty"}
+
+var (
+	probeOnce sync.Once
+	probed    Support
+	probeErr  error
+)
+
+// Init turns the process into the sandbox helper when it was started by
+// Apply: it confines itself and execs the command, and never returns. In
+// any other process it returns immediately. Call it first thing in main,
+// and in TestMain of tests that run sandboxed commands.
+func Init() {
+	data, ok := os.LookupEnv(envSpec)
+	if !ok {
+		return
+	}
+	os.Unsetenv(envSpec)
+
+	var s spec
+	if err := json.Unmarshal([]byte(data), &s); err != nil {
+		fmt.Fprintf(os.Stderr, "picoclaw sandbox: invalid spec: %v\n", err)
+		os.Exit(helperExitCode)
+	}
+	if s.Probe {
+		if s.Namespaces && makeMountsPrivate() != nil {
+			os.Exit(helperExitCode)
+		}
+		fmt.Print(probeToken)
+		os.Exit(0)
+	}
+
+	err := confineAndExec(&s)
+	fmt.Fprintf(os.Stderr, "picoclaw sandbox: %v\n", err)
+	os.Exit(helperExitCode)
+}
+
+// Probe checks once which features are available and whether the helper
+// can be started at all (Init must be called by the binary).
+func Probe() (Support, error) {
+	probeOnce.Do(func() {
+		probed.Landlock = landlockABI()
+		if runProbe(true) {
+			probed.Namespaces = true
+		} else if !runProbe(false) {
+			probeErr = fmt.Errorf("sandbox helper failed to start; the binary must call sandbox.Init")
+			return
+		}
+
+		fields := map[string]any{"namespaces": probed.Namespaces, "landlock_abi": probed.Landlock}
+		switch {
+		case probed.Landlock == 0:
+			logger.WarnCF("sandbox", "Landlock unavailable; sandboxed commands can write outside the workspace", fields)
+		case !probed.Namespaces:
+			logger.WarnCF("sandbox", "User namespaces unavailable; no private /tmp, network blocking limited to TCP", fields)
+		default:
+			logger.InfoCF("sandbox", "Sandbox available", fields)
+		}
+	})
+	return probed, probeErr
+}
+
+func runProbe(namespaces bool) bool {
+	data, _ := json.Marshal(spec{Probe: true, Namespaces: namespaces})
+	cmd := exec.Command(selfExe)
+	cmd.Env = append(os.Environ(), envSpec+"="+string(data))
+	if namespaces {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+		setNamespaces(cmd.SysProcAttr, true)
+	}
+	out, err := cmd.Output()
+	return err == nil && string(out) == probeToken
+}
+
+// Apply rewrites cmd so it starts through the sandbox helper. cmd must not
+// have been started; its SysProcAttr settings are kept.
+func (p *Policy) Apply(cmd *exec.Cmd) error {
+	if cmd.Err != nil {
+		return cmd.Err
+	}
+	support, err := Probe()
+	if err != nil {
+		return err
+	}
+
+	s := spec{
+		Policy:     *p,
+		Path:       cmd.Path,
+		Args:       cmd.Args,
+		Namespaces: support.Namespaces,
+		Landlock:   support.Landlock,
+	}
+	if p.NoNetwork && !support.Namespaces && support.Landlock < 4 {
+		logger.WarnCF("sandbox", "Cannot block network access on this kernel", nil)
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	cmd.Env = append(env, envSpec+"="+string(data))
+	cmd.Path = selfExe
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	if support.Namespaces {
+		setNamespaces(cmd.SysProcAttr, p.NoNetwork)
+	}
+	return nil
+}
+
+// setNamespaces starts the process in new user and mount namespaces (and a
+// network namespace with only a down loopback interface when isolating the
+// network), mapped to the caller's own uid and gid.
+func setNamespaces(attr *syscall.SysProcAttr, newNet bool) {
+	attr.Cloneflags |= syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS
+	if newNet {
+		attr.Cloneflags |= syscall.CLONE_NEWNET
+	}
+	attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getuid(), HostID: os.Getuid(), Size: 1}}
+	attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getgid(), HostID: os.Getgid(), Size: 1}}
+	attr.GidMappingsEnableSetgroups = false
+}
+
+func makeMountsPrivate() error {
+	return unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, "")
+}
+
+// confineAndExec runs in the helper: it applies the policy to itself and
+// execs the command, which inherits every restriction.
+func confineAndExec(s *spec) error {
+	// Landlock and no_new_privs apply to the calling thread, which must
+	// then be the one that execs.
+	runtime.LockOSThread()
+
+	writable := s.Policy.Writable
+	if s.Namespaces {
+		if err := makeMountsPrivate(); err != nil {
+			return fmt.Errorf("making mounts private: %w", err)
+		}
+		if privateTmpAllowed(writable) {
+			if err := unix.Mount("tmpfs", "/tmp", "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "mode=1777"); err == nil {
+				writable = append(writable, "/tmp")
+			}
+		}
+	}
+
+	if err := setRlimits(&s.Policy); err != nil {
+		return err
+	}
+
+	if s.Landlock > 0 {
+		blockTCP := s.Policy.NoNetwork && !s.Namespaces
+		if err := restrictSelf(s.Landlock, writable, blockTCP); err != nil {
+			return fmt.Errorf("landlock: %w", err)
+		}
+	}
+
+	return syscall.Exec(s.Path, s.Args, os.Environ())
+}
+
+// privateTmpAllowed reports whether /tmp can be replaced by a private tmpfs
+// without hiding a writable directory that lives under it.
+func privateTmpAllowed(writable []string) bool {
+	for _, dir := range writable {
+		if dir == "/tmp" || strings.HasPrefix(dir, "/tmp/") {
+			return false
+		}
+	}
+	return true
+}
+
+func setRlimits(p *Policy) error {
+	limits := []struct {
+		resource int
+		value    uint64
+		name     string
+	}{
+		{unix.RLIMIT_CPU, p.CPUSeconds, "cpu"},
+		{unix.RLIMIT_AS, p.MemoryBytes, "memory"},
+		{unix.RLIMIT_NPROC, p.MaxProcesses, "processes"},
+	}
+	for _, l := range limits {
+		if l.value == 0 {
+			continue
+		}
+		rl := unix.Rlimit{Cur: l.value, Max: l.value}
+		if err := unix.Setrlimit(l.resource, &rl); err != nil {
+			return fmt.Errorf("setting %s limit: %w", l.name, err)
+		}
+	}
+	return nil
+}
+
+func landlockABI() int {
+	abi, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, 0, 0, unix.LANDLOCK_CREATE_RULESET_VERSION)
+	if errno != 0 {
+		return 0
+	}
+	return int(abi)
+}
+
+// Landlock access rights by ABI version.
+const (
+	fsReadAccess = unix.LANDLOCK_ACCESS_FS_EXECUTE | unix.LANDLOCK_ACCESS_FS_READ_FILE | unix.LANDLOCK_ACCESS_FS_READ_DIR
+	fsAccessV1   = 1<<13 - 1
+)
+
+func handledFSAccess(abi int) uint64 {
+	access := uint64(fsAccessV1)
+	if abi >= 2 {
+		access |= unix.LANDLOCK_ACCESS_FS_REFER
+	}
+	if abi >= 3 {
+		access |= unix.LANDLOCK_ACCESS_FS_TRUNCATE
+	}
+	if abi >= 5 {
+		access |= unix.LANDLOCK_ACCESS_FS_IOCTL_DEV
+	}
+	return access
+}
+
+// restrictSelf allows reading and executing everything, writing only below
+// the writable directories and to a few device files, and optionally
+// blocks TCP. From ABI 6 on, signals to processes outside the sandbox are
+// blocked too.
+func restrictSelf(abi int, writable []string, blockTCP bool) error {
+	handled := handledFSAccess(abi)
+	attr := unix.LandlockRulesetAttr{Access_fs: handled}
+	size := unsafe.Sizeof(attr.Access_fs)
+	if abi >= 4 {
+		size += unsafe.Sizeof(attr.Access_net)
+		if blockTCP {
+			attr.Access_net = unix.LANDLOCK_ACCESS_NET_BIND_TCP | unix.LANDLOCK_ACCESS_NET_CONNECT_TCP
+		}
+	}
+	if abi >= 6 {
+		size += unsafe.Sizeof(attr.Scoped)
+		attr.Scoped = unix.LANDLOCK_SCOPE_SIGNAL
+	}
+
+	fd, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, uintptr(unsafe.Pointer(&attr)), size, 0)
+	if errno != 0 {
+		return fmt.Errorf("creating ruleset: %w", errno)
+	}
+	ruleset := int(fd)
+	defer unix.Close(ruleset)
+
+	if err := addPathRule(ruleset, "/", fsReadAccess); err != nil {
+		return err
+	}
+	for _, dir := range writable {
+		if err := addPathRule(ruleset, dir, handled); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	fileAccess := handled & (unix.LANDLOCK_ACCESS_FS_READ_FILE | unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+		unix.LANDLOCK_ACCESS_FS_TRUNCATE | unix.LANDLOCK_ACCESS_FS_IOCTL_DEV)
+	for _, dev := range devFiles {
+		addPathRule(ruleset, dev, fileAccess)
+	}
+
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("setting no_new_privs: %w", err)
+	}
+	if _, _, errno := unix.Syscall(unix.SYS_LANDLOCK_RESTRICT_SELF, uintptr(ruleset), 0, 0); errno != 0 {
+		return fmt.Errorf("restricting self: %w", errno)
+	}
+	return nil
+}
+
+func addPathRule(ruleset int, path string, access uint64) error {
+	fd, err := unix.Open(filepath.Clean(path), unix.O_PATH|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	defer unix.Close(fd)
+
+	var st unix.Stat_t
+	if err := unix.Fstat(fd, &st); err != nil {
+		return err
+	}
+	if st.Mode&unix.S_IFMT != unix.S_IFDIR {
+		// Directory-only rights are rejected on files.
+		access &= unix.LANDLOCK_ACCESS_FS_EXECUTE | unix.LANDLOCK_ACCESS_FS_READ_FILE |
+			unix.LANDLOCK_ACCESS_FS_WRITE_FILE | unix.LANDLOCK_ACCESS_FS_TRUNCATE | unix.LANDLOCK_ACCESS_FS_IOCTL_DEV
+	}
+
+	rule := unix.LandlockPathBeneathAttr{Allowed_access: access, Parent_fd: int32(fd)}
+	_, _, errno := unix.Syscall6(unix.SYS_LANDLOCK_ADD_RULE, uintptr(ruleset), unix.LANDLOCK_RULE_PATH_BENEATH,
+		uintptr(unsafe.Pointer(&rule)), 0, 0, 0)
+	if errno != 0 {
+		return fmt.Errorf("adding rule for %s: %w", path, errno)
+	}
+	return nil
+}
@@ -0,0 +1,251 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// envDial makes the test binary dial the given address and exit, so network
+// isolation can be checked without relying on external tools.
+const envDial = "PICOCLAW_SANDBOX_TEST_DIAL"
+
+func TestMain(m *testing.M) {
+	Init()
+	if addr := os.Getenv(envDial); addr != "" {
+		conn, err := net.DialTimeout("tcp", addr, 2*time.Second)
+		if err != nil {
+			fmt.Print("blocked")
+			os.Exit(0)
+		}
+		conn.Close()
+		fmt.Print("connected")
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func requireLandlock(t *testing.T) Support {
+	t.Helper()
+	support, err := Probe()
+	if err != nil {
+		t.Skipf("sandbox helper unavailable: %v", err)
+	}
+	if support.Landlock == 0 {
+		t.Skip("Landlock not supported by this kernel")
+	}
+	return support
+}
+
+func runSandboxed(t *testing.T, p *Policy, dir, command string) (string, error) {
+	t.Helper()
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Dir = dir
+	if err := p.Apply(cmd); err != nil {
+		t.Fatalf("Apply() error: %v", err)
+	}
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err := cmd.Run()
+	return out.String(), err
+}
+
+// newPolicy returns a policy for a fresh workspace plus a directory outside
+// it.
+func newPolicy(t *testing.T) (p *Policy, workspace, outside string) {
+	t.Helper()
+	base := t.TempDir()
+	workspace = filepath.Join(base, "workspace")
+	outside = filepath.Join(base, "outside")
+	for _, dir := range []string{workspace, outside} {
+		if err := os.Mkdir(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	enabled := true
+	return FromConfig(&config.SandboxConfig{Enabled: &enabled}, workspace), workspace, outside
+}
+
+func TestFromConfig(t *testing.T) {
+	if p := FromConfig(nil, "/ws"); p != nil {
+		t.Errorf("FromConfig(nil) = %+v, want nil", p)
+	}
+	disabled := false
+	if p := FromConfig(&config.SandboxConfig{Enabled: &disabled}, "/ws"); p != nil {
+		t.Errorf("FromConfig(disabled) = %+v, want nil", p)
+	}
+
+	enabled, noNet := true, true
+	p := FromConfig(&config.SandboxConfig{
+		Enabled:       &enabled,
+		NoNetwork:     &noNet,
+		WritablePaths: []string{"/cache"},
+		CPUSeconds:    5,
+		MemoryMB:      64,
+		MaxProcesses:  32,
+	}, "/ws")
+	if p == nil {
+		t.Fatal("FromConfig(enabled) = nil")
+	}
+	if len(p.Writable) != 2 || p.Writable[0] != "/ws" || p.Writable[1] != "/cache" {
+		t.Errorf("Writable = %v, want [/ws /cache]", p.Writable)
+	}
+	if !p.NoNetwork || p.CPUSeconds != 5 || p.MemoryBytes != 64<<20 || p.MaxProcesses != 32 {
+		t.Errorf("unexpected policy %+v", p)
+	}
+}
+
+func TestApply_WritesConfinedToWorkspace(t *testing.T) {
+	requireLandlock(t)
+	p, workspace, outside := newPolicy(t)
+
+	out, err := runSandboxed(t, p, workspace, "echo hi > inside.txt && cat inside.txt")
+	if err != nil || strings.TrimSpace(out) != "hi" {
+		t.Fatalf("write inside workspace failed: %v, output %q", err, out)
+	}
+
+	target := filepath.Join(outside, "escape.txt")
+	if out, err := runSandboxed(t, p, workspace, "echo pwned > "+target); err == nil {
+		t.Errorf("write outside workspace succeeded, output %q", out)
+	}
+	if _, err := os.Stat(target); !os.IsNotExist(err) {
+		t.Errorf("%s exists after sandboxed write", target)
+	}
+}
+
+func TestApply_BlocksEscapes(t *testing.T) {
+	requireLandlock(t)
+	p, workspace, outside := newPolicy(t)
+	victim := filepath.Join(outside, "victim.txt")
+	if err := os.WriteFile(victim, []byte("keep"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		command string
+	}{
+		{"rm", "rm -f " + victim},
+		{"mv", "mv " + victim + " ./stolen.txt"},
+		{"truncate", ": > " + victim},
+		{"obfuscated", fmt.Sprintf("sh -c \"$(echo %q | base64 -d)\"", base64Of("rm -f "+victim))},
+		{"symlink", "ln -s " + outside + " link && echo x > link/new.txt"},
+		{"cd", "cd " + outside + " && touch new.txt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runSandboxed(t, p, workspace, tt.command)
+			data, err := os.ReadFile(victim)
+			if err != nil || string(data) != "keep" {
+				t.Fatalf("victim modified: %q, %v", data, err)
+			}
+			if _, err := os.Stat(filepath.Join(outside, "new.txt")); !os.IsNotExist(err) {
+				t.Fatal("file created outside workspace")
+			}
+		})
+	}
+}
+
+func base64Of(s string) string {
+	out, err := exec.Command("sh", "-c", "printf %s \"$0\" | base64 -w0", s).Output()
+	if err != nil {
+		panic(err)
+	}
+	return string(out)
+}
+
+func TestApply_DevNullWritable(t *testing.T) {
+	requireLandlock(t)
+	p, workspace, _ := newPolicy(t)
+
+	if out, err := runSandboxed(t, p, workspace, "echo hi > /dev/null && echo ok"); err != nil {
+		t.Fatalf("redirect to /dev/null failed: %v, output %q", err, out)
+	}
+}
+
+func TestApply_NoNetwork(t *testing.T) {
+	support := requireLandlock(t)
+	if !support.Namespaces && support.Landlock < 4 {
+		t.Skip("network isolation not supported by this kernel")
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	p, workspace, _ := newPolicy(t)
+	dial := func() string {
+		cmd := exec.Command(os.Args[0])
+		cmd.Dir = workspace
+		cmd.Env = append(os.Environ(), envDial+"="+ln.Addr().String())
+		if err := p.Apply(cmd); err != nil {
+			t.Fatalf("Apply() error: %v", err)
+		}
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("dial helper failed: %v", err)
+		}
+		return string(out)
+	}
+
+	if got := dial(); got != "connected" {
+		t.Fatalf("dial with network allowed = %q, want connected", got)
+	}
+	p.NoNetwork = true
+	if got := dial(); got != "blocked" {
+		t.Errorf("dial with no_network = %q, want blocked", got)
+	}
+}
+
+func TestApply_Rlimits(t *testing.T) {
+	requireLandlock(t)
+	p, workspace, _ := newPolicy(t)
+	p.CPUSeconds = 3
+	p.MaxProcesses = 4096
+
+	out, err := runSandboxed(t, p, workspace, "cat /proc/self/limits")
+	if err != nil {
+		t.Fatalf("command failed: %v, output %q", err, out)
+	}
+	want := map[string]string{"Max cpu time": "3", "Max processes": "4096"}
+	for _, line := range strings.Split(out, "\n") {
+		for name, limit := range want {
+			if fields := strings.Fields(strings.TrimPrefix(line, name)); strings.HasPrefix(line, name) &&
+				len(fields) > 1 && fields[0] == limit && fields[1] == limit {
+				delete(want, name)
+			}
+		}
+	}
+	if len(want) > 0 {
+		t.Errorf("limits not applied: %v\n%s", want, out)
+	}
+}
+
+func TestApply_PreservesCmdErr(t *testing.T) {
+	p, _, _ := newPolicy(t)
+	cmd := exec.Command("definitely-not-a-real-command-xyz")
+	if err := p.Apply(cmd); err == nil {
+		t.Error("Apply() should return the lookup error of cmd")
+	}
+}
@@ -0,0 +1,28 @@
+//go:build !linux
+
+package sandbox
+
+import (
+	"os/exec"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+var warnOnce sync.Once
+
+// Init is a no-op outside Linux.
+func Init() {}
+
+// Probe reports that no confinement feature is available.
+func Probe() (Support, error) {
+	return Support{}, nil
+}
+
+// Apply leaves cmd unchanged: the sandbox is only implemented on Linux.
+func (p *Policy) Apply(cmd *exec.Cmd) error {
+	warnOnce.Do(func() {
+		logger.WarnCF("sandbox", "Sandbox is only supported on Linux; commands run unconfined", nil)
+	})
+	return nil
+}
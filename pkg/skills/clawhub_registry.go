@@ -10,6 +10,7 @@ import (
 	"os"
 	"time"
 
+	"github.com/sipeed/picoclaw/pkg/egress"
 	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
@@ -84,6 +85,14 @@ func NewClawHubRegistry(cfg ClawHubConfig) *ClawHubRegistry {
 	}
 }
 
+// SetEgressPolicy restricts the hosts the registry client may reach. It
+// must be called before the registry is used.
+func (c *ClawHubRegistry) SetEgressPolicy(p *egress.Policy) {
+	if t, ok := c.client.Transport.(*http.Transport); ok {
+		c.client.Transport = p.Transport(t)
+	}
+}
+
 func (c *ClawHubRegistry) Name() string {
 	return "clawhub"
 }
@@ -3,18 +3,19 @@ package skills
 import (
 	"context"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"path/filepath"
 	"time"
 
+	"github.com/sipeed/picoclaw/pkg/egress"
 	"github.com/sipeed/picoclaw/pkg/fileutil"
 	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
 type SkillInstaller struct {
 	workspace string
+	egress    *egress.Policy
 }
 
 func NewSkillInstaller(workspace string) *SkillInstaller {
@@ -23,6 +24,12 @@ func NewSkillInstaller(workspace string) *SkillInstaller {
 	}
 }
 
+// SetEgressPolicy restricts the hosts skills are downloaded from and the
+// size of the downloaded files.
+func (si *SkillInstaller) SetEgressPolicy(p *egress.Policy) {
+	si.egress = p
+}
+
 func (si *SkillInstaller) InstallFromGitHub(ctx context.Context, repo string) error {
 	skillDir := filepath.Join(si.workspace, "skills", filepath.Base(repo))
 
@@ -32,7 +39,10 @@ func (si *SkillInstaller) InstallFromGitHub(ctx context.Context, repo string) er
 
 	url := fmt.Sprintf("https://raw.githubusercontent.com/%s/main/SKILL.md", repo)
 
-	client := &http.Client{Timeout: 15 * time.Second}
+	client := &http.Client{
+		Timeout:   15 * time.Second,
+		Transport: si.egress.Transport(http.DefaultTransport.(*http.Transport)),
+	}
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
@@ -48,10 +58,13 @@ func (si *SkillInstaller) InstallFromGitHub(ctx context.Context, repo string) er
 		return fmt.Errorf("failed to fetch skill: HTTP %d", resp.StatusCode)
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	body, truncated, err := si.egress.ReadBody(resp.Body)
 	if err != nil {
 		return fmt.Errorf("failed to read response: %w", err)
 	}
+	if truncated {
+		return fmt.Errorf("skill file exceeds %d bytes", si.egress.MaxResponseBytes())
+	}
 
 	if err := os.MkdirAll(skillDir, 0o755); err != nil {
 		return fmt.Errorf("failed to create skill directory: %w", err)
@@ -17,6 +17,24 @@ type ContextualTool interface {
 	SetContext(channel, chatID string)
 }
 
+type chatKey struct{}
+
+type chat struct{ channel, chatID string }
+
+// WithChat returns a context telling the tools executed with it which
+// channel and chat the current turn belongs to. Unlike SetContext it is
+// safe when turns of different chats run concurrently.
+func WithChat(ctx context.Context, channel, chatID string) context.Context {
+	return context.WithValue(ctx, chatKey{}, chat{channel: channel, chatID: chatID})
+}
+
+// ChatFromContext returns the channel and chat set by WithChat, or empty
+// strings when ctx carries none.
+func ChatFromContext(ctx context.Context) (channel, chatID string) {
+	c, _ := ctx.Value(chatKey{}).(chat)
+	return c.channel, c.chatID
+}
+
 // AsyncCallback is a function type that async tools use to notify completion.
 // When an async tool finishes its work, it calls this callback with the result.
 //
@@ -15,6 +15,7 @@ type EditFileTool struct {
 	workspace string
 	permStore *PermissionStore
 	permFn    PermissionFunc
+	journal   *Journal
 }
 
 // NewEditFileTool creates a new EditFileTool with optional directory restriction.
@@ -33,6 +34,10 @@ func (t *EditFileTool) SetPermission(store *PermissionStore, fn PermissionFunc)
 	t.permFn = fn
 }
 
+func (t *EditFileTool) SetJournal(j *Journal) {
+	t.journal = j
+}
+
 func (t *EditFileTool) Name() string {
 	return "edit_file"
 }
@@ -81,8 +86,11 @@ func (t *EditFileTool) Execute(ctx context.Context, args map[string]any) *ToolRe
 	if err := checkFilePermission(ctx, path, t.workspace, t.permStore, t.permFn); err != nil {
 		return ErrorResult(err.Error())
 	}
+	if err := checkJournalWrite(path, t.workspace); err != nil {
+		return ErrorResult(err.Error())
+	}
 
-	if err := editFile(t.fs, path, oldText, newText); err != nil {
+	if err := editFile(withJournal(ctx, t.fs, t.journal, t.workspace, t.Name()), path, oldText, newText); err != nil {
 		return ErrorResult(err.Error())
 	}
 	return SilentResult(fmt.Sprintf("File edited: %s", path))
@@ -93,6 +101,7 @@ type AppendFileTool struct {
 	workspace string
 	permStore *PermissionStore
 	permFn    PermissionFunc
+	journal   *Journal
 }
 
 func NewAppendFileTool(workspace string, restrict bool) *AppendFileTool {
@@ -110,6 +119,10 @@ func (t *AppendFileTool) SetPermission(store *PermissionStore, fn PermissionFunc
 	t.permFn = fn
 }
 
+func (t *AppendFileTool) SetJournal(j *Journal) {
+	t.journal = j
+}
+
 func (t *AppendFileTool) Name() string {
 	return "append_file"
 }
@@ -149,8 +162,11 @@ func (t *AppendFileTool) Execute(ctx context.Context, args map[string]any) *Tool
 	if err := checkFilePermission(ctx, path, t.workspace, t.permStore, t.permFn); err != nil {
 		return ErrorResult(err.Error())
 	}
+	if err := checkJournalWrite(path, t.workspace); err != nil {
+		return ErrorResult(err.Error())
+	}
 
-	if err := appendFile(t.fs, path, content); err != nil {
+	if err := appendFile(withJournal(ctx, t.fs, t.journal, t.workspace, t.Name()), path, content); err != nil {
 		return ErrorResult(err.Error())
 	}
 	return SilentResult(fmt.Sprintf("Appended to %s", path))
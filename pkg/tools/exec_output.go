@@ -0,0 +1,211 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultExecOutputBytes is how much of each output stream exec returns.
+	DefaultExecOutputBytes = 8 * 1024
+	// DefaultExecSpillBytes caps the file that keeps a stream's full output.
+	DefaultExecSpillBytes = 16 * 1024 * 1024
+
+	// execOutputDir holds spilled output, relative to the workspace.
+	execOutputDir = ".exec_output"
+	// maxSpillFiles is how many spill files are kept; older ones are removed.
+	maxSpillFiles = 20
+)
+
+// ExecInfo describes how a command run by exec ended.
+type ExecInfo struct {
+	// ExitCode is the command's exit status, -1 if it was killed by a signal.
+	ExitCode int           `json:"exit_code"`
+	Duration time.Duration `json:"duration"`
+	TimedOut bool          `json:"timed_out,omitempty"`
+	// StdoutBytes and StderrBytes count all output, including what was cut.
+	StdoutBytes int64 `json:"stdout_bytes"`
+	StderrBytes int64 `json:"stderr_bytes"`
+	// Truncated is set when part of the output was left out of the result.
+	Truncated bool `json:"truncated,omitempty"`
+	// OutputFiles lists the files holding the full output of cut streams.
+	OutputFiles []string `json:"output_files,omitempty"`
+}
+
+// String renders info as the single line exec appends to its result, e.g.
+//
+//	[exit_code=3 duration=1.2s stdout_bytes=40890 stderr_bytes=5 truncated=true output_files=/w/.exec_output/exec-1]
+func (i *ExecInfo) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[exit_code=%d duration=%s", i.ExitCode, i.Duration.Round(time.Millisecond))
+	if i.TimedOut {
+		b.WriteString(" timed_out=true")
+	}
+	fmt.Fprintf(&b, " stdout_bytes=%d stderr_bytes=%d", i.StdoutBytes, i.StderrBytes)
+	if i.Truncated {
+		b.WriteString(" truncated=true")
+	}
+	if len(i.OutputFiles) > 0 {
+		b.WriteString(" output_files=" + strings.Join(i.OutputFiles, ","))
+	}
+	b.WriteString("]")
+	return b.String()
+}
+
+// outputCapture keeps the first and last limit/2 bytes of a stream. Once the
+// stream outgrows that, everything is also written to a spill file (up to
+// spillLimit bytes) so nothing is lost to the model.
+type outputCapture struct {
+	mu         sync.Mutex
+	head       []byte
+	tail       *ringBuffer
+	headLimit  int
+	total      int64
+	spillDir   string
+	spillName  string
+	spillLimit int64
+	spill      *os.File
+	spilled    int64
+	spillErr   error
+}
+
+func newOutputCapture(limit int, spillDir, spillName string, spillLimit int64) *outputCapture {
+	headLimit := limit / 2
+	return &outputCapture{
+		headLimit:  headLimit,
+		tail:       newRingBuffer(limit - headLimit),
+		spillDir:   spillDir,
+		spillName:  spillName,
+		spillLimit: spillLimit,
+	}
+}
+
+func (c *outputCapture) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.spill == nil && c.spillErr == nil && c.spillLimit > 0 &&
+		c.total+int64(len(p)) > int64(c.headLimit+c.tail.size) {
+		// Nothing has been dropped yet, so head and tail are the whole stream.
+		c.openSpill()
+	}
+	if c.spill != nil && c.spilled < c.spillLimit {
+		chunk := p[:min(int64(len(p)), c.spillLimit-c.spilled)]
+		n, err := c.spill.Write(chunk)
+		c.spilled += int64(n)
+		if err != nil {
+			c.spillErr = err
+		}
+	}
+
+	c.total += int64(len(p))
+	rest := p
+	if room := c.headLimit - len(c.head); room > 0 {
+		n := min(room, len(rest))
+		c.head = append(c.head, rest[:n]...)
+		rest = rest[n:]
+	}
+	if len(rest) > 0 {
+		c.tail.Write(rest)
+	}
+	return len(p), nil
+}
+
+func (c *outputCapture) openSpill() {
+	if err := os.MkdirAll(c.spillDir, 0o755); err != nil {
+		c.spillErr = err
+		return
+	}
+	pruneSpillFiles(c.spillDir, maxSpillFiles-1)
+	f, err := os.CreateTemp(c.spillDir, c.spillName)
+	if err != nil {
+		c.spillErr = err
+		return
+	}
+	c.spill = f
+	tail, _, _ := c.tail.readFrom(0)
+	for _, chunk := range [][]byte{c.head, []byte(tail)} {
+		n, err := f.Write(chunk)
+		c.spilled += int64(n)
+		if err != nil {
+			c.spillErr = err
+			return
+		}
+	}
+}
+
+// close finishes the spill file and returns its path, or "" if there is none.
+func (c *outputCapture) close() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.spill == nil {
+		return ""
+	}
+	c.spill.Close()
+	return c.spill.Name()
+}
+
+func (c *outputCapture) truncated() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.total > int64(len(c.head))+int64(len(c.tail.buf))
+}
+
+// String returns the captured output, with a marker where bytes were cut.
+func (c *outputCapture) String() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tail, _, _ := c.tail.readFrom(0)
+	omitted := c.total - int64(len(c.head)) - int64(len(tail))
+	if omitted <= 0 {
+		return string(c.head) + tail
+	}
+
+	var marker string
+	switch {
+	case c.spill != nil && c.spilled < c.total:
+		marker = fmt.Sprintf("[... %d bytes omitted; the first %d bytes are saved to %s ...]",
+			omitted, c.spilled, c.spill.Name())
+	case c.spill != nil:
+		marker = fmt.Sprintf("[... %d bytes omitted; full output saved to %s, "+
+			"page through it with read_file (offset, limit) ...]", omitted, c.spill.Name())
+	default:
+		marker = fmt.Sprintf("[... %d bytes omitted ...]", omitted)
+	}
+	// The cuts can split multi-byte characters.
+	return strings.ToValidUTF8(string(c.head), "") + "\n" + marker + "\n" + strings.ToValidUTF8(tail, "")
+}
+
+// pruneSpillFiles removes the oldest spill files so at most keep remain.
+func pruneSpillFiles(dir string, keep int) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	type spillFile struct {
+		path    string
+		modTime time.Time
+	}
+	var files []spillFile
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasPrefix(e.Name(), "exec-") {
+			continue
+		}
+		if info, err := e.Info(); err == nil {
+			files = append(files, spillFile{filepath.Join(dir, e.Name()), info.ModTime()})
+		}
+	}
+	if len(files) <= keep {
+		return
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	for _, f := range files[:len(files)-keep] {
+		os.Remove(f.path)
+	}
+}
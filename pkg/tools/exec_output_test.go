@@ -0,0 +1,152 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestOutputCapture_Small(t *testing.T) {
+	c := newOutputCapture(16, t.TempDir(), "exec-*-stdout.log", 1024)
+	fmt.Fprint(c, "hello ")
+	fmt.Fprint(c, "world")
+	if got := c.String(); got != "hello world" {
+		t.Errorf("String() = %q, want %q", got, "hello world")
+	}
+	if c.truncated() {
+		t.Error("truncated() = true for output within the limit")
+	}
+	if path := c.close(); path != "" {
+		t.Errorf("close() = %q, want no spill file", path)
+	}
+}
+
+func TestOutputCapture_HeadTailAndSpill(t *testing.T) {
+	dir := t.TempDir()
+	c := newOutputCapture(10, dir, "exec-*-stdout.log", 1024)
+	for i := 0; i < 10; i++ {
+		fmt.Fprintf(c, "%d", i)
+		fmt.Fprint(c, "abc")
+	}
+	full := "0abc1abc2abc3abc4abc5abc6abc7abc8abc9abc"
+
+	got := c.String()
+	if !strings.HasPrefix(got, "0abc1\n") || !strings.HasSuffix(got, "\nc9abc") {
+		t.Errorf("String() = %q, want head %q and tail %q", got, "0abc1", "c9abc")
+	}
+	if !strings.Contains(got, "30 bytes omitted") {
+		t.Errorf("String() = %q, want omitted byte count", got)
+	}
+	if !c.truncated() {
+		t.Error("truncated() = false")
+	}
+
+	path := c.close()
+	if path == "" || filepath.Dir(path) != dir {
+		t.Fatalf("close() = %q, want a spill file in %s", path, dir)
+	}
+	if !strings.Contains(got, path) {
+		t.Errorf("String() = %q, want it to name the spill file", got)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != full {
+		t.Errorf("spill file = %q, want %q", data, full)
+	}
+}
+
+func TestOutputCapture_SpillLimit(t *testing.T) {
+	c := newOutputCapture(4, t.TempDir(), "exec-*-stdout.log", 8)
+	fmt.Fprint(c, strings.Repeat("x", 100))
+	path := c.close()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 8 {
+		t.Errorf("spill file has %d bytes, want 8", len(data))
+	}
+	if got := c.String(); !strings.Contains(got, "the first 8 bytes are saved") {
+		t.Errorf("String() = %q, want a note that the spill file is incomplete", got)
+	}
+}
+
+func TestOutputCapture_NoSpill(t *testing.T) {
+	dir := t.TempDir()
+	c := newOutputCapture(4, dir, "exec-*-stdout.log", 0)
+	fmt.Fprint(c, strings.Repeat("x", 100))
+	if path := c.close(); path != "" {
+		t.Errorf("close() = %q, want no spill file when spilling is disabled", path)
+	}
+	if got := c.String(); !strings.Contains(got, "[... 96 bytes omitted ...]") {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestPruneSpillFiles(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 5; i++ {
+		os.WriteFile(filepath.Join(dir, fmt.Sprintf("exec-%d-stdout.log", i)), nil, 0o644)
+	}
+	os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0o644)
+
+	pruneSpillFiles(dir, 2)
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 3 {
+		t.Errorf("%d entries left, want 2 spill files and notes.txt", len(entries))
+	}
+}
+
+func TestShellTool_SpillsLargeOutput(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses POSIX shell commands")
+	}
+	workspace := t.TempDir()
+	tool, err := NewExecTool(workspace, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := tool.Execute(context.Background(), map[string]any{
+		"command": `awk 'BEGIN { for (i = 0; i < 5000; i++) print "line" i }'; echo oops >&2; exit 3`,
+	})
+	if !result.IsError {
+		t.Error("expected IsError for exit code 3")
+	}
+	info := result.Exec
+	if info == nil {
+		t.Fatalf("result.Exec is nil: %s", result.ForLLM)
+	}
+	if info.ExitCode != 3 || !info.Truncated || info.StderrBytes != 5 || info.Duration <= 0 {
+		t.Errorf("Exec = %+v", info)
+	}
+	if len(result.ForLLM) > 2*DefaultExecOutputBytes {
+		t.Errorf("ForLLM has %d bytes, want it bounded", len(result.ForLLM))
+	}
+	summary := fmt.Sprintf("stdout_bytes=%d stderr_bytes=5 truncated=true output_files=", info.StdoutBytes)
+	for _, want := range []string{"line0\n", "line4999\n", "STDERR:\noops", "Exit code", "[exit_code=3 ", summary} {
+		if !strings.Contains(result.ForLLM, want) {
+			t.Errorf("ForLLM missing %q", want)
+		}
+	}
+
+	if len(info.OutputFiles) != 1 {
+		t.Fatalf("OutputFiles = %v, want the stdout spill file", info.OutputFiles)
+	}
+	if dir := filepath.Dir(info.OutputFiles[0]); dir != filepath.Join(workspace, execOutputDir) {
+		t.Errorf("spill file in %s, want the workspace", dir)
+	}
+	data, err := os.ReadFile(info.OutputFiles[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 5000 {
+		t.Errorf("spill file has %d lines, want 5000", lines)
+	}
+}
@@ -0,0 +1,206 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultReadFileBytes is the most text read_file returns in one call.
+	DefaultReadFileBytes = 64 << 10
+	// maxReadMediaBytes is the largest image or PDF handed to the model.
+	maxReadMediaBytes = 10 << 20
+
+	sniffBytes   = 8 << 10
+	hexdumpBytes = 256
+)
+
+// readMediaTypes are the file types read_file attaches for the model to
+// view instead of returning their bytes.
+var readMediaTypes = map[string]bool{
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+}
+
+// readFileContent reads path from f according to the offset and limit
+// line range (offset is 1-based, limit <= 0 means no limit).
+func readFileContent(f *os.File, path string, offset, limit, maxBytes int) (*ToolResult, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s is a directory; use list_dir instead", path)
+	}
+
+	sniff := make([]byte, sniffBytes)
+	n, err := io.ReadFull(f, sniff)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	sniff = sniff[:n]
+	size := info.Size()
+
+	contentType := http.DetectContentType(sniff)
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = contentType[:i]
+	}
+	if readMediaTypes[contentType] {
+		return readMedia(f, path, contentType, size)
+	}
+	if isBinary(sniff) {
+		return NewToolResult(fmt.Sprintf("%s is a binary file (%s, %d bytes). First %d bytes:\n%s",
+			path, contentType, size, min(hexdumpBytes, n), hex.Dump(sniff[:min(hexdumpBytes, n)]))), nil
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	w, err := readLines(f, offset, limit, maxBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	return NewToolResult(w.String(offset)), nil
+}
+
+// readMedia attaches an image or PDF for the model to view.
+func readMedia(f *os.File, path, contentType string, size int64) (*ToolResult, error) {
+	if size > maxReadMediaBytes {
+		return NewToolResult(fmt.Sprintf("%s is %s (%d bytes), too large to attach (limit %d bytes)",
+			path, contentType, size, maxReadMediaBytes)), nil
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	return &ToolResult{
+		ForLLM:      fmt.Sprintf("%s is %s (%d bytes); it is attached for you to view", path, contentType, size),
+		ForLLMMedia: []string{"data:" + contentType + ";base64," + base64.StdEncoding.EncodeToString(data)},
+	}, nil
+}
+
+// isBinary reports whether the start of a file looks like binary data:
+// it holds a NUL byte or is not valid UTF-8.
+func isBinary(sniff []byte) bool {
+	if bytes.IndexByte(sniff, 0) >= 0 {
+		return true
+	}
+	// The sample may end in the middle of a multi-byte character.
+	for i := 0; i < utf8.UTFMax && len(sniff) > 0 && !utf8.Valid(sniff); i++ {
+		sniff = sniff[:len(sniff)-1]
+	}
+	return !utf8.Valid(sniff)
+}
+
+// lineWindow is the part of a text file returned by read_file.
+type lineWindow struct {
+	text    bytes.Buffer
+	first   int  // first line shown, 0 if none
+	last    int  // last line shown
+	total   int  // lines in the file
+	cut     bool // output stopped at the byte cap
+	partial bool // the last line shown was cut short
+
+	maxBytes int
+}
+
+// readLines collects lines offset..offset+limit-1 of r, stopping once
+// maxBytes of text have been collected. It reads to the end to count the
+// lines of the file.
+func readLines(r io.Reader, offset, limit, maxBytes int) (*lineWindow, error) {
+	w := &lineWindow{maxBytes: maxBytes}
+	br := bufio.NewReaderSize(r, 64<<10)
+	lineStart := true
+	for {
+		chunk, err := br.ReadSlice('\n')
+		if len(chunk) > 0 {
+			startsLine := lineStart
+			if startsLine {
+				w.total++
+			}
+			lineStart = chunk[len(chunk)-1] == '\n'
+
+			line := w.total
+			if !w.cut && line >= offset && (limit <= 0 || line < offset+limit) {
+				room := maxBytes - w.text.Len()
+				switch {
+				case len(chunk) <= room:
+					w.text.Write(chunk)
+					if w.first == 0 {
+						w.first = line
+					}
+					w.last = line
+				case startsLine && w.first != 0:
+					// The next line does not fit; stop before it.
+					w.cut = true
+				default:
+					// A single line longer than the cap: show its start.
+					w.text.Write(chunk[:room])
+					if w.first == 0 {
+						w.first = line
+					}
+					w.last = line
+					w.cut, w.partial = true, true
+				}
+			}
+		}
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if errors.Is(err, io.EOF) {
+			return w, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// String returns the text, followed by a notice when only part of the
+// file is shown.
+func (w *lineWindow) String(offset int) string {
+	if w.first == 0 {
+		if w.total == 0 {
+			return ""
+		}
+		return fmt.Sprintf("[offset %d is past the end of the file (%d lines)]", offset, w.total)
+	}
+	// A truncated line can end in the middle of a character.
+	text := strings.ToValidUTF8(w.text.String(), "")
+	if w.first == 1 && w.last == w.total && !w.partial {
+		return text
+	}
+
+	var sb strings.Builder
+	sb.WriteString(text)
+	if !strings.HasSuffix(text, "\n") {
+		sb.WriteString("\n")
+	}
+	fmt.Fprintf(&sb, "\n[Showing lines %d-%d of %d", w.first, w.last, w.total)
+	if w.partial {
+		fmt.Fprintf(&sb, "; line %d is truncated", w.last)
+	}
+	if w.cut {
+		fmt.Fprintf(&sb, "; output limited to %d bytes", w.maxBytes)
+	}
+	if w.last < w.total {
+		fmt.Fprintf(&sb, ". Use offset=%d to continue", w.last+1)
+	}
+	sb.WriteString("]")
+	return sb.String()
+}
@@ -161,7 +161,9 @@ func (t *ReadFileTool) Name() string {
 }
 
 func (t *ReadFileTool) Description() string {
-	return "Read the contents of a file"
+	return "Read the contents of a file. Large text files are returned in parts; use offset and limit " +
+		"to read a range of lines. Images and PDFs are attached for you to view, other binary files " +
+		"are summarized with a hexdump of their first bytes."
 }
 
 func (t *ReadFileTool) Parameters() map[string]any {
@@ -172,6 +174,14 @@ func (t *ReadFileTool) Parameters() map[string]any {
 				"type":        "string",
 				"description": "Path to the file to read",
 			},
+			"offset": map[string]any{
+				"type":        "integer",
+				"description": "Line number to start reading from (1-based, default 1)",
+			},
+			"limit": map[string]any{
+				"type":        "integer",
+				"description": "Maximum number of lines to read (default: as many as fit the size limit)",
+			},
 		},
 		"required": []string{"path"},
 	}
@@ -187,11 +197,25 @@ func (t *ReadFileTool) Execute(ctx context.Context, args map[string]any) *ToolRe
 		return ErrorResult(err.Error())
 	}
 
-	content, err := t.fs.ReadFile(path)
+	offset, limit := 1, 0
+	if o, ok := args["offset"].(float64); ok && o > 1 {
+		offset = int(o)
+	}
+	if l, ok := args["limit"].(float64); ok && l > 0 {
+		limit = int(l)
+	}
+
+	f, err := t.fs.Open(path)
 	if err != nil {
 		return ErrorResult(err.Error())
 	}
-	return NewToolResult(string(content))
+	defer f.Close()
+
+	result, err := readFileContent(f, path, offset, limit, DefaultReadFileBytes)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+	return result
 }
 
 type WriteFileTool struct {
@@ -199,6 +223,7 @@ type WriteFileTool struct {
 	workspace string
 	permStore *PermissionStore
 	permFn    PermissionFunc
+	journal   *Journal
 }
 
 func NewWriteFileTool(workspace string, restrict bool) *WriteFileTool {
@@ -216,6 +241,10 @@ func (t *WriteFileTool) SetPermission(store *PermissionStore, fn PermissionFunc)
 	t.permFn = fn
 }
 
+func (t *WriteFileTool) SetJournal(j *Journal) {
+	t.journal = j
+}
+
 func (t *WriteFileTool) Name() string {
 	return "write_file"
 }
@@ -255,8 +284,12 @@ func (t *WriteFileTool) Execute(ctx context.Context, args map[string]any) *ToolR
 	if err := checkFilePermission(ctx, path, t.workspace, t.permStore, t.permFn); err != nil {
 		return ErrorResult(err.Error())
 	}
+	if err := checkJournalWrite(path, t.workspace); err != nil {
+		return ErrorResult(err.Error())
+	}
 
-	if err := t.fs.WriteFile(path, []byte(content)); err != nil {
+	fsys := withJournal(ctx, t.fs, t.journal, t.workspace, t.Name())
+	if err := fsys.WriteFile(path, []byte(content)); err != nil {
 		return ErrorResult(err.Error())
 	}
 
@@ -341,6 +374,9 @@ type fileSystem interface {
 	ReadFile(path string) ([]byte, error)
 	WriteFile(path string, data []byte) error
 	ReadDir(path string) ([]os.DirEntry, error)
+	Remove(path string) error
+	// Open opens a file for reading without loading it into memory.
+	Open(path string) (*os.File, error)
 }
 
 // hostFs is an unrestricted fileReadWriter that operates directly on the host filesystem.
@@ -349,21 +385,37 @@ type hostFs struct{}
 func (h *hostFs) ReadFile(path string) ([]byte, error) {
 	content, err := os.ReadFile(path)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("failed to read file: file not found: %w", err)
-		}
-		if os.IsPermission(err) {
-			return nil, fmt.Errorf("failed to read file: access denied: %w", err)
-		}
-		return nil, fmt.Errorf("failed to read file: %w", err)
+		return nil, hostReadError(err)
 	}
 	return content, nil
 }
 
+func (h *hostFs) Open(path string) (*os.File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, hostReadError(err)
+	}
+	return f, nil
+}
+
+func hostReadError(err error) error {
+	if os.IsNotExist(err) {
+		return fmt.Errorf("failed to read file: file not found: %w", err)
+	}
+	if os.IsPermission(err) {
+		return fmt.Errorf("failed to read file: access denied: %w", err)
+	}
+	return fmt.Errorf("failed to read file: %w", err)
+}
+
 func (h *hostFs) ReadDir(path string) ([]os.DirEntry, error) {
 	return os.ReadDir(path)
 }
 
+func (h *hostFs) Remove(path string) error {
+	return os.Remove(path)
+}
+
 func (h *hostFs) WriteFile(path string, data []byte) error {
 	// Use unified atomic write utility with explicit sync for flash storage reliability.
 	// Using 0o600 (owner read/write only) for secure default permissions.
@@ -399,15 +451,7 @@ func (r *sandboxFs) ReadFile(path string) ([]byte, error) {
 	err := r.execute(path, func(root *os.Root, relPath string) error {
 		fileContent, err := root.ReadFile(relPath)
 		if err != nil {
-			if os.IsNotExist(err) {
-				return fmt.Errorf("failed to read file: file not found: %w", err)
-			}
-			// os.Root returns "escapes from parent" for paths outside the root
-			if os.IsPermission(err) || strings.Contains(err.Error(), "escapes from parent") ||
-				strings.Contains(err.Error(), "permission denied") {
-				return fmt.Errorf("failed to read file: access denied: %w", err)
-			}
-			return fmt.Errorf("failed to read file: %w", err)
+			return sandboxReadError(err)
 		}
 		content = fileContent
 		return nil
@@ -415,6 +459,32 @@ func (r *sandboxFs) ReadFile(path string) ([]byte, error) {
 	return content, err
 }
 
+// Open returns a file that stays usable after the workspace root is closed.
+func (r *sandboxFs) Open(path string) (*os.File, error) {
+	var f *os.File
+	err := r.execute(path, func(root *os.Root, relPath string) error {
+		file, err := root.Open(relPath)
+		if err != nil {
+			return sandboxReadError(err)
+		}
+		f = file
+		return nil
+	})
+	return f, err
+}
+
+func sandboxReadError(err error) error {
+	if os.IsNotExist(err) {
+		return fmt.Errorf("failed to read file: file not found: %w", err)
+	}
+	// os.Root returns "escapes from parent" for paths outside the root
+	if os.IsPermission(err) || strings.Contains(err.Error(), "escapes from parent") ||
+		strings.Contains(err.Error(), "permission denied") {
+		return fmt.Errorf("failed to read file: access denied: %w", err)
+	}
+	return fmt.Errorf("failed to read file: %w", err)
+}
+
 func (r *sandboxFs) WriteFile(path string, data []byte) error {
 	return r.execute(path, func(root *os.Root, relPath string) error {
 		dir := filepath.Dir(relPath)
@@ -481,6 +551,12 @@ func (r *sandboxFs) ReadDir(path string) ([]os.DirEntry, error) {
 	return entries, err
 }
 
+func (r *sandboxFs) Remove(path string) error {
+	return r.execute(path, func(root *os.Root, relPath string) error {
+		return root.Remove(relPath)
+	})
+}
+
 // Helper to get a safe relative path for os.Root usage
 func getSafeRelPath(workspace, path string) (string, error) {
 	if workspace == "" {
@@ -2,6 +2,7 @@ package tools
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
 	"io"
 	"os"
@@ -705,3 +706,102 @@ func TestReadFileTool_Permission_NoPermFn_AllowsAccess(t *testing.T) {
 	assert.False(t, result.IsError, "Expected success when no permFn set, got: %s", result.ForLLM)
 	assert.Contains(t, result.ForLLM, "no permission func")
 }
+
+func TestReadFileTool_LineRange(t *testing.T) {
+	workspace := t.TempDir()
+	var sb strings.Builder
+	for i := 1; i <= 10; i++ {
+		fmt.Fprintf(&sb, "line %d\n", i)
+	}
+	writeTree(t, workspace, map[string]string{"f.txt": sb.String()})
+	tool := NewReadFileTool(workspace, true)
+	ctx := context.Background()
+
+	result := tool.Execute(ctx, map[string]any{"path": "f.txt"})
+	if result.ForLLM != sb.String() {
+		t.Errorf("whole file = %q", result.ForLLM)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"path": "f.txt", "offset": 3.0, "limit": 2.0})
+	want := "line 3\nline 4\n\n[Showing lines 3-4 of 10. Use offset=5 to continue]"
+	if result.ForLLM != want {
+		t.Errorf("range = %q, want %q", result.ForLLM, want)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"path": "f.txt", "offset": 9.0})
+	if !strings.HasPrefix(result.ForLLM, "line 9\nline 10\n\n[Showing lines 9-10 of 10]") {
+		t.Errorf("tail = %q", result.ForLLM)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"path": "f.txt", "offset": 50.0})
+	if !strings.Contains(result.ForLLM, "past the end of the file (10 lines)") {
+		t.Errorf("past the end = %q", result.ForLLM)
+	}
+
+	if result = tool.Execute(ctx, map[string]any{"path": "."}); !result.IsError {
+		t.Errorf("reading a directory should fail: %q", result.ForLLM)
+	}
+}
+
+func TestReadLines_ByteCap(t *testing.T) {
+	w, err := readLines(strings.NewReader("aaaa\nbbbb\ncccc\n"), 1, 0, 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := w.String(1)
+	want := "aaaa\nbbbb\n\n[Showing lines 1-2 of 3; output limited to 12 bytes. Use offset=3 to continue]"
+	if got != want {
+		t.Errorf("capped = %q, want %q", got, want)
+	}
+
+	// A line longer than the cap is cut, and reading continues after it.
+	long := strings.Repeat("x", 100) + "\nnext\n"
+	w, err = readLines(strings.NewReader(long), 1, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = w.String(1)
+	if !strings.HasPrefix(got, "xxxxxxxxxx\n\n[Showing lines 1-1 of 2; line 1 is truncated") ||
+		!strings.HasSuffix(got, "Use offset=2 to continue]") {
+		t.Errorf("long line = %q", got)
+	}
+}
+
+func TestReadFileTool_BinaryAndMedia(t *testing.T) {
+	workspace := t.TempDir()
+	png := "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"
+	writeTree(t, workspace, map[string]string{
+		"data.bin":  "ELF\x00\x01\x02\x03",
+		"image.png": png,
+		"doc.pdf":   "%PDF-1.4\n",
+		"latin1":    "caf\xe9 au lait",
+		"utf8.txt":  "café",
+	})
+	tool := NewReadFileTool(workspace, true)
+	ctx := context.Background()
+
+	result := tool.Execute(ctx, map[string]any{"path": "data.bin"})
+	if !strings.Contains(result.ForLLM, "binary file") || !strings.Contains(result.ForLLM, "00000000  45 4c 46 00") {
+		t.Errorf("binary = %q", result.ForLLM)
+	}
+	if result = tool.Execute(ctx, map[string]any{"path": "latin1"}); !strings.Contains(result.ForLLM, "binary file") {
+		t.Errorf("invalid UTF-8 = %q", result.ForLLM)
+	}
+	if result = tool.Execute(ctx, map[string]any{"path": "utf8.txt"}); result.ForLLM != "café" {
+		t.Errorf("utf8 = %q", result.ForLLM)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"path": "image.png"})
+	wantURL := "data:image/png;base64," + base64.StdEncoding.EncodeToString([]byte(png))
+	if len(result.ForLLMMedia) != 1 || result.ForLLMMedia[0] != wantURL {
+		t.Errorf("image media = %v", result.ForLLMMedia)
+	}
+	if !strings.Contains(result.ForLLM, "attached for you to view") {
+		t.Errorf("image = %q", result.ForLLM)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"path": "doc.pdf"})
+	if len(result.ForLLMMedia) != 1 || !strings.HasPrefix(result.ForLLMMedia[0], "data:application/pdf;base64,") {
+		t.Errorf("pdf media = %v", result.ForLLMMedia)
+	}
+}
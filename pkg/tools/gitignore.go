@@ -0,0 +1,123 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// ignoreRule is one pattern from a .gitignore file.
+type ignoreRule struct {
+	segments []string // pattern split on "/", possibly with "**" segments
+	negate   bool
+	dirOnly  bool
+}
+
+// parseGitignore parses the contents of a .gitignore file. Patterns
+// without a slash match at any depth; the others are relative to the
+// directory holding the file.
+func parseGitignore(data []byte) []ignoreRule {
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var rule ignoreRule
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\`) {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		if !strings.Contains(line, "/") {
+			line = "**/" + line
+		}
+		rule.segments = strings.Split(strings.TrimPrefix(line, "/"), "/")
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// matchSegments matches a slash-separated path against pattern segments,
+// where "**" matches any number of path segments and other segments use
+// path.Match syntax.
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			rest := pattern[1:]
+			if len(rest) == 0 {
+				return true
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(rest, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// ignoreMatcher applies the .gitignore files found while walking a tree.
+type ignoreMatcher struct {
+	fsys  fs.FS
+	rules map[string][]ignoreRule // by directory, as fs.FS paths
+}
+
+func newIgnoreMatcher(fsys fs.FS) *ignoreMatcher {
+	return &ignoreMatcher{fsys: fsys, rules: make(map[string][]ignoreRule)}
+}
+
+// load reads the .gitignore file of dir, if any.
+func (m *ignoreMatcher) load(dir string) {
+	data, err := fs.ReadFile(m.fsys, path.Join(dir, ".gitignore"))
+	if err == nil {
+		m.rules[dir] = parseGitignore(data)
+	}
+}
+
+// ignored reports whether name (an fs.FS path below root) is excluded by
+// the .gitignore files of root and the directories between them. The last
+// matching rule wins, and deeper files override shallower ones.
+func (m *ignoreMatcher) ignored(root, name string, isDir bool) bool {
+	ignored := false
+	dir := root
+	for {
+		rel := strings.TrimPrefix(name, dir+"/")
+		if dir == "." {
+			rel = name
+		}
+		for _, rule := range m.rules[dir] {
+			if rule.dirOnly && !isDir {
+				continue
+			}
+			if matchSegments(rule.segments, strings.Split(rel, "/")) {
+				ignored = !rule.negate
+			}
+		}
+		next, _, found := strings.Cut(rel, "/")
+		if !found {
+			return ignored
+		}
+		dir = path.Join(dir, next)
+	}
+}
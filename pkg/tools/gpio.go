@@ -0,0 +1,327 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+const (
+	// defaultGPIOWaitTimeout is how long wait blocks when no timeout is given.
+	defaultGPIOWaitTimeout = 5 * time.Second
+	// maxGPIOWaitTimeout caps how long a single wait may block.
+	maxGPIOWaitTimeout = 60 * time.Second
+	// maxGPIOLines is the most lines one request may hold (GPIO_V2_LINES_MAX).
+	maxGPIOLines = 64
+	// gpioConsumer is the label other programs see on lines the tool holds.
+	gpioConsumer = "picoclaw"
+)
+
+// gpioLine identifies one line of a chip.
+type gpioLine struct {
+	chip   string
+	offset int
+}
+
+// gpioSettings are the optional line settings of read, write and wait.
+type gpioSettings struct {
+	activeLow bool
+	bias      string // "", "pull-up", "pull-down" or "disabled"
+	drive     string // "", "push-pull", "open-drain" or "open-source"
+	edge      string // "rising", "falling" or "both"; wait only
+	debounce  time.Duration
+}
+
+// GPIOTool reads and drives GPIO lines through the Linux GPIO character
+// device (/dev/gpiochipN, uAPI v2). Only the lines in the agent's gpio
+// allowlist may be used. Lines written as outputs stay requested until
+// they are released, so a relay keeps its state between calls.
+type GPIOTool struct {
+	lines       map[string]map[int]bool
+	outputLines map[string]map[int]bool
+
+	mu   sync.Mutex
+	held map[gpioLine]int // request fds of lines held as outputs
+}
+
+// NewGPIOTool creates the tool with the agent's allowlist; cfg may be nil,
+// in which case chips and lines can be listed but not used.
+func NewGPIOTool(cfg *config.GPIOConfig) *GPIOTool {
+	t := &GPIOTool{
+		lines:       make(map[string]map[int]bool),
+		outputLines: make(map[string]map[int]bool),
+		held:        make(map[gpioLine]int),
+	}
+	if cfg == nil {
+		return t
+	}
+	for chip, offsets := range cfg.Lines {
+		addGPIOLines(t.lines, chip, offsets)
+	}
+	// Output lines may be read and watched as well.
+	for chip, offsets := range cfg.OutputLines {
+		addGPIOLines(t.lines, chip, offsets)
+		addGPIOLines(t.outputLines, chip, offsets)
+	}
+	return t
+}
+
+func addGPIOLines(set map[string]map[int]bool, chip string, offsets []int) {
+	if set[chip] == nil {
+		set[chip] = make(map[int]bool)
+	}
+	for _, offset := range offsets {
+		set[chip][offset] = true
+	}
+}
+
+func (t *GPIOTool) Name() string {
+	return "gpio"
+}
+
+func (t *GPIOTool) Description() string {
+	return "Read and drive GPIO lines (buttons, relays, LEDs) through /dev/gpiochipN. " +
+		"Actions: list (chips, or the lines of a chip), read (line values), write (drive a line; it stays " +
+		"driven until released), wait (wait for a rising/falling edge with a timeout), release (stop driving " +
+		"lines). Only lines in the agent's gpio allowlist can be used. Linux only."
+}
+
+func (t *GPIOTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"action": map[string]any{
+				"type":        "string",
+				"enum":        []string{"list", "read", "write", "wait", "release"},
+				"description": "Action to perform",
+			},
+			"chip": map[string]any{
+				"type":        "string",
+				"description": "GPIO chip, e.g. \"gpiochip0\" or \"0\". Required except for list and release.",
+			},
+			"line": map[string]any{
+				"type":        "integer",
+				"description": "Line offset on the chip. Required for write/wait.",
+			},
+			"lines": map[string]any{
+				"type":        "array",
+				"items":       map[string]any{"type": "integer"},
+				"description": "Line offsets to read at once (alternative to line for read)",
+			},
+			"value": map[string]any{
+				"type":        "integer",
+				"description": "Value to drive for write: 0 (inactive) or 1 (active)",
+			},
+			"active_low": map[string]any{
+				"type":        "boolean",
+				"description": "Treat the physical low level as active (value 1)",
+			},
+			"bias": map[string]any{
+				"type":        "string",
+				"enum":        []string{"pull-up", "pull-down", "disabled"},
+				"description": "Internal bias resistor for read/wait, if the chip supports it",
+			},
+			"drive": map[string]any{
+				"type":        "string",
+				"enum":        []string{"push-pull", "open-drain", "open-source"},
+				"description": "Output drive for write. Default: push-pull.",
+			},
+			"edge": map[string]any{
+				"type":        "string",
+				"enum":        []string{"rising", "falling", "both"},
+				"description": "Edge to wait for. Default: both.",
+			},
+			"debounce_ms": map[string]any{
+				"type":        "integer",
+				"description": "Debounce period for wait in milliseconds, e.g. 20 for a push button",
+			},
+			"timeout_ms": map[string]any{
+				"type":        "integer",
+				"description": "How long wait blocks in milliseconds. Default: 5000, maximum 60000.",
+			},
+			"confirm": map[string]any{
+				"type":        "boolean",
+				"description": "Must be true for write operations. Safety guard to prevent accidental writes.",
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+func (t *GPIOTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	if runtime.GOOS != "linux" {
+		return ErrorResult("GPIO is only supported on Linux. This tool requires /dev/gpiochip* device files.")
+	}
+
+	action, ok := args["action"].(string)
+	if !ok {
+		return ErrorResult("action is required")
+	}
+
+	switch action {
+	case "list":
+		return t.list(args)
+	case "read":
+		return t.readLines(args)
+	case "write":
+		return t.writeLine(args)
+	case "wait":
+		return t.waitEdge(ctx, args)
+	case "release":
+		return t.release(args)
+	default:
+		return ErrorResult(fmt.Sprintf("unknown action: %s (valid: list, read, write, wait, release)", action))
+	}
+}
+
+// Helper functions for GPIO operations (used by platform-specific implementations)
+
+var gpioChipArgRe = regexp.MustCompile(`^(?:gpiochip)?(\d+)$`)
+
+// parseGPIOChip returns the chip name ("gpiochip0") from args["chip"],
+// which may also be given as a bare number (prevents path injection).
+//
+//nolint:unused // Used by gpio_linux.go
+func parseGPIOChip(args map[string]any) (string, *ToolResult) {
+	chip, ok := args["chip"].(string)
+	if !ok || chip == "" {
+		return "", ErrorResult("chip is required (e.g. \"gpiochip0\")")
+	}
+	m := gpioChipArgRe.FindStringSubmatch(chip)
+	if m == nil {
+		return "", ErrorResult("invalid chip: use a name such as \"gpiochip0\" or its number")
+	}
+	return "gpiochip" + m[1], nil
+}
+
+// parseGPIOLines returns the line offsets from args["line"] or, if multi is
+// set, args["lines"].
+//
+//nolint:unused // Used by gpio_linux.go
+func parseGPIOLines(args map[string]any, multi bool) ([]int, *ToolResult) {
+	var raw []any
+	if line, ok := args["line"]; ok {
+		raw = []any{line}
+	} else if lines, ok := args["lines"].([]any); ok && multi {
+		raw = lines
+	}
+	if len(raw) == 0 {
+		return nil, ErrorResult("line is required (the offset of the line on the chip)")
+	}
+	if len(raw) > maxGPIOLines {
+		return nil, ErrorResult(fmt.Sprintf("at most %d lines can be used at once", maxGPIOLines))
+	}
+	offsets := make([]int, 0, len(raw))
+	seen := make(map[int]bool)
+	for _, v := range raw {
+		f, ok := v.(float64)
+		if !ok || f < 0 || f != float64(int(f)) {
+			return nil, ErrorResult(fmt.Sprintf("invalid line offset %v: must be a non-negative integer", v))
+		}
+		if !seen[int(f)] {
+			seen[int(f)] = true
+			offsets = append(offsets, int(f))
+		}
+	}
+	return offsets, nil
+}
+
+// parseGPIOSettings returns the optional line settings in args.
+//
+//nolint:unused // Used by gpio_linux.go
+func parseGPIOSettings(args map[string]any) (gpioSettings, *ToolResult) {
+	s := gpioSettings{edge: "both"}
+	s.activeLow, _ = args["active_low"].(bool)
+	for _, opt := range []struct {
+		name  string
+		dst   *string
+		valid []string
+	}{
+		{"bias", &s.bias, []string{"pull-up", "pull-down", "disabled"}},
+		{"drive", &s.drive, []string{"push-pull", "open-drain", "open-source"}},
+		{"edge", &s.edge, []string{"rising", "falling", "both"}},
+	} {
+		v, ok := args[opt.name].(string)
+		if !ok {
+			continue
+		}
+		found := false
+		for _, valid := range opt.valid {
+			found = found || v == valid
+		}
+		if !found {
+			return s, ErrorResult(fmt.Sprintf("invalid %s %q (valid: %s)", opt.name, v, strings.Join(opt.valid, ", ")))
+		}
+		*opt.dst = v
+	}
+	if ms, ok := args["debounce_ms"].(float64); ok {
+		if ms < 0 || ms > 1000 {
+			return s, ErrorResult("debounce_ms must be between 0 and 1000")
+		}
+		s.debounce = time.Duration(ms) * time.Millisecond
+	}
+	return s, nil
+}
+
+// parseGPIOTimeout returns the wait timeout from args["timeout_ms"].
+//
+//nolint:unused // Used by gpio_linux.go
+func parseGPIOTimeout(args map[string]any) (time.Duration, *ToolResult) {
+	ms, ok := args["timeout_ms"].(float64)
+	if !ok {
+		return defaultGPIOWaitTimeout, nil
+	}
+	timeout := time.Duration(ms) * time.Millisecond
+	if timeout < 0 || timeout > maxGPIOWaitTimeout {
+		return 0, ErrorResult(fmt.Sprintf("timeout_ms must be between 0 and %d", maxGPIOWaitTimeout.Milliseconds()))
+	}
+	return timeout, nil
+}
+
+// checkAllowed reports an error unless every offset is in the allowlist
+// for reading or, if output is set, for driving.
+//
+//nolint:unused // Used by gpio_linux.go
+func (t *GPIOTool) checkAllowed(chip string, offsets []int, output bool) *ToolResult {
+	set, kind := t.lines, "lines"
+	if output {
+		set, kind = t.outputLines, "output_lines"
+	}
+	for _, offset := range offsets {
+		if set[chip][offset] {
+			continue
+		}
+		allowed := "none"
+		if offsets := sortedGPIOLines(set[chip]); len(offsets) > 0 {
+			allowed = strings.Trim(fmt.Sprint(offsets), "[]")
+		}
+		return ErrorResult(fmt.Sprintf(
+			"%s line %d is not allowed for this agent (allowed %s: %s). "+
+				"Lines are allowed under gpio.%s in the agent configuration.",
+			chip, offset, kind, allowed, kind))
+	}
+	return nil
+}
+
+// allowedLines returns the allowed offsets of chip for list.
+//
+//nolint:unused // Used by gpio_linux.go
+func (t *GPIOTool) allowedLines(chip string) (read, output []int) {
+	return sortedGPIOLines(t.lines[chip]), sortedGPIOLines(t.outputLines[chip])
+}
+
+func sortedGPIOLines(set map[int]bool) []int {
+	offsets := make([]int, 0, len(set))
+	for offset := range set {
+		offsets = append(offsets, offset)
+	}
+	sort.Ints(offsets)
+	return offsets
+}
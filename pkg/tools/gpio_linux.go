@@ -0,0 +1,664 @@
+package tools
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// GPIO character device ioctl constants from <linux/gpio.h> (uAPI v2).
+// Calculated from the _IOR/_IOWR(0xB4, nr, size) macros:
+//
+//	direction<<30 | size<<16 | type(0xB4)<<8 | nr
+const (
+	gpioGetChipInfoIoctl     = 0x8044B401 // _IOR(0xB4, 0x01, struct gpiochip_info) — 68 bytes
+	gpioV2GetLineInfoIoctl   = 0xC100B405 // _IOWR(0xB4, 0x05, struct gpio_v2_line_info) — 256 bytes
+	gpioV2GetLineIoctl       = 0xC250B407 // _IOWR(0xB4, 0x07, struct gpio_v2_line_request) — 592 bytes
+	gpioV2LineGetValuesIoctl = 0xC010B40E // _IOWR(0xB4, 0x0E, struct gpio_v2_line_values) — 16 bytes
+	gpioV2LineSetValuesIoctl = 0xC010B40F // _IOWR(0xB4, 0x0F, struct gpio_v2_line_values) — 16 bytes
+
+	// enum gpio_v2_line_flag
+	gpioV2LineFlagUsed         = 1 << 0
+	gpioV2LineFlagActiveLow    = 1 << 1
+	gpioV2LineFlagInput        = 1 << 2
+	gpioV2LineFlagOutput       = 1 << 3
+	gpioV2LineFlagEdgeRising   = 1 << 4
+	gpioV2LineFlagEdgeFalling  = 1 << 5
+	gpioV2LineFlagOpenDrain    = 1 << 6
+	gpioV2LineFlagOpenSource   = 1 << 7
+	gpioV2LineFlagBiasPullUp   = 1 << 8
+	gpioV2LineFlagBiasPullDown = 1 << 9
+	gpioV2LineFlagBiasDisabled = 1 << 10
+
+	// enum gpio_v2_line_attr_id
+	gpioV2LineAttrIDOutputValues = 2
+	gpioV2LineAttrIDDebounce     = 3
+
+	// enum gpio_v2_line_event_id
+	gpioV2LineEventRisingEdge  = 1
+	gpioV2LineEventFallingEdge = 2
+
+	// gpioEventBufferSize is the kernel event queue of a wait request.
+	gpioEventBufferSize = 16
+)
+
+// gpiochipInfo matches the kernel struct gpiochip_info.
+type gpiochipInfo struct {
+	name  [32]byte
+	label [32]byte
+	lines uint32
+}
+
+// gpioV2LineAttribute matches the kernel struct gpio_v2_line_attribute.
+// value holds the flags, output values or debounce period of the union;
+// the debounce period is a __u32 in its first 4 bytes (see gpioDebounceValue).
+type gpioV2LineAttribute struct {
+	id      uint32
+	padding uint32
+	value   uint64
+}
+
+// gpioDebounceValue returns the attribute value holding debounce as the
+// union's __u32 debounce_period_us, which occupies the first 4 bytes of the
+// 8-byte value whatever the byte order.
+func gpioDebounceValue(debounce time.Duration) uint64 {
+	var b [8]byte
+	binary.NativeEndian.PutUint32(b[:4], uint32(debounce.Microseconds()))
+	return binary.NativeEndian.Uint64(b[:])
+}
+
+// gpioV2LineConfigAttribute matches the kernel struct gpio_v2_line_config_attribute.
+type gpioV2LineConfigAttribute struct {
+	attr gpioV2LineAttribute
+	mask uint64
+}
+
+// gpioV2LineConfig matches the kernel struct gpio_v2_line_config.
+type gpioV2LineConfig struct {
+	flags    uint64
+	numAttrs uint32
+	padding  [5]uint32
+	attrs    [10]gpioV2LineConfigAttribute
+}
+
+// gpioV2LineRequest matches the kernel struct gpio_v2_line_request.
+type gpioV2LineRequest struct {
+	offsets         [maxGPIOLines]uint32
+	consumer        [32]byte
+	config          gpioV2LineConfig
+	numLines        uint32
+	eventBufferSize uint32
+	padding         [5]uint32
+	fd              int32
+}
+
+// gpioV2LineInfo matches the kernel struct gpio_v2_line_info.
+type gpioV2LineInfo struct {
+	name     [32]byte
+	consumer [32]byte
+	offset   uint32
+	numAttrs uint32
+	flags    uint64
+	attrs    [10]gpioV2LineAttribute
+	padding  [4]uint32
+}
+
+// gpioV2LineValues matches the kernel struct gpio_v2_line_values. Bit i
+// refers to the i-th line of the request.
+type gpioV2LineValues struct {
+	bits uint64
+	mask uint64
+}
+
+// gpioV2LineEvent matches the kernel struct gpio_v2_line_event.
+type gpioV2LineEvent struct {
+	timestampNs uint64
+	id          uint32
+	offset      uint32
+	seqno       uint32
+	lineSeqno   uint32
+	padding     [6]uint32
+}
+
+// gpioDevDir is where the chip device files live; tests point it elsewhere.
+var gpioDevDir = "/dev"
+
+// gpioIoctl performs an ioctl on a chip or line request fd. Tests replace it
+// to emulate the kernel.
+var gpioIoctl = func(fd int, req uint, arg unsafe.Pointer) error {
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), uintptr(req), uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// openGPIOChip opens a chip by name and reads its info.
+func openGPIOChip(chip string) (int, gpiochipInfo, error) {
+	var info gpiochipInfo
+	path := filepath.Join(gpioDevDir, chip)
+	fd, err := unix.Open(path, unix.O_RDWR|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return -1, info, fmt.Errorf("failed to open %s: %w (check that it exists and that you may access it)", path, err)
+	}
+	if err := gpioIoctl(fd, gpioGetChipInfoIoctl, unsafe.Pointer(&info)); err != nil {
+		unix.Close(fd)
+		return -1, info, fmt.Errorf("%s is not a GPIO chip: %w", path, err)
+	}
+	return fd, info, nil
+}
+
+// requestGPIOLines requests offsets of chip with the given flags and, for
+// outputs, initial values, and returns the line request fd. The chip fd is
+// not needed once the request exists.
+func requestGPIOLines(chip string, offsets []int, flags uint64, values []int, debounce time.Duration) (int, error) {
+	fd, info, err := openGPIOChip(chip)
+	if err != nil {
+		return -1, err
+	}
+	defer unix.Close(fd)
+
+	var req gpioV2LineRequest
+	for i, offset := range offsets {
+		if offset >= int(info.lines) {
+			return -1, fmt.Errorf("%s has no line %d (it has %d lines)", chip, offset, info.lines)
+		}
+		req.offsets[i] = uint32(offset)
+	}
+	req.numLines = uint32(len(offsets))
+	copy(req.consumer[:], gpioConsumer)
+	req.config.flags = flags
+	all := uint64(1)<<len(offsets) - 1
+	if values != nil {
+		var bits uint64
+		for i, v := range values {
+			if v != 0 {
+				bits |= 1 << i
+			}
+		}
+		req.config.attrs[req.config.numAttrs] = gpioV2LineConfigAttribute{
+			attr: gpioV2LineAttribute{id: gpioV2LineAttrIDOutputValues, value: bits},
+			mask: all,
+		}
+		req.config.numAttrs++
+	}
+	if debounce > 0 {
+		req.config.attrs[req.config.numAttrs] = gpioV2LineConfigAttribute{
+			attr: gpioV2LineAttribute{id: gpioV2LineAttrIDDebounce, value: gpioDebounceValue(debounce)},
+			mask: all,
+		}
+		req.config.numAttrs++
+	}
+	if flags&(gpioV2LineFlagEdgeRising|gpioV2LineFlagEdgeFalling) != 0 {
+		req.eventBufferSize = gpioEventBufferSize
+	}
+	if err := gpioIoctl(fd, gpioV2GetLineIoctl, unsafe.Pointer(&req)); err != nil {
+		if errors.Is(err, unix.EBUSY) {
+			return -1, fmt.Errorf("%s line(s) %v are in use by another driver or program", chip, offsets)
+		}
+		return -1, fmt.Errorf("failed to request %s line(s) %v: %w", chip, offsets, err)
+	}
+	return int(req.fd), nil
+}
+
+// gpioGetValues reads the values of the n lines of a request.
+func gpioGetValues(fd, n int) ([]int, error) {
+	vals := gpioV2LineValues{mask: uint64(1)<<n - 1}
+	if err := gpioIoctl(fd, gpioV2LineGetValuesIoctl, unsafe.Pointer(&vals)); err != nil {
+		return nil, err
+	}
+	values := make([]int, n)
+	for i := range values {
+		values[i] = int(vals.bits >> i & 1)
+	}
+	return values, nil
+}
+
+// gpioSetValue drives the single line of a request.
+func gpioSetValue(fd, value int) error {
+	vals := gpioV2LineValues{bits: uint64(value & 1), mask: 1}
+	return gpioIoctl(fd, gpioV2LineSetValuesIoctl, unsafe.Pointer(&vals))
+}
+
+// settingsFlags returns the line flags for s, as an input or an output.
+func settingsFlags(s gpioSettings, output bool) uint64 {
+	var flags uint64 = gpioV2LineFlagInput
+	if output {
+		flags = gpioV2LineFlagOutput
+		switch s.drive {
+		case "open-drain":
+			flags |= gpioV2LineFlagOpenDrain
+		case "open-source":
+			flags |= gpioV2LineFlagOpenSource
+		}
+	}
+	if s.activeLow {
+		flags |= gpioV2LineFlagActiveLow
+	}
+	switch s.bias {
+	case "pull-up":
+		flags |= gpioV2LineFlagBiasPullUp
+	case "pull-down":
+		flags |= gpioV2LineFlagBiasPullDown
+	case "disabled":
+		flags |= gpioV2LineFlagBiasDisabled
+	}
+	return flags
+}
+
+func cString(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+	return string(b)
+}
+
+// list shows the chips, or the lines of one chip, with what the agent may use.
+func (t *GPIOTool) list(args map[string]any) *ToolResult {
+	if _, ok := args["chip"]; ok {
+		chip, errResult := parseGPIOChip(args)
+		if errResult != nil {
+			return errResult
+		}
+		return t.listLines(chip)
+	}
+
+	matches, err := filepath.Glob(filepath.Join(gpioDevDir, "gpiochip*"))
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to scan for GPIO chips: %v", err))
+	}
+	type chipInfo struct {
+		Chip        string `json:"chip"`
+		Label       string `json:"label"`
+		Lines       int    `json:"lines"`
+		Allowed     []int  `json:"allowed_lines"`
+		OutputLines []int  `json:"allowed_output_lines"`
+	}
+	chips := make([]chipInfo, 0, len(matches))
+	for _, m := range matches {
+		chip := filepath.Base(m)
+		if gpioChipArgRe.FindStringSubmatch(chip) == nil {
+			continue
+		}
+		fd, info, err := openGPIOChip(chip)
+		if err != nil {
+			continue
+		}
+		unix.Close(fd)
+		read, output := t.allowedLines(chip)
+		chips = append(chips, chipInfo{
+			Chip: chip, Label: cString(info.label[:]), Lines: int(info.lines), Allowed: read, OutputLines: output,
+		})
+	}
+	if len(chips) == 0 {
+		return SilentResult(
+			"No GPIO chips found. Check that the board's GPIO controller is enabled in the device tree " +
+				"and that /dev/gpiochip* is accessible (see hardware skill)",
+		)
+	}
+	// Order gpiochip2 before gpiochip10.
+	sort.Slice(chips, func(i, j int) bool {
+		a, b := chips[i].Chip, chips[j].Chip
+		return len(a) < len(b) || len(a) == len(b) && a < b
+	})
+
+	result, _ := json.MarshalIndent(chips, "", "  ")
+	return SilentResult(fmt.Sprintf("Found %d GPIO chip(s):\n%s", len(chips), string(result)))
+}
+
+// listLines shows every line of chip with its name, consumer and flags.
+func (t *GPIOTool) listLines(chip string) *ToolResult {
+	fd, info, err := openGPIOChip(chip)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+	defer unix.Close(fd)
+
+	type lineInfo struct {
+		Line      int    `json:"line"`
+		Name      string `json:"name,omitempty"`
+		Consumer  string `json:"consumer,omitempty"`
+		Direction string `json:"direction"`
+		ActiveLow bool   `json:"active_low,omitempty"`
+		Bias      string `json:"bias,omitempty"`
+		Drive     string `json:"drive,omitempty"`
+		Edge      string `json:"edge,omitempty"`
+		Access    string `json:"access,omitempty"`
+	}
+	lines := make([]lineInfo, 0, info.lines)
+	for offset := 0; offset < int(info.lines); offset++ {
+		li := gpioV2LineInfo{offset: uint32(offset)}
+		if err := gpioIoctl(fd, gpioV2GetLineInfoIoctl, unsafe.Pointer(&li)); err != nil {
+			return ErrorResult(fmt.Sprintf("failed to read %s line %d: %v", chip, offset, err))
+		}
+		l := lineInfo{
+			Line:      offset,
+			Name:      cString(li.name[:]),
+			Consumer:  cString(li.consumer[:]),
+			Direction: "input",
+			ActiveLow: li.flags&gpioV2LineFlagActiveLow != 0,
+		}
+		if li.flags&gpioV2LineFlagOutput != 0 {
+			l.Direction = "output"
+			l.Drive = "push-pull"
+			if li.flags&gpioV2LineFlagOpenDrain != 0 {
+				l.Drive = "open-drain"
+			} else if li.flags&gpioV2LineFlagOpenSource != 0 {
+				l.Drive = "open-source"
+			}
+		}
+		switch {
+		case li.flags&gpioV2LineFlagBiasPullUp != 0:
+			l.Bias = "pull-up"
+		case li.flags&gpioV2LineFlagBiasPullDown != 0:
+			l.Bias = "pull-down"
+		case li.flags&gpioV2LineFlagBiasDisabled != 0:
+			l.Bias = "disabled"
+		}
+		switch li.flags & (gpioV2LineFlagEdgeRising | gpioV2LineFlagEdgeFalling) {
+		case gpioV2LineFlagEdgeRising:
+			l.Edge = "rising"
+		case gpioV2LineFlagEdgeFalling:
+			l.Edge = "falling"
+		case gpioV2LineFlagEdgeRising | gpioV2LineFlagEdgeFalling:
+			l.Edge = "both"
+		}
+		if li.flags&gpioV2LineFlagUsed != 0 && l.Consumer == "" {
+			l.Consumer = "(in use)"
+		}
+		switch {
+		case t.outputLines[chip][offset]:
+			l.Access = "read/write"
+		case t.lines[chip][offset]:
+			l.Access = "read"
+		}
+		lines = append(lines, l)
+	}
+
+	result, _ := json.MarshalIndent(lines, "", "  ")
+	return SilentResult(fmt.Sprintf("%s (%s) has %d line(s); access shows what this agent may use:\n%s",
+		chip, cString(info.label[:]), info.lines, string(result)))
+}
+
+// readLines reads the values of one or more lines. Lines the tool drives
+// as outputs report the value being driven.
+func (t *GPIOTool) readLines(args map[string]any) *ToolResult {
+	chip, errResult := parseGPIOChip(args)
+	if errResult != nil {
+		return errResult
+	}
+	offsets, errResult := parseGPIOLines(args, true)
+	if errResult != nil {
+		return errResult
+	}
+	if errResult := t.checkAllowed(chip, offsets, false); errResult != nil {
+		return errResult
+	}
+	settings, errResult := parseGPIOSettings(args)
+	if errResult != nil {
+		return errResult
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	values := make(map[int]int, len(offsets))
+	var inputs []int
+	for _, offset := range offsets {
+		fd, held := t.held[gpioLine{chip, offset}]
+		if !held {
+			inputs = append(inputs, offset)
+			continue
+		}
+		v, err := gpioGetValues(fd, 1)
+		if err != nil {
+			return ErrorResult(fmt.Sprintf("failed to read %s line %d: %v", chip, offset, err))
+		}
+		values[offset] = v[0]
+	}
+	if len(inputs) > 0 {
+		fd, err := requestGPIOLines(chip, inputs, settingsFlags(settings, false), nil, 0)
+		if err != nil {
+			return ErrorResult(err.Error())
+		}
+		v, err := gpioGetValues(fd, len(inputs))
+		unix.Close(fd)
+		if err != nil {
+			return ErrorResult(fmt.Sprintf("failed to read %s lines %v: %v", chip, inputs, err))
+		}
+		for i, offset := range inputs {
+			values[offset] = v[i]
+		}
+	}
+
+	type lineValue struct {
+		Line  int `json:"line"`
+		Value int `json:"value"`
+	}
+	out := make([]lineValue, 0, len(offsets))
+	for _, offset := range offsets {
+		out = append(out, lineValue{offset, values[offset]})
+	}
+	result, _ := json.MarshalIndent(map[string]any{"chip": chip, "values": out}, "", "  ")
+	return SilentResult(string(result))
+}
+
+// writeLine drives a line. The line stays requested as an output, keeping
+// its value, until it is released.
+func (t *GPIOTool) writeLine(args map[string]any) *ToolResult {
+	confirm, _ := args["confirm"].(bool)
+	if !confirm {
+		return ErrorResult(
+			"write operations require confirm: true. Please confirm with the user before driving GPIO lines, " +
+				"as a wrong line or value can switch connected equipment or short an output.",
+		)
+	}
+	chip, errResult := parseGPIOChip(args)
+	if errResult != nil {
+		return errResult
+	}
+	offsets, errResult := parseGPIOLines(args, false)
+	if errResult != nil {
+		return errResult
+	}
+	offset := offsets[0]
+	if errResult := t.checkAllowed(chip, offsets, true); errResult != nil {
+		return errResult
+	}
+	v, ok := args["value"].(float64)
+	if !ok || (v != 0 && v != 1) {
+		return ErrorResult("value is required: 0 (inactive) or 1 (active)")
+	}
+	value := int(v)
+	settings, errResult := parseGPIOSettings(args)
+	if errResult != nil {
+		return errResult
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	line := gpioLine{chip, offset}
+	if fd, held := t.held[line]; held {
+		if err := gpioSetValue(fd, value); err != nil {
+			return ErrorResult(fmt.Sprintf("failed to set %s line %d: %v", chip, offset, err))
+		}
+		return SilentResult(fmt.Sprintf("%s line %d set to %d", chip, offset, value))
+	}
+	fd, err := requestGPIOLines(chip, offsets, settingsFlags(settings, true), []int{value}, 0)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+	t.held[line] = fd
+	return SilentResult(fmt.Sprintf(
+		"%s line %d set to %d. The line stays an output with this value until it is released.",
+		chip, offset, value))
+}
+
+// waitEdge waits for edge events on a line until the first one arrives or
+// the timeout passes, and returns the events queued by then.
+func (t *GPIOTool) waitEdge(ctx context.Context, args map[string]any) *ToolResult {
+	chip, errResult := parseGPIOChip(args)
+	if errResult != nil {
+		return errResult
+	}
+	offsets, errResult := parseGPIOLines(args, false)
+	if errResult != nil {
+		return errResult
+	}
+	offset := offsets[0]
+	if errResult := t.checkAllowed(chip, offsets, false); errResult != nil {
+		return errResult
+	}
+	settings, errResult := parseGPIOSettings(args)
+	if errResult != nil {
+		return errResult
+	}
+	timeout, errResult := parseGPIOTimeout(args)
+	if errResult != nil {
+		return errResult
+	}
+
+	t.mu.Lock()
+	_, held := t.held[gpioLine{chip, offset}]
+	t.mu.Unlock()
+	if held {
+		return ErrorResult(fmt.Sprintf("%s line %d is driven as an output; release it before waiting for edges",
+			chip, offset))
+	}
+
+	flags := settingsFlags(settings, false)
+	switch settings.edge {
+	case "rising":
+		flags |= gpioV2LineFlagEdgeRising
+	case "falling":
+		flags |= gpioV2LineFlagEdgeFalling
+	default:
+		flags |= gpioV2LineFlagEdgeRising | gpioV2LineFlagEdgeFalling
+	}
+	fd, err := requestGPIOLines(chip, offsets, flags, nil, settings.debounce)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+	defer unix.Close(fd)
+
+	events, err := readGPIOEvents(ctx, fd, timeout)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed waiting for %s line %d: %v", chip, offset, err))
+	}
+	if len(events) == 0 {
+		return SilentResult(fmt.Sprintf("No %s edge on %s line %d within %dms",
+			settings.edge, chip, offset, timeout.Milliseconds()))
+	}
+
+	type edgeEvent struct {
+		Edge        string `json:"edge"`
+		TimestampNs uint64 `json:"timestamp_ns"`
+		Seqno       uint32 `json:"seqno"`
+	}
+	out := make([]edgeEvent, 0, len(events))
+	for _, ev := range events {
+		edge := "rising"
+		if ev.id == gpioV2LineEventFallingEdge {
+			edge = "falling"
+		}
+		out = append(out, edgeEvent{Edge: edge, TimestampNs: ev.timestampNs, Seqno: ev.lineSeqno})
+	}
+	resp := map[string]any{"chip": chip, "line": offset, "events": out}
+	if v, err := gpioGetValues(fd, 1); err == nil {
+		resp["value"] = v[0]
+	}
+	result, _ := json.MarshalIndent(resp, "", "  ")
+	return SilentResult(string(result))
+}
+
+// readGPIOEvents waits up to timeout for the line request fd to report
+// events and returns those queued, or none on timeout.
+func readGPIOEvents(ctx context.Context, fd int, timeout time.Duration) ([]gpioV2LineEvent, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		// Wake up regularly so a cancelled turn does not wait out the timeout.
+		ms := min(int(time.Until(deadline).Milliseconds()), 200)
+		fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+		n, err := unix.Poll(fds, max(ms, 0))
+		if errors.Is(err, unix.EINTR) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if n > 0 {
+			break
+		}
+		if ms <= 0 {
+			return nil, nil
+		}
+	}
+
+	var buf [gpioEventBufferSize]gpioV2LineEvent
+	size := int(unsafe.Sizeof(buf[0]))
+	raw := unsafe.Slice((*byte)(unsafe.Pointer(&buf[0])), len(buf)*size)
+	n, err := unix.Read(fd, raw)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:n/size], nil
+}
+
+// release stops driving a line, or every line the tool holds when no chip
+// is given. Released lines return to the control of the kernel.
+func (t *GPIOTool) release(args map[string]any) *ToolResult {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := args["chip"]; !ok {
+		n := len(t.held)
+		t.closeHeld()
+		return SilentResult(fmt.Sprintf("Released %d GPIO line(s)", n))
+	}
+	chip, errResult := parseGPIOChip(args)
+	if errResult != nil {
+		return errResult
+	}
+	offsets, errResult := parseGPIOLines(args, true)
+	if errResult != nil {
+		return errResult
+	}
+	released := 0
+	for _, offset := range offsets {
+		line := gpioLine{chip, offset}
+		if fd, held := t.held[line]; held {
+			unix.Close(fd)
+			delete(t.held, line)
+			released++
+		}
+	}
+	return SilentResult(fmt.Sprintf("Released %d of %d %s line(s)", released, len(offsets), chip))
+}
+
+// Close releases every line the tool holds.
+func (t *GPIOTool) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closeHeld()
+}
+
+func (t *GPIOTool) closeHeld() {
+	for line, fd := range t.held {
+		unix.Close(fd)
+		delete(t.held, line)
+	}
+}
@@ -0,0 +1,319 @@
+package tools
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// fakeGPIOChip is a chip emulated by fakeGPIO.
+type fakeGPIOChip struct {
+	label  string
+	names  []string
+	values []int
+}
+
+// fakeGPIORequest is a line request handed out by fakeGPIO. Its fd is the
+// read end of a pipe, so poll and read work as on a real request.
+type fakeGPIORequest struct {
+	chip     *fakeGPIOChip
+	offsets  []int
+	flags    uint64
+	debounce uint32 // microseconds
+	events   int    // write end of the pipe
+}
+
+// fakeGPIO emulates the GPIO uAPI v2 ioctls over empty files standing in
+// for /dev/gpiochipN.
+type fakeGPIO struct {
+	t     *testing.T
+	mu    sync.Mutex
+	chips map[string]*fakeGPIOChip
+	reqs  map[int]*fakeGPIORequest
+}
+
+func newFakeGPIO(t *testing.T, chips map[string]*fakeGPIOChip) *fakeGPIO {
+	t.Helper()
+	f := &fakeGPIO{t: t, chips: chips, reqs: make(map[int]*fakeGPIORequest)}
+	dir := t.TempDir()
+	for name := range chips {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	origDir, origIoctl := gpioDevDir, gpioIoctl
+	gpioDevDir, gpioIoctl = dir, f.ioctl
+	t.Cleanup(func() {
+		gpioDevDir, gpioIoctl = origDir, origIoctl
+		for _, req := range f.reqs {
+			unix.Close(req.events)
+		}
+	})
+	return f
+}
+
+func (f *fakeGPIO) ioctl(fd int, req uint, arg unsafe.Pointer) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch req {
+	case gpioGetChipInfoIoctl:
+		chip, name := f.chipOf(fd)
+		info := (*gpiochipInfo)(arg)
+		copy(info.name[:], name)
+		copy(info.label[:], chip.label)
+		info.lines = uint32(len(chip.values))
+	case gpioV2GetLineInfoIoctl:
+		chip, _ := f.chipOf(fd)
+		info := (*gpioV2LineInfo)(arg)
+		copy(info.name[:], chip.names[info.offset])
+		for _, r := range f.reqs {
+			for _, offset := range r.offsets {
+				if r.chip == chip && offset == int(info.offset) {
+					info.flags = r.flags | gpioV2LineFlagUsed
+					copy(info.consumer[:], gpioConsumer)
+				}
+			}
+		}
+	case gpioV2GetLineIoctl:
+		chip, _ := f.chipOf(fd)
+		lr := (*gpioV2LineRequest)(arg)
+		r := &fakeGPIORequest{chip: chip, flags: lr.config.flags}
+		for i := 0; i < int(lr.numLines); i++ {
+			r.offsets = append(r.offsets, int(lr.offsets[i]))
+		}
+		for _, a := range lr.config.attrs[:lr.config.numAttrs] {
+			switch a.attr.id {
+			case gpioV2LineAttrIDOutputValues:
+				for i, offset := range r.offsets {
+					chip.values[offset] = int(a.attr.value >> i & 1)
+				}
+			case gpioV2LineAttrIDDebounce:
+				// debounce_period_us is the first __u32 of the value union.
+				var b [8]byte
+				binary.NativeEndian.PutUint64(b[:], a.attr.value)
+				r.debounce = binary.NativeEndian.Uint32(b[:4])
+			}
+		}
+		var p [2]int
+		if err := unix.Pipe2(p[:], unix.O_CLOEXEC|unix.O_NONBLOCK); err != nil {
+			return err
+		}
+		r.events = p[1]
+		f.reqs[p[0]] = r
+		lr.fd = int32(p[0])
+	case gpioV2LineGetValuesIoctl, gpioV2LineSetValuesIoctl:
+		r, ok := f.reqs[fd]
+		if !ok {
+			return unix.EBADF
+		}
+		vals := (*gpioV2LineValues)(arg)
+		for i, offset := range r.offsets {
+			if vals.mask>>i&1 == 0 {
+				continue
+			}
+			if req == gpioV2LineSetValuesIoctl {
+				r.chip.values[offset] = int(vals.bits >> i & 1)
+			} else {
+				vals.bits |= uint64(r.chip.values[offset]) << i
+			}
+		}
+	default:
+		return unix.ENOTTY
+	}
+	return nil
+}
+
+// chipOf returns the chip whose file fd refers to.
+func (f *fakeGPIO) chipOf(fd int) (*fakeGPIOChip, string) {
+	path, err := os.Readlink(fmt.Sprintf("/proc/self/fd/%d", fd))
+	if err != nil {
+		f.t.Fatalf("ioctl on unknown fd %d: %v", fd, err)
+	}
+	name := filepath.Base(path)
+	return f.chips[name], name
+}
+
+// edge waits for a request watching the line for edges and queues an event on it.
+func (f *fakeGPIO) edge(chip string, offset int, rising bool) {
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		f.mu.Lock()
+		for _, r := range f.reqs {
+			if r.chip != f.chips[chip] || r.offsets[0] != offset ||
+				r.flags&(gpioV2LineFlagEdgeRising|gpioV2LineFlagEdgeFalling) == 0 {
+				continue
+			}
+			ev := gpioV2LineEvent{timestampNs: 1234, id: gpioV2LineEventFallingEdge, offset: uint32(offset), lineSeqno: 1}
+			if rising {
+				ev.id = gpioV2LineEventRisingEdge
+			}
+			r.chip.values[offset] = map[bool]int{true: 1, false: 0}[rising]
+			unix.Write(r.events, unsafe.Slice((*byte)(unsafe.Pointer(&ev)), unsafe.Sizeof(ev)))
+			f.mu.Unlock()
+			return
+		}
+		f.mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+	f.t.Errorf("no edge request for %s line %d", chip, offset)
+}
+
+func newFakeChips() map[string]*fakeGPIOChip {
+	names := make([]string, 32)
+	names[17] = "GPIO17"
+	return map[string]*fakeGPIOChip{
+		"gpiochip0": {label: "pinctrl-bcm2711", names: names, values: make([]int, 32)},
+		"gpiochip1": {label: "raspberrypi-exp-gpio", names: make([]string, 8), values: make([]int, 8)},
+	}
+}
+
+func TestGPIOStructSizes(t *testing.T) {
+	sizes := map[uint]uintptr{
+		gpioGetChipInfoIoctl:     unsafe.Sizeof(gpiochipInfo{}),
+		gpioV2GetLineInfoIoctl:   unsafe.Sizeof(gpioV2LineInfo{}),
+		gpioV2GetLineIoctl:       unsafe.Sizeof(gpioV2LineRequest{}),
+		gpioV2LineGetValuesIoctl: unsafe.Sizeof(gpioV2LineValues{}),
+	}
+	for req, size := range sizes {
+		if want := uintptr(req >> 16 & 0x3fff); size != want {
+			t.Errorf("ioctl %#x: struct is %d bytes, want %d", req, size, want)
+		}
+	}
+	if size := unsafe.Sizeof(gpioV2LineEvent{}); size != 48 {
+		t.Errorf("gpio_v2_line_event is %d bytes, want 48", size)
+	}
+
+	// debounce_period_us is a __u32 at the start of the attribute union.
+	value := gpioDebounceValue(20 * time.Millisecond)
+	union := (*[2]uint32)(unsafe.Pointer(&value))
+	if union[0] != 20000 || union[1] != 0 {
+		t.Errorf("debounce value = %v, want 20000 in the first __u32", *union)
+	}
+}
+
+func TestGPIOTool_ListReadWrite(t *testing.T) {
+	fake := newFakeGPIO(t, newFakeChips())
+	tool := NewGPIOTool(&config.GPIOConfig{
+		Lines:       map[string][]int{"gpiochip0": {4}},
+		OutputLines: map[string][]int{"gpiochip0": {17}},
+	})
+	defer tool.Close()
+	ctx := context.Background()
+
+	result := tool.Execute(ctx, map[string]any{"action": "list"})
+	if result.IsError || !strings.Contains(result.ForLLM, "Found 2 GPIO chip(s)") ||
+		!strings.Contains(result.ForLLM, `"allowed_lines": [
+      4,
+      17
+    ]`) {
+		t.Errorf("list = %s", result.ForLLM)
+	}
+
+	fake.chips["gpiochip0"].values[4] = 1
+	result = tool.Execute(ctx, map[string]any{"action": "read", "chip": "0", "lines": []any{4.0, 17.0}})
+	if result.IsError || !strings.Contains(result.ForLLM, `"line": 4,
+      "value": 1`) {
+		t.Errorf("read = %s", result.ForLLM)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"action": "write", "chip": "gpiochip0", "line": 17.0, "value": 1.0})
+	if !result.IsError || !strings.Contains(result.ForLLM, "confirm") {
+		t.Errorf("write without confirm: %s", result.ForLLM)
+	}
+	result = tool.Execute(ctx, map[string]any{
+		"action": "write", "chip": "gpiochip0", "line": 4.0, "value": 1.0, "confirm": true,
+	})
+	if !result.IsError || !strings.Contains(result.ForLLM, "allowed output_lines: 17") {
+		t.Errorf("write to an input-only line: %s", result.ForLLM)
+	}
+	result = tool.Execute(ctx, map[string]any{
+		"action": "write", "chip": "gpiochip0", "line": 17.0, "value": 1.0, "drive": "open-drain", "confirm": true,
+	})
+	if result.IsError || fake.chips["gpiochip0"].values[17] != 1 {
+		t.Fatalf("write = %s, value %d", result.ForLLM, fake.chips["gpiochip0"].values[17])
+	}
+
+	// The line stays requested: list shows it, and writes and reads reuse it.
+	result = tool.Execute(ctx, map[string]any{"action": "list", "chip": "gpiochip0"})
+	var lines []map[string]any
+	json.Unmarshal([]byte(result.ForLLM[strings.Index(result.ForLLM, "["):]), &lines)
+	if len(lines) != 32 || lines[17]["name"] != "GPIO17" || lines[17]["consumer"] != gpioConsumer ||
+		lines[17]["direction"] != "output" || lines[17]["drive"] != "open-drain" ||
+		lines[17]["access"] != "read/write" || lines[4]["access"] != "read" || lines[5]["access"] != nil {
+		t.Errorf("list lines = %s", result.ForLLM)
+	}
+	tool.Execute(ctx, map[string]any{"action": "write", "chip": "gpiochip0", "line": 17.0, "value": 0.0, "confirm": true})
+	if fake.chips["gpiochip0"].values[17] != 0 || len(tool.held) != 1 {
+		t.Errorf("second write: value %d, %d held", fake.chips["gpiochip0"].values[17], len(tool.held))
+	}
+	fake.chips["gpiochip0"].values[17] = 1
+	result = tool.Execute(ctx, map[string]any{"action": "read", "chip": "gpiochip0", "line": 17.0})
+	if !strings.Contains(result.ForLLM, `"value": 1`) {
+		t.Errorf("read of a held line = %s", result.ForLLM)
+	}
+	if result := tool.Execute(ctx, map[string]any{"action": "wait", "chip": "0", "line": 17.0}); !result.IsError {
+		t.Errorf("wait on a held output should fail, got: %s", result.ForLLM)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"action": "release"})
+	if result.ForLLM != "Released 1 GPIO line(s)" || len(tool.held) != 0 {
+		t.Errorf("release = %s", result.ForLLM)
+	}
+}
+
+func TestGPIOTool_Wait(t *testing.T) {
+	fake := newFakeGPIO(t, newFakeChips())
+	tool := NewGPIOTool(&config.GPIOConfig{Lines: map[string][]int{"gpiochip1": {3}}})
+	ctx := context.Background()
+
+	result := tool.Execute(ctx, map[string]any{
+		"action": "wait", "chip": "gpiochip1", "line": 3.0, "edge": "falling", "timeout_ms": 50.0,
+	})
+	if result.IsError || result.ForLLM != "No falling edge on gpiochip1 line 3 within 50ms" {
+		t.Errorf("wait timeout = %s", result.ForLLM)
+	}
+
+	go fake.edge("gpiochip1", 3, true)
+	result = tool.Execute(ctx, map[string]any{
+		"action": "wait", "chip": "gpiochip1", "line": 3.0, "bias": "pull-down", "debounce_ms": 20.0,
+	})
+	var out struct {
+		Events []struct {
+			Edge        string `json:"edge"`
+			TimestampNs uint64 `json:"timestamp_ns"`
+		} `json:"events"`
+		Value int `json:"value"`
+	}
+	if err := json.Unmarshal([]byte(result.ForLLM), &out); err != nil {
+		t.Fatalf("wait = %s", result.ForLLM)
+	}
+	if len(out.Events) != 1 || out.Events[0].Edge != "rising" || out.Events[0].TimestampNs != 1234 || out.Value != 1 {
+		t.Errorf("wait = %s", result.ForLLM)
+	}
+	for _, r := range fake.reqs {
+		want := uint64(gpioV2LineFlagInput | gpioV2LineFlagEdgeRising | gpioV2LineFlagEdgeFalling |
+			gpioV2LineFlagBiasPullDown)
+		if r.flags == want && r.debounce != 20000 {
+			t.Errorf("debounce = %dus, want 20000", r.debounce)
+		}
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	result = tool.Execute(cancelled, map[string]any{"action": "wait", "chip": "gpiochip1", "line": 3.0})
+	if !result.IsError {
+		t.Errorf("wait with a cancelled context = %s", result.ForLLM)
+	}
+}
@@ -0,0 +1,33 @@
+//go:build !linux
+
+package tools
+
+import "context"
+
+// list is a stub for non-Linux platforms.
+func (t *GPIOTool) list(args map[string]any) *ToolResult {
+	return ErrorResult("GPIO is only supported on Linux")
+}
+
+// readLines is a stub for non-Linux platforms.
+func (t *GPIOTool) readLines(args map[string]any) *ToolResult {
+	return ErrorResult("GPIO is only supported on Linux")
+}
+
+// writeLine is a stub for non-Linux platforms.
+func (t *GPIOTool) writeLine(args map[string]any) *ToolResult {
+	return ErrorResult("GPIO is only supported on Linux")
+}
+
+// waitEdge is a stub for non-Linux platforms.
+func (t *GPIOTool) waitEdge(ctx context.Context, args map[string]any) *ToolResult {
+	return ErrorResult("GPIO is only supported on Linux")
+}
+
+// release is a stub for non-Linux platforms.
+func (t *GPIOTool) release(args map[string]any) *ToolResult {
+	return ErrorResult("GPIO is only supported on Linux")
+}
+
+// Close is a no-op on non-Linux platforms, where no lines are ever held.
+func (t *GPIOTool) Close() {}
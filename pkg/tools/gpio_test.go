@@ -0,0 +1,53 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestGPIOTool_Allowlist(t *testing.T) {
+	tool := NewGPIOTool(&config.GPIOConfig{
+		Lines:       map[string][]int{"gpiochip0": {4, 5}},
+		OutputLines: map[string][]int{"gpiochip0": {17}, "gpiochip1": {2}},
+	})
+	if r := tool.checkAllowed("gpiochip0", []int{4, 5, 17}, false); r != nil {
+		t.Errorf("reading allowed lines: %s", r.ForLLM)
+	}
+	if r := tool.checkAllowed("gpiochip1", []int{2}, true); r != nil {
+		t.Errorf("driving an allowed line: %s", r.ForLLM)
+	}
+	r := tool.checkAllowed("gpiochip0", []int{17, 4}, true)
+	if r == nil || !strings.Contains(r.ForLLM, "gpiochip0 line 4 is not allowed") {
+		t.Errorf("driving an input-only line should fail, got %v", r)
+	}
+
+	r = NewGPIOTool(nil).checkAllowed("gpiochip0", []int{4}, false)
+	if r == nil || !strings.Contains(r.ForLLM, "allowed lines: none") {
+		t.Errorf("nil config should allow nothing, got %v", r)
+	}
+}
+
+func TestGPIOTool_InvalidArgs(t *testing.T) {
+	tool := NewGPIOTool(&config.GPIOConfig{OutputLines: map[string][]int{"gpiochip0": {17}}})
+	ctx := context.Background()
+	for _, args := range []map[string]any{
+		{"action": "read", "line": 17.0},
+		{"action": "read", "chip": "../gpiochip0", "line": 17.0},
+		{"action": "read", "chip": "gpiochip0"},
+		{"action": "read", "chip": "gpiochip0", "line": -1.0},
+		{"action": "read", "chip": "gpiochip0", "line": 1.5},
+		{"action": "read", "chip": "gpiochip0", "line": 17.0, "bias": "pull-sideways"},
+		{"action": "write", "chip": "gpiochip0", "line": 17.0, "value": 2.0, "confirm": true},
+		{"action": "write", "chip": "gpiochip0", "line": 17.0, "confirm": true},
+		{"action": "wait", "chip": "gpiochip0", "line": 17.0, "timeout_ms": 120000.0},
+		{"action": "wait", "chip": "gpiochip0", "line": 17.0, "debounce_ms": -1.0},
+		{"action": "toggle"},
+	} {
+		if result := tool.Execute(ctx, args); !result.IsError {
+			t.Errorf("Execute(%v) should fail, got: %s", args, result.ForLLM)
+		}
+	}
+}
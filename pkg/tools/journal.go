@@ -0,0 +1,454 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	// JournalDir is the workspace directory holding the file change journal.
+	JournalDir = ".journal"
+
+	DefaultJournalEntries   = 200
+	DefaultJournalBytes     = 64 << 20
+	DefaultJournalFileBytes = 4 << 20
+
+	journalIndex = "index.json"
+)
+
+// JournalEntry records the content a file had before a tool changed it.
+type JournalEntry struct {
+	ID      int64       `json:"id"`
+	Session string      `json:"session"`
+	Turn    int         `json:"turn"`
+	Tool    string      `json:"tool"`
+	Path    string      `json:"path"`
+	Existed bool        `json:"existed"`
+	Size    int64       `json:"size"`
+	Mode    fs.FileMode `json:"mode,omitempty"`    // permission bits, restored with the content
+	Omitted bool        `json:"omitted,omitempty"` // too large to snapshot
+	Time    time.Time   `json:"time"`
+}
+
+// JournaledTool is a tool whose file changes are recorded in a Journal.
+type JournaledTool interface {
+	Tool
+	SetJournal(j *Journal)
+}
+
+// Journal keeps a bounded history of the files changed by the agent so
+// that changes can be rolled back. Snapshots are stored under
+// <workspace>/.journal and keyed by session and turn. The index is read
+// from disk on every call, so the CLI and a running agent can share it.
+// The current turn travels in the context given to the tools, so turns of
+// different sessions may run concurrently.
+type Journal struct {
+	dir          string
+	workspace    string
+	restrict     bool
+	maxEntries   int
+	maxBytes     int64
+	maxFileBytes int64
+
+	mu sync.Mutex
+}
+
+// journalTurn is the turn that changes made with a context are recorded in.
+type journalTurn struct {
+	session string
+	turn    int // 0 until the turn records its first change; guarded by Journal.mu
+}
+
+type journalTurnKey struct{}
+
+// NewJournal creates a journal for workspace. With restrict set, files are
+// only restored inside the workspace. Non-positive limits use the defaults.
+func NewJournal(workspace string, restrict bool, maxEntries int, maxBytes, maxFileBytes int64) *Journal {
+	if maxEntries <= 0 {
+		maxEntries = DefaultJournalEntries
+	}
+	if maxBytes <= 0 {
+		maxBytes = DefaultJournalBytes
+	}
+	if maxFileBytes <= 0 {
+		maxFileBytes = DefaultJournalFileBytes
+	}
+	return &Journal{
+		dir:          filepath.Join(workspace, JournalDir),
+		workspace:    workspace,
+		restrict:     restrict,
+		maxEntries:   maxEntries,
+		maxBytes:     maxBytes,
+		maxFileBytes: maxFileBytes,
+	}
+}
+
+// BeginTurn returns a context for a new turn of session. Changes recorded
+// with it are rolled back together.
+func (j *Journal) BeginTurn(ctx context.Context, session string) context.Context {
+	return context.WithValue(ctx, journalTurnKey{}, &journalTurn{session: session})
+}
+
+// Record stores the content path had before a change in the turn of ctx.
+// existed is false when the change creates the file. Without a turn in ctx
+// the change is recorded as a turn of its own.
+func (j *Journal) Record(ctx context.Context, tool, path string, prior []byte, existed bool) error {
+	return j.record(ctx, tool, path, prior, int64(len(prior)), existed)
+}
+
+// record is Record for a file of size bytes; prior may be nil when size is
+// over the per-file limit, as the content is not snapshotted then.
+func (j *Journal) record(ctx context.Context, tool, path string, prior []byte, size int64, existed bool) error {
+	turn, ok := ctx.Value(journalTurnKey{}).(*journalTurn)
+	if !ok {
+		turn = &journalTurn{}
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entries, err := j.load()
+	if err != nil {
+		return err
+	}
+	if turn.turn == 0 {
+		turn.turn = 1
+		for _, e := range entries {
+			if e.Session == turn.session && e.Turn >= turn.turn {
+				turn.turn = e.Turn + 1
+			}
+		}
+	}
+
+	e := JournalEntry{
+		ID:      1,
+		Session: turn.session,
+		Turn:    turn.turn,
+		Tool:    tool,
+		Path:    path,
+		Existed: existed,
+		Size:    size,
+		Time:    time.Now(),
+	}
+	if info, err := os.Stat(path); existed && err == nil {
+		e.Mode = info.Mode().Perm()
+	}
+	if n := len(entries); n > 0 {
+		e.ID = entries[n-1].ID + 1
+	}
+
+	if err := os.MkdirAll(j.dir, 0o755); err != nil {
+		return fmt.Errorf("creating journal directory: %w", err)
+	}
+	if existed {
+		if e.Size > j.maxFileBytes {
+			e.Omitted = true
+		} else if err := fileutil.WriteFileAtomic(j.snapshotPath(e.ID), prior, 0o600); err != nil {
+			return fmt.Errorf("writing snapshot: %w", err)
+		}
+	}
+
+	return j.save(j.prune(append(entries, e)))
+}
+
+// Entries returns the recorded changes, oldest first.
+func (j *Journal) Entries() ([]JournalEntry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.load()
+}
+
+// Rollback undoes the last n turns of session that changed files and
+// returns the restored entries, one per file.
+func (j *Journal) Rollback(session string, n int) ([]JournalEntry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entries, err := j.load()
+	if err != nil {
+		return nil, err
+	}
+	turns := make(map[int]bool)
+	for i := len(entries) - 1; i >= 0 && len(turns) < n; i-- {
+		if entries[i].Session == session {
+			turns[entries[i].Turn] = true
+		}
+	}
+	restored, err := j.undo(entries, func(e JournalEntry) bool {
+		return e.Session == session && turns[e.Turn]
+	})
+	if err != nil {
+		return nil, err
+	}
+	return restored, nil
+}
+
+// Restore returns the file changed by entry id to the content it had
+// before that change, undoing later changes to the same file as well.
+func (j *Journal) Restore(id int64) (JournalEntry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entries, err := j.load()
+	if err != nil {
+		return JournalEntry{}, err
+	}
+	var path string
+	for _, e := range entries {
+		if e.ID == id {
+			path = e.Path
+		}
+	}
+	if path == "" {
+		return JournalEntry{}, fmt.Errorf("no journal entry %d", id)
+	}
+	restored, err := j.undo(entries, func(e JournalEntry) bool {
+		return e.Path == path && e.ID >= id
+	})
+	if err != nil {
+		return JournalEntry{}, err
+	}
+	return restored[0], nil
+}
+
+// undo restores every file touched by the selected entries to its state
+// before the earliest of them, then drops the selected entries.
+func (j *Journal) undo(entries []JournalEntry, selected func(JournalEntry) bool) ([]JournalEntry, error) {
+	first := make(map[string]JournalEntry)
+	var keep, drop []JournalEntry
+	for _, e := range entries {
+		if !selected(e) {
+			keep = append(keep, e)
+			continue
+		}
+		drop = append(drop, e)
+		if _, ok := first[e.Path]; !ok {
+			first[e.Path] = e
+		}
+	}
+	if len(drop) == 0 {
+		return nil, errors.New("no changes to roll back")
+	}
+
+	restored := make([]JournalEntry, 0, len(first))
+	for _, e := range first {
+		if err := j.restoreFile(e); err != nil {
+			return nil, fmt.Errorf("restoring %s: %w", e.Path, err)
+		}
+		restored = append(restored, e)
+	}
+	sort.Slice(restored, func(a, b int) bool { return restored[a].ID < restored[b].ID })
+
+	for _, e := range drop {
+		os.Remove(j.snapshotPath(e.ID))
+	}
+	return restored, j.save(keep)
+}
+
+func (j *Journal) restoreFile(e JournalEntry) error {
+	if e.Omitted {
+		// Nothing was kept; the file is left as it is.
+		return nil
+	}
+	// The index lives in the workspace, so its paths are checked like any
+	// other path the file tools are given.
+	path, err := validatePath(e.Path, j.workspace, j.restrict)
+	if err != nil {
+		return err
+	}
+	if err := checkJournalWrite(path, j.workspace); err != nil {
+		return err
+	}
+
+	if !e.Existed {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	data, err := os.ReadFile(j.snapshotPath(e.ID))
+	if err != nil {
+		return fmt.Errorf("reading snapshot: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	mode := e.Mode.Perm()
+	if mode == 0 {
+		mode = 0o600 // recorded before modes were kept
+	}
+	return fileutil.WriteFileAtomic(path, data, mode)
+}
+
+// prune drops the oldest entries until the journal is within its limits.
+func (j *Journal) prune(entries []JournalEntry) []JournalEntry {
+	var total int64
+	for _, e := range entries {
+		if !e.Omitted {
+			total += e.Size
+		}
+	}
+	for len(entries) > 1 && (len(entries) > j.maxEntries || total > j.maxBytes) {
+		if !entries[0].Omitted {
+			total -= entries[0].Size
+		}
+		os.Remove(j.snapshotPath(entries[0].ID))
+		entries = entries[1:]
+	}
+	return entries
+}
+
+func (j *Journal) snapshotPath(id int64) string {
+	return filepath.Join(j.dir, fmt.Sprintf("%d.snap", id))
+}
+
+func (j *Journal) load() ([]JournalEntry, error) {
+	data, err := os.ReadFile(filepath.Join(j.dir, journalIndex))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading journal: %w", err)
+	}
+	var entries []JournalEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("parsing journal: %w", err)
+	}
+	return entries, nil
+}
+
+func (j *Journal) save(entries []JournalEntry) error {
+	if entries == nil {
+		entries = []JournalEntry{}
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(j.dir, 0o755); err != nil {
+		return fmt.Errorf("creating journal directory: %w", err)
+	}
+	return fileutil.WriteFileAtomic(filepath.Join(j.dir, journalIndex), data, 0o600)
+}
+
+// checkJournalWrite refuses changes to files under the journal directory of
+// workspace, which would let a tool rewrite what a restore writes where.
+func checkJournalWrite(path, workspace string) error {
+	absWorkspace, err := filepath.Abs(workspace)
+	if err != nil {
+		return nil
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(absWorkspace, path)
+	}
+	if isWithinWorkspace(path, filepath.Join(absWorkspace, JournalDir)) {
+		return fmt.Errorf("access denied: %s is reserved for the file change journal", JournalDir)
+	}
+	return nil
+}
+
+// journalFs is a fileSystem that records the prior content of files in a
+// Journal before changing them.
+type journalFs struct {
+	fileSystem
+	ctx       context.Context
+	journal   *Journal
+	workspace string
+	tool      string
+}
+
+// withJournal wraps fsys so that changes made through it by tool are
+// journaled in the turn of ctx. It returns fsys itself when j is nil.
+func withJournal(ctx context.Context, fsys fileSystem, j *Journal, workspace, tool string) fileSystem {
+	if j == nil {
+		return fsys
+	}
+	return &journalFs{fileSystem: fsys, ctx: ctx, journal: j, workspace: workspace, tool: tool}
+}
+
+func (f *journalFs) WriteFile(path string, data []byte) error {
+	journalChange(f.ctx, f.journal, f.fileSystem, f.workspace, f.tool, path)
+	return f.fileSystem.WriteFile(path, data)
+}
+
+func (f *journalFs) Remove(path string) error {
+	journalChange(f.ctx, f.journal, f.fileSystem, f.workspace, f.tool, path)
+	return f.fileSystem.Remove(path)
+}
+
+// journalChange records the current content of path in j before a tool
+// changes it. Failures are logged rather than blocking the change.
+func journalChange(ctx context.Context, j *Journal, fsys fileSystem, workspace, tool, path string) {
+	if j == nil {
+		return
+	}
+	prior, size, err := readJournalSnapshot(fsys, path, j.maxFileBytes)
+	existed := err == nil
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return // the change itself will fail
+	}
+	if _, ok := fsys.(*sandboxFs); ok && !filepath.IsAbs(path) {
+		path = filepath.Join(workspace, path)
+	}
+	journalRecord(ctx, j, tool, path, prior, size, existed)
+}
+
+// readJournalSnapshot returns the content and size of path. Files larger
+// than limit are only measured, so they are never read into memory whole.
+func readJournalSnapshot(fsys fileSystem, path string, limit int64) ([]byte, int64, error) {
+	f, err := fsys.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+	if info.IsDir() {
+		return nil, 0, fmt.Errorf("%s is a directory", path)
+	}
+	if info.Size() > limit {
+		return nil, info.Size(), nil
+	}
+	// The file may grow between Stat and reading it.
+	prior, err := io.ReadAll(io.LimitReader(f, limit+1))
+	if err != nil {
+		return nil, 0, err
+	}
+	if int64(len(prior)) > limit {
+		return nil, int64(len(prior)), nil
+	}
+	return prior, int64(len(prior)), nil
+}
+
+// journalRecord records a snapshot of size bytes whose content the caller
+// already holds; prior is nil when size is over the journal's file limit.
+func journalRecord(ctx context.Context, j *Journal, tool, path string, prior []byte, size int64, existed bool) {
+	if j == nil {
+		return
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	if err := j.record(ctx, tool, path, prior, size, existed); err != nil {
+		logger.WarnCF("tool", "Failed to journal file change",
+			map[string]any{
+				"tool":  tool,
+				"path":  path,
+				"error": err.Error(),
+			})
+	}
+}
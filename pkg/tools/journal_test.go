@@ -0,0 +1,203 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestJournal_RollbackTurns(t *testing.T) {
+	workspace := t.TempDir()
+	writeTree(t, workspace, map[string]string{"a.txt": "a1\n"})
+	j := NewJournal(workspace, true, 0, 0, 0)
+	write := NewWriteFileTool(workspace, true)
+	edit := NewEditFileTool(workspace, true)
+	write.SetJournal(j)
+	edit.SetJournal(j)
+	ctx := context.Background()
+
+	turn := j.BeginTurn(ctx, "s1")
+	write.Execute(turn, map[string]any{"path": "a.txt", "content": "a2\n"})
+	write.Execute(turn, map[string]any{"path": "new.txt", "content": "n\n"})
+	// A turn of another session runs while the second turn of s1 does.
+	turn = j.BeginTurn(ctx, "s1")
+	other := j.BeginTurn(ctx, "s2")
+	edit.Execute(turn, map[string]any{"path": "a.txt", "old_text": "a2", "new_text": "a3"})
+	write.Execute(other, map[string]any{"path": "other.txt", "content": "o\n"})
+	edit.Execute(turn, map[string]any{"path": "a.txt", "old_text": "a3", "new_text": "a4"})
+
+	entries, err := j.Entries()
+	if err != nil || len(entries) != 5 {
+		t.Fatalf("Entries() = %v, %v", entries, err)
+	}
+	if entries[2].Turn != 2 || entries[3].Session != "s2" || entries[3].Turn != 1 ||
+		entries[4].Session != "s1" || entries[4].Turn != 2 {
+		t.Errorf("unexpected keys: %+v", entries)
+	}
+	if entries[1].Existed || entries[1].Path != filepath.Join(workspace, "new.txt") {
+		t.Errorf("creation entry = %+v", entries[1])
+	}
+
+	restored, err := j.Rollback("s1", 1)
+	if err != nil || len(restored) != 1 {
+		t.Fatalf("Rollback(s1, 1) = %v, %v", restored, err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(workspace, "a.txt")); string(got) != "a2\n" {
+		t.Errorf("a.txt after one turn = %q", got)
+	}
+
+	if _, err := j.Rollback("s1", 1); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(workspace, "a.txt")); string(got) != "a1\n" {
+		t.Errorf("a.txt after two turns = %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(workspace, "new.txt")); !os.IsNotExist(err) {
+		t.Error("new.txt should have been removed")
+	}
+	if _, err := os.Stat(filepath.Join(workspace, "other.txt")); err != nil {
+		t.Error("other sessions must not be rolled back")
+	}
+	if _, err := j.Rollback("s1", 1); err == nil {
+		t.Error("expected an error with nothing left to roll back")
+	}
+}
+
+func TestJournal_Restore(t *testing.T) {
+	workspace := t.TempDir()
+	writeTree(t, workspace, map[string]string{"a.txt": "v1", "b.txt": "b1"})
+	j := NewJournal(workspace, true, 0, 0, 0)
+	write := NewWriteFileTool(workspace, true)
+	write.SetJournal(j)
+	ctx := j.BeginTurn(context.Background(), "s")
+
+	write.Execute(ctx, map[string]any{"path": "a.txt", "content": "v2"})
+	write.Execute(ctx, map[string]any{"path": "b.txt", "content": "b2"})
+	write.Execute(ctx, map[string]any{"path": "a.txt", "content": "v3"})
+
+	e, err := j.Restore(1)
+	if err != nil || e.ID != 1 {
+		t.Fatalf("Restore(1) = %+v, %v", e, err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(workspace, "a.txt")); string(got) != "v1" {
+		t.Errorf("a.txt = %q", got)
+	}
+	if got, _ := os.ReadFile(filepath.Join(workspace, "b.txt")); string(got) != "b2" {
+		t.Errorf("b.txt should be untouched, got %q", got)
+	}
+	if entries, _ := j.Entries(); len(entries) != 1 || entries[0].ID != 2 {
+		t.Errorf("remaining entries = %+v", entries)
+	}
+	if _, err := j.Restore(1); err == nil {
+		t.Error("restoring a dropped entry should fail")
+	}
+}
+
+func TestJournal_RestoreChecks(t *testing.T) {
+	workspace := t.TempDir()
+	writeTree(t, workspace, map[string]string{"run.sh": "echo hi\n", "big.txt": "too large"})
+	os.Chmod(filepath.Join(workspace, "run.sh"), 0o750)
+	j := NewJournal(workspace, true, 0, 0, 8)
+	write := NewWriteFileTool(workspace, true)
+	write.SetJournal(j)
+	ctx := j.BeginTurn(context.Background(), "s")
+
+	write.Execute(ctx, map[string]any{"path": "run.sh", "content": "rm -rf /\n"})
+	write.Execute(ctx, map[string]any{"path": "big.txt", "content": "small"})
+	if _, err := j.Restore(1); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(filepath.Join(workspace, "run.sh"))
+	if err != nil || (runtime.GOOS != "windows" && info.Mode().Perm() != 0o750) {
+		t.Errorf("run.sh mode after restore = %v, %v, want 0750", info.Mode(), err)
+	}
+	entries, _ := j.Entries()
+	if len(entries) != 1 || !entries[0].Omitted || entries[0].Size != 9 {
+		t.Errorf("large file entry = %+v, want omitted with its size", entries)
+	}
+
+	// The journal itself cannot be changed through the file tools.
+	r := write.Execute(ctx, map[string]any{"path": filepath.Join(JournalDir, "index.json"), "content": "[]"})
+	if !r.IsError || !strings.Contains(r.ForLLM, "reserved") {
+		t.Errorf("writing the journal index = %+v, want refused", r)
+	}
+
+	// Paths in the index are checked again on restore.
+	outside := filepath.Join(t.TempDir(), "outside.txt")
+	os.WriteFile(outside, []byte("keep"), 0o644)
+	if err := j.Record(ctx, "write_file", outside, []byte("evil"), true); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ = j.Entries()
+	if _, err := j.Restore(entries[len(entries)-1].ID); err == nil {
+		t.Error("restoring a path outside the workspace should fail")
+	}
+	if got, _ := os.ReadFile(outside); string(got) != "keep" {
+		t.Errorf("outside.txt = %q, want it untouched", got)
+	}
+}
+
+func TestJournal_Limits(t *testing.T) {
+	workspace := t.TempDir()
+	j := NewJournal(workspace, true, 3, 10, 4)
+	ctx := j.BeginTurn(context.Background(), "s")
+	for i := range 5 {
+		if err := j.Record(ctx, "write_file", filepath.Join(workspace, "f.txt"), []byte("abc"), true); err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			if _, err := os.Stat(filepath.Join(workspace, JournalDir, "1.snap")); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	entries, _ := j.Entries()
+	if len(entries) != 3 || entries[0].ID != 3 {
+		t.Errorf("entries after pruning = %+v", entries)
+	}
+	if _, err := os.Stat(filepath.Join(workspace, JournalDir, "1.snap")); !os.IsNotExist(err) {
+		t.Error("pruned snapshot should be removed")
+	}
+
+	if err := j.Record(ctx, "write_file", filepath.Join(workspace, "big.txt"), []byte("too large"), true); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ = j.Entries()
+	if last := entries[len(entries)-1]; !last.Omitted {
+		t.Errorf("large file should be omitted: %+v", last)
+	}
+}
+
+func TestApplyPatchTool_Journal(t *testing.T) {
+	workspace := t.TempDir()
+	writeTree(t, workspace, map[string]string{"a.txt": "one\n", "b.txt": "two\n"})
+	j := NewJournal(workspace, true, 0, 0, 0)
+	tool := NewApplyPatchTool(workspace, true)
+	tool.SetJournal(j)
+
+	ctx := j.BeginTurn(context.Background(), "s")
+	patch := "--- a/a.txt\n+++ b/a.txt\n@@ -1 +1 @@\n-one\n+ONE\n" +
+		"--- a/b.txt\n+++ /dev/null\n@@ -1 +0,0 @@\n-two\n" +
+		"--- /dev/null\n+++ b/c.txt\n@@ -0,0 +1 @@\n+three\n"
+	if r := tool.Execute(ctx, map[string]any{"patch": patch}); r.IsError {
+		t.Fatal(r.ForLLM)
+	}
+	restored, err := j.Rollback("s", 1)
+	if err != nil || len(restored) != 3 {
+		t.Fatalf("Rollback = %v, %v", restored, err)
+	}
+	for name, want := range map[string]string{"a.txt": "one\n", "b.txt": "two\n"} {
+		if got, _ := os.ReadFile(filepath.Join(workspace, name)); string(got) != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(workspace, "c.txt")); !os.IsNotExist(err) {
+		t.Error("c.txt should have been removed")
+	}
+	if !strings.HasSuffix(restored[0].Path, "a.txt") {
+		t.Errorf("restored = %+v", restored)
+	}
+}
@@ -0,0 +1,685 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxContextFuzz is how many leading and trailing context lines of a hunk
+// may be ignored when it does not apply as given, like patch's fuzz factor.
+const maxContextFuzz = 2
+
+var hunkHeaderRe = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// filePatch is the part of a unified diff that changes one file. An empty
+// oldPath creates the file, an empty newPath deletes it.
+type filePatch struct {
+	oldPath string
+	newPath string
+	hunks   []hunk
+}
+
+type hunk struct {
+	header    string
+	oldStart  int // 1-based; 0 when the header carries no line numbers
+	lines     []hunkLine
+	noEOL     bool // the new side ends without a newline
+	hasOldEOL bool // an old-side "\ No newline" marker was seen
+}
+
+type hunkLine struct {
+	op   byte // ' ', '-' or '+'
+	text string
+}
+
+// oldLines returns the lines the hunk expects in the file.
+func (h *hunk) oldLines() []string {
+	var out []string
+	for _, l := range h.lines {
+		if l.op != '+' {
+			out = append(out, l.text)
+		}
+	}
+	return out
+}
+
+// parseUnifiedDiff parses a unified diff as produced by diff -u or git diff.
+// Hunk line counts are not trusted: a hunk runs until the next hunk or file
+// header, since hand-written diffs often get them wrong.
+func parseUnifiedDiff(patch string) ([]filePatch, error) {
+	lines := strings.Split(strings.ReplaceAll(patch, "\r\n", "\n"), "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	var files []filePatch
+	var cur *filePatch
+	var h *hunk
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			files = append(files, filePatch{
+				oldPath: diffPath(line[4:]),
+				newPath: diffPath(lines[i+1][4:]),
+			})
+			cur, h = &files[len(files)-1], nil
+			i++
+		case strings.HasPrefix(line, "@@"):
+			if cur == nil {
+				return nil, fmt.Errorf("line %d: hunk before any --- / +++ file header", i+1)
+			}
+			cur.hunks = append(cur.hunks, hunk{header: line})
+			h = &cur.hunks[len(cur.hunks)-1]
+			if m := hunkHeaderRe.FindStringSubmatch(line); m != nil {
+				h.oldStart, _ = strconv.Atoi(m[1])
+			}
+		case h == nil:
+			// Preamble such as "diff --git" or "index" lines.
+		case strings.HasPrefix(line, `\`):
+			if n := len(h.lines); n > 0 && h.lines[n-1].op == '-' {
+				h.hasOldEOL = true
+			} else {
+				h.noEOL = true
+			}
+		case line == "":
+			// Editors and models often strip the space of empty context lines.
+			h.lines = append(h.lines, hunkLine{op: ' '})
+		case line[0] == ' ' || line[0] == '-' || line[0] == '+':
+			h.lines = append(h.lines, hunkLine{op: line[0], text: line[1:]})
+		case strings.HasPrefix(line, "diff "):
+			h = nil
+		default:
+			return nil, fmt.Errorf("line %d: unexpected line in hunk: %q", i+1, line)
+		}
+	}
+	if len(files) == 0 {
+		return nil, errors.New("no file headers (--- / +++) found in patch")
+	}
+
+	for i := range files {
+		f := &files[i]
+		stripGitPrefixes(f)
+		if f.oldPath == "" && f.newPath == "" {
+			return nil, errors.New("file header with /dev/null on both sides")
+		}
+		if len(f.hunks) == 0 && f.newPath != "" {
+			return nil, fmt.Errorf("no hunks for %s", f.newPath)
+		}
+	}
+	return files, nil
+}
+
+// diffPath extracts the path of a ---/+++ header, dropping a timestamp.
+func diffPath(s string) string {
+	if i := strings.IndexByte(s, '\t'); i >= 0 {
+		s = s[:i]
+	}
+	s = strings.TrimSpace(s)
+	if s == "/dev/null" {
+		return ""
+	}
+	return s
+}
+
+// stripGitPrefixes removes the a/ and b/ prefixes git puts on paths.
+func stripGitPrefixes(f *filePatch) {
+	oldOK := f.oldPath == "" || strings.HasPrefix(f.oldPath, "a/")
+	newOK := f.newPath == "" || strings.HasPrefix(f.newPath, "b/")
+	if !oldOK || !newOK {
+		return
+	}
+	f.oldPath = strings.TrimPrefix(f.oldPath, "a/")
+	f.newPath = strings.TrimPrefix(f.newPath, "b/")
+}
+
+// normalizeSpace collapses runs of whitespace so lines compare equal despite
+// indentation or trailing whitespace drift.
+func normalizeSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// hunkMatch is where a hunk applies: its lines[lead:len-trail] replace the
+// file lines starting at pos.
+type hunkMatch struct {
+	pos         int
+	lead, trail int
+	fuzzy       bool
+}
+
+// findHunk locates the old side of h in lines at or after minPos, trying an
+// exact match, then a whitespace-insensitive one, then ignoring up to
+// maxContextFuzz context lines at either end. Among candidates the one
+// closest to expected wins.
+func findHunk(lines []string, h *hunk, expected, minPos int) (hunkMatch, bool) {
+	for fuzz := 0; fuzz <= maxContextFuzz; fuzz++ {
+		lead := min(fuzz, leadingContext(h.lines))
+		trail := min(fuzz, trailingContext(h.lines[lead:]))
+		if fuzz > 0 && lead+trail == 0 {
+			break
+		}
+		sub := &hunk{lines: h.lines[lead : len(h.lines)-trail]}
+		old := sub.oldLines()
+		for _, fuzzy := range []bool{false, true} {
+			if pos, ok := findLines(lines, old, expected+lead, minPos, fuzzy); ok {
+				return hunkMatch{pos: pos, lead: lead, trail: trail, fuzzy: fuzzy}, true
+			}
+		}
+	}
+	return hunkMatch{}, false
+}
+
+func leadingContext(lines []hunkLine) int {
+	n := 0
+	for n < len(lines) && lines[n].op == ' ' {
+		n++
+	}
+	return n
+}
+
+func trailingContext(lines []hunkLine) int {
+	n := 0
+	for n < len(lines) && lines[len(lines)-1-n].op == ' ' {
+		n++
+	}
+	return n
+}
+
+// findLines returns the start of the block old in lines nearest to
+// expected, searching from minPos.
+func findLines(lines, old []string, expected, minPos int, fuzzy bool) (int, bool) {
+	if len(old) == 0 {
+		return max(minPos, min(expected, len(lines))), true
+	}
+	best, found := 0, false
+	for pos := minPos; pos+len(old) <= len(lines); pos++ {
+		if !blockEqual(lines[pos:pos+len(old)], old, fuzzy) {
+			continue
+		}
+		if !found || abs(pos-expected) < abs(best-expected) {
+			best, found = pos, true
+		}
+	}
+	return best, found
+}
+
+func blockEqual(a, b []string, fuzzy bool) bool {
+	for i := range b {
+		if a[i] != b[i] && (!fuzzy || normalizeSpace(a[i]) != normalizeSpace(b[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// closestMatch describes the window of lines most similar to want, for
+// conflict reports. Ties go to the window nearest to expected.
+func closestMatch(lines, want []string, expected int) string {
+	if len(want) == 0 || len(lines) == 0 {
+		return ""
+	}
+	bestPos, bestScore := 0, -1
+	for pos := 0; pos < len(lines); pos++ {
+		score := 0
+		for i := 0; i < len(want) && pos+i < len(lines); i++ {
+			if normalizeSpace(lines[pos+i]) == normalizeSpace(want[i]) {
+				score++
+			}
+		}
+		if score > bestScore || score == bestScore && abs(pos-expected) < abs(bestPos-expected) {
+			bestPos, bestScore = pos, score
+		}
+	}
+	if bestScore == 0 {
+		return "no similar lines found in the file"
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "closest match at line %d (%d of %d lines match):", bestPos+1, bestScore, len(want))
+	for i := bestPos; i < min(bestPos+len(want), len(lines)); i++ {
+		fmt.Fprintf(&sb, "\n%6d | %s", i+1, lines[i])
+	}
+	return sb.String()
+}
+
+// fileLines splits content into lines, remembering the line ending and
+// whether the last line is terminated.
+type fileLines struct {
+	lines []string
+	crlf  bool
+	eol   bool
+}
+
+func splitFileLines(content []byte) fileLines {
+	s := string(content)
+	f := fileLines{crlf: strings.Contains(s, "\r\n"), eol: s == "" || strings.HasSuffix(s, "\n")}
+	if f.crlf {
+		s = strings.ReplaceAll(s, "\r\n", "\n")
+	}
+	if s != "" {
+		f.lines = strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	}
+	return f
+}
+
+func (f fileLines) bytes() []byte {
+	if len(f.lines) == 0 {
+		return nil
+	}
+	sep := "\n"
+	if f.crlf {
+		sep = "\r\n"
+	}
+	s := strings.Join(f.lines, sep)
+	if f.eol {
+		s += sep
+	}
+	return []byte(s)
+}
+
+// applyHunks applies the hunks of one file in order. Context lines keep the
+// file's own text, so whitespace-insensitive matches do not rewrite them.
+// It returns notes on hunks that needed an offset or fuzz.
+func applyHunks(f fileLines, path string, hunks []hunk) (fileLines, []string, error) {
+	var out []string
+	var notes []string
+	cur, delta := 0, 0
+	for i := range hunks {
+		h := &hunks[i]
+		expected := max(h.oldStart-1+delta, 0)
+		if h.oldStart == 0 {
+			expected = cur
+		}
+		m, ok := findHunk(f.lines, h, expected, cur)
+		if !ok {
+			report := fmt.Sprintf("hunk %d of %s does not apply (%s)", i+1, path, h.header)
+			if old := h.oldLines(); len(old) > 0 {
+				report += "; expected:\n" + indentLines(old) + "\n" + closestMatch(f.lines, old, expected)
+			}
+			return f, nil, errors.New(report)
+		}
+		if note := describeMatch(m, expected, i+1); note != "" {
+			notes = append(notes, path+": "+note)
+		}
+
+		out = append(out, f.lines[cur:m.pos]...)
+		j := m.pos
+		added, removed := 0, 0
+		for _, l := range h.lines[m.lead : len(h.lines)-m.trail] {
+			switch l.op {
+			case ' ':
+				out = append(out, f.lines[j])
+				j++
+			case '-':
+				j++
+				removed++
+			case '+':
+				out = append(out, l.text)
+				added++
+			}
+		}
+		cur = j
+		delta += added - removed
+		if h.noEOL {
+			f.eol = false
+		} else if h.hasOldEOL {
+			f.eol = true
+		}
+	}
+	f.lines = append(out, f.lines[cur:]...)
+	return f, notes, nil
+}
+
+func describeMatch(m hunkMatch, expected, n int) string {
+	var parts []string
+	if m.pos != expected && expected > 0 {
+		parts = append(parts, fmt.Sprintf("offset %+d lines", m.pos-expected))
+	}
+	if m.fuzzy {
+		parts = append(parts, "ignoring whitespace")
+	}
+	if m.lead+m.trail > 0 {
+		parts = append(parts, fmt.Sprintf("fuzz %d", max(m.lead, m.trail)))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("hunk %d applied at line %d (%s)", n, m.pos+m.lead+1, strings.Join(parts, ", "))
+}
+
+func indentLines(lines []string) string {
+	var sb strings.Builder
+	for i, l := range lines {
+		if i > 0 {
+			sb.WriteByte('\n')
+		}
+		sb.WriteString("       | " + l)
+	}
+	return sb.String()
+}
+
+// replaceFuzzy replaces the single occurrence of oldText in content. When
+// there is no exact match it compares line by line ignoring whitespace.
+func replaceFuzzy(content []byte, oldText, newText string) ([]byte, bool, error) {
+	if updated, err := replaceEditContent(content, oldText, newText); err == nil {
+		return updated, false, nil
+	} else if strings.Contains(string(content), oldText) {
+		return nil, false, err // ambiguous, not missing
+	}
+
+	f := splitFileLines(content)
+	old := splitFileLines([]byte(oldText)).lines
+	var matches []int
+	for pos := 0; len(old) > 0 && pos+len(old) <= len(f.lines); pos++ {
+		if blockEqual(f.lines[pos:pos+len(old)], old, true) {
+			matches = append(matches, pos)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		msg := "old_text not found, even ignoring whitespace"
+		if report := closestMatch(f.lines, old, 0); report != "" {
+			msg += "; " + report
+		}
+		return nil, false, errors.New(msg)
+	case 1:
+	default:
+		return nil, false, fmt.Errorf("old_text matches %d places when ignoring whitespace; add more context", len(matches))
+	}
+
+	pos := matches[0]
+	newLines := splitFileLines([]byte(newText)).lines
+	f.lines = append(f.lines[:pos], append(newLines, f.lines[pos+len(old):]...)...)
+	return f.bytes(), true, nil
+}
+
+// ApplyPatchTool applies a unified diff or a list of edits to one or more
+// files. Every change is checked before anything is written, so a patch
+// either applies completely or not at all.
+type ApplyPatchTool struct {
+	fs        fileSystem
+	workspace string
+	permStore *PermissionStore
+	permFn    PermissionFunc
+	journal   *Journal
+}
+
+func NewApplyPatchTool(workspace string, restrict bool) *ApplyPatchTool {
+	var fs fileSystem
+	if restrict {
+		fs = &sandboxFs{workspace: workspace}
+	} else {
+		fs = &hostFs{}
+	}
+	return &ApplyPatchTool{fs: fs, workspace: workspace}
+}
+
+func (t *ApplyPatchTool) SetPermission(store *PermissionStore, fn PermissionFunc) {
+	t.permStore = store
+	t.permFn = fn
+}
+
+func (t *ApplyPatchTool) SetJournal(j *Journal) {
+	t.journal = j
+}
+
+func (t *ApplyPatchTool) Name() string {
+	return "apply_patch"
+}
+
+func (t *ApplyPatchTool) Description() string {
+	return "Change one or more files in a single step, either with a unified diff (patch) or a list of " +
+		"old_text/new_text replacements (edits). All changes are applied together or not at all. Hunks " +
+		"tolerate shifted line numbers and whitespace differences; on conflict the closest match is reported."
+}
+
+func (t *ApplyPatchTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"patch": map[string]any{
+				"type": "string",
+				"description": "Unified diff with ---/+++ file headers and @@ hunks. Paths are relative to the " +
+					"workspace; git's a/ and b/ prefixes are accepted. Use /dev/null to create or delete files.",
+			},
+			"edits": map[string]any{
+				"type":        "array",
+				"description": "Replacements applied in order; each old_text must match exactly one place",
+				"items": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"path":     map[string]any{"type": "string"},
+						"old_text": map[string]any{"type": "string"},
+						"new_text": map[string]any{"type": "string"},
+					},
+					"required": []string{"path", "old_text", "new_text"},
+				},
+			},
+		},
+	}
+}
+
+// pendingFile is the planned new state of one file.
+type pendingFile struct {
+	path     string
+	original []byte
+	existed  bool
+	content  []byte
+	remove   bool
+}
+
+func (t *ApplyPatchTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	patch, _ := args["patch"].(string)
+	rawEdits, _ := args["edits"].([]any)
+	if (patch == "") == (len(rawEdits) == 0) {
+		return ErrorResult("provide either patch or edits")
+	}
+
+	p := &patchPlan{tool: t, ctx: ctx, files: make(map[string]*pendingFile)}
+	var err error
+	if patch != "" {
+		err = p.planDiff(patch)
+	} else {
+		err = p.planEdits(rawEdits)
+	}
+	if err != nil {
+		return ErrorResult("patch not applied: " + err.Error())
+	}
+
+	if err := p.commit(); err != nil {
+		return ErrorResult("patch not applied: " + err.Error()).WithError(err)
+	}
+	return SilentResult(p.summary())
+}
+
+// patchPlan collects the changes of one call before any is written.
+type patchPlan struct {
+	tool    *ApplyPatchTool
+	ctx     context.Context
+	files   map[string]*pendingFile
+	order   []string
+	notes   []string
+	changes []string
+}
+
+// resolve maps a patch path to the path handed to the file system.
+func (p *patchPlan) resolve(path string) string {
+	if !filepath.IsAbs(path) && p.tool.workspace != "" {
+		return filepath.Join(p.tool.workspace, path)
+	}
+	return path
+}
+
+// load returns the pending state of path, reading it on first use.
+func (p *patchPlan) load(path string) (*pendingFile, error) {
+	resolved := p.resolve(path)
+	if f, ok := p.files[resolved]; ok {
+		return f, nil
+	}
+	if err := checkFilePermission(p.ctx, resolved, p.tool.workspace, p.tool.permStore, p.tool.permFn); err != nil {
+		return nil, err
+	}
+	if err := checkJournalWrite(resolved, p.tool.workspace); err != nil {
+		return nil, err
+	}
+	content, err := p.tool.fs.ReadFile(resolved)
+	existed := true
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		existed = false
+	}
+	f := &pendingFile{path: resolved, original: content, existed: existed, content: content}
+	p.files[resolved] = f
+	p.order = append(p.order, resolved)
+	return f, nil
+}
+
+func (p *patchPlan) planDiff(patch string) error {
+	filePatches, err := parseUnifiedDiff(patch)
+	if err != nil {
+		return err
+	}
+	for _, fp := range filePatches {
+		switch {
+		case fp.oldPath == "":
+			if err := p.planCreate(fp); err != nil {
+				return err
+			}
+		case fp.newPath == "":
+			f, err := p.load(fp.oldPath)
+			if err != nil {
+				return err
+			}
+			if !f.existed || f.remove {
+				return fmt.Errorf("cannot delete %s: file not found", fp.oldPath)
+			}
+			f.remove = true
+			p.changes = append(p.changes, "deleted "+fp.oldPath)
+		default:
+			if fp.oldPath != fp.newPath {
+				return fmt.Errorf("renames are not supported (%s -> %s)", fp.oldPath, fp.newPath)
+			}
+			f, err := p.load(fp.newPath)
+			if err != nil {
+				return err
+			}
+			if !f.existed || f.remove {
+				return fmt.Errorf("cannot patch %s: file not found", fp.newPath)
+			}
+			updated, notes, err := applyHunks(splitFileLines(f.content), fp.newPath, fp.hunks)
+			if err != nil {
+				return err
+			}
+			f.content = updated.bytes()
+			p.notes = append(p.notes, notes...)
+			p.changes = append(p.changes, fmt.Sprintf("patched %s (%d hunks)", fp.newPath, len(fp.hunks)))
+		}
+	}
+	return nil
+}
+
+func (p *patchPlan) planCreate(fp filePatch) error {
+	f, err := p.load(fp.newPath)
+	if err != nil {
+		return err
+	}
+	if f.existed && !f.remove {
+		return fmt.Errorf("cannot create %s: file already exists", fp.newPath)
+	}
+	created, _, err := applyHunks(fileLines{eol: true}, fp.newPath, fp.hunks)
+	if err != nil {
+		return err
+	}
+	f.content, f.remove = created.bytes(), false
+	p.changes = append(p.changes, "created "+fp.newPath)
+	return nil
+}
+
+func (p *patchPlan) planEdits(rawEdits []any) error {
+	for i, raw := range rawEdits {
+		edit, ok := raw.(map[string]any)
+		if !ok {
+			return fmt.Errorf("edit %d is not an object", i+1)
+		}
+		path, _ := edit["path"].(string)
+		oldText, okOld := edit["old_text"].(string)
+		newText, okNew := edit["new_text"].(string)
+		if path == "" || !okOld || !okNew || oldText == "" {
+			return fmt.Errorf("edit %d needs path, old_text and new_text", i+1)
+		}
+		f, err := p.load(path)
+		if err != nil {
+			return fmt.Errorf("edit %d: %w", i+1, err)
+		}
+		if !f.existed {
+			return fmt.Errorf("edit %d: %s not found", i+1, path)
+		}
+		updated, fuzzy, err := replaceFuzzy(f.content, oldText, newText)
+		if err != nil {
+			return fmt.Errorf("edit %d (%s): %w", i+1, path, err)
+		}
+		f.content = updated
+		if fuzzy {
+			p.notes = append(p.notes, fmt.Sprintf("%s: edit %d matched ignoring whitespace", path, i+1))
+		}
+		p.changes = append(p.changes, fmt.Sprintf("edited %s", path))
+	}
+	return nil
+}
+
+// commit writes every planned change. If a write fails, the files already
+// written are restored. The changes are journaled only once all of them
+// are written, so a rolled-back patch leaves nothing for /rollback to undo.
+func (p *patchPlan) commit() error {
+	var done []*pendingFile
+	for _, path := range p.order {
+		f := p.files[path]
+		var err error
+		switch {
+		case f.remove && f.existed:
+			err = p.tool.fs.Remove(f.path)
+		case f.remove:
+			continue
+		default:
+			err = p.tool.fs.WriteFile(f.path, f.content)
+		}
+		if err != nil {
+			for _, w := range done {
+				if w.existed {
+					p.tool.fs.WriteFile(w.path, w.original)
+				} else {
+					p.tool.fs.Remove(w.path)
+				}
+			}
+			return fmt.Errorf("writing %s: %w", f.path, err)
+		}
+		done = append(done, f)
+	}
+	for _, f := range done {
+		journalRecord(p.ctx, p.tool.journal, p.tool.Name(), f.path, f.original, int64(len(f.original)), f.existed)
+	}
+	return nil
+}
+
+func (p *patchPlan) summary() string {
+	var sb strings.Builder
+	sb.WriteString("Patch applied: " + strings.Join(p.changes, ", "))
+	for _, note := range p.notes {
+		sb.WriteString("\n- " + note)
+	}
+	return sb.String()
+}
@@ -0,0 +1,283 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const patchSource = `package main
+
+import "fmt"
+
+func main() {
+	fmt.Println("hello")
+}
+
+func helper() int {
+	return 1
+}
+`
+
+func TestApplyPatchTool_UnifiedDiff(t *testing.T) {
+	workspace := t.TempDir()
+	writeTree(t, workspace, map[string]string{"main.go": patchSource, "old.txt": "bye\n"})
+
+	patch := `diff --git a/main.go b/main.go
+--- a/main.go
++++ b/main.go
+@@ -5,3 +5,3 @@ import "fmt"
+ func main() {
+-	fmt.Println("hello")
++	fmt.Println("hello, world")
+ }
+@@ -9,3 +9,3 @@
+ func helper() int {
+-	return 1
++	return 2
+ }
+--- /dev/null
++++ b/docs/new.md
+@@ -0,0 +1,2 @@
++# New
++file
+--- a/old.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-bye
+`
+	result := NewApplyPatchTool(workspace, true).Execute(context.Background(), map[string]any{"patch": patch})
+	if result.IsError {
+		t.Fatalf("apply_patch failed: %s", result.ForLLM)
+	}
+
+	got, _ := os.ReadFile(filepath.Join(workspace, "main.go"))
+	want := strings.Replace(strings.Replace(patchSource, `"hello"`, `"hello, world"`, 1), "return 1", "return 2", 1)
+	if string(got) != want {
+		t.Errorf("main.go = %q, want %q", got, want)
+	}
+	if got, _ := os.ReadFile(filepath.Join(workspace, "docs/new.md")); string(got) != "# New\nfile\n" {
+		t.Errorf("docs/new.md = %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(workspace, "old.txt")); !os.IsNotExist(err) {
+		t.Error("old.txt should have been deleted")
+	}
+	for _, s := range []string{"patched main.go (2 hunks)", "created docs/new.md", "deleted old.txt"} {
+		if !strings.Contains(result.ForLLM, s) {
+			t.Errorf("summary %q missing %q", result.ForLLM, s)
+		}
+	}
+}
+
+func TestApplyPatchTool_FuzzyHunks(t *testing.T) {
+	workspace := t.TempDir()
+	// Two lines were added at the top and the indentation changed since the
+	// diff was written.
+	drifted := "// header\n// more\n" + strings.ReplaceAll(patchSource, "\t", "    ")
+	writeTree(t, workspace, map[string]string{"main.go": drifted})
+
+	patch := `--- main.go
++++ main.go
+@@ -9,3 +9,3 @@
+ func helper() int {
+-	return 1
++	return 2
+ }
+`
+	result := NewApplyPatchTool(workspace, true).Execute(context.Background(), map[string]any{"patch": patch})
+	if result.IsError {
+		t.Fatalf("apply_patch failed: %s", result.ForLLM)
+	}
+	got, _ := os.ReadFile(filepath.Join(workspace, "main.go"))
+	if !strings.Contains(string(got), "func helper() int {\n\treturn 2\n}") {
+		t.Errorf("main.go = %q", got)
+	}
+	// Context lines keep the file's own indentation.
+	if !strings.Contains(string(got), "func main() {\n    fmt.Println") {
+		t.Errorf("unrelated lines changed: %q", got)
+	}
+	if !strings.Contains(result.ForLLM, "offset +2 lines") || !strings.Contains(result.ForLLM, "ignoring whitespace") {
+		t.Errorf("summary should describe the fuzz: %q", result.ForLLM)
+	}
+}
+
+func TestApplyPatchTool_ContextFuzzAndNoLineNumbers(t *testing.T) {
+	workspace := t.TempDir()
+	writeTree(t, workspace, map[string]string{"main.go": patchSource})
+
+	// The first context line is wrong and the header has no line numbers.
+	patch := `--- a/main.go
++++ b/main.go
+@@
+ func helperr() int {
+-	return 1
++	return 3
+ }
+`
+	result := NewApplyPatchTool(workspace, true).Execute(context.Background(), map[string]any{"patch": patch})
+	if result.IsError {
+		t.Fatalf("apply_patch failed: %s", result.ForLLM)
+	}
+	got, _ := os.ReadFile(filepath.Join(workspace, "main.go"))
+	if !strings.Contains(string(got), "func helper() int {\n\treturn 3\n}") {
+		t.Errorf("main.go = %q", got)
+	}
+}
+
+func TestApplyPatchTool_ConflictIsAtomic(t *testing.T) {
+	workspace := t.TempDir()
+	writeTree(t, workspace, map[string]string{"a.txt": "one\ntwo\nthree\n", "main.go": patchSource})
+
+	patch := `--- a/a.txt
++++ b/a.txt
+@@ -1,3 +1,3 @@
+ one
+-two
++TWO
+ three
+--- a/main.go
++++ b/main.go
+@@ -9,3 +9,3 @@
+ func helper() string {
+-	return "x"
++	return "y"
+ }
+`
+	result := NewApplyPatchTool(workspace, true).Execute(context.Background(), map[string]any{"patch": patch})
+	if !result.IsError {
+		t.Fatal("expected a conflict")
+	}
+	for _, s := range []string{"hunk 1 of main.go does not apply", `return "x"`, "closest match at line 9"} {
+		if !strings.Contains(result.ForLLM, s) {
+			t.Errorf("conflict report %q missing %q", result.ForLLM, s)
+		}
+	}
+	if got, _ := os.ReadFile(filepath.Join(workspace, "a.txt")); string(got) != "one\ntwo\nthree\n" {
+		t.Errorf("a.txt was modified despite the conflict: %q", got)
+	}
+}
+
+func TestApplyPatchTool_Edits(t *testing.T) {
+	workspace := t.TempDir()
+	writeTree(t, workspace, map[string]string{"main.go": patchSource, "b.txt": "alpha\nbeta\n"})
+	tool := NewApplyPatchTool(workspace, true)
+
+	result := tool.Execute(context.Background(), map[string]any{"edits": []any{
+		map[string]any{"path": "main.go", "old_text": `fmt.Println("hello")`, "new_text": `fmt.Println("hi")`},
+		// Whitespace differs from the file.
+		map[string]any{
+			"path":     "main.go",
+			"old_text": "func helper() int {\n  return 1\n}",
+			"new_text": "func helper() int {\n\treturn 5\n}",
+		},
+		map[string]any{"path": "b.txt", "old_text": "beta", "new_text": "gamma"},
+	}})
+	if result.IsError {
+		t.Fatalf("edits failed: %s", result.ForLLM)
+	}
+	got, _ := os.ReadFile(filepath.Join(workspace, "main.go"))
+	if !strings.Contains(string(got), `fmt.Println("hi")`) || !strings.Contains(string(got), "\treturn 5\n") {
+		t.Errorf("main.go = %q", got)
+	}
+	if got, _ := os.ReadFile(filepath.Join(workspace, "b.txt")); string(got) != "alpha\ngamma\n" {
+		t.Errorf("b.txt = %q", got)
+	}
+	if !strings.Contains(result.ForLLM, "edit 2 matched ignoring whitespace") {
+		t.Errorf("summary = %q", result.ForLLM)
+	}
+
+	// A failing edit leaves every file untouched.
+	result = tool.Execute(context.Background(), map[string]any{"edits": []any{
+		map[string]any{"path": "b.txt", "old_text": "alpha", "new_text": "ALPHA"},
+		map[string]any{"path": "main.go", "old_text": "does not exist", "new_text": "x"},
+	}})
+	if !result.IsError || !strings.Contains(result.ForLLM, "edit 2 (main.go)") {
+		t.Errorf("expected edit 2 to fail, got %q", result.ForLLM)
+	}
+	if got, _ := os.ReadFile(filepath.Join(workspace, "b.txt")); string(got) != "alpha\ngamma\n" {
+		t.Errorf("b.txt was modified despite the failure: %q", got)
+	}
+}
+
+func TestApplyPatchTool_RestrictToWorkspace(t *testing.T) {
+	base := t.TempDir()
+	workspace := filepath.Join(base, "ws")
+	writeTree(t, base, map[string]string{"ws/a.txt": "a\n", "secret.txt": "s\n"})
+	tool := NewApplyPatchTool(workspace, true)
+
+	patch := "--- ../secret.txt\n+++ ../secret.txt\n@@ -1 +1 @@\n-s\n+pwned\n"
+	if r := tool.Execute(context.Background(), map[string]any{"patch": patch}); !r.IsError {
+		t.Errorf("patching outside the workspace should fail: %s", r.ForLLM)
+	}
+	r := tool.Execute(context.Background(), map[string]any{"edits": []any{
+		map[string]any{"path": filepath.Join(base, "secret.txt"), "old_text": "s", "new_text": "pwned"},
+	}})
+	if !r.IsError {
+		t.Errorf("editing outside the workspace should fail: %s", r.ForLLM)
+	}
+	if got, _ := os.ReadFile(filepath.Join(base, "secret.txt")); string(got) != "s\n" {
+		t.Errorf("secret.txt = %q", got)
+	}
+}
+
+func TestApplyPatchTool_InvalidInput(t *testing.T) {
+	tool := NewApplyPatchTool(t.TempDir(), true)
+	for _, args := range []map[string]any{
+		{},
+		{"patch": "not a diff"},
+		{"patch": "--- a/x\n+++ b/x\n@@ -1 +1 @@\n?garbage\n"},
+		{"patch": "--- a/x\n+++ b/y\n@@ -1 +1 @@\n-a\n+b\n"},
+		{"edits": []any{map[string]any{"path": "x"}}},
+	} {
+		if r := tool.Execute(context.Background(), args); !r.IsError {
+			t.Errorf("Execute(%v) should fail", args)
+		}
+	}
+}
+
+func TestSplitFileLines_RoundTrip(t *testing.T) {
+	for _, s := range []string{"", "\n", "a", "a\n", "a\nb", "a\r\nb\r\n", "\n\n"} {
+		if got := string(splitFileLines([]byte(s)).bytes()); got != s {
+			t.Errorf("round trip of %q = %q", s, got)
+		}
+	}
+}
+
+// failingWriteFs fails every write to one file.
+type failingWriteFs struct {
+	fileSystem
+	fail string
+}
+
+func (fs *failingWriteFs) WriteFile(path string, data []byte) error {
+	if filepath.Base(path) == fs.fail {
+		return os.ErrPermission
+	}
+	return fs.fileSystem.WriteFile(path, data)
+}
+
+func TestApplyPatchTool_FailedCommitIsNotJournaled(t *testing.T) {
+	workspace := t.TempDir()
+	writeTree(t, workspace, map[string]string{"a.txt": "a\n", "b.txt": "b\n"})
+	tool := NewApplyPatchTool(workspace, true)
+	tool.fs = &failingWriteFs{fileSystem: tool.fs, fail: "b.txt"}
+	j := NewJournal(workspace, true, 0, 0, 0)
+	tool.SetJournal(j)
+	ctx := j.BeginTurn(context.Background(), "s1")
+
+	result := tool.Execute(ctx, map[string]any{"edits": []any{
+		map[string]any{"path": "a.txt", "old_text": "a", "new_text": "A"},
+		map[string]any{"path": "b.txt", "old_text": "b", "new_text": "B"},
+	}})
+	if !result.IsError {
+		t.Fatalf("expected the write of b.txt to fail, got %q", result.ForLLM)
+	}
+	if got, _ := os.ReadFile(filepath.Join(workspace, "a.txt")); string(got) != "a\n" {
+		t.Errorf("a.txt = %q, want it restored", got)
+	}
+	if entries, err := j.Entries(); err != nil || len(entries) != 0 {
+		t.Errorf("Entries() = %+v, %v; want nothing journaled", entries, err)
+	}
+}
@@ -0,0 +1,503 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/sandbox"
+)
+
+const (
+	// DefaultMaxProcesses bounds the running background processes per session.
+	DefaultMaxProcesses = 8
+	// DefaultProcessOutputBytes is the output kept per background process.
+	DefaultProcessOutputBytes = 64 * 1024
+
+	// maxExitedProcesses is how many finished processes a session keeps for polling.
+	maxExitedProcesses = 16
+	// maxPollWait caps how long a single poll may block.
+	maxPollWait = 60 * time.Second
+)
+
+// ringBuffer keeps the last len(buf) bytes written to it and counts every
+// byte ever written, so readers can resume from an offset and tell how much
+// output they missed.
+type ringBuffer struct {
+	mu    sync.Mutex
+	buf   []byte
+	size  int
+	total int64
+}
+
+func newRingBuffer(size int) *ringBuffer {
+	return &ringBuffer{size: size}
+}
+
+func (b *ringBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.total += int64(len(p))
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.size {
+		b.buf = append(b.buf[:0], b.buf[len(b.buf)-b.size:]...)
+	}
+	return len(p), nil
+}
+
+// readFrom returns the output written since offset, the new offset, and how
+// many bytes after offset were already overwritten.
+func (b *ringBuffer) readFrom(offset int64) (string, int64, int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	start := b.total - int64(len(b.buf))
+	var dropped int64
+	if offset < start {
+		dropped = start - offset
+		offset = start
+	}
+	return string(b.buf[offset-start:]), b.total, dropped
+}
+
+// backgroundProcess is one command started by the process tool.
+type backgroundProcess struct {
+	id      string
+	command string
+	cwd     string
+	started time.Time
+	cancel  context.CancelFunc
+	stdin   io.WriteCloser
+	output  *ringBuffer
+	done    chan struct{}
+
+	mu       sync.Mutex
+	readOff  int64
+	exitCode int
+	exitErr  string
+	ended    time.Time
+	killed   bool
+}
+
+func (p *backgroundProcess) running() bool {
+	select {
+	case <-p.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// status describes the process state, e.g. "running" or "exited (code 1)".
+func (p *backgroundProcess) status() string {
+	if p.running() {
+		return "running"
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch {
+	case p.killed:
+		return "killed"
+	case p.exitErr != "":
+		return fmt.Sprintf("exited (%s)", p.exitErr)
+	default:
+		return fmt.Sprintf("exited (code %d)", p.exitCode)
+	}
+}
+
+func (p *backgroundProcess) runtime() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.ended.IsZero() {
+		return time.Since(p.started).Round(time.Second)
+	}
+	return p.ended.Sub(p.started).Round(time.Second)
+}
+
+// ProcessManager tracks background processes per session. Processes outlive
+// the tool call that started them until they exit, are killed, or their
+// session is reset.
+type ProcessManager struct {
+	maxProcesses int
+	outputBytes  int
+
+	mu       sync.Mutex
+	nextID   int
+	sessions map[string][]*backgroundProcess
+}
+
+// NewProcessManager creates a manager; zero limits select the defaults.
+func NewProcessManager(maxProcesses, outputBytes int) *ProcessManager {
+	if maxProcesses <= 0 {
+		maxProcesses = DefaultMaxProcesses
+	}
+	if outputBytes <= 0 {
+		outputBytes = DefaultProcessOutputBytes
+	}
+	return &ProcessManager{
+		maxProcesses: maxProcesses,
+		outputBytes:  outputBytes,
+		sessions:     make(map[string][]*backgroundProcess),
+	}
+}
+
+// sessionFromContext returns the session key of the chat of ctx. Tools run
+// outside a chat, e.g. from the CLI, share the cli:direct session.
+func sessionFromContext(ctx context.Context) string {
+	channel, chatID := ChatFromContext(ctx)
+	if channel == "" || chatID == "" {
+		channel, chatID = "cli", "direct"
+	}
+	return ProcessSessionKey(channel, chatID)
+}
+
+// ProcessSessionKey identifies the session of a channel and chat.
+func ProcessSessionKey(channel, chatID string) string {
+	return channel + ":" + chatID
+}
+
+func (m *ProcessManager) start(session, command, cwd string, policy *sandbox.Policy) (*backgroundProcess, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	procs := m.sessions[session]
+	running := 0
+	for _, p := range procs {
+		if p.running() {
+			running++
+		}
+	}
+	if running >= m.maxProcesses {
+		return nil, fmt.Errorf("too many background processes (%d running); kill one first", running)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cmd := shellCommand(ctx, command, cwd)
+	// Killing the shell alone would leave its children running.
+	cmd.Cancel = func() error { return terminateProcessTree(cmd) }
+	cmd.WaitDelay = 2 * time.Second
+	if policy != nil {
+		if err := policy.Apply(cmd); err != nil {
+			cancel()
+			return nil, fmt.Errorf("sandbox: %w", err)
+		}
+	}
+
+	output := newRingBuffer(m.outputBytes)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to start command: %w", err)
+	}
+
+	m.nextID++
+	p := &backgroundProcess{
+		id:      fmt.Sprintf("p%d", m.nextID),
+		command: command,
+		cwd:     cwd,
+		started: time.Now(),
+		cancel:  cancel,
+		stdin:   stdin,
+		output:  output,
+		done:    make(chan struct{}),
+	}
+	go func() {
+		err := cmd.Wait()
+		p.mu.Lock()
+		p.ended = time.Now()
+		p.exitCode = cmd.ProcessState.ExitCode()
+		if err != nil && p.exitCode < 0 {
+			p.exitErr = err.Error()
+		}
+		p.mu.Unlock()
+		cancel()
+		close(p.done)
+	}()
+
+	m.sessions[session] = pruneExited(append(procs, p))
+	return p, nil
+}
+
+// pruneExited drops the oldest finished processes beyond maxExitedProcesses.
+func pruneExited(procs []*backgroundProcess) []*backgroundProcess {
+	exited := 0
+	for _, p := range procs {
+		if !p.running() {
+			exited++
+		}
+	}
+	out := procs[:0]
+	for _, p := range procs {
+		if exited > maxExitedProcesses && !p.running() {
+			exited--
+			continue
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+func (m *ProcessManager) get(session, id string) (*backgroundProcess, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, p := range m.sessions[session] {
+		if p.id == id {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+func (m *ProcessManager) list(session string) []*backgroundProcess {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*backgroundProcess(nil), m.sessions[session]...)
+}
+
+// kill terminates the process tree and waits for it to exit.
+func (p *backgroundProcess) kill() {
+	if !p.running() {
+		return
+	}
+	p.mu.Lock()
+	p.killed = true
+	p.mu.Unlock()
+	p.cancel()
+	<-p.done
+}
+
+// KillSession kills and forgets every process of a session, e.g. when the
+// conversation is reset.
+func (m *ProcessManager) KillSession(session string) int {
+	m.mu.Lock()
+	procs := m.sessions[session]
+	delete(m.sessions, session)
+	m.mu.Unlock()
+	return killAll(procs)
+}
+
+// Close kills every process of every session.
+func (m *ProcessManager) Close() {
+	m.mu.Lock()
+	var procs []*backgroundProcess
+	for _, ps := range m.sessions {
+		procs = append(procs, ps...)
+	}
+	m.sessions = make(map[string][]*backgroundProcess)
+	m.mu.Unlock()
+	killAll(procs)
+}
+
+func killAll(procs []*backgroundProcess) int {
+	killed := 0
+	var wg sync.WaitGroup
+	for _, p := range procs {
+		if p.running() {
+			killed++
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				p.kill()
+			}()
+		}
+	}
+	wg.Wait()
+	return killed
+}
+
+// ProcessTool starts and manages long-running commands in the background.
+// It shares the safety guards of the exec tool it is created with.
+// Processes belong to the session of the chat in the context given to
+// Execute.
+type ProcessTool struct {
+	exec    *ExecTool
+	manager *ProcessManager
+}
+
+func NewProcessTool(execTool *ExecTool, manager *ProcessManager) *ProcessTool {
+	return &ProcessTool{exec: execTool, manager: manager}
+}
+
+func (t *ProcessTool) Name() string {
+	return "process"
+}
+
+func (t *ProcessTool) Description() string {
+	return "Run long-lived shell commands in the background (dev servers, log tails, long builds). " +
+		"Actions: start (returns an id), poll (new output and status; optionally wait for exit), " +
+		"write (send input to stdin), kill, list. Use exec instead for short commands."
+}
+
+func (t *ProcessTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"action": map[string]any{
+				"type":        "string",
+				"enum":        []string{"start", "poll", "write", "kill", "list"},
+				"description": "Operation to perform",
+			},
+			"command": map[string]any{
+				"type":        "string",
+				"description": "Shell command to start (start)",
+			},
+			"working_dir": map[string]any{
+				"type":        "string",
+				"description": "Optional working directory (start)",
+			},
+			"id": map[string]any{
+				"type":        "string",
+				"description": "Process id returned by start (poll, write, kill)",
+			},
+			"input": map[string]any{
+				"type":        "string",
+				"description": "Text to write to stdin; include a trailing newline to submit a line (write)",
+			},
+			"close_stdin": map[string]any{
+				"type":        "boolean",
+				"description": "Close stdin after writing, signalling end of input (write)",
+			},
+			"wait_seconds": map[string]any{
+				"type":        "integer",
+				"description": "Block up to this many seconds (max 60) for the process to exit (start, poll)",
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+func (t *ProcessTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	action, _ := args["action"].(string)
+	switch action {
+	case "start":
+		return t.start(ctx, args)
+	case "poll":
+		return t.withProcess(ctx, args, func(p *backgroundProcess) *ToolResult {
+			return t.poll(ctx, p, waitDuration(args))
+		})
+	case "write":
+		return t.withProcess(ctx, args, t.write(args))
+	case "kill":
+		return t.withProcess(ctx, args, func(p *backgroundProcess) *ToolResult {
+			p.kill()
+			return t.poll(ctx, p, 0)
+		})
+	case "list":
+		return t.list(ctx)
+	default:
+		return ErrorResult("action must be one of start, poll, write, kill, list")
+	}
+}
+
+func (t *ProcessTool) start(ctx context.Context, args map[string]any) *ToolResult {
+	command, _ := args["command"].(string)
+	if strings.TrimSpace(command) == "" {
+		return ErrorResult("command is required")
+	}
+	cwd, guardError := t.exec.prepareCommand(ctx, command, args)
+	if guardError != "" {
+		return ErrorResult(guardError)
+	}
+
+	p, err := t.manager.start(sessionFromContext(ctx), command, cwd, t.exec.sandbox)
+	if err != nil {
+		return ErrorResult(err.Error()).WithError(err)
+	}
+	return t.poll(ctx, p, waitDuration(args))
+}
+
+func (t *ProcessTool) withProcess(
+	ctx context.Context,
+	args map[string]any,
+	fn func(*backgroundProcess) *ToolResult,
+) *ToolResult {
+	id, _ := args["id"].(string)
+	if id == "" {
+		return ErrorResult("id is required")
+	}
+	p, ok := t.manager.get(sessionFromContext(ctx), id)
+	if !ok {
+		return ErrorResult(fmt.Sprintf("no process %q in this session; use action=list", id))
+	}
+	return fn(p)
+}
+
+// poll waits up to wait for the process to exit and reports its status and
+// the output produced since the previous poll.
+func (t *ProcessTool) poll(ctx context.Context, p *backgroundProcess, wait time.Duration) *ToolResult {
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-p.done:
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+		timer.Stop()
+	}
+
+	// Read the status first so output written just before exit is included.
+	status := p.status()
+	p.mu.Lock()
+	out, next, dropped := p.output.readFrom(p.readOff)
+	p.readOff = next
+	p.mu.Unlock()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Process %s: %s after %s\nCommand: %s\n", p.id, status, p.runtime(), p.command)
+	if dropped > 0 {
+		fmt.Fprintf(&b, "... (%d earlier bytes of output dropped)\n", dropped)
+	}
+	if out == "" {
+		b.WriteString("(no new output)")
+	} else {
+		b.WriteString(out)
+	}
+	return SilentResult(b.String())
+}
+
+func (t *ProcessTool) write(args map[string]any) func(*backgroundProcess) *ToolResult {
+	return func(p *backgroundProcess) *ToolResult {
+		if !p.running() {
+			return ErrorResult(fmt.Sprintf("process %s is not running (%s)", p.id, p.status()))
+		}
+		input, _ := args["input"].(string)
+		if input != "" {
+			if _, err := io.WriteString(p.stdin, input); err != nil {
+				return ErrorResult(fmt.Sprintf("writing to %s: %v", p.id, err)).WithError(err)
+			}
+		}
+		if closeStdin, _ := args["close_stdin"].(bool); closeStdin {
+			p.stdin.Close()
+		}
+		return SilentResult(fmt.Sprintf("Wrote %d bytes to %s", len(input), p.id))
+	}
+}
+
+func (t *ProcessTool) list(ctx context.Context) *ToolResult {
+	procs := t.manager.list(sessionFromContext(ctx))
+	if len(procs) == 0 {
+		return SilentResult("No background processes in this session")
+	}
+	sort.Slice(procs, func(i, j int) bool { return procs[i].started.Before(procs[j].started) })
+
+	var b strings.Builder
+	for _, p := range procs {
+		fmt.Fprintf(&b, "%s  %-18s %8s  %s\n", p.id, p.status(), p.runtime(), p.command)
+	}
+	return SilentResult(strings.TrimRight(b.String(), "\n"))
+}
+
+func waitDuration(args map[string]any) time.Duration {
+	seconds, _ := args["wait_seconds"].(float64)
+	wait := time.Duration(seconds * float64(time.Second))
+	return min(max(wait, 0), maxPollWait)
+}
@@ -0,0 +1,191 @@
+//go:build !windows
+
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestProcessTool(t *testing.T, maxProcesses, outputBytes int) *ProcessTool {
+	t.Helper()
+	execTool, err := NewExecTool(t.TempDir(), true)
+	if err != nil {
+		t.Fatalf("NewExecTool() error: %v", err)
+	}
+	manager := NewProcessManager(maxProcesses, outputBytes)
+	t.Cleanup(manager.Close)
+	return NewProcessTool(execTool, manager)
+}
+
+func TestRingBuffer(t *testing.T) {
+	b := newRingBuffer(8)
+	b.Write([]byte("hello "))
+	out, off, dropped := b.readFrom(0)
+	if out != "hello " || off != 6 || dropped != 0 {
+		t.Fatalf("readFrom(0) = %q, %d, %d", out, off, dropped)
+	}
+
+	b.Write([]byte("world!"))
+	if out, off, dropped := b.readFrom(off); out != "world!" || off != 12 || dropped != 0 {
+		t.Fatalf("readFrom(6) = %q, %d, %d", out, off, dropped)
+	}
+	out, off, dropped = b.readFrom(0)
+	if out != "o world!" || off != 12 || dropped != 4 {
+		t.Fatalf("readFrom(0) = %q, %d, %d, want %q, 12, 4", out, off, dropped, "o world!")
+	}
+
+	if out, _, _ := b.readFrom(off); out != "" {
+		t.Errorf("readFrom(end) = %q, want empty", out)
+	}
+}
+
+func TestProcessTool_StartPollExit(t *testing.T) {
+	tool := newTestProcessTool(t, 0, 0)
+	ctx := context.Background()
+
+	result := tool.Execute(ctx, map[string]any{
+		"action":       "start",
+		"command":      "echo first; sleep 0.2; echo second; exit 3",
+		"wait_seconds": float64(5),
+	})
+	if result.IsError {
+		t.Fatalf("start error: %s", result.ForLLM)
+	}
+	for _, want := range []string{"Process p1: exited (code 3)", "first", "second"} {
+		if !strings.Contains(result.ForLLM, want) {
+			t.Errorf("start result missing %q:\n%s", want, result.ForLLM)
+		}
+	}
+
+	result = tool.Execute(ctx, map[string]any{"action": "poll", "id": "p1"})
+	if !strings.Contains(result.ForLLM, "(no new output)") {
+		t.Errorf("second poll should have no new output:\n%s", result.ForLLM)
+	}
+}
+
+func TestProcessTool_WriteStdin(t *testing.T) {
+	tool := newTestProcessTool(t, 0, 0)
+	ctx := context.Background()
+
+	result := tool.Execute(ctx, map[string]any{"action": "start", "command": "cat"})
+	if !strings.Contains(result.ForLLM, "running") {
+		t.Fatalf("start result: %s", result.ForLLM)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"action": "write", "id": "p1", "input": "ping\n", "close_stdin": true})
+	if result.IsError {
+		t.Fatalf("write error: %s", result.ForLLM)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"action": "poll", "id": "p1", "wait_seconds": float64(5)})
+	if !strings.Contains(result.ForLLM, "exited (code 0)") || !strings.Contains(result.ForLLM, "ping") {
+		t.Errorf("poll result:\n%s", result.ForLLM)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"action": "write", "id": "p1", "input": "late"})
+	if !result.IsError {
+		t.Error("write to an exited process should fail")
+	}
+}
+
+func TestProcessTool_KillAndList(t *testing.T) {
+	tool := newTestProcessTool(t, 0, 0)
+	ctx := context.Background()
+
+	tool.Execute(ctx, map[string]any{"action": "start", "command": "sleep 60 & sleep 60"})
+	start := time.Now()
+	result := tool.Execute(ctx, map[string]any{"action": "kill", "id": "p1"})
+	if !strings.Contains(result.ForLLM, "killed") {
+		t.Errorf("kill result:\n%s", result.ForLLM)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("kill took %v", time.Since(start))
+	}
+
+	result = tool.Execute(ctx, map[string]any{"action": "list"})
+	if !strings.Contains(result.ForLLM, "p1") || !strings.Contains(result.ForLLM, "killed") {
+		t.Errorf("list result:\n%s", result.ForLLM)
+	}
+}
+
+func TestProcessTool_SessionsAreIsolated(t *testing.T) {
+	tool := newTestProcessTool(t, 0, 0)
+	chat1 := WithChat(context.Background(), "telegram", "1")
+	chat2 := WithChat(context.Background(), "telegram", "2")
+
+	tool.Execute(chat1, map[string]any{"action": "start", "command": "sleep 60"})
+
+	if result := tool.Execute(chat2, map[string]any{"action": "poll", "id": "p1"}); !result.IsError {
+		t.Errorf("another session should not see p1:\n%s", result.ForLLM)
+	}
+	if result := tool.Execute(chat2, map[string]any{"action": "kill", "id": "p1"}); !result.IsError {
+		t.Errorf("another session should not kill p1:\n%s", result.ForLLM)
+	}
+	if result := tool.Execute(chat2, map[string]any{"action": "list"}); strings.Contains(result.ForLLM, "p1") {
+		t.Errorf("another session should not list p1:\n%s", result.ForLLM)
+	}
+	if result := tool.Execute(chat1, map[string]any{"action": "poll", "id": "p1"}); result.IsError {
+		t.Errorf("poll from the owning session = %s", result.ForLLM)
+	}
+
+	if n := tool.manager.KillSession(ProcessSessionKey("telegram", "1")); n != 1 {
+		t.Errorf("KillSession() = %d, want 1", n)
+	}
+}
+
+func TestProcessTool_Limits(t *testing.T) {
+	tool := newTestProcessTool(t, 1, 16)
+	ctx := context.Background()
+
+	result := tool.Execute(ctx, map[string]any{
+		"action":       "start",
+		"command":      "printf '%040d' 0",
+		"wait_seconds": float64(5),
+	})
+	if !strings.Contains(result.ForLLM, "24 earlier bytes of output dropped") {
+		t.Errorf("expected dropped output note:\n%s", result.ForLLM)
+	}
+
+	tool.Execute(ctx, map[string]any{"action": "start", "command": "sleep 60"})
+	result = tool.Execute(ctx, map[string]any{"action": "start", "command": "sleep 60"})
+	if !result.IsError || !strings.Contains(result.ForLLM, "too many") {
+		t.Errorf("start beyond the limit should fail:\n%s", result.ForLLM)
+	}
+}
+
+func TestProcessTool_Guards(t *testing.T) {
+	tool := newTestProcessTool(t, 0, 0)
+	ctx := context.Background()
+
+	tests := []map[string]any{
+		{"action": "start", "command": "rm -rf /"},
+		{"action": "start", "command": "cat ../secret"},
+		{"action": "start", "command": ""},
+		{"action": "poll"},
+		{"action": "bogus"},
+	}
+	for _, args := range tests {
+		if result := tool.Execute(ctx, args); !result.IsError {
+			t.Errorf("Execute(%v) should fail, got:\n%s", args, result.ForLLM)
+		}
+	}
+}
+
+func TestProcessManager_PrunesExited(t *testing.T) {
+	m := NewProcessManager(0, 0)
+	defer m.Close()
+	for i := range maxExitedProcesses + 3 {
+		p, err := m.start("s", fmt.Sprintf("exit %d", i%2), "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		<-p.done
+	}
+	if got := len(m.list("s")); got > maxExitedProcesses+1 {
+		t.Errorf("kept %d processes, want at most %d", got, maxExitedProcesses+1)
+	}
+}
@@ -90,6 +90,18 @@ func TestToolRegistry_Get_NotFound(t *testing.T) {
 	}
 }
 
+func TestToolRegistry_Unregister(t *testing.T) {
+	r := NewToolRegistry()
+	r.Register(newMockTool("a", "first"))
+	r.Register(newMockTool("b", "second"))
+
+	r.Unregister("a")
+	r.Unregister("missing")
+	if _, ok := r.Get("a"); ok || r.Count() != 1 {
+		t.Errorf("after Unregister(a): count %d, a still registered: %v", r.Count(), ok)
+	}
+}
+
 func TestToolRegistry_RegisterOverwrite(t *testing.T) {
 	r := NewToolRegistry()
 	r.Register(newMockTool("dup", "first"))
@@ -167,6 +179,33 @@ func TestToolRegistry_ExecuteWithContext_SkipsEmptyContext(t *testing.T) {
 	}
 }
 
+type mockChatTool struct {
+	mockRegistryTool
+	channel string
+	chatID  string
+}
+
+func (m *mockChatTool) Execute(ctx context.Context, _ map[string]any) *ToolResult {
+	m.channel, m.chatID = ChatFromContext(ctx)
+	return m.result
+}
+
+func TestToolRegistry_ExecuteWithContext_PassesChatInContext(t *testing.T) {
+	r := NewToolRegistry()
+	ct := &mockChatTool{mockRegistryTool: *newMockTool("chat_tool", "reads the chat")}
+	r.Register(ct)
+
+	r.ExecuteWithContext(context.Background(), "chat_tool", nil, "telegram", "chat-42", nil)
+	if ct.channel != "telegram" || ct.chatID != "chat-42" {
+		t.Errorf("ChatFromContext() = %q, %q", ct.channel, ct.chatID)
+	}
+
+	r.ExecuteWithContext(context.Background(), "chat_tool", nil, "", "", nil)
+	if ct.channel != "" || ct.chatID != "" {
+		t.Errorf("ChatFromContext() without a chat = %q, %q", ct.channel, ct.chatID)
+	}
+}
+
 func TestToolRegistry_ExecuteWithContext_AsyncCallback(t *testing.T) {
 	r := NewToolRegistry()
 	at := &mockAsyncRegistryTool{
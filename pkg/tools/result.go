@@ -34,6 +34,14 @@ type ToolResult struct {
 	// Media contains media store refs produced by this tool.
 	// When non-empty, the agent will publish these as OutboundMediaMessage.
 	Media []string `json:"media,omitempty"`
+
+	// ForLLMMedia contains files for the LLM to view, as data URLs
+	// (data:image/png;base64,...). The agent attaches them after the tool results.
+	ForLLMMedia []string `json:"for_llm_media,omitempty"`
+
+	// Exec describes how a shell command ended (exec tool only): exit code,
+	// duration, and whether output was truncated or spilled to files.
+	Exec *ExecInfo `json:"exec,omitempty"`
 }
 
 // NewToolResult creates a basic ToolResult with content for the LLM.
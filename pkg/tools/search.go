@@ -0,0 +1,610 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	defaultSearchLimit = 100
+	maxSearchLimit     = 1000
+	maxGrepContext     = 10
+	// maxGrepFileSize skips files too large to be worth scanning.
+	maxGrepFileSize = 10 * 1024 * 1024
+	// maxGrepLineLen shortens long lines (minified code, data) in results.
+	maxGrepLineLen = 300
+	// binarySniffLen is how much of a file is checked for NUL bytes.
+	binarySniffLen = 8000
+)
+
+// grepFileTypes maps the type filter of grep to file name patterns.
+var grepFileTypes = map[string][]string{
+	"c":     {"*.c", "*.h"},
+	"cpp":   {"*.cpp", "*.cc", "*.cxx", "*.hpp", "*.hh", "*.h"},
+	"css":   {"*.css", "*.scss", "*.less"},
+	"go":    {"*.go"},
+	"html":  {"*.html", "*.htm"},
+	"java":  {"*.java"},
+	"js":    {"*.js", "*.jsx", "*.mjs", "*.cjs"},
+	"json":  {"*.json"},
+	"md":    {"*.md", "*.markdown"},
+	"py":    {"*.py"},
+	"rust":  {"*.rs"},
+	"sh":    {"*.sh", "*.bash"},
+	"toml":  {"*.toml"},
+	"ts":    {"*.ts", "*.tsx"},
+	"txt":   {"*.txt"},
+	"yaml":  {"*.yaml", "*.yml"},
+	"proto": {"*.proto"},
+}
+
+// searchScope is the tree a glob or grep call walks. With restrict set it
+// is rooted at the workspace through os.Root, so neither ".." nor symlinks
+// can leave it.
+type searchScope struct {
+	fsys      fs.FS
+	start     string // fs.FS path of the searched file or directory
+	base      string // absolute directory fsys is rooted at
+	workspace string
+	close     func() error
+}
+
+func openSearchScope(workspace string, restrict bool, dir string) (*searchScope, error) {
+	if dir == "" {
+		dir = "."
+	}
+	if restrict {
+		rel, err := getSafeRelPath(workspace, dir)
+		if err != nil {
+			return nil, err
+		}
+		root, err := os.OpenRoot(workspace)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open workspace: %w", err)
+		}
+		base, _ := filepath.Abs(workspace)
+		return &searchScope{
+			fsys:      root.FS(),
+			start:     filepath.ToSlash(rel),
+			base:      base,
+			workspace: base,
+			close:     root.Close,
+		}, nil
+	}
+
+	if !filepath.IsAbs(dir) && workspace != "" {
+		dir = filepath.Join(workspace, dir)
+	}
+	base, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(base)
+	if err != nil {
+		return nil, err
+	}
+	start := "."
+	if !info.IsDir() {
+		start = filepath.Base(base)
+		base = filepath.Dir(base)
+	}
+	ws := workspace
+	if ws != "" {
+		ws, _ = filepath.Abs(ws)
+	}
+	return &searchScope{
+		fsys:      os.DirFS(base),
+		start:     start,
+		base:      base,
+		workspace: ws,
+		close:     func() error { return nil },
+	}, nil
+}
+
+// display returns how a result path is shown: relative to the workspace
+// when it is inside, absolute otherwise.
+func (s *searchScope) display(name string) string {
+	abs := filepath.Join(s.base, filepath.FromSlash(name))
+	if s.workspace != "" && isWithinWorkspace(abs, s.workspace) {
+		if rel, err := filepath.Rel(s.workspace, abs); err == nil {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return abs
+}
+
+// relToStart returns name relative to the searched directory.
+func (s *searchScope) relToStart(name string) string {
+	if s.start == "." {
+		return name
+	}
+	return strings.TrimPrefix(name, s.start+"/")
+}
+
+// walk calls fn for every file below the scope, skipping .git directories
+// and, unless includeIgnored is set, whatever .gitignore files exclude.
+// fn returns fs.SkipAll to stop early.
+func (s *searchScope) walk(ctx context.Context, includeIgnored bool, fn func(name string, d fs.DirEntry) error) error {
+	ignore := newIgnoreMatcher(s.fsys)
+	return fs.WalkDir(s.fsys, s.start, func(name string, d fs.DirEntry, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			if name == s.start {
+				return err
+			}
+			// Unreadable entries are skipped rather than failing the search.
+			if d != nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if name != s.start {
+			if d.IsDir() && (d.Name() == ".git" || d.Name() == JournalDir || d.Name() == execOutputDir) {
+				return fs.SkipDir
+			}
+			if !includeIgnored && ignore.ignored(s.start, name, d.IsDir()) {
+				if d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+		}
+		if d.IsDir() {
+			if !includeIgnored {
+				ignore.load(name)
+			}
+			return nil
+		}
+		return fn(name, d)
+	})
+}
+
+// searchLimit reads the limit argument, applying the default and maximum.
+func searchLimit(args map[string]any) int {
+	limit := defaultSearchLimit
+	if l, ok := args["limit"].(float64); ok && l > 0 {
+		limit = min(int(l), maxSearchLimit)
+	}
+	return limit
+}
+
+// splitPattern splits a glob pattern into segments for matchSegments and
+// checks its syntax.
+func splitPattern(pattern string) ([]string, error) {
+	segments := strings.Split(strings.Trim(filepath.ToSlash(pattern), "/"), "/")
+	for _, seg := range segments {
+		if _, err := path.Match(seg, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	return segments, nil
+}
+
+// GlobTool finds files by name pattern without shelling out to find.
+type GlobTool struct {
+	workspace string
+	restrict  bool
+	permStore *PermissionStore
+	permFn    PermissionFunc
+}
+
+func NewGlobTool(workspace string, restrict bool) *GlobTool {
+	return &GlobTool{workspace: workspace, restrict: restrict}
+}
+
+func (t *GlobTool) SetPermission(store *PermissionStore, fn PermissionFunc) {
+	t.permStore = store
+	t.permFn = fn
+}
+
+func (t *GlobTool) Name() string {
+	return "glob"
+}
+
+func (t *GlobTool) Description() string {
+	return "Find files whose path matches a glob pattern, e.g. \"**/*.go\" or \"src/*.ts\". " +
+		"\"*\" stays within one directory, \"**\" spans any number of directories. " +
+		"Files excluded by .gitignore are skipped unless include_ignored is true."
+}
+
+func (t *GlobTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"pattern": map[string]any{
+				"type":        "string",
+				"description": "Glob pattern relative to path",
+			},
+			"path": map[string]any{
+				"type":        "string",
+				"description": "Directory to search in (default: workspace)",
+			},
+			"limit": map[string]any{
+				"type":        "integer",
+				"description": fmt.Sprintf("Maximum number of paths to return (default %d)", defaultSearchLimit),
+			},
+			"include_ignored": map[string]any{
+				"type":        "boolean",
+				"description": "Also return files excluded by .gitignore",
+			},
+		},
+		"required": []string{"pattern"},
+	}
+}
+
+func (t *GlobTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	pattern, _ := args["pattern"].(string)
+	if pattern == "" {
+		return ErrorResult("pattern is required")
+	}
+	segments, err := splitPattern(pattern)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+	dir, _ := args["path"].(string)
+	if err := checkFilePermission(ctx, filepath.Join(dir, "*"), t.workspace, t.permStore, t.permFn); err != nil {
+		return ErrorResult(err.Error())
+	}
+	includeIgnored, _ := args["include_ignored"].(bool)
+	limit := searchLimit(args)
+
+	scope, err := openSearchScope(t.workspace, t.restrict, dir)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to search: %v", err))
+	}
+	defer scope.close()
+
+	var matches []string
+	truncated := false
+	err = scope.walk(ctx, includeIgnored, func(name string, d fs.DirEntry) error {
+		if !matchSegments(segments, strings.Split(scope.relToStart(name), "/")) {
+			return nil
+		}
+		if len(matches) == limit {
+			truncated = true
+			return fs.SkipAll
+		}
+		matches = append(matches, scope.display(name))
+		return nil
+	})
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to search: %v", err))
+	}
+
+	if len(matches) == 0 {
+		return SilentResult("No files found")
+	}
+	out := strings.Join(matches, "\n")
+	if truncated {
+		out += fmt.Sprintf("\n(showing the first %d matches; narrow the pattern or raise limit)", limit)
+	}
+	return SilentResult(out)
+}
+
+// GrepTool searches file contents with a regular expression without
+// shelling out to grep.
+type GrepTool struct {
+	workspace string
+	restrict  bool
+	permStore *PermissionStore
+	permFn    PermissionFunc
+}
+
+func NewGrepTool(workspace string, restrict bool) *GrepTool {
+	return &GrepTool{workspace: workspace, restrict: restrict}
+}
+
+func (t *GrepTool) SetPermission(store *PermissionStore, fn PermissionFunc) {
+	t.permStore = store
+	t.permFn = fn
+}
+
+func (t *GrepTool) Name() string {
+	return "grep"
+}
+
+func (t *GrepTool) Description() string {
+	return "Search file contents with a regular expression (RE2 syntax). Prints matching lines as " +
+		"path:line:text, or only file names or per-file counts. Binary files and files excluded by " +
+		".gitignore are skipped."
+}
+
+func (t *GrepTool) Parameters() map[string]any {
+	types := make([]string, 0, len(grepFileTypes))
+	for name := range grepFileTypes {
+		types = append(types, name)
+	}
+	sort.Strings(types)
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"pattern": map[string]any{
+				"type":        "string",
+				"description": "Regular expression to search for",
+			},
+			"path": map[string]any{
+				"type":        "string",
+				"description": "File or directory to search (default: workspace)",
+			},
+			"glob": map[string]any{
+				"type":        "string",
+				"description": "Only search files whose path matches this glob, e.g. \"*.go\" or \"src/**/*.ts\"",
+			},
+			"type": map[string]any{
+				"type":        "string",
+				"description": "Only search files of this type",
+				"enum":        types,
+			},
+			"ignore_case": map[string]any{
+				"type":        "boolean",
+				"description": "Match case-insensitively",
+			},
+			"context": map[string]any{
+				"type":        "integer",
+				"description": fmt.Sprintf("Lines of context around each match (max %d)", maxGrepContext),
+			},
+			"output_mode": map[string]any{
+				"type":        "string",
+				"enum":        []string{"content", "files", "count"},
+				"description": "content: matching lines (default); files: file names only; count: matches per file",
+			},
+			"limit": map[string]any{
+				"type": "integer",
+				"description": fmt.Sprintf("Maximum number of matching lines, or files in files/count mode "+
+					"(default %d)", defaultSearchLimit),
+			},
+			"include_ignored": map[string]any{
+				"type":        "boolean",
+				"description": "Also search files excluded by .gitignore",
+			},
+		},
+		"required": []string{"pattern"},
+	}
+}
+
+// grepOptions are the parsed arguments of a grep call.
+type grepOptions struct {
+	re      *regexp.Regexp
+	glob    []string // a file must match the glob, if any
+	types   []string // and one of the type patterns, if any
+	context int
+	mode    string
+	limit   int
+}
+
+func (t *GrepTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	opts, err := parseGrepArgs(args)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+	dir, _ := args["path"].(string)
+	if err := checkFilePermission(ctx, filepath.Join(dir, "*"), t.workspace, t.permStore, t.permFn); err != nil {
+		return ErrorResult(err.Error())
+	}
+	includeIgnored, _ := args["include_ignored"].(bool)
+
+	scope, err := openSearchScope(t.workspace, t.restrict, dir)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to search: %v", err))
+	}
+	defer scope.close()
+
+	var out strings.Builder
+	found, files := 0, 0
+	truncated := false
+	err = scope.walk(ctx, includeIgnored, func(name string, d fs.DirEntry) error {
+		if !opts.wants(scope.relToStart(name), d.Name()) {
+			return nil
+		}
+		display := scope.display(name)
+		switch opts.mode {
+		case "files", "count":
+			limit := 1
+			if opts.mode == "count" {
+				limit = -1
+			}
+			_, count, _ := grepFile(scope.fsys, name, opts, limit)
+			if count == 0 {
+				return nil
+			}
+			if files == opts.limit {
+				truncated = true
+				return fs.SkipAll
+			}
+			files++
+			if opts.mode == "files" {
+				out.WriteString(display + "\n")
+			} else {
+				fmt.Fprintf(&out, "%s:%d\n", display, count)
+			}
+		default:
+			lines, count, more := grepFile(scope.fsys, name, opts, opts.limit-found)
+			if count > 0 {
+				if found > 0 && opts.context > 0 {
+					out.WriteString("--\n")
+				}
+				for _, l := range lines {
+					if l == "--" {
+						out.WriteString("--\n")
+						continue
+					}
+					out.WriteString(display + l + "\n")
+				}
+				found += count
+			}
+			if more {
+				truncated = true
+				return fs.SkipAll
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to search: %v", err))
+	}
+
+	if out.Len() == 0 {
+		return SilentResult("No matches found")
+	}
+	result := strings.TrimSuffix(out.String(), "\n")
+	if truncated {
+		result += fmt.Sprintf("\n(results limited to %d; narrow the search or raise limit)", opts.limit)
+	}
+	return SilentResult(result)
+}
+
+func parseGrepArgs(args map[string]any) (*grepOptions, error) {
+	pattern, _ := args["pattern"].(string)
+	if pattern == "" {
+		return nil, errors.New("pattern is required")
+	}
+	if ignoreCase, _ := args["ignore_case"].(bool); ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %v", err)
+	}
+
+	opts := &grepOptions{re: re, mode: "content", limit: searchLimit(args)}
+	if c, ok := args["context"].(float64); ok && c > 0 {
+		opts.context = min(int(c), maxGrepContext)
+	}
+	if mode, _ := args["output_mode"].(string); mode != "" {
+		switch mode {
+		case "content", "files", "count":
+			opts.mode = mode
+		default:
+			return nil, fmt.Errorf("output_mode must be content, files or count; got %q", mode)
+		}
+	}
+	if glob, _ := args["glob"].(string); glob != "" {
+		segments, err := splitPattern(glob)
+		if err != nil {
+			return nil, err
+		}
+		opts.glob = segments
+	}
+	if typ, _ := args["type"].(string); typ != "" {
+		patterns, ok := grepFileTypes[typ]
+		if !ok {
+			return nil, fmt.Errorf("unknown file type %q", typ)
+		}
+		opts.types = patterns
+	}
+	return opts, nil
+}
+
+// wants reports whether a file passes the glob and type filters.
+func (o *grepOptions) wants(rel, base string) bool {
+	if o.glob != nil {
+		// Like grep --include, a pattern without "/" matches the base name.
+		target := strings.Split(rel, "/")
+		if len(o.glob) == 1 {
+			target = []string{base}
+		}
+		if !matchSegments(o.glob, target) {
+			return false
+		}
+	}
+	if len(o.types) == 0 {
+		return true
+	}
+	for _, pattern := range o.types {
+		if ok, _ := path.Match(pattern, base); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// grepFile searches one file. It returns the formatted result lines (each
+// starting with ":line:" for matches or "-line-" for context), the number
+// of matching lines found, and whether more matches were left out once
+// limit was reached (limit < 0 counts all matches without collecting them).
+func grepFile(fsys fs.FS, name string, opts *grepOptions, limit int) ([]string, int, bool) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, 0, false
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err != nil || !info.Mode().IsRegular() || info.Size() > maxGrepFileSize {
+		return nil, 0, false
+	}
+
+	reader := bufio.NewReader(f)
+	if head, _ := reader.Peek(binarySniffLen); bytes.IndexByte(head, 0) >= 0 {
+		return nil, 0, false
+	}
+
+	var (
+		out       []string
+		before    []string // the last opts.context lines, for leading context
+		count     int
+		lineNo    int
+		afterLeft int // trailing context lines still to print
+		lastOut   int // number of the last line printed
+	)
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		if !opts.re.MatchString(line) {
+			if afterLeft > 0 {
+				out = append(out, fmt.Sprintf("-%d-%s", lineNo, clipLine(line)))
+				lastOut = lineNo
+				afterLeft--
+			} else if opts.context > 0 {
+				before = append(before, line)
+				if len(before) > opts.context {
+					before = before[1:]
+				}
+			}
+			continue
+		}
+
+		if limit >= 0 && count == limit {
+			return out, count, true
+		}
+		count++
+		if limit < 0 {
+			continue
+		}
+		if opts.context > 0 && lastOut > 0 && lineNo-len(before) > lastOut+1 {
+			out = append(out, "--")
+		}
+		for i, b := range before {
+			out = append(out, fmt.Sprintf("-%d-%s", lineNo-len(before)+i, clipLine(b)))
+		}
+		before = before[:0]
+		out = append(out, fmt.Sprintf(":%d:%s", lineNo, clipLine(line)))
+		lastOut = lineNo
+		afterLeft = opts.context
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, io.EOF) {
+		// A line longer than the buffer ends the scan; keep what was found.
+		return out, count, false
+	}
+	return out, count, false
+}
+
+func clipLine(line string) string {
+	if len(line) <= maxGrepLineLen {
+		return line
+	}
+	return strings.ToValidUTF8(line[:maxGrepLineLen], "") + "..."
+}
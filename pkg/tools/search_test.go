@@ -0,0 +1,263 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// writeTree creates files (path -> content) below dir.
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func lines(s string) []string {
+	return strings.Split(strings.TrimSpace(s), "\n")
+}
+
+func TestMatchSegments(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "pkg/main.go", false},
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "pkg/tools/main.go", true},
+		{"pkg/**", "pkg/a/b", true},
+		{"pkg/**/test", "pkg/test", true},
+		{"pkg/**/test", "pkg/a/b/test", true},
+		{"pkg/*/test", "pkg/a/b/test", false},
+		{"src/?.ts", "src/a.ts", true},
+	}
+	for _, tt := range tests {
+		got := matchSegments(strings.Split(tt.pattern, "/"), strings.Split(tt.name, "/"))
+		if got != tt.want {
+			t.Errorf("matchSegments(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseGitignore(t *testing.T) {
+	rules := parseGitignore([]byte("# comment\n\nbuild/\n*.log\n!keep.log\n/root.txt\ndocs/*.tmp\n"))
+	m := &ignoreMatcher{rules: map[string][]ignoreRule{".": rules}}
+	tests := []struct {
+		name  string
+		isDir bool
+		want  bool
+	}{
+		{"build", true, true},
+		{"build", false, false},
+		{"src/build", true, true},
+		{"app.log", false, true},
+		{"src/app.log", false, true},
+		{"keep.log", false, false},
+		{"root.txt", false, true},
+		{"src/root.txt", false, false},
+		{"docs/a.tmp", false, true},
+		{"src/docs/a.tmp", false, false},
+		{"main.go", false, false},
+	}
+	for _, tt := range tests {
+		if got := m.ignored(".", tt.name, tt.isDir); got != tt.want {
+			t.Errorf("ignored(%q, dir=%v) = %v, want %v", tt.name, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestGlobTool(t *testing.T) {
+	workspace := t.TempDir()
+	writeTree(t, workspace, map[string]string{
+		"main.go":             "",
+		"README.md":           "",
+		"pkg/a/a.go":          "",
+		"pkg/a/a_test.go":     "",
+		"pkg/b/b.go":          "",
+		"vendor/x/x.go":       "",
+		".gitignore":          "vendor/\n*.gen.go\n",
+		"pkg/b/types.gen.go":  "",
+		"pkg/b/.gitignore":    "!types.gen.go\n",
+		".git/objects/foo.go": "",
+		".exec_output/out.go": "",
+	})
+	tool := NewGlobTool(workspace, true)
+	ctx := context.Background()
+
+	result := tool.Execute(ctx, map[string]any{"pattern": "**/*.go"})
+	if result.IsError {
+		t.Fatal(result.ForLLM)
+	}
+	want := []string{"main.go", "pkg/a/a.go", "pkg/a/a_test.go", "pkg/b/b.go", "pkg/b/types.gen.go"}
+	if got := lines(result.ForLLM); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("glob **/*.go = %v, want %v", got, want)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"pattern": "*.go"})
+	if got := lines(result.ForLLM); len(got) != 1 || got[0] != "main.go" {
+		t.Errorf("glob *.go = %v, want only main.go", got)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"pattern": "*.go", "path": "pkg/a"})
+	if got := lines(result.ForLLM); len(got) != 2 || got[0] != "pkg/a/a.go" {
+		t.Errorf("glob in pkg/a = %v", got)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"pattern": "**/*.go", "include_ignored": true})
+	if !strings.Contains(result.ForLLM, "vendor/x/x.go") || strings.Contains(result.ForLLM, ".git/") ||
+		strings.Contains(result.ForLLM, execOutputDir) {
+		t.Errorf("include_ignored result = %q", result.ForLLM)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"pattern": "**/*.go", "limit": 2.0})
+	if got := lines(result.ForLLM); len(got) != 3 || !strings.Contains(got[2], "first 2 matches") {
+		t.Errorf("limited result = %v", got)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"pattern": "*.rs"})
+	if result.IsError || result.ForLLM != "No files found" {
+		t.Errorf("no match result = %+v", result)
+	}
+
+	if result = tool.Execute(ctx, map[string]any{"pattern": "[", "path": "."}); !result.IsError {
+		t.Error("expected an error for an invalid pattern")
+	}
+}
+
+func TestSearchTools_RestrictToWorkspace(t *testing.T) {
+	base := t.TempDir()
+	workspace := filepath.Join(base, "workspace")
+	outside := filepath.Join(base, "outside")
+	writeTree(t, base, map[string]string{
+		"workspace/notes.txt": "hello",
+		"outside/secret.txt":  "hello secret",
+	})
+	if runtime.GOOS != "windows" {
+		if err := os.Symlink(outside, filepath.Join(workspace, "link")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := context.Background()
+	glob := NewGlobTool(workspace, true)
+	grep := NewGrepTool(workspace, true)
+
+	for _, dir := range []string{"..", outside, "../outside"} {
+		if r := glob.Execute(ctx, map[string]any{"pattern": "*", "path": dir}); !r.IsError {
+			t.Errorf("glob in %q should be denied, got %q", dir, r.ForLLM)
+		}
+		if r := grep.Execute(ctx, map[string]any{"pattern": "hello", "path": dir}); !r.IsError {
+			t.Errorf("grep in %q should be denied, got %q", dir, r.ForLLM)
+		}
+	}
+
+	if runtime.GOOS != "windows" {
+		if r := grep.Execute(ctx, map[string]any{"pattern": "secret", "path": "link"}); !r.IsError {
+			t.Errorf("grep through a symlink should be denied, got %q", r.ForLLM)
+		}
+	}
+	r := grep.Execute(ctx, map[string]any{"pattern": "hello"})
+	if r.IsError || strings.Contains(r.ForLLM, "secret") {
+		t.Errorf("grep followed a symlink out of the workspace: %q", r.ForLLM)
+	}
+
+	// Without the restriction the same search is allowed.
+	r = NewGrepTool(workspace, false).Execute(ctx, map[string]any{"pattern": "secret", "path": outside})
+	if r.IsError || !strings.Contains(r.ForLLM, "secret.txt:1:hello secret") {
+		t.Errorf("unrestricted grep = %q", r.ForLLM)
+	}
+}
+
+func TestGrepTool(t *testing.T) {
+	workspace := t.TempDir()
+	writeTree(t, workspace, map[string]string{
+		"a.go":        "package a\n\nfunc Foo() {}\n\nfunc bar() {}\n",
+		"b.py":        "def foo():\n    pass\n",
+		"docs/c.md":   "Foo is documented here\n",
+		"data.bin":    "foo\x00\x01\x02",
+		"ignored.txt": "foo",
+		".gitignore":  "ignored.txt\n",
+	})
+	tool := NewGrepTool(workspace, true)
+	ctx := context.Background()
+
+	r := tool.Execute(ctx, map[string]any{"pattern": "foo", "ignore_case": true})
+	if r.IsError {
+		t.Fatal(r.ForLLM)
+	}
+	want := []string{"a.go:3:func Foo() {}", "b.py:1:def foo():", "docs/c.md:1:Foo is documented here"}
+	if got := lines(r.ForLLM); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("grep = %q, want %q", got, want)
+	}
+
+	r = tool.Execute(ctx, map[string]any{"pattern": "foo", "ignore_case": true, "type": "go"})
+	if got := lines(r.ForLLM); len(got) != 1 || got[0] != "a.go:3:func Foo() {}" {
+		t.Errorf("type=go: %q", got)
+	}
+
+	r = tool.Execute(ctx, map[string]any{"pattern": "foo", "ignore_case": true, "glob": "docs/*.md"})
+	if got := lines(r.ForLLM); len(got) != 1 || !strings.HasPrefix(got[0], "docs/c.md") {
+		t.Errorf("glob=docs/*.md: %q", got)
+	}
+
+	r = tool.Execute(ctx, map[string]any{"pattern": "^func", "context": 1.0, "path": "a.go"})
+	want = []string{"a.go-2-", "a.go:3:func Foo() {}", "a.go-4-", "a.go:5:func bar() {}"}
+	if got := lines(r.ForLLM); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("context: %q, want %q", got, want)
+	}
+
+	r = tool.Execute(ctx, map[string]any{"pattern": "func", "output_mode": "count"})
+	if strings.TrimSpace(r.ForLLM) != "a.go:2" {
+		t.Errorf("count: %q", r.ForLLM)
+	}
+
+	r = tool.Execute(ctx, map[string]any{"pattern": "(?i)foo", "output_mode": "files"})
+	if got := lines(r.ForLLM); len(got) != 3 {
+		t.Errorf("files: %q", got)
+	}
+
+	r = tool.Execute(ctx, map[string]any{"pattern": "func", "limit": 1.0})
+	got := lines(r.ForLLM)
+	if len(got) != 2 || got[0] != "a.go:3:func Foo() {}" || !strings.Contains(got[1], "limited to 1") {
+		t.Errorf("limit: %q", got)
+	}
+
+	r = tool.Execute(ctx, map[string]any{"pattern": "nothing matches this"})
+	if r.IsError || r.ForLLM != "No matches found" {
+		t.Errorf("no match: %+v", r)
+	}
+
+	for _, args := range []map[string]any{
+		{"pattern": "("},
+		{"pattern": "x", "type": "cobol"},
+		{"pattern": "x", "output_mode": "json"},
+	} {
+		if r := tool.Execute(ctx, args); !r.IsError {
+			t.Errorf("Execute(%v) should fail", args)
+		}
+	}
+}
+
+func TestGrepTool_ContextSeparators(t *testing.T) {
+	workspace := t.TempDir()
+	writeTree(t, workspace, map[string]string{
+		"f.txt": "match 1\nx\nx\nx\nx\nmatch 6\n",
+	})
+	r := NewGrepTool(workspace, true).Execute(context.Background(),
+		map[string]any{"pattern": "match", "context": 1.0})
+	want := []string{"f.txt:1:match 1", "f.txt-2-x", "--", "f.txt-5-x", "f.txt:6:match 6"}
+	if got := lines(r.ForLLM); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("grep = %q, want %q", got, want)
+	}
+}
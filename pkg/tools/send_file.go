@@ -0,0 +1,285 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/media"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// DefaultSendFileBytes is the largest file send_file delivers on channels
+// without a limit of their own.
+const DefaultSendFileBytes = 20 << 20
+
+// MediaSendCallback delivers media attachments to a chat.
+type MediaSendCallback func(ctx context.Context, msg bus.OutboundMediaMessage) error
+
+// SendFileTool sends a workspace file to the user as an attachment. The
+// file is copied to the media directory and registered in the media store,
+// whose expiry cleanup removes the copy after delivery.
+type SendFileTool struct {
+	fs              fileSystem
+	workspace       string
+	maxBytes        int64
+	channelMaxBytes map[string]int64
+	mediaDir        string
+
+	store        media.MediaStore
+	sendCallback MediaSendCallback
+	canSendMedia func(channel string) bool
+
+	permStore *PermissionStore
+	permFn    PermissionFunc
+}
+
+// NewSendFileTool creates a send_file tool. maxBytes limits files on
+// channels that have no entry in channelMaxBytes; non-positive uses
+// DefaultSendFileBytes.
+func NewSendFileTool(workspace string, restrict bool, maxBytes int64, channelMaxBytes map[string]int64) *SendFileTool {
+	var fs fileSystem
+	if restrict {
+		fs = &sandboxFs{workspace: workspace}
+	} else {
+		fs = &hostFs{}
+	}
+	if maxBytes <= 0 {
+		maxBytes = DefaultSendFileBytes
+	}
+	return &SendFileTool{
+		fs:              fs,
+		workspace:       workspace,
+		maxBytes:        maxBytes,
+		channelMaxBytes: channelMaxBytes,
+		mediaDir:        filepath.Join(os.TempDir(), "picoclaw_media"),
+	}
+}
+
+func (t *SendFileTool) Name() string {
+	return "send_file"
+}
+
+func (t *SendFileTool) Description() string {
+	return "Send a file from the workspace to the user as an attachment, such as a chart, image, " +
+		"report or archive you created. Images, audio and video are sent as such; other files as documents."
+}
+
+func (t *SendFileTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"path": map[string]any{
+				"type":        "string",
+				"description": "Path of the file to send",
+			},
+			"caption": map[string]any{
+				"type":        "string",
+				"description": "Optional: text shown with the file",
+			},
+			"filename": map[string]any{
+				"type":        "string",
+				"description": "Optional: file name shown to the user (default: the name of the file)",
+			},
+			"type": map[string]any{
+				"type":        "string",
+				"enum":        []string{"image", "audio", "video", "file"},
+				"description": "Optional: how to send the file (default: inferred from its content type)",
+			},
+			"channel": map[string]any{
+				"type":        "string",
+				"description": "Optional: target channel (telegram, discord, etc.)",
+			},
+			"chat_id": map[string]any{
+				"type":        "string",
+				"description": "Optional: target chat/user ID",
+			},
+		},
+		"required": []string{"path"},
+	}
+}
+
+func (t *SendFileTool) SetPermission(store *PermissionStore, fn PermissionFunc) {
+	t.permStore = store
+	t.permFn = fn
+}
+
+// SetMediaStore sets the store files are registered in. Without one the
+// tool reports that sending files is not available.
+func (t *SendFileTool) SetMediaStore(store media.MediaStore) {
+	t.store = store
+}
+
+func (t *SendFileTool) SetSendCallback(callback MediaSendCallback) {
+	t.sendCallback = callback
+}
+
+// SetMediaSupport sets the check for whether a channel can send media.
+func (t *SendFileTool) SetMediaSupport(canSend func(channel string) bool) {
+	t.canSendMedia = canSend
+}
+
+// limit returns the largest file that may be sent on channel.
+func (t *SendFileTool) limit(channel string) int64 {
+	if n := t.channelMaxBytes[channel]; n > 0 {
+		return n
+	}
+	return t.maxBytes
+}
+
+func (t *SendFileTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	path, _ := args["path"].(string)
+	if path == "" {
+		return ErrorResult("path is required")
+	}
+	caption, _ := args["caption"].(string)
+	filename, _ := args["filename"].(string)
+	mediaType, _ := args["type"].(string)
+	switch mediaType {
+	case "", "image", "audio", "video", "file":
+	default:
+		return ErrorResult(fmt.Sprintf("invalid type %q: use image, audio, video or file", mediaType))
+	}
+
+	// Files go to the chat of the current turn unless told otherwise.
+	defaultChannel, defaultChatID := ChatFromContext(ctx)
+	channel, _ := args["channel"].(string)
+	chatID, _ := args["chat_id"].(string)
+	if channel == "" {
+		channel = defaultChannel
+	}
+	if chatID == "" {
+		chatID = defaultChatID
+	}
+	if channel == "" || chatID == "" {
+		return ErrorResult("No target channel/chat specified")
+	}
+	if t.store == nil || t.sendCallback == nil {
+		return ErrorResult("Sending files is not configured")
+	}
+	if t.canSendMedia != nil && !t.canSendMedia(channel) {
+		return ErrorResult(fmt.Sprintf("channel %s cannot send files", channel))
+	}
+
+	if err := checkFilePermission(ctx, path, t.workspace, t.permStore, t.permFn); err != nil {
+		return ErrorResult(err.Error())
+	}
+	f, err := t.fs.Open(path)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to read file: %v", err))
+	}
+	if info.IsDir() {
+		return ErrorResult(fmt.Sprintf("%s is a directory; archive it first to send it", path))
+	}
+	limit := t.limit(channel)
+	if info.Size() > limit {
+		return ErrorResult(fmt.Sprintf("%s is %d bytes, larger than the %d byte limit for %s",
+			path, info.Size(), limit, channel))
+	}
+
+	if filename == "" {
+		filename = filepath.Base(path)
+	}
+	contentType, err := detectFileType(f, filename)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to read file: %v", err))
+	}
+	if mediaType == "" {
+		mediaType = media.InferType(filename, contentType)
+	}
+
+	localPath, err := t.copyToMediaDir(f, filename, limit)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+	scope := channel + ":" + chatID + ":" + uuid.New().String()
+	ref, err := t.store.Store(localPath, media.MediaMeta{
+		Filename:    filename,
+		ContentType: contentType,
+		Source:      "tool:send_file",
+	}, scope)
+	if err != nil {
+		os.Remove(localPath)
+		return ErrorResult(fmt.Sprintf("registering file: %v", err))
+	}
+
+	err = t.sendCallback(ctx, bus.OutboundMediaMessage{
+		Channel: channel,
+		ChatID:  chatID,
+		Parts: []bus.MediaPart{{
+			Type:        mediaType,
+			Ref:         ref,
+			Caption:     caption,
+			Filename:    filename,
+			ContentType: contentType,
+		}},
+	})
+	if err != nil {
+		t.store.ReleaseAll(scope)
+		return &ToolResult{ForLLM: fmt.Sprintf("sending file: %v", err), IsError: true, Err: err}
+	}
+
+	// Silent: the user receives the file itself
+	return &ToolResult{
+		ForLLM: fmt.Sprintf("Sent %s (%s, %s, %d bytes) to %s:%s",
+			filename, mediaType, contentType, info.Size(), channel, chatID),
+		Silent: true,
+	}
+}
+
+// detectFileType returns the MIME type of f from the extension of name, or
+// from its content when the extension is unknown.
+func detectFileType(f *os.File, name string) (string, error) {
+	if ct := mime.TypeByExtension(strings.ToLower(filepath.Ext(name))); ct != "" {
+		return ct, nil
+	}
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return http.DetectContentType(head[:n]), nil
+}
+
+// copyToMediaDir copies f into the media directory, so that the media
+// store can delete the copy without touching the workspace.
+func (t *SendFileTool) copyToMediaDir(f *os.File, filename string, limit int64) (string, error) {
+	if err := os.MkdirAll(t.mediaDir, 0o700); err != nil {
+		return "", fmt.Errorf("creating media directory: %w", err)
+	}
+	localPath := filepath.Join(t.mediaDir, uuid.New().String()[:8]+"_"+utils.SanitizeFilename(filename))
+	out, err := os.OpenFile(localPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return "", fmt.Errorf("copying file: %w", err)
+	}
+	// The file may have grown since it was checked.
+	n, err := io.Copy(out, io.LimitReader(f, limit+1))
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && n > limit {
+		err = fmt.Errorf("file is larger than the %d byte limit", limit)
+	}
+	if err != nil {
+		os.Remove(localPath)
+		return "", fmt.Errorf("copying file: %w", err)
+	}
+	return localPath, nil
+}
@@ -0,0 +1,166 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/media"
+)
+
+// testChat is the chat the tests send files from.
+var testChat = WithChat(context.Background(), "discord", "chat-1")
+
+func newTestSendFileTool(t *testing.T, workspace string, restrict bool) (*SendFileTool, *[]bus.OutboundMediaMessage) {
+	t.Helper()
+	tool := NewSendFileTool(workspace, restrict, 16, map[string]int64{"telegram": 32})
+	tool.mediaDir = t.TempDir()
+	tool.SetMediaStore(media.NewFileMediaStore())
+	var sent []bus.OutboundMediaMessage
+	tool.SetSendCallback(func(ctx context.Context, msg bus.OutboundMediaMessage) error {
+		sent = append(sent, msg)
+		return nil
+	})
+	return tool, &sent
+}
+
+func TestSendFileTool_Execute(t *testing.T) {
+	workspace := t.TempDir()
+	os.WriteFile(filepath.Join(workspace, "chart.png"), []byte("\x89PNG\r\n\x1a\nimage"), 0o644)
+	tool, sent := newTestSendFileTool(t, workspace, true)
+
+	result := tool.Execute(testChat, map[string]any{"path": "chart.png", "caption": "Weekly chart"})
+	if result.IsError || !result.Silent {
+		t.Fatalf("result = %+v", result)
+	}
+	if len(*sent) != 1 || len((*sent)[0].Parts) != 1 {
+		t.Fatalf("sent = %+v", *sent)
+	}
+	msg := (*sent)[0]
+	part := msg.Parts[0]
+	if msg.Channel != "discord" || msg.ChatID != "chat-1" {
+		t.Errorf("target = %s:%s", msg.Channel, msg.ChatID)
+	}
+	if part.Type != "image" || part.Caption != "Weekly chart" || part.Filename != "chart.png" ||
+		part.ContentType != "image/png" {
+		t.Errorf("part = %+v", part)
+	}
+
+	// The store owns a copy, so its cleanup leaves the workspace alone.
+	localPath, err := tool.store.Resolve(part.Ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Dir(localPath) != tool.mediaDir {
+		t.Errorf("stored path %s is not in the media directory", localPath)
+	}
+}
+
+func TestSendFileTool_TargetsChatOfContext(t *testing.T) {
+	workspace := t.TempDir()
+	os.WriteFile(filepath.Join(workspace, "a.txt"), []byte("a"), 0o644)
+	tool, sent := newTestSendFileTool(t, workspace, true)
+
+	tool.Execute(WithChat(context.Background(), "telegram", "chat-2"), map[string]any{"path": "a.txt"})
+	tool.Execute(testChat, map[string]any{"path": "a.txt"})
+	if len(*sent) != 2 || (*sent)[0].Channel != "telegram" || (*sent)[0].ChatID != "chat-2" ||
+		(*sent)[1].Channel != "discord" || (*sent)[1].ChatID != "chat-1" {
+		t.Errorf("sent = %+v", *sent)
+	}
+
+	result := tool.Execute(context.Background(), map[string]any{"path": "a.txt"})
+	if !result.IsError || !strings.Contains(result.ForLLM, "No target") {
+		t.Errorf("result without a chat = %+v", result)
+	}
+}
+
+func TestSendFileTool_TypeInference(t *testing.T) {
+	workspace := t.TempDir()
+	os.WriteFile(filepath.Join(workspace, "notes"), []byte("plain text"), 0o644)
+	os.WriteFile(filepath.Join(workspace, "clip.mp3"), []byte("ID3"), 0o644)
+	tool, sent := newTestSendFileTool(t, workspace, true)
+
+	tool.Execute(testChat, map[string]any{"path": "notes"})
+	tool.Execute(testChat, map[string]any{"path": "clip.mp3"})
+	tool.Execute(testChat, map[string]any{"path": "clip.mp3", "type": "file", "filename": "a.mp3"})
+	if len(*sent) != 3 {
+		t.Fatalf("sent %d messages, want 3", len(*sent))
+	}
+	want := []struct{ typ, contentType, filename string }{
+		{"file", "text/plain; charset=utf-8", "notes"},
+		{"audio", "audio/mpeg", "clip.mp3"},
+		{"file", "audio/mpeg", "a.mp3"},
+	}
+	for i, w := range want {
+		part := (*sent)[i].Parts[0]
+		if part.Type != w.typ || part.ContentType != w.contentType || part.Filename != w.filename {
+			t.Errorf("part %d = %+v, want %+v", i, part, w)
+		}
+	}
+}
+
+func TestSendFileTool_Limits(t *testing.T) {
+	workspace := t.TempDir()
+	os.WriteFile(filepath.Join(workspace, "big.txt"), []byte(strings.Repeat("x", 20)), 0o644)
+	os.Mkdir(filepath.Join(workspace, "dir"), 0o755)
+	outside := filepath.Join(t.TempDir(), "secret.txt")
+	os.WriteFile(outside, []byte("secret"), 0o644)
+	tool, sent := newTestSendFileTool(t, workspace, true)
+	ctx := testChat
+
+	if result := tool.Execute(ctx, map[string]any{"path": "big.txt"}); !result.IsError ||
+		!strings.Contains(result.ForLLM, "limit") {
+		t.Errorf("file over the default limit: %+v", result)
+	}
+	if result := tool.Execute(ctx, map[string]any{"path": "big.txt", "channel": "telegram"}); result.IsError {
+		t.Errorf("file within the channel limit: %s", result.ForLLM)
+	}
+	if result := tool.Execute(ctx, map[string]any{"path": "dir"}); !result.IsError {
+		t.Error("directory should be rejected")
+	}
+	if result := tool.Execute(ctx, map[string]any{"path": outside}); !result.IsError {
+		t.Error("file outside the workspace should be rejected")
+	}
+	if result := tool.Execute(ctx, map[string]any{"path": "big.txt", "type": "sticker"}); !result.IsError {
+		t.Error("unknown type should be rejected")
+	}
+	if len(*sent) != 1 {
+		t.Errorf("sent %d messages, want 1", len(*sent))
+	}
+
+	tool.SetMediaSupport(func(channel string) bool { return channel == "telegram" })
+	if result := tool.Execute(ctx, map[string]any{"path": "big.txt", "channel": "cli"}); !result.IsError {
+		t.Error("channel without media support should be rejected")
+	}
+
+	unrestricted, _ := newTestSendFileTool(t, workspace, false)
+	if result := unrestricted.Execute(ctx, map[string]any{"path": outside}); result.IsError {
+		t.Errorf("unrestricted tool: %s", result.ForLLM)
+	}
+}
+
+func TestSendFileTool_SendFailure(t *testing.T) {
+	workspace := t.TempDir()
+	os.WriteFile(filepath.Join(workspace, "a.txt"), []byte("a"), 0o644)
+	tool, _ := newTestSendFileTool(t, workspace, true)
+	var ref string
+	tool.SetSendCallback(func(ctx context.Context, msg bus.OutboundMediaMessage) error {
+		ref = msg.Parts[0].Ref
+		return errors.New("channel down")
+	})
+
+	result := tool.Execute(testChat, map[string]any{"path": "a.txt"})
+	if !result.IsError || !strings.Contains(result.ForLLM, "channel down") {
+		t.Errorf("result = %+v", result)
+	}
+	if _, err := tool.store.Resolve(ref); err == nil {
+		t.Error("file should be released after a failed send")
+	}
+	if entries, _ := os.ReadDir(tool.mediaDir); len(entries) != 0 {
+		t.Errorf("media directory has %d leftover files", len(entries))
+	}
+}
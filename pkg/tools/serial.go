@@ -0,0 +1,637 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// maxSerialPorts bounds the ports a session may keep open.
+	maxSerialPorts = 8
+	// defaultSerialTimeout is how long a read waits when no timeout is given.
+	defaultSerialTimeout = time.Second
+	// maxSerialTimeout caps how long a single read or write may block.
+	maxSerialTimeout = 60 * time.Second
+	// defaultSerialReadBytes and maxSerialReadBytes bound one read.
+	defaultSerialReadBytes = 1024
+	maxSerialReadBytes     = 64 * 1024
+	// maxSerialWriteBytes bounds one write.
+	maxSerialWriteBytes = 4096
+)
+
+// serialPortPatterns are the device files list reports as serial ports.
+var serialPortPatterns = []string{
+	"/dev/ttyUSB*", "/dev/ttyACM*", "/dev/ttyAMA*", "/dev/ttyS*", "/dev/ttyGS*", "/dev/serial/by-id/*",
+}
+
+// serialOpenPatterns are the device files open accepts. Other devices such
+// as /dev/watchdog or /dev/mem act on being opened, so they are refused
+// before the tty check can run. Pseudo-terminals cover virtual ports made
+// with socat.
+var serialOpenPatterns = append([]string{
+	"/dev/tty*", "/dev/rfcomm*", "/dev/serial/by-id/*", "/dev/serial/by-path/*", "/dev/pts/*",
+}, serialPortPatterns...)
+
+// serialSettings are the line settings of an open port.
+type serialSettings struct {
+	Baud     int    `json:"baud"`
+	DataBits int    `json:"data_bits"`
+	Parity   string `json:"parity"`
+	StopBits int    `json:"stop_bits"`
+	// Timeout is the default wait of read.
+	Timeout time.Duration `json:"-"`
+}
+
+// serialDevice is an open serial device; the implementation is platform
+// specific.
+type serialDevice interface {
+	// read waits up to wait for data and returns 0 bytes if none arrived.
+	read(buf []byte, wait time.Duration) (int, error)
+	// write writes all of data, waiting up to wait for the device to accept it.
+	write(data []byte, wait time.Duration) (int, error)
+	close() error
+}
+
+// serialPort is a port opened by the serial tool. Bytes read past the
+// delimiter of a read are kept for the next one.
+type serialPort struct {
+	id       string
+	path     string
+	settings serialSettings
+
+	mu      sync.Mutex
+	dev     serialDevice
+	pending []byte
+}
+
+// SerialTool talks to UART peripherals (GPS modules, microcontrollers,
+// modems, LoRa radios) through ports that stay open across calls. Ports
+// belong to the session of the chat that opened them.
+type SerialTool struct {
+	mu       sync.Mutex
+	nextID   int
+	sessions map[string][]*serialPort
+}
+
+func NewSerialTool() *SerialTool {
+	return &SerialTool{sessions: make(map[string][]*serialPort)}
+}
+
+func (t *SerialTool) Name() string {
+	return "serial"
+}
+
+func (t *SerialTool) Description() string {
+	return "Talk to serial/UART devices such as GPS modules, Arduinos, modems and LoRa radios. " +
+		"Actions: list (find ports and show open ones), open (returns an id; set baud, parity and timeout), " +
+		"write (send text or bytes), read (read until a delimiter, a byte count or the timeout), close. " +
+		"Ports stay open between calls. Linux only."
+}
+
+func (t *SerialTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"action": map[string]any{
+				"type":        "string",
+				"enum":        []string{"list", "open", "write", "read", "close"},
+				"description": "Action to perform",
+			},
+			"port": map[string]any{
+				"type":        "string",
+				"description": "Device path for open, e.g. \"/dev/ttyUSB0\" or \"ttyACM0\"",
+			},
+			"id": map[string]any{
+				"type":        "string",
+				"description": "Port id returned by open. Required for write/read/close.",
+			},
+			"baud": map[string]any{
+				"type":        "integer",
+				"description": "Baud rate for open. Default: 9600.",
+			},
+			"data_bits": map[string]any{
+				"type":        "integer",
+				"description": "Data bits for open (5-8). Default: 8.",
+			},
+			"parity": map[string]any{
+				"type":        "string",
+				"enum":        []string{"none", "even", "odd"},
+				"description": "Parity for open. Default: none.",
+			},
+			"stop_bits": map[string]any{
+				"type":        "integer",
+				"description": "Stop bits for open (1 or 2). Default: 1.",
+			},
+			"timeout_ms": map[string]any{
+				"type": "integer",
+				"description": "For open: default read timeout in milliseconds (default 1000). " +
+					"For read/write: timeout of this call. Maximum 60000.",
+			},
+			"data": map[string]any{
+				"type":        "string",
+				"description": "Text to write, e.g. \"AT\\r\\n\". Escapes such as \\r, \\n and \\x1a are sent as bytes.",
+			},
+			"bytes": map[string]any{
+				"type":        "array",
+				"items":       map[string]any{"type": "integer"},
+				"description": "Raw bytes to write (0-255 each), instead of data",
+			},
+			"until": map[string]any{
+				"type":        "string",
+				"description": "For read: stop after this delimiter, e.g. \"\\n\" or \"OK\\r\\n\"",
+			},
+			"max_bytes": map[string]any{
+				"type":        "integer",
+				"description": "For read: stop after this many bytes (1-65536). Default: 1024.",
+			},
+			"confirm": map[string]any{
+				"type":        "boolean",
+				"description": "Must be true for write operations. Safety guard to prevent accidental writes.",
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+func (t *SerialTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	if runtime.GOOS != "linux" {
+		return ErrorResult("Serial ports are only supported on Linux.")
+	}
+
+	action, ok := args["action"].(string)
+	if !ok {
+		return ErrorResult("action is required")
+	}
+
+	session := sessionFromContext(ctx)
+	switch action {
+	case "list":
+		return t.list(session)
+	case "open":
+		return t.open(session, args)
+	case "write":
+		return t.withPort(session, args, func(p *serialPort) *ToolResult { return t.write(ctx, p, args) })
+	case "read":
+		return t.withPort(session, args, func(p *serialPort) *ToolResult { return t.read(ctx, p, args) })
+	case "close":
+		return t.withPort(session, args, func(p *serialPort) *ToolResult { return t.closePort(session, p) })
+	default:
+		return ErrorResult(fmt.Sprintf("unknown action: %s (valid: list, open, write, read, close)", action))
+	}
+}
+
+// list finds serial device files and the ports open in session.
+func (t *SerialTool) list(session string) *ToolResult {
+	seen := map[string]bool{}
+	var ports []string
+	for _, pattern := range serialPortPatterns {
+		matches, _ := filepath.Glob(pattern)
+		for _, m := range matches {
+			if !seen[m] {
+				seen[m] = true
+				ports = append(ports, m)
+			}
+		}
+	}
+
+	type openInfo struct {
+		ID   string `json:"id"`
+		Port string `json:"port"`
+		serialSettings
+		TimeoutMS int64 `json:"timeout_ms"`
+	}
+	t.mu.Lock()
+	open := make([]openInfo, 0, len(t.sessions[session]))
+	for _, p := range t.sessions[session] {
+		open = append(open, openInfo{p.id, p.path, p.settings, p.settings.Timeout.Milliseconds()})
+	}
+	t.mu.Unlock()
+
+	if len(ports) == 0 && len(open) == 0 {
+		return SilentResult(
+			"No serial ports found. USB adapters appear as /dev/ttyUSB* or /dev/ttyACM*; on-board UARTs " +
+				"may need to be enabled in the device tree or pinmux (see hardware skill).",
+		)
+	}
+	result, _ := json.MarshalIndent(map[string]any{"ports": ports, "open": open}, "", "  ")
+	return SilentResult(fmt.Sprintf("Found %d serial port(s), %d open:\n%s", len(ports), len(open), result))
+}
+
+func (t *SerialTool) open(session string, args map[string]any) *ToolResult {
+	path, errResult := parseSerialPath(args)
+	if errResult != nil {
+		return errResult
+	}
+	settings, errResult := parseSerialSettings(args)
+	if errResult != nil {
+		return errResult
+	}
+
+	t.mu.Lock()
+	for _, p := range t.sessions[session] {
+		if p.path == path {
+			t.mu.Unlock()
+			return ErrorResult(fmt.Sprintf("%s is already open as %s", path, p.id))
+		}
+	}
+	if len(t.sessions[session]) >= maxSerialPorts {
+		t.mu.Unlock()
+		return ErrorResult(fmt.Sprintf("too many open ports (max %d); close one first", maxSerialPorts))
+	}
+	t.mu.Unlock()
+
+	dev, err := openSerialDevice(path, settings)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to open %s: %v", path, err))
+	}
+
+	t.mu.Lock()
+	t.nextID++
+	p := &serialPort{
+		id:       fmt.Sprintf("serial%d", t.nextID),
+		path:     path,
+		settings: settings,
+		dev:      dev,
+	}
+	t.sessions[session] = append(t.sessions[session], p)
+	t.mu.Unlock()
+
+	return SilentResult(fmt.Sprintf("Opened %s as %s (%d baud, %d%s%d, timeout %dms)", path, p.id,
+		settings.Baud, settings.DataBits, strings.ToUpper(settings.Parity[:1]), settings.StopBits,
+		settings.Timeout.Milliseconds()))
+}
+
+// withPort looks up the port named by args["id"] in session.
+func (t *SerialTool) withPort(session string, args map[string]any, fn func(*serialPort) *ToolResult) *ToolResult {
+	id, _ := args["id"].(string)
+	if id == "" {
+		return ErrorResult("id is required (from the open action)")
+	}
+	t.mu.Lock()
+	var port *serialPort
+	for _, p := range t.sessions[session] {
+		if p.id == id {
+			port = p
+		}
+	}
+	t.mu.Unlock()
+	if port == nil {
+		return ErrorResult(fmt.Sprintf("no open port %s in this session (use list to see open ports)", id))
+	}
+	return fn(port)
+}
+
+func (t *SerialTool) write(ctx context.Context, p *serialPort, args map[string]any) *ToolResult {
+	confirm, _ := args["confirm"].(bool)
+	if !confirm {
+		return ErrorResult(
+			"write operations require confirm: true. Please confirm with the user before writing to serial " +
+				"devices, as commands can change device configuration or firmware.",
+		)
+	}
+	data, errResult := parseSerialData(args)
+	if errResult != nil {
+		return errResult
+	}
+	timeout, errResult := parseSerialTimeout(args, p.settings.Timeout)
+	if errResult != nil {
+		return errResult
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = min(timeout, time.Until(deadline))
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.dev == nil {
+		return ErrorResult(fmt.Sprintf("port %s is closed", p.id))
+	}
+	n, err := p.dev.write(data, timeout)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to write to %s after %d byte(s): %v", p.path, n, err))
+	}
+	return SilentResult(fmt.Sprintf("Wrote %d byte(s) to %s (%s)", n, p.path, p.id))
+}
+
+func (t *SerialTool) read(ctx context.Context, p *serialPort, args map[string]any) *ToolResult {
+	timeout, errResult := parseSerialTimeout(args, p.settings.Timeout)
+	if errResult != nil {
+		return errResult
+	}
+	maxBytes := defaultSerialReadBytes
+	if m, ok := args["max_bytes"].(float64); ok {
+		maxBytes = int(m)
+		if maxBytes < 1 || maxBytes > maxSerialReadBytes {
+			return ErrorResult(fmt.Sprintf("max_bytes must be between 1 and %d", maxSerialReadBytes))
+		}
+	}
+	var until []byte
+	if u, ok := args["until"].(string); ok && u != "" {
+		until = []byte(unescapeSerial(u))
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.dev == nil {
+		return ErrorResult(fmt.Sprintf("port %s is closed", p.id))
+	}
+	data, matched, err := p.readUntil(ctx, until, maxBytes, timeout)
+	if err != nil && len(data) == 0 {
+		return ErrorResult(fmt.Sprintf("failed to read from %s: %v", p.path, err))
+	}
+
+	stop := "timeout"
+	switch {
+	case matched:
+		stop = "delimiter"
+	case len(data) >= maxBytes:
+		stop = "max_bytes"
+	case err != nil:
+		stop = "error: " + err.Error()
+	}
+	out := map[string]any{
+		"id":       p.id,
+		"port":     p.path,
+		"length":   len(data),
+		"text":     string(bytes.ToValidUTF8(data, []byte("\uFFFD"))),
+		"stop":     stop,
+		"buffered": len(p.pending),
+	}
+	if !isPrintableText(data) {
+		hexBytes := make([]string, len(data))
+		for i, b := range data {
+			hexBytes[i] = fmt.Sprintf("0x%02x", b)
+		}
+		out["hex"] = hexBytes
+	}
+	result, _ := json.MarshalIndent(out, "", "  ")
+	return SilentResult(string(result))
+}
+
+// readUntil returns the bytes up to and including until, or up to
+// maxBytes, or whatever arrived before timeout. Data past the stop point
+// stays in p.pending.
+func (p *serialPort) readUntil(ctx context.Context, until []byte, maxBytes int,
+	timeout time.Duration,
+) ([]byte, bool, error) {
+	deadline := time.Now().Add(timeout)
+	buf := make([]byte, 4096)
+	for {
+		if len(until) > 0 {
+			if i := bytes.Index(p.pending, until); i >= 0 && i+len(until) <= maxBytes {
+				return p.take(i + len(until)), true, nil
+			}
+		}
+		if len(p.pending) >= maxBytes {
+			return p.take(maxBytes), false, nil
+		}
+
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			return p.take(len(p.pending)), false, nil
+		}
+		if err := ctx.Err(); err != nil {
+			return p.take(len(p.pending)), false, err
+		}
+		// Wake up regularly so a cancelled context ends the read.
+		n, err := p.dev.read(buf, min(wait, 100*time.Millisecond))
+		p.pending = append(p.pending, buf[:n]...)
+		if err != nil {
+			return p.take(len(p.pending)), false, err
+		}
+	}
+}
+
+func (p *serialPort) take(n int) []byte {
+	data := bytes.Clone(p.pending[:n])
+	p.pending = p.pending[n:]
+	return data
+}
+
+func (t *SerialTool) closePort(session string, p *serialPort) *ToolResult {
+	t.mu.Lock()
+	ports := t.sessions[session]
+	for i, q := range ports {
+		if q == p {
+			t.sessions[session] = append(ports[:i:i], ports[i+1:]...)
+			break
+		}
+	}
+	t.mu.Unlock()
+
+	if err := p.close(); err != nil {
+		return ErrorResult(fmt.Sprintf("failed to close %s: %v", p.path, err))
+	}
+	return SilentResult(fmt.Sprintf("Closed %s (%s)", p.path, p.id))
+}
+
+func (p *serialPort) close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.dev == nil {
+		return nil
+	}
+	err := p.dev.close()
+	p.dev = nil
+	return err
+}
+
+// CloseSession closes every port of a session, e.g. when the conversation
+// is reset, and returns how many were open.
+func (t *SerialTool) CloseSession(session string) int {
+	t.mu.Lock()
+	ports := t.sessions[session]
+	delete(t.sessions, session)
+	t.mu.Unlock()
+	for _, p := range ports {
+		p.close()
+	}
+	return len(ports)
+}
+
+// Close closes every port of every session.
+func (t *SerialTool) Close() {
+	t.mu.Lock()
+	sessions := make([]string, 0, len(t.sessions))
+	for s := range t.sessions {
+		sessions = append(sessions, s)
+	}
+	t.mu.Unlock()
+	sort.Strings(sessions)
+	for _, s := range sessions {
+		t.CloseSession(s)
+	}
+}
+
+// parseSerialPath validates the port argument and returns its device path.
+func parseSerialPath(args map[string]any) (string, *ToolResult) {
+	port, _ := args["port"].(string)
+	if port == "" {
+		return "", ErrorResult("port is required (e.g. \"/dev/ttyUSB0\"; use list to find ports)")
+	}
+	if !strings.HasPrefix(port, "/") {
+		port = "/dev/" + port
+	}
+	path := filepath.Clean(port)
+	for _, pattern := range serialOpenPatterns {
+		if ok, _ := filepath.Match(pattern, path); ok {
+			return path, nil
+		}
+	}
+	return "", ErrorResult("port must be a serial device such as /dev/ttyUSB0, /dev/ttyACM0, /dev/rfcomm0 or " +
+		"/dev/serial/by-id/...")
+}
+
+// serialBaudRates are the baud rates open accepts.
+var serialBaudRates = []int{
+	50, 75, 110, 134, 150, 200, 300, 600, 1200, 1800, 2400, 4800, 9600, 19200, 38400, 57600, 115200, 230400,
+	460800, 500000, 576000, 921600, 1000000, 1152000, 1500000, 2000000, 2500000, 3000000, 3500000, 4000000,
+}
+
+func parseSerialSettings(args map[string]any) (serialSettings, *ToolResult) {
+	s := serialSettings{Baud: 9600, DataBits: 8, Parity: "none", StopBits: 1, Timeout: defaultSerialTimeout}
+	if b, ok := args["baud"].(float64); ok {
+		s.Baud = int(b)
+		found := false
+		for _, rate := range serialBaudRates {
+			found = found || rate == s.Baud
+		}
+		if !found {
+			return s, ErrorResult(fmt.Sprintf("unsupported baud rate %d (common: 9600, 19200, 38400, 57600, 115200)",
+				s.Baud))
+		}
+	}
+	if d, ok := args["data_bits"].(float64); ok {
+		s.DataBits = int(d)
+		if s.DataBits < 5 || s.DataBits > 8 {
+			return s, ErrorResult("data_bits must be between 5 and 8")
+		}
+	}
+	if p, ok := args["parity"].(string); ok && p != "" {
+		s.Parity = strings.ToLower(p)
+		if s.Parity != "none" && s.Parity != "even" && s.Parity != "odd" {
+			return s, ErrorResult("parity must be none, even or odd")
+		}
+	}
+	if sb, ok := args["stop_bits"].(float64); ok {
+		s.StopBits = int(sb)
+		if s.StopBits != 1 && s.StopBits != 2 {
+			return s, ErrorResult("stop_bits must be 1 or 2")
+		}
+	}
+	var errResult *ToolResult
+	s.Timeout, errResult = parseSerialTimeout(args, s.Timeout)
+	return s, errResult
+}
+
+func parseSerialTimeout(args map[string]any, def time.Duration) (time.Duration, *ToolResult) {
+	ms, ok := args["timeout_ms"].(float64)
+	if !ok {
+		return def, nil
+	}
+	timeout := time.Duration(ms) * time.Millisecond
+	if timeout < 0 || timeout > maxSerialTimeout {
+		return 0, ErrorResult(fmt.Sprintf("timeout_ms must be between 0 and %d", maxSerialTimeout.Milliseconds()))
+	}
+	return timeout, nil
+}
+
+// parseSerialData returns the bytes to write from args["data"] or args["bytes"].
+func parseSerialData(args map[string]any) ([]byte, *ToolResult) {
+	text, hasText := args["data"].(string)
+	raw, hasBytes := args["bytes"].([]any)
+	var data []byte
+	switch {
+	case hasText && hasBytes:
+		return nil, ErrorResult("use either data or bytes, not both")
+	case hasText:
+		data = []byte(unescapeSerial(text))
+	case hasBytes:
+		data = make([]byte, 0, len(raw))
+		for i, v := range raw {
+			f, ok := v.(float64)
+			if !ok {
+				return nil, ErrorResult(fmt.Sprintf("bytes[%d] is not a valid byte value", i))
+			}
+			b := int(f)
+			if b < 0 || b > 255 {
+				return nil, ErrorResult(fmt.Sprintf("bytes[%d] = %d is out of byte range (0-255)", i, b))
+			}
+			data = append(data, byte(b))
+		}
+	}
+	if len(data) == 0 {
+		return nil, ErrorResult("data or bytes is required for write")
+	}
+	if len(data) > maxSerialWriteBytes {
+		return nil, ErrorResult(fmt.Sprintf("data too long: maximum %d bytes per write", maxSerialWriteBytes))
+	}
+	return data, nil
+}
+
+// unescapeSerial turns the escapes \r, \n, \t, \0, \\ and \xHH that the
+// model writes literally into the bytes they stand for.
+func unescapeSerial(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' || i+1 == len(s) {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'r':
+			b.WriteByte('\r')
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case '0':
+			b.WriteByte(0)
+		case '\\':
+			b.WriteByte('\\')
+		case 'x':
+			if i+2 < len(s) {
+				if v, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+					b.WriteByte(byte(v))
+					i += 2
+					continue
+				}
+			}
+			b.WriteString(`\x`)
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// isPrintableText reports whether data is UTF-8 text without control
+// characters other than whitespace.
+func isPrintableText(data []byte) bool {
+	if !utf8.Valid(data) {
+		return false
+	}
+	for _, r := range string(data) {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,179 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// termiosBaud maps baud rates to the termios speed constants.
+var termiosBaud = map[int]uint32{
+	50: unix.B50, 75: unix.B75, 110: unix.B110, 134: unix.B134, 150: unix.B150, 200: unix.B200,
+	300: unix.B300, 600: unix.B600, 1200: unix.B1200, 1800: unix.B1800, 2400: unix.B2400, 4800: unix.B4800,
+	9600: unix.B9600, 19200: unix.B19200, 38400: unix.B38400, 57600: unix.B57600, 115200: unix.B115200,
+	230400: unix.B230400, 460800: unix.B460800, 500000: unix.B500000, 576000: unix.B576000,
+	921600: unix.B921600, 1000000: unix.B1000000, 1152000: unix.B1152000, 1500000: unix.B1500000,
+	2000000: unix.B2000000, 2500000: unix.B2500000, 3000000: unix.B3000000, 3500000: unix.B3500000,
+	4000000: unix.B4000000,
+}
+
+// ttyDevice is a serial port opened through the termios interface.
+type ttyDevice struct {
+	fd int
+}
+
+// openSerialDevice opens path without making it the controlling terminal,
+// claims it exclusively and puts it in raw mode with the given settings.
+func openSerialDevice(path string, s serialSettings) (serialDevice, error) {
+	speed, ok := termiosBaud[s.Baud]
+	if !ok {
+		return nil, fmt.Errorf("unsupported baud rate %d", s.Baud)
+	}
+	// Opening a device can have effects of its own, so refuse anything that
+	// is not a character device before opening it.
+	var st unix.Stat_t
+	if err := unix.Stat(path, &st); err != nil {
+		return nil, &os.PathError{Op: "stat", Path: path, Err: err}
+	}
+	if st.Mode&unix.S_IFMT != unix.S_IFCHR {
+		return nil, fmt.Errorf("%s is not a character device", path)
+	}
+	fd, err := unix.Open(path, unix.O_RDWR|unix.O_NOCTTY|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err := configureTTY(fd, speed, s); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+	return &ttyDevice{fd: fd}, nil
+}
+
+func configureTTY(fd int, speed uint32, s serialSettings) error {
+	tio, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return fmt.Errorf("not a serial port: %w", err)
+	}
+	// Keep other processes from opening the port while it is in use.
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), unix.TIOCEXCL, 0); errno != 0 {
+		return fmt.Errorf("claiming port: %w", errno)
+	}
+
+	// Raw mode, as cfmakeraw: no echo, no line editing, no translation.
+	tio.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL |
+		unix.IXON | unix.IXOFF | unix.IXANY | unix.INPCK
+	tio.Oflag &^= unix.OPOST
+	tio.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	tio.Cflag &^= unix.CSIZE | unix.PARENB | unix.PARODD | unix.CSTOPB | unix.CRTSCTS | unix.CBAUD
+	tio.Cflag |= unix.CREAD | unix.CLOCAL | speed
+	switch s.DataBits {
+	case 5:
+		tio.Cflag |= unix.CS5
+	case 6:
+		tio.Cflag |= unix.CS6
+	case 7:
+		tio.Cflag |= unix.CS7
+	default:
+		tio.Cflag |= unix.CS8
+	}
+	switch s.Parity {
+	case "even":
+		tio.Cflag |= unix.PARENB
+		tio.Iflag |= unix.INPCK
+	case "odd":
+		tio.Cflag |= unix.PARENB | unix.PARODD
+		tio.Iflag |= unix.INPCK
+	}
+	if s.StopBits == 2 {
+		tio.Cflag |= unix.CSTOPB
+	}
+	// Reads never block in the kernel; readiness is waited for with poll.
+	tio.Cc[unix.VMIN] = 0
+	tio.Cc[unix.VTIME] = 0
+
+	if err := unix.IoctlSetTermios(fd, unix.TCSETS, tio); err != nil {
+		return fmt.Errorf("configuring port: %w", err)
+	}
+	// Drop anything received before the port was configured.
+	return unix.IoctlSetInt(fd, unix.TCFLSH, unix.TCIFLUSH)
+}
+
+func (d *ttyDevice) read(buf []byte, wait time.Duration) (int, error) {
+	ready, err := d.poll(unix.POLLIN, wait)
+	if err != nil || !ready {
+		return 0, err
+	}
+	n, err := unix.Read(d.fd, buf)
+	if errors.Is(err, unix.EAGAIN) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, errors.New("device disconnected")
+	}
+	return n, nil
+}
+
+func (d *ttyDevice) write(data []byte, wait time.Duration) (int, error) {
+	deadline := time.Now().Add(wait)
+	written := 0
+	for written < len(data) {
+		n, err := unix.Write(d.fd, data[written:])
+		if n > 0 {
+			written += n
+		}
+		switch {
+		case err == nil || errors.Is(err, unix.EINTR):
+			continue
+		case !errors.Is(err, unix.EAGAIN):
+			return written, err
+		}
+		ready, err := d.poll(unix.POLLOUT, time.Until(deadline))
+		if err != nil {
+			return written, err
+		}
+		if !ready {
+			return written, errors.New("timed out waiting for the device to accept data")
+		}
+	}
+	// Wait until the data has been transmitted, so a following read sees the reply.
+	if err := unix.IoctlSetInt(d.fd, unix.TCSBRK, 1); err != nil && !errors.Is(err, unix.ENOTTY) {
+		return written, err
+	}
+	return written, nil
+}
+
+// poll waits up to wait for events on the device and reports whether it is
+// ready. A hang-up is reported as an error.
+func (d *ttyDevice) poll(events int16, wait time.Duration) (bool, error) {
+	deadline := time.Now().Add(wait)
+	for {
+		ms := int(time.Until(deadline).Milliseconds())
+		fds := []unix.PollFd{{Fd: int32(d.fd), Events: events}}
+		n, err := unix.Poll(fds, max(ms, 0))
+		if errors.Is(err, unix.EINTR) {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		if n == 0 {
+			return false, nil
+		}
+		if fds[0].Revents&events != 0 {
+			return true, nil
+		}
+		if fds[0].Revents&(unix.POLLHUP|unix.POLLERR|unix.POLLNVAL) != 0 {
+			return false, errors.New("device disconnected")
+		}
+	}
+}
+
+func (d *ttyDevice) close() error {
+	return unix.Close(d.fd)
+}
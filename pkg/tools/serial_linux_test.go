@@ -0,0 +1,213 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// openPTY returns the master side of a new pseudo-terminal pair and the
+// path of its slave, which the tool opens like a serial port.
+func openPTY(t *testing.T) (*os.File, string) {
+	t.Helper()
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		t.Skipf("pseudo-terminals not available: %v", err)
+	}
+	t.Cleanup(func() { master.Close() })
+
+	var n int
+	raw, err := master.SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw.Control(func(fd uintptr) {
+		if err = unix.IoctlSetPointerInt(int(fd), unix.TIOCSPTLCK, 0); err == nil {
+			n, err = unix.IoctlGetInt(int(fd), unix.TIOCGPTN)
+		}
+	})
+	if err != nil {
+		t.Fatalf("unlocking pty: %v", err)
+	}
+	return master, fmt.Sprintf("/dev/pts/%d", n)
+}
+
+func readMaster(t *testing.T, master *os.File, n int) string {
+	t.Helper()
+	master.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 0, n)
+	chunk := make([]byte, n)
+	for len(buf) < n {
+		m, err := master.Read(chunk[:n-len(buf)])
+		if err != nil {
+			t.Fatalf("reading pty master after %q: %v", buf, err)
+		}
+		buf = append(buf, chunk[:m]...)
+	}
+	return string(buf)
+}
+
+func openSerial(ctx context.Context, t *testing.T, tool *SerialTool, args map[string]any) string {
+	t.Helper()
+	result := tool.Execute(ctx, args)
+	if result.IsError {
+		t.Fatalf("open: %s", result.ForLLM)
+	}
+	id := strings.Fields(strings.SplitN(result.ForLLM, " as ", 2)[1])[0]
+	return id
+}
+
+func readSerial(ctx context.Context, t *testing.T, tool *SerialTool, args map[string]any) map[string]any {
+	t.Helper()
+	args["action"] = "read"
+	result := tool.Execute(ctx, args)
+	if result.IsError {
+		t.Fatalf("read: %s", result.ForLLM)
+	}
+	var out map[string]any
+	if err := json.Unmarshal([]byte(result.ForLLM), &out); err != nil {
+		t.Fatalf("read result %q: %v", result.ForLLM, err)
+	}
+	return out
+}
+
+func TestSerialTool_WriteRead(t *testing.T) {
+	master, slave := openPTY(t)
+	tool := NewSerialTool()
+	defer tool.Close()
+	ctx := WithChat(context.Background(), "telegram", "chat1")
+
+	id := openSerial(ctx, t, tool, map[string]any{"action": "open", "port": slave, "baud": 115200.0, "timeout_ms": 200.0})
+
+	result := tool.Execute(ctx, map[string]any{"action": "write", "id": id, "data": `AT\r\n`})
+	if !result.IsError || !strings.Contains(result.ForLLM, "confirm") {
+		t.Errorf("write without confirm: %s", result.ForLLM)
+	}
+	result = tool.Execute(ctx, map[string]any{"action": "write", "id": id, "data": `AT\r\n`, "confirm": true})
+	if result.IsError {
+		t.Fatalf("write: %s", result.ForLLM)
+	}
+	if got := readMaster(t, master, 4); got != "AT\r\n" {
+		t.Errorf("device received %q", got)
+	}
+	tool.Execute(ctx, map[string]any{"action": "write", "id": id, "bytes": []any{1.0, 255.0}, "confirm": true})
+	if got := readMaster(t, master, 2); got != "\x01\xff" {
+		t.Errorf("device received %q", got)
+	}
+
+	master.Write([]byte("$GPGGA,1\r\n$GPRMC,2\r\nOK"))
+	out := readSerial(ctx, t, tool, map[string]any{"id": id, "until": `\r\n`})
+	if out["text"] != "$GPGGA,1\r\n" || out["stop"] != "delimiter" {
+		t.Errorf("first line = %v", out)
+	}
+	out = readSerial(ctx, t, tool, map[string]any{"id": id, "until": `\r\n`})
+	if out["text"] != "$GPRMC,2\r\n" || out["stop"] != "delimiter" || out["buffered"] != 2.0 {
+		t.Errorf("second line = %v", out)
+	}
+	out = readSerial(ctx, t, tool, map[string]any{"id": id, "until": `\r\n`, "timeout_ms": 50.0})
+	if out["text"] != "OK" || out["stop"] != "timeout" {
+		t.Errorf("partial line = %v", out)
+	}
+
+	master.Write([]byte{0x00, 0x10, 0x20, 0x30})
+	out = readSerial(ctx, t, tool, map[string]any{"id": id, "max_bytes": 3.0})
+	if out["stop"] != "max_bytes" || fmt.Sprint(out["hex"]) != "[0x00 0x10 0x20]" {
+		t.Errorf("binary read = %v", out)
+	}
+}
+
+func TestSerialTool_Sessions(t *testing.T) {
+	_, slave := openPTY(t)
+	tool := NewSerialTool()
+	defer tool.Close()
+	ctx := WithChat(context.Background(), "telegram", "chat1")
+
+	id := openSerial(ctx, t, tool, map[string]any{"action": "open", "port": slave, "parity": "even", "stop_bits": 2.0})
+	if result := tool.Execute(ctx, map[string]any{"action": "open", "port": slave}); !result.IsError {
+		t.Error("opening a port twice should fail")
+	}
+	if result := tool.Execute(ctx, map[string]any{"action": "list"}); !strings.Contains(result.ForLLM, slave) ||
+		!strings.Contains(result.ForLLM, `"parity": "even"`) {
+		t.Errorf("list = %s", result.ForLLM)
+	}
+
+	// Another chat cannot use the port.
+	other := WithChat(context.Background(), "discord", "chat2")
+	if result := tool.Execute(other, map[string]any{"action": "read", "id": id}); !result.IsError {
+		t.Error("port should not be visible to another session")
+	}
+	if result := tool.Execute(other, map[string]any{"action": "close", "id": id}); !result.IsError {
+		t.Error("another session should not close the port")
+	}
+	if result := tool.Execute(other, map[string]any{"action": "list"}); strings.Contains(result.ForLLM, id) {
+		t.Errorf("another session lists the port: %s", result.ForLLM)
+	}
+
+	if n := tool.CloseSession(ProcessSessionKey("telegram", "chat1")); n != 1 {
+		t.Errorf("CloseSession closed %d ports, want 1", n)
+	}
+	if result := tool.Execute(ctx, map[string]any{"action": "close", "id": id}); !result.IsError {
+		t.Error("port should be gone after CloseSession")
+	}
+	id = openSerial(ctx, t, tool, map[string]any{"action": "open", "port": slave})
+	if result := tool.Execute(ctx, map[string]any{"action": "close", "id": id}); result.IsError {
+		t.Errorf("close: %s", result.ForLLM)
+	}
+}
+
+func TestSerialTool_InvalidArgs(t *testing.T) {
+	tool := NewSerialTool()
+	ctx := context.Background()
+	for _, args := range []map[string]any{
+		{"action": "open"},
+		{"action": "open", "port": "../etc/passwd"},
+		{"action": "open", "port": "/dev/null"},
+		{"action": "open", "port": "watchdog"},
+		{"action": "open", "port": "/dev/mem"},
+		{"action": "open", "port": "/dev/serial/../mmcblk0"},
+		{"action": "open", "port": "ttyUSB0", "baud": 12345.0},
+		{"action": "open", "port": "ttyUSB0", "data_bits": 9.0},
+		{"action": "open", "port": "ttyUSB0", "parity": "mark"},
+		{"action": "open", "port": "ttyUSB0", "timeout_ms": 120000.0},
+		{"action": "read", "id": "serial1"},
+		{"action": "flash"},
+	} {
+		if result := tool.Execute(ctx, args); !result.IsError {
+			t.Errorf("Execute(%v) should fail, got: %s", args, result.ForLLM)
+		}
+	}
+}
+
+func TestUnescapeSerial(t *testing.T) {
+	tests := map[string]string{
+		`AT\r\n`:     "AT\r\n",
+		`\x1a`:       "\x1a",
+		`a\\n`:       `a\n`,
+		`\xZZ\q\`:    `\xZZ\q\`,
+		"plain text": "plain text",
+	}
+	for in, want := range tests {
+		if got := unescapeSerial(in); got != want {
+			t.Errorf("unescapeSerial(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestOpenSerialDevice_RequiresCharDevice(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ttyUSB0")
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := openSerialDevice(path, serialSettings{Baud: 9600, DataBits: 8, Parity: "none", StopBits: 1})
+	if err == nil || !strings.Contains(err.Error(), "not a character device") {
+		t.Errorf("openSerialDevice(regular file) = %v", err)
+	}
+}
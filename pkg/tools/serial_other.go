@@ -0,0 +1,10 @@
+//go:build !linux
+
+package tools
+
+import "errors"
+
+// openSerialDevice is a stub for non-Linux platforms.
+func openSerialDevice(path string, s serialSettings) (serialDevice, error) {
+	return nil, errors.New("serial ports are only supported on Linux")
+}
@@ -1,7 +1,6 @@
 package tools
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -14,6 +13,7 @@ import (
 	"time"
 
 	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/sandbox"
 )
 
 type ExecTool struct {
@@ -24,6 +24,9 @@ type ExecTool struct {
 	restrictToWorkspace bool
 	permStore           *PermissionStore
 	permFn              PermissionFunc
+	sandbox             *sandbox.Policy
+	outputBytes         int
+	spillBytes          int64
 }
 
 func (t *ExecTool) SetPermission(store *PermissionStore, fn PermissionFunc) {
@@ -90,9 +93,16 @@ func NewExecTool(workingDir string, restrict bool) (*ExecTool, error) {
 
 func NewExecToolWithConfig(workingDir string, restrict bool, config *config.Config) (*ExecTool, error) {
 	denyPatterns := make([]*regexp.Regexp, 0)
+	outputBytes, spillBytes := DefaultExecOutputBytes, DefaultExecSpillBytes
 
 	if config != nil {
 		execConfig := config.Tools.Exec
+		if execConfig.MaxOutputBytes > 0 {
+			outputBytes = execConfig.MaxOutputBytes
+		}
+		if execConfig.MaxSpillBytes != 0 {
+			spillBytes = max(execConfig.MaxSpillBytes, 0)
+		}
 		enableDenyPatterns := execConfig.EnableDenyPatterns
 		if enableDenyPatterns {
 			denyPatterns = append(denyPatterns, defaultDenyPatterns...)
@@ -120,6 +130,8 @@ func NewExecToolWithConfig(workingDir string, restrict bool, config *config.Conf
 		denyPatterns:        denyPatterns,
 		allowPatterns:       nil,
 		restrictToWorkspace: restrict,
+		outputBytes:         outputBytes,
+		spillBytes:          int64(spillBytes),
 	}, nil
 }
 
@@ -154,27 +166,8 @@ func (t *ExecTool) Execute(ctx context.Context, args map[string]any) *ToolResult
 		return ErrorResult("command is required")
 	}
 
-	cwd := t.workingDir
-	if wd, ok := args["working_dir"].(string); ok && wd != "" {
-		if t.restrictToWorkspace && t.workingDir != "" {
-			resolvedWD, err := validatePath(wd, t.workingDir, true)
-			if err != nil {
-				return ErrorResult("Command blocked by safety guard (" + err.Error() + ")")
-			}
-			cwd = resolvedWD
-		} else {
-			cwd = wd
-		}
-	}
-
-	if cwd == "" {
-		wd, err := os.Getwd()
-		if err == nil {
-			cwd = wd
-		}
-	}
-
-	if guardError := t.guardCommandWithPermission(ctx, command, cwd); guardError != "" {
+	cwd, guardError := t.prepareCommand(ctx, command, args)
+	if guardError != "" {
 		return ErrorResult(guardError)
 	}
 
@@ -188,22 +181,23 @@ func (t *ExecTool) Execute(ctx context.Context, args map[string]any) *ToolResult
 	}
 	defer cancel()
 
-	var cmd *exec.Cmd
-	if runtime.GOOS == "windows" {
-		cmd = exec.CommandContext(cmdCtx, "powershell", "-NoProfile", "-NonInteractive", "-Command", command)
-	} else {
-		cmd = exec.CommandContext(cmdCtx, "sh", "-c", command)
-	}
-	if cwd != "" {
-		cmd.Dir = cwd
+	cmd := shellCommand(cmdCtx, command, cwd)
+	if t.sandbox != nil {
+		if err := t.sandbox.Apply(cmd); err != nil {
+			return ErrorResult(fmt.Sprintf("sandbox: %v", err)).WithError(err)
+		}
 	}
 
-	prepareCommandForTermination(cmd)
-
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	spillDir := filepath.Join(os.TempDir(), "picoclaw"+execOutputDir)
+	if t.workingDir != "" {
+		spillDir = filepath.Join(t.workingDir, execOutputDir)
+	}
+	stdout := newOutputCapture(t.outputBytes, spillDir, "exec-*-stdout.log", t.spillBytes)
+	stderr := newOutputCapture(t.outputBytes, spillDir, "exec-*-stderr.log", t.spillBytes)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
 
+	started := time.Now()
 	if err := cmd.Start(); err != nil {
 		return ErrorResult(fmt.Sprintf("failed to start command: %v", err))
 	}
@@ -228,20 +222,38 @@ func (t *ExecTool) Execute(ctx context.Context, args map[string]any) *ToolResult
 		}
 	}
 
+	info := &ExecInfo{
+		ExitCode:    cmd.ProcessState.ExitCode(),
+		Duration:    time.Since(started),
+		TimedOut:    errors.Is(cmdCtx.Err(), context.DeadlineExceeded),
+		StdoutBytes: stdout.total,
+		StderrBytes: stderr.total,
+		Truncated:   stdout.truncated() || stderr.truncated(),
+	}
+	for _, c := range []*outputCapture{stdout, stderr} {
+		if path := c.close(); path != "" {
+			info.OutputFiles = append(info.OutputFiles, path)
+		}
+	}
+
 	output := stdout.String()
-	if stderr.Len() > 0 {
+	if stderr.total > 0 {
 		output += "\nSTDERR:\n" + stderr.String()
 	}
 
-	if err != nil {
-		if errors.Is(cmdCtx.Err(), context.DeadlineExceeded) {
-			msg := fmt.Sprintf("Command timed out after %v", t.timeout)
-			return &ToolResult{
-				ForLLM:  msg,
-				ForUser: msg,
-				IsError: true,
-			}
+	if info.TimedOut {
+		msg := fmt.Sprintf("Command timed out after %v", t.timeout)
+		if output != "" {
+			msg += "\nOutput before timeout:\n" + output
+		}
+		return &ToolResult{
+			ForLLM:  msg + "\n" + info.String(),
+			ForUser: msg,
+			IsError: true,
+			Exec:    info,
 		}
+	}
+	if err != nil {
 		output += fmt.Sprintf("\nExit code: %v", err)
 	}
 
@@ -249,24 +261,55 @@ func (t *ExecTool) Execute(ctx context.Context, args map[string]any) *ToolResult
 		output = "(no output)"
 	}
 
-	maxLen := 10000
-	if len(output) > maxLen {
-		output = output[:maxLen] + fmt.Sprintf("\n... (truncated, %d more chars)", len(output)-maxLen)
+	return &ToolResult{
+		ForLLM:  output + "\n" + info.String(),
+		ForUser: output,
+		IsError: err != nil,
+		Exec:    info,
 	}
+}
 
-	if err != nil {
-		return &ToolResult{
-			ForLLM:  output,
-			ForUser: output,
-			IsError: true,
+// prepareCommand resolves the working directory from args and runs the
+// safety guards. It returns the directory, or a guard error message.
+func (t *ExecTool) prepareCommand(ctx context.Context, command string, args map[string]any) (string, string) {
+	cwd := t.workingDir
+	if wd, ok := args["working_dir"].(string); ok && wd != "" {
+		if t.restrictToWorkspace && t.workingDir != "" {
+			resolvedWD, err := validatePath(wd, t.workingDir, true)
+			if err != nil {
+				return "", "Command blocked by safety guard (" + err.Error() + ")"
+			}
+			cwd = resolvedWD
+		} else {
+			cwd = wd
 		}
 	}
 
-	return &ToolResult{
-		ForLLM:  output,
-		ForUser: output,
-		IsError: false,
+	if cwd == "" {
+		wd, err := os.Getwd()
+		if err == nil {
+			cwd = wd
+		}
 	}
+
+	return cwd, t.guardCommandWithPermission(ctx, command, cwd)
+}
+
+// shellCommand builds the platform shell invocation of command, set up so
+// terminateProcessTree can kill everything it spawns.
+func shellCommand(ctx context.Context, command, cwd string) *exec.Cmd {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "powershell", "-NoProfile", "-NonInteractive", "-Command", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	if cwd != "" {
+		cmd.Dir = cwd
+	}
+
+	prepareCommandForTermination(cmd)
+	return cmd
 }
 
 func (t *ExecTool) guardCommand(command, cwd string) string {
@@ -411,6 +454,12 @@ func (t *ExecTool) SetTimeout(timeout time.Duration) {
 	t.timeout = timeout
 }
 
+// SetSandbox confines the commands run by this tool (and the process tool
+// built on it); nil runs them unconfined.
+func (t *ExecTool) SetSandbox(policy *sandbox.Policy) {
+	t.sandbox = policy
+}
+
 func (t *ExecTool) SetRestrictToWorkspace(restrict bool) {
 	t.restrictToWorkspace = restrict
 }
@@ -122,6 +122,7 @@ func RunToolLoop(
 		messages = append(messages, assistantMsg)
 
 		// 7. Execute tool calls
+		var media []string
 		for _, tc := range normalizedToolCalls {
 			argsJSON, _ := json.Marshal(tc.Arguments)
 			argsPreview := utils.Truncate(string(argsJSON), 200)
@@ -152,6 +153,10 @@ func RunToolLoop(
 				ToolCallID: tc.ID,
 			}
 			messages = append(messages, toolResultMsg)
+			media = append(media, toolResult.ForLLMMedia...)
+		}
+		if len(media) > 0 {
+			messages = append(messages, MediaMessage(media))
 		}
 	}
 
@@ -160,3 +165,16 @@ func RunToolLoop(
 		Iterations: iteration,
 	}, nil
 }
+
+// MediaMessage carries the files that tool results attached for the LLM
+// (ToolResult.ForLLMMedia). Providers only accept media on user messages,
+// so it follows the tool results as one, marked so that providers do not
+// take it for the start of a new turn.
+func MediaMessage(media []string) providers.Message {
+	return providers.Message{
+		Role:      "user",
+		Content:   fmt.Sprintf("[%d file(s) attached by the tool results above]", len(media)),
+		Media:     media,
+		ToolMedia: true,
+	}
+}
@@ -3,14 +3,22 @@ package tools
 import (
 	"bytes"
 	"context"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"net/url"
 	"regexp"
 	"strings"
 	"time"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+
+	"github.com/sipeed/picoclaw/pkg/egress"
 )
 
 const (
@@ -27,11 +35,7 @@ const (
 
 // Pre-compiled regexes for HTML text extraction
 var (
-	reScript     = regexp.MustCompile(`<script[\s\S]*?</script>`)
-	reStyle      = regexp.MustCompile(`<style[\s\S]*?</style>`)
-	reTags       = regexp.MustCompile(`<[^>]+>`)
-	reWhitespace = regexp.MustCompile(`[^\S\n]+`)
-	reBlankLines = regexp.MustCompile(`\n{3,}`)
+	reTags = regexp.MustCompile(`<[^>]+>`)
 
 	// DuckDuckGo result extraction
 	reDDGLink    = regexp.MustCompile(`<a[^>]*class="[^"]*result__a[^"]*"[^>]*href="([^"]+)"[^>]*>([\s\S]*?)</a>`)
@@ -522,6 +526,7 @@ type WebFetchTool struct {
 	maxChars int
 	proxy    string
 	client   *http.Client
+	policy   *egress.Policy
 }
 
 func NewWebFetchTool(maxChars int) *WebFetchTool {
@@ -531,6 +536,13 @@ func NewWebFetchTool(maxChars int) *WebFetchTool {
 }
 
 func NewWebFetchToolWithProxy(maxChars int, proxy string) (*WebFetchTool, error) {
+	return NewWebFetchToolWithPolicy(maxChars, proxy, nil)
+}
+
+// NewWebFetchToolWithPolicy creates a web_fetch tool whose requests,
+// including redirects, are restricted by the egress policy. A nil policy
+// allows every host.
+func NewWebFetchToolWithPolicy(maxChars int, proxy string, policy *egress.Policy) (*WebFetchTool, error) {
 	if maxChars <= 0 {
 		maxChars = defaultMaxChars
 	}
@@ -538,6 +550,7 @@ func NewWebFetchToolWithProxy(maxChars int, proxy string) (*WebFetchTool, error)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create HTTP client for web fetch: %w", err)
 	}
+	client.Transport = policy.Transport(client.Transport.(*http.Transport))
 	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
 		if len(via) >= maxRedirects {
 			return fmt.Errorf("stopped after %d redirects", maxRedirects)
@@ -548,6 +561,7 @@ func NewWebFetchToolWithProxy(maxChars int, proxy string) (*WebFetchTool, error)
 		maxChars: maxChars,
 		proxy:    proxy,
 		client:   client,
+		policy:   policy,
 	}, nil
 }
 
@@ -556,7 +570,10 @@ func (t *WebFetchTool) Name() string {
 }
 
 func (t *WebFetchTool) Description() string {
-	return "Fetch a URL and extract readable content (HTML to text). Use this to get weather info, news, articles, or any web content."
+	return "Fetch a URL and extract its readable content: the main text of HTML pages as Markdown " +
+		"(headings, lists, links and tables kept, navigation and footers dropped), PDF text, " +
+		"CSV as a table, or JSON. Use this to get weather info, news, articles, or any web content. " +
+		"Long content is returned in parts; use start_index to continue where the previous part ended."
 }
 
 func (t *WebFetchTool) Parameters() map[string]any {
@@ -572,6 +589,11 @@ func (t *WebFetchTool) Parameters() map[string]any {
 				"description": "Maximum characters to extract",
 				"minimum":     100.0,
 			},
+			"start_index": map[string]any{
+				"type":        "integer",
+				"description": "Character offset to start from, to read the next part of long content (default 0)",
+				"minimum":     0.0,
+			},
 		},
 		"required": []string{"url"},
 	}
@@ -596,12 +618,20 @@ func (t *WebFetchTool) Execute(ctx context.Context, args map[string]any) *ToolRe
 		return ErrorResult("missing domain in URL")
 	}
 
+	if err := t.policy.CheckHost(parsedURL.Hostname()); err != nil {
+		return ErrorResult(err.Error())
+	}
+
 	maxChars := t.maxChars
 	if mc, ok := args["maxChars"].(float64); ok {
 		if int(mc) > 100 {
 			maxChars = int(mc)
 		}
 	}
+	startIndex := 0
+	if si, ok := args["start_index"].(float64); ok && si > 0 {
+		startIndex = int(si)
+	}
 
 	req, err := http.NewRequestWithContext(ctx, "GET", urlStr, nil)
 	if err != nil {
@@ -616,80 +646,174 @@ func (t *WebFetchTool) Execute(ctx context.Context, args map[string]any) *ToolRe
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	body, bodyTruncated, err := t.policy.ReadBody(resp.Body)
 	if err != nil {
 		return ErrorResult(fmt.Sprintf("failed to read response: %v", err))
 	}
 
-	contentType := resp.Header.Get("Content-Type")
-
-	var text, extractor string
-
-	if strings.Contains(contentType, "application/json") {
-		var jsonData any
-		if err := json.Unmarshal(body, &jsonData); err == nil {
-			formatted, _ := json.MarshalIndent(jsonData, "", "  ")
-			text = string(formatted)
-			extractor = "json"
-		} else {
-			text = string(body)
-			extractor = "raw"
-		}
-	} else if strings.Contains(contentType, "text/html") || len(body) > 0 &&
-		(strings.HasPrefix(string(body), "<!DOCTYPE") || strings.HasPrefix(strings.ToLower(string(body)), "<html")) {
-		text = t.extractText(string(body))
-		extractor = "text"
-	} else {
-		text = string(body)
-		extractor = "raw"
+	content, err := extractWebContent(body, resp.Header.Get("Content-Type"), resp.Request.URL,
+		pdfDecodeFactor*t.policy.MaxResponseBytes())
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to extract content from %s: %v", urlStr, err))
 	}
 
-	truncated := len(text) > maxChars
-	if truncated {
-		text = text[:maxChars]
+	// Pages are counted in characters so that start_index never splits one.
+	chars := []rune(content.text)
+	if startIndex > 0 && startIndex >= len(chars) {
+		return ErrorResult(fmt.Sprintf("start_index %d is past the end of the content (%d characters)",
+			startIndex, len(chars)))
 	}
+	end := min(startIndex+maxChars, len(chars))
+	text := string(chars[startIndex:end])
+	more := end < len(chars)
+	truncated := more || bodyTruncated
 
 	result := map[string]any{
-		"url":       urlStr,
-		"status":    resp.StatusCode,
-		"extractor": extractor,
-		"truncated": truncated,
-		"length":    len(text),
-		"text":      text,
+		"url":          urlStr,
+		"status":       resp.StatusCode,
+		"extractor":    content.extractor,
+		"truncated":    truncated,
+		"length":       end - startIndex,
+		"start_index":  startIndex,
+		"total_length": len(chars),
+		"text":         text,
+	}
+	if content.title != "" {
+		result["title"] = content.title
+	}
+	if more {
+		result["next_index"] = end
 	}
 
 	resultJSON, _ := json.MarshalIndent(result, "", "  ")
 
+	var llm strings.Builder
+	fmt.Fprintf(&llm, "Fetched %d bytes from %s (extractor: %s, truncated: %v)\n",
+		len(text), urlStr, content.extractor, truncated)
+	if content.title != "" {
+		fmt.Fprintf(&llm, "Title: %s\n", content.title)
+	}
+	if startIndex > 0 || more {
+		fmt.Fprintf(&llm, "Showing characters %d-%d of %d\n", startIndex, end, len(chars))
+	}
+	llm.WriteString("\n")
+	llm.WriteString(text)
+	if more {
+		fmt.Fprintf(&llm, "\n\n[Content continues. Call web_fetch with start_index=%d to read more.]", end)
+	}
+	if bodyTruncated {
+		fmt.Fprintf(&llm, "\n\n[The response exceeded %d bytes; the rest was not downloaded.]",
+			t.policy.MaxResponseBytes())
+	}
+
 	return &ToolResult{
-		ForLLM: fmt.Sprintf(
-			"Fetched %d bytes from %s (extractor: %s, truncated: %v)",
-			len(text),
-			urlStr,
-			extractor,
-			truncated,
-		),
+		ForLLM:  llm.String(),
 		ForUser: string(resultJSON),
 	}
 }
 
-func (t *WebFetchTool) extractText(htmlContent string) string {
-	result := reScript.ReplaceAllLiteralString(htmlContent, "")
-	result = reStyle.ReplaceAllLiteralString(result, "")
-	result = reTags.ReplaceAllLiteralString(result, "")
+// webContent is the text web_fetch extracted from a response.
+type webContent struct {
+	title     string
+	text      string
+	extractor string
+}
 
-	result = strings.TrimSpace(result)
+// extractWebContent converts a response body to text according to its
+// content type: the main content of HTML pages as Markdown, PDF text, CSV
+// as a Markdown table, formatted JSON, or plain text. maxDecoded bounds the
+// bytes decompressed from a PDF.
+func extractWebContent(body []byte, contentType string, pageURL *url.URL, maxDecoded int64) (webContent, error) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "" || mediaType == "application/octet-stream" {
+		mediaType, _, _ = mime.ParseMediaType(http.DetectContentType(body))
+	}
 
-	result = reWhitespace.ReplaceAllString(result, " ")
-	result = reBlankLines.ReplaceAllString(result, "\n\n")
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		var jsonData any
+		if err := json.Unmarshal(body, &jsonData); err == nil {
+			formatted, _ := json.MarshalIndent(jsonData, "", "  ")
+			return webContent{text: string(formatted), extractor: "json"}, nil
+		}
+		return webContent{text: string(body), extractor: "raw"}, nil
+
+	case mediaType == "application/pdf" || bytes.HasPrefix(body, []byte("%PDF-")):
+		text, err := extractPDFText(body, maxDecoded)
+		if err != nil {
+			return webContent{}, err
+		}
+		return webContent{text: text, extractor: "pdf"}, nil
+
+	case mediaType == "text/html" || mediaType == "application/xhtml+xml" || looksLikeHTML(body):
+		doc, err := html.Parse(bytes.NewReader(decodeCharset(body, contentType)))
+		if err != nil {
+			return webContent{}, err
+		}
+		page := extractReadable(doc, pageURL)
+		return webContent{title: page.Title, text: page.Markdown, extractor: "readability"}, nil
 
-	lines := strings.Split(result, "\n")
-	var cleanLines []string
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line != "" {
-			cleanLines = append(cleanLines, line)
+	case mediaType == "text/csv" || mediaType == "application/csv" || mediaType == "text/tab-separated-values":
+		comma := ','
+		if mediaType == "text/tab-separated-values" {
+			comma = '\t'
 		}
+		text := decodeCharset(body, contentType)
+		if table, err := csvToMarkdown(text, comma); err == nil {
+			return webContent{text: table, extractor: "csv"}, nil
+		}
+		return webContent{text: string(text), extractor: "raw"}, nil
+	}
+
+	if isBinary(body[:min(len(body), sniffBytes)]) {
+		return webContent{}, fmt.Errorf("unsupported content type %s", mediaType)
+	}
+	return webContent{text: string(decodeCharset(body, contentType)), extractor: "raw"}, nil
+}
+
+func looksLikeHTML(body []byte) bool {
+	start := strings.ToLower(string(bytes.TrimSpace(body[:min(len(body), 512)])))
+	return strings.HasPrefix(start, "<!doctype html") || strings.HasPrefix(start, "<html")
+}
+
+// decodeCharset converts text in the charset named by the content type or
+// an HTML meta tag to UTF-8. Undeclared text is taken as UTF-8 when valid.
+func decodeCharset(body []byte, contentType string) []byte {
+	enc, name, certain := charset.DetermineEncoding(body, contentType)
+	if name == "utf-8" || !certain && utf8.Valid(body) {
+		return body
+	}
+	if decoded, err := enc.NewDecoder().Bytes(body); err == nil {
+		return decoded
+	}
+	return body
+}
+
+// csvToMarkdown formats CSV data as a Markdown table.
+func csvToMarkdown(data []byte, comma rune) (string, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comma = comma
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	records, err := r.ReadAll()
+	if err != nil {
+		return "", err
+	}
+	cols := 0
+	for _, rec := range records {
+		cols = max(cols, len(rec))
 	}
+	if cols == 0 {
+		return "", nil
+	}
+	return markdownTable(records, cols), nil
+}
 
-	return strings.Join(cleanLines, "\n")
+// extractText returns the main content of an HTML page as Markdown.
+func (t *WebFetchTool) extractText(htmlContent string) string {
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return ""
+	}
+	return extractReadable(doc, nil).Markdown
 }
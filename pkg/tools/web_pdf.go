@@ -0,0 +1,750 @@
+package tools
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+const (
+	// maxPDFStreamBytes caps the decompressed size of a single PDF stream.
+	maxPDFStreamBytes = 32 << 20
+	// pdfDecodeFactor times the response size limit caps the bytes
+	// decompressed from a whole PDF.
+	pdfDecodeFactor = 4
+)
+
+var (
+	rePDFObject   = regexp.MustCompile(`(\d+)\s+\d+\s+obj\b`)
+	rePDFRef      = regexp.MustCompile(`^\s*(\d+)\s+\d+\s+R`)
+	rePDFRefs     = regexp.MustCompile(`(\d+)\s+\d+\s+R`)
+	rePDFFontRefs = regexp.MustCompile(`/([^\s/<>\[\]()]+)\s+(\d+)\s+\d+\s+R`)
+	rePDFFontRes  = regexp.MustCompile(`/Font\s*(?:<<([^>]*)>>|(\d+)\s+\d+\s+R)`)
+	rePDFStream   = regexp.MustCompile(`\bstream\r?\n`)
+)
+
+// pdfDoc holds the objects of a PDF file needed to extract its text.
+// Streams are decoded only when needed, and all decoding shares one budget,
+// so a small file of highly compressed streams cannot exhaust memory.
+type pdfDoc struct {
+	objects   map[int]string    // object dictionaries, without stream data
+	streams   map[int]pdfStream // raw stream data
+	order     []int             // object numbers in file order
+	budget    int64             // bytes left to decompress
+	exhausted bool              // a stream was skipped for lack of budget
+}
+
+// pdfStream is the undecoded data of a stream object.
+type pdfStream struct {
+	dict string
+	raw  []byte
+}
+
+// extractPDFText returns the text of a PDF, page by page. It understands
+// uncompressed and Flate-compressed content streams, compressed object
+// streams and ToUnicode character maps, which covers the PDFs produced by
+// common office and publishing tools. Scanned PDFs have no text to extract.
+// At most maxDecoded bytes are decompressed across the whole document.
+func extractPDFText(data []byte, maxDecoded int64) (string, error) {
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		return "", errors.New("not a PDF file")
+	}
+	doc := parsePDF(data, maxDecoded)
+	if doc.encrypted(data) {
+		return "", errors.New("encrypted PDFs are not supported")
+	}
+
+	fonts := doc.fontMaps()
+	var pages []string
+	pageContents, guessed := doc.pageContents()
+	for _, contents := range pageContents {
+		var sb strings.Builder
+		for _, num := range contents {
+			content := doc.stream(num)
+			if guessed && !isPDFContentStream(content) {
+				continue
+			}
+			sb.WriteString(extractPDFContentText(content, fonts))
+			sb.WriteString("\n")
+		}
+		if text := strings.TrimSpace(sb.String()); text != "" {
+			pages = append(pages, text)
+		}
+	}
+	text := strings.Join(pages, "\n\n")
+	if text == "" {
+		if doc.exhausted {
+			return "", fmt.Errorf("the PDF decompresses to more than %d bytes", maxDecoded)
+		}
+		return "", errors.New("the PDF has no extractable text (it may be scanned)")
+	}
+	if doc.exhausted {
+		text += fmt.Sprintf("\n\n[The rest of the PDF was not read: it decompresses to more than %d bytes.]", maxDecoded)
+	}
+	return text, nil
+}
+
+// encrypted reports whether the trailer, or the cross-reference stream
+// that replaces it, names an encryption dictionary.
+func (d *pdfDoc) encrypted(data []byte) bool {
+	if i := bytes.LastIndex(data, []byte("trailer")); i >= 0 && bytes.Contains(data[i:], []byte("/Encrypt")) {
+		return true
+	}
+	for _, obj := range d.objects {
+		if strings.Contains(obj, "/XRef") && strings.Contains(obj, "/Encrypt") {
+			return true
+		}
+	}
+	return false
+}
+
+func parsePDF(data []byte, maxDecoded int64) *pdfDoc {
+	doc := &pdfDoc{objects: make(map[int]string), streams: make(map[int]pdfStream), budget: maxDecoded}
+	locs := rePDFObject.FindAllSubmatchIndex(data, -1)
+	for i, loc := range locs {
+		num, _ := strconv.Atoi(string(data[loc[2]:loc[3]]))
+		end := len(data)
+		if i+1 < len(locs) {
+			end = locs[i+1][0]
+		}
+		body := data[loc[1]:end]
+		if j := bytes.Index(body, []byte("endobj")); j >= 0 && !bytes.Contains(body[:j], []byte("stream")) {
+			body = body[:j]
+		}
+
+		var raw []byte
+		isStream := false
+		if s := rePDFStream.FindIndex(body); s != nil {
+			raw = body[s[1]:]
+			if e := bytes.LastIndex(raw, []byte("endstream")); e >= 0 {
+				raw = bytes.TrimRight(raw[:e], "\r\n")
+			}
+			body = body[:s[0]]
+			isStream = true
+		}
+
+		if _, seen := doc.objects[num]; !seen {
+			doc.order = append(doc.order, num)
+		}
+		dict := string(body)
+		doc.objects[num] = dict
+		if isStream {
+			doc.streams[num] = pdfStream{dict: dict, raw: raw}
+			if strings.Contains(dict, "/ObjStm") {
+				if stream := doc.stream(num); stream != nil {
+					doc.addObjectStream(dict, stream)
+				}
+			}
+		}
+	}
+	return doc
+}
+
+// stream returns the decoded data of stream object num, or nil if it is
+// not a stream, cannot be decoded or the decoding budget is spent. The
+// result is not kept, so each stream should be decoded once.
+func (d *pdfDoc) stream(num int) []byte {
+	s, ok := d.streams[num]
+	if !ok {
+		return nil
+	}
+	if !strings.Contains(s.dict, "/Filter") {
+		// Unfiltered data aliases the file and costs nothing.
+		return decodePDFStream(s.dict, s.raw, 0)
+	}
+	limit := min(d.budget, maxPDFStreamBytes)
+	if limit <= 0 {
+		d.exhausted = true
+		return nil
+	}
+	out := decodePDFStream(s.dict, s.raw, limit)
+	d.budget -= int64(len(out))
+	if int64(len(out)) >= limit && limit < maxPDFStreamBytes {
+		d.exhausted = true
+	}
+	return out
+}
+
+// decodePDFStream decodes stream data with no filter or FlateDecode, up to
+// limit decoded bytes. It returns nil for images and other filters.
+func decodePDFStream(dict string, raw []byte, limit int64) []byte {
+	if strings.Contains(dict, "/Image") {
+		return nil
+	}
+	if !strings.Contains(dict, "/Filter") {
+		return raw
+	}
+	filters := strings.Count(dict, "Decode")
+	if filters != 1 || !strings.Contains(dict, "/FlateDecode") || strings.Contains(dict, "/DecodeParms") {
+		return nil
+	}
+	r, err := zlib.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil
+	}
+	defer r.Close()
+	// Keep what decompresses even if the stream is damaged.
+	out, _ := io.ReadAll(io.LimitReader(r, limit))
+	return out
+}
+
+// addObjectStream adds the objects packed in a compressed object stream.
+func (d *pdfDoc) addObjectStream(dict string, data []byte) {
+	first := pdfInt(dict, "/First")
+	n := pdfInt(dict, "/N")
+	if first <= 0 || first > len(data) || n <= 0 {
+		return
+	}
+	header := strings.Fields(string(data[:first]))
+	for i := 0; i+1 < len(header) && i/2 < n; i += 2 {
+		num, err1 := strconv.Atoi(header[i])
+		off, err2 := strconv.Atoi(header[i+1])
+		if err1 != nil || err2 != nil || first+off > len(data) {
+			continue
+		}
+		end := len(data)
+		if i+3 < len(header) {
+			if next, err := strconv.Atoi(header[i+3]); err == nil && first+next <= len(data) && next >= off {
+				end = first + next
+			}
+		}
+		if _, seen := d.objects[num]; !seen {
+			d.order = append(d.order, num)
+			d.objects[num] = string(data[first+off : end])
+		}
+	}
+}
+
+func pdfInt(dict, key string) int {
+	i := strings.Index(dict, key)
+	if i < 0 {
+		return 0
+	}
+	fields := strings.Fields(dict[i+len(key):])
+	if len(fields) == 0 {
+		return 0
+	}
+	v, _ := strconv.Atoi(strings.TrimRight(fields[0], "/>"))
+	return v
+}
+
+// pdfRef returns the object number of the indirect reference following key
+// in dict, or 0.
+func pdfRef(dict, key string) int {
+	i := strings.Index(dict, key)
+	if i < 0 {
+		return 0
+	}
+	m := rePDFRef.FindStringSubmatch(dict[i+len(key):])
+	if m == nil {
+		return 0
+	}
+	v, _ := strconv.Atoi(m[1])
+	return v
+}
+
+// pageContents returns the content stream objects of each page, in page
+// order. PDFs whose page tree cannot be followed fall back to every stream
+// in file order, with guessed set: the caller must then skip the streams
+// that turn out not to be content streams.
+func (d *pdfDoc) pageContents() (pages [][]int, guessed bool) {
+	seen := make(map[int]bool)
+	var visit func(num int)
+	visit = func(num int) {
+		if seen[num] {
+			return
+		}
+		seen[num] = true
+		obj := d.objects[num]
+		if i := strings.Index(obj, "/Kids"); i >= 0 {
+			for _, kid := range pdfRefArray(obj[i+len("/Kids"):]) {
+				visit(kid)
+			}
+			return
+		}
+		if !strings.Contains(obj, "/Contents") {
+			return
+		}
+		var contents []int
+		if ref := pdfRef(obj, "/Contents"); ref != 0 {
+			if _, ok := d.streams[ref]; ok {
+				contents = []int{ref}
+			} else {
+				// An indirect array of content streams.
+				contents = pdfRefArray(d.objects[ref])
+			}
+		} else if i := strings.Index(obj, "/Contents"); i >= 0 {
+			contents = pdfRefArray(obj[i+len("/Contents"):])
+		}
+		pages = append(pages, contents)
+	}
+	for _, num := range d.order {
+		if obj := d.objects[num]; strings.Contains(obj, "/Catalog") {
+			if root := pdfRef(obj, "/Pages"); root != 0 {
+				visit(root)
+				break
+			}
+		}
+	}
+	if len(pages) > 0 {
+		return pages, false
+	}
+
+	for _, num := range d.order {
+		if s, ok := d.streams[num]; ok && !strings.Contains(s.dict, "/ObjStm") && !strings.Contains(s.dict, "/XRef") {
+			pages = append(pages, []int{num})
+		}
+	}
+	return pages, true
+}
+
+// pdfRefArray parses the object numbers of an array of references that s
+// starts with.
+func pdfRefArray(s string) []int {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "[") {
+		return nil
+	}
+	end := strings.IndexByte(s, ']')
+	if end < 0 {
+		return nil
+	}
+	var nums []int
+	for _, m := range rePDFRefs.FindAllStringSubmatch(s[:end], -1) {
+		v, _ := strconv.Atoi(m[1])
+		nums = append(nums, v)
+	}
+	return nums
+}
+
+func isPDFContentStream(s []byte) bool {
+	return bytes.Contains(s, []byte("BT")) && (bytes.Contains(s, []byte("Tj")) || bytes.Contains(s, []byte("TJ")))
+}
+
+// pdfCMap maps character codes of a font to Unicode text.
+type pdfCMap struct {
+	width int // bytes per character code
+	chars map[uint32]string
+}
+
+// fontMaps returns the ToUnicode maps of the fonts by resource name. Names
+// are not scoped to pages, which is enough for most files.
+func (d *pdfDoc) fontMaps() map[string]*pdfCMap {
+	cmaps := make(map[int]*pdfCMap)
+	fonts := make(map[string]*pdfCMap)
+	for _, num := range d.order {
+		m := rePDFFontRes.FindStringSubmatch(d.objects[num])
+		if m == nil {
+			continue
+		}
+		res := m[1]
+		if m[2] != "" {
+			n, _ := strconv.Atoi(m[2])
+			res = d.objects[n]
+		}
+		for _, m := range rePDFFontRefs.FindAllStringSubmatch(res, -1) {
+			fontNum, _ := strconv.Atoi(m[2])
+			cmapNum := pdfRef(d.objects[fontNum], "/ToUnicode")
+			if cmapNum == 0 {
+				continue
+			}
+			if _, ok := cmaps[cmapNum]; !ok {
+				cmaps[cmapNum] = parsePDFCMap(d.stream(cmapNum))
+			}
+			if _, ok := fonts[m[1]]; !ok {
+				fonts[m[1]] = cmaps[cmapNum]
+			}
+		}
+	}
+	return fonts
+}
+
+func parsePDFCMap(data []byte) *pdfCMap {
+	cm := &pdfCMap{width: 1, chars: make(map[uint32]string)}
+	s := string(data)
+	if i := strings.Index(s, "begincodespacerange"); i >= 0 {
+		if hexes := pdfHexTokens(s[i:], 1); len(hexes) > 0 && len(hexes[0]) > 0 {
+			cm.width = len(hexes[0])
+		}
+	}
+	for _, section := range pdfSections(s, "beginbfchar", "endbfchar") {
+		hexes := pdfHexTokens(section, -1)
+		for i := 0; i+1 < len(hexes); i += 2 {
+			cm.chars[pdfCode(hexes[i])] = utf16BEString(hexes[i+1])
+		}
+	}
+	for _, section := range pdfSections(s, "beginbfrange", "endbfrange") {
+		for _, line := range strings.Split(section, "\n") {
+			hexes := pdfHexTokens(line, -1)
+			if len(hexes) < 3 {
+				continue
+			}
+			lo, hi := pdfCode(hexes[0]), pdfCode(hexes[1])
+			if hi < lo || hi-lo > 0xFFFF {
+				continue
+			}
+			if strings.Contains(line, "[") {
+				for i, dst := range hexes[2:] {
+					cm.chars[lo+uint32(i)] = utf16BEString(dst)
+				}
+				continue
+			}
+			dst := utf16.Decode(utf16BE(hexes[2]))
+			if len(dst) == 0 {
+				continue
+			}
+			for code := lo; code <= hi; code++ {
+				r := append([]rune(nil), dst...)
+				r[len(r)-1] += rune(code - lo)
+				cm.chars[code] = string(r)
+			}
+		}
+	}
+	return cm
+}
+
+func pdfSections(s, begin, end string) []string {
+	var sections []string
+	for {
+		i := strings.Index(s, begin)
+		if i < 0 {
+			return sections
+		}
+		s = s[i+len(begin):]
+		j := strings.Index(s, end)
+		if j < 0 {
+			return append(sections, s)
+		}
+		sections = append(sections, s[:j])
+		s = s[j+len(end):]
+	}
+}
+
+// pdfHexTokens decodes up to n <hex> tokens of s (all when n < 0).
+func pdfHexTokens(s string, n int) [][]byte {
+	var out [][]byte
+	for n < 0 || len(out) < n {
+		i := strings.IndexByte(s, '<')
+		if i < 0 {
+			break
+		}
+		j := strings.IndexByte(s[i:], '>')
+		if j < 0 {
+			break
+		}
+		out = append(out, decodePDFHex(s[i+1:i+j]))
+		s = s[i+j+1:]
+	}
+	return out
+}
+
+func decodePDFHex(s string) []byte {
+	s = strings.Join(strings.Fields(s), "")
+	if len(s)%2 == 1 {
+		s += "0"
+	}
+	b, _ := hex.DecodeString(s)
+	return b
+}
+
+func pdfCode(b []byte) uint32 {
+	var v uint32
+	for _, c := range b {
+		v = v<<8 | uint32(c)
+	}
+	return v
+}
+
+func utf16BE(b []byte) []uint16 {
+	u := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		u = append(u, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+	return u
+}
+
+func utf16BEString(b []byte) string {
+	return string(utf16.Decode(utf16BE(b)))
+}
+
+// pdfTextWriter assembles the text shown by a content stream. Text shown
+// on a different line than the previous text starts a new line.
+type pdfTextWriter struct {
+	sb    strings.Builder
+	cmap  *pdfCMap
+	y     float64 // vertical position of the current text line
+	lastY float64 // vertical position of the last text shown
+	shown bool
+	nl    bool // a line break is pending
+	gap   bool // a word gap is pending
+}
+
+func (w *pdfTextWriter) show(s []byte) {
+	text := w.decode(s)
+	if text == "" {
+		return
+	}
+	switch {
+	case w.shown && (w.nl || w.y != w.lastY):
+		if !strings.HasSuffix(w.sb.String(), "\n") {
+			w.sb.WriteByte('\n')
+		}
+	case w.gap && w.shown && !strings.HasSuffix(w.sb.String(), " ") && !strings.HasPrefix(text, " "):
+		w.sb.WriteByte(' ')
+	}
+	w.sb.WriteString(text)
+	w.lastY, w.shown, w.nl, w.gap = w.y, true, false, false
+}
+
+func (w *pdfTextWriter) decode(s []byte) string {
+	var sb strings.Builder
+	if w.cmap == nil {
+		// PDFDocEncoding and WinAnsiEncoding agree with Latin-1 for text.
+		for _, c := range s {
+			if c >= 0x20 || c == '\t' {
+				sb.WriteRune(rune(c))
+			}
+		}
+		return sb.String()
+	}
+	width := w.cmap.width
+	for i := 0; i+width <= len(s); i += width {
+		if r, ok := w.cmap.chars[pdfCode(s[i:i+width])]; ok {
+			sb.WriteString(r)
+		}
+	}
+	return sb.String()
+}
+
+// extractPDFContentText interprets the text operators of a content stream.
+func extractPDFContentText(content []byte, fonts map[string]*pdfCMap) string {
+	w := &pdfTextWriter{}
+	var operands []pdfToken
+	lex := &pdfLexer{data: content}
+	for {
+		tok, ok := lex.next()
+		if !ok {
+			break
+		}
+		if tok.kind != pdfOperator {
+			operands = append(operands, tok)
+			continue
+		}
+		num := func(i int) float64 {
+			if i < len(operands) && operands[i].kind == pdfNumber {
+				v, _ := strconv.ParseFloat(operands[i].text, 64)
+				return v
+			}
+			return 0
+		}
+		switch tok.text {
+		case "BT":
+			w.y = 0
+		case "Tf":
+			if len(operands) >= 2 && operands[0].kind == pdfName {
+				w.cmap = fonts[operands[0].text]
+			}
+		case "Td", "TD":
+			if len(operands) >= 2 {
+				w.y += num(len(operands) - 1)
+				w.gap = true
+			}
+		case "Tm":
+			if len(operands) >= 6 {
+				w.y = num(len(operands) - 1)
+				w.gap = true
+			}
+		case "T*":
+			w.nl = true
+		case "Tj":
+			if n := len(operands); n > 0 && operands[n-1].kind == pdfString {
+				w.show(operands[n-1].data)
+			}
+		case "'", "\"":
+			w.nl = true
+			if n := len(operands); n > 0 && operands[n-1].kind == pdfString {
+				w.show(operands[n-1].data)
+			}
+		case "TJ":
+			for _, el := range operands {
+				switch el.kind {
+				case pdfString:
+					w.show(el.data)
+				case pdfNumber:
+					// Large negative adjustments are word gaps.
+					if v, _ := strconv.ParseFloat(el.text, 64); v < -200 {
+						w.gap = true
+					}
+				}
+			}
+		case "BI":
+			lex.skipInlineImage()
+		}
+		operands = operands[:0]
+	}
+	return w.sb.String()
+}
+
+type pdfTokenKind int
+
+const (
+	pdfOperator pdfTokenKind = iota
+	pdfNumber
+	pdfString
+	pdfName
+	pdfOther
+)
+
+type pdfToken struct {
+	kind pdfTokenKind
+	text string // operator, number or name
+	data []byte // string bytes
+}
+
+// pdfLexer splits a content stream into tokens. The elements of arrays
+// are returned as separate tokens; brackets and dictionaries are skipped.
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+func (l *pdfLexer) next() (pdfToken, bool) {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		switch {
+		case isSpaceByte(c) || c == 0 || c == '[' || c == ']':
+			l.pos++
+		case c == '%':
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		case c == '(':
+			return pdfToken{kind: pdfString, data: l.literalString()}, true
+		case c == '<':
+			if l.pos+1 < len(l.data) && l.data[l.pos+1] == '<' {
+				l.pos += 2
+				return pdfToken{kind: pdfOther}, true
+			}
+			end := bytes.IndexByte(l.data[l.pos:], '>')
+			if end < 0 {
+				l.pos = len(l.data)
+				return pdfToken{}, false
+			}
+			s := string(l.data[l.pos+1 : l.pos+end])
+			l.pos += end + 1
+			return pdfToken{kind: pdfString, data: decodePDFHex(s)}, true
+		case c == '>':
+			l.pos++
+			if l.pos < len(l.data) && l.data[l.pos] == '>' {
+				l.pos++
+			}
+		case c == '/':
+			l.pos++
+			return pdfToken{kind: pdfName, text: l.word()}, true
+		default:
+			w := l.word()
+			if w == "" {
+				l.pos++
+				continue
+			}
+			if _, err := strconv.ParseFloat(w, 64); err == nil {
+				return pdfToken{kind: pdfNumber, text: w}, true
+			}
+			return pdfToken{kind: pdfOperator, text: w}, true
+		}
+	}
+	return pdfToken{}, false
+}
+
+func (l *pdfLexer) word() string {
+	start := l.pos
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if isSpaceByte(c) || c == 0 || strings.IndexByte("()<>[]{}/%", c) >= 0 {
+			break
+		}
+		l.pos++
+	}
+	return string(l.data[start:l.pos])
+}
+
+// literalString reads a (...) string, handling escapes and nested
+// parentheses.
+func (l *pdfLexer) literalString() []byte {
+	l.pos++ // (
+	var out []byte
+	depth := 1
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return out
+			}
+		case '\\':
+			if l.pos >= len(l.data) {
+				return out
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'b', 'f':
+			case '\r', '\n':
+				// Line continuation.
+				if e == '\r' && l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+			case '0', '1', '2', '3', '4', '5', '6', '7':
+				v := int(e - '0')
+				for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+					v = v*8 + int(l.data[l.pos]-'0')
+					l.pos++
+				}
+				out = append(out, byte(v))
+			default:
+				out = append(out, e)
+			}
+			continue
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// skipInlineImage skips the data of an inline image up to its EI operator.
+func (l *pdfLexer) skipInlineImage() {
+	i := bytes.Index(l.data[l.pos:], []byte("ID"))
+	if i < 0 {
+		l.pos = len(l.data)
+		return
+	}
+	l.pos += i + 2
+	for l.pos < len(l.data) {
+		j := bytes.Index(l.data[l.pos:], []byte("EI"))
+		if j < 0 {
+			l.pos = len(l.data)
+			return
+		}
+		l.pos += j + 2
+		if isSpaceByte(l.data[l.pos-3]) && (l.pos == len(l.data) || isSpaceByte(l.data[l.pos])) {
+			return
+		}
+	}
+}
@@ -0,0 +1,588 @@
+package tools
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var (
+	// Class and id names of page furniture that is dropped before looking
+	// for the main content, unless the name also looks like content.
+	reUnlikely = regexp.MustCompile(`(?i)-ad-|^ad-|advert|banner|breadcrumb|combx|comment|community|consent|` +
+		`cookie|disqus|footer|gdpr|masthead|menu|modal|nav|newsletter|pager|pagination|popup|promo|related|` +
+		`share|shoutbox|sidebar|skip|social|sponsor|subscribe|widget`)
+	reLikely = regexp.MustCompile(`(?i)article|body|content|entry|hentry|main|post|story|text|blog`)
+
+	reMarkdownBlankLines = regexp.MustCompile(`\n{3,}`)
+)
+
+// droppedElements never hold readable content.
+var droppedElements = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true, atom.Svg: true,
+	atom.Canvas: true, atom.Iframe: true, atom.Object: true, atom.Embed: true, atom.Button: true,
+	atom.Input: true, atom.Select: true, atom.Textarea: true, atom.Dialog: true, atom.Nav: true,
+	atom.Aside: true, atom.Link: true, atom.Meta: true,
+}
+
+// droppedRoles are ARIA roles of page furniture.
+var droppedRoles = map[string]bool{
+	"navigation": true, "banner": true, "contentinfo": true, "complementary": true,
+	"dialog": true, "alertdialog": true, "menu": true, "menubar": true, "search": true,
+}
+
+// readablePage is the main content of an HTML page.
+type readablePage struct {
+	Title    string
+	Markdown string
+}
+
+// extractReadable finds the main content of an HTML document, leaving out
+// navigation, banners, sidebars and footers, and converts it to Markdown
+// with headings, lists, links and tables preserved. Relative links are
+// resolved against base, which may be nil.
+func extractReadable(doc *html.Node, base *url.URL) readablePage {
+	page := readablePage{Title: documentTitle(doc)}
+	if b := findFirst(doc, atom.Base); b != nil {
+		if href, err := url.Parse(attr(b, "href")); err == nil && attr(b, "href") != "" {
+			if base != nil {
+				href = base.ResolveReference(href)
+			}
+			base = href
+		}
+	}
+
+	body := findFirst(doc, atom.Body)
+	if body == nil {
+		body = doc
+	}
+	pruneNode(body, false)
+
+	c := &markdownConverter{base: base}
+	w := &markdownWriter{}
+	for _, n := range mainContent(body) {
+		c.render(w, n)
+		w.block()
+	}
+	page.Markdown = w.String()
+	return page
+}
+
+// documentTitle returns the og:title or <title> of a document.
+func documentTitle(doc *html.Node) string {
+	var title string
+	walk(doc, func(n *html.Node) bool {
+		switch n.DataAtom {
+		case atom.Meta:
+			if p := attr(n, "property"); p == "og:title" && attr(n, "content") != "" {
+				title = attr(n, "content")
+				return false
+			}
+		case atom.Title:
+			if title == "" {
+				title = textContent(n)
+			}
+		case atom.Body:
+			return false
+		}
+		return true
+	})
+	return strings.Join(strings.Fields(title), " ")
+}
+
+// pruneNode removes the children of n that are not content. Headers and
+// footers are kept inside an article, where they hold its title and byline.
+func pruneNode(n *html.Node, inArticle bool) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		switch {
+		case c.Type == html.CommentNode:
+			n.RemoveChild(c)
+		case c.Type != html.ElementNode:
+		case isFurniture(c, inArticle):
+			n.RemoveChild(c)
+		default:
+			pruneNode(c, inArticle || c.DataAtom == atom.Article || c.DataAtom == atom.Main)
+		}
+		c = next
+	}
+}
+
+func isFurniture(n *html.Node, inArticle bool) bool {
+	if droppedElements[n.DataAtom] {
+		return true
+	}
+	if !inArticle && (n.DataAtom == atom.Header || n.DataAtom == atom.Footer) {
+		return true
+	}
+	if droppedRoles[attr(n, "role")] || attr(n, "aria-hidden") == "true" || hasAttr(n, "hidden") {
+		return true
+	}
+	style := strings.ReplaceAll(strings.ToLower(attr(n, "style")), " ", "")
+	if strings.Contains(style, "display:none") || strings.Contains(style, "visibility:hidden") {
+		return true
+	}
+	switch n.DataAtom {
+	case atom.Html, atom.Body, atom.Article, atom.Main, atom.Table, atom.Tbody, atom.Thead, atom.Tr, atom.Td,
+		atom.Th, atom.Pre, atom.Code, atom.A:
+		return false
+	}
+	names := attr(n, "class") + " " + attr(n, "id")
+	return reUnlikely.MatchString(names) && !reLikely.MatchString(names)
+}
+
+// mainContent picks the nodes holding the main content of body: the
+// largest <article> or <main> element when there is one, otherwise the
+// block whose paragraphs score highest together with related siblings.
+func mainContent(body *html.Node) []*html.Node {
+	for _, a := range []atom.Atom{atom.Article, atom.Main} {
+		var best *html.Node
+		bestLen := 0
+		walk(body, func(n *html.Node) bool {
+			if n.DataAtom == a || a == atom.Main && attr(n, "role") == "main" {
+				if l := len(textContent(n)); l > bestLen {
+					best, bestLen = n, l
+				}
+				return false
+			}
+			return true
+		})
+		if best != nil && bestLen >= 200 {
+			return []*html.Node{best}
+		}
+	}
+
+	scores := make(map[*html.Node]float64)
+	var order []*html.Node
+	add := func(n *html.Node, s float64) {
+		if n == nil || n.Type != html.ElementNode {
+			return
+		}
+		if _, ok := scores[n]; !ok {
+			order = append(order, n)
+		}
+		scores[n] += s
+	}
+	walk(body, func(n *html.Node) bool {
+		switch n.DataAtom {
+		case atom.P, atom.Pre, atom.Td, atom.Blockquote, atom.Li:
+			text := strings.TrimSpace(textContent(n))
+			if len(text) < 25 {
+				return false
+			}
+			s := 1 + float64(strings.Count(text, ",")) + min(float64(len(text))/100, 3)
+			add(n.Parent, s)
+			if n.Parent != nil {
+				add(n.Parent.Parent, s/2)
+			}
+			return false
+		}
+		return true
+	})
+
+	var best *html.Node
+	var bestScore float64
+	for _, n := range order {
+		scores[n] *= 1 - linkDensity(n)
+		if scores[n] > bestScore {
+			best, bestScore = n, scores[n]
+		}
+	}
+	if best == nil || best == body || best.Parent == nil {
+		return []*html.Node{body}
+	}
+
+	// Content split across sibling blocks (e.g. several <div>s of paragraphs).
+	threshold := max(10, bestScore*0.2)
+	var nodes []*html.Node
+	for s := best.Parent.FirstChild; s != nil; s = s.NextSibling {
+		switch {
+		case s == best:
+			nodes = append(nodes, s)
+		case s.Type != html.ElementNode:
+		case scores[s] >= threshold:
+			nodes = append(nodes, s)
+		case s.DataAtom == atom.P || isHeading(s):
+			if text := textContent(s); (isHeading(s) || len(text) > 80) && linkDensity(s) < 0.25 {
+				nodes = append(nodes, s)
+			}
+		}
+	}
+	return nodes
+}
+
+// linkDensity is the share of the text of n that is inside links.
+func linkDensity(n *html.Node) float64 {
+	total := len(strings.TrimSpace(textContent(n)))
+	if total == 0 {
+		return 0
+	}
+	linked := 0
+	walk(n, func(c *html.Node) bool {
+		if c.DataAtom == atom.A {
+			linked += len(strings.TrimSpace(textContent(c)))
+			return false
+		}
+		return true
+	})
+	return float64(linked) / float64(total)
+}
+
+// markdownConverter renders HTML nodes as Markdown.
+type markdownConverter struct {
+	base *url.URL
+}
+
+func (c *markdownConverter) render(w *markdownWriter, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		w.text(n.Data)
+		return
+	case html.ElementNode:
+	default:
+		c.children(w, n)
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		if text := c.inline(n); text != "" {
+			w.block()
+			w.open(strings.Repeat("#", int(n.Data[1]-'0')) + " " + text)
+			w.block()
+		}
+	case atom.P, atom.Div, atom.Section, atom.Article, atom.Main, atom.Header, atom.Footer, atom.Figure,
+		atom.Figcaption, atom.Address, atom.Details, atom.Summary, atom.Dl, atom.Dt, atom.Dd, atom.Form,
+		atom.Fieldset, atom.Center:
+		w.block()
+		c.children(w, n)
+		w.block()
+	case atom.Br:
+		w.lineBreak()
+	case atom.Hr:
+		w.block()
+		w.open("---")
+		w.block()
+	case atom.Pre:
+		w.block()
+		w.open("```\n" + strings.Trim(textContent(n), "\n") + "\n```")
+		w.block()
+	case atom.Code, atom.Kbd, atom.Samp, atom.Tt:
+		if text := strings.Join(strings.Fields(textContent(n)), " "); text != "" {
+			w.open("`" + text + "`")
+		}
+	case atom.Strong, atom.B:
+		c.wrap(w, n, "**")
+	case atom.Em, atom.I:
+		c.wrap(w, n, "*")
+	case atom.Del, atom.S, atom.Strike:
+		c.wrap(w, n, "~~")
+	case atom.A:
+		c.link(w, n)
+	case atom.Img:
+		if alt, src := strings.TrimSpace(attr(n, "alt")), c.resolve(attr(n, "src")); alt != "" && src != "" {
+			w.open("![" + alt + "](" + src + ")")
+		}
+	case atom.Ul, atom.Ol:
+		c.list(w, n)
+	case atom.Blockquote:
+		if text := c.sub(n); text != "" {
+			w.block()
+			lines := strings.Split(text, "\n")
+			for i, line := range lines {
+				lines[i] = strings.TrimRight("> "+line, " ")
+			}
+			w.open(strings.Join(lines, "\n"))
+			w.block()
+		}
+	case atom.Table:
+		c.table(w, n)
+	default:
+		c.children(w, n)
+	}
+}
+
+func (c *markdownConverter) children(w *markdownWriter, n *html.Node) {
+	for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+		c.render(w, ch)
+	}
+}
+
+// sub renders the children of n as a separate Markdown fragment.
+func (c *markdownConverter) sub(n *html.Node) string {
+	w := &markdownWriter{}
+	c.children(w, n)
+	return w.String()
+}
+
+// inline renders the children of n on a single line.
+func (c *markdownConverter) inline(n *html.Node) string {
+	return strings.Join(strings.Fields(c.sub(n)), " ")
+}
+
+func (c *markdownConverter) wrap(w *markdownWriter, n *html.Node, marker string) {
+	if text := c.inline(n); text != "" {
+		w.open(marker + text + marker)
+	}
+}
+
+func (c *markdownConverter) link(w *markdownWriter, n *html.Node) {
+	text := c.inline(n)
+	if text == "" {
+		return
+	}
+	href := attr(n, "href")
+	if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+		w.open(text)
+		return
+	}
+	w.open("[" + text + "](" + c.resolve(href) + ")")
+}
+
+func (c *markdownConverter) resolve(ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || strings.HasPrefix(ref, "data:") {
+		return ""
+	}
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ""
+	}
+	if c.base != nil {
+		u = c.base.ResolveReference(u)
+	}
+	return strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29").Replace(u.String())
+}
+
+func (c *markdownConverter) list(w *markdownWriter, n *html.Node) {
+	ordered := n.DataAtom == atom.Ol
+	num := 1
+	if s, err := strconv.Atoi(attr(n, "start")); err == nil {
+		num = s
+	}
+	w.block()
+	for li := n.FirstChild; li != nil; li = li.NextSibling {
+		if li.Type != html.ElementNode {
+			continue
+		}
+		text := strings.ReplaceAll(c.sub(li), "\n\n", "\n")
+		if text == "" {
+			continue
+		}
+		marker := "- "
+		if ordered {
+			marker = fmt.Sprintf("%d. ", num)
+			num++
+		}
+		lines := strings.Split(text, "\n")
+		for i := 1; i < len(lines); i++ {
+			lines[i] = strings.Repeat(" ", len(marker)) + lines[i]
+		}
+		w.open(marker + strings.Join(lines, "\n"))
+		w.lineBreak()
+	}
+	w.block()
+}
+
+// table renders a data table as a Markdown table. Layout tables, which
+// have a single column or contain other tables, are rendered as blocks.
+func (c *markdownConverter) table(w *markdownWriter, n *html.Node) {
+	var rows [][]string
+	cols := 0
+	nested := false
+	walk(n, func(ch *html.Node) bool {
+		if ch == n {
+			return true
+		}
+		switch ch.DataAtom {
+		case atom.Table:
+			nested = true
+			return false
+		case atom.Tr:
+			var row []string
+			for cell := ch.FirstChild; cell != nil; cell = cell.NextSibling {
+				if cell.DataAtom == atom.Td || cell.DataAtom == atom.Th {
+					row = append(row, c.inline(cell))
+				}
+			}
+			if len(row) > 0 {
+				rows = append(rows, row)
+				cols = max(cols, len(row))
+			}
+			return false
+		}
+		return true
+	})
+	if nested || cols < 2 {
+		w.block()
+		c.children(w, n)
+		w.block()
+		return
+	}
+
+	w.block()
+	w.open(markdownTable(rows, cols))
+	w.block()
+}
+
+// markdownTable formats rows as a Markdown table of cols columns, with the
+// first row as the header.
+func markdownTable(rows [][]string, cols int) string {
+	var sb strings.Builder
+	writeRow := func(row []string) {
+		sb.WriteString("|")
+		for i := range cols {
+			cell := ""
+			if i < len(row) {
+				cell = strings.Join(strings.Fields(row[i]), " ")
+				cell = strings.ReplaceAll(cell, "|", `\|`)
+			}
+			sb.WriteString(" " + cell + " |")
+		}
+		sb.WriteString("\n")
+	}
+	writeRow(rows[0])
+	writeRow(slices.Repeat([]string{"---"}, cols))
+	for _, row := range rows[1:] {
+		writeRow(row)
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// markdownWriter accumulates Markdown, collapsing the whitespace of HTML
+// text and separating blocks with blank lines.
+type markdownWriter struct {
+	buf   []byte
+	space bool // whitespace is pending before the next text
+}
+
+// text writes HTML text with runs of whitespace collapsed.
+func (w *markdownWriter) text(s string) {
+	if s == "" {
+		return
+	}
+	words := strings.Fields(s)
+	if len(words) == 0 || isSpaceByte(s[0]) {
+		w.space = true
+	}
+	for i, word := range words {
+		if i > 0 {
+			w.space = true
+		}
+		w.open(word)
+	}
+	if len(words) > 0 && isSpaceByte(s[len(s)-1]) {
+		w.space = true
+	}
+}
+
+// open writes s as it is, after any pending whitespace.
+func (w *markdownWriter) open(s string) {
+	if w.space && len(w.buf) > 0 && w.buf[len(w.buf)-1] != '\n' {
+		w.buf = append(w.buf, ' ')
+	}
+	w.space = false
+	w.buf = append(w.buf, s...)
+}
+
+func (w *markdownWriter) trimSpace() {
+	for len(w.buf) > 0 && w.buf[len(w.buf)-1] == ' ' {
+		w.buf = w.buf[:len(w.buf)-1]
+	}
+	w.space = false
+}
+
+func (w *markdownWriter) lineBreak() {
+	w.trimSpace()
+	if len(w.buf) > 0 {
+		w.buf = append(w.buf, '\n')
+	}
+}
+
+// block ends the current block with a blank line.
+func (w *markdownWriter) block() {
+	w.trimSpace()
+	for len(w.buf) > 0 && w.buf[len(w.buf)-1] == '\n' {
+		w.buf = w.buf[:len(w.buf)-1]
+	}
+	if len(w.buf) > 0 {
+		w.buf = append(w.buf, '\n', '\n')
+	}
+}
+
+func (w *markdownWriter) String() string {
+	return strings.TrimSpace(reMarkdownBlankLines.ReplaceAllString(string(w.buf), "\n\n"))
+}
+
+func isSpaceByte(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r' || b == '\f'
+}
+
+func isHeading(n *html.Node) bool {
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		return true
+	}
+	return false
+}
+
+// walk calls fn for n and its descendants in document order, skipping the
+// descendants of nodes for which fn returns false.
+func walk(n *html.Node, fn func(*html.Node) bool) {
+	if !fn(n) {
+		return
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		walk(c, fn)
+	}
+}
+
+func findFirst(n *html.Node, a atom.Atom) *html.Node {
+	var found *html.Node
+	walk(n, func(c *html.Node) bool {
+		if found != nil {
+			return false
+		}
+		if c.DataAtom == a {
+			found = c
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+func textContent(n *html.Node) string {
+	var sb strings.Builder
+	walk(n, func(c *html.Node) bool {
+		if c.Type == html.TextNode {
+			sb.WriteString(c.Data)
+		}
+		return true
+	})
+	return sb.String()
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func hasAttr(n *html.Node, key string) bool {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,167 @@
+package tools
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+const articlePage = `<!DOCTYPE html>
+<html><head><title>Fallback title</title><meta property="og:title" content="Readable Go"></head>
+<body>
+<header><a href="/">Home</a> <a href="/blog">Blog</a></header>
+<nav><ul><li><a href="/a">Menu entry</a></li></ul></nav>
+<div class="cookie-banner">We use cookies. <button>Accept cookies</button></div>
+<div id="layout">
+  <div class="post-content">
+    <h1>Readable Go</h1>
+    <p>Extracting the main content of a page, without the navigation, banners and footers around it,
+    makes fetched pages far easier to read.</p>
+    <h2>Details</h2>
+    <p>Links such as <a href="/docs/page?x=1">the docs</a> are kept, and so is <strong>emphasis</strong>,
+    along with lists, tables and code, which carry much of the meaning of technical pages.</p>
+    <ul><li>First item</li><li>Second <em>item</em>
+      <ol><li>Nested</li></ol></li></ul>
+    <table><tr><th>Name</th><th>Value</th></tr><tr><td>a|b</td><td>1</td></tr></table>
+    <pre>func main() {
+	fmt.Println("hi")
+}</pre>
+  </div>
+  <aside class="sidebar"><p>Related posts you might like, with many words to look like content.</p></aside>
+</div>
+<footer>Copyright 2026</footer>
+<script>track()</script>
+</body></html>`
+
+func TestExtractReadable(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(articlePage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, _ := url.Parse("https://example.com/blog/post")
+	page := extractReadable(doc, base)
+
+	if page.Title != "Readable Go" {
+		t.Errorf("Title = %q", page.Title)
+	}
+	for _, want := range []string{
+		"# Readable Go",
+		"## Details",
+		"[the docs](https://example.com/docs/page?x=1)",
+		"**emphasis**",
+		"- First item\n- Second *item*\n  1. Nested",
+		"| Name | Value |\n| --- | --- |\n| a\\|b | 1 |",
+		"```\nfunc main() {\n\tfmt.Println(\"hi\")\n}\n```",
+		"makes fetched pages far easier to read.",
+	} {
+		if !strings.Contains(page.Markdown, want) {
+			t.Errorf("Markdown missing %q:\n%s", want, page.Markdown)
+		}
+	}
+	for _, unwanted := range []string{"Home", "Menu entry", "cookies", "Related posts", "Copyright", "track()"} {
+		if strings.Contains(page.Markdown, unwanted) {
+			t.Errorf("Markdown should not contain %q:\n%s", unwanted, page.Markdown)
+		}
+	}
+}
+
+func TestExtractReadable_PrefersArticle(t *testing.T) {
+	body := strings.Repeat("Article text that is long enough to be the main content of the page. ", 5)
+	doc, _ := html.Parse(strings.NewReader(`<body><div><p>` + strings.Repeat("Teaser text, ", 20) +
+		`</p></div><article><header><h1>Title</h1></header><p>` + body + `</p></article></body>`))
+	page := extractReadable(doc, nil)
+	if !strings.HasPrefix(page.Markdown, "# Title\n\nArticle text") || strings.Contains(page.Markdown, "Teaser") {
+		t.Errorf("Markdown = %q", page.Markdown)
+	}
+}
+
+// buildPDF assembles a PDF from object bodies, numbered from 1. Stream
+// objects are given as their dictionary and data.
+func buildPDF(objects ...string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	for i, obj := range objects {
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	buf.WriteString("trailer\n<< /Root 1 0 R >>\n%%EOF\n")
+	return buf.Bytes()
+}
+
+func flateStream(data string) string {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write([]byte(data))
+	zw.Close()
+	return fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", buf.Len(), buf.String())
+}
+
+func TestExtractPDFText(t *testing.T) {
+	cmap := "/CIDInit /ProcSet findresource begin\nbegincmap\n1 begincodespacerange\n<0000> <FFFF>\n" +
+		"endcodespacerange\n1 beginbfchar\n<0001> <00C9>\nendbfchar\n1 beginbfrange\n<0002> <0004> <0074>\n" +
+		"endbfrange\nendcmap"
+	page1 := "BT /F1 12 Tf 72 720 Td (Hello \\(PDF\\) World) Tj 0 -14 Td [(Sec) 10 (ond) -300 (line)] TJ ET"
+	page2 := "BT /F2 12 Tf 1 0 0 1 72 720 Tm <0001000200030004> Tj ET BT /F1 12 Tf 1 0 0 1 150 720 Tm (same line) Tj ET"
+	data := buildPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [4 0 R 3 0 R] /Count 2 >>",
+		"<< /Type /Page /Parent 2 0 R /Resources << /Font << /F1 6 0 R /F2 7 0 R >> >> /Contents 5 0 R >>",
+		"<< /Type /Page /Parent 2 0 R /Resources << /Font << /F1 6 0 R >> >> /Contents [9 0 R] >>",
+		flateStream(page2),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		"<< /Type /Font /Subtype /Type0 /BaseFont /Custom /ToUnicode 8 0 R >>",
+		flateStream(cmap),
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(page1), page1),
+	)
+
+	text, err := extractPDFText(data, maxPDFStreamBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Hello (PDF) World\nSecond line\n\nÉtuv same line"
+	if text != want {
+		t.Errorf("extractPDFText() = %q, want %q", text, want)
+	}
+
+	if _, err := extractPDFText(buildPDF("<< /Type /Catalog >>"), maxPDFStreamBytes); err == nil {
+		t.Error("expected an error for a PDF without text")
+	}
+	encrypted := append(buildPDF("<< /Type /Catalog >>"), []byte("trailer\n<< /Encrypt 5 0 R >>\n")...)
+	if _, err := extractPDFText(encrypted, maxPDFStreamBytes); err == nil || !strings.Contains(err.Error(), "encrypted") {
+		t.Errorf("encrypted PDF error = %v", err)
+	}
+}
+
+func TestExtractPDFTextDecodeBudget(t *testing.T) {
+	// Each page's content stream decompresses to 1 MB but compresses to
+	// about 1 KB.
+	const pages = 20
+	objects := []string{"<< /Type /Catalog /Pages 2 0 R >>", ""}
+	var kids []string
+	for i := 0; i < pages; i++ {
+		page := len(objects) + 1
+		kids = append(kids, fmt.Sprintf("%d 0 R", page))
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /Contents %d 0 R >>", page+1),
+			flateStream(fmt.Sprintf("BT (page %d) Tj ET ", i)+strings.Repeat(" ", 1<<20)))
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), pages)
+	data := buildPDF(objects...)
+
+	text, err := extractPDFText(data, 5<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(text, "page 0") || strings.Contains(text, "page 5") ||
+		!strings.Contains(text, "The rest of the PDF was not read") {
+		t.Errorf("extractPDFText() = %q", text)
+	}
+
+	if text, err := extractPDFText(data, 64<<20); err != nil || !strings.Contains(text, "page 19") {
+		t.Errorf("extractPDFText() = %q, %v", text, err)
+	}
+}
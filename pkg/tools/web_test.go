@@ -8,6 +8,9 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/egress"
 )
 
 // TestWebTool_WebFetch_Success verifies successful URL fetching
@@ -43,6 +46,51 @@ func TestWebTool_WebFetch_Success(t *testing.T) {
 	}
 }
 
+// TestWebTool_WebFetch_EgressPolicy verifies that private hosts are refused
+// and that response bodies are capped
+func TestWebTool_WebFetch_EgressPolicy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/metadata" {
+			http.Redirect(w, r, "http://169.254.169.254/latest/meta-data/", http.StatusFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(strings.Repeat("x", 200)))
+	}))
+	defer server.Close()
+	ctx := context.Background()
+
+	blocked, err := NewWebFetchToolWithPolicy(50000, "", egress.New(config.EgressConfig{BlockPrivateNetworks: true}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := blocked.Execute(ctx, map[string]any{"url": server.URL})
+	if !result.IsError || !strings.Contains(result.ForLLM, "egress policy") {
+		t.Errorf("expected loopback URL to be blocked, got: %s", result.ForLLM)
+	}
+
+	allowed, err := NewWebFetchToolWithPolicy(50000, "", egress.New(config.EgressConfig{
+		BlockPrivateNetworks: true,
+		AllowHosts:           []string{"127.0.0.1"},
+		MaxResponseBytes:     100,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result = allowed.Execute(ctx, map[string]any{"url": server.URL})
+	if result.IsError || !strings.Contains(result.ForLLM, "truncated: true") {
+		t.Errorf("expected a truncated body, got: %s", result.ForLLM)
+	}
+	if !strings.Contains(result.ForUser, `"length": 100`) {
+		t.Errorf("expected 100 bytes of body, got: %s", result.ForUser)
+	}
+
+	result = allowed.Execute(ctx, map[string]any{"url": server.URL + "/metadata"})
+	if !result.IsError || !strings.Contains(result.ForLLM, "egress policy") {
+		t.Errorf("expected redirect to the metadata service to be blocked, got: %s", result.ForLLM)
+	}
+}
+
 // TestWebTool_WebFetch_JSON verifies JSON content handling
 func TestWebTool_WebFetch_JSON(t *testing.T) {
 	testData := map[string]string{"key": "value", "number": "123"}
@@ -174,6 +222,83 @@ func TestWebTool_WebFetch_Truncation(t *testing.T) {
 	}
 }
 
+// TestWebTool_WebFetch_StartIndex verifies paging through long content
+func TestWebTool_WebFetch_StartIndex(t *testing.T) {
+	content := strings.Repeat("é", 150) + strings.Repeat("b", 100)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(content))
+	}))
+	defer server.Close()
+
+	tool := NewWebFetchTool(200)
+	ctx := context.Background()
+
+	result := tool.Execute(ctx, map[string]any{"url": server.URL})
+	if result.IsError || !strings.Contains(result.ForLLM, "start_index=200") {
+		t.Fatalf("expected a continuation hint, got: %s", result.ForLLM)
+	}
+	resultMap := make(map[string]any)
+	json.Unmarshal([]byte(result.ForUser), &resultMap)
+	if resultMap["next_index"] != 200.0 || resultMap["total_length"] != 250.0 {
+		t.Errorf("unexpected paging fields: %v", resultMap)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"url": server.URL, "start_index": 200.0})
+	resultMap = make(map[string]any)
+	json.Unmarshal([]byte(result.ForUser), &resultMap)
+	if resultMap["text"] != strings.Repeat("b", 50) || resultMap["truncated"] != false {
+		t.Errorf("second part = %v", resultMap)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"url": server.URL, "start_index": 300.0})
+	if !result.IsError {
+		t.Errorf("expected an error past the end, got: %s", result.ForLLM)
+	}
+}
+
+// TestWebTool_WebFetch_Formats verifies CSV, PDF and binary responses
+func TestWebTool_WebFetch_Formats(t *testing.T) {
+	pdf := buildPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 4 0 R >>",
+		flateStream("BT /F1 12 Tf 72 720 Td (PDF body text) Tj ET"),
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/data.csv":
+			w.Header().Set("Content-Type", "text/csv")
+			w.Write([]byte("name,count\nfoo,1\n\"bar, baz\",2\n"))
+		case "/doc.pdf":
+			w.Header().Set("Content-Type", "application/pdf")
+			w.Write(pdf)
+		default:
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write([]byte{0x7f, 'E', 'L', 'F', 0, 0, 1, 2})
+		}
+	}))
+	defer server.Close()
+
+	tool := NewWebFetchTool(50000)
+	ctx := context.Background()
+
+	result := tool.Execute(ctx, map[string]any{"url": server.URL + "/data.csv"})
+	if !strings.Contains(result.ForLLM, "| name | count |\n| --- | --- |\n| foo | 1 |\n| bar, baz | 2 |") {
+		t.Errorf("CSV result: %s", result.ForLLM)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"url": server.URL + "/doc.pdf"})
+	if !strings.Contains(result.ForLLM, "extractor: pdf") || !strings.Contains(result.ForLLM, "PDF body text") {
+		t.Errorf("PDF result: %s", result.ForLLM)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"url": server.URL + "/bin"})
+	if !result.IsError || !strings.Contains(result.ForLLM, "unsupported content type") {
+		t.Errorf("binary result: %s", result.ForLLM)
+	}
+}
+
 // TestWebTool_WebSearch_NoApiKey verifies that no tool is created when API key is missing
 func TestWebTool_WebSearch_NoApiKey(t *testing.T) {
 	tool, err := NewWebSearchTool(WebSearchToolOptions{BraveEnabled: true, BraveAPIKey: ""})